@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"url-shortner/internal/server"
+)
+
+// runExport writes the archive of an account, as in: api export -email me@example.com -out export.zip
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the user whose account is exported")
+	out := flags.String("out", "export.zip", "zip file the archive is written to")
+	baseUrl := flags.String("base-url", "http://localhost:"+os.Getenv("PORT"), "base of the short urls written out")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+
+	if err := server.Export(file, *email, *baseUrl); err != nil {
+		file.Close()
+		os.Remove(*out)
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"url-shortner/internal/server"
+)
+
+// runImport creates the links of a CSV file, as in: api import -file links.csv -email me@example.com
+// Created links are written to stdout as CSV, rows that could not be imported to stderr
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	path := flags.String("file", "", "CSV file to import, with a link_to_short column")
+	email := flags.String("email", "", "email of the user the links are created for")
+	organizationId := flags.Int("organization-id", 0, "organization the links are created for, if any")
+	baseUrl := flags.String("base-url", "http://localhost:"+os.Getenv("PORT"), "base of the short urls written out")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" || *email == "" {
+		return fmt.Errorf("-file and -email are required")
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var organization *int
+	if *organizationId != 0 {
+		organization = organizationId
+	}
+
+	result, err := server.Import(file, *email, organization, *baseUrl)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(os.Stdout)
+	out.Write([]string{"line", "short_code", "short_url"})
+	for _, created := range result.Created {
+		out.Write([]string{strconv.Itoa(created.Line), created.ShortCode, created.ShortUrl})
+	}
+	out.Flush()
+
+	for _, rowError := range result.Errors {
+		fmt.Fprintf(os.Stderr, "line %d: %s\n", rowError.Line, rowError.Error)
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d of %d rows\n", len(result.Created), result.Rows)
+
+	return out.Error()
+}
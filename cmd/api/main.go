@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"url-shortner/internal/logging"
 	"url-shortner/internal/server"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/systemd"
 )
 
 func gracefulShutdown(apiServer *http.Server, done chan bool) {
@@ -21,6 +27,7 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	<-ctx.Done()
 
 	log.Println("shutting down gracefully, press Ctrl+C again to force")
+	systemd.Notify(systemd.Stopping)
 
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
@@ -30,17 +37,47 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 		log.Printf("Server forced to shutdown with error: %v", err)
 	}
 
+	// No more redirects come in, the clicks still buffered are stored before exiting
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDrain()
+	if err := shortener.DrainClicks(drainCtx); err != nil {
+		log.Printf("[api:gracefulShutdown] Could not store the buffered clicks: %v", err)
+	}
+
 	log.Println("Server exiting")
 
 	// Notify the main goroutine that the shutdown is complete
 	done <- true
 }
 
+// Commands run instead of the api, e.g. ./api import -file links.csv -email me@example.com
+var commands = map[string]func(args []string) error{
+	"import": runImport,
+	"export": runExport,
+}
+
 func main() {
 
-	log.SetPrefix("[API] ")
+	if err := logging.Setup("api"); err != nil {
+		log.Fatalf("[api:main] Invalid logging configuration: %v", err)
+	}
 	log.Println("[api:main] Running api")
 
+	if len(os.Args) > 1 {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			log.Fatalf("[api:main] Unknown command {%s}, expected import or export", os.Args[1])
+		}
+		if err := command(os.Args[2:]); err != nil {
+			log.Fatalf("[api:main] %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	if err := server.Preflight(); err != nil {
+		log.Fatalf("[api:main] Startup checks failed: %v", err)
+	}
+
 	server := server.NewServer()
 
 	// Create a done channel to signal when the shutdown is complete
@@ -49,7 +86,17 @@ func main() {
 	// Run graceful shutdown in a separate goroutine
 	go gracefulShutdown(server, done)
 
-	err := server.ListenAndServe()
+	listener, err := listen(server.Addr)
+	if err != nil {
+		log.Fatalf("[api:main] Could not listen: %v", err)
+	}
+
+	if err := systemd.Notify(systemd.Ready); err != nil {
+		log.Printf("[api:main] Could not notify systemd: %v", err)
+	}
+	systemd.StartWatchdog(answers(listener.Addr()))
+
+	err = server.Serve(listener)
 	if err != nil && err != http.ErrServerClosed {
 		panic(fmt.Sprintf("http server error: %s", err))
 	}
@@ -58,3 +105,37 @@ func main() {
 	<-done
 	log.Println("Graceful shutdown complete.")
 }
+
+// listen returns the socket passed by systemd when the api is socket activated, a new one on addr otherwise
+func listen(addr string) (net.Listener, error) {
+	listener, err := systemd.Listener()
+	if err != nil || listener != nil {
+		return listener, err
+	}
+	return net.Listen("tcp", addr)
+}
+
+// answers returns a check of the api answering /livez on addr, so the systemd watchdog restarts an api that hangs
+func answers(addr net.Addr) func() bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		// e.g. a unix socket passed by systemd, only the process being alive can be told
+		return func() bool { return true }
+	}
+
+	host := "127.0.0.1"
+	if !tcp.IP.IsUnspecified() {
+		host = tcp.IP.String()
+	}
+	url := "http://" + net.JoinHostPort(host, strconv.Itoa(tcp.Port)) + "/livez"
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	return func() bool {
+		resp, err := client.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"url-shortner/internal/safebrowsing"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Every job the cronjobs know of, some only scheduled when their service is configured
+var jobNames = []string{
+	"delete_expired_links", "disable_banned_links", "process_deletion_requests", "verify_domains", "purge_raw_ips",
+	"rollup_clicks", "purge_click_events", "refresh_stats_views", "maintain_tables", "manage_click_partitions",
+	"delete_finished_jobs", "export_to_warehouse", "scan_unsafe_links", "send_digests", "delete_ended_sessions",
+	"send_notifications",
+}
+
+// The jobs working in batches, with the largest batch they take, 0 when unbounded
+var batchJobs = map[string]int{
+	"export_to_warehouse": 0,
+	"scan_unsafe_links":   safebrowsing.MaxBatchSize,
+	"send_digests":        0,
+	"send_notifications":  0,
+}
+
+// The entry of JOBS_FILE whose timeout and concurrency apply to the jobs not setting theirs
+const defaultsEntry = "defaults"
+
+// jobConfig is the configuration of a job in JOBS_FILE. Fields left out keep the defaults of the job
+type jobConfig struct {
+	// Standard cron expression replacing the schedule of the job
+	Schedule string `yaml:"schedule"`
+
+	// A disabled job isn't scheduled, it can still be run on demand
+	Enabled *bool `yaml:"enabled"`
+
+	// Rows handled at a time, for the jobs of batchJobs
+	BatchSize int `yaml:"batch_size"`
+
+	// How long a run may take, e.g. 5m. The run is failed past it, and the context given to the job cancelled
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Databases, one per tenant, the job runs against at once. 1 when unset
+	Concurrency int `yaml:"concurrency"`
+}
+
+// The configuration of the jobs by name, see loadJobConfigs
+var jobConfigs = map[string]*jobConfig{}
+
+// loadJobConfigs reads JOBS_FILE, a YAML mapping of job names to their configuration, and validates it. Without it
+// every job keeps its defaults
+func loadJobConfigs() (map[string]*jobConfig, error) {
+	configs := map[string]*jobConfig{}
+
+	path := os.Getenv("JOBS_FILE")
+	if path == "" {
+		return configs, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JOBS_FILE: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&configs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid JOBS_FILE: %w", err)
+	}
+
+	for name, config := range configs {
+		if name != defaultsEntry && !slices.Contains(jobNames, name) {
+			return nil, fmt.Errorf("invalid JOBS_FILE: unknown job %q", name)
+		}
+		if config == nil {
+			configs[name] = &jobConfig{}
+			continue
+		}
+		if name == defaultsEntry && (config.Schedule != "" || config.Enabled != nil || config.BatchSize != 0) {
+			return nil, fmt.Errorf("invalid JOBS_FILE: %s only takes a timeout and a concurrency", defaultsEntry)
+		}
+		if config.Schedule != "" {
+			if _, err := cron.ParseStandard(config.Schedule); err != nil {
+				return nil, fmt.Errorf("invalid JOBS_FILE: schedule of %s: %w", name, err)
+			}
+		}
+		if config.Timeout < 0 {
+			return nil, fmt.Errorf("invalid JOBS_FILE: timeout of %s can't be negative", name)
+		}
+		if config.Concurrency < 0 {
+			return nil, fmt.Errorf("invalid JOBS_FILE: concurrency of %s can't be negative", name)
+		}
+		if config.BatchSize != 0 {
+			largest, ok := batchJobs[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid JOBS_FILE: %s doesn't work in batches", name)
+			}
+			if config.BatchSize < 0 || (largest > 0 && config.BatchSize > largest) {
+				return nil, fmt.Errorf("invalid JOBS_FILE: batch_size of %s must be between 1 and %d", name, largest)
+			}
+		}
+	}
+
+	return configs, nil
+}
+
+// configOf returns the configuration of the job name, with the defaults of JOBS_FILE for what it leaves out
+func configOf(name string) *jobConfig {
+	config := &jobConfig{}
+	if configured, ok := jobConfigs[name]; ok {
+		*config = *configured
+	}
+
+	if defaults, ok := jobConfigs[defaultsEntry]; ok {
+		if config.Timeout == 0 {
+			config.Timeout = defaults.Timeout
+		}
+		if config.Concurrency == 0 {
+			config.Concurrency = defaults.Concurrency
+		}
+	}
+	if config.Concurrency == 0 {
+		config.Concurrency = 1
+	}
+	return config
+}
+
+// batchSize returns the batch size configured for the job name, fallback when there is none
+func batchSize(name string, fallback int) int {
+	if size := configOf(name).BatchSize; size > 0 {
+		return size
+	}
+	return fallback
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestLoadJobConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		invalid string
+	}{
+		{"empty", "", ""},
+		{"overrides", "export_to_warehouse:\n  schedule: \"0 * * * *\"\n  batch_size: 1000\n  timeout: 10m\nverify_domains:\n  enabled: false\n", ""},
+		{"unknown job", "purge_everything:\n  enabled: false\n", "unknown job"},
+		{"unknown field", "verify_domains:\n  retries: 3\n", "retries"},
+		{"invalid schedule", "verify_domains:\n  schedule: every minute\n", "schedule of verify_domains"},
+		{"negative timeout", "verify_domains:\n  timeout: -1m\n", "timeout of verify_domains"},
+		{"batch of a job without batches", "verify_domains:\n  batch_size: 10\n", "doesn't work in batches"},
+		{"batch too large", "scan_unsafe_links:\n  batch_size: 501\n", "batch_size of scan_unsafe_links"},
+		{"defaults", "defaults:\n  timeout: 30m\n  concurrency: 4\n", ""},
+		{"schedule in defaults", "defaults:\n  schedule: \"0 * * * *\"\n", "only takes"},
+		{"negative concurrency", "verify_domains:\n  concurrency: -2\n", "concurrency of verify_domains"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "jobs.yaml")
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("JOBS_FILE", path)
+
+		configs, err := loadJobConfigs()
+		if tt.invalid != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.invalid) {
+				t.Errorf("%s: expected an error about %s; got %v", tt.name, tt.invalid, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if tt.name == "overrides" {
+			export := configs["export_to_warehouse"]
+			if export.Schedule != "0 * * * *" || export.BatchSize != 1000 || export.Timeout != 10*time.Minute {
+				t.Errorf("expected the overrides of export_to_warehouse read; got %+v", export)
+			}
+			if enabled := configs["verify_domains"].Enabled; enabled == nil || *enabled {
+				t.Errorf("expected verify_domains disabled; got %v", enabled)
+			}
+		}
+	}
+}
+
+func TestScheduleWithJobConfigs(t *testing.T) {
+	disabled := false
+	jobConfigs = map[string]*jobConfig{
+		"verify_domains":      {Enabled: &disabled},
+		"refresh_stats_views": {Schedule: "0 * * * *", Timeout: time.Millisecond},
+	}
+	defer func() { jobs, jobConfigs = map[string]*job{}, map[string]*jobConfig{} }()
+
+	c := cron.New()
+	noop := func(ctx context.Context, db database.Service) error { return nil }
+	schedule(c, nil, "verify_domains", "*/5 * * * *", noop)
+	schedule(c, nil, "refresh_stats_views", "*/5 * * * *", func(ctx context.Context, db database.Service) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if len(c.Entries()) != 1 {
+		t.Errorf("expected the disabled job left out of the schedule; got %d entries", len(c.Entries()))
+	}
+	if _, ok := jobs["verify_domains"]; !ok {
+		t.Errorf("expected the disabled job still runnable on demand")
+	}
+	if interval := jobs["refresh_stats_views"].interval; interval != time.Hour {
+		t.Errorf("expected the schedule of the file used; got an interval of %s", interval)
+	}
+
+	db := &mocks.Service{}
+	if err := runNow([]database.Service{db}, "refresh_stats_views"); err == nil {
+		t.Errorf("expected the run cut short by its timeout")
+	}
+	if len(db.CallsTo("FailJobRun")) != 1 {
+		t.Errorf("expected the timed out run recorded as failed; got %+v", db.Calls())
+	}
+}
+
+func TestRunTimesOut(t *testing.T) {
+	jobConfigs = map[string]*jobConfig{defaultsEntry: {Timeout: 10 * time.Millisecond}}
+	defer func() { jobConfigs = map[string]*jobConfig{} }()
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	db := &mocks.Service{}
+	err := run(db, "refresh_stats_views", time.Minute, func(ctx context.Context, db database.Service) error {
+		// Ignores ctx, like a query without a deadline
+		<-stuck
+		return nil
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the run failed once timed out; got %v", err)
+	}
+	if len(db.CallsTo("FailJobRun")) != 1 {
+		t.Errorf("expected the timed out run recorded as failed; got %+v", db.Calls())
+	}
+}
+
+func TestRunAllConcurrency(t *testing.T) {
+	jobConfigs = map[string]*jobConfig{"refresh_stats_views": {Concurrency: 2}}
+	defer func() { jobConfigs = map[string]*jobConfig{} }()
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	j := &job{interval: time.Minute, fn: func(ctx context.Context, db database.Service) error {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}}
+
+	dbs := []database.Service{&mocks.Service{}, &mocks.Service{}, &mocks.Service{}, &mocks.Service{}, &mocks.Service{}}
+	if err := runAll(dbs, "refresh_stats_views", j); err != nil {
+		t.Fatal(err)
+	}
+
+	if most != 2 {
+		t.Errorf("expected 2 databases handled at once; got %d", most)
+	}
+	for i, db := range dbs {
+		if len(db.(*mocks.Service).CallsTo("FinishJobRun")) != 1 {
+			t.Errorf("expected database %d handled", i)
+		}
+	}
+}
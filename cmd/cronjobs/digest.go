@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+)
+
+const (
+	// Recipients read at a time, unless JOBS_FILE sets the batch_size of send_digests
+	digestBatchSize = 100
+
+	// Links and referrers listed in each section of a digest
+	digestTop = 5
+
+	// A user isn't sent another digest this soon after the last, so a rerun of the job in the same week sends nothing
+	digestMinInterval = 6 * 24 * time.Hour
+)
+
+// sendDigests emails the users who want it the digest of their links over the week before now, from the daily
+// rollups. Users with nothing to tell, no click and no link expiring, are skipped. A user whose digest fails is
+// retried on the next run
+func sendDigests(ctx context.Context, db database.Service, m mailer.Mailer, now time.Time) error {
+	until := now.UTC().Truncate(24 * time.Hour)
+	since := until.AddDate(0, 0, -7)
+	size := batchSize("send_digests", digestBatchSize)
+
+	afterId, sent, failed := 0, 0, 0
+	for {
+		users, err := db.ListDigestRecipients(now.Add(-digestMinInterval), afterId, size)
+		if err != nil {
+			return fmt.Errorf("send digests: %w", err)
+		}
+
+		for _, user := range users {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("send digests: stopped after {%d}: %w", sent, err)
+			}
+			afterId = user.Id
+
+			digest, err := db.GetLinkDigest(user.Id, since, until, digestTop)
+			if err != nil {
+				return fmt.Errorf("send digests: %w", err)
+			}
+			if digest.Empty() {
+				continue
+			}
+
+			message, err := mailer.Compose(user.Email, "digest", digest)
+			if err != nil {
+				return fmt.Errorf("send digests: %w", err)
+			}
+			if err := m.Send(ctx, message); err != nil {
+				log.Printf("[cronjobs:sendDigests] Could not send the digest of user {%d}: %v", user.Id, err)
+				failed++
+				continue
+			}
+			if err := db.MarkDigestSent(user.Id, now); err != nil {
+				return fmt.Errorf("send digests: %w", err)
+			}
+			sent++
+		}
+
+		if len(users) < size {
+			break
+		}
+	}
+
+	log.Printf("[cronjobs:sendDigests] Sent {%d} digests, {%d} failed", sent, failed)
+	if failed > 0 {
+		return fmt.Errorf("send digests: %d of %d could not be sent", failed, sent+failed)
+	}
+	return nil
+}
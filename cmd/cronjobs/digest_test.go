@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/mocks"
+)
+
+func TestSendDigests(t *testing.T) {
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt/")
+	now := time.Date(2025, 6, 16, 8, 0, 0, 0, time.UTC)
+
+	digests := map[int]*database.LinkDigestModel{
+		1: {Links: 3, Clicks: 42, TopLinks: []*database.DigestLinkModel{{ShortCode: "docs", Link: "https://example.com/docs", Clicks: 40}},
+			TopReferrers: []*database.ReferrerClicksModel{{Referrer: "news.ycombinator.com", Clicks: 30}}},
+		2: {Links: 1},
+		3: {Links: 1, Expiring: []*database.DigestLinkModel{{ShortCode: "sale", ExpiresAt: now.Add(48 * time.Hour)}}},
+	}
+	db := &mocks.Service{
+		ListDigestRecipientsFunc: func(sentBefore time.Time, afterId int, limit int) ([]*database.UserModel, error) {
+			if afterId > 0 {
+				return nil, nil
+			}
+			return []*database.UserModel{{Id: 1, Email: "ada@example.com"}, {Id: 2, Email: "idle@example.com"}, {Id: 3, Email: "bob@example.com"}}, nil
+		},
+		GetLinkDigestFunc: func(userId int, since time.Time, until time.Time, top int) (*database.LinkDigestModel, error) {
+			digest := digests[userId]
+			digest.Since, digest.Until = since, until
+			return digest, nil
+		},
+	}
+
+	mail := &refusingMailer{refused: "bob@example.com"}
+	err := sendDigests(context.Background(), db, mail, now)
+	if err == nil {
+		t.Errorf("expected the failed digest reported")
+	}
+
+	calls := db.CallsTo("GetLinkDigest")
+	if since, until := calls[0].Args[1].(time.Time), calls[0].Args[2].(time.Time); !since.Equal(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)) || !until.Equal(time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the week before summed up; got %s to %s", since, until)
+	}
+	sent := mail.Messages()
+	if len(sent) != 1 || sent[0].To != "ada@example.com" {
+		t.Fatalf("expected only the digest with something to tell sent; got %+v", sent)
+	}
+	for _, expected := range []string{"42 clicks", "https://sho.rt/short/docs, 40 clicks", "news.ycombinator.com, 30 clicks", "Jun 9 to Jun 15"} {
+		if !strings.Contains(sent[0].Subject+"\n"+sent[0].Text, expected) {
+			t.Errorf("expected the digest to mention %q; got %s", expected, sent[0].Text)
+		}
+	}
+	if !strings.Contains(sent[0].HTML, `<a href="https://sho.rt/short/docs"`) {
+		t.Errorf("expected the html digest to link the short urls; got %s", sent[0].HTML)
+	}
+	if marked := db.CallsTo("MarkDigestSent"); len(marked) != 1 || marked[0].Args[0] != 1 {
+		t.Errorf("expected only the digest sent marked; got %+v", marked)
+	}
+}
+
+// refusingMailer captures the emails, but those to refused
+type refusingMailer struct {
+	mailer.Capture
+	refused string
+}
+
+func (m *refusingMailer) Send(ctx context.Context, message mailer.Message) error {
+	if message.To == m.refused {
+		return errors.New("mailbox unavailable")
+	}
+	return m.Capture.Send(ctx, message)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/dnsverify"
+)
+
+// verifyDomains looks up the TXT record of every custom domain waiting for its verification, after releasing
+// those that waited for too long. Failed lookups are retried on the next run, only the database fails the run.
+func verifyDomains(db database.DomainRepository, resolver dnsverify.Resolver) error {
+	_, releaseErr := db.DeleteUnverifiedDomains(time.Now().Add(-dnsverify.PendingTTL))
+
+	domains, err := db.ListUnverifiedDomains()
+	if err != nil {
+		return errors.Join(releaseErr, err)
+	}
+
+	verified := 0
+	for _, domain := range domains {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := dnsverify.Check(ctx, resolver, domain.Hostname, domain.VerificationToken)
+		cancel()
+		if err != nil && !errors.Is(err, dnsverify.ErrNotVerified) {
+			log.Printf("[cronjobs:verifyDomains] Could not look up the record of {%s}: %v", domain.Hostname, err)
+			continue
+		}
+
+		found := err == nil
+		_, markErr := db.MarkDomainChecked(domain.Id, found)
+		if markErr != nil {
+			return errors.Join(releaseErr, markErr)
+		}
+		if found {
+			verified++
+		}
+	}
+
+	log.Printf("[cronjobs:verifyDomains] Verified {%d} of {%d} pending domains", verified, len(domains))
+	return releaseErr
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The jobs deleting or purging data, those CRON_DRY_RUN applies to
+var destructiveJobs = []string{"delete_expired_links", "purge_raw_ips", "purge_click_events", "manage_click_partitions", "delete_finished_jobs", "verify_domains", "delete_ended_sessions"}
+
+// Files a dry run names in its log, the others are only counted
+const dryRunSample = 10
+
+// Jobs whose runs only log what they would delete, see database.Service.DryRun
+var dryRuns = map[string]bool{}
+
+// dryRunsFromEnv reads CRON_DRY_RUN: true for every destructive job, or a comma separated list of them
+func dryRunsFromEnv() (map[string]bool, error) {
+	jobs := map[string]bool{}
+
+	value := os.Getenv("CRON_DRY_RUN")
+	switch value {
+	case "", "false":
+		return jobs, nil
+	case "true":
+		for _, name := range destructiveJobs {
+			jobs[name] = true
+		}
+		return jobs, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !isDestructive(name) {
+			return nil, fmt.Errorf("invalid CRON_DRY_RUN %q, %q isn't one of %s", value, name, strings.Join(destructiveJobs, ", "))
+		}
+		jobs[name] = true
+	}
+	return jobs, nil
+}
+
+func isDestructive(name string) bool {
+	for _, destructive := range destructiveJobs {
+		if name == destructive {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/metrics"
+	"url-shortner/internal/notify"
+
+	"github.com/robfig/cron/v3"
+)
+
+// job is a scheduled job, kept by name so that it can also be run on demand, see runNow
+type job struct {
+	interval time.Duration
+	fn       func(ctx context.Context, db database.Service) error
+}
+
+// The scheduled jobs by name
+var jobs = map[string]*job{}
+
+// schedule runs fn on spec under name, against each database in turn. Each run records its start in the jobs_status
+// table of the database, then its end when fn succeeds or its error when fn fails, so the api can flag a job that
+// stopped finishing. Its duration and failures are reported as metrics. JOBS_FILE may replace spec, or disable the job,
+// which then only runs on demand
+func schedule(c *cron.Cron, dbs []database.Service, name string, spec string, fn func(ctx context.Context, db database.Service) error) {
+	config := configOf(name)
+	if config.Schedule != "" {
+		spec = config.Schedule
+	}
+
+	interval, err := intervalOf(spec)
+	if err != nil {
+		log.Fatalf("[cronjobs:schedule] Invalid schedule {%s} for {%s}: %v", spec, name, err)
+	}
+	jobs[name] = &job{interval: interval, fn: fn}
+
+	if config.Enabled != nil && !*config.Enabled {
+		log.Printf("[cronjobs:schedule] {%s} is disabled, it only runs on demand", name)
+		return
+	}
+
+	_, err = c.AddJob(spec, skipInMaintenance(cron.FuncJob(func() {
+		runAll(dbs, name, jobs[name])
+	})))
+	if err != nil {
+		log.Fatalf("[cronjobs:schedule] Could not schedule {%s}: %v", name, err)
+	}
+}
+
+// runAll runs the job name against the databases, as many at once as its concurrency, and returns the errors of the
+// runs that failed or were skipped
+func runAll(dbs []database.Service, name string, j *job) error {
+	start := time.Now()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	slots := make(chan struct{}, configOf(name).Concurrency)
+	for _, db := range dbs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if err := run(db, name, j.interval, j.fn); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	metrics.Count("cron.runs", 1, "job:"+name)
+	metrics.Timing("cron.duration", time.Since(start), "job:"+name)
+
+	return errors.Join(errs...)
+}
+
+// run runs fn against db, recording it in the jobs_status table of db. Runs hold an advisory lock named after the job,
+// so a run is skipped while the previous one, of this instance or another, is still going. It returns the error of fn,
+// or why it wasn't run. Past the timeout of the job in JOBS_FILE, the context given to fn is cancelled and the run is
+// failed without waiting for fn, which keeps the lock until it returns
+func run(db database.Service, name string, interval time.Duration, fn func(ctx context.Context, db database.Service) error) error {
+	lock, err := db.TryAcquireLock(context.Background(), "cron:"+name)
+	if errors.Is(err, database.ErrLocked) {
+		log.Printf("[cronjobs:schedule] Skipping {%s}, still running elsewhere", name)
+		metrics.Count("cron.skipped", 1, "job:"+name)
+		return err
+	}
+	if err != nil {
+		log.Printf("[cronjobs:schedule] Could not lock {%s}: %v", name, err)
+		metrics.Count("cron.failures", 1, "job:"+name)
+		return err
+	}
+
+	if dryRuns[name] {
+		log.Printf("[cronjobs:schedule] Dry run of {%s}, nothing is deleted", name)
+		db = db.DryRun()
+	}
+
+	if err := db.StartJobRun(name, interval); err != nil {
+		log.Printf("[cronjobs:schedule] Could not record the start of {%s}: %v", name, err)
+	}
+
+	ctx := context.Background()
+	timeout := configOf(name).Timeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer lock.Release()
+		done <- fn(ctx, db)
+	}()
+
+	var jobErr error
+	select {
+	case jobErr = <-done:
+	case <-ctx.Done():
+		// fn may be stuck on a call ignoring ctx, the next runs of the job are skipped until it returns
+		jobErr = fmt.Errorf("%s timed out after %s: %w", name, timeout, ctx.Err())
+		metrics.Count("cron.timeouts", 1, "job:"+name)
+	}
+
+	if jobErr != nil {
+		log.Printf("[cronjobs:schedule] Run of {%s} failed: %v", name, jobErr)
+		metrics.Count("cron.failures", 1, "job:"+name)
+		notify.Send(notify.Event{
+			Type:   notify.JobFailed,
+			Title:  "Cron job " + name + " failed",
+			Fields: map[string]string{"job": name, "error": jobErr.Error()},
+		})
+		if err := db.FailJobRun(name, jobErr.Error()); err != nil {
+			log.Printf("[cronjobs:schedule] Could not record the failure of {%s}: %v", name, err)
+		}
+		return jobErr
+	}
+
+	if err := db.FinishJobRun(name); err != nil {
+		log.Printf("[cronjobs:schedule] Could not record the end of {%s}: %v", name, err)
+	}
+	return nil
+}
+
+// intervalOf returns the time between two runs of a schedule, between the next two for irregular ones
+func intervalOf(spec string) (time.Duration, error) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	next := sched.Next(time.Now())
+	return sched.Next(next).Sub(next), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestRunSkipsLockedJobs(t *testing.T) {
+	tests := []struct {
+		name        string
+		lockErr     error
+		expectedRun bool
+	}{
+		{"acquired", nil, true},
+		{"held elsewhere", fmt.Errorf("acquire lock: %w", database.ErrLocked), false},
+		{"database down", fmt.Errorf("acquire lock: connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			TryAcquireLockFunc: func(ctx context.Context, key string) (*database.Lock, error) {
+				return nil, tt.lockErr
+			},
+		}
+
+		ran := false
+		run(db, "delete_expired_links", time.Minute, func(ctx context.Context, db database.Service) error {
+			ran = true
+			return nil
+		})
+
+		if ran != tt.expectedRun {
+			t.Errorf("%s: expected run %t; got %t", tt.name, tt.expectedRun, ran)
+		}
+		if calls := db.CallsTo("TryAcquireLock"); len(calls) != 1 || calls[0].Args[0] != "cron:delete_expired_links" {
+			t.Errorf("%s: expected the lock of the job taken; got %+v", tt.name, calls)
+		}
+		if recorded := len(db.CallsTo("StartJobRun")) == 1; recorded != tt.expectedRun {
+			t.Errorf("%s: expected the start recorded %t; got %t", tt.name, tt.expectedRun, recorded)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	dryRuns = map[string]bool{"delete_expired_links": true}
+	defer func() { dryRuns = map[string]bool{} }()
+
+	for _, name := range []string{"delete_expired_links", "refresh_stats_views"} {
+		db := &mocks.Service{}
+		run(db, name, time.Minute, func(ctx context.Context, db database.Service) error {
+			return db.DeleteExpiredLinks()
+		})
+
+		if dryRun := len(db.CallsTo("DryRun")) == 1; dryRun != dryRuns[name] {
+			t.Errorf("%s: expected dry run %t; got %t", name, dryRuns[name], dryRun)
+		}
+	}
+}
+
+func TestDryRunsFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		valid    bool
+	}{
+		{"", nil, true},
+		{"false", nil, true},
+		{"true", destructiveJobs, true},
+		{"purge_click_events, delete_expired_links", []string{"purge_click_events", "delete_expired_links"}, true},
+		{"refresh_stats_views", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("CRON_DRY_RUN", tt.value)
+		jobs, err := dryRunsFromEnv()
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: expected valid %t; got %v", tt.value, tt.valid, err)
+			continue
+		}
+		if len(jobs) != len(tt.expected) {
+			t.Errorf("%q: expected %v; got %v", tt.value, tt.expected, jobs)
+		}
+		for _, name := range tt.expected {
+			if !jobs[name] {
+				t.Errorf("%q: expected %s dry run", tt.value, name)
+			}
+		}
+	}
+}
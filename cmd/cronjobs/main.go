@@ -1,26 +1,218 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"os"
+	"time"
+	"url-shortner/internal/blobstore"
 	"url-shortner/internal/database"
+	"url-shortner/internal/logging"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/notify"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/privacy"
+	"url-shortner/internal/safebrowsing"
+	"url-shortner/internal/systemd"
+	"url-shortner/internal/tenancy"
+	"url-shortner/internal/warehouse"
 
 	"github.com/robfig/cron/v3"
 )
 
 func main() {
-	log.SetPrefix("[CRONJOBS] ")
+	if err := logging.Setup("cronjobs"); err != nil {
+		log.Fatalf("[cronjobs:main] Invalid logging configuration: %v", err)
+	}
 	log.Println("[cronjobs:main] Running cronjob")
-	c := cron.New()
+	c := cron.New(cron.WithChain(recordDispatch))
 
-	db := database.New()
+	if err := plans.Load(); err != nil {
+		log.Fatalf("[cronjobs:main] Could not load the plans: %v", err)
+	}
+
+	if err := tenancy.Load(); err != nil {
+		log.Fatalf("[cronjobs:main] Could not load the tenants: %v", err)
+	}
+
+	partitions, err := partitioningFromEnv()
+	if err != nil {
+		log.Fatalf("[cronjobs:main] Invalid click partitioning: %v", err)
+	}
+
+	blobs, err := blobstore.New()
+	if err != nil {
+		log.Fatalf("[cronjobs:main] Invalid blobstore configuration: %v", err)
+	}
+
+	if err := mailer.CheckConfig(); err != nil {
+		log.Fatalf("[cronjobs:main] Invalid mailer configuration: %v", err)
+	}
+
+	if err := notify.CheckConfig(); err != nil {
+		log.Fatalf("[cronjobs:main] Invalid notifications configuration: %v", err)
+	}
+
+	if dryRuns, err = dryRunsFromEnv(); err != nil {
+		log.Fatalf("[cronjobs:main] Invalid dry run configuration: %v", err)
+	}
+
+	if jobConfigs, err = loadJobConfigs(); err != nil {
+		log.Fatalf("[cronjobs:main] Invalid jobs configuration: %v", err)
+	}
+
+	dataWarehouse, err := warehouse.New()
+	if err != nil {
+		log.Fatalf("[cronjobs:main] Invalid warehouse configuration: %v", err)
+	}
+
+	// Every job runs against each of them in turn
+	dbs := databases()
 
 	// Running every minute
-	c.AddFunc("*/1 * * * *", func() {
-		db.DeleteExpiredLinks()
+	schedule(c, dbs, "delete_expired_links", "*/1 * * * *", func(ctx context.Context, db database.Service) error {
+		return db.DeleteExpiredLinks()
+	})
+
+	// Running every five minutes
+	schedule(c, dbs, "disable_banned_links", "*/5 * * * *", func(ctx context.Context, db database.Service) error {
+		_, err := db.DisableBannedLinks()
+		return err
 	})
 
+	// Running every ten minutes
+	schedule(c, dbs, "process_deletion_requests", "*/10 * * * *", func(ctx context.Context, db database.Service) error {
+		_, err := db.ProcessDeletionRequests()
+		return err
+	})
+
+	// Running every five minutes, so a custom domain starts serving links soon after its TXT record is created
+	schedule(c, dbs, "verify_domains", "*/5 * * * *", func(ctx context.Context, db database.Service) error {
+		return verifyDomains(db, net.DefaultResolver)
+	})
+
+	// Running every hour, even with retention disabled to clear raw ips stored under a previous setting
+	schedule(c, dbs, "purge_raw_ips", "30 * * * *", func(ctx context.Context, db database.Service) error {
+		_, err := db.PurgeRawIps(privacy.RawIpRetention)
+		return err
+	})
+
+	// Running every hour, the daily rollups of today and the days before catch up with the click events
+	schedule(c, dbs, "rollup_clicks", "20 * * * *", func(ctx context.Context, db database.Service) error {
+		return rollupClicks(db, time.Now())
+	})
+
+	// Running every day, click events and their rollups are kept as long as the plan or organization of their link
+	// allows
+	schedule(c, dbs, "purge_click_events", "15 3 * * *", func(ctx context.Context, db database.Service) error {
+		return purgeAnalytics(db, time.Now())
+	})
+
+	// Running every five minutes, the stats endpoints count live once the views are older than STATS_VIEW_MAX_AGE
+	schedule(c, dbs, "refresh_stats_views", "*/5 * * * *", func(ctx context.Context, db database.Service) error {
+		return db.RefreshStatsViews()
+	})
+
+	// Running every hour, the planner statistics of the hot tables are kept fresh after mass expirations
+	schedule(c, dbs, "maintain_tables", "50 * * * *", func(ctx context.Context, db database.Service) error {
+		return maintainTables(db, vacuumTables)
+	})
+
+	// Running every day, partitions are created a few periods ahead so missed runs don't leave clicks without one
+	schedule(c, dbs, "manage_click_partitions", "30 2 * * *", func(ctx context.Context, db database.Service) error {
+		return manageClickPartitions(db, partitions, time.Now())
+	})
+
+	// Running every day, finished jobs and the archives of exports are kept a week
+	schedule(c, dbs, "delete_finished_jobs", "45 3 * * *", func(ctx context.Context, db database.Service) error {
+		return deleteFinishedJobs(ctx, db, blobs, time.Now().Add(-jobRetention), dryRuns["delete_finished_jobs"])
+	})
+
+	// Running every hour, sessions past their absolute timeout or signed out are of no use anymore
+	schedule(c, dbs, "delete_ended_sessions", "40 * * * *", func(ctx context.Context, db database.Service) error {
+		_, err := db.DeleteEndedSessions()
+		return err
+	})
+
+	if dataWarehouse != nil {
+		// Running every fifteen minutes unless WAREHOUSE_EXPORT_SCHEDULE, or JOBS_FILE, says otherwise
+		spec := os.Getenv("WAREHOUSE_EXPORT_SCHEDULE")
+		if spec == "" {
+			spec = "*/15 * * * *"
+		}
+		schedule(c, dbs, "export_to_warehouse", spec, func(ctx context.Context, db database.Service) error {
+			return exportToWarehouse(ctx, db, dataWarehouse, time.Now())
+		})
+	}
+
+	if client := safebrowsing.New(); client != nil {
+		// Running every hour
+		schedule(c, dbs, "scan_unsafe_links", "0 * * * *", func(ctx context.Context, db database.Service) error {
+			return scanUnsafeLinks(ctx, db, client)
+		})
+	}
+
+	if mailer.Configured() {
+		mail := mailer.New()
+		// Running every Monday morning, on the week before
+		schedule(c, dbs, "send_digests", "0 8 * * 1", func(ctx context.Context, db database.Service) error {
+			return sendDigests(ctx, db, mail, time.Now())
+		})
+
+		// Running every five minutes, the outbox filled by moderation and invitations
+		schedule(c, dbs, "send_notifications", "*/5 * * * *", func(ctx context.Context, db database.Service) error {
+			return sendNotifications(ctx, db, mail, time.Now())
+		})
+	}
+
+	if len(os.Args) > 1 {
+		if os.Args[1] != "run" {
+			log.Fatalf("[cronjobs:main] Unknown command {%s}, expected run", os.Args[1])
+		}
+		err := runCommand(os.Args[2:], dbs)
+		// The failures of the run are notified before exiting
+		notify.Wait()
+		if err != nil {
+			log.Fatalf("[cronjobs:main] run failed: %v", err)
+		}
+		return
+	}
+
+	// Runs requested through the admin api
+	scheduleRunRequests(c, dbs)
+
+	// The switch is stored in the default schema, shared with the api
+	go maintenance.Watch(context.Background(), func() (bool, string, error) {
+		switched, err := database.New().GetMaintenance()
+		if err != nil {
+			return false, "", err
+		}
+		return switched.Enabled, switched.Message, nil
+	})
+
+	scheduleTick(c)
 	c.Start()
 
+	if err := systemd.Notify(systemd.Ready); err != nil {
+		log.Printf("[cronjobs:main] Could not notify systemd: %v", err)
+	}
+	// The jobs run on goroutines of their own, a wedged job shows in its heartbeat rather than here. The watchdog is
+	// only pinged while the scheduler keeps starting jobs
+	systemd.StartWatchdog(schedulerProgressing)
+
 	// This keeps the program running
 	select {}
 }
+
+// skipInMaintenance leaves the database alone during a maintenance, switched on the api or with MAINTENANCE_MODE
+func skipInMaintenance(job cron.Job) cron.Job {
+	return cron.FuncJob(func() {
+		if maintenance.Enabled() {
+			log.Println("[cronjobs:skipInMaintenance] Skipping a job during the maintenance")
+			return
+		}
+		job.Run()
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+)
+
+// Notifications read at a time, unless JOBS_FILE sets the batch_size of send_notifications
+const notificationBatchSize = 100
+
+// sendNotifications emails the notifications waiting in the outbox, such as the moderation of a link or an invitation
+// to an organization, to their user. A notification that fails is retried on the next run
+func sendNotifications(ctx context.Context, db database.Service, m mailer.Mailer, now time.Time) error {
+	size := batchSize("send_notifications", notificationBatchSize)
+
+	afterId, sent, failed := 0, 0, 0
+	for {
+		notifications, err := db.ListUnsentNotifications(afterId, size)
+		if err != nil {
+			return fmt.Errorf("send notifications: %w", err)
+		}
+
+		for _, notification := range notifications {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("send notifications: stopped after {%d}: %w", sent, err)
+			}
+			afterId = notification.Id
+
+			message, err := mailer.Compose(notification.Email, "notification", notification)
+			if err != nil {
+				return fmt.Errorf("send notifications: %w", err)
+			}
+			if err := m.Send(ctx, message); err != nil {
+				log.Printf("[cronjobs:sendNotifications] Could not send notification {%d} to user {%d}: %v", notification.Id, notification.UserId, err)
+				failed++
+				continue
+			}
+			if err := db.MarkNotificationSent(notification.Id, now); err != nil {
+				return fmt.Errorf("send notifications: %w", err)
+			}
+			sent++
+		}
+
+		if len(notifications) < size {
+			break
+		}
+	}
+
+	log.Printf("[cronjobs:sendNotifications] Sent {%d} notifications, {%d} failed", sent, failed)
+	if failed > 0 {
+		return fmt.Errorf("send notifications: %d of %d could not be sent", failed, sent+failed)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestSendNotifications(t *testing.T) {
+	now := time.Date(2025, 6, 16, 8, 0, 0, 0, time.UTC)
+	db := &mocks.Service{
+		ListUnsentNotificationsFunc: func(afterId int, limit int) ([]*database.NotificationModel, error) {
+			if afterId > 0 {
+				return nil, nil
+			}
+			return []*database.NotificationModel{
+				{Id: 1, UserId: 1, Email: "ada@example.com", Subject: "Your short link docs is now taken_down", Body: "The short link docs moved from active to taken_down.\nReason: phishing\n"},
+				{Id: 2, UserId: 2, Email: "bob@example.com", Subject: "Your short link sale is now flagged", Body: "The short link sale moved from active to flagged.\n"},
+			}, nil
+		},
+	}
+
+	mail := &refusingMailer{refused: "bob@example.com"}
+	if err := sendNotifications(context.Background(), db, mail, now); err == nil {
+		t.Errorf("expected the failed notification reported")
+	}
+
+	sent := mail.Messages()
+	if len(sent) != 1 || sent[0].To != "ada@example.com" || sent[0].Subject != "Your short link docs is now taken_down" {
+		t.Fatalf("expected the notification emailed to its user; got %+v", sent)
+	}
+	if !strings.Contains(sent[0].Text, "Reason: phishing") || !strings.Contains(sent[0].HTML, "Reason: phishing") {
+		t.Errorf("expected the body of the notification in the email; got %s", sent[0].Text)
+	}
+	if marked := db.CallsTo("MarkNotificationSent"); len(marked) != 1 || marked[0].Args[0] != 1 {
+		t.Errorf("expected only the notification sent marked, the other retried; got %+v", marked)
+	}
+}
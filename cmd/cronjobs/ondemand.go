@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"url-shortner/internal/database"
+
+	"github.com/robfig/cron/v3"
+)
+
+// runCommand runs a job at once instead of scheduling them all, e.g. ./cronjobs run purge_click_events -dry-run
+func runCommand(args []string, dbs []database.Service) error {
+	if len(args) == 0 {
+		return errors.New("usage: run <job> [-dry-run]")
+	}
+	name := args[0]
+
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only log what the job would delete")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *dryRun {
+		if !isDestructive(name) {
+			return fmt.Errorf("%s deletes nothing, only %s can be dry run", name, strings.Join(destructiveJobs, ", "))
+		}
+		dryRuns[name] = true
+	}
+
+	return runNow(dbs, name)
+}
+
+// runNow runs the scheduled job name against each database, with the locking and the heartbeats of a scheduled run.
+// It isn't held back by a maintenance, the operator asked for it
+func runNow(dbs []database.Service, name string) error {
+	j, ok := jobs[name]
+	if !ok {
+		names := make([]string, 0, len(jobs))
+		for name := range jobs {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("unknown job %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	log.Printf("[cronjobs:runNow] Running {%s} on demand", name)
+	return runAll(dbs, name, j)
+}
+
+// scheduleRunRequests runs the jobs requested through the admin api every tickInterval, against the database they
+// were requested in. Requests made during a maintenance wait for its end
+func scheduleRunRequests(c *cron.Cron, dbs []database.Service) {
+	c.Schedule(cron.Every(tickInterval), skipInMaintenance(cron.FuncJob(func() {
+		for _, db := range dbs {
+			runRequested(db)
+		}
+	})))
+}
+
+func runRequested(db database.Service) {
+	names, err := db.ClaimJobRunRequests()
+	if err != nil {
+		log.Printf("[cronjobs:runRequested] Could not read the requested runs: %v", err)
+		return
+	}
+
+	for _, name := range names {
+		j, ok := jobs[name]
+		if !ok {
+			log.Printf("[cronjobs:runRequested] Ignoring the requested run of {%s}, not scheduled by this instance", name)
+			continue
+		}
+		log.Printf("[cronjobs:runRequested] Running {%s} as requested", name)
+		run(db, name, j.interval, j.fn)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestRunCommand(t *testing.T) {
+	ran := 0
+	jobs = map[string]*job{
+		"delete_expired_links": {interval: time.Minute, fn: func(ctx context.Context, db database.Service) error {
+			ran++
+			return db.DeleteExpiredLinks()
+		}},
+	}
+	defer func() { jobs, dryRuns = map[string]*job{}, map[string]bool{} }()
+
+	db := &mocks.Service{}
+	if err := runCommand([]string{"delete_expired_links", "-dry-run"}, []database.Service{db}); err != nil {
+		t.Fatal(err)
+	}
+	if ran != 1 || len(db.CallsTo("DryRun")) != 1 || len(db.CallsTo("FinishJobRun")) != 1 {
+		t.Errorf("expected a dry run recorded as a scheduled run; got %d runs and %+v", ran, db.Calls())
+	}
+
+	if err := runCommand([]string{"purge_everything"}, []database.Service{db}); err == nil || !strings.Contains(err.Error(), "delete_expired_links") {
+		t.Errorf("expected an unknown job refused with the known ones; got %v", err)
+	}
+	if err := runCommand([]string{"refresh_stats_views", "-dry-run"}, []database.Service{db}); err == nil {
+		t.Errorf("expected a dry run of a job deleting nothing refused")
+	}
+}
+
+func TestRunRequested(t *testing.T) {
+	ran := []string{}
+	jobs = map[string]*job{
+		"refresh_stats_views": {interval: 5 * time.Minute, fn: func(ctx context.Context, db database.Service) error {
+			ran = append(ran, "refresh_stats_views")
+			return nil
+		}},
+	}
+	defer func() { jobs = map[string]*job{} }()
+
+	db := &mocks.Service{
+		ClaimJobRunRequestsFunc: func() ([]string, error) {
+			return []string{"refresh_stats_views", "scan_unsafe_links"}, nil
+		},
+	}
+	runRequested(db)
+
+	if len(ran) != 1 {
+		t.Errorf("expected the scheduled job run and the other ignored; got %v", ran)
+	}
+	if calls := db.CallsTo("StartJobRun"); len(calls) != 1 || calls[0].Args[0] != "refresh_stats_views" {
+		t.Errorf("expected the run recorded; got %+v", calls)
+	}
+}
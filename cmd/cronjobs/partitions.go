@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/plans"
+)
+
+const defaultPartitionsAhead = 3
+
+// partitioning is how the click events are split: the length of a partition, and how many are created ahead
+type partitioning struct {
+	weekly bool
+	ahead  int
+}
+
+// partitioningFromEnv reads CLICK_PARTITION_PERIOD, month or week, and CLICK_PARTITIONS_AHEAD
+func partitioningFromEnv() (partitioning, error) {
+	p := partitioning{ahead: defaultPartitionsAhead}
+
+	switch period := os.Getenv("CLICK_PARTITION_PERIOD"); period {
+	case "", "month":
+	case "week":
+		p.weekly = true
+	default:
+		return p, fmt.Errorf("invalid CLICK_PARTITION_PERIOD %q, expected month or week", period)
+	}
+
+	if value := os.Getenv("CLICK_PARTITIONS_AHEAD"); value != "" {
+		ahead, err := strconv.Atoi(value)
+		if err != nil || ahead < 1 {
+			return p, fmt.Errorf("invalid CLICK_PARTITIONS_AHEAD %q", value)
+		}
+		p.ahead = ahead
+	}
+
+	return p, nil
+}
+
+// start returns the start of the period holding t, in UTC, weeks starting on Monday
+func (p partitioning) start(t time.Time) time.Time {
+	t = t.UTC()
+	if !p.weekly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// next returns the start of the period after the one holding t
+func (p partitioning) next(t time.Time) time.Time {
+	if p.weekly {
+		return p.start(t).AddDate(0, 0, 7)
+	}
+	return p.start(t).AddDate(0, 1, 0)
+}
+
+// longestClickRetention returns how long the plan or organization keeping click events the longest keeps them. It
+// reports false when one keeps them forever
+func longestClickRetention(db database.OrganizationRepository) (time.Duration, bool, error) {
+	longest := 0
+	for _, plan := range plans.All() {
+		if plan.ClickRetention() == 0 {
+			return 0, false, nil
+		}
+		longest = max(longest, plan.ClickRetention())
+	}
+
+	overrides, err := db.ListOrganizationRetentions()
+	if err != nil {
+		return 0, false, err
+	}
+	for _, override := range overrides {
+		if override.ClickRetentionDays == nil {
+			continue
+		}
+		if *override.ClickRetentionDays == 0 {
+			return 0, false, nil
+		}
+		longest = max(longest, *override.ClickRetentionDays)
+	}
+
+	return time.Duration(longest) * 24 * time.Hour, true, nil
+}
+
+// manageClickPartitions creates the partitions of the click events up to p.ahead periods past the current one,
+// following on from the last partition, and drops those whose clicks are all past the retention of every plan and
+// organization. Clicks past their own retention are deleted by purge_click_events
+func manageClickPartitions(db database.Service, p partitioning, now time.Time) error {
+	partitions, err := db.ListClickPartitions()
+	if err != nil {
+		return err
+	}
+
+	from := p.start(now)
+	if len(partitions) > 0 && partitions[len(partitions)-1].To.After(from) {
+		from = partitions[len(partitions)-1].To
+	}
+
+	// The end of the last period to cover, the current one being the first
+	horizon := p.start(now)
+	for range p.ahead + 1 {
+		horizon = p.next(horizon)
+	}
+
+	var errs []error
+	for from.Before(horizon) {
+		to := p.next(from)
+		if _, err := db.CreateClickPartition(from, to); err != nil {
+			// The next ones would leave a gap
+			errs = append(errs, err)
+			break
+		}
+		from = to
+	}
+
+	retention, bounded, err := longestClickRetention(db)
+	if err != nil || !bounded {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, partition := range partitions {
+		if !partition.To.After(now.Add(-retention)) {
+			if err := db.DropClickPartition(partition.Name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
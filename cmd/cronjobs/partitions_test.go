@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/plans"
+)
+
+func TestPartitioningPeriods(t *testing.T) {
+	now := time.Date(2025, 6, 12, 15, 30, 0, 0, time.UTC)
+
+	monthly := partitioning{}
+	if start, next := monthly.start(now), monthly.next(now); !start.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) || !next.Equal(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected June; got %v to %v", start, next)
+	}
+
+	weekly := partitioning{weekly: true}
+	if start, next := weekly.start(now), weekly.next(now); !start.Equal(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)) || !next.Equal(time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the week starting on Monday the 9th; got %v to %v", start, next)
+	}
+	if start := weekly.start(time.Date(2025, 6, 15, 23, 0, 0, 0, time.UTC)); !start.Equal(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected Sunday in the week of Monday the 9th; got %v", start)
+	}
+}
+
+func TestManageClickPartitions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.json")
+	content := `{"free": {"analytics_retention_days": 30}, "pro": {"analytics_retention_days": 365}, "enterprise": {"analytics_retention_days": 90}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PLANS_FILE", path)
+	if err := plans.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 12, 15, 30, 0, 0, time.UTC)
+	month := func(m time.Month) time.Time { return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC) }
+
+	db := &mocks.Service{
+		ListClickPartitionsFunc: func() ([]*database.ClickPartitionModel, error) {
+			return []*database.ClickPartitionModel{
+				{Name: "click_events_history", To: month(7)},
+			}, nil
+		},
+	}
+
+	if err := manageClickPartitions(db, partitioning{ahead: 2}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	created := db.CallsTo("CreateClickPartition")
+	if len(created) != 2 {
+		t.Fatalf("expected July and August created after the last partition; got %v", created)
+	}
+	if from, to := created[0].Args[0].(time.Time), created[1].Args[1].(time.Time); !from.Equal(month(7)) || !to.Equal(month(9)) {
+		t.Errorf("expected partitions from July until September; got %v until %v", from, to)
+	}
+
+	if dropped := db.CallsTo("DropClickPartition"); len(dropped) != 0 {
+		t.Errorf("expected the partitions within the retention kept; got %v", dropped)
+	}
+
+	// The history partition is dropped once the plan keeping clicks the longest is past it
+	if err := manageClickPartitions(db, partitioning{ahead: 2}, month(7).AddDate(0, 0, 365)); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := db.CallsTo("DropClickPartition"); len(dropped) != 1 || dropped[0].Args[0] != "click_events_history" {
+		t.Errorf("expected the history partition dropped; got %v", dropped)
+	}
+}
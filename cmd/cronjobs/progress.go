@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// How often the scheduler starts the tick job, so it dispatches something even between the hourly jobs
+	tickInterval = 10 * time.Second
+
+	// Time without any job started past which the scheduler is taken for stuck and the watchdog isn't pinged
+	stalledAfter = 3 * tickInterval
+)
+
+// lastDispatch is when the scheduler last started a job, in unix nanoseconds. Jobs skipped during a maintenance
+// count, they were dispatched all the same
+var lastDispatch atomic.Int64
+
+// recordDispatch notes that the scheduler started job
+func recordDispatch(job cron.Job) cron.Job {
+	return cron.FuncJob(func() {
+		lastDispatch.Store(time.Now().UnixNano())
+		job.Run()
+	})
+}
+
+// scheduleTick adds the tick job, doing nothing but being dispatched every tickInterval
+func scheduleTick(c *cron.Cron) {
+	lastDispatch.Store(time.Now().UnixNano())
+	c.Schedule(cron.Every(tickInterval), cron.FuncJob(func() {}))
+}
+
+// schedulerProgressing reports whether the scheduler started a job lately, for the watchdog
+func schedulerProgressing() bool {
+	return time.Since(time.Unix(0, lastDispatch.Load())) < stalledAfter
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"url-shortner/internal/blobstore"
+	"url-shortner/internal/database"
+	"url-shortner/internal/plans"
+)
+
+// How long finished jobs are kept, long enough to download the archive of an export
+const jobRetention = 7 * 24 * time.Hour
+
+// How far back rollup_clicks counts click events, so the days a missed run left out are caught up
+const rollupLookback = 2
+
+// rollupClicks counts the click events of the last days, from midnight rollupLookback days ago, into the daily
+// rollups
+func rollupClicks(db database.ClickRepository, now time.Time) error {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	_, err := db.RollupClicks(day.AddDate(0, 0, -rollupLookback))
+	return err
+}
+
+// purgeAnalytics deletes the click events and daily rollups past their retention: that of each plan, then that of
+// the organizations overriding it. A scope that fails doesn't keep the others from being purged
+func purgeAnalytics(db database.Service, now time.Time) error {
+	overrides, err := db.ListOrganizationRetentions()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	purge := func(scope database.AnalyticsScope, clickDays int, rollupDays int) {
+		if clickDays > 0 {
+			if _, err := db.PurgeClickEvents(scope, now.AddDate(0, 0, -clickDays)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if rollupDays > 0 {
+			if _, err := db.PurgeClickRollups(scope, now.AddDate(0, 0, -rollupDays)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for _, plan := range plans.All() {
+		purge(database.AnalyticsScope{Plan: plan.Name}, plan.ClickRetention(), plan.RollupRetention())
+	}
+
+	// The retentions an organization leaves to its plan were purged along with the plan
+	for _, override := range overrides {
+		purge(database.AnalyticsScope{OrganizationId: override.OrganizationId}, days(override.ClickRetentionDays), days(override.RollupRetentionDays))
+	}
+
+	return errors.Join(errs...)
+}
+
+// days returns the retention of an override, 0 when it isn't set
+func days(retention *int) int {
+	if retention == nil {
+		return 0
+	}
+	return *retention
+}
+
+// deleteFinishedJobs deletes the jobs finished before before, after the files they kept in the blobstore. Jobs
+// whose files could not be deleted are kept for the next run. A dry run only lists the files, db being a dry run too
+func deleteFinishedJobs(ctx context.Context, db database.Service, blobs blobstore.Store, before time.Time, dryRun bool) error {
+	if blobs != nil {
+		keys, err := db.ListFinishedJobFileKeys(before)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			log.Printf("[cronjobs:deleteFinishedJobs] Dry run, would delete {%d} files, e.g. {%s}", len(keys), strings.Join(keys[:min(len(keys), dryRunSample)], ", "))
+			keys = nil
+		}
+
+		var errs []error
+		for _, key := range keys {
+			if err := blobs.Delete(ctx, key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
+
+	_, err := db.DeleteFinishedJobs(before)
+	return err
+}
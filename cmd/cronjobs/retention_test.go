@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/blobstore"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/plans"
+)
+
+func TestPurgeAnalytics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.json")
+	content := `{"free": {"analytics_retention_days": 30}, "pro": {"analytics_retention_days": 365, "rollup_retention_days": 730}, "enterprise": {}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PLANS_FILE", path)
+	t.Setenv("ANALYTICS_RETENTION_DAYS", "90")
+	t.Setenv("ROLLUP_RETENTION_DAYS", "")
+	if err := plans.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 12, 3, 15, 0, 0, time.UTC)
+	forever, week := 0, 7
+
+	db := &mocks.Service{
+		ListOrganizationRetentionsFunc: func() ([]*database.RetentionModel, error) {
+			return []*database.RetentionModel{
+				{OrganizationId: 7, ClickRetentionDays: &week},
+				{OrganizationId: 8, ClickRetentionDays: &forever, RollupRetentionDays: &week},
+			}, nil
+		},
+	}
+
+	if err := purgeAnalytics(db, now); err != nil {
+		t.Fatal(err)
+	}
+
+	purged := map[database.AnalyticsScope]time.Time{}
+	for _, call := range db.CallsTo("PurgeClickEvents") {
+		purged[call.Args[0].(database.AnalyticsScope)] = call.Args[1].(time.Time)
+	}
+	expected := map[database.AnalyticsScope]time.Time{
+		{Plan: plans.Free}:       now.AddDate(0, 0, -30),
+		{Plan: plans.Pro}:        now.AddDate(0, 0, -365),
+		{Plan: plans.Enterprise}: now.AddDate(0, 0, -90),
+		{OrganizationId: 7}:      now.AddDate(0, 0, -7),
+	}
+	if len(purged) != len(expected) {
+		t.Fatalf("expected click events purged for %v; got %v", expected, purged)
+	}
+	for scope, before := range expected {
+		if !purged[scope].Equal(before) {
+			t.Errorf("expected the click events of %s purged before %v; got %v", scope, before, purged[scope])
+		}
+	}
+
+	// Without ROLLUP_RETENTION_DAYS only the plan and organization setting one lose their rollups
+	rollups := db.CallsTo("PurgeClickRollups")
+	if len(rollups) != 2 {
+		t.Fatalf("expected the rollups of pro and organization 8 purged; got %v", rollups)
+	}
+	if scope, before := rollups[0].Args[0].(database.AnalyticsScope), rollups[0].Args[1].(time.Time); scope.Plan != plans.Pro || !before.Equal(now.AddDate(0, 0, -730)) {
+		t.Errorf("expected the rollups of pro purged after two years; got %s before %v", scope, before)
+	}
+	if scope := rollups[1].Args[0].(database.AnalyticsScope); scope.OrganizationId != 8 {
+		t.Errorf("expected the rollups of organization 8 purged; got %s", scope)
+	}
+}
+
+func TestRollupClicksLooksBack(t *testing.T) {
+	db := &mocks.Service{}
+	if err := rollupClicks(db, time.Date(2025, 6, 12, 15, 20, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := db.CallsTo("RollupClicks")
+	if len(calls) != 1 || !calls[0].Args[0].(time.Time).Equal(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the clicks rolled up since midnight two days ago; got %v", calls)
+	}
+}
+
+func TestDeleteFinishedJobs(t *testing.T) {
+	t.Setenv("BLOBSTORE", "disk")
+	t.Setenv("BLOBSTORE_DIR", t.TempDir())
+	blobs, err := blobstore.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := blobs.Put(ctx, "jobs/9/export.zip", strings.NewReader("archive"), 7, "application/zip"); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-jobRetention)
+	db := &mocks.Service{
+		ListFinishedJobFileKeysFunc: func(time.Time) ([]string, error) {
+			return []string{"jobs/9/export.zip"}, nil
+		},
+	}
+	if err := deleteFinishedJobs(ctx, db, blobs, before, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobs.Get(ctx, "jobs/9/export.zip"); err != nil {
+		t.Errorf("expected the archive kept by a dry run; got %v", err)
+	}
+
+	if err := deleteFinishedJobs(ctx, db, blobs, before, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := blobs.Get(ctx, "jobs/9/export.zip"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("expected the archive deleted; got %v", err)
+	}
+	if calls := db.CallsTo("DeleteFinishedJobs"); len(calls) != 2 || !calls[1].Args[0].(time.Time).Equal(before) {
+		t.Errorf("expected the jobs deleted after their files; got %v", calls)
+	}
+}
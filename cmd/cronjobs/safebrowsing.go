@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/safebrowsing"
+)
+
+// scanUnsafeLinks re-checks every active link against Safe Browsing and takes the flagged ones down, recording it
+// in their moderation history like an admin would. The run fails when the links can't be listed or looked up.
+func scanUnsafeLinks(ctx context.Context, db database.Service, client *safebrowsing.Client) error {
+	log.Println("[cronjobs:scanUnsafeLinks] Scanning active links")
+
+	size := batchSize("scan_unsafe_links", safebrowsing.MaxBatchSize)
+	lastId, disabled := 0, 0
+	for {
+		links, err := db.ListActiveShortUrls(lastId, size)
+		if err != nil {
+			return fmt.Errorf("scan unsafe links: %w", err)
+		}
+		if len(links) == 0 {
+			break
+		}
+		lastId = links[len(links)-1].Id
+
+		urls := make([]string, 0, len(links))
+		for _, link := range links {
+			urls = append(urls, link.Link)
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		flagged, err := client.Check(lookupCtx, urls)
+		cancel()
+		if err != nil {
+			log.Printf("[cronjobs:scanUnsafeLinks] Lookup failed after disabling {%d} unsafe links, stopping scan: %v", disabled, err)
+			return fmt.Errorf("scan unsafe links: %w", err)
+		}
+
+		for _, link := range links {
+			if threatType, ok := flagged[link.Link]; ok {
+				_, err := db.TransitionModeration(&database.TakedownEventModel{
+					ShortCode:  link.ShortCode,
+					FromState:  link.ModerationState,
+					ToState:    database.ModerationTakenDown,
+					ReasonCode: takedownReasonOf(threatType),
+					Note:       "safe browsing: " + threatType,
+					Actor:      "safebrowsing",
+				})
+				if err == nil {
+					disabled++
+				}
+			}
+		}
+	}
+
+	log.Printf("[cronjobs:scanUnsafeLinks] Disabled {%d} unsafe links", disabled)
+	return nil
+}
+
+// takedownReasonOf maps a Safe Browsing threat type to the reason code of a takedown
+func takedownReasonOf(threatType string) string {
+	if threatType == "SOCIAL_ENGINEERING" {
+		return "phishing"
+	}
+	return "malware"
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/tenancy"
+)
+
+// databases returns the schemas the jobs run against: the one of each tenant with TENANT_MODE=schema, the
+// configured one otherwise
+func databases() []database.Service {
+	if !tenancy.Enabled {
+		return []database.Service{database.New()}
+	}
+
+	dbs := []database.Service{}
+	for _, tenant := range tenancy.All() {
+		db, err := database.ForSchema(tenant.Schema())
+		if err != nil {
+			log.Fatalf("[cronjobs:databases] Could not connect to the schema of tenant {%s}: %v", tenant.Name, err)
+		}
+		dbs = append(dbs, db)
+	}
+
+	return dbs
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/metrics"
+)
+
+// The tables written the most, whose planner statistics go stale first, e.g. after a wave of expirations
+var hotTables = []string{"short_url", "link_click_counters", "click_events", "click_daily_rollups"}
+
+// Whether maintain_tables vacuums the hot tables too, on top of what autovacuum does
+var vacuumTables = os.Getenv("DB_MAINTENANCE_VACUUM") == "true"
+
+// maintainTables analyzes the hot tables, vacuuming them first when vacuum is set, then reports the size and dead
+// rows of every table
+func maintainTables(db database.UpkeepRepository, vacuum bool) error {
+	var errs []error
+	if vacuum {
+		if err := db.VacuumTables(hotTables); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := db.AnalyzeTables(hotTables); err != nil {
+		errs = append(errs, err)
+	}
+
+	tables, err := db.ListTableStats()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, table := range tables {
+		tags := []string{"schema:" + table.Schema, "table:" + table.Table}
+		metrics.Gauge("db.table.bytes", float64(table.TableBytes), tags...)
+		metrics.Gauge("db.table.index_bytes", float64(table.IndexBytes), tags...)
+		metrics.Gauge("db.table.dead_rows", float64(table.DeadRows), tags...)
+		metrics.Gauge("db.table.dead_ratio", table.DeadRatio(), tags...)
+	}
+
+	return errors.Join(errs...)
+}
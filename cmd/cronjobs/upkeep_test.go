@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestMaintainTables(t *testing.T) {
+	for _, vacuum := range []bool{false, true} {
+		db := &mocks.Service{
+			AnalyzeTablesFunc: func(tables []string) error {
+				return errors.New("canceling statement due to lock timeout")
+			},
+			ListTableStatsFunc: func() ([]*database.TableStatsModel, error) {
+				return []*database.TableStatsModel{{Schema: "public", Table: "short_url", LiveRows: 3, DeadRows: 1}}, nil
+			},
+		}
+
+		if err := maintainTables(db, vacuum); err == nil {
+			t.Errorf("vacuum %t: expected the failed analyze reported", vacuum)
+		}
+
+		analyzed := db.CallsTo("AnalyzeTables")
+		if len(analyzed) != 1 || !slices.Equal(analyzed[0].Args[0].([]string), hotTables) {
+			t.Errorf("vacuum %t: expected the hot tables analyzed; got %+v", vacuum, analyzed)
+		}
+		if vacuumed := len(db.CallsTo("VacuumTables")) == 1; vacuumed != vacuum {
+			t.Errorf("vacuum %t: got vacuumed %t", vacuum, vacuumed)
+		}
+		if len(db.CallsTo("ListTableStats")) != 1 {
+			t.Errorf("vacuum %t: expected the table stats reported despite the failure", vacuum)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/warehouse"
+)
+
+const (
+	// Rows read and shipped at a time, unless JOBS_FILE sets the batch_size of export_to_warehouse
+	exportBatchSize = 5000
+
+	// Batches shipped per stream and run, the rest waits for the next run
+	maxExportBatches = 20
+
+	// Rows newer than this are left for the next run, so the transactions still writing older ones commit first
+	exportLag = time.Minute
+)
+
+// Streams shipped to the warehouse, naming their cursors
+const (
+	clicksStream = "click_events"
+	linksStream  = "links"
+)
+
+// exportToWarehouse ships the click events and link changes made since the last run. Each stream goes on from its
+// cursor, saved after every batch, so a failed run is picked up where it stopped
+func exportToWarehouse(ctx context.Context, db database.Service, w *warehouse.Warehouse, now time.Time) error {
+	return errors.Join(exportClicks(ctx, db, w, now), exportLinks(ctx, db, w, now))
+}
+
+func exportClicks(ctx context.Context, db database.Service, w *warehouse.Warehouse, now time.Time) error {
+	cursor, err := db.GetExportCursor(clicksStream)
+	if err != nil {
+		return err
+	}
+	size := batchSize("export_to_warehouse", exportBatchSize)
+
+	for range maxExportBatches {
+		clicks, err := db.ListClicksForExport(cursor.LastId, now.Add(-exportLag), size)
+		if err != nil || len(clicks) == 0 {
+			return err
+		}
+
+		rows := make([]warehouse.Row, 0, len(clicks))
+		for _, click := range clicks {
+			rows = append(rows, clickRow(click))
+		}
+		if err := w.Insert(ctx, w.ClicksTable, rows); err != nil {
+			return err
+		}
+
+		cursor.LastId = clicks[len(clicks)-1].Id
+		if err := db.SaveExportCursor(cursor); err != nil {
+			return err
+		}
+		if len(clicks) < size {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func exportLinks(ctx context.Context, db database.Service, w *warehouse.Warehouse, now time.Time) error {
+	cursor, err := db.GetExportCursor(linksStream)
+	if err != nil {
+		return err
+	}
+	size := batchSize("export_to_warehouse", exportBatchSize)
+
+	for range maxExportBatches {
+		links, err := db.ListLinksForExport(cursor.LastUpdatedAt, int(cursor.LastId), now.Add(-exportLag), size)
+		if err != nil || len(links) == 0 {
+			return err
+		}
+
+		rows := make([]warehouse.Row, 0, len(links))
+		for _, link := range links {
+			rows = append(rows, linkRow(link))
+		}
+		if err := w.Insert(ctx, w.LinksTable, rows); err != nil {
+			return err
+		}
+
+		last := links[len(links)-1]
+		cursor.LastUpdatedAt, cursor.LastId = last.UpdatedAt, int64(last.Id)
+		if err := db.SaveExportCursor(cursor); err != nil {
+			return err
+		}
+		if len(links) < size {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// clickRow is a click event as shipped, with its truncated ip and without the raw one
+func clickRow(click *database.ClickEventModel) warehouse.Row {
+	return warehouse.Row{
+		InsertId: strconv.FormatInt(click.Id, 10),
+		Values: map[string]any{
+			"id":           click.Id,
+			"short_url_id": click.ShortUrlId,
+			"short_code":   click.ShortCode,
+			"ip":           click.Ip,
+			"ip_hash":      click.IpHash,
+			"user_agent":   click.UserAgent,
+			"referrer":     click.Referrer,
+			"clicked_at":   click.ClickedAt.UTC().Format(time.RFC3339Nano),
+		},
+	}
+}
+
+// linkRow is a version of a link as shipped, a row per change. The latest updated_at of an id is the current one
+func linkRow(link *database.ShortUrlModel) warehouse.Row {
+	var disabledAt *string
+	if link.DisabledAt != nil {
+		formatted := link.DisabledAt.UTC().Format(time.RFC3339Nano)
+		disabledAt = &formatted
+	}
+
+	return warehouse.Row{
+		InsertId: strconv.Itoa(link.Id) + "-" + strconv.FormatInt(link.UpdatedAt.UnixNano(), 10),
+		Values: map[string]any{
+			"id":               link.Id,
+			"short_code":       link.ShortCode,
+			"link":             link.Link,
+			"exp_time_minutes": link.ExpTimeMinutes,
+			"owner_id":         link.OwnerId,
+			"organization_id":  link.OrganizationId,
+			"domain_id":        link.DomainId,
+			"namespace_id":     link.NamespaceId,
+			"visibility":       link.Visibility,
+			"moderation_state": link.ModerationState,
+			"disabled_at":      disabledAt,
+			"disabled_reason":  link.DisabledReason,
+			"created_at":       link.CreatedAt.UTC().Format(time.RFC3339Nano),
+			"updated_at":       link.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/warehouse"
+)
+
+func TestExportToWarehouse(t *testing.T) {
+	inserted := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		table := r.URL.Query().Get("query")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			inserted[table]++
+		}
+		if strings.Contains(table, "`links`") {
+			http.Error(w, "Table analytics.links doesn't exist", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("WAREHOUSE", "clickhouse")
+	t.Setenv("CLICKHOUSE_URL", server.URL)
+	t.Setenv("CLICKHOUSE_DATABASE", "analytics")
+	dataWarehouse, err := warehouse.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 13, 12, 0, 0, 0, time.UTC)
+	db := &mocks.Service{
+		GetExportCursorFunc: func(stream string) (*database.ExportCursorModel, error) {
+			return &database.ExportCursorModel{Stream: stream, LastId: 40}, nil
+		},
+		ListClicksForExportFunc: func(afterId int64, before time.Time, limit int) ([]*database.ClickEventModel, error) {
+			if afterId != 40 || !before.Equal(now.Add(-exportLag)) {
+				t.Errorf("expected the clicks after the cursor, up to the lag; got after %d before %v", afterId, before)
+			}
+			return []*database.ClickEventModel{{Id: 41, ShortCode: "docs", RawIp: "203.0.113.7"}, {Id: 42, ShortCode: "docs"}}, nil
+		},
+		ListLinksForExportFunc: func(time.Time, int, time.Time, int) ([]*database.ShortUrlModel, error) {
+			return []*database.ShortUrlModel{{Id: 7, ShortCode: "docs", UpdatedAt: now.Add(-time.Hour)}}, nil
+		},
+	}
+
+	err = exportToWarehouse(context.Background(), db, dataWarehouse, now)
+	if err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Errorf("expected the failed links reported; got %v", err)
+	}
+	if inserted["INSERT INTO `analytics`.`click_events` FORMAT JSONEachRow"] != 2 {
+		t.Errorf("expected both clicks shipped; got %v", inserted)
+	}
+
+	// Only the clicks went through, the links are sent again next run
+	saved := db.CallsTo("SaveExportCursor")
+	if len(saved) != 1 {
+		t.Fatalf("expected the cursor of the clicks saved only; got %v", saved)
+	}
+	if cursor := saved[0].Args[0].(*database.ExportCursorModel); cursor.Stream != clicksStream || cursor.LastId != 42 {
+		t.Errorf("expected the clicks cursor moved to the last click; got %+v", cursor)
+	}
+
+	if _, ok := clickRow(&database.ClickEventModel{RawIp: "203.0.113.7"}).Values["raw_ip"]; ok {
+		t.Error("expected raw ips kept out of the warehouse")
+	}
+}
@@ -0,0 +1,259 @@
+// loadgen sends realistic redirect traffic to a running instance, to measure it under load: it creates a set of
+// links, then follows them from many clients at once, a few popular links taking most of the clicks, with a share
+// of unknown codes as sent by scanners. It reports the status codes and the latencies seen.
+//
+//	go run ./cmd/loadgen -base-url http://localhost:8080 -links 200 -duration 1m -concurrency 32
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+	"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+	"curl/8.5.0",
+}
+
+var referrers = []string{
+	"",
+	"https://www.google.com/",
+	"https://news.ycombinator.com/",
+	"https://t.co/",
+	"https://mail.example.com/",
+}
+
+type config struct {
+	baseUrl     string
+	apiKey      string
+	links       int
+	duration    time.Duration
+	concurrency int
+	rate        int
+	skew        float64
+	unknown     float64
+}
+
+// result is what a worker saw of a single redirect
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.baseUrl, "base-url", "http://localhost:"+os.Getenv("PORT"), "base url of the instance")
+	flag.StringVar(&cfg.apiKey, "api-key", os.Getenv("LOADGEN_API_KEY"), "api key the links are created with, anonymous when empty")
+	flag.IntVar(&cfg.links, "links", 100, "links created before the run")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "how long redirects are sent")
+	flag.IntVar(&cfg.concurrency, "concurrency", 16, "clients sending redirects at once")
+	flag.IntVar(&cfg.rate, "rate", 0, "redirects per second across every client, unlimited when 0")
+	flag.Float64Var(&cfg.skew, "skew", 1.1, "how much the popular links dominate the clicks, the s of a Zipf distribution, above 1")
+	flag.Float64Var(&cfg.unknown, "unknown", 0.05, "share of the redirects asking for codes that don't exist")
+	flag.Parse()
+
+	if cfg.links <= 0 || cfg.concurrency <= 0 || cfg.skew <= 1 || cfg.unknown < 0 || cfg.unknown > 1 {
+		log.Fatalf("[loadgen:main] Invalid flags, -links and -concurrency must be positive, -skew above 1 and -unknown between 0 and 1")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// The redirects are what is measured, not the destinations
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{MaxIdleConnsPerHost: cfg.concurrency},
+	}
+
+	codes, err := createLinks(ctx, client, cfg)
+	if err != nil {
+		log.Fatalf("[loadgen:main] Could not create the links: %v", err)
+	}
+	log.Printf("[loadgen:main] Created {%d} links, sending redirects for {%s}", len(codes), cfg.duration)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	started := time.Now()
+	results := run(ctx, client, cfg, codes)
+	report(os.Stdout, results, time.Since(started))
+}
+
+// createLinks shortens cfg.links links and returns their codes
+func createLinks(ctx context.Context, client *http.Client, cfg config) ([]string, error) {
+	codes := make([]string, 0, cfg.links)
+	for i := range cfg.links {
+		body, _ := json.Marshal(map[string]any{
+			"link_to_short":    fmt.Sprintf("https://example.com/loadgen/%d-%d", time.Now().UnixNano(), i),
+			"exp_time_minutes": int(cfg.duration.Minutes()) + 60,
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.baseUrl+"/short", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cfg.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.apiKey)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var created struct {
+			ShortUrl string `json:"short_url"`
+			Message  string `json:"message"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("link %d: status %d: %s", i, resp.StatusCode, created.Message)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("link %d: %w", i, err)
+		}
+
+		codes = append(codes, path.Base(created.ShortUrl))
+	}
+	return codes, nil
+}
+
+// run sends redirects from cfg.concurrency workers until ctx is done
+func run(ctx context.Context, client *http.Client, cfg config, codes []string) []result {
+	var ticks <-chan time.Time
+	if cfg.rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(cfg.rate))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var (
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	for worker := range cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			popularity := rand.NewZipf(random, cfg.skew, 1, uint64(len(codes)-1))
+			var seen []result
+
+			for {
+				if ticks != nil {
+					select {
+					case <-ctx.Done():
+					case <-ticks:
+					}
+				}
+				if ctx.Err() != nil {
+					break
+				}
+
+				code := codes[popularity.Uint64()]
+				if random.Float64() < cfg.unknown {
+					code = fmt.Sprintf("missing%d", random.Intn(1_000_000))
+				}
+				seen = append(seen, redirect(ctx, client, cfg.baseUrl, code, random))
+			}
+
+			mu.Lock()
+			results = append(results, seen...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func redirect(ctx context.Context, client *http.Client, baseUrl, code string, random *rand.Rand) result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/short/"+code, nil)
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("User-Agent", userAgents[random.Intn(len(userAgents))])
+	if referrer := referrers[random.Intn(len(referrers))]; referrer != "" {
+		req.Header.Set("Referer", referrer)
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// Requests cut by the end of the run aren't failures of the instance
+		if ctx.Err() != nil {
+			return result{}
+		}
+		return result{err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return result{status: resp.StatusCode, latency: time.Since(started)}
+}
+
+func report(w io.Writer, results []result, elapsed time.Duration) {
+	statuses := map[int]int{}
+	failures := map[string]int{}
+	latencies := make([]time.Duration, 0, len(results))
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failures[result.err.Error()]++
+		case result.status != 0:
+			statuses[result.status]++
+			latencies = append(latencies, result.latency)
+		}
+	}
+	slices.Sort(latencies)
+
+	fmt.Fprintf(w, "%d redirects in %s, %.0f per second\n", len(latencies), elapsed.Round(time.Millisecond), float64(len(latencies))/elapsed.Seconds())
+
+	codes := make([]int, 0, len(statuses))
+	for status := range statuses {
+		codes = append(codes, status)
+	}
+	slices.Sort(codes)
+	for _, status := range codes {
+		fmt.Fprintf(w, "  %d %s: %d\n", status, http.StatusText(status), statuses[status])
+	}
+	for err, count := range failures {
+		fmt.Fprintf(w, "  error %s: %d\n", strings.TrimSpace(err), count)
+	}
+
+	if len(latencies) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "latency p50 %s, p90 %s, p99 %s, max %s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99), latencies[len(latencies)-1])
+}
+
+// percentile returns the latency below which the share p of the sorted latencies fall
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index].Round(time.Microsecond)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	"url-shortner/internal/database"
+)
+
+const apiKeyPrefix = "us_"
+
+// Scopes an api key can be restricted to
+const (
+	ScopeLinksWrite = "links:write"
+	ScopeLinksRead  = "links:read"
+	ScopeStatsRead  = "stats:read"
+	ScopeAdmin      = "admin"
+)
+
+// DefaultScopes are given to api keys created without choosing scopes. They exclude ScopeAdmin
+var DefaultScopes = []string{ScopeLinksWrite, ScopeLinksRead, ScopeStatsRead}
+
+// ValidScope reports whether scope is one of the known scopes.
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeLinksWrite, ScopeLinksRead, ScopeStatsRead, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+type contextKey string
+
+const (
+	userContextKey    contextKey = "auth_user"
+	apiKeyContextKey  contextKey = "auth_api_key"
+	sessionContextKey contextKey = "auth_session"
+)
+
+// GenerateApiKey creates a new random api key.
+// It returns the plain key, which must be shown to the user only once,
+// a short prefix used to identify the key in listings and the hash to be stored.
+func GenerateApiKey() (plain string, prefix string, hash string, err error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	plain = apiKeyPrefix + hex.EncodeToString(buf)
+	return plain, plain[:len(apiKeyPrefix)+6], HashApiKey(plain), nil
+}
+
+// HashApiKey returns the hex encoded SHA-256 of an api key.
+func HashApiKey(plain string) string {
+	return HashToken(plain)
+}
+
+// HashToken returns the hex encoded SHA-256 of a secret token, the only form tokens are stored in.
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithUser returns a copy of ctx carrying the authenticated user.
+func WithUser(ctx context.Context, user *database.UserModel) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the authenticated user, or nil for anonymous requests.
+func UserFromContext(ctx context.Context) *database.UserModel {
+	user, _ := ctx.Value(userContextKey).(*database.UserModel)
+	return user
+}
+
+// WithApiKey returns a copy of ctx carrying the api key the request authenticated with.
+func WithApiKey(ctx context.Context, apiKey *database.ApiKeyModel) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, apiKey)
+}
+
+// ApiKeyFromContext returns the api key the request authenticated with, or nil for anonymous and admin token requests.
+func ApiKeyFromContext(ctx context.Context) *database.ApiKeyModel {
+	apiKey, _ := ctx.Value(apiKeyContextKey).(*database.ApiKeyModel)
+	return apiKey
+}
+
+// WithSession returns a copy of ctx carrying the dashboard session the request authenticated with.
+func WithSession(ctx context.Context, session *database.SessionModel) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// SessionFromContext returns the session the request authenticated with through its cookie, or nil.
+func SessionFromContext(ctx context.Context) *database.SessionModel {
+	session, _ := ctx.Value(sessionContextKey).(*database.SessionModel)
+	return session
+}
+
+// HasScope reports whether the request in ctx may act within scope. Only api keys are restricted:
+// anonymous and admin token requests are left to the other checks of the route.
+func HasScope(ctx context.Context, scope string) bool {
+	apiKey := ApiKeyFromContext(ctx)
+	return apiKey == nil || slices.Contains(apiKey.Scopes, scope)
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+)
+
+// ErrNoVerifiedEmail is returned when the provider account has no verified email to match a local user with
+var ErrNoVerifiedEmail = errors.New("oauth: the account has no verified email")
+
+// Provider runs the OAuth2 authorization code flow, with PKCE, against an identity provider.
+type Provider struct {
+	Name         string
+	clientId     string
+	clientSecret string
+	redirectUrl  string
+	scopes       []string
+
+	authUrl  string
+	tokenUrl string
+
+	// identity fetches the account of the access token from the provider specific api
+	identity   func(ctx context.Context, p *Provider, accessToken string) (*Identity, error)
+	apiUrl     string
+	httpClient *http.Client
+}
+
+// Identity is the account a user signed in with.
+type Identity struct {
+	Provider string
+	Subject  string
+	Email    string
+}
+
+// OAuthProviders returns the providers configured through GOOGLE_CLIENT_ID / GOOGLE_CLIENT_SECRET and
+// GITHUB_CLIENT_ID / GITHUB_CLIENT_SECRET. Callbacks are sent to OAUTH_REDIRECT_BASE_URL/auth/{provider}/callback.
+func OAuthProviders() map[string]*Provider {
+	baseUrl := strings.TrimSuffix(os.Getenv("OAUTH_REDIRECT_BASE_URL"), "/")
+	providers := map[string]*Provider{}
+
+	if clientId := os.Getenv("GOOGLE_CLIENT_ID"); clientId != "" {
+		providers[ProviderGoogle] = &Provider{
+			Name:         ProviderGoogle,
+			clientId:     clientId,
+			clientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			redirectUrl:  baseUrl + "/auth/" + ProviderGoogle + "/callback",
+			scopes:       []string{"openid", "email"},
+			authUrl:      "https://accounts.google.com/o/oauth2/v2/auth",
+			tokenUrl:     "https://oauth2.googleapis.com/token",
+			identity:     googleIdentity,
+			apiUrl:       "https://openidconnect.googleapis.com",
+			httpClient:   &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+
+	if clientId := os.Getenv("GITHUB_CLIENT_ID"); clientId != "" {
+		providers[ProviderGitHub] = &Provider{
+			Name:         ProviderGitHub,
+			clientId:     clientId,
+			clientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			redirectUrl:  baseUrl + "/auth/" + ProviderGitHub + "/callback",
+			scopes:       []string{"read:user", "user:email"},
+			authUrl:      "https://github.com/login/oauth/authorize",
+			tokenUrl:     "https://github.com/login/oauth/access_token",
+			identity:     githubIdentity,
+			apiUrl:       "https://api.github.com",
+			httpClient:   &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+
+	return providers
+}
+
+// NewState returns a random value binding the callback to the browser that started the flow.
+func NewState() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewPKCE returns a PKCE code verifier and its S256 challenge (RFC 7636).
+func NewPKCE() (verifier string, challenge string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	verifier = base64.RawURLEncoding.EncodeToString(buf)
+	return verifier, pkceChallenge(verifier), nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL is where the browser is sent to sign in with the provider.
+func (p *Provider) AuthCodeURL(state string, challenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientId},
+		"redirect_uri":          {p.redirectUrl},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	return p.authUrl + "?" + query.Encode()
+}
+
+// Exchange trades the authorization code of the callback for an access token.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectUrl},
+		"client_id":     {p.clientId},
+		"client_secret": {p.clientSecret},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("oauth: build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub answers form encoded unless asked for JSON
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return "", fmt.Errorf("oauth: exchange code: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("oauth: exchange code: no access token, error %q", token.Error)
+	}
+
+	return token.AccessToken, nil
+}
+
+// Identity fetches the account the access token belongs to. It fails with ErrNoVerifiedEmail when the
+// account has no verified email.
+func (p *Provider) Identity(ctx context.Context, accessToken string) (*Identity, error) {
+	return p.identity(ctx, p, accessToken)
+}
+
+func (p *Provider) get(ctx context.Context, path string, accessToken string, body any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiUrl+path, nil)
+	if err != nil {
+		return fmt.Errorf("oauth: build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	if err := p.do(req, body); err != nil {
+		return fmt.Errorf("oauth: get %s: %w", path, err)
+	}
+	return nil
+}
+
+func (p *Provider) do(req *http.Request, body any) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+func googleIdentity(ctx context.Context, p *Provider, accessToken string) (*Identity, error) {
+	var userInfo struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := p.get(ctx, "/v1/userinfo", accessToken, &userInfo); err != nil {
+		return nil, err
+	}
+
+	if userInfo.Email == "" || !userInfo.EmailVerified {
+		return nil, ErrNoVerifiedEmail
+	}
+
+	return &Identity{Provider: p.Name, Subject: userInfo.Sub, Email: userInfo.Email}, nil
+}
+
+func githubIdentity(ctx context.Context, p *Provider, accessToken string) (*Identity, error) {
+	var user struct {
+		Id int64 `json:"id"`
+	}
+	if err := p.get(ctx, "/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+
+	// The public email of the profile may be unset or unverified, the primary verified one is listed separately
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, "/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return &Identity{Provider: p.Name, Subject: strconv.FormatInt(user.Id, 10), Email: email.Email}, nil
+		}
+	}
+
+	return nil, ErrNoVerifiedEmail
+}
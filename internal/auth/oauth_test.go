@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	// Example of RFC 7636 appendix B
+	challenge := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("unexpected challenge %q", challenge)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	provider := &Provider{
+		Name:        ProviderGitHub,
+		clientId:    "client",
+		redirectUrl: "https://sho.rt/auth/github/callback",
+		scopes:      []string{"read:user", "user:email"},
+		authUrl:     "https://github.com/login/oauth/authorize",
+	}
+
+	parsed, err := url.Parse(provider.AuthCodeURL("state", "challenge"))
+	if err != nil {
+		t.Fatalf("invalid url: %v", err)
+	}
+
+	query := parsed.Query()
+	expected := map[string]string{
+		"client_id":             "client",
+		"redirect_uri":          "https://sho.rt/auth/github/callback",
+		"scope":                 "read:user user:email",
+		"state":                 "state",
+		"code_challenge":        "challenge",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range expected {
+		if query.Get(name) != value {
+			t.Errorf("expected %s=%q; got %q", name, value, query.Get(name))
+		}
+	}
+}
+
+func fakeGitHub(t *testing.T, emails string) *Provider {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "code" || r.PostForm.Get("code_verifier") != "verifier" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token"})
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 42}`))
+	})
+	mux.HandleFunc("GET /user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(emails))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &Provider{
+		Name:       ProviderGitHub,
+		tokenUrl:   server.URL + "/token",
+		identity:   githubIdentity,
+		apiUrl:     server.URL,
+		httpClient: &http.Client{Timeout: time.Second},
+	}
+}
+
+func TestGitHubSignIn(t *testing.T) {
+	tests := []struct {
+		name          string
+		emails        string
+		expectedEmail string
+		expectedErr   error
+	}{
+		{"primary verified email", `[{"email": "old@example.com", "verified": true}, {"email": "me@example.com", "primary": true, "verified": true}]`, "me@example.com", nil},
+		{"unverified primary email", `[{"email": "me@example.com", "primary": true}]`, "", ErrNoVerifiedEmail},
+	}
+
+	for _, tt := range tests {
+		provider := fakeGitHub(t, tt.emails)
+
+		accessToken, err := provider.Exchange(context.Background(), "code", "verifier")
+		if err != nil {
+			t.Fatalf("%s: error exchanging code: %v", tt.name, err)
+		}
+
+		identity, err := provider.Identity(context.Background(), accessToken)
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expectedErr, err)
+		}
+		if err == nil && (identity.Subject != "42" || identity.Email != tt.expectedEmail) {
+			t.Errorf("%s: unexpected identity %+v", tt.name, identity)
+		}
+	}
+}
+
+func TestExchangeRejectedCode(t *testing.T) {
+	provider := fakeGitHub(t, "[]")
+
+	if _, err := provider.Exchange(context.Background(), "wrong", "verifier"); err == nil {
+		t.Error("expected an error for a rejected code")
+	}
+}
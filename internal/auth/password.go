@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bounds of a password. bcrypt ignores whatever comes after 72 bytes
+const (
+	MinPasswordLength = 10
+	MaxPasswordBytes  = 72
+)
+
+// Compared against when the user is unknown, so signing in takes as long whether the email exists or not
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not the password of anyone"), bcrypt.DefaultCost)
+
+// ValidatePassword checks password is long enough to be used, and short enough to be hashed whole.
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return fmt.Errorf("the password must have at least %d characters", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordBytes {
+		return fmt.Errorf("the password must have at most %d bytes", MaxPasswordBytes)
+	}
+	return nil
+}
+
+// HashPassword returns the bcrypt hash of a password, the only form passwords are stored in.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash. An empty hash, for users without password, never matches
+// but takes as long to check.
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
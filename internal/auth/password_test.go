@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswords(t *testing.T) {
+	for _, password := range []string{"short", strings.Repeat("a", MaxPasswordBytes+1), strings.Repeat("é", 40)} {
+		if err := ValidatePassword(password); err == nil {
+			t.Errorf("expected %q refused", password)
+		}
+	}
+	if err := ValidatePassword("correct horse battery"); err != nil {
+		t.Errorf("expected a long password accepted; got %v", err)
+	}
+
+	hash, err := HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "correct horse battery" || !CheckPassword(hash, "correct horse battery") {
+		t.Errorf("expected the password hashed and checked")
+	}
+	if CheckPassword(hash, "wrong horse battery") || CheckPassword("", "") {
+		t.Errorf("expected a wrong password, or a user without one, refused")
+	}
+}
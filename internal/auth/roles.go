@@ -0,0 +1,58 @@
+package auth
+
+import "url-shortner/internal/database"
+
+// Roles of a user, from the least to the most privileged. Each role can do everything the ones before it can
+const (
+	// RoleViewer can read links and their stats
+	RoleViewer = "viewer"
+	// RoleEditor can also create and edit links
+	RoleEditor = "editor"
+	// RoleAdmin can also manage users, domains and blocklists
+	RoleAdmin = "admin"
+)
+
+// RoleRank orders user roles, it is 0 for unknown roles.
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// ValidRole reports whether role is one of the known user roles.
+func ValidRole(role string) bool {
+	return RoleRank(role) > 0
+}
+
+// RoleAllows reports whether a user with role may act where required is needed.
+func RoleAllows(role string, required string) bool {
+	return RoleRank(role) > 0 && RoleRank(role) >= RoleRank(required)
+}
+
+// OrgRoleRank orders the roles of organization members, it is 0 for unknown roles.
+func OrgRoleRank(role string) int {
+	switch role {
+	case database.OrgRoleViewer:
+		return 1
+	case database.OrgRoleEditor:
+		return 2
+	case database.OrgRoleAdmin:
+		return 3
+	case database.OrgRoleOwner:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// OrgRoleAllows reports whether a member with role may act where required is needed.
+func OrgRoleAllows(role string, required string) bool {
+	return OrgRoleRank(role) > 0 && OrgRoleRank(role) >= OrgRoleRank(required)
+}
@@ -0,0 +1,70 @@
+// Package blobstore keeps files too large for the database, such as the archives of exports, on the local disk or
+// in an S3 compatible bucket, as configured by the BLOBSTORE environment variable.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for keys holding nothing
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key. Keys are slash separated paths, e.g. jobs/42/export.zip
+type Store interface {
+	// Put stores the size bytes read from body at key, replacing what was there
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Get opens the blob at key, to be closed by the caller. It returns ErrNotFound when there is none
+	Get(ctx context.Context, key string) (*Blob, error)
+
+	// Delete removes the blob at key. Deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is a stored blob being read
+type Blob struct {
+	io.ReadCloser
+
+	Size        int64
+	ContentType string
+}
+
+// New returns the store configured by BLOBSTORE, disk or s3, and the variables of that store. It returns nil when
+// BLOBSTORE isn't set, callers then keep their files in the database.
+func New() (Store, error) {
+	switch kind := os.Getenv("BLOBSTORE"); kind {
+	case "":
+		return nil, nil
+	case "disk":
+		return newDisk(envOr("BLOBSTORE_DIR", "data/blobs"))
+	case "s3":
+		return newS3()
+	default:
+		return nil, fmt.Errorf("invalid BLOBSTORE %q, expected disk or s3", kind)
+	}
+}
+
+// validKey reports whether key is a relative slash separated path that stays below the root of the store
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,130 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisk(t *testing.T) {
+	store, err := newDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "jobs/42/export.zip", strings.NewReader("archive"), 7, "application/zip"); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := store.Get(ctx, "jobs/42/export.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(content) != "archive" || blob.Size != 7 {
+		t.Errorf("expected the blob read back; got %q of %d bytes", content, blob.Size)
+	}
+
+	if err := store.Delete(ctx, "jobs/42/export.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "jobs/42/export.zip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the deleted blob not found; got %v", err)
+	}
+	if err := store.Delete(ctx, "jobs/42/export.zip"); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed; got %v", err)
+	}
+
+	for _, key := range []string{"../etc/passwd", "/etc/passwd", "jobs//42", ""} {
+		if err := store.Put(ctx, key, strings.NewReader(""), 0, ""); err == nil {
+			t.Errorf("expected the key %q refused", key)
+		}
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	// The example of the AWS documentation on deriving signing keys
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if encoded := hex.EncodeToString(key); encoded != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("unexpected signing key %s", encoded)
+	}
+}
+
+func TestS3(t *testing.T) {
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=minio/20250614/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+			t.Errorf("unexpected authorization %q", authorization)
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.EscapedPath()] = string(body)
+		case http.MethodGet:
+			object, ok := objects[r.URL.EscapedPath()]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+				return
+			}
+			w.Write([]byte(object))
+		case http.MethodDelete:
+			delete(objects, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	store := &s3{
+		endpoint:    endpoint,
+		bucket:      "archives",
+		region:      "us-east-1",
+		accessKeyId: "minio",
+		secretKey:   "minio-secret",
+		pathStyle:   true,
+		httpClient:  server.Client(),
+		now:         func() time.Time { return time.Date(2025, 6, 14, 12, 0, 0, 0, time.UTC) },
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "jobs/tenant a/42.zip", strings.NewReader("archive"), 7, "application/zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects["/archives/jobs/tenant%20a/42.zip"]; !ok {
+		t.Fatalf("expected the object stored in the bucket, its key escaped; got %v", objects)
+	}
+
+	blob, err := store.Get(ctx, "jobs/tenant a/42.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(content) != "archive" {
+		t.Errorf("expected the object read back; got %q", content)
+	}
+
+	if err := store.Delete(ctx, "jobs/tenant a/42.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "jobs/tenant a/42.zip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the deleted object not found; got %v", err)
+	}
+
+	virtualHosted := &s3{endpoint: &url.URL{Scheme: "https", Host: "s3.eu-west-1.amazonaws.com"}, bucket: "archives"}
+	if objectUrl := virtualHosted.objectUrl("jobs/42.zip").String(); objectUrl != "https://archives.s3.eu-west-1.amazonaws.com/jobs/42.zip" {
+		t.Errorf("expected the bucket in the hostname; got %s", objectUrl)
+	}
+}
@@ -0,0 +1,94 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// disk keeps blobs as files below a directory. Content types aren't kept
+type disk struct {
+	dir string
+}
+
+func newDisk(dir string) (*disk, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("blobstore: create BLOBSTORE_DIR: %w", err)
+	}
+	return &disk{dir: dir}, nil
+}
+
+func (d *disk) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("blobstore: invalid key %q", key)
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file renamed over the blob, so readers never see it half written
+func (d *disk) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("blobstore: put %s: %w", key, err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return fmt.Errorf("blobstore: put %s: %w", key, err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("blobstore: put %s: %w", key, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("blobstore: put %s: %w", key, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("blobstore: put %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (d *disk) Get(ctx context.Context, key string) (*Blob, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("blobstore: get %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get %s: %w", key, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("blobstore: get %s: %w", key, err)
+	}
+
+	return &Blob{ReadCloser: file, Size: info.Size()}, nil
+}
+
+func (d *disk) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("blobstore: delete %s: %w", key, err)
+	}
+	return nil
+}
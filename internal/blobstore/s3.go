@@ -0,0 +1,225 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Payloads aren't hashed, so Put streams the body. Requests are still signed, and sent over TLS unless the endpoint
+// says otherwise
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3 keeps blobs in a bucket of Amazon S3 or of a compatible store such as MinIO, signing requests with SigV4
+type s3 struct {
+	endpoint    *url.URL
+	bucket      string
+	region      string
+	accessKeyId string
+	secretKey   string
+	pathStyle   bool
+	httpClient  *http.Client
+	now         func() time.Time
+}
+
+// newS3 reads BLOBSTORE_S3_BUCKET, BLOBSTORE_S3_REGION, BLOBSTORE_S3_ENDPOINT, BLOBSTORE_S3_ACCESS_KEY_ID,
+// BLOBSTORE_S3_SECRET_ACCESS_KEY and BLOBSTORE_S3_PATH_STYLE
+func newS3() (*s3, error) {
+	bucket := os.Getenv("BLOBSTORE_S3_BUCKET")
+	accessKeyId, secretKey := os.Getenv("BLOBSTORE_S3_ACCESS_KEY_ID"), os.Getenv("BLOBSTORE_S3_SECRET_ACCESS_KEY")
+	if bucket == "" || accessKeyId == "" || secretKey == "" {
+		return nil, fmt.Errorf("BLOBSTORE_S3_BUCKET, BLOBSTORE_S3_ACCESS_KEY_ID and BLOBSTORE_S3_SECRET_ACCESS_KEY are required with BLOBSTORE=s3")
+	}
+
+	region := envOr("BLOBSTORE_S3_REGION", "us-east-1")
+	endpoint, err := url.Parse(envOr("BLOBSTORE_S3_ENDPOINT", "https://s3."+region+".amazonaws.com"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid BLOBSTORE_S3_ENDPOINT %q", os.Getenv("BLOBSTORE_S3_ENDPOINT"))
+	}
+
+	return &s3{
+		endpoint:    endpoint,
+		bucket:      bucket,
+		region:      region,
+		accessKeyId: accessKeyId,
+		secretKey:   secretKey,
+		pathStyle:   os.Getenv("BLOBSTORE_S3_PATH_STYLE") == "true",
+		httpClient:  &http.Client{Timeout: 5 * time.Minute},
+		now:         time.Now,
+	}, nil
+}
+
+// objectUrl is the url of key, with the bucket in the path for MinIO and the like, in the hostname otherwise
+func (s *s3) objectUrl(key string) *url.URL {
+	objectUrl := *s.endpoint
+	if s.pathStyle {
+		objectUrl.Path = "/" + s.bucket + "/" + key
+	} else {
+		objectUrl.Host = s.bucket + "." + s.endpoint.Host
+		objectUrl.Path = "/" + key
+	}
+	objectUrl.RawPath = uriEncode(objectUrl.Path)
+	return &objectUrl
+}
+
+func (s *s3) request(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("blobstore: invalid key %q", key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.objectUrl(key).String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: build request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *s3) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("blobstore: put %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("blobstore: put %s: %s", key, errorOf(resp))
+	}
+
+	return nil
+}
+
+func (s *s3) Get(ctx context.Context, key string) (*Blob, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get %s: %w", key, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("blobstore: get %s: %w", key, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("blobstore: get %s: %s", key, errorOf(resp))
+	}
+
+	return &Blob{ReadCloser: resp.Body, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+func (s *s3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("blobstore: delete %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	// S3 answers 204 whether or not the object existed
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("blobstore: delete %s: %s", key, errorOf(resp))
+	}
+
+	return nil
+}
+
+func (s *s3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, s.now().UTC())
+	return s.httpClient.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 of req to its headers
+func (s *s3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + unsignedPayload + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signature := hex.EncodeToString(hmacSha256(signingKey(s.secretKey, date, s.region, "s3"), stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKeyId+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// signingKey derives the key of a day, region and service from the secret access key
+func signingKey(secretKey string, date string, region string, service string) []byte {
+	key := hmacSha256([]byte("AWS4"+secretKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, service)
+	return hmacSha256(key, "aws4_request")
+}
+
+func hmacSha256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	// Encode sorts by key
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// uriEncode escapes every byte of a path but slashes and the unreserved characters, as SigV4 expects
+func uriEncode(value string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~', b == '/':
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// errorOf describes a failed response, with the code S3 puts in its XML error
+func errorOf(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if start, end := strings.Index(string(body), "<Code>"), strings.Index(string(body), "</Code>"); start >= 0 && end > start {
+		return fmt.Sprintf("status %d, %s", resp.StatusCode, string(body)[start+len("<Code>"):end])
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode)
+}
@@ -0,0 +1,68 @@
+// Package bloom implements a Bloom filter: a compact set that may answer that it contains a value it doesn't, at
+// a chosen rate, but never that it doesn't contain a value it does.
+package bloom
+
+import (
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+)
+
+// Filter is safe for concurrent use, values can be added while others are tested
+type Filter struct {
+	words  []uint64
+	bits   uint64
+	hashes int
+}
+
+// New returns a filter sized to hold capacity values with a false positive rate of falsePositives, e.g. 0.01
+func New(capacity int, falsePositives float64) *Filter {
+	capacity = max(capacity, 1)
+
+	// The optimal sizes: m = -n ln(p) / ln(2)^2 bits and k = m/n ln(2) hashes
+	bits := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositives) / (math.Ln2 * math.Ln2)))
+	bits = max(bits, 64)
+	hashes := int(math.Round(float64(bits) / float64(capacity) * math.Ln2))
+
+	return &Filter{
+		words:  make([]uint64, (bits+63)/64),
+		bits:   bits,
+		hashes: max(hashes, 1),
+	}
+}
+
+// Add puts value in the filter
+func (f *Filter) Add(value string) {
+	h1, h2 := hash(value)
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.bits
+		atomic.OrUint64(&f.words[bit/64], 1<<(bit%64))
+	}
+}
+
+// MayContain reports false when value was never added, true when it probably was
+func (f *Filter) MayContain(value string) bool {
+	h1, h2 := hash(value)
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.bits
+		if atomic.LoadUint64(&f.words[bit/64])&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash derives the two hashes the positions are computed from, see Kirsch and Mitzenmacher
+func hash(value string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(value))
+	sum := h.Sum(nil)
+
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[8+i])
+	}
+	// An even step would only visit half of the positions when the size is even
+	return h1, h2 | 1
+}
@@ -0,0 +1,30 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		f.Add("code" + strconv.Itoa(i))
+	}
+
+	for i := 0; i < 10000; i++ {
+		if !f.MayContain("code" + strconv.Itoa(i)) {
+			t.Fatalf("expected code%d to be found", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.MayContain("missing" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	// 1% expected, with some room for the randomness of the hashes
+	if falsePositives > 200 {
+		t.Errorf("expected about 100 false positives; got %d", falsePositives)
+	}
+}
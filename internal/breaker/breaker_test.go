@@ -0,0 +1,82 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := New(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("expected call %d to be allowed, got %v", i, err)
+		}
+		b.Record(true)
+	}
+
+	if b.State() != Closed {
+		t.Fatalf("expected closed below the threshold, got %s", b.State())
+	}
+
+	b.Allow()
+	b.Record(true)
+
+	if b.State() != Open {
+		t.Fatalf("expected open at the threshold, got %s", b.State())
+	}
+
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected ErrOpen, got %v", err)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := New(2, time.Minute)
+
+	b.Allow()
+	b.Record(true)
+	b.Allow()
+	b.Record(false)
+	b.Allow()
+	b.Record(true)
+
+	if b.State() != Closed {
+		t.Fatalf("expected closed, got %s", b.State())
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	now := time.Now()
+	b := New(1, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	b.Allow()
+	b.Record(true)
+
+	now = now.Add(11 * time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected the probe to be allowed, got %v", err)
+	}
+	if b.State() != HalfOpen {
+		t.Fatalf("expected half-open, got %s", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected a single probe at a time, got %v", err)
+	}
+
+	b.Record(true)
+	if b.State() != Open {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %s", b.State())
+	}
+
+	now = now.Add(11 * time.Second)
+	b.Allow()
+	b.Record(false)
+
+	if b.State() != Closed {
+		t.Fatalf("expected a successful probe to close the breaker, got %s", b.State())
+	}
+}
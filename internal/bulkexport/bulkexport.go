@@ -0,0 +1,145 @@
+// Package bulkexport writes the archive an account is exported as: a zip of its links as CSV, in a format bulkimport
+// reads back, their clicks as CSV and everything else as JSON.
+package bulkexport
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortner/internal/database"
+)
+
+// Files of the archive
+const (
+	AccountFile = "account.json"
+	LinksFile   = "links.csv"
+	ClicksFile  = "clicks.csv"
+	StatsFile   = "stats.json"
+)
+
+// Link is a link of the account with its stats, nil when they could not be loaded
+type Link struct {
+	*database.ShortUrlModel
+	ShortUrl string
+	Stats    *database.LinkStatsModel
+}
+
+// Archive is everything exported about an account
+type Archive struct {
+	// Written as is to account.json
+	Account any
+
+	Links  []Link
+	Clicks []*database.ClickEventModel
+}
+
+type dailyClicks struct {
+	Day    string `json:"day"`
+	Clicks int    `json:"clicks"`
+}
+
+type linkStats struct {
+	ShortCode      string        `json:"short_code"`
+	TotalClicks    int           `json:"total_clicks"`
+	UniqueVisitors int           `json:"unique_visitors"`
+	Daily          []dailyClicks `json:"daily"`
+}
+
+// Write writes the archive to w as a zip
+func Write(w io.Writer, archive Archive) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeJSON(zw, AccountFile, archive.Account); err != nil {
+		return err
+	}
+	if err := writeLinks(zw, archive.Links); err != nil {
+		return err
+	}
+	if err := writeClicks(zw, archive.Clicks); err != nil {
+		return err
+	}
+
+	stats := []linkStats{}
+	for _, link := range archive.Links {
+		if link.Stats == nil {
+			continue
+		}
+		daily := make([]dailyClicks, 0, len(link.Stats.Daily))
+		for _, day := range link.Stats.Daily {
+			daily = append(daily, dailyClicks{Day: day.Day.Format(time.DateOnly), Clicks: day.Clicks})
+		}
+		stats = append(stats, linkStats{
+			ShortCode:      link.ShortCode,
+			TotalClicks:    link.Stats.TotalClicks,
+			UniqueVisitors: link.Stats.UniqueVisitors,
+			Daily:          daily,
+		})
+	}
+	if err := writeJSON(zw, StatsFile, stats); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, value any) error {
+	file, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeLinks starts with the columns bulkimport reads, so the file can be imported as is
+func writeLinks(zw *zip.Writer, links []Link) error {
+	file, err := zw.Create(LinksFile)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(file)
+	out.Write([]string{"link_to_short", "code", "namespace", "exp_time_minutes", "short_url", "times_clicked", "created_at", "disabled"})
+	for _, link := range links {
+		namespace, code := "", link.ShortCode
+		if link.NamespaceId != nil {
+			namespace, code, _ = strings.Cut(link.ShortCode, "/")
+		}
+		out.Write([]string{
+			link.Link,
+			code,
+			namespace,
+			strconv.Itoa(link.ExpTimeMinutes),
+			link.ShortUrl,
+			strconv.Itoa(link.TimesClicked),
+			link.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatBool(link.DisabledAt != nil),
+		})
+	}
+	out.Flush()
+
+	return out.Error()
+}
+
+func writeClicks(zw *zip.Writer, clicks []*database.ClickEventModel) error {
+	file, err := zw.Create(ClicksFile)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(file)
+	out.Write([]string{"short_code", "clicked_at", "ip", "user_agent", "referrer"})
+	for _, click := range clicks {
+		out.Write([]string{click.ShortCode, click.ClickedAt.UTC().Format(time.RFC3339), click.Ip, click.UserAgent, click.Referrer})
+	}
+	out.Flush()
+
+	return out.Error()
+}
@@ -0,0 +1,67 @@
+package bulkexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"url-shortner/internal/bulkimport"
+	"url-shortner/internal/database"
+)
+
+func TestWriteLinksImportable(t *testing.T) {
+	namespaceId := 3
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	archive := Archive{
+		Account: map[string]string{"email": "me@example.com"},
+		Links: []Link{
+			{ShortUrlModel: &database.ShortUrlModel{Link: "https://example.com/a", ShortCode: "abcde", ExpTimeMinutes: 60, CreatedAt: created}},
+			{ShortUrlModel: &database.ShortUrlModel{Link: "https://example.com/b", ShortCode: "docs/onboarding", NamespaceId: &namespaceId, ExpTimeMinutes: 120, CreatedAt: created}},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := Write(&buffer, archive); err != nil {
+		t.Fatalf("expected the archive to be written; got %v", err)
+	}
+
+	archived, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("expected a zip; got %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, file := range archived.File {
+		names[file.Name] = true
+	}
+	for _, name := range []string{AccountFile, LinksFile, ClicksFile, StatsFile} {
+		if !names[name] {
+			t.Errorf("expected %s in the archive; got %v", name, names)
+		}
+	}
+
+	links, err := archived.Open(LinksFile)
+	if err != nil {
+		t.Fatalf("expected %s to open; got %v", LinksFile, err)
+	}
+	defer links.Close()
+
+	rows, rowErrors, err := bulkimport.Parse(links)
+	if err != nil || len(rowErrors) > 0 {
+		t.Fatalf("expected %s to be importable; got %v %+v", LinksFile, err, rowErrors)
+	}
+
+	expected := []bulkimport.Row{
+		{Line: 2, Link: "https://example.com/a", Code: "abcde", ExpTimeMinutes: 60},
+		{Line: 3, Link: "https://example.com/b", Code: "onboarding", Namespace: "docs", ExpTimeMinutes: 120},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows; got %+v", len(expected), rows)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("row %d: expected %+v; got %+v", i, expected[i], rows[i])
+		}
+	}
+}
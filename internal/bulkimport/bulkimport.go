@@ -0,0 +1,168 @@
+// Package bulkimport reads the CSV files links are imported from, e.g. the export of another shortener, and holds
+// the per-row report of an import.
+package bulkimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Most rows a single file may hold
+	MaxRows = 10000
+
+	// Lifetime of the links of rows without exp_time_minutes, exports of other shorteners rarely have one
+	DefaultExpTimeMinutes = 365 * 24 * 60
+)
+
+var (
+	ErrNoLinkColumn = errors.New("the header must have a link_to_short, long_url, url or destination column")
+	ErrTooManyRows  = fmt.Errorf("a file can't hold more than %d rows", MaxRows)
+)
+
+// Accepted names of each column, the first one found in the header is used. long_url and custom_bitlinks are the
+// columns of bit.ly exports
+var (
+	linkColumns      = []string{"link_to_short", "long_url", "url", "destination"}
+	codeColumns      = []string{"code", "custom_code", "custom_bitlinks", "keyword"}
+	expiryColumns    = []string{"exp_time_minutes"}
+	namespaceColumns = []string{"namespace"}
+)
+
+// Row is a link to create
+type Row struct {
+	// Line of the file, the header being line 1
+	Line int `json:"line"`
+
+	Link           string `json:"link"`
+	ExpTimeMinutes int    `json:"exp_time_minutes"`
+	Code           string `json:"code,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+}
+
+// RowError is why a row was skipped
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Created is a link created from a row
+type Created struct {
+	Line      int    `json:"line"`
+	ShortCode string `json:"short_code"`
+	ShortUrl  string `json:"short_url"`
+}
+
+// Result reports an import row by row
+type Result struct {
+	Rows    int        `json:"rows"`
+	Created []Created  `json:"created"`
+	Errors  []RowError `json:"errors"`
+}
+
+// NewResult returns an empty report for rows rows, with the errors already found while parsing
+func NewResult(rows int, parseErrors []RowError) *Result {
+	return &Result{Rows: rows, Created: []Created{}, Errors: append([]RowError{}, parseErrors...)}
+}
+
+// Parse reads a CSV file with a header. Rows that can't be read are reported as errors and left out, the whole file
+// fails only when its header lacks a link column or it holds more than MaxRows rows.
+func Parse(r io.Reader) ([]Row, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, ErrNoLinkColumn
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+
+	link := column(columns, linkColumns)
+	if link < 0 {
+		return nil, nil, ErrNoLinkColumn
+	}
+	code, expiry, namespace := column(columns, codeColumns), column(columns, expiryColumns), column(columns, namespaceColumns)
+
+	var rows []Row
+	var rowErrors []RowError
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, RowError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+
+		if len(rows)+len(rowErrors) >= MaxRows {
+			return nil, nil, ErrTooManyRows
+		}
+
+		row := Row{
+			Line:           line,
+			Link:           field(record, link),
+			ExpTimeMinutes: DefaultExpTimeMinutes,
+			Code:           lastSegment(field(record, code)),
+			Namespace:      field(record, namespace),
+		}
+		if row.Link == "" {
+			rowErrors = append(rowErrors, RowError{Line: line, Error: "missing link"})
+			continue
+		}
+		if value := field(record, expiry); value != "" {
+			minutes, err := strconv.Atoi(value)
+			if err != nil {
+				rowErrors = append(rowErrors, RowError{Line: line, Error: fmt.Sprintf("invalid exp_time_minutes %q", value)})
+				continue
+			}
+			row.ExpTimeMinutes = minutes
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+func column(columns map[string]int, names []string) int {
+	for _, name := range names {
+		if i, ok := columns[name]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// lastSegment keeps the code of a short link exported in full, e.g. "summer" for "bit.ly/summer"
+func lastSegment(code string) string {
+	return code[strings.LastIndex(code, "/")+1:]
+}
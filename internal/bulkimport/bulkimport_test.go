@@ -0,0 +1,63 @@
+package bulkimport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	file := "\ufeffTitle,long_url,custom_bitlinks,exp_time_minutes\n" +
+		"Summer,https://example.com/summer,bit.ly/summer,60\n" +
+		"No link,,,\n" +
+		"Bad expiry,https://example.com/a,,soon\n" +
+		"\n" +
+		"Plain,https://example.com/b\n" +
+		"Broken,\"https://example.com/c\n"
+
+	rows, rowErrors, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("expected the file to parse; got %v", err)
+	}
+
+	expected := []Row{
+		{Line: 2, Link: "https://example.com/summer", Code: "summer", ExpTimeMinutes: 60},
+		{Line: 6, Link: "https://example.com/b", ExpTimeMinutes: DefaultExpTimeMinutes},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows; got %+v", len(expected), rows)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("row %d: expected %+v; got %+v", i, expected[i], rows[i])
+		}
+	}
+
+	expectedLines := []int{3, 4, 7}
+	if len(rowErrors) != len(expectedLines) {
+		t.Fatalf("expected errors on lines %v; got %+v", expectedLines, rowErrors)
+	}
+	for i, line := range expectedLines {
+		if rowErrors[i].Line != line {
+			t.Errorf("error %d: expected line %d; got %+v", i, line, rowErrors[i])
+		}
+	}
+}
+
+func TestParseRefusedFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		expected error
+	}{
+		{"empty", "", ErrNoLinkColumn},
+		{"no link column", "title,code\nSummer,summer\n", ErrNoLinkColumn},
+		{"too many rows", "url\n" + strings.Repeat("https://example.com\n", MaxRows+1), ErrTooManyRows},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := Parse(strings.NewReader(tt.file)); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU[int](2, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected least recently used entry to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("expected a to be cached; got %v, %v", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("expected c to be cached; got %v, %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries; got %d", c.Len())
+	}
+}
+
+func TestLRUExpiration(t *testing.T) {
+	c := NewLRU[string](10, 10*time.Millisecond)
+
+	c.Set("a", "value")
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to be expired")
+	}
+	if value, ok := c.GetStale("a"); !ok || value != "value" {
+		t.Errorf("expected the expired value of a; got %q", value)
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	c := NewLRU[string](10, time.Minute)
+
+	c.Set("a", "value")
+	c.Delete("a")
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to be deleted")
+	}
+}
+
+func TestLRUClear(t *testing.T) {
+	c := NewLRU[string](10, time.Minute)
+
+	c.Set("a", "value")
+	c.Set("b", "value")
+	c.Clear()
+
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Errorf("expected no entries left; got %d", c.Len())
+	}
+
+	c.Set("a", "value")
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("expected the cache usable after Clear")
+	}
+}
@@ -0,0 +1,148 @@
+// Package captcha verifies the CAPTCHA answers of visitors with hCaptcha or reCAPTCHA, whose siteverify APIs are
+// alike.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Providers of CAPTCHA_PROVIDER
+const (
+	HCaptcha  = "hcaptcha"
+	ReCaptcha = "recaptcha"
+)
+
+var endpoints = map[string]string{
+	HCaptcha:  "https://api.hcaptcha.com/siteverify",
+	ReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// ErrFailed is returned for answers the provider refused: missing, wrong, expired, already used, or scored as a bot
+var ErrFailed = errors.New("captcha: verification failed")
+
+// Client verifies answers with the provider of the deployment.
+type Client struct {
+	Provider string
+	// Public key of the widget, sent to the visitors so they can answer
+	SiteKey string
+
+	secret     string
+	minScore   float64
+	endpoint   string
+	httpClient *http.Client
+
+	// FailOpen lets requests through when the provider can't be reached
+	FailOpen bool
+}
+
+// New returns a client configured from CAPTCHA_PROVIDER, CAPTCHA_SITE_KEY, CAPTCHA_SECRET, CAPTCHA_MIN_SCORE,
+// CAPTCHA_FAIL_OPEN and CAPTCHA_VERIFY_URL. It returns nil when no provider is configured, meaning nobody is asked to
+// answer one.
+func New() *Client {
+	provider := os.Getenv("CAPTCHA_PROVIDER")
+	if endpoints[provider] == "" {
+		return nil
+	}
+
+	endpoint := os.Getenv("CAPTCHA_VERIFY_URL")
+	if endpoint == "" {
+		endpoint = endpoints[provider]
+	}
+
+	minScore, _ := strconv.ParseFloat(os.Getenv("CAPTCHA_MIN_SCORE"), 64)
+	return &Client{
+		Provider:   provider,
+		SiteKey:    os.Getenv("CAPTCHA_SITE_KEY"),
+		secret:     os.Getenv("CAPTCHA_SECRET"),
+		minScore:   minScore,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		FailOpen:   os.Getenv("CAPTCHA_FAIL_OPEN") == "true",
+	}
+}
+
+// CheckConfig validates the CAPTCHA_* settings, so a half configured provider fails on boot
+func CheckConfig() error {
+	provider := os.Getenv("CAPTCHA_PROVIDER")
+	if provider == "" {
+		return nil
+	}
+	if endpoints[provider] == "" {
+		return fmt.Errorf("invalid CAPTCHA_PROVIDER %q, expected %s or %s", provider, HCaptcha, ReCaptcha)
+	}
+	if os.Getenv("CAPTCHA_SECRET") == "" || os.Getenv("CAPTCHA_SITE_KEY") == "" {
+		return fmt.Errorf("CAPTCHA_PROVIDER %s needs CAPTCHA_SECRET and CAPTCHA_SITE_KEY", provider)
+	}
+	if value := os.Getenv("CAPTCHA_VERIFY_URL"); value != "" {
+		if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("invalid CAPTCHA_VERIFY_URL %q", value)
+		}
+	}
+	if value := os.Getenv("CAPTCHA_MIN_SCORE"); value != "" {
+		if score, err := strconv.ParseFloat(value, 64); err != nil || score < 0 || score > 1 {
+			return fmt.Errorf("invalid CAPTCHA_MIN_SCORE %q, expected a number between 0 and 1", value)
+		}
+	}
+	return nil
+}
+
+type verifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify checks the answer of a visitor coming from remoteIp. It returns ErrFailed when the provider refuses it, and
+// other errors when the provider can't tell
+func (c *Client) Verify(ctx context.Context, answer string, remoteIp string) error {
+	if answer == "" {
+		return ErrFailed
+	}
+
+	form := url.Values{"secret": {c.secret}, "response": {answer}}
+	if remoteIp != "" {
+		form.Set("remoteip", remoteIp)
+	}
+	if c.Provider == HCaptcha && c.SiteKey != "" {
+		form.Set("sitekey", c.SiteKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("captcha: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha: verification failed to complete: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha: verification returned status %d", resp.StatusCode)
+	}
+
+	var respBody verifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return fmt.Errorf("captcha: invalid response: %w", err)
+	}
+
+	if !respBody.Success {
+		return fmt.Errorf("%w: %s", ErrFailed, strings.Join(respBody.ErrorCodes, ", "))
+	}
+	// Only scoring CAPTCHAs, reCAPTCHA v3 and hCaptcha Enterprise, answer a score
+	if respBody.Score != nil && *respBody.Score < c.minScore {
+		return fmt.Errorf("%w: score %.2f below %.2f", ErrFailed, *respBody.Score, c.minScore)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("secret") != "test-secret" || r.PostForm.Get("remoteip") != "203.0.113.7" {
+			t.Errorf("expected the secret and the ip of the visitor sent; got %v", r.PostForm)
+		}
+
+		switch r.PostForm.Get("response") {
+		case "solved":
+			w.Write([]byte(`{"success": true}`))
+		case "human":
+			w.Write([]byte(`{"success": true, "score": 0.9}`))
+		case "bot":
+			w.Write([]byte(`{"success": true, "score": 0.1}`))
+		case "down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{Provider: ReCaptcha, secret: "test-secret", minScore: 0.5, endpoint: server.URL, httpClient: server.Client()}
+
+	tests := []struct {
+		answer string
+		failed bool
+	}{
+		{answer: "solved"},
+		{answer: "human"},
+		{answer: "bot", failed: true},
+		{answer: "wrong", failed: true},
+		{answer: "", failed: true},
+	}
+
+	for _, tt := range tests {
+		err := client.Verify(context.Background(), tt.answer, "203.0.113.7")
+		if errors.Is(err, ErrFailed) != tt.failed || (!tt.failed && err != nil) {
+			t.Errorf("%q: expected failed %v; got %v", tt.answer, tt.failed, err)
+		}
+	}
+
+	if err := client.Verify(context.Background(), "down", "203.0.113.7"); err == nil || errors.Is(err, ErrFailed) {
+		t.Errorf("expected an unavailable provider told apart from a refusal; got %v", err)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		provider string
+		secret   string
+		minScore string
+		valid    bool
+	}{
+		{valid: true},
+		{provider: HCaptcha, secret: "secret", valid: true},
+		{provider: ReCaptcha, secret: "secret", minScore: "0.5", valid: true},
+		{provider: ReCaptcha, secret: "secret", minScore: "2", valid: false},
+		{provider: HCaptcha, valid: false},
+		{provider: "turnstile", secret: "secret", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("CAPTCHA_PROVIDER", tt.provider)
+		t.Setenv("CAPTCHA_SECRET", tt.secret)
+		t.Setenv("CAPTCHA_SITE_KEY", "site-key")
+		t.Setenv("CAPTCHA_MIN_SCORE", tt.minScore)
+
+		if err := CheckConfig(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid %v; got %v", tt, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package capture keeps the last requests and responses of the api in memory, sanitized, to diagnose what an
+// integrator sends without a packet capture. It is meant to be switched on for a while and never by default.
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSize      = 100
+	defaultBodyBytes = 4096
+
+	redacted = "[REDACTED]"
+)
+
+// Names of headers, query parameters and json fields whose value is never kept, matched case insensitively
+// anywhere in the name, e.g. "X-Api-Key" or "refresh_token"
+var sensitive = []string{"authorization", "cookie", "token", "secret", "password", "api-key", "api_key", "apikey"}
+
+// The plain api keys are returned once, as "key"
+const keyField = "key"
+
+// Exchange is a request and the response it got
+type Exchange struct {
+	Id              int64             `json:"id"`
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	Route           string            `json:"route,omitempty"`
+	RemoteAddr      string            `json:"remote_addr"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body"`
+	Status          int               `json:"status"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body"`
+	DurationMs      float64           `json:"duration_ms"`
+}
+
+// Ring holds the last exchanges, the oldest being dropped once it is full
+type Ring struct {
+	// Bytes of each body kept, the rest is cut
+	BodyBytes int
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	next      int
+	lastId    int64
+}
+
+// New returns a ring configured by DEBUG_CAPTURE_SIZE and DEBUG_CAPTURE_BODY_BYTES. It returns nil unless
+// DEBUG_CAPTURE is "true", meaning nothing is captured.
+func New() *Ring {
+	if os.Getenv("DEBUG_CAPTURE") != "true" {
+		return nil
+	}
+
+	size, err := strconv.Atoi(os.Getenv("DEBUG_CAPTURE_SIZE"))
+	if err != nil || size <= 0 {
+		size = defaultSize
+	}
+	bodyBytes, err := strconv.Atoi(os.Getenv("DEBUG_CAPTURE_BODY_BYTES"))
+	if err != nil || bodyBytes < 0 {
+		bodyBytes = defaultBodyBytes
+	}
+
+	log.Printf("[capture:New] Capturing the last {%d} requests and responses, turn DEBUG_CAPTURE off once done", size)
+	return NewRing(size, bodyBytes)
+}
+
+// NewRing returns a ring holding size exchanges with bodies cut past bodyBytes
+func NewRing(size int, bodyBytes int) *Ring {
+	return &Ring{BodyBytes: bodyBytes, exchanges: make([]Exchange, 0, size)}
+}
+
+// Add sanitizes exchange and keeps it, numbering it. The Route of the exchange, e.g.
+// "/api/v1/orgs/invitations/{token}/accept", tells which segments of its path are sensitive
+func (r *Ring) Add(exchange Exchange) {
+	exchange.URL = sanitizeURL(exchange.URL, exchange.Route)
+	exchange.RequestBody = sanitizeBody(exchange.RequestBody)
+	exchange.ResponseBody = sanitizeBody(exchange.ResponseBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	exchange.Id = r.lastId
+	if len(r.exchanges) < cap(r.exchanges) {
+		r.exchanges = append(r.exchanges, exchange)
+		return
+	}
+	r.exchanges[r.next] = exchange
+	r.next = (r.next + 1) % len(r.exchanges)
+}
+
+// List returns the exchanges kept, the newest first
+func (r *Ring) List() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]Exchange, 0, len(r.exchanges))
+	for i := len(r.exchanges) - 1; i >= 0; i-- {
+		list = append(list, r.exchanges[(r.next+i)%len(r.exchanges)])
+	}
+	return list
+}
+
+// Clear drops every exchange kept
+func (r *Ring) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.exchanges = r.exchanges[:0]
+	r.next = 0
+}
+
+// Headers flattens headers, redacting the sensitive ones
+func Headers(headers http.Header) map[string]string {
+	flat := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if isSensitive(name) {
+			flat[name] = redacted
+			continue
+		}
+		flat[name] = strings.Join(values, ", ")
+	}
+	return flat
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	if name == keyField {
+		return true
+	}
+	for _, word := range sensitive {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func sanitizeURL(raw string, route string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	if path := sanitizePath(parsed.Path, route); path != parsed.Path {
+		parsed.Path, parsed.RawPath = path, ""
+	}
+	if parsed.RawQuery == "" {
+		return parsed.String()
+	}
+
+	query := parsed.Query()
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, redacted)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// sanitizePath redacts the segments of path matched by a sensitive parameter of route, e.g. the token of
+// "/api/v1/orgs/invitations/{token}/accept". Segments past a wildcard are kept as they are
+func sanitizePath(path string, route string) string {
+	if route == "" {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, pattern := range strings.Split(route, "/") {
+		if i >= len(segments) || pattern == "*" {
+			break
+		}
+		name, ok := strings.CutPrefix(pattern, "{")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(strings.TrimSuffix(name, "}"), ":")
+		if isSensitive(name) {
+			segments[i] = redacted
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sanitizeBody redacts the sensitive fields of a json body, at any depth. Other bodies, like forms, CSV files or
+// pages, can't be told apart from secrets and only their size is kept. A json body cut by BodyBytes can't be parsed
+// and is dropped as well
+func sanitizeBody(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" {
+		return body
+	}
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return fmt.Sprintf("[%d bytes of non json body, not kept]", len(body))
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(trimmed), &value); err != nil {
+		return "[unparseable json, not kept]"
+	}
+	sanitized, _ := json.Marshal(redact(value))
+	return string(sanitized)
+}
+
+func redact(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if isSensitive(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = redact(field)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = redact(item)
+		}
+	}
+	return value
+}
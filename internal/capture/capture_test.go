@@ -0,0 +1,66 @@
+package capture
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(2, 100)
+	for _, method := range []string{"GET", "POST", "PUT"} {
+		ring.Add(Exchange{Method: method})
+	}
+
+	var methods []string
+	var ids []int64
+	for _, exchange := range ring.List() {
+		methods = append(methods, exchange.Method)
+		ids = append(ids, exchange.Id)
+	}
+	if !slices.Equal(methods, []string{"PUT", "POST"}) || !slices.Equal(ids, []int64{3, 2}) {
+		t.Errorf("expected the last two exchanges newest first; got %v %v", methods, ids)
+	}
+
+	ring.Clear()
+	if list := ring.List(); len(list) != 0 {
+		t.Errorf("expected no exchanges once cleared; got %v", list)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	headers := Headers(http.Header{
+		"Authorization": {"Bearer usk_secret"},
+		"X-Api-Key":     {"usk_secret"},
+		"Content-Type":  {"application/json"},
+	})
+	if headers["Authorization"] != redacted || headers["X-Api-Key"] != redacted || headers["Content-Type"] != "application/json" {
+		t.Errorf("expected the credentials redacted; got %v", headers)
+	}
+
+	if url := sanitizeURL("/short/abc?token=usk_secret&ref=home", "/short/{short_code}"); url != "/short/abc?ref=home&token=%5BREDACTED%5D" {
+		t.Errorf("expected the token redacted from the url; got %s", url)
+	}
+
+	if url := sanitizeURL("/api/v1/orgs/invitations/inv_secret/accept", "/api/v1/orgs/invitations/{token}/accept"); url != "/api/v1/orgs/invitations/%5BREDACTED%5D/accept" {
+		t.Errorf("expected the token redacted from the path; got %s", url)
+	}
+
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"link":"https://example.com","password":"hunter2"}`, `{"link":"https://example.com","password":"[REDACTED]"}`},
+		{`{"api_key":{"id":1},"key":"usk_secret"}`, `{"api_key":"[REDACTED]","key":"[REDACTED]"}`},
+		{`[{"webhook":{"secret":"s"}}]`, `[{"webhook":{"secret":"[REDACTED]"}}]`},
+		{`{"password":"hunt`, "[unparseable json, not kept]"},
+		{"<html>Not found</html>", "[22 bytes of non json body, not kept]"},
+		{"link_to_short=https%3A%2F%2Fexample.com&password=hunter2", "[56 bytes of non json body, not kept]"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if body := sanitizeBody(tt.body); body != tt.expected {
+			t.Errorf("expected %s sanitized as %s; got %s", tt.body, tt.expected, body)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *service) SaveAbuseReport(abuseReportModel *AbuseReportModel) (*AbuseReportModel, error) {
+	log.Printf("[database:SaveAbuseReport] Reporting shortCode {%s} for {%s}", abuseReportModel.ShortCode, abuseReportModel.Reason)
+
+	query := `INSERT INTO abuse_reports (short_url_id, reason, details, reporter_email, reporter_ip, reporter_user_agent)
+		SELECT id, $2, $3, NULLIF($4, ''), $5, $6 FROM short_url WHERE short_code = $1 LIMIT 1
+		RETURNING id, short_url_id, reason, status, created_at;`
+
+	inserted := &AbuseReportModel{}
+	err := s.db.QueryRow(context.Background(), query, abuseReportModel.ShortCode, abuseReportModel.Reason, abuseReportModel.Details, abuseReportModel.ReporterEmail, abuseReportModel.ReporterIp, abuseReportModel.ReporterUserAgent).Scan(&inserted.Id, &inserted.ShortUrlId, &inserted.Reason, &inserted.Status, &inserted.CreatedAt)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("[database:SaveAbuseReport] Error inserting abuse report: %v", err)
+		}
+		return nil, fmt.Errorf("save abuse report for %s: %w", abuseReportModel.ShortCode, notFound(err))
+	}
+
+	inserted.ShortCode = abuseReportModel.ShortCode
+
+	return inserted, nil
+}
+
+func (s *service) ListAbuseReports(status string) ([]*AbuseReportModel, error) {
+	query := `SELECT ar.id, ar.short_url_id, su.short_code, su.link, ar.reason, COALESCE(ar.details, ''), COALESCE(ar.reporter_email, ''),
+			COALESCE(ar.reporter_ip, ''), COALESCE(ar.reporter_user_agent, ''), ar.status, ar.created_at, ar.resolved_at
+		FROM abuse_reports AS ar
+		JOIN short_url AS su ON su.id = ar.short_url_id
+		WHERE ar.status = $1
+		ORDER BY ar.id;`
+
+	rows, err := s.db.Query(context.Background(), query, status)
+	if err != nil {
+		log.Printf("[database:ListAbuseReports] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list %s abuse reports: %w", status, err)
+	}
+	defer rows.Close()
+
+	reports := []*AbuseReportModel{}
+	for rows.Next() {
+		report := &AbuseReportModel{}
+		err := rows.Scan(&report.Id, &report.ShortUrlId, &report.ShortCode, &report.Link, &report.Reason, &report.Details, &report.ReporterEmail,
+			&report.ReporterIp, &report.ReporterUserAgent, &report.Status, &report.CreatedAt, &report.ResolvedAt)
+		if err != nil {
+			log.Printf("[database:ListAbuseReports] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list %s abuse reports: %w", status, err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+func (s *service) DismissAbuseReport(id int) error {
+	log.Printf("[database:DismissAbuseReport] Dismissing abuse report {%d}", id)
+
+	result, err := s.db.Exec(context.Background(), "UPDATE abuse_reports SET status = $2, resolved_at = NOW() WHERE id = $1 AND status = $3;", id, ReportDismissed, ReportOpen)
+	if err != nil {
+		log.Printf("[database:DismissAbuseReport] Could not dismiss abuse report {%d}: %v", id, err)
+		return fmt.Errorf("dismiss abuse report %d: %w", id, err)
+	}
+
+	affected := result.RowsAffected()
+
+	if affected == 0 {
+		return fmt.Errorf("dismiss abuse report %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) DisableReportedLink(id int) error {
+	log.Printf("[database:DisableReportedLink] Disabling link of abuse report {%d}", id)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("disable link of abuse report %d: %w", id, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	var shortUrlId int
+	var reason string
+	err = tx.QueryRow(context.Background(), "SELECT short_url_id, reason FROM abuse_reports WHERE id = $1 AND status = $2;", id, ReportOpen).Scan(&shortUrlId, &reason)
+	if err != nil {
+		return fmt.Errorf("disable link of abuse report %d: %w", id, notFound(err))
+	}
+
+	_, err = tx.Exec(context.Background(), "UPDATE short_url SET disabled_at = COALESCE(disabled_at, NOW()), disabled_reason = $2 WHERE id = $1;", shortUrlId, "abuse report: "+reason)
+	if err != nil {
+		log.Printf("[database:DisableReportedLink] Could not disable short url {%d}: %v", shortUrlId, err)
+		return fmt.Errorf("disable link of abuse report %d: %w", id, err)
+	}
+
+	_, err = tx.Exec(context.Background(), "UPDATE abuse_reports SET status = $2, resolved_at = NOW() WHERE short_url_id = $1 AND status = $3;", shortUrlId, ReportActioned, ReportOpen)
+	if err != nil {
+		log.Printf("[database:DisableReportedLink] Could not close reports of short url {%d}: %v", shortUrlId, err)
+		return fmt.Errorf("disable link of abuse report %d: %w", id, err)
+	}
+
+	return tx.Commit(context.Background())
+}
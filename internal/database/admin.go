@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/normalize"
+	"url-shortner/internal/pagination"
+)
+
+func (s *service) ListShortUrls(page pagination.Page) ([]*ShortUrlModel, error) {
+	log.Printf("[database:ListShortUrls] Listing short urls with limit {%d}", page.Limit)
+
+	query := "SELECT " + shortUrlColumns + " FROM short_url"
+	args := []any{}
+	if page.After != nil {
+		query += " WHERE (created_at, id) < ($1, $2)"
+		args = append(args, page.After.CreatedAt, page.After.Id)
+	}
+	args = append(args, page.Fetch())
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d;", len(args))
+
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		log.Printf("[database:ListShortUrls] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list short urls: %w", err)
+	}
+	defer rows.Close()
+
+	shortUrls := []*ShortUrlModel{}
+	for rows.Next() {
+		shortUrl, err := scanShortUrl(rows)
+		if err != nil {
+			log.Printf("[database:ListShortUrls] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list short urls: %w", err)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+
+	return shortUrls, rows.Err()
+}
+
+func (s *service) FindShortUrlsByLink(link string) ([]*ShortUrlModel, error) {
+	query := "SELECT " + shortUrlColumns + " FROM short_url WHERE link_hash = $1 ORDER BY id;"
+
+	rows, err := s.db.Query(context.Background(), query, normalize.Hash(link))
+	if err != nil {
+		log.Printf("[database:FindShortUrlsByLink] Something went wrong: %v", err)
+		return nil, fmt.Errorf("find short urls by link: %w", err)
+	}
+	defer rows.Close()
+
+	shortUrls := []*ShortUrlModel{}
+	for rows.Next() {
+		shortUrl, err := scanShortUrl(rows)
+		if err != nil {
+			log.Printf("[database:FindShortUrlsByLink] Error scanning row: %v", err)
+			return nil, fmt.Errorf("find short urls by link: %w", err)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+
+	return shortUrls, rows.Err()
+}
+
+func (s *service) ListActiveShortUrls(afterId int, limit int) ([]*ShortUrlModel, error) {
+	query := `SELECT ` + shortUrlColumns + `
+		FROM short_url
+		WHERE id > $1 AND disabled_at IS NULL AND NOW() < created_at + (exp_time_minutes || ' minutes')::interval
+		ORDER BY id LIMIT $2;`
+
+	rows, err := s.db.Query(context.Background(), query, afterId, limit)
+	if err != nil {
+		log.Printf("[database:ListActiveShortUrls] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list active short urls after %d: %w", afterId, err)
+	}
+	defer rows.Close()
+
+	shortUrls := []*ShortUrlModel{}
+	for rows.Next() {
+		shortUrl, err := scanShortUrl(rows)
+		if err != nil {
+			log.Printf("[database:ListActiveShortUrls] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list active short urls after %d: %w", afterId, err)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+
+	return shortUrls, rows.Err()
+}
+
+func (s *service) DeleteShortUrl(shortCode string) error {
+	log.Printf("[database:DeleteShortUrl] Deleting short url for shortCode: {%s}", shortCode)
+
+	query := "DELETE FROM short_url WHERE short_code = $1;"
+
+	result, err := s.db.Exec(context.Background(), query, shortCode)
+	if err != nil {
+		log.Printf("[database:DeleteShortUrl] something went wrong while deleting shortCode {%s}: %v", shortCode, err)
+		return fmt.Errorf("delete short url %s: %w", shortCode, err)
+	}
+
+	affected := result.RowsAffected()
+
+	if affected == 0 {
+		log.Printf("[database:DeleteShortUrl] No short url found for shortCode: {%s}", shortCode)
+		return fmt.Errorf("delete short url %s: %w", shortCode, ErrNotFound)
+	}
+
+	log.Printf("[database:DeleteShortUrl] Short url deleted for shortCode: {%s}", shortCode)
+
+	return nil
+}
+
+func (s *service) LastShortUrlDeletion() (time.Time, error) {
+	var deletedAt time.Time
+	err := s.read.QueryRow(context.Background(), "SELECT last_deleted_at FROM short_url_deletions;").Scan(&deletedAt)
+	if err != nil {
+		log.Printf("[database:LastShortUrlDeletion] Something went wrong: %v", err)
+		return time.Time{}, fmt.Errorf("last short url deletion: %w", notFound(err))
+	}
+
+	return deletedAt, nil
+}
+
+func (s *service) GlobalStats() (*GlobalStatsModel, error) {
+	log.Printf("[database:GlobalStats] Collecting global stats")
+
+	query := `SELECT
+		COUNT(*),
+		COUNT(*) FILTER (WHERE NOW() < created_at + (exp_time_minutes || ' minutes')::interval),
+		COUNT(*) FILTER (WHERE NOW() >= created_at + (exp_time_minutes || ' minutes')::interval),
+		(SELECT COALESCE(SUM(clicks), 0)::bigint FROM link_click_counters),
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL)
+	FROM short_url;`
+
+	stats := &GlobalStatsModel{}
+	err := s.read.QueryRow(context.Background(), query).Scan(&stats.TotalLinks, &stats.ActiveLinks, &stats.ExpiredLinks, &stats.TotalClicks, &stats.TotalUsers, &stats.TotalApiKeys)
+	if err != nil {
+		log.Printf("[database:GlobalStats] Something went wrong: %v", err)
+		return nil, fmt.Errorf("global stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (s *service) PurgeShortUrl(shortCode string) (*PurgeSummaryModel, error) {
+	log.Printf("[database:PurgeShortUrl] Purging shortCode: {%s}", shortCode)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		log.Printf("[database:PurgeShortUrl] Could not begin transaction: %v", err)
+		return nil, fmt.Errorf("purge short url %s: %w", shortCode, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	summary := &PurgeSummaryModel{ShortCode: shortCode}
+
+	result, err := tx.Exec(context.Background(), "DELETE FROM click_events WHERE short_url_id IN (SELECT id FROM short_url WHERE short_code = $1);", shortCode)
+	if err != nil {
+		log.Printf("[database:PurgeShortUrl] something went wrong while deleting click events of shortCode {%s}: %v", shortCode, err)
+		return nil, fmt.Errorf("purge short url %s: %w", shortCode, err)
+	}
+	summary.ClickEventsRemoved = result.RowsAffected()
+
+	query := "DELETE FROM short_url WHERE short_code = $1 RETURNING link, " + timesClickedExpr + ";"
+
+	rows, err := tx.Query(context.Background(), query, shortCode)
+	if err != nil {
+		log.Printf("[database:PurgeShortUrl] something went wrong while deleting shortCode {%s}: %v", shortCode, err)
+		return nil, fmt.Errorf("purge short url %s: %w", shortCode, err)
+	}
+	for rows.Next() {
+		var timesClicked int
+		if err := rows.Scan(&summary.Link, &timesClicked); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("purge short url %s: %w", shortCode, err)
+		}
+		summary.LinksRemoved++
+		summary.TimesClicked += timesClicked
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("purge short url %s: %w", shortCode, err)
+	}
+
+	if summary.LinksRemoved == 0 {
+		log.Printf("[database:PurgeShortUrl] No short url found for shortCode: {%s}", shortCode)
+		return nil, fmt.Errorf("purge short url %s: %w", shortCode, ErrNotFound)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Printf("[database:PurgeShortUrl] Could not commit purge for shortCode {%s}: %v", shortCode, err)
+		return nil, fmt.Errorf("purge short url %s: %w", shortCode, err)
+	}
+
+	log.Printf("[database:PurgeShortUrl] Purged shortCode {%s}: %+v", shortCode, summary)
+
+	return summary, nil
+}
+
+func (s *service) CountUserLinks(userId int, since time.Time) (*LinkCountModel, error) {
+	count, err := s.countLinks("owner_id", userId, since)
+	if err != nil {
+		return nil, fmt.Errorf("count links of user %d: %w", userId, err)
+	}
+	return count, nil
+}
+
+func (s *service) CountApiKeyLinks(apiKeyId int, since time.Time) (*LinkCountModel, error) {
+	count, err := s.countLinks("api_key_id", apiKeyId, since)
+	if err != nil {
+		return nil, fmt.Errorf("count links of api key %d: %w", apiKeyId, err)
+	}
+	return count, nil
+}
+
+// CountAnonymousLinks counts the links created without an owner nor an api key since a point in time
+func (s *service) CountAnonymousLinks(since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM short_url WHERE owner_id IS NULL AND api_key_id IS NULL AND created_at >= $1;`
+
+	var count int
+	if err := s.db.QueryRow(context.Background(), query, since).Scan(&count); err != nil {
+		log.Printf("[database:CountAnonymousLinks] Something went wrong: %v", err)
+		return 0, fmt.Errorf("count anonymous links: %w", err)
+	}
+
+	return count, nil
+}
+
+// countLinks counts the links matching column, which is never user input. It reads from the primary since
+// quotas are checked right before inserting and a lagging replica would let a burst of links through
+func (s *service) countLinks(column string, id int, since time.Time) (*LinkCountModel, error) {
+	query := `SELECT
+		COUNT(*) FILTER (WHERE created_at >= $2),
+		COUNT(*) FILTER (WHERE disabled_at IS NULL AND NOW() < created_at + (exp_time_minutes || ' minutes')::interval)
+	FROM short_url WHERE ` + column + ` = $1;`
+
+	count := &LinkCountModel{}
+	if err := s.db.QueryRow(context.Background(), query, id, since).Scan(&count.Created, &count.Active); err != nil {
+		log.Printf("[database:countLinks] Something went wrong: %v", err)
+		return nil, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (s *service) RecordAudit(entry *AuditLogModel) error {
+	query := "INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, ip) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''));"
+
+	_, err := s.db.Exec(context.Background(), query, entry.Actor, entry.Action, entry.EntityType, entry.EntityId, nullableJSON(entry.Before), nullableJSON(entry.After), entry.Ip)
+	if err != nil {
+		log.Printf("[database:RecordAudit] Could not record {%s} on {%s:%s}: %v", entry.Action, entry.EntityType, entry.EntityId, err)
+		return fmt.Errorf("record audit %s: %w", entry.Action, err)
+	}
+
+	return nil
+}
+
+func (s *service) ListAuditLog(filter AuditLogFilter) ([]*AuditLogModel, error) {
+	conditions := []string{}
+	args := []any{}
+
+	addCondition := func(column string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if filter.Actor != "" {
+		addCondition("actor", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action", filter.Action)
+	}
+	if filter.EntityType != "" {
+		addCondition("entity_type", filter.EntityType)
+	}
+	if filter.EntityId != "" {
+		addCondition("entity_id", filter.EntityId)
+	}
+	if after := filter.Page.After; after != nil {
+		args = append(args, after.CreatedAt, after.Id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := "SELECT id, actor, action, entity_type, entity_id, before, after, COALESCE(ip, ''), created_at FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Page.Fetch())
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d;", len(args))
+
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		log.Printf("[database:ListAuditLog] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*AuditLogModel{}
+	for rows.Next() {
+		entry := &AuditLogModel{}
+		err := rows.Scan(&entry.Id, &entry.Actor, &entry.Action, &entry.EntityType, &entry.EntityId, &entry.Before, &entry.After, &entry.Ip, &entry.CreatedAt)
+		if err != nil {
+			log.Printf("[database:ListAuditLog] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list audit log: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullableJSON maps an empty document to SQL NULL.
+func nullableJSON(document []byte) any {
+	if len(document) == 0 {
+		return nil
+	}
+	return string(document)
+}
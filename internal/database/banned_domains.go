@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// hostExpr extracts the lowercased host of short_url.link in SQL, mirroring policy.HostOf.
+const hostExpr = `lower(substring(link from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/?#]*@)?([^/:?#]+)'))`
+
+func (s *service) SaveBannedDomain(bannedDomainModel *BannedDomainModel) (*BannedDomainModel, error) {
+	query := "INSERT INTO banned_domains (pattern, reason) VALUES ($1, $2) RETURNING id, pattern, COALESCE(reason, ''), created_at;"
+
+	inserted := &BannedDomainModel{}
+	err := s.db.QueryRow(context.Background(), query, bannedDomainModel.Pattern, bannedDomainModel.Reason).Scan(&inserted.Id, &inserted.Pattern, &inserted.Reason, &inserted.CreatedAt)
+	if err != nil {
+		log.Printf("[database:SaveBannedDomain] Error inserting banned domain: %v", err)
+		return nil, fmt.Errorf("ban domain %s: %w", bannedDomainModel.Pattern, unique(err))
+	}
+
+	log.Printf("[database:SaveBannedDomain] Banned pattern: {%s}", inserted.Pattern)
+
+	return inserted, nil
+}
+
+func (s *service) ListBannedDomains() ([]*BannedDomainModel, error) {
+	query := "SELECT id, pattern, COALESCE(reason, ''), created_at FROM banned_domains ORDER BY id;"
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:ListBannedDomains] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list banned domains: %w", err)
+	}
+	defer rows.Close()
+
+	bannedDomains := []*BannedDomainModel{}
+	for rows.Next() {
+		bannedDomain := &BannedDomainModel{}
+		if err := rows.Scan(&bannedDomain.Id, &bannedDomain.Pattern, &bannedDomain.Reason, &bannedDomain.CreatedAt); err != nil {
+			log.Printf("[database:ListBannedDomains] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list banned domains: %w", err)
+		}
+		bannedDomains = append(bannedDomains, bannedDomain)
+	}
+
+	return bannedDomains, rows.Err()
+}
+
+func (s *service) DeleteBannedDomain(id int) error {
+	log.Printf("[database:DeleteBannedDomain] Removing banned domain with id: {%d}", id)
+
+	result, err := s.db.Exec(context.Background(), "DELETE FROM banned_domains WHERE id = $1;", id)
+	if err != nil {
+		log.Printf("[database:DeleteBannedDomain] something went wrong while deleting banned domain {%d}: %v", id, err)
+		return fmt.Errorf("delete banned domain %d: %w", id, err)
+	}
+
+	affected := result.RowsAffected()
+
+	if affected == 0 {
+		return fmt.Errorf("delete banned domain %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) DisableBannedLinks() (int64, error) {
+	log.Printf("[database:DisableBannedLinks] Disabling links pointing at banned domains")
+
+	// Plain patterns match the domain and its subdomains, patterns with "*" are globs over the whole host
+	query := `UPDATE short_url AS su
+		SET disabled_at = NOW(), disabled_reason = 'banned domain: ' || bd.pattern
+		FROM banned_domains AS bd
+		WHERE su.disabled_at IS NULL
+		AND (
+			CASE WHEN strpos(bd.pattern, '*') > 0
+				THEN ` + hostExpr + ` LIKE replace(replace(bd.pattern, '_', '\_'), '*', '%')
+				ELSE ` + hostExpr + ` = bd.pattern OR ` + hostExpr + ` LIKE '%.' || bd.pattern
+			END
+		);`
+
+	result, err := s.db.Exec(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:DisableBannedLinks] something went wrong: %v", err)
+		return 0, fmt.Errorf("disable banned links: %w", err)
+	}
+
+	affected := result.RowsAffected()
+
+	log.Printf("[database:DisableBannedLinks] Disabled {%d} links", affected)
+
+	return affected, nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"url-shortner/internal/normalize"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// shortUrlBatchColumns are copied into the short_url_batch staging table by SaveShortUrls, in this order
+var shortUrlBatchColumns = []string{"position", "link", "exp_time_minutes", "short_code", "owner_id", "link_hash", "api_key_id", "organization_id", "domain_id", "namespace_id", "visibility"}
+
+// SaveShortUrls copies the links into a staging table, then moves them to short_url in a single statement. COPY
+// can't skip rows, so the move is what leaves out the links whose code is taken
+func (s *service) SaveShortUrls(shortUrlModels []*ShortUrlModel) ([]*ShortUrlModel, error) {
+	if len(shortUrlModels) == 0 {
+		return []*ShortUrlModel{}, nil
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+	}
+	defer tx.Rollback(context.Background())
+
+	staging := `CREATE TEMP TABLE short_url_batch ON COMMIT DROP AS
+		SELECT 0 AS position, link, exp_time_minutes, short_code, owner_id, link_hash, api_key_id, organization_id, domain_id, namespace_id, visibility
+		FROM short_url WITH NO DATA;`
+	if _, err := tx.Exec(context.Background(), staging); err != nil {
+		return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+	}
+
+	_, err = tx.CopyFrom(context.Background(), pgx.Identifier{"short_url_batch"}, shortUrlBatchColumns, pgx.CopyFromSlice(len(shortUrlModels), func(i int) ([]any, error) {
+		shortUrl := shortUrlModels[i]
+		return []any{i, shortUrl.Link, shortUrl.ExpTimeMinutes, shortUrl.ShortCode, shortUrl.OwnerId, normalize.Hash(shortUrl.Link), shortUrl.ApiKeyId, shortUrl.OrganizationId, shortUrl.DomainId, shortUrl.NamespaceId, visibilityOf(shortUrl)}, nil
+	}))
+	if err != nil {
+		log.Printf("[database:SaveShortUrls] Could not copy {%d} short urls: %v", len(shortUrlModels), err)
+		return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+	}
+
+	query := `INSERT INTO short_url (link, exp_time_minutes, short_code, owner_id, link_hash, api_key_id, organization_id, domain_id, namespace_id, visibility)
+		SELECT link, exp_time_minutes, short_code, owner_id, link_hash, api_key_id, organization_id, domain_id, namespace_id, visibility
+		FROM short_url_batch ORDER BY position
+		ON CONFLICT DO NOTHING
+		RETURNING id, link, exp_time_minutes, short_code, created_at, owner_id, api_key_id, organization_id, domain_id, namespace_id, visibility;`
+
+	rows, err := tx.Query(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:SaveShortUrls] Could not insert {%d} short urls: %v", len(shortUrlModels), err)
+		return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+	}
+
+	byCode := map[string]*ShortUrlModel{}
+	for rows.Next() {
+		inserted := &ShortUrlModel{}
+		if err := rows.Scan(&inserted.Id, &inserted.Link, &inserted.ExpTimeMinutes, &inserted.ShortCode, &inserted.CreatedAt, &inserted.OwnerId, &inserted.ApiKeyId, &inserted.OrganizationId, &inserted.DomainId, &inserted.NamespaceId, &inserted.Visibility); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+		}
+		byCode[inserted.ShortCode] = inserted
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+	}
+
+	// Links needing a review are disabled before the batch is committed, so none of them is ever live
+	for _, shortUrl := range shortUrlModels {
+		if entity, ok := byCode[shortUrl.ShortCode]; ok && shortUrl.Review != nil {
+			if err := reviewSaved(tx, entity, shortUrl.Review); err != nil {
+				return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+			}
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("save %d short urls: %w", len(shortUrlModels), err)
+	}
+
+	// RETURNING doesn't keep the order of the input
+	inserted := make([]*ShortUrlModel, 0, len(byCode))
+	for _, shortUrl := range shortUrlModels {
+		if entity, ok := byCode[shortUrl.ShortCode]; ok {
+			inserted = append(inserted, entity)
+			delete(byCode, shortUrl.ShortCode)
+		}
+	}
+
+	log.Printf("[database:SaveShortUrls] Inserted {%d} of {%d} short urls", len(inserted), len(shortUrlModels))
+
+	return inserted, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"url-shortner/migrations"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Arbitrary key of the advisory lock taken while bootstrapping, so instances starting together don't race
+const bootstrapLockKey = 727361
+
+// Same bookkeeping table as goose, so later migrations can be applied with the goose cli as usual
+const gooseVersionTableQuery = `CREATE TABLE IF NOT EXISTS goose_db_version (
+		id SERIAL PRIMARY KEY,
+		version_id BIGINT NOT NULL,
+		is_applied BOOLEAN NOT NULL,
+		tstamp TIMESTAMP DEFAULT NOW()
+	);
+	INSERT INTO goose_db_version (version_id, is_applied)
+		SELECT 0, true WHERE NOT EXISTS (SELECT 1 FROM goose_db_version);`
+
+func (s *service) BootstrapSchema() (int, error) {
+	all, err := migrations.All()
+	if err != nil {
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		log.Printf("[database:BootstrapSchema] Error starting transaction: %v", err)
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(context.Background(), "SELECT pg_advisory_xact_lock($1);", bootstrapLockKey); err != nil {
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRow(context.Background(), "SELECT to_regclass('short_url') IS NOT NULL;").Scan(&exists); err != nil {
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+	if exists {
+		log.Printf("[database:BootstrapSchema] Schema already present, leaving it to the migrations")
+		return 0, nil
+	}
+
+	if _, err := tx.Exec(context.Background(), gooseVersionTableQuery); err != nil {
+		log.Printf("[database:BootstrapSchema] Could not create goose_db_version: %v", err)
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+
+	applied, err := applyMigrations(tx, all, 0)
+	if err != nil {
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return 0, fmt.Errorf("bootstrap schema: %w", err)
+	}
+
+	log.Printf("[database:BootstrapSchema] Created the schema from {%d} migrations", applied)
+
+	return applied, nil
+}
+
+// applyMigrations runs the migrations newer than after within tx, recording each one in goose_db_version
+func applyMigrations(tx pgx.Tx, all []migrations.Migration, after int64) (int, error) {
+	applied := 0
+	for _, migration := range all {
+		if migration.Version <= after {
+			continue
+		}
+
+		if _, err := tx.Exec(context.Background(), migration.Up); err != nil {
+			log.Printf("[database:applyMigrations] Migration {%s} failed: %v", migration.Name, err)
+			return 0, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+
+		if _, err := tx.Exec(context.Background(), "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true);", migration.Version); err != nil {
+			return 0, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
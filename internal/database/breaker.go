@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"url-shortner/internal/breaker"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// guardedDB runs queries through a circuit breaker so callers fail fast with breaker.ErrOpen
+// while Postgres is unreachable, instead of queueing up on the pool. Errors showing the connections are gone are
+// passed to the monitor of the pool, which reconnects it
+type guardedDB struct {
+	db      dbtx
+	breaker *breaker.Breaker
+	monitor *poolMonitor
+}
+
+// isUnavailable tells connection level failures apart from errors Postgres answered with
+func isUnavailable(err error) bool {
+	if err == nil || errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection exception, 57P covers shutdowns and "cannot connect now"
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+
+	return true
+}
+
+func (g *guardedDB) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	tag, err := g.db.Exec(ctx, sql, arguments...)
+	g.breaker.Record(isUnavailable(err))
+	g.monitor.observe(err)
+	return tag, err
+}
+
+func (g *guardedDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	rows, err := g.db.Query(ctx, sql, args...)
+	g.breaker.Record(isUnavailable(err))
+	g.monitor.observe(err)
+	return rows, err
+}
+
+func (g *guardedDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if err := g.breaker.Allow(); err != nil {
+		return errRow{err: err}
+	}
+
+	return &guardedRow{row: g.db.QueryRow(ctx, sql, args...), breaker: g.breaker, monitor: g.monitor}
+}
+
+func (g *guardedDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	tx, err := g.db.Begin(ctx)
+	g.breaker.Record(isUnavailable(err))
+	g.monitor.observe(err)
+	return tx, err
+}
+
+func (g *guardedDB) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return 0, err
+	}
+
+	copied, err := g.db.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	g.breaker.Record(isUnavailable(err))
+	g.monitor.observe(err)
+	return copied, err
+}
+
+// guardedRow reports the outcome of a QueryRow once it is scanned, since pgx defers the error until then
+type guardedRow struct {
+	row     pgx.Row
+	breaker *breaker.Breaker
+	monitor *poolMonitor
+}
+
+func (r *guardedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	r.breaker.Record(isUnavailable(err))
+	r.monitor.observe(err)
+	return err
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...any) error {
+	return r.err
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (s *service) SaveLinkClaim(claimModel *LinkClaimModel) error {
+	query := `INSERT INTO link_claims (short_url_id, token_hash) VALUES ($1, $2);`
+
+	if _, err := s.db.Exec(context.Background(), query, claimModel.ShortUrlId, claimModel.TokenHash); err != nil {
+		log.Printf("[database:SaveLinkClaim] Error inserting claim of short url {%d}: %v", claimModel.ShortUrlId, err)
+		return fmt.Errorf("save claim of short url %d: %w", claimModel.ShortUrlId, err)
+	}
+
+	return nil
+}
+
+func (s *service) ClaimLink(tokenHash string, userId int) (*ShortUrlModel, error) {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("claim link: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	var shortUrlId int
+	query := `DELETE FROM link_claims WHERE token_hash = $1 RETURNING short_url_id;`
+	if err := tx.QueryRow(context.Background(), query, tokenHash).Scan(&shortUrlId); err != nil {
+		return nil, fmt.Errorf("claim link: %w", notFound(err))
+	}
+
+	// A link someone owns by now, e.g. through the admin api, stays theirs
+	query = `UPDATE short_url SET owner_id = $2 WHERE id = $1 AND owner_id IS NULL AND api_key_id IS NULL
+		RETURNING ` + shortUrlColumns + `;`
+	shortUrl, err := scanShortUrl(tx.QueryRow(context.Background(), query, shortUrlId, userId))
+	if err != nil {
+		return nil, fmt.Errorf("claim short url %d: %w", shortUrlId, notFound(err))
+	}
+
+	// The link is managed from the account now
+	if _, err := tx.Exec(context.Background(), "DELETE FROM link_edit_tokens WHERE short_url_id = $1;", shortUrlId); err != nil {
+		return nil, fmt.Errorf("claim short url %d: %w", shortUrlId, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("claim short url %d: %w", shortUrlId, err)
+	}
+
+	log.Printf("[database:ClaimLink] User {%d} claimed shortCode {%s}", userId, shortUrl.ShortCode)
+
+	return shortUrl, nil
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *service) RecordClick(clickEventModel *ClickEventModel) error {
+	query := "INSERT INTO click_events (short_url_id, ip, ip_hash, raw_ip, user_agent, referrer) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''));"
+
+	_, err := s.db.Exec(context.Background(), query, clickEventModel.ShortUrlId, clickEventModel.Ip, clickEventModel.IpHash, clickEventModel.RawIp, clickEventModel.UserAgent, clickEventModel.Referrer)
+	if err != nil {
+		log.Printf("[database:RecordClick] Could not record click for short url {%d}: %v", clickEventModel.ShortUrlId, err)
+		return fmt.Errorf("record click on short url %d: %w", clickEventModel.ShortUrlId, err)
+	}
+
+	return nil
+}
+
+// clickEventBatchColumns are copied into the click_events_batch staging table by RecordClicks, in this order
+var clickEventBatchColumns = []string{"short_url_id", "ip", "ip_hash", "raw_ip", "user_agent", "referrer", "clicked_at"}
+
+// RecordClicks copies the click events into a staging table, then moves them to click_events and adds them to the
+// click counters in the same transaction, so both stay in step. The clicks of links deleted since are left out
+func (s *service) RecordClicks(clickEventModels []*ClickEventModel) (int64, error) {
+	if len(clickEventModels) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("record %d clicks: %w", len(clickEventModels), err)
+	}
+	defer tx.Rollback(context.Background())
+
+	staging := `CREATE TEMP TABLE click_events_batch ON COMMIT DROP AS
+		SELECT short_url_id, ip, ip_hash, raw_ip, user_agent, referrer, clicked_at FROM click_events WITH NO DATA;`
+	if _, err := tx.Exec(context.Background(), staging); err != nil {
+		return 0, fmt.Errorf("record %d clicks: %w", len(clickEventModels), err)
+	}
+
+	now := time.Now()
+	_, err = tx.CopyFrom(context.Background(), pgx.Identifier{"click_events_batch"}, clickEventBatchColumns, pgx.CopyFromSlice(len(clickEventModels), func(i int) ([]any, error) {
+		click := clickEventModels[i]
+		clickedAt := click.ClickedAt
+		if clickedAt.IsZero() {
+			clickedAt = now
+		}
+		return []any{click.ShortUrlId, click.Ip, click.IpHash, click.RawIp, click.UserAgent, click.Referrer, clickedAt}, nil
+	}))
+	if err != nil {
+		log.Printf("[database:RecordClicks] Could not copy {%d} click events: %v", len(clickEventModels), err)
+		return 0, fmt.Errorf("record %d clicks: %w", len(clickEventModels), err)
+	}
+
+	query := `INSERT INTO click_events (short_url_id, ip, ip_hash, raw_ip, user_agent, referrer, clicked_at)
+		SELECT batch.short_url_id, NULLIF(batch.ip, ''), NULLIF(batch.ip_hash, ''), NULLIF(batch.raw_ip, ''), NULLIF(batch.user_agent, ''), NULLIF(batch.referrer, ''), batch.clicked_at
+		FROM click_events_batch batch JOIN short_url ON short_url.id = batch.short_url_id;`
+	result, err := tx.Exec(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:RecordClicks] Could not insert {%d} click events: %v", len(clickEventModels), err)
+		return 0, fmt.Errorf("record %d clicks: %w", len(clickEventModels), err)
+	}
+
+	counters := `INSERT INTO link_click_counters (short_url_id, shard, clicks)
+		SELECT batch.short_url_id, $1, COUNT(*) FROM click_events_batch batch JOIN short_url ON short_url.id = batch.short_url_id
+		GROUP BY batch.short_url_id
+		ON CONFLICT (short_url_id, shard) DO UPDATE SET clicks = link_click_counters.clicks + EXCLUDED.clicks, updated_at = NOW();`
+	if _, err := tx.Exec(context.Background(), counters, rand.Intn(clickCounterShards)); err != nil {
+		log.Printf("[database:RecordClicks] Could not count {%d} clicks: %v", len(clickEventModels), err)
+		return 0, fmt.Errorf("record %d clicks: %w", len(clickEventModels), err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return 0, fmt.Errorf("record %d clicks: %w", len(clickEventModels), err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (s *service) PurgeRawIps(retention time.Duration) (int64, error) {
+	log.Printf("[database:PurgeRawIps] Clearing raw ips older than %s", retention)
+
+	affected, err := s.destroy("PurgeRawIps", "UPDATE click_events SET raw_ip = NULL WHERE raw_ip IS NOT NULL AND clicked_at < $1", "id", time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("[database:PurgeRawIps] something went wrong: %v", err)
+		return 0, fmt.Errorf("purge raw ips: %w", err)
+	}
+
+	log.Printf("[database:PurgeRawIps] Cleared {%d} raw ips", affected)
+
+	return affected, nil
+}
+
+// PurgeClickEvents deletes the click events older than before of the links in scope
+func (s *service) PurgeClickEvents(scope AnalyticsScope, before time.Time) (int64, error) {
+	log.Printf("[database:PurgeClickEvents] Deleting click events of {%s} links before %s", scope, before.Format(time.RFC3339))
+
+	condition, args := scope.condition("click_retention_days", before)
+	query := `DELETE FROM click_events AS ce
+		USING short_url AS su
+		LEFT JOIN organizations AS o ON o.id = su.organization_id
+		LEFT JOIN users AS u ON u.id = su.owner_id
+		WHERE ce.short_url_id = su.id AND ce.clicked_at < $1 AND ` + condition
+
+	affected, err := s.destroy("PurgeClickEvents", query, "ce.id", args...)
+	if err != nil {
+		log.Printf("[database:PurgeClickEvents] something went wrong: %v", err)
+		return 0, fmt.Errorf("purge click events of %s: %w", scope, err)
+	}
+
+	log.Printf("[database:PurgeClickEvents] Deleted {%d} click events", affected)
+
+	return affected, nil
+}
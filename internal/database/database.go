@@ -2,44 +2,119 @@ package database
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"url-shortner/internal/breaker"
+	"url-shortner/internal/normalize"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 // Service represents a service that interacts with a database.
 type Service interface {
-	// Health returns a map of health status information.
-	// The keys and values in the map are service-specific.
-	Health() map[string]string
+	// Health reports whether the database is up, degraded or down, with details per connection pool.
+	// It never terminates the process
+	Health() *HealthModel
+
+	// Version of the newest goose migration applied to the database
+	SchemaVersion() (int64, error)
+
+	// Create the schema from the bundled migrations when the database is empty, recording them as applied
+	// for goose. It returns how many migrations were run, zero when the schema already exists
+	BootstrapSchema() (int, error)
 
 	// Close terminates the database connection.
 	// It returns an error if the connection cannot be closed.
 	Close() error
 
-	// Insert into database
-	SaveShortUrl(*ShortUrlModel) (*ShortUrlModel, error)
-
-	// Get the Shortned URL entity
-	GetShortUrl(shortCode string) (*ShortUrlModel, error)
+	// Call changed with the short code of each link of the schema inserted, updated or deleted, by any replica,
+	// until ctx is done. missed is called when changes may have gone unnoticed, e.g. after the connection was lost.
+	// Every service of the process shares a single listening connection
+	WatchLinkChanges(ctx context.Context, changed func(shortCode string), missed func())
+
+	// Take the advisory lock named key in the schema, on a connection held until the lock is released, so instances
+	// doing the same work don't overlap. It fails with ErrLocked at once when another session holds it
+	TryAcquireLock(ctx context.Context, key string) (*Lock, error)
+
+	// A Service on the same connections whose deletes and purges only log how many rows they would change, with a
+	// sample of them, and change nothing. Their counts are returned as usual
+	DryRun() Service
+
+	// The storage of each entity, see repositories.go
+	LinkRepository
+	ClickRepository
+	UserRepository
+	PasswordRepository
+	VerificationRepository
+	SessionRepository
+	ClaimRepository
+	EditTokenRepository
+	DigestRepository
+	NotificationRepository
+	PrivacyRepository
+	BlocklistRepository
+	ModerationRepository
+	OrganizationRepository
+	AuditRepository
+	DomainRepository
+	NamespaceRepository
+	JobRepository
+	MaintenanceRepository
+	WarehouseRepository
+	UpkeepRepository
+
+	// Run fn against a Service bound to a single transaction. The transaction is committed when fn
+	// returns nil and rolled back otherwise. Transactions started inside fn become savepoints
+	RunInTransaction(fn func(Service) error) error
+}
 
-	// Update the shortned URL times_cliecked attribute
-	UpdateTimesClicked(shortCode string) error
+// Number of counter rows a link's clicks are spread over
+const clickCounterShards = 8
+
+// Queries run on every redirect or shortening. Their text never changes, so each pooled connection
+// prepares them once through the pgx statement cache and reuses the plan afterwards
+const (
+	saveShortUrlQuery       = "INSERT INTO short_url (link, exp_time_minutes, short_code, owner_id, link_hash, api_key_id, organization_id, domain_id, namespace_id, visibility) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, link, exp_time_minutes, short_code, created_at, owner_id, api_key_id, organization_id, domain_id, namespace_id, visibility;"
+	getShortUrlQuery        = "SELECT " + shortUrlColumns + " FROM short_url WHERE short_code=$1;"
+	updateTimesClickedQuery = `INSERT INTO link_click_counters (short_url_id, shard, clicks)
+		SELECT id, $2, 1 FROM short_url WHERE short_code = $1
+		ON CONFLICT (short_url_id, shard) DO UPDATE SET clicks = link_click_counters.clicks + 1, updated_at = NOW();`
+)
 
-	// Delete expired links
-	DeleteExpiredLinks() error
+// dbtx is implemented by both *pgxpool.Pool and pgx.Tx, so queries run the same way in and out of a transaction
+type dbtx interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 }
 
 type service struct {
-	db *sql.DB
+	pool    *pgxpool.Pool
+	db      dbtx
+	breaker *breaker.Breaker
+	monitor *poolMonitor
+
+	// read serves the lookups and stats queries. It is the read replica when one is configured, db otherwise
+	read           dbtx
+	replicaPool    *pgxpool.Pool
+	replicaBreaker *breaker.Breaker
+	replicaMonitor *poolMonitor
+
+	// Destructive statements are rolled back and only logged, see destroy
+	dryRun bool
 }
 
 var (
@@ -50,73 +125,318 @@ var (
 	host       = os.Getenv("BLUEPRINT_DB_HOST")
 	schema     = os.Getenv("BLUEPRINT_DB_SCHEMA")
 	dbInstance *service
+
+	// Pool tuning, pgxpool defaults are used when unset
+	minConns          = os.Getenv("BLUEPRINT_DB_MIN_CONNS")
+	maxConns          = os.Getenv("BLUEPRINT_DB_MAX_CONNS")
+	maxConnLifetime   = os.Getenv("BLUEPRINT_DB_MAX_CONN_LIFETIME")
+	maxConnIdleTime   = os.Getenv("BLUEPRINT_DB_MAX_CONN_IDLE_TIME")
+	healthCheckPeriod = os.Getenv("BLUEPRINT_DB_HEALTH_CHECK_PERIOD")
+	statementCache    = os.Getenv("BLUEPRINT_DB_STATEMENT_CACHE_CAPACITY")
+
+	// How often the pools are pinged, and reset when the ping fails, see poolMonitor
+	monitorInterval = os.Getenv("BLUEPRINT_DB_MONITOR_INTERVAL")
+
+	// Circuit breaker around every query, see guardedDB
+	breakerThreshold = os.Getenv("BLUEPRINT_DB_BREAKER_THRESHOLD")
+	breakerCooldown  = os.Getenv("BLUEPRINT_DB_BREAKER_COOLDOWN")
+
+	// Optional read only DSN used for lookups, see readDB
+	replicaUrl = os.Getenv("BLUEPRINT_DB_REPLICA_URL")
+
+	// Whether the api may create the schema of an empty database on startup, see BootstrapSchema
+	Bootstrap = os.Getenv("BLUEPRINT_DB_BOOTSTRAP") == "true"
 )
 
+// CheckConfig reports the connection settings that are required but missing
+func CheckConfig() error {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"BLUEPRINT_DB_HOST", host},
+		{"BLUEPRINT_DB_PORT", port},
+		{"BLUEPRINT_DB_DATABASE", database},
+		{"BLUEPRINT_DB_USERNAME", username},
+	}
+
+	var missing []string
+	for _, r := range required {
+		if r.value == "" {
+			missing = append(missing, r.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
 func New() Service {
 	// Reuse Connection
 	if dbInstance != nil {
 		return dbInstance
 	}
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s", username, password, host, port, database, schema)
-	db, err := sql.Open("pgx", connStr)
+	instance, err := connect("primary", connString(schema))
 	if err != nil {
 		log.Fatal(err)
 	}
-	dbInstance = &service{
-		db: db,
+	dbInstance = instance
+
+	if replicaUrl != "" {
+		replicaPool, replicaBreaker, replicaMonitor, err := newReplica()
+		if err != nil {
+			log.Fatal(err)
+		}
+		dbInstance.replicaPool = replicaPool
+		dbInstance.replicaBreaker = replicaBreaker
+		dbInstance.replicaMonitor = replicaMonitor
+		dbInstance.read = &readDB{
+			replica: &guardedDB{db: replicaPool, breaker: replicaBreaker, monitor: replicaMonitor},
+			primary: dbInstance.db,
+		}
 	}
+
 	return dbInstance
 }
 
-// Health checks the health of the database connection by pinging the database.
-// It returns a map with keys indicating various health statistics.
-func (s *service) Health() map[string]string {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+// connString is the DSN of the configured database with schemaName as search_path
+func connString(schemaName string) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s", username, password, host, port, database, schemaName)
+}
 
-	stats := make(map[string]string)
+// Connect returns a service on its own pool for connStr, independent from the one shared through New.
+// It is meant for tools and tests talking to a database other than the configured one
+func Connect(connStr string) (Service, error) {
+	return connect("primary", connStr)
+}
 
-	// Ping the database
-	err := s.db.PingContext(ctx)
+// connect opens a pool for connStr, monitored under name
+func connect(name string, connStr string) (*service, error) {
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := configurePool(config); err != nil {
+		return nil, err
+	}
+	interval, err := poolMonitorInterval()
+	if err != nil {
+		return nil, err
+	}
+	monitor := monitorPool(name, config)
+	db, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	dbBreaker, err := newBreaker()
 	if err != nil {
-		stats["status"] = "down"
-		stats["error"] = fmt.Sprintf("db down: %v", err)
-		log.Fatalf("db down: %v", err) // Log the error and terminate the program
-		return stats
-	}
-
-	// Database is up, add more statistics
-	stats["status"] = "up"
-	stats["message"] = "It's healthy"
-
-	// Get database stats (like open connections, in use, idle, etc.)
-	dbStats := s.db.Stats()
-	stats["open_connections"] = strconv.Itoa(dbStats.OpenConnections)
-	stats["in_use"] = strconv.Itoa(dbStats.InUse)
-	stats["idle"] = strconv.Itoa(dbStats.Idle)
-	stats["wait_count"] = strconv.FormatInt(dbStats.WaitCount, 10)
-	stats["wait_duration"] = dbStats.WaitDuration.String()
-	stats["max_idle_closed"] = strconv.FormatInt(dbStats.MaxIdleClosed, 10)
-	stats["max_lifetime_closed"] = strconv.FormatInt(dbStats.MaxLifetimeClosed, 10)
+		db.Close()
+		return nil, err
+	}
+	monitor.start(db, interval)
+
+	instance := &service{
+		pool:    db,
+		db:      &guardedDB{db: db, breaker: dbBreaker, monitor: monitor},
+		breaker: dbBreaker,
+		monitor: monitor,
+	}
+	instance.read = instance.db
+
+	return instance, nil
+}
+
+// configurePool applies the BLUEPRINT_DB_* pool settings that are set
+func configurePool(config *pgxpool.Config) error {
+	if minConns != "" {
+		n, err := strconv.ParseInt(minConns, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid BLUEPRINT_DB_MIN_CONNS %q: %w", minConns, err)
+		}
+		config.MinConns = int32(n)
+	}
+
+	if maxConns != "" {
+		n, err := strconv.ParseInt(maxConns, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid BLUEPRINT_DB_MAX_CONNS %q: %w", maxConns, err)
+		}
+		config.MaxConns = int32(n)
+	}
+
+	// Hot queries rely on the per connection statement cache instead of being parsed on every call
+	config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	if statementCache != "" {
+		n, err := strconv.Atoi(statementCache)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid BLUEPRINT_DB_STATEMENT_CACHE_CAPACITY %q: expected a positive number", statementCache)
+		}
+		config.ConnConfig.StatementCacheCapacity = n
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"BLUEPRINT_DB_MAX_CONN_LIFETIME", maxConnLifetime, &config.MaxConnLifetime},
+		{"BLUEPRINT_DB_MAX_CONN_IDLE_TIME", maxConnIdleTime, &config.MaxConnIdleTime},
+		{"BLUEPRINT_DB_HEALTH_CHECK_PERIOD", healthCheckPeriod, &config.HealthCheckPeriod},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", d.name, d.value, err)
+		}
+		*d.dest = parsed
+	}
+
+	return nil
+}
+
+// poolMonitorInterval is BLUEPRINT_DB_MONITOR_INTERVAL, 15 seconds when unset
+func poolMonitorInterval() (time.Duration, error) {
+	if monitorInterval == "" {
+		return 15 * time.Second, nil
+	}
+	d, err := time.ParseDuration(monitorInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid BLUEPRINT_DB_MONITOR_INTERVAL %q: expected a positive duration", monitorInterval)
+	}
+	return d, nil
+}
+
+// newBreaker builds the database circuit breaker: 5 consecutive failures open it for 10 seconds unless configured otherwise
+func newBreaker() (*breaker.Breaker, error) {
+	threshold := 5
+	if breakerThreshold != "" {
+		n, err := strconv.Atoi(breakerThreshold)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid BLUEPRINT_DB_BREAKER_THRESHOLD %q: expected a positive number", breakerThreshold)
+		}
+		threshold = n
+	}
+
+	cooldown := 10 * time.Second
+	if breakerCooldown != "" {
+		d, err := time.ParseDuration(breakerCooldown)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BLUEPRINT_DB_BREAKER_COOLDOWN %q: %w", breakerCooldown, err)
+		}
+		cooldown = d
+	}
+
+	return breaker.New(threshold, cooldown), nil
+}
+
+// Health pings the primary and, when configured, the replica. It never fails: an unreachable
+// primary is reported as down, an unreachable replica or a saturated pool as degraded.
+func (s *service) Health() *HealthModel {
+	health := &HealthModel{
+		Status:     HealthUp,
+		Message:    "It's healthy",
+		Components: map[string]*ComponentHealthModel{},
+	}
+
+	primary := pingComponent(s.pool, s.breaker)
+	health.Components["primary"] = primary
+
+	// Get pool stats (like open connections, in use, idle, etc.)
+	poolStats := s.pool.Stat()
+	primary.Details["open_connections"] = strconv.Itoa(int(poolStats.TotalConns()))
+	primary.Details["in_use"] = strconv.Itoa(int(poolStats.AcquiredConns()))
+	primary.Details["idle"] = strconv.Itoa(int(poolStats.IdleConns()))
+	primary.Details["max_connections"] = strconv.Itoa(int(poolStats.MaxConns()))
+	primary.Details["wait_count"] = strconv.FormatInt(poolStats.EmptyAcquireCount(), 10)
+	primary.Details["wait_duration"] = poolStats.AcquireDuration().String()
+	primary.Details["max_idle_closed"] = strconv.FormatInt(poolStats.MaxIdleDestroyCount(), 10)
+	primary.Details["max_lifetime_closed"] = strconv.FormatInt(poolStats.MaxLifetimeDestroyCount(), 10)
+	if s.monitor != nil {
+		primary.Details["reconnects"] = strconv.FormatInt(s.monitor.reconnects.Load(), 10)
+	}
+
+	if primary.Status == HealthDown {
+		health.Status = HealthDown
+		health.Message = "The primary database is unreachable."
+		return health
+	}
 
 	// Evaluate stats to provide a health message
-	if dbStats.OpenConnections > 40 { // Assuming 50 is the max for this example
-		stats["message"] = "The database is experiencing heavy load."
+	if poolStats.EmptyAcquireCount() > 1000 {
+		health.Message = "The database has a high number of wait events, indicating potential bottlenecks."
+	}
+
+	if poolStats.MaxIdleDestroyCount() > int64(poolStats.TotalConns())/2 {
+		health.Message = "Many idle connections are being closed, consider revising the connection pool settings."
+	}
+
+	if poolStats.MaxLifetimeDestroyCount() > int64(poolStats.TotalConns())/2 {
+		health.Message = "Many connections are being closed due to max lifetime, consider increasing max lifetime or revising the connection usage pattern."
+	}
+
+	if poolStats.AcquiredConns() >= poolStats.MaxConns() {
+		primary.Status = HealthDegraded
+		health.Status = HealthDegraded
+		health.Message = "The database is experiencing heavy load."
+	}
+
+	if s.replicaPool != nil {
+		replica := pingComponent(s.replicaPool, s.replicaBreaker)
+		health.Components["replica"] = replica
+
+		if replica.Status != HealthUp {
+			health.Status = HealthDegraded
+			health.Message = "The read replica is unreachable, lookups are served by the primary."
+		}
+	}
+
+	return health
+}
+
+func (s *service) SchemaVersion() (int64, error) {
+	// The newest record of a version tells whether it is currently applied
+	query := `SELECT COALESCE(MAX(version_id), 0) FROM (
+			SELECT DISTINCT ON (version_id) version_id, is_applied FROM goose_db_version ORDER BY version_id, id DESC
+		) AS latest
+		WHERE is_applied;`
+
+	var version int64
+	if err := s.db.QueryRow(context.Background(), query).Scan(&version); err != nil {
+		log.Printf("[database:SchemaVersion] Could not read the schema version: %v", err)
+		return 0, fmt.Errorf("read schema version: %w", err)
 	}
 
-	if dbStats.WaitCount > 1000 {
-		stats["message"] = "The database has a high number of wait events, indicating potential bottlenecks."
+	return version, nil
+}
+
+// pingComponent reports a pool as down when its ping fails, and as degraded while its breaker is still recovering
+func pingComponent(pool *pgxpool.Pool, b *breaker.Breaker) *ComponentHealthModel {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	component := &ComponentHealthModel{
+		Status:  HealthUp,
+		Details: map[string]string{"breaker": b.State().String()},
 	}
 
-	if dbStats.MaxIdleClosed > int64(dbStats.OpenConnections)/2 {
-		stats["message"] = "Many idle connections are being closed, consider revising the connection pool settings."
+	if err := pool.Ping(ctx); err != nil {
+		log.Printf("[database:Health] Ping failed: %v", err)
+		component.Status = HealthDown
+		component.Error = fmt.Sprintf("db down: %v", err)
+		return component
 	}
 
-	if dbStats.MaxLifetimeClosed > int64(dbStats.OpenConnections)/2 {
-		stats["message"] = "Many connections are being closed due to max lifetime, consider increasing max lifetime or revising the connection usage pattern."
+	if b.State() != breaker.Closed {
+		component.Status = HealthDegraded
 	}
 
-	return stats
+	return component
 }
 
 // Close closes the database connection.
@@ -125,23 +445,47 @@ func (s *service) Health() map[string]string {
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
 	log.Printf("Disconnected from database: %s", database)
-	return s.db.Close()
+	s.monitor.close()
+	s.pool.Close()
+	if s.replicaPool != nil {
+		s.replicaMonitor.close()
+		s.replicaPool.Close()
+	}
+	return nil
+}
+
+func (s *service) RunInTransaction(fn func(Service) error) error {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		log.Printf("[database:RunInTransaction] Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := fn(&service{pool: s.pool, db: tx, breaker: s.breaker, read: tx, dryRun: s.dryRun}); err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
 }
 
 func (s *service) SaveShortUrl(shortUrlModel *ShortUrlModel) (*ShortUrlModel, error) {
-	query := "INSERT INTO short_url (link, times_clicked, exp_time_minutes, short_code) VALUES ($1, 0, $2, $3) RETURNING id, link, times_clicked, exp_time_minutes, short_code;"
+	inserted, err := s.reviewed(shortUrlModel.Review, func(q dbtx) (*ShortUrlModel, error) {
+		inserted := &ShortUrlModel{}
+		err := q.QueryRow(context.Background(), saveShortUrlQuery, shortUrlModel.Link, shortUrlModel.ExpTimeMinutes, shortUrlModel.ShortCode, shortUrlModel.OwnerId, normalize.Hash(shortUrlModel.Link), shortUrlModel.ApiKeyId, shortUrlModel.OrganizationId, shortUrlModel.DomainId, shortUrlModel.NamespaceId, visibilityOf(shortUrlModel)).Scan(&inserted.Id, &inserted.Link, &inserted.ExpTimeMinutes, &inserted.ShortCode, &inserted.CreatedAt, &inserted.OwnerId, &inserted.ApiKeyId, &inserted.OrganizationId, &inserted.DomainId, &inserted.NamespaceId, &inserted.Visibility)
 
-	inserted := &ShortUrlModel{}
-	err := s.db.QueryRow(query, shortUrlModel.Link, shortUrlModel.ExpTimeMinutes, shortUrlModel.ShortCode).Scan(&inserted.Id, &inserted.Link, &inserted.TimesClicked, &inserted.ExpTimeMinutes, &inserted.ShortCode)
+		if err != nil {
+			if err := unique(err); errors.Is(err, ErrConflict) {
+				return nil, fmt.Errorf("save short url %s: %w", shortUrlModel.ShortCode, err)
+			}
 
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			fmt.Println(pgErr.Message) // => syntax error at end of input
-			fmt.Println(pgErr.Code)    // => 42601
 			log.Printf("[database:SaveShortUrl] Error inserting short_url: %v", err)
-			return nil, err
+			return nil, fmt.Errorf("save short url %s: %w", shortUrlModel.ShortCode, err)
 		}
+		return inserted, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	log.Printf("[database:SaveShortUrl] Inserted: %+v", inserted)
@@ -149,47 +493,119 @@ func (s *service) SaveShortUrl(shortUrlModel *ShortUrlModel) (*ShortUrlModel, er
 	return inserted, nil
 }
 
-func (s *service) GetShortUrl(shortCode string) (*ShortUrlModel, error) {
-	log.Printf("[database:GetShortUrl] Querying for shortCode: {%s}", shortCode)
+// upsertShortUrlQuery only updates a link of the same owner, or of the same organization for shared links, and
+// never a disabled one. The expiry of an updated link counts from the update, so created_at is reset along with
+// exp_time_minutes. xmax is zero for the rows the statement inserted
+const upsertShortUrlQuery = `INSERT INTO short_url (link, exp_time_minutes, short_code, owner_id, link_hash, api_key_id, organization_id, domain_id, namespace_id, visibility)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (short_code) DO UPDATE SET link = EXCLUDED.link, link_hash = EXCLUDED.link_hash, exp_time_minutes = EXCLUDED.exp_time_minutes, visibility = EXCLUDED.visibility, created_at = NOW()
+	WHERE short_url.disabled_at IS NULL
+		AND short_url.organization_id IS NOT DISTINCT FROM EXCLUDED.organization_id
+		AND (short_url.organization_id IS NOT NULL OR short_url.owner_id = EXCLUDED.owner_id)
+	RETURNING id, link, exp_time_minutes, short_code, created_at, owner_id, api_key_id, organization_id, domain_id, namespace_id, visibility, xmax = 0;`
+
+func (s *service) UpsertShortUrl(shortUrlModel *ShortUrlModel) (*ShortUrlModel, bool, error) {
+	var created bool
+	upserted, err := s.reviewed(shortUrlModel.Review, func(q dbtx) (*ShortUrlModel, error) {
+		upserted := &ShortUrlModel{}
+		err := q.QueryRow(context.Background(), upsertShortUrlQuery, shortUrlModel.Link, shortUrlModel.ExpTimeMinutes, shortUrlModel.ShortCode, shortUrlModel.OwnerId, normalize.Hash(shortUrlModel.Link), shortUrlModel.ApiKeyId, shortUrlModel.OrganizationId, shortUrlModel.DomainId, shortUrlModel.NamespaceId, visibilityOf(shortUrlModel)).Scan(&upserted.Id, &upserted.Link, &upserted.ExpTimeMinutes, &upserted.ShortCode, &upserted.CreatedAt, &upserted.OwnerId, &upserted.ApiKeyId, &upserted.OrganizationId, &upserted.DomainId, &upserted.NamespaceId, &upserted.Visibility, &created)
+
+		// The code exists but the update was refused by the WHERE clause, either because the link is of someone else
+		// or because it is disabled
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("upsert short url %s: %w", shortUrlModel.ShortCode, s.refusedUpsert(shortUrlModel))
+		}
+		if err != nil {
+			log.Printf("[database:UpsertShortUrl] Error upserting short_url: %v", err)
+			return nil, fmt.Errorf("upsert short url %s: %w", shortUrlModel.ShortCode, unique(err))
+		}
+		return upserted, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	log.Printf("[database:UpsertShortUrl] Upserted (created: %t): %+v", created, upserted)
+
+	return upserted, created, nil
+}
+
+// refusedUpsert tells why the link with the code of shortUrlModel could not be updated: ErrDisabled when it is a
+// disabled link of the caller, e.g. one under review, ErrDuplicateCode otherwise
+func (s *service) refusedUpsert(shortUrlModel *ShortUrlModel) error {
+	query := `SELECT disabled_at IS NOT NULL FROM short_url
+		WHERE short_code = $1 AND organization_id IS NOT DISTINCT FROM $2 AND (organization_id IS NOT NULL OR owner_id = $3);`
 
-	query := "SELECT link, times_clicked, exp_time_minutes, short_code, created_at FROM short_url WHERE short_code=$1;"
+	var disabled bool
+	err := s.db.QueryRow(context.Background(), query, shortUrlModel.ShortCode, shortUrlModel.OrganizationId, shortUrlModel.OwnerId).Scan(&disabled)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[database:UpsertShortUrl] Could not tell why short_code {%s} was not updated: %v", shortUrlModel.ShortCode, err)
+	}
+	if disabled {
+		return ErrDisabled
+	}
+	return &ConflictError{Field: "short_code", err: ErrDuplicateCode}
+}
 
-	searched := &ShortUrlModel{}
-	err := s.db.QueryRow(query, shortCode).Scan(&searched.Link, &searched.TimesClicked, &searched.ExpTimeMinutes, &searched.ShortCode, &searched.CreatedAt)
+func (s *service) TakenShortCodes(shortCodes []string) ([]string, error) {
+	rows, err := s.read.Query(context.Background(), "SELECT short_code FROM short_url WHERE short_code = ANY($1);", shortCodes)
+	if err != nil {
+		log.Printf("[database:TakenShortCodes] Something went wrong: %v", err)
+		return nil, fmt.Errorf("taken short codes: %w", err)
+	}
 
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			fmt.Println(pgErr.Message) // => syntax error at end of input
-			fmt.Println(pgErr.Code)    // => 42601
-			log.Printf("[database:GetShortUrl] Something went wrong: %v", err)
-			return nil, err
-		}
+		return nil, fmt.Errorf("taken short codes: %w", err)
+	}
+
+	return taken, nil
+}
 
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("[database:GetShortUrl] Query returned no rows: %+v", err)
-			return nil, err
+func (s *service) ListShortCodes(since time.Time) ([]string, error) {
+	rows, err := s.read.Query(context.Background(), "SELECT short_code FROM short_url WHERE created_at >= $1;", since)
+	if err != nil {
+		log.Printf("[database:ListShortCodes] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list short codes: %w", err)
+	}
+
+	codes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("list short codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (s *service) GetShortUrl(shortCode string) (*ShortUrlModel, error) {
+	// Every redirect that misses the cache comes through here, the chatter is only logged when debugging
+	slog.Debug("Querying for a short url", "short_code", shortCode)
+
+	searched, err := scanShortUrl(s.read.QueryRow(context.Background(), getShortUrlQuery, shortCode))
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			slog.Debug("No short url found", "short_code", shortCode)
+			return nil, fmt.Errorf("get short url %s: %w", shortCode, ErrNotFound)
 		}
 
+		slog.Error("Could not query for a short url", "short_code", shortCode, "error", err)
+		return nil, fmt.Errorf("get short url %s: %w", shortCode, err)
 	}
 
-	log.Printf("[database:GetShortUrl] Found a url: %+v", searched)
+	slog.Debug("Found a short url", "short_code", shortCode, "link", searched.Link)
 
 	return searched, nil
 }
 
 func (s *service) UpdateTimesClicked(shortCode string) error {
-	log.Printf("[database:UpdateTimesClicked] Updating times_clicked for shortCode: {%s}", shortCode)
-
-	query := "UPDATE short_url SET times_clicked = times_clicked + 1 WHERE short_code = $1;"
-
-	_, err := s.db.Exec(query, shortCode)
+	_, err := s.db.Exec(context.Background(), updateTimesClickedQuery, shortCode, rand.Intn(clickCounterShards))
 
 	if err != nil {
-		log.Printf("[database:UpdateTimesClicked] something went wrong while updating for shortCode {%s}: %v", shortCode, err)
-		return err
+		slog.Error("Could not count a click", "short_code", shortCode, "error", err)
+		return fmt.Errorf("count click on short url %s: %w", shortCode, err)
 	}
-	log.Printf("[database:UpdateTimesClicked] Times_clicked updated for shortCode: {%s}", shortCode)
+	slog.Debug("Counted a click", "short_code", shortCode)
 
 	return nil
 }
@@ -197,15 +613,15 @@ func (s *service) UpdateTimesClicked(shortCode string) error {
 func (s *service) DeleteExpiredLinks() error {
 	log.Printf("[database:DeleteExpiredLinks] Deleting expired links")
 
-	query := "DELETE FROM short_url WHERE NOW() >= created_at + (exp_time_minutes || ' minutes')::interval;"
+	query := "DELETE FROM short_url WHERE NOW() >= created_at + (exp_time_minutes || ' minutes')::interval"
 
-	_, err := s.db.Exec(query)
+	deleted, err := s.destroy("DeleteExpiredLinks", query, "short_code")
 
 	if err != nil {
 		log.Printf("[database:DeleteExpiredLinks] something went wrong: %v", err)
-		return err
+		return fmt.Errorf("delete expired links: %w", err)
 	}
-	log.Printf("[database:DeleteExpiredLinks] Expired links deleted")
+	log.Printf("[database:DeleteExpiredLinks] Deleted {%d} expired links", deleted)
 
 	return nil
-}
\ No newline at end of file
+}
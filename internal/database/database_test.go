@@ -2,10 +2,16 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -76,18 +82,18 @@ func TestNew(t *testing.T) {
 func TestHealth(t *testing.T) {
 	srv := New()
 
-	stats := srv.Health()
+	health := srv.Health()
 
-	if stats["status"] != "up" {
-		t.Fatalf("expected status to be up, got %s", stats["status"])
+	if health.Status != HealthUp {
+		t.Fatalf("expected status to be up, got %s", health.Status)
 	}
 
-	if _, ok := stats["error"]; ok {
-		t.Fatalf("expected error not to be present")
+	if primary := health.Components["primary"]; primary == nil || primary.Error != "" {
+		t.Fatalf("expected the primary to be reported without error, got %+v", primary)
 	}
 
-	if stats["message"] != "It's healthy" {
-		t.Fatalf("expected message to be 'It's healthy', got %s", stats["message"])
+	if health.Message != "It's healthy" {
+		t.Fatalf("expected message to be 'It's healthy', got %s", health.Message)
 	}
 }
 
@@ -98,3 +104,67 @@ func TestClose(t *testing.T) {
 		t.Fatalf("expected Close() to return nil")
 	}
 }
+
+func TestRunInTransaction(t *testing.T) {
+	srv := New()
+
+	if err := srv.RunInTransaction(func(tx Service) error { return nil }); err != nil {
+		t.Fatalf("expected the transaction to commit, got %v", err)
+	}
+
+	rollback := errors.New("rollback")
+	if err := srv.RunInTransaction(func(tx Service) error { return rollback }); !errors.Is(err, rollback) {
+		t.Fatalf("expected the error of fn to be returned, got %v", err)
+	}
+}
+
+func TestTryAcquireLock(t *testing.T) {
+	srv := New()
+
+	lock, err := srv.TryAcquireLock(context.Background(), "cron:delete_expired_links")
+	if err != nil {
+		t.Fatalf("expected the lock acquired, got %v", err)
+	}
+
+	if _, err := srv.TryAcquireLock(context.Background(), "cron:delete_expired_links"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected the held lock refused, got %v", err)
+	}
+	other, err := srv.TryAcquireLock(context.Background(), "cron:purge_raw_ips")
+	if err != nil {
+		t.Fatalf("expected another key acquired, got %v", err)
+	}
+	other.Release()
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("expected the lock released, got %v", err)
+	}
+	lock, err = srv.TryAcquireLock(context.Background(), "cron:delete_expired_links")
+	if err != nil {
+		t.Fatalf("expected the released lock acquired again, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestNeedsReconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"no error", nil, false},
+		{"no rows", pgx.ErrNoRows, false},
+		{"canceled", context.Canceled, false},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"demoted primary", &pgconn.PgError{Code: "25006"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"connection reset", fmt.Errorf("read: %w", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}), true},
+		{"closed by the server", io.ErrUnexpectedEOF, true},
+	}
+
+	for _, tt := range tests {
+		if got := needsReconnect(tt.err); got != tt.expected {
+			t.Errorf("%s: expected %t; got %t", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (s *service) GetEmailPreferences(userId int) (*EmailPreferencesModel, error) {
+	preferences := &EmailPreferencesModel{}
+	err := s.db.QueryRow(context.Background(), "SELECT id, weekly_digest FROM users WHERE id = $1;", userId).Scan(&preferences.UserId, &preferences.WeeklyDigest)
+	if err != nil {
+		return nil, fmt.Errorf("get email preferences of user %d: %w", userId, notFound(err))
+	}
+
+	return preferences, nil
+}
+
+func (s *service) UpdateEmailPreferences(preferencesModel *EmailPreferencesModel) (*EmailPreferencesModel, error) {
+	log.Printf("[database:UpdateEmailPreferences] Setting the weekly digest of user {%d} to {%t}", preferencesModel.UserId, preferencesModel.WeeklyDigest)
+
+	query := "UPDATE users SET weekly_digest = $2 WHERE id = $1 RETURNING id, weekly_digest;"
+
+	preferences := &EmailPreferencesModel{}
+	err := s.db.QueryRow(context.Background(), query, preferencesModel.UserId, preferencesModel.WeeklyDigest).Scan(&preferences.UserId, &preferences.WeeklyDigest)
+	if err != nil {
+		return nil, fmt.Errorf("update email preferences of user %d: %w", preferencesModel.UserId, notFound(err))
+	}
+
+	return preferences, nil
+}
+
+func (s *service) ListDigestRecipients(sentBefore time.Time, afterId int, limit int) ([]*UserModel, error) {
+	query := `SELECT id, email, role, plan, created_at FROM users
+		WHERE weekly_digest AND (digest_sent_at IS NULL OR digest_sent_at < $1) AND id > $2
+		ORDER BY id LIMIT $3;`
+
+	rows, err := s.db.Query(context.Background(), query, sentBefore, afterId, limit)
+	if err != nil {
+		log.Printf("[database:ListDigestRecipients] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list digest recipients: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*UserModel{}
+	for rows.Next() {
+		user := &UserModel{}
+		if err := rows.Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("list digest recipients: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// GetLinkDigest counts the clicks from the daily rollups, so since and until are expected to be midnights UTC. The
+// referrers are read from the click events, those already purged are left out
+func (s *service) GetLinkDigest(userId int, since time.Time, until time.Time, top int) (*LinkDigestModel, error) {
+	digest := &LinkDigestModel{Since: since, Until: until}
+
+	query := `SELECT
+		(SELECT COUNT(*) FROM short_url WHERE owner_id = $1),
+		(SELECT COALESCE(SUM(r.clicks), 0)::bigint FROM click_daily_rollups r JOIN short_url su ON su.id = r.short_url_id
+			WHERE su.owner_id = $1 AND r.day >= $2 AND r.day < $3);`
+	if err := s.read.QueryRow(context.Background(), query, userId, since, until).Scan(&digest.Links, &digest.Clicks); err != nil {
+		log.Printf("[database:GetLinkDigest] Could not count the clicks: %v", err)
+		return nil, fmt.Errorf("get link digest of user %d: %w", userId, err)
+	}
+
+	query = `SELECT su.short_code, su.link, SUM(r.clicks)::bigint AS clicks, su.created_at + (su.exp_time_minutes || ' minutes')::interval
+		FROM click_daily_rollups r
+		JOIN short_url su ON su.id = r.short_url_id
+		WHERE su.owner_id = $1 AND r.day >= $2 AND r.day < $3
+		GROUP BY su.id
+		ORDER BY clicks DESC, su.short_code LIMIT $4;`
+	topLinks, err := s.listDigestLinks(query, userId, since, until, top)
+	if err != nil {
+		return nil, fmt.Errorf("get link digest of user %d: %w", userId, err)
+	}
+	digest.TopLinks = topLinks
+
+	query = `SELECT lower(substring(ce.referrer from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')) AS referrer, COUNT(*)
+		FROM click_events ce
+		JOIN short_url su ON su.id = ce.short_url_id
+		WHERE su.owner_id = $1 AND ce.clicked_at >= $2 AND ce.clicked_at < $3
+		GROUP BY 1
+		HAVING lower(substring(ce.referrer from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')) IS NOT NULL
+		ORDER BY 2 DESC, 1 LIMIT $4;`
+	rows, err := s.read.Query(context.Background(), query, userId, since, until, top)
+	if err != nil {
+		log.Printf("[database:GetLinkDigest] Could not count the referrers: %v", err)
+		return nil, fmt.Errorf("get link digest of user %d: %w", userId, err)
+	}
+	defer rows.Close()
+
+	digest.TopReferrers = []*ReferrerClicksModel{}
+	for rows.Next() {
+		referrer := &ReferrerClicksModel{}
+		if err := rows.Scan(&referrer.Referrer, &referrer.Clicks); err != nil {
+			return nil, fmt.Errorf("get link digest of user %d: %w", userId, err)
+		}
+		digest.TopReferrers = append(digest.TopReferrers, referrer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get link digest of user %d: %w", userId, err)
+	}
+
+	query = `SELECT short_code, link, 0, created_at + (exp_time_minutes || ' minutes')::interval AS expires_at
+		FROM short_url
+		WHERE owner_id = $1 AND disabled_at IS NULL
+			AND created_at + (exp_time_minutes || ' minutes')::interval >= $2
+			AND created_at + (exp_time_minutes || ' minutes')::interval < $3
+		ORDER BY expires_at, short_code LIMIT $4;`
+	expiring, err := s.listDigestLinks(query, userId, until, until.AddDate(0, 0, 7), top)
+	if err != nil {
+		return nil, fmt.Errorf("get link digest of user %d: %w", userId, err)
+	}
+	digest.Expiring = expiring
+
+	return digest, nil
+}
+
+// listDigestLinks runs query, selecting the short code, link, clicks and expiry of links
+func (s *service) listDigestLinks(query string, args ...any) ([]*DigestLinkModel, error) {
+	rows, err := s.read.Query(context.Background(), query, args...)
+	if err != nil {
+		log.Printf("[database:GetLinkDigest] Could not list the links: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []*DigestLinkModel{}
+	for rows.Next() {
+		link := &DigestLinkModel{}
+		if err := rows.Scan(&link.ShortCode, &link.Link, &link.Clicks, &link.ExpiresAt); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+func (s *service) MarkDigestSent(userId int, at time.Time) error {
+	if _, err := s.db.Exec(context.Background(), "UPDATE users SET digest_sent_at = $2 WHERE id = $1;", userId, at); err != nil {
+		return fmt.Errorf("mark digest of user %d sent: %w", userId, err)
+	}
+	return nil
+}
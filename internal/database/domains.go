@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// domainColumns must be kept in sync with scanDomain
+const domainColumns = "id, hostname, organization_id, COALESCE(verification_token, ''), verified_at, checked_at, COALESCE(logo_url, ''), COALESCE(primary_color, ''), COALESCE(background_color, ''), COALESCE(footer_text, ''), created_at"
+
+func scanDomain(row scanner) (*DomainModel, error) {
+	domain := &DomainModel{}
+	err := row.Scan(&domain.Id, &domain.Hostname, &domain.OrganizationId, &domain.VerificationToken, &domain.VerifiedAt, &domain.CheckedAt, &domain.LogoUrl, &domain.PrimaryColor, &domain.BackgroundColor, &domain.FooterText, &domain.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (s *service) SaveDomain(domainModel *DomainModel) (*DomainModel, error) {
+	query := "INSERT INTO domains (hostname, organization_id, verification_token, verified_at) VALUES ($1, $2, NULLIF($3, ''), $4) RETURNING " + domainColumns + ";"
+
+	inserted, err := scanDomain(s.db.QueryRow(context.Background(), query, domainModel.Hostname, domainModel.OrganizationId, domainModel.VerificationToken, domainModel.VerifiedAt))
+	if err != nil {
+		if err := unique(err); errors.Is(err, ErrConflict) {
+			return nil, fmt.Errorf("save domain %s: %w", domainModel.Hostname, err)
+		}
+
+		log.Printf("[database:SaveDomain] Error inserting domain: %v", err)
+		return nil, fmt.Errorf("save domain %s: %w", domainModel.Hostname, err)
+	}
+
+	log.Printf("[database:SaveDomain] Added domain: {%s}", inserted.Hostname)
+
+	return inserted, nil
+}
+
+func (s *service) ListDomains() ([]*DomainModel, error) {
+	return s.listDomains("list domains", "SELECT "+domainColumns+" FROM domains ORDER BY id;")
+}
+
+func (s *service) ListOrganizationDomains(organizationId int) ([]*DomainModel, error) {
+	return s.listDomains(fmt.Sprintf("list domains of organization %d", organizationId), "SELECT "+domainColumns+" FROM domains WHERE organization_id = $1 ORDER BY id;", organizationId)
+}
+
+func (s *service) ListUnverifiedDomains() ([]*DomainModel, error) {
+	return s.listDomains("list unverified domains", "SELECT "+domainColumns+" FROM domains WHERE verified_at IS NULL ORDER BY id;")
+}
+
+func (s *service) listDomains(op string, query string, args ...any) ([]*DomainModel, error) {
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		log.Printf("[database:listDomains] Something went wrong: %v", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	domains := []*DomainModel{}
+	for rows.Next() {
+		domain, err := scanDomain(rows)
+		if err != nil {
+			log.Printf("[database:listDomains] Error scanning row: %v", err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
+
+func (s *service) GetDomain(id int) (*DomainModel, error) {
+	domain, err := scanDomain(s.db.QueryRow(context.Background(), "SELECT "+domainColumns+" FROM domains WHERE id = $1;", id))
+	if err != nil {
+		return nil, fmt.Errorf("get domain %d: %w", id, notFound(err))
+	}
+
+	return domain, nil
+}
+
+func (s *service) GetDomainByHostname(hostname string) (*DomainModel, error) {
+	domain, err := scanDomain(s.read.QueryRow(context.Background(), "SELECT "+domainColumns+" FROM domains WHERE hostname = $1;", hostname))
+	if err != nil {
+		return nil, fmt.Errorf("get domain %s: %w", hostname, notFound(err))
+	}
+
+	return domain, nil
+}
+
+func (s *service) MarkDomainChecked(id int, verified bool) (*DomainModel, error) {
+	query := `UPDATE domains SET checked_at = NOW(), verified_at = CASE WHEN $2 THEN COALESCE(verified_at, NOW()) ELSE verified_at END
+		WHERE id = $1
+		RETURNING ` + domainColumns + ";"
+
+	domain, err := scanDomain(s.db.QueryRow(context.Background(), query, id, verified))
+	if err != nil {
+		return nil, fmt.Errorf("mark domain %d checked: %w", id, notFound(err))
+	}
+
+	if verified {
+		log.Printf("[database:MarkDomainChecked] Verified domain: {%s}", domain.Hostname)
+	}
+
+	return domain, nil
+}
+
+func (s *service) UpdateDomainBranding(domainModel *DomainModel) (*DomainModel, error) {
+	query := `UPDATE domains SET logo_url = NULLIF($2, ''), primary_color = NULLIF($3, ''), background_color = NULLIF($4, ''), footer_text = NULLIF($5, '')
+		WHERE id = $1
+		RETURNING ` + domainColumns + ";"
+
+	domain, err := scanDomain(s.db.QueryRow(context.Background(), query, domainModel.Id, domainModel.LogoUrl, domainModel.PrimaryColor, domainModel.BackgroundColor, domainModel.FooterText))
+	if err != nil {
+		log.Printf("[database:UpdateDomainBranding] Could not update domain {%d}: %v", domainModel.Id, err)
+		return nil, fmt.Errorf("update branding of domain %d: %w", domainModel.Id, notFound(err))
+	}
+
+	return domain, nil
+}
+
+func (s *service) DeleteUnverifiedDomains(before time.Time) (int64, error) {
+	deleted, err := s.destroy("DeleteUnverifiedDomains", "DELETE FROM domains WHERE verified_at IS NULL AND created_at < $1", "hostname", before)
+	if err != nil {
+		log.Printf("[database:DeleteUnverifiedDomains] Something went wrong: %v", err)
+		return 0, fmt.Errorf("delete unverified domains: %w", err)
+	}
+
+	log.Printf("[database:DeleteUnverifiedDomains] Removed {%d} domains never verified", deleted)
+
+	return deleted, nil
+}
+
+func (s *service) DeleteDomain(id int) error {
+	log.Printf("[database:DeleteDomain] Removing domain with id: {%d}", id)
+
+	result, err := s.db.Exec(context.Background(), "DELETE FROM domains WHERE id = $1;", id)
+	if err != nil {
+		log.Printf("[database:DeleteDomain] something went wrong while deleting domain {%d}: %v", id, err)
+		return fmt.Errorf("delete domain %d: %w", id, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("delete domain %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Rows a dry run names in its log, the others are only counted
+const dryRunSample = 10
+
+func (s *service) DryRun() Service {
+	dryRun := *s
+	dryRun.dryRun = true
+	return &dryRun
+}
+
+// destroy runs query, a DELETE or an UPDATE without its semicolon, and returns how many rows it changed. In a dry run
+// the statement runs in a transaction rolled back instead, returning key of each row so that a sample is logged. The
+// count is then exact, at the cost of the locks of a real run
+func (s *service) destroy(op string, query string, key string, args ...any) (int64, error) {
+	if !s.dryRun {
+		result, err := s.db.Exec(context.Background(), query+";", args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected(), nil
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	rows, err := tx.Query(context.Background(), fmt.Sprintf("%s RETURNING (%s)::text;", query, key), args...)
+	if err != nil {
+		return 0, err
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("[database:%s] Dry run, would change {%d} rows, e.g. {%s}", op, len(keys), strings.Join(keys[:min(len(keys), dryRunSample)], ", "))
+
+	return int64(len(keys)), nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"url-shortner/internal/normalize"
+)
+
+func (s *service) SaveLinkEditToken(tokenModel *LinkEditTokenModel) error {
+	query := `INSERT INTO link_edit_tokens (short_url_id, token_hash) VALUES ($1, $2);`
+
+	if _, err := s.db.Exec(context.Background(), query, tokenModel.ShortUrlId, tokenModel.TokenHash); err != nil {
+		log.Printf("[database:SaveLinkEditToken] Error inserting edit token of short url {%d}: %v", tokenModel.ShortUrlId, err)
+		return fmt.Errorf("save edit token of short url %d: %w", tokenModel.ShortUrlId, err)
+	}
+
+	return nil
+}
+
+// GetShortUrlByEditToken reads from the primary, a lagging replica would refuse the token of a link just created
+func (s *service) GetShortUrlByEditToken(shortCode string, tokenHash string) (*ShortUrlModel, error) {
+	query := `SELECT ` + shortUrlColumns + ` FROM short_url
+		WHERE short_code = $1 AND owner_id IS NULL
+			AND id IN (SELECT short_url_id FROM link_edit_tokens WHERE token_hash = $2);`
+
+	shortUrl, err := scanShortUrl(s.db.QueryRow(context.Background(), query, shortCode, tokenHash))
+	if err != nil {
+		return nil, fmt.Errorf("get short url %s by edit token: %w", shortCode, notFound(err))
+	}
+
+	return shortUrl, nil
+}
+
+func (s *service) EditShortUrl(shortCode string, link string, expTimeMinutes int, review *ReviewModel) (*ShortUrlModel, error) {
+	query := `UPDATE short_url SET link = $2, link_hash = $3, exp_time_minutes = $4
+		WHERE short_code = $1 AND disabled_at IS NULL
+		RETURNING ` + shortUrlColumns + `;`
+
+	shortUrl, err := s.reviewed(review, func(q dbtx) (*ShortUrlModel, error) {
+		shortUrl, err := scanShortUrl(q.QueryRow(context.Background(), query, shortCode, link, normalize.Hash(link), expTimeMinutes))
+		if err != nil {
+			log.Printf("[database:EditShortUrl] Could not edit shortCode {%s}: %v", shortCode, err)
+			return nil, fmt.Errorf("edit short url %s: %w", shortCode, notFound(err))
+		}
+		return shortUrl, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[database:EditShortUrl] Edited shortCode {%s}", shortCode)
+
+	return shortUrl, nil
+}
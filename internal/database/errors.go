@@ -0,0 +1,126 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors returned by the Service methods, so callers can tell outcomes apart with errors.Is
+var (
+	// The requested row doesn't exist, or isn't in the state the operation expects anymore
+	ErrNotFound = errors.New("not found")
+
+	// A unique constraint refused the value of a field, see ConflictError
+	ErrConflict = errors.New("already in use")
+
+	// Another short url already uses the short code
+	ErrDuplicateCode = errors.New("short code already in use")
+
+	// Another domain already has the hostname
+	ErrDuplicateDomain = errors.New("domain already exists")
+
+	// Another namespace already has the name
+	ErrDuplicateNamespace = errors.New("namespace already exists")
+
+	// The namespace still has links
+	ErrNamespaceInUse = errors.New("namespace has links")
+
+	// The link exists but can no longer be followed, see ShortUrlModel.Usable
+	ErrExpired  = errors.New("link expired")
+	ErrDisabled = errors.New("link disabled")
+
+	// Removing or demoting the member would leave the organization without an owner
+	ErrLastOwner = errors.New("organization needs an owner")
+
+	// The invitation names another email than the one of the user accepting it
+	ErrInvitationEmail = errors.New("invitation is for another email")
+
+	// The advisory lock is held by another session, see TryAcquireLock
+	ErrLocked = errors.New("locked by another instance")
+)
+
+// ConflictError is a write refused by a unique constraint. It matches ErrConflict, and the error of the constraint
+// when it has one, e.g. ErrDuplicateCode
+type ConflictError struct {
+	// Field whose value is already taken, as in short_code or domain
+	Field string
+
+	err error
+}
+
+func (e *ConflictError) Error() string {
+	return e.Field + " already in use"
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.err
+}
+
+// conflicts names the field and the error of the unique constraints callers tell apart
+var conflicts = map[string]*ConflictError{
+	shortCodeConstraint:      {Field: "short_code", err: ErrDuplicateCode},
+	domainHostnameConstraint: {Field: "domain", err: ErrDuplicateDomain},
+	namespaceNameConstraint:  {Field: "namespace", err: ErrDuplicateNamespace},
+	userEmailConstraint:      {Field: "email"},
+	bannedPatternConstraint:  {Field: "pattern"},
+}
+
+// conflictDetail matches the DETAIL of a unique_violation, as in "Key (short_code)=(abc) already exists."
+var conflictDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// unique maps a unique_violation to a ConflictError and leaves any other error untouched. The values of the row
+// Postgres puts in the message aren't kept, only the name of the field
+func unique(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolation {
+		return err
+	}
+
+	if conflict, ok := conflicts[pgErr.ConstraintName]; ok {
+		return &ConflictError{Field: conflict.Field, err: conflict.err}
+	}
+
+	field := pgErr.ConstraintName
+	if match := conflictDetail.FindStringSubmatch(pgErr.Detail); match != nil {
+		field = match[1]
+	}
+	return &ConflictError{Field: field}
+}
+
+// notFound maps a missing row to ErrNotFound and leaves any other error untouched
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+const (
+	// SQLSTATE of unique_violation
+	uniqueViolation = "23505"
+
+	// SQLSTATE of foreign_key_violation
+	foreignKeyViolation = "23503"
+
+	// Unique index guaranteeing a short code maps to a single link
+	shortCodeConstraint = "short_url_short_code_key"
+
+	// Unique index on the hostname of the domains
+	domainHostnameConstraint = "domains_hostname_key"
+
+	// Unique index on the name of the namespaces
+	namespaceNameConstraint = "namespaces_name_key"
+
+	// Unique index on the email of the users
+	userEmailConstraint = "users_email_key"
+
+	// Unique index on the patterns of the banned domains
+	bannedPatternConstraint = "banned_domains_pattern_key"
+)
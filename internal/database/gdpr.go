@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (s *service) ExportUserData(userId int) (*UserExportModel, error) {
+	log.Printf("[database:ExportUserData] Exporting data of user {%d}", userId)
+
+	export := &UserExportModel{User: &UserModel{}}
+
+	err := s.db.QueryRow(context.Background(), "SELECT id, email, role, plan, created_at FROM users WHERE id = $1;", userId).Scan(&export.User.Id, &export.User.Email, &export.User.Role, &export.User.Plan, &export.User.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("export data of user %d: %w", userId, notFound(err))
+	}
+
+	if export.Identities, err = s.ListUserIdentities(userId); err != nil {
+		return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+	}
+
+	if export.ApiKeys, err = s.ListApiKeys(userId); err != nil {
+		return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+	}
+
+	rows, err := s.db.Query(context.Background(), "SELECT "+shortUrlColumns+" FROM short_url WHERE owner_id = $1 ORDER BY id;", userId)
+	if err != nil {
+		log.Printf("[database:ExportUserData] Could not list links: %v", err)
+		return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+	}
+	defer rows.Close()
+
+	export.Links = []*ShortUrlModel{}
+	for rows.Next() {
+		shortUrl, err := scanShortUrl(rows)
+		if err != nil {
+			return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+		}
+		export.Links = append(export.Links, shortUrl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+	}
+
+	query := `SELECT ce.id, ce.short_url_id, su.short_code, COALESCE(ce.ip, ''), COALESCE(ce.user_agent, ''), COALESCE(ce.referrer, ''), ce.clicked_at
+		FROM click_events AS ce
+		JOIN short_url AS su ON su.id = ce.short_url_id
+		WHERE su.owner_id = $1
+		ORDER BY ce.id;`
+
+	clickRows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		log.Printf("[database:ExportUserData] Could not list click events: %v", err)
+		return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+	}
+	defer clickRows.Close()
+
+	export.ClickEvents = []*ClickEventModel{}
+	for clickRows.Next() {
+		click := &ClickEventModel{}
+		if err := clickRows.Scan(&click.Id, &click.ShortUrlId, &click.ShortCode, &click.Ip, &click.UserAgent, &click.Referrer, &click.ClickedAt); err != nil {
+			return nil, fmt.Errorf("export data of user %d: %w", userId, err)
+		}
+		export.ClickEvents = append(export.ClickEvents, click)
+	}
+
+	return export, clickRows.Err()
+}
+
+func (s *service) RequestUserDeletion(userId int) (*DeletionRequestModel, error) {
+	log.Printf("[database:RequestUserDeletion] Deletion requested by user {%d}", userId)
+
+	// Asking twice keeps the original pending request
+	query := `INSERT INTO deletion_requests (user_id) VALUES ($1)
+		ON CONFLICT (user_id) WHERE status = 'pending' DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING id, user_id, status, requested_at, processed_at;`
+
+	request := &DeletionRequestModel{}
+	err := s.db.QueryRow(context.Background(), query, userId).Scan(&request.Id, &request.UserId, &request.Status, &request.RequestedAt, &request.ProcessedAt)
+	if err != nil {
+		log.Printf("[database:RequestUserDeletion] Could not save deletion request: %v", err)
+		return nil, fmt.Errorf("request deletion of user %d: %w", userId, err)
+	}
+
+	return request, nil
+}
+
+func (s *service) GetDeletionRequest(userId int) (*DeletionRequestModel, error) {
+	query := "SELECT id, user_id, status, requested_at, processed_at FROM deletion_requests WHERE user_id = $1 ORDER BY id DESC LIMIT 1;"
+
+	request := &DeletionRequestModel{}
+	err := s.db.QueryRow(context.Background(), query, userId).Scan(&request.Id, &request.UserId, &request.Status, &request.RequestedAt, &request.ProcessedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get deletion request of user %d: %w", userId, notFound(err))
+	}
+
+	return request, nil
+}
+
+func (s *service) ProcessDeletionRequests() (int, error) {
+	log.Printf("[database:ProcessDeletionRequests] Processing pending deletion requests")
+
+	rows, err := s.db.Query(context.Background(), "SELECT id, user_id FROM deletion_requests WHERE status = $1 ORDER BY id;", DeletionPending)
+	if err != nil {
+		log.Printf("[database:ProcessDeletionRequests] Could not list pending requests: %v", err)
+		return 0, fmt.Errorf("process deletion requests: %w", err)
+	}
+
+	pending := map[int]int{}
+	for rows.Next() {
+		var id, userId int
+		if err := rows.Scan(&id, &userId); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("process deletion requests: %w", err)
+		}
+		pending[id] = userId
+	}
+	rows.Close()
+
+	processed := 0
+	for id, userId := range pending {
+		if err := s.eraseUser(id, userId); err != nil {
+			log.Printf("[database:ProcessDeletionRequests] Could not erase user {%d}: %v", userId, err)
+			continue
+		}
+		processed++
+	}
+
+	log.Printf("[database:ProcessDeletionRequests] Erased {%d} users", processed)
+
+	return processed, nil
+}
+
+// eraseUser deletes the click events of the user's links, the links and the user in one transaction.
+// Links shared with an organization are kept for it, only losing their owner.
+// Api keys, identities, memberships and notifications go away with the user through their foreign keys.
+func (s *service) eraseUser(requestId int, userId int) error {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("erase user %d: %w", userId, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	statements := []string{
+		"DELETE FROM click_events WHERE short_url_id IN (SELECT id FROM short_url WHERE owner_id = $1 AND organization_id IS NULL);",
+		"DELETE FROM short_url WHERE owner_id = $1 AND organization_id IS NULL;",
+		"DELETE FROM users WHERE id = $1;",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(context.Background(), statement, userId); err != nil {
+			return fmt.Errorf("erase user %d: %w", userId, err)
+		}
+	}
+
+	_, err = tx.Exec(context.Background(), "UPDATE deletion_requests SET status = $2, processed_at = NOW() WHERE id = $1;", requestId, DeletionCompleted)
+	if err != nil {
+		return fmt.Errorf("erase user %d: %w", userId, err)
+	}
+
+	return tx.Commit(context.Background())
+}
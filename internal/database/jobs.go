@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// jobColumns must be kept in sync with scanJob
+const jobColumns = "id, kind, status, payload, result, COALESCE(error, ''), user_id, attempts, created_at, started_at, finished_at"
+
+func scanJob(row scanner) (*JobModel, error) {
+	job := &JobModel{}
+	err := row.Scan(&job.Id, &job.Kind, &job.Status, &job.Payload, &job.Result, &job.Error, &job.UserId, &job.Attempts, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *service) EnqueueJob(jobModel *JobModel) (*JobModel, error) {
+	query := "INSERT INTO jobs (kind, payload, user_id) VALUES ($1, $2, $3) RETURNING " + jobColumns + ";"
+
+	job, err := scanJob(s.db.QueryRow(context.Background(), query, jobModel.Kind, jobModel.Payload, jobModel.UserId))
+	if err != nil {
+		log.Printf("[database:EnqueueJob] Error queueing {%s} job: %v", jobModel.Kind, err)
+		return nil, fmt.Errorf("enqueue %s job: %w", jobModel.Kind, err)
+	}
+
+	log.Printf("[database:EnqueueJob] Queued {%s} job {%d}", job.Kind, job.Id)
+
+	return job, nil
+}
+
+func (s *service) GetJob(id int) (*JobModel, error) {
+	job, err := scanJob(s.db.QueryRow(context.Background(), "SELECT "+jobColumns+" FROM jobs WHERE id = $1;", id))
+	if err != nil {
+		return nil, fmt.Errorf("get job %d: %w", id, notFound(err))
+	}
+
+	return job, nil
+}
+
+func (s *service) ClaimJob(kinds []string, staleAfter time.Duration) (*JobModel, error) {
+	// SKIP LOCKED lets several workers claim jobs at once without waiting on each other
+	query := `UPDATE jobs SET status = $3, started_at = NOW(), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($1) AND (status = $4 OR (status = $3 AND started_at < NOW() - make_interval(secs => $2)))
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns + ";"
+
+	job, err := scanJob(s.db.QueryRow(context.Background(), query, kinds, staleAfter.Seconds(), JobRunning, JobPending))
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", notFound(err))
+	}
+
+	log.Printf("[database:ClaimJob] Claimed {%s} job {%d}, attempt {%d}", job.Kind, job.Id, job.Attempts)
+
+	return job, nil
+}
+
+func (s *service) FinishJob(id int, result []byte, jobErr string) error {
+	status := JobDone
+	if jobErr != "" {
+		status = JobFailed
+	}
+
+	query := "UPDATE jobs SET status = $2, result = $3, error = NULLIF($4, ''), finished_at = NOW() WHERE id = $1;"
+
+	tag, err := s.db.Exec(context.Background(), query, id, status, result, jobErr)
+	if err != nil {
+		log.Printf("[database:FinishJob] Could not finish job {%d}: %v", id, err)
+		return fmt.Errorf("finish job %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("finish job %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) SaveJobFile(id int, file []byte) error {
+	tag, err := s.db.Exec(context.Background(), "UPDATE jobs SET file = $2 WHERE id = $1;", id, file)
+	if err != nil {
+		log.Printf("[database:SaveJobFile] Could not save the file of job {%d}: %v", id, err)
+		return fmt.Errorf("save file of job %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("save file of job %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) GetJobFile(id int) ([]byte, error) {
+	var file []byte
+	err := s.db.QueryRow(context.Background(), "SELECT file FROM jobs WHERE id = $1 AND file IS NOT NULL;", id).Scan(&file)
+	if err != nil {
+		return nil, fmt.Errorf("get file of job %d: %w", id, notFound(err))
+	}
+
+	return file, nil
+}
+
+func (s *service) SaveJobFileKey(id int, key string) error {
+	tag, err := s.db.Exec(context.Background(), "UPDATE jobs SET file_key = $2 WHERE id = $1;", id, key)
+	if err != nil {
+		log.Printf("[database:SaveJobFileKey] Could not save the file key of job {%d}: %v", id, err)
+		return fmt.Errorf("save file key of job %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("save file key of job %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) GetJobFileKey(id int) (string, error) {
+	var key string
+	err := s.db.QueryRow(context.Background(), "SELECT file_key FROM jobs WHERE id = $1 AND file_key IS NOT NULL;", id).Scan(&key)
+	if err != nil {
+		return "", fmt.Errorf("get file key of job %d: %w", id, notFound(err))
+	}
+
+	return key, nil
+}
+
+func (s *service) ListFinishedJobFileKeys(before time.Time) ([]string, error) {
+	rows, err := s.db.Query(context.Background(), "SELECT file_key FROM jobs WHERE finished_at < $1 AND file_key IS NOT NULL ORDER BY id;", before)
+	if err != nil {
+		log.Printf("[database:ListFinishedJobFileKeys] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list file keys of jobs finished before %s: %w", before.Format(time.RFC3339), err)
+	}
+
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("list file keys of jobs finished before %s: %w", before.Format(time.RFC3339), err)
+	}
+
+	return keys, nil
+}
+
+func (s *service) DeleteFinishedJobs(before time.Time) (int64, error) {
+	deleted, err := s.destroy("DeleteFinishedJobs", "DELETE FROM jobs WHERE finished_at < $1", "id", before)
+	if err != nil {
+		log.Printf("[database:DeleteFinishedJobs] Could not delete finished jobs: %v", err)
+		return 0, fmt.Errorf("delete jobs finished before %s: %w", before.Format(time.RFC3339), err)
+	}
+
+	log.Printf("[database:DeleteFinishedJobs] Deleted {%d} jobs finished before {%s}", deleted, before.Format(time.RFC3339))
+
+	return deleted, nil
+}
+
+func (s *service) StartJobRun(name string, interval time.Duration) error {
+	query := `INSERT INTO jobs_status (name, interval_seconds, last_started_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (name) DO UPDATE SET interval_seconds = EXCLUDED.interval_seconds, last_started_at = NOW();`
+
+	if _, err := s.db.Exec(context.Background(), query, name, int(interval.Seconds())); err != nil {
+		log.Printf("[database:StartJobRun] Could not record the start of {%s}: %v", name, err)
+		return fmt.Errorf("start job run %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *service) FinishJobRun(name string) error {
+	tag, err := s.db.Exec(context.Background(), "UPDATE jobs_status SET last_finished_at = NOW(), last_error = NULL WHERE name = $1;", name)
+	if err != nil {
+		log.Printf("[database:FinishJobRun] Could not record the end of {%s}: %v", name, err)
+		return fmt.Errorf("finish job run %s: %w", name, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("finish job run %s: %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) FailJobRun(name string, jobErr string) error {
+	tag, err := s.db.Exec(context.Background(), "UPDATE jobs_status SET last_failed_at = NOW(), last_error = $2 WHERE name = $1;", name, jobErr)
+	if err != nil {
+		log.Printf("[database:FailJobRun] Could not record the failure of {%s}: %v", name, err)
+		return fmt.Errorf("fail job run %s: %w", name, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("fail job run %s: %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) ListJobStatuses() ([]*JobStatusModel, error) {
+	query := "SELECT " + jobStatusColumns + " FROM jobs_status ORDER BY name;"
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:ListJobStatuses] Could not list the job statuses: %v", err)
+		return nil, fmt.Errorf("list job statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []*JobStatusModel{}
+	for rows.Next() {
+		status, err := scanJobStatus(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list job statuses: %w", err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// jobStatusColumns must be kept in sync with scanJobStatus
+const jobStatusColumns = "name, interval_seconds, last_started_at, last_finished_at, last_failed_at, COALESCE(last_error, ''), run_requested_at"
+
+func scanJobStatus(row scanner) (*JobStatusModel, error) {
+	status := &JobStatusModel{}
+	var intervalSeconds int
+	if err := row.Scan(&status.Name, &intervalSeconds, &status.LastStartedAt, &status.LastFinishedAt, &status.LastFailedAt, &status.LastError, &status.RunRequestedAt); err != nil {
+		return nil, err
+	}
+	status.Interval = time.Duration(intervalSeconds) * time.Second
+	return status, nil
+}
+
+func (s *service) RequestJobRun(name string) (*JobStatusModel, error) {
+	query := "UPDATE jobs_status SET run_requested_at = NOW() WHERE name = $1 RETURNING " + jobStatusColumns + ";"
+
+	status, err := scanJobStatus(s.db.QueryRow(context.Background(), query, name))
+	if err != nil {
+		return nil, fmt.Errorf("request run of job %s: %w", name, notFound(err))
+	}
+
+	log.Printf("[database:RequestJobRun] Requested a run of {%s}", name)
+
+	return status, nil
+}
+
+func (s *service) ClaimJobRunRequests() ([]string, error) {
+	rows, err := s.db.Query(context.Background(), "UPDATE jobs_status SET run_requested_at = NULL WHERE run_requested_at IS NOT NULL RETURNING name;")
+	if err != nil {
+		return nil, fmt.Errorf("claim job run requests: %w", err)
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("claim job run requests: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Lock is an advisory lock taken with TryAcquireLock. It is held by the session of its connection, which is kept out
+// of the pool until Release
+type Lock struct {
+	key  string
+	conn *pgxpool.Conn
+}
+
+// Advisory locks are shared by the whole database, the schema is part of the key so tenants don't wait for each other
+const tryAcquireLockQuery = "SELECT pg_try_advisory_lock(hashtext(current_schema()), hashtext($1));"
+
+func (s *service) TryAcquireLock(ctx context.Context, key string) (*Lock, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock %s: %w", key, err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, tryAcquireLockQuery, key).Scan(&acquired); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("acquire lock %s: %w", key, err)
+	}
+	if !acquired {
+		conn.Release()
+		return nil, fmt.Errorf("acquire lock %s: %w", key, ErrLocked)
+	}
+
+	return &Lock{key: key, conn: conn}, nil
+}
+
+// Release unlocks and gives the connection back to the pool. A connection that can't unlock is closed, which
+// releases the lock all the same. Releasing a nil or released Lock does nothing
+func (l *Lock) Release() error {
+	if l == nil || l.conn == nil {
+		return nil
+	}
+	conn := l.conn
+	l.conn = nil
+	defer conn.Release()
+
+	var unlocked bool
+	err := conn.QueryRow(context.Background(), "SELECT pg_advisory_unlock(hashtext(current_schema()), hashtext($1));", l.key).Scan(&unlocked)
+	if err != nil || !unlocked {
+		log.Printf("[database:Lock.Release] Could not unlock {%s}, closing its connection: %v", l.key, err)
+		conn.Conn().Close(context.Background())
+	}
+	if err != nil {
+		return fmt.Errorf("release lock %s: %w", l.key, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (s *service) GetMaintenance() (*MaintenanceModel, error) {
+	switched := &MaintenanceModel{}
+	err := s.db.QueryRow(context.Background(), "SELECT enabled, message, updated_at FROM maintenance;").Scan(&switched.Enabled, &switched.Message, &switched.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get maintenance: %w", notFound(err))
+	}
+
+	return switched, nil
+}
+
+func (s *service) SetMaintenance(maintenanceModel *MaintenanceModel) (*MaintenanceModel, error) {
+	query := "UPDATE maintenance SET enabled = $1, message = $2, updated_at = NOW() RETURNING enabled, message, updated_at;"
+
+	switched := &MaintenanceModel{}
+	err := s.db.QueryRow(context.Background(), query, maintenanceModel.Enabled, maintenanceModel.Message).Scan(&switched.Enabled, &switched.Message, &switched.UpdatedAt)
+	if err != nil {
+		log.Printf("[database:SetMaintenance] Could not switch the maintenance to {%t}: %v", maintenanceModel.Enabled, err)
+		return nil, fmt.Errorf("set maintenance: %w", notFound(err))
+	}
+
+	log.Printf("[database:SetMaintenance] Maintenance switched to {%t}", switched.Enabled)
+
+	return switched, nil
+}
@@ -1,13 +1,628 @@
 package database
 
-import "time"
+import (
+	"time"
+
+	"url-shortner/internal/pagination"
+)
 
 type ShortUrlModel struct {
-	Id             int
-	Link           string
-	TimesClicked   int
-	ExpTimeMinutes int
+	Id              int
+	Link            string
+	TimesClicked    int
+	ExpTimeMinutes  int
+	CreatedAt       time.Time
+	ShortCode       string
+	OwnerId         *int
+	OrganizationId  *int
+	ApiKeyId        *int
+	DomainId        *int
+	NamespaceId     *int
+	DisabledAt      *time.Time
+	DisabledReason  string
+	ModerationState string
+	Visibility      string
+
+	// When the link or its click count last changed
+	UpdatedAt time.Time
+
+	// Review queued in the same transaction as saving the link, which is then saved disabled. Only read by the
+	// writes, nil for the links needing none
+	Review *ReviewModel
+}
+
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// visibilityOf is the visibility stored for m, public unless it says otherwise
+func visibilityOf(m *ShortUrlModel) string {
+	if m.Visibility == "" {
+		return VisibilityPublic
+	}
+	return m.Visibility
+}
+
+// Usable reports why the link can't be followed at now: ErrDisabled, ErrExpired, or nil when it can
+func (m *ShortUrlModel) Usable(now time.Time) error {
+	if m.DisabledAt != nil {
+		return ErrDisabled
+	}
+
+	if !now.Before(m.CreatedAt.Add(time.Duration(m.ExpTimeMinutes) * time.Minute)) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// scanner is implemented by both pgx.Row and pgx.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// shortUrlColumns must be kept in sync with scanShortUrl
+const shortUrlColumns = "id, link, " + timesClickedExpr + ", exp_time_minutes, short_code, created_at, owner_id, organization_id, domain_id, namespace_id, disabled_at, COALESCE(disabled_reason, ''), moderation_state, visibility, " + updatedAtExpr
+
+// timesClickedExpr sums the click counter shards of the current short_url row
+const timesClickedExpr = "COALESCE((SELECT SUM(clicks) FROM link_click_counters WHERE short_url_id = short_url.id), 0)::bigint"
+
+// updatedAtExpr is when the current short_url row or one of its click counter shards last changed
+const updatedAtExpr = "GREATEST(updated_at, (SELECT MAX(updated_at) FROM link_click_counters WHERE short_url_id = short_url.id))"
+
+func scanShortUrl(row scanner) (*ShortUrlModel, error) {
+	shortUrl := &ShortUrlModel{}
+	err := row.Scan(&shortUrl.Id, &shortUrl.Link, &shortUrl.TimesClicked, &shortUrl.ExpTimeMinutes, &shortUrl.ShortCode, &shortUrl.CreatedAt, &shortUrl.OwnerId, &shortUrl.OrganizationId, &shortUrl.DomainId, &shortUrl.NamespaceId, &shortUrl.DisabledAt, &shortUrl.DisabledReason, &shortUrl.ModerationState, &shortUrl.Visibility, &shortUrl.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return shortUrl, nil
+}
+
+type UserModel struct {
+	Id        int
+	Email     string
+	Role      string
+	Plan      string
+	CreatedAt time.Time
+	// Nil until the user proves owning the email
+	EmailVerifiedAt *time.Time
+}
+
+type ApiKeyModel struct {
+	Id         int
+	UserId     int
+	Name       string
+	KeyPrefix  string
+	KeyHash    string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+
+	// When the key stops authenticating, nil for keys that never expire
+	ExpiresAt *time.Time
+
+	// Owner of the key, only loaded by GetApiKeyByHash
+	User *UserModel
+}
+
+// ApiKeyUsageModel counts what an api key did during a UTC day
+type ApiKeyUsageModel struct {
+	ApiKeyId     int
+	Day          time.Time
+	Requests     int64
+	LinksCreated int64
+}
+
+// LinkCountModel is how many links a user or api key created since a point in time, and how many of theirs
+// are still active
+type LinkCountModel struct {
+	Created int
+	Active  int
+}
+
+type GlobalStatsModel struct {
+	TotalLinks   int
+	ActiveLinks  int
+	ExpiredLinks int
+	TotalClicks  int
+	TotalUsers   int
+	TotalApiKeys int
+}
+
+type PurgeSummaryModel struct {
+	ShortCode          string
+	Link               string
+	LinksRemoved       int
+	TimesClicked       int
+	ClickEventsRemoved int64
+}
+
+// DomainModel is a hostname short links are served on
+type DomainModel struct {
+	Id       int
+	Hostname string
+
+	// Organization that brought its own domain, nil for the domains of the instance
+	OrganizationId *int
+
+	// Expected in the TXT record proving the ownership of a custom domain, see dnsverify
+	VerificationToken string
+
+	// Links are only served on verified domains. CheckedAt is the last time the TXT record was looked up
+	VerifiedAt *time.Time
+	CheckedAt  *time.Time
+
+	// Branding of the pages shown to visitors on the domain, empty for the defaults
+	LogoUrl         string
+	PrimaryColor    string
+	BackgroundColor string
+	FooterText      string
+
+	CreatedAt time.Time
+}
+
+// NamespaceModel groups links under a prefix, as in /short/docs/onboarding
+type NamespaceModel struct {
+	Id          int
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// JobModel is work queued to run in the background, e.g. a large import. Payload and Result are JSON documents
+// whose shape depends on the kind of job
+type JobModel struct {
+	Id         int
+	Kind       string
+	Status     string
+	Payload    []byte
+	Result     []byte
+	Error      string
+	UserId     *int
+	Attempts   int
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// JobStatusModel is the heartbeat of a cron job, updated when a run starts and when it finishes or fails
+type JobStatusModel struct {
+	Name           string
+	Interval       time.Duration
+	LastStartedAt  *time.Time
+	LastFinishedAt *time.Time
+	LastFailedAt   *time.Time
+
+	// Error of the last failed run, empty once a run finished since
+	LastError string
+
+	// When a run outside the schedule was asked for, nil once the cronjobs picked it up
+	RunRequestedAt *time.Time
+}
+
+// Running reports whether the last run started has neither finished nor failed yet
+func (m *JobStatusModel) Running() bool {
+	return m.LastStartedAt != nil &&
+		(m.LastFinishedAt == nil || m.LastStartedAt.After(*m.LastFinishedAt)) &&
+		(m.LastFailedAt == nil || m.LastStartedAt.After(*m.LastFailedAt))
+}
+
+// Late reports whether the job hasn't finished a run for factor times its interval, counted from its first start
+// when it never finished one
+func (m *JobStatusModel) Late(now time.Time, factor int) bool {
+	since := m.LastFinishedAt
+	if since == nil {
+		since = m.LastStartedAt
+	}
+	return since != nil && now.Sub(*since) > time.Duration(factor)*m.Interval
+}
+
+// MaintenanceModel is the maintenance switch shared by every process, see the maintenance package
+type MaintenanceModel struct {
+	Enabled   bool
+	Message   string
+	UpdatedAt time.Time
+}
+
+type BannedDomainModel struct {
+	Id        int
+	Pattern   string
+	Reason    string
+	CreatedAt time.Time
+}
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+type ReviewModel struct {
+	Id         int
+	ShortUrlId int
+	ShortCode  string
+	Link       string
+	Score      int
+	Reasons    string
+	Status     string
+	CreatedAt  time.Time
+	ReviewedAt *time.Time
+}
+
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+type AbuseReportModel struct {
+	Id                int
+	ShortUrlId        int
+	ShortCode         string
+	Link              string
+	Reason            string
+	Details           string
+	ReporterEmail     string
+	ReporterIp        string
+	ReporterUserAgent string
+	Status            string
+	CreatedAt         time.Time
+	ResolvedAt        *time.Time
+}
+
+const (
+	ModerationActive      = "active"
+	ModerationFlagged     = "flagged"
+	ModerationUnderReview = "under_review"
+	ModerationTakenDown   = "taken_down"
+)
+
+type TakedownEventModel struct {
+	Id         int
+	ShortUrlId int
+	ShortCode  string
+	FromState  string
+	ToState    string
+	ReasonCode string
+	Note       string
+	Actor      string
+	CreatedAt  time.Time
+}
+
+type AuditLogModel struct {
+	Id         int64
+	Actor      string
+	Action     string
+	EntityType string
+	EntityId   string
+	Before     []byte
+	After      []byte
+	Ip         string
+	CreatedAt  time.Time
+}
+
+// AuditLogFilter narrows ListAuditLog. Empty fields are ignored
+type AuditLogFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityId   string
+	Page       pagination.Page
+}
+
+type ClickEventModel struct {
+	Id         int64
+	ShortUrlId int
+	ShortCode  string
+	Ip         string
+	IpHash     string
+	RawIp      string
+	UserAgent  string
+	Referrer   string
+	ClickedAt  time.Time
+}
+
+// ClickPartitionModel is a partition of click_events, holding the clicks made from From, nil for the first one,
+// until To
+type ClickPartitionModel struct {
+	Name string
+	From *time.Time
+	To   time.Time
+}
+
+// ExportCursorModel is how far a stream was shipped to the warehouse: up to the row LastId, or up to the change at
+// LastUpdatedAt of the row LastId for streams of rows that change
+type ExportCursorModel struct {
+	Stream        string
+	LastId        int64
+	LastUpdatedAt time.Time
+	ExportedAt    time.Time
+}
+
+// AnalyticsScope picks the links whose analytics are purged: those of OrganizationId when set, or else those on Plan
+// outside the organizations overriding its retention
+type AnalyticsScope struct {
+	Plan           string
+	OrganizationId int
+}
+
+// RetentionModel is the analytics retention of an organization overriding that of its plan, in days. nil follows
+// the plan and 0 keeps forever
+type RetentionModel struct {
+	OrganizationId      int
+	ClickRetentionDays  *int
+	RollupRetentionDays *int
+}
+
+const (
+	DeletionPending   = "pending"
+	DeletionCompleted = "completed"
+)
+
+type DeletionRequestModel struct {
+	Id          int
+	UserId      int
+	Status      string
+	RequestedAt time.Time
+	ProcessedAt *time.Time
+}
+
+// UserExportModel holds everything stored about a user
+// UserPasswordModel is a user along with the bcrypt hash of its password, empty for users without one
+type UserPasswordModel struct {
+	User         *UserModel
+	PasswordHash string
+}
+
+// PasswordResetModel lets whoever holds its token set the password of a user, once, until it expires
+type PasswordResetModel struct {
+	Id        int
+	UserId    int
+	TokenHash string
+	// Client that asked for the reset
+	Ip        string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// EmailVerificationModel proves the user owns the email it was sent to, once, until it expires
+type EmailVerificationModel struct {
+	Id        int
+	UserId    int
+	Email     string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// LinkClaimModel lets the creator of an anonymous link attach it to an account later, with the token of the claim
+type LinkClaimModel struct {
+	ShortUrlId int
+	TokenHash  string
+	CreatedAt  time.Time
+}
+
+// LinkEditTokenModel lets the creator of an anonymous link change or delete it, with the token
+type LinkEditTokenModel struct {
+	ShortUrlId int
+	TokenHash  string
+	CreatedAt  time.Time
+}
+
+// SessionModel authenticates the dashboard of a user through a cookie holding its token
+type SessionModel struct {
+	Id        int
+	UserId    int
+	TokenHash string
+	// Sign in method that started the session, a provider or "password"
+	Method     string
+	Ip         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	// When the session ends however active it is
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+
+	// Owner of the session, only loaded by GetSessionByHash
+	User *UserModel
+}
+
+// UserIdentityModel is an OAuth account linked to a user
+type UserIdentityModel struct {
+	Id        int
+	UserId    int
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// Roles of a member within an organization, from the most to the least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleEditor = "editor"
+	OrgRoleViewer = "viewer"
+)
+
+type OrganizationModel struct {
+	Id        int
+	Name      string
+	Plan      string
+	CreatedAt time.Time
+
+	// Role of the user the organization was listed for
+	Role string
+}
+
+type MemberModel struct {
+	OrganizationId int
+	UserId         int
+	Email          string
+	Role           string
 	CreatedAt      time.Time
+}
+
+type InvitationModel struct {
+	Id             int
+	OrganizationId int
+	// Empty for invitations shared as a link
+	Email      string
+	Role       string
+	TokenHash  string
+	InvitedBy  *int
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+}
+
+type UserExportModel struct {
+	User        *UserModel
+	Identities  []*UserIdentityModel
+	ApiKeys     []*ApiKeyModel
+	Links       []*ShortUrlModel
+	ClickEvents []*ClickEventModel
+}
+
+type DailyClicksModel struct {
+	Day    time.Time
+	Clicks int
+}
+
+type LinkStatsModel struct {
 	ShortCode      string
+	TotalClicks    int
+	UniqueVisitors int
+	Daily          []*DailyClicksModel
+}
+
+type DailyLinksModel struct {
+	Day   time.Time
+	Links int
+}
+
+// DomainStatsModel counts the links to a destination domain and their clicks
+type DomainStatsModel struct {
+	Domain string
+	Links  int
+	Clicks int
+}
+
+// TableStatsModel is the size of a table and how many of its rows are dead, left behind by updates and deletes until
+// they are vacuumed
+type TableStatsModel struct {
+	Schema     string
+	Table      string
+	LiveRows   int64
+	DeadRows   int64
+	TableBytes int64
+	IndexBytes int64
+
+	// When the statistics of the planner were last updated, by ANALYZE or autovacuum. nil when they never were
+	AnalyzedAt *time.Time
 }
 
+// DeadRatio is the share of the rows of the table that are dead
+func (t *TableStatsModel) DeadRatio() float64 {
+	if t.LiveRows+t.DeadRows == 0 {
+		return 0
+	}
+	return float64(t.DeadRows) / float64(t.LiveRows+t.DeadRows)
+}
+
+type LeaderboardEntryModel struct {
+	ShortCode  string
+	Link       string
+	Visibility string
+	Clicks     int
+}
+
+// EmailPreferencesModel is what a user agreed to receive by email
+type EmailPreferencesModel struct {
+	UserId       int
+	WeeklyDigest bool
+}
+
+// NotificationModel is a message for a user waiting in the notifications outbox, e.g. about the moderation of their
+// link, with the email it is sent to
+type NotificationModel struct {
+	Id        int
+	UserId    int
+	Email     string
+	Subject   string
+	Body      string
+	CreatedAt time.Time
+	SentAt    *time.Time
+}
+
+// DigestLinkModel is a link as listed in a digest, with its clicks over the period
+type DigestLinkModel struct {
+	ShortCode string
+	Link      string
+	Clicks    int
+	ExpiresAt time.Time
+}
+
+type ReferrerClicksModel struct {
+	Referrer string
+	Clicks   int
+}
+
+// LinkDigestModel sums up how the links of a user did over a period, and which expire in the next one
+type LinkDigestModel struct {
+	Since time.Time
+	Until time.Time
+
+	Links        int
+	Clicks       int
+	TopLinks     []*DigestLinkModel
+	TopReferrers []*ReferrerClicksModel
+
+	// Links expiring within a period past Until
+	Expiring []*DigestLinkModel
+}
+
+// Empty reports whether the digest has nothing to tell
+func (d *LinkDigestModel) Empty() bool {
+	return d.Clicks == 0 && len(d.Expiring) == 0
+}
+
+type LinkSummaryModel struct {
+	TotalLinks   int
+	ActiveLinks  int
+	ExpiredLinks int
+	TotalClicks  int
+	Daily        []*DailyLinksModel
+}
+
+const (
+	HealthUp       = "up"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+type HealthModel struct {
+	Status     string
+	Message    string
+	Components map[string]*ComponentHealthModel
+}
+
+type ComponentHealthModel struct {
+	Status  string
+	Error   string
+	Details map[string]string
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *service) SaveNamespace(namespaceModel *NamespaceModel) (*NamespaceModel, error) {
+	query := "INSERT INTO namespaces (name, description) VALUES ($1, NULLIF($2, '')) RETURNING id, name, COALESCE(description, ''), created_at;"
+
+	inserted := &NamespaceModel{}
+	err := s.db.QueryRow(context.Background(), query, namespaceModel.Name, namespaceModel.Description).Scan(&inserted.Id, &inserted.Name, &inserted.Description, &inserted.CreatedAt)
+	if err != nil {
+		if err := unique(err); errors.Is(err, ErrConflict) {
+			return nil, fmt.Errorf("save namespace %s: %w", namespaceModel.Name, err)
+		}
+
+		log.Printf("[database:SaveNamespace] Error inserting namespace: %v", err)
+		return nil, fmt.Errorf("save namespace %s: %w", namespaceModel.Name, err)
+	}
+
+	log.Printf("[database:SaveNamespace] Created namespace: {%s}", inserted.Name)
+
+	return inserted, nil
+}
+
+func (s *service) ListNamespaces() ([]*NamespaceModel, error) {
+	rows, err := s.read.Query(context.Background(), "SELECT id, name, COALESCE(description, ''), created_at FROM namespaces ORDER BY name;")
+	if err != nil {
+		log.Printf("[database:ListNamespaces] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	namespaces := []*NamespaceModel{}
+	for rows.Next() {
+		namespace := &NamespaceModel{}
+		if err := rows.Scan(&namespace.Id, &namespace.Name, &namespace.Description, &namespace.CreatedAt); err != nil {
+			log.Printf("[database:ListNamespaces] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list namespaces: %w", err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces, rows.Err()
+}
+
+func (s *service) GetNamespaceByName(name string) (*NamespaceModel, error) {
+	namespace := &NamespaceModel{}
+	err := s.read.QueryRow(context.Background(), "SELECT id, name, COALESCE(description, ''), created_at FROM namespaces WHERE name = $1;", name).Scan(&namespace.Id, &namespace.Name, &namespace.Description, &namespace.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get namespace %s: %w", name, notFound(err))
+	}
+
+	return namespace, nil
+}
+
+func (s *service) DeleteNamespace(id int) error {
+	log.Printf("[database:DeleteNamespace] Removing namespace with id: {%d}", id)
+
+	result, err := s.db.Exec(context.Background(), "DELETE FROM namespaces WHERE id = $1;", id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return fmt.Errorf("delete namespace %d: %w", id, ErrNamespaceInUse)
+		}
+
+		log.Printf("[database:DeleteNamespace] something went wrong while deleting namespace {%d}: %v", id, err)
+		return fmt.Errorf("delete namespace %d: %w", id, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("delete namespace %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (s *service) ListUnsentNotifications(afterId int, limit int) ([]*NotificationModel, error) {
+	query := `SELECT n.id, n.user_id, u.email, n.subject, n.body, n.created_at FROM notifications AS n
+		JOIN users AS u ON u.id = n.user_id
+		WHERE n.sent_at IS NULL AND n.id > $1
+		ORDER BY n.id LIMIT $2;`
+
+	rows, err := s.db.Query(context.Background(), query, afterId, limit)
+	if err != nil {
+		log.Printf("[database:ListUnsentNotifications] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list unsent notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []*NotificationModel{}
+	for rows.Next() {
+		notification := &NotificationModel{}
+		if err := rows.Scan(&notification.Id, &notification.UserId, &notification.Email, &notification.Subject, &notification.Body, &notification.CreatedAt); err != nil {
+			return nil, fmt.Errorf("list unsent notifications: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (s *service) MarkNotificationSent(id int, at time.Time) error {
+	if _, err := s.db.Exec(context.Background(), "UPDATE notifications SET sent_at = $2 WHERE id = $1;", id, at); err != nil {
+		return fmt.Errorf("mark notification %d sent: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Channel the short_url triggers notify on, see the notify_short_url_changes migration
+const linkChangesChannel = "short_url_changes"
+
+// Longest wait before listening again after the connection was lost
+const maxListenBackoff = time.Minute
+
+// linkChange is the payload of a notification on linkChangesChannel
+type linkChange struct {
+	Schema    string `json:"schema"`
+	ShortCode string `json:"short_code"`
+}
+
+type linkSubscriber struct {
+	changed func(shortCode string)
+	missed  func()
+}
+
+// linkListener holds the single connection of the process listening on linkChangesChannel, whatever the number of
+// tenants, and hands each notification to the subscribers of the schema it comes from
+type linkListener struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*linkSubscriber]bool
+
+	// Stops the listening goroutine, nil while none runs
+	stop context.CancelFunc
+}
+
+var linkChanges = &linkListener{subscribers: map[string]map[*linkSubscriber]bool{}}
+
+func (s *service) WatchLinkChanges(ctx context.Context, changed func(shortCode string), missed func()) {
+	config := s.pool.Config().ConnConfig
+	schemaName := schemaOf(config)
+	subscriber := &linkSubscriber{changed: changed, missed: missed}
+
+	linkChanges.subscribe(schemaName, subscriber, config)
+	<-ctx.Done()
+	linkChanges.unsubscribe(schemaName, subscriber)
+}
+
+// schemaOf returns the schema the tables of config are created in: the first of its search_path, public when unset
+func schemaOf(config *pgx.ConnConfig) string {
+	first, _, _ := strings.Cut(config.RuntimeParams["search_path"], ",")
+	if first = strings.Trim(strings.TrimSpace(first), `"`); first == "" || first == "$user" {
+		return "public"
+	}
+	return first
+}
+
+// subscribe adds subscriber to those of schemaName, and starts listening with config unless the listener already is
+func (l *linkListener) subscribe(schemaName string, subscriber *linkSubscriber, config *pgx.ConnConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.subscribers[schemaName] == nil {
+		l.subscribers[schemaName] = map[*linkSubscriber]bool{}
+	}
+	l.subscribers[schemaName][subscriber] = true
+
+	if l.stop == nil {
+		ctx, stop := context.WithCancel(context.Background())
+		l.stop = stop
+		go l.run(ctx, config.Copy())
+	}
+}
+
+// unsubscribe removes subscriber, and closes the connection once nobody is left listening
+func (l *linkListener) unsubscribe(schemaName string, subscriber *linkSubscriber) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.subscribers[schemaName], subscriber)
+	if len(l.subscribers[schemaName]) == 0 {
+		delete(l.subscribers, schemaName)
+	}
+
+	if len(l.subscribers) == 0 && l.stop != nil {
+		l.stop()
+		l.stop = nil
+	}
+}
+
+// run listens until ctx is done, connecting again with a growing backoff whenever the connection is lost
+func (l *linkListener) run(ctx context.Context, config *pgx.ConnConfig) {
+	backoff := time.Second
+	failed := false
+
+	for {
+		err := l.listen(ctx, config, func() {
+			if failed {
+				// Whatever changed while nobody listened went unnoticed
+				l.missed()
+			}
+			failed = false
+			backoff = time.Second
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		failed = true
+
+		log.Printf("[database:linkListener] Could not listen for link changes, retrying in {%s}: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxListenBackoff)
+	}
+}
+
+// listen holds a connection of its own, outside the pools since it is never given back, and dispatches the
+// notifications until the connection fails or ctx is done. listening is called once notifications are delivered
+func (l *linkListener) listen(ctx context.Context, config *pgx.ConnConfig, listening func()) error {
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("listen for link changes: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+linkChangesChannel+";"); err != nil {
+		return fmt.Errorf("listen for link changes: %w", err)
+	}
+	log.Printf("[database:linkListener] Listening for link changes")
+	listening()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("listen for link changes: %w", err)
+		}
+
+		var change linkChange
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			log.Printf("[database:linkListener] Invalid notification {%s}: %v", notification.Payload, err)
+			continue
+		}
+		for _, subscriber := range l.subscribersOf(change.Schema) {
+			subscriber.changed(change.ShortCode)
+		}
+	}
+}
+
+// missed tells every subscriber that changes may have been lost
+func (l *linkListener) missed() {
+	for _, subscriber := range l.subscribersOf("") {
+		subscriber.missed()
+	}
+}
+
+// subscribersOf returns the subscribers of schemaName, all of them when it is empty. They are called outside the
+// lock, so they may subscribe or unsubscribe themselves
+func (l *linkListener) subscribersOf(schemaName string) []*linkSubscriber {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var subscribers []*linkSubscriber
+	for name, ofSchema := range l.subscribers {
+		if schemaName != "" && name != schemaName {
+			continue
+		}
+		for subscriber := range ofSchema {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+	return subscribers
+}
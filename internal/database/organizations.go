@@ -0,0 +1,407 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/pagination"
+)
+
+func (s *service) CreateOrganization(organizationModel *OrganizationModel, ownerId int) (*OrganizationModel, error) {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		log.Printf("[database:CreateOrganization] Could not begin transaction: %v", err)
+		return nil, fmt.Errorf("create organization: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	inserted := &OrganizationModel{Role: OrgRoleOwner}
+	err = tx.QueryRow(context.Background(), "INSERT INTO organizations (name) VALUES ($1) RETURNING id, name, plan, created_at;", organizationModel.Name).Scan(&inserted.Id, &inserted.Name, &inserted.Plan, &inserted.CreatedAt)
+	if err != nil {
+		log.Printf("[database:CreateOrganization] Error inserting organization: %v", err)
+		return nil, fmt.Errorf("create organization: %w", err)
+	}
+
+	_, err = tx.Exec(context.Background(), "INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3);", inserted.Id, ownerId, OrgRoleOwner)
+	if err != nil {
+		log.Printf("[database:CreateOrganization] Error inserting owner: %v", err)
+		return nil, fmt.Errorf("create organization: %w", err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("create organization: %w", err)
+	}
+
+	log.Printf("[database:CreateOrganization] Created organization {%d} owned by user {%d}", inserted.Id, ownerId)
+
+	return inserted, nil
+}
+
+func (s *service) ListOrganizations(userId int) ([]*OrganizationModel, error) {
+	query := `SELECT o.id, o.name, o.plan, o.created_at, m.role
+		FROM organizations AS o
+		JOIN organization_members AS m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.id;`
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		log.Printf("[database:ListOrganizations] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list organizations of user %d: %w", userId, err)
+	}
+	defer rows.Close()
+
+	organizations := []*OrganizationModel{}
+	for rows.Next() {
+		organization := &OrganizationModel{}
+		if err := rows.Scan(&organization.Id, &organization.Name, &organization.Plan, &organization.CreatedAt, &organization.Role); err != nil {
+			log.Printf("[database:ListOrganizations] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list organizations of user %d: %w", userId, err)
+		}
+		organizations = append(organizations, organization)
+	}
+
+	return organizations, rows.Err()
+}
+
+func (s *service) GetOrganization(id int) (*OrganizationModel, error) {
+	organization := &OrganizationModel{}
+	err := s.db.QueryRow(context.Background(), "SELECT id, name, plan, created_at FROM organizations WHERE id = $1;", id).Scan(&organization.Id, &organization.Name, &organization.Plan, &organization.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get organization %d: %w", id, notFound(err))
+	}
+
+	return organization, nil
+}
+
+func (s *service) UpdateOrganizationPlan(id int, plan string) (*OrganizationModel, error) {
+	log.Printf("[database:UpdateOrganizationPlan] Moving organization {%d} to plan {%s}", id, plan)
+
+	organization := &OrganizationModel{}
+	err := s.db.QueryRow(context.Background(), "UPDATE organizations SET plan = $2 WHERE id = $1 RETURNING id, name, plan, created_at;", id, plan).Scan(&organization.Id, &organization.Name, &organization.Plan, &organization.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("update plan of organization %d: %w", id, notFound(err))
+	}
+
+	return organization, nil
+}
+
+func (s *service) CountOrganizationLinks(organizationId int, since time.Time) (*LinkCountModel, error) {
+	count, err := s.countLinks("organization_id", organizationId, since)
+	if err != nil {
+		return nil, fmt.Errorf("count links of organization %d: %w", organizationId, err)
+	}
+	return count, nil
+}
+
+// memberColumns must be kept in sync with scanMember
+const memberColumns = "m.organization_id, m.user_id, u.email, m.role, m.created_at"
+
+func scanMember(row scanner) (*MemberModel, error) {
+	member := &MemberModel{}
+	if err := row.Scan(&member.OrganizationId, &member.UserId, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (s *service) GetMembership(organizationId int, userId int) (*MemberModel, error) {
+	query := "SELECT " + memberColumns + " FROM organization_members AS m JOIN users AS u ON u.id = m.user_id WHERE m.organization_id = $1 AND m.user_id = $2;"
+
+	member, err := scanMember(s.db.QueryRow(context.Background(), query, organizationId, userId))
+	if err != nil {
+		return nil, fmt.Errorf("get membership of user %d in organization %d: %w", userId, organizationId, notFound(err))
+	}
+
+	return member, nil
+}
+
+func (s *service) ListMembers(organizationId int) ([]*MemberModel, error) {
+	query := "SELECT " + memberColumns + " FROM organization_members AS m JOIN users AS u ON u.id = m.user_id WHERE m.organization_id = $1 ORDER BY m.created_at;"
+
+	rows, err := s.db.Query(context.Background(), query, organizationId)
+	if err != nil {
+		log.Printf("[database:ListMembers] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list members of organization %d: %w", organizationId, err)
+	}
+	defer rows.Close()
+
+	members := []*MemberModel{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			log.Printf("[database:ListMembers] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list members of organization %d: %w", organizationId, err)
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (s *service) RemoveMember(organizationId int, userId int) error {
+	log.Printf("[database:RemoveMember] Removing user {%d} from organization {%d}", userId, organizationId)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("remove user %d from organization %d: %w", userId, organizationId, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	// Lock the owners so two owners can't leave at the same time
+	var owners int
+	err = tx.QueryRow(context.Background(), "SELECT COUNT(*) FROM (SELECT 1 FROM organization_members WHERE organization_id = $1 AND role = $2 FOR UPDATE) AS owners;", organizationId, OrgRoleOwner).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("remove user %d from organization %d: %w", userId, organizationId, err)
+	}
+
+	var role string
+	err = tx.QueryRow(context.Background(), "DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2 RETURNING role;", organizationId, userId).Scan(&role)
+	if err != nil {
+		return fmt.Errorf("remove user %d from organization %d: %w", userId, organizationId, notFound(err))
+	}
+
+	if role == OrgRoleOwner && owners <= 1 {
+		return fmt.Errorf("remove user %d from organization %d: %w", userId, organizationId, ErrLastOwner)
+	}
+
+	return tx.Commit(context.Background())
+}
+
+func (s *service) UpdateMemberRole(organizationId int, userId int, role string) (*MemberModel, error) {
+	log.Printf("[database:UpdateMemberRole] Setting role of user {%d} in organization {%d} to {%s}", userId, organizationId, role)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	// Lock the owners so two owners can't demote each other at the same time
+	var owners int
+	err = tx.QueryRow(context.Background(), "SELECT COUNT(*) FROM (SELECT 1 FROM organization_members WHERE organization_id = $1 AND role = $2 FOR UPDATE) AS owners;", organizationId, OrgRoleOwner).Scan(&owners)
+	if err != nil {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, err)
+	}
+
+	var previous string
+	err = tx.QueryRow(context.Background(), "SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2 FOR UPDATE;", organizationId, userId).Scan(&previous)
+	if err != nil {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, notFound(err))
+	}
+
+	if previous == OrgRoleOwner && role != OrgRoleOwner && owners <= 1 {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, ErrLastOwner)
+	}
+
+	if _, err := tx.Exec(context.Background(), "UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2;", organizationId, userId, role); err != nil {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, err)
+	}
+
+	query := "SELECT " + memberColumns + " FROM organization_members AS m JOIN users AS u ON u.id = m.user_id WHERE m.organization_id = $1 AND m.user_id = $2;"
+	member, err := scanMember(tx.QueryRow(context.Background(), query, organizationId, userId))
+	if err != nil {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("update role of user %d in organization %d: %w", userId, organizationId, err)
+	}
+
+	return member, nil
+}
+
+func (s *service) SaveInvitation(invitationModel *InvitationModel) (*InvitationModel, error) {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("save invitation to organization %d: %w", invitationModel.OrganizationId, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+		RETURNING id, organization_id, COALESCE(email, ''), role, token_hash, invited_by, created_at, expires_at;`
+
+	inserted := &InvitationModel{}
+	err = tx.QueryRow(context.Background(), query, invitationModel.OrganizationId, invitationModel.Email, invitationModel.Role, invitationModel.TokenHash, invitationModel.InvitedBy, invitationModel.ExpiresAt).
+		Scan(&inserted.Id, &inserted.OrganizationId, &inserted.Email, &inserted.Role, &inserted.TokenHash, &inserted.InvitedBy, &inserted.CreatedAt, &inserted.ExpiresAt)
+	if err != nil {
+		log.Printf("[database:SaveInvitation] Error inserting invitation: %v", err)
+		return nil, fmt.Errorf("save invitation to organization %d: %w", invitationModel.OrganizationId, err)
+	}
+
+	// Invitees who already have an account hear about it through their notifications
+	if inserted.Email != "" {
+		query = `INSERT INTO notifications (user_id, subject, body)
+			SELECT u.id, 'Invitation to ' || o.name, 'You have been invited to join ' || o.name || ' as ' || $3 || '.'
+			FROM users AS u, organizations AS o
+			WHERE u.email = $1 AND o.id = $2;`
+
+		if _, err := tx.Exec(context.Background(), query, inserted.Email, inserted.OrganizationId, inserted.Role); err != nil {
+			log.Printf("[database:SaveInvitation] Could not notify the invitee: %v", err)
+			return nil, fmt.Errorf("save invitation to organization %d: %w", invitationModel.OrganizationId, err)
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("save invitation to organization %d: %w", invitationModel.OrganizationId, err)
+	}
+
+	return inserted, nil
+}
+
+func (s *service) AcceptInvitation(tokenHash string, userId int) (*MemberModel, error) {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("accept invitation: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `SELECT i.id, i.organization_id, COALESCE(i.email, ''), i.role, u.email
+		FROM organization_invitations AS i, users AS u
+		WHERE i.token_hash = $1 AND u.id = $2 AND i.accepted_at IS NULL AND NOW() < i.expires_at
+		FOR UPDATE OF i;`
+
+	invitation := &InvitationModel{}
+	var userEmail string
+	err = tx.QueryRow(context.Background(), query, tokenHash, userId).Scan(&invitation.Id, &invitation.OrganizationId, &invitation.Email, &invitation.Role, &userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("accept invitation: %w", notFound(err))
+	}
+
+	if invitation.Email != "" && invitation.Email != userEmail {
+		return nil, fmt.Errorf("accept invitation %d: %w", invitation.Id, ErrInvitationEmail)
+	}
+
+	// Accepting again, e.g. a second link invitation, keeps the current role
+	query = `INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO NOTHING;`
+	if _, err := tx.Exec(context.Background(), query, invitation.OrganizationId, userId, invitation.Role); err != nil {
+		log.Printf("[database:AcceptInvitation] Error inserting member: %v", err)
+		return nil, fmt.Errorf("accept invitation %d: %w", invitation.Id, err)
+	}
+
+	// Link invitations can be shared with the whole team, only the ones naming an email are used up
+	if invitation.Email != "" {
+		if _, err := tx.Exec(context.Background(), "UPDATE organization_invitations SET accepted_at = NOW() WHERE id = $1;", invitation.Id); err != nil {
+			return nil, fmt.Errorf("accept invitation %d: %w", invitation.Id, err)
+		}
+	}
+
+	query = "SELECT " + memberColumns + " FROM organization_members AS m JOIN users AS u ON u.id = m.user_id WHERE m.organization_id = $1 AND m.user_id = $2;"
+	member, err := scanMember(tx.QueryRow(context.Background(), query, invitation.OrganizationId, userId))
+	if err != nil {
+		return nil, fmt.Errorf("accept invitation %d: %w", invitation.Id, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("accept invitation %d: %w", invitation.Id, err)
+	}
+
+	log.Printf("[database:AcceptInvitation] User {%d} joined organization {%d}", userId, invitation.OrganizationId)
+
+	return member, nil
+}
+
+const invitationColumns = "id, organization_id, COALESCE(email, ''), role, token_hash, invited_by, created_at, expires_at, accepted_at"
+
+func scanInvitation(row scanner) (*InvitationModel, error) {
+	invitation := &InvitationModel{}
+	if err := row.Scan(&invitation.Id, &invitation.OrganizationId, &invitation.Email, &invitation.Role, &invitation.TokenHash, &invitation.InvitedBy, &invitation.CreatedAt, &invitation.ExpiresAt, &invitation.AcceptedAt); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *service) ListInvitations(organizationId int) ([]*InvitationModel, error) {
+	query := "SELECT " + invitationColumns + ` FROM organization_invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL AND NOW() < expires_at
+		ORDER BY created_at DESC, id DESC;`
+
+	rows, err := s.db.Query(context.Background(), query, organizationId)
+	if err != nil {
+		log.Printf("[database:ListInvitations] Error listing invitations: %v", err)
+		return nil, fmt.Errorf("list invitations of organization %d: %w", organizationId, err)
+	}
+	defer rows.Close()
+
+	invitations := []*InvitationModel{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list invitations of organization %d: %w", organizationId, err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (s *service) GetInvitation(organizationId int, id int) (*InvitationModel, error) {
+	query := "SELECT " + invitationColumns + " FROM organization_invitations WHERE organization_id = $1 AND id = $2;"
+
+	invitation, err := scanInvitation(s.db.QueryRow(context.Background(), query, organizationId, id))
+	if err != nil {
+		return nil, fmt.Errorf("get invitation %d: %w", id, notFound(err))
+	}
+
+	return invitation, nil
+}
+
+func (s *service) RenewInvitation(organizationId int, id int, tokenHash string, expiresAt time.Time) (*InvitationModel, error) {
+	query := `UPDATE organization_invitations SET token_hash = $3, expires_at = $4
+		WHERE organization_id = $1 AND id = $2 AND accepted_at IS NULL
+		RETURNING ` + invitationColumns + ";"
+
+	invitation, err := scanInvitation(s.db.QueryRow(context.Background(), query, organizationId, id, tokenHash, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("renew invitation %d: %w", id, notFound(err))
+	}
+
+	return invitation, nil
+}
+
+func (s *service) RevokeInvitation(organizationId int, id int) (*InvitationModel, error) {
+	query := "DELETE FROM organization_invitations WHERE organization_id = $1 AND id = $2 AND accepted_at IS NULL RETURNING " + invitationColumns + ";"
+
+	invitation, err := scanInvitation(s.db.QueryRow(context.Background(), query, organizationId, id))
+	if err != nil {
+		return nil, fmt.Errorf("revoke invitation %d: %w", id, notFound(err))
+	}
+
+	log.Printf("[database:RevokeInvitation] Revoked invitation {%d} of organization {%d}", id, organizationId)
+
+	return invitation, nil
+}
+
+func (s *service) ListOrganizationLinks(organizationId int, page pagination.Page) ([]*ShortUrlModel, error) {
+	query := "SELECT " + shortUrlColumns + " FROM short_url WHERE organization_id = $1"
+	args := []any{organizationId}
+	if page.After != nil {
+		query += " AND (created_at, id) < ($2, $3)"
+		args = append(args, page.After.CreatedAt, page.After.Id)
+	}
+	args = append(args, page.Fetch())
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d;", len(args))
+
+	rows, err := s.read.Query(context.Background(), query, args...)
+	if err != nil {
+		log.Printf("[database:ListOrganizationLinks] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list links of organization %d: %w", organizationId, err)
+	}
+	defer rows.Close()
+
+	shortUrls := []*ShortUrlModel{}
+	for rows.Next() {
+		shortUrl, err := scanShortUrl(rows)
+		if err != nil {
+			log.Printf("[database:ListOrganizationLinks] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list links of organization %d: %w", organizationId, err)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+
+	return shortUrls, rows.Err()
+}
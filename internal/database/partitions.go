@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListClickPartitions reads the bounds of each partition from the catalog. The default partition has none and is
+// left out
+func (s *service) ListClickPartitions() ([]*ClickPartitionModel, error) {
+	query := `SELECT child.relname,
+			NULLIF(trim(both '''' from bounds[1]), 'MINVALUE')::timestamptz,
+			trim(both '''' from bounds[2])::timestamptz
+		FROM pg_inherits
+		JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid,
+		LATERAL regexp_match(pg_get_expr(child.relpartbound, child.oid), 'FROM \((.+)\) TO \((.+)\)') AS bounds
+		WHERE parent.relname = 'click_events' AND parent.relnamespace = to_regnamespace(current_schema()) AND bounds IS NOT NULL
+		ORDER BY 3;`
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("list click partitions: %w", err)
+	}
+	defer rows.Close()
+
+	partitions := []*ClickPartitionModel{}
+	for rows.Next() {
+		partition := &ClickPartitionModel{}
+		if err := rows.Scan(&partition.Name, &partition.From, &partition.To); err != nil {
+			return nil, fmt.Errorf("list click partitions: %w", err)
+		}
+		partitions = append(partitions, partition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list click partitions: %w", err)
+	}
+
+	return partitions, nil
+}
+
+func (s *service) CreateClickPartition(from time.Time, to time.Time) (*ClickPartitionModel, error) {
+	partition := &ClickPartitionModel{Name: "click_events_" + from.UTC().Format("20060102"), From: &from, To: to}
+
+	// Bounds can't be sent as parameters. Formatted as RFC 3339 they hold no quote to escape
+	query := fmt.Sprintf("CREATE TABLE %s PARTITION OF click_events FOR VALUES FROM ('%s') TO ('%s');",
+		pgx.Identifier{partition.Name}.Sanitize(), from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if _, err := s.db.Exec(context.Background(), query); err != nil {
+		log.Printf("[database:CreateClickPartition] Could not create {%s}: %v", partition.Name, err)
+		return nil, fmt.Errorf("create click partition %s: %w", partition.Name, err)
+	}
+
+	log.Printf("[database:CreateClickPartition] Created {%s} from {%s} to {%s}", partition.Name, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	return partition, nil
+}
+
+// DropClickPartition detaches the partition, then drops it along with its click events
+func (s *service) DropClickPartition(name string) error {
+	table := pgx.Identifier{name}.Sanitize()
+
+	if s.dryRun {
+		log.Printf("[database:DropClickPartition] Dry run, would drop {%s}", name)
+		return nil
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("drop click partition %s: %w", name, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(context.Background(), "ALTER TABLE click_events DETACH PARTITION "+table+";"); err != nil {
+		log.Printf("[database:DropClickPartition] Could not detach {%s}: %v", name, err)
+		return fmt.Errorf("drop click partition %s: %w", name, err)
+	}
+	if _, err := tx.Exec(context.Background(), "DROP TABLE "+table+";"); err != nil {
+		return fmt.Errorf("drop click partition %s: %w", name, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return fmt.Errorf("drop click partition %s: %w", name, err)
+	}
+
+	log.Printf("[database:DropClickPartition] Dropped {%s}", name)
+
+	return nil
+}
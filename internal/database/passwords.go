@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (s *service) GetUserPassword(email string) (*UserPasswordModel, error) {
+	query := "SELECT id, email, role, plan, created_at, email_verified_at, COALESCE(password_hash, '') FROM users WHERE LOWER(email) = LOWER($1);"
+
+	user := &UserPasswordModel{User: &UserModel{}}
+	err := s.db.QueryRow(context.Background(), query, email).Scan(&user.User.Id, &user.User.Email, &user.User.Role, &user.User.Plan, &user.User.CreatedAt, &user.User.EmailVerifiedAt, &user.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("get password of %s: %w", email, notFound(err))
+	}
+
+	return user, nil
+}
+
+func (s *service) SetPassword(userId int, passwordHash string) error {
+	result, err := s.db.Exec(context.Background(), "UPDATE users SET password_hash = $2 WHERE id = $1;", userId, passwordHash)
+	if err != nil {
+		log.Printf("[database:SetPassword] Could not set the password of user {%d}: %v", userId, err)
+		return fmt.Errorf("set password of user %d: %w", userId, err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("set password of user %d: %w", userId, ErrNotFound)
+	}
+
+	log.Printf("[database:SetPassword] Set the password of user {%d}", userId)
+
+	return nil
+}
+
+func (s *service) SavePasswordReset(resetModel *PasswordResetModel) (*PasswordResetModel, error) {
+	query := `INSERT INTO password_resets (user_id, token_hash, ip, expires_at) VALUES ($1, $2, NULLIF($3, ''), $4)
+		RETURNING id, user_id, token_hash, COALESCE(ip, ''), created_at, expires_at;`
+
+	inserted := &PasswordResetModel{}
+	err := s.db.QueryRow(context.Background(), query, resetModel.UserId, resetModel.TokenHash, resetModel.Ip, resetModel.ExpiresAt).
+		Scan(&inserted.Id, &inserted.UserId, &inserted.TokenHash, &inserted.Ip, &inserted.CreatedAt, &inserted.ExpiresAt)
+	if err != nil {
+		log.Printf("[database:SavePasswordReset] Error inserting password reset: %v", err)
+		return nil, fmt.Errorf("save password reset of user %d: %w", resetModel.UserId, err)
+	}
+
+	return inserted, nil
+}
+
+func (s *service) ResetPassword(tokenHash string, passwordHash string) (*UserModel, error) {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("reset password: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `SELECT user_id FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND NOW() < expires_at
+		FOR UPDATE;`
+
+	var userId int
+	if err := tx.QueryRow(context.Background(), query, tokenHash).Scan(&userId); err != nil {
+		return nil, fmt.Errorf("reset password: %w", notFound(err))
+	}
+
+	// A link sent earlier must not set the password again
+	if _, err := tx.Exec(context.Background(), "UPDATE password_resets SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL;", userId); err != nil {
+		return nil, fmt.Errorf("reset password of user %d: %w", userId, err)
+	}
+
+	// The token was emailed, using it proves owning the email
+	query = `UPDATE users SET password_hash = $2, email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1
+		RETURNING id, email, role, plan, created_at, email_verified_at;`
+	user := &UserModel{}
+	if err := tx.QueryRow(context.Background(), query, userId, passwordHash).Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt); err != nil {
+		return nil, fmt.Errorf("reset password of user %d: %w", userId, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("reset password of user %d: %w", userId, err)
+	}
+
+	log.Printf("[database:ResetPassword] Reset the password of user {%d}", userId)
+
+	return user, nil
+}
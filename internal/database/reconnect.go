@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortner/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// A pool is reset at most this often, so a database that stays down doesn't churn connections
+const minResetInterval = 5 * time.Second
+
+// poolMonitor drops the connections of a pool once they stop being usable, e.g. after a failover left them on a
+// demoted primary or on an address the hostname no longer resolves to. New connections resolve the host again.
+// It also pings the pool periodically and reports its stats as metrics
+type poolMonitor struct {
+	name string
+	pool *pgxpool.Pool
+
+	mu        sync.Mutex
+	lastReset time.Time
+
+	reconnects  atomic.Int64
+	reconnected atomic.Bool
+
+	stop context.CancelFunc
+}
+
+// monitorPool registers the hooks of the monitor on config, to be called before the pool is created
+func monitorPool(name string, config *pgxpool.Config) *poolMonitor {
+	m := &poolMonitor{name: name}
+
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		metrics.Count("db.connections", 1, "pool:"+name)
+		if m.reconnected.CompareAndSwap(true, false) {
+			log.Printf("[database:monitorPool] Reconnected the {%s} pool to {%s}", name, conn.PgConn().Conn().RemoteAddr())
+		}
+		return nil
+	}
+
+	return m
+}
+
+// start watches pool every interval until close
+func (m *poolMonitor) start(pool *pgxpool.Pool, interval time.Duration) {
+	m.pool = pool
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stop = cancel
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.check(ctx)
+			}
+		}
+	}()
+}
+
+func (m *poolMonitor) check(ctx context.Context) {
+	stats := m.pool.Stat()
+	tags := []string{"pool:" + m.name}
+	metrics.Gauge("db.pool.total", float64(stats.TotalConns()), tags...)
+	metrics.Gauge("db.pool.in_use", float64(stats.AcquiredConns()), tags...)
+	metrics.Gauge("db.pool.idle", float64(stats.IdleConns()), tags...)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	// Acquires a connection, so a dead one is found and replaced before a request gets it
+	if err := m.pool.Ping(ctx); err != nil && ctx.Err() == nil {
+		m.reset(err)
+	}
+}
+
+// observe resets the pool when err tells its connections are gone or on the wrong server
+func (m *poolMonitor) observe(err error) {
+	if m != nil && needsReconnect(err) {
+		m.reset(err)
+	}
+}
+
+func (m *poolMonitor) reset(cause error) {
+	m.mu.Lock()
+	if time.Since(m.lastReset) < minResetInterval {
+		m.mu.Unlock()
+		return
+	}
+	m.lastReset = time.Now()
+	m.mu.Unlock()
+
+	log.Printf("[database:reset] Dropping the connections of the {%s} pool: %v", m.name, cause)
+	metrics.Count("db.reconnects", 1, "pool:"+m.name)
+	m.reconnects.Add(1)
+	m.reconnected.Store(true)
+
+	// Idle connections are closed now, those in use once released
+	m.pool.Reset()
+}
+
+func (m *poolMonitor) close() {
+	if m != nil && m.stop != nil {
+		m.stop()
+	}
+}
+
+// needsReconnect tells the errors of a connection that is broken or that reached a server which can no longer take
+// writes, from those of a single query. Timeouts and cancellations belong to the query
+func needsReconnect(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 25006 is a write on a primary demoted to a replica, class 08 connection exceptions and 57P shutdowns
+		return pgErr.Code == "25006" || strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
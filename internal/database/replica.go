@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"url-shortner/internal/breaker"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// readDB sends queries to the read replica and falls back to the primary when the replica is unavailable.
+// Writes and transactions always go to the primary
+type readDB struct {
+	replica *guardedDB
+	primary dbtx
+}
+
+// newReplica connects to BLUEPRINT_DB_REPLICA_URL with the same pool settings as the primary
+func newReplica() (*pgxpool.Pool, *breaker.Breaker, *poolMonitor, error) {
+	config, err := pgxpool.ParseConfig(replicaUrl)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid BLUEPRINT_DB_REPLICA_URL: %w", err)
+	}
+	if err := configurePool(config); err != nil {
+		return nil, nil, nil, err
+	}
+	interval, err := poolMonitorInterval()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	monitor := monitorPool("replica", config)
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	replicaBreaker, err := newBreaker()
+	if err != nil {
+		pool.Close()
+		return nil, nil, nil, err
+	}
+	monitor.start(pool, interval)
+
+	return pool, replicaBreaker, monitor, nil
+}
+
+// shouldFallback reports whether a replica error is worth retrying on the primary
+func shouldFallback(err error) bool {
+	return errors.Is(err, breaker.ErrOpen) || isUnavailable(err)
+}
+
+func (r *readDB) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return r.primary.Exec(ctx, sql, arguments...)
+}
+
+func (r *readDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return r.primary.Begin(ctx)
+}
+
+func (r *readDB) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return r.primary.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (r *readDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := r.replica.Query(ctx, sql, args...)
+	if shouldFallback(err) {
+		log.Printf("[database:readDB] Replica unavailable, querying the primary: %v", err)
+		return r.primary.Query(ctx, sql, args...)
+	}
+
+	return rows, err
+}
+
+func (r *readDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &replicaRow{db: r, ctx: ctx, sql: sql, args: args}
+}
+
+// replicaRow runs the query when scanned, so a failing replica can be retried on the primary.
+// A missing row is retried as well since the replica may not have caught up with a recent write yet
+type replicaRow struct {
+	db   *readDB
+	ctx  context.Context
+	sql  string
+	args []any
+}
+
+func (r *replicaRow) Scan(dest ...any) error {
+	err := r.db.replica.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if !shouldFallback(err) && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[database:readDB] Replica unavailable, querying the primary: %v", err)
+	}
+
+	return r.db.primary.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+}
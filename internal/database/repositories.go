@@ -0,0 +1,541 @@
+package database
+
+import (
+	"time"
+
+	"url-shortner/internal/pagination"
+)
+
+// LinkRepository stores the short urls themselves.
+type LinkRepository interface {
+	// Insert into database. Links with a Review are saved disabled, queued for review in the same transaction, as
+	// are those of SaveShortUrls and UpsertShortUrl
+	SaveShortUrl(*ShortUrlModel) (*ShortUrlModel, error)
+
+	// Insert many short urls at once with COPY. Those whose short code is taken are skipped: the ones inserted are
+	// returned in the order they were given
+	SaveShortUrls([]*ShortUrlModel) ([]*ShortUrlModel, error)
+
+	// Insert a short url, or update the destination and expiry of the one with its code when it has the same owner
+	// or organization, the expiry then counting from the update. It reports whether the link was created, and fails
+	// with ErrDisabled when the link is disabled, e.g. under review, or ErrDuplicateCode when it belongs to someone else
+	UpsertShortUrl(*ShortUrlModel) (*ShortUrlModel, bool, error)
+
+	// Get the Shortned URL entity
+	GetShortUrl(shortCode string) (*ShortUrlModel, error)
+
+	// Return which of the short codes are already taken, expired and disabled links included
+	TakenShortCodes(shortCodes []string) ([]string, error)
+
+	// List the short codes of the links created since a point in time, every code for the zero time
+	ListShortCodes(since time.Time) ([]string, error)
+
+	// Update the shortned URL times_cliecked attribute
+	UpdateTimesClicked(shortCode string) error
+
+	// Delete expired links
+	DeleteExpiredLinks() error
+
+	// Find the short urls pointing at a destination, compared in its normalized form
+	FindShortUrlsByLink(link string) ([]*ShortUrlModel, error)
+
+	// List a page of short urls regardless of owner, newest first. Up to page.Fetch() rows are returned
+	// so the caller can tell whether another page follows, see pagination.Trim
+	ListShortUrls(page pagination.Page) ([]*ShortUrlModel, error)
+
+	// List links that are neither expired nor disabled with an id greater than afterId, ordered by id
+	ListActiveShortUrls(afterId int, limit int) ([]*ShortUrlModel, error)
+
+	// Delete a short url by its short code
+	DeleteShortUrl(shortCode string) error
+
+	// Get when a short url was last deleted, for the Last-Modified of listings a deletion changed
+	LastShortUrlDeletion() (time.Time, error)
+
+	// Hard delete a short url and everything recorded about it in a single transaction
+	PurgeShortUrl(shortCode string) (*PurgeSummaryModel, error)
+
+	// Count the links a user created since a point in time, along with its active links
+	CountUserLinks(userId int, since time.Time) (*LinkCountModel, error)
+
+	// Count the links created with an api key since a point in time, along with its active links
+	CountApiKeyLinks(apiKeyId int, since time.Time) (*LinkCountModel, error)
+
+	// Count the links created for an organization since a point in time, along with its active links
+	CountOrganizationLinks(organizationId int, since time.Time) (*LinkCountModel, error)
+
+	// Count the links created without an owner nor an api key since a point in time
+	CountAnonymousLinks(since time.Time) (int, error)
+}
+
+// ClickRepository records redirects and aggregates them.
+type ClickRepository interface {
+	// Record a redirect
+	RecordClick(*ClickEventModel) error
+
+	// Record many redirects at once with COPY and count them, returning how many were stored
+	RecordClicks([]*ClickEventModel) (int64, error)
+
+	// Click totals of a short url, with unique visitors and clicks per day over the last days
+	GetLinkStats(shortCode string, days int) (*LinkStatsModel, error)
+
+	// Aggregated numbers across the whole instance
+	GlobalStats() (*GlobalStatsModel, error)
+
+	// Link and click totals with the links created per day over the last days, of the public links only
+	GetLinkSummary(days int) (*LinkSummaryModel, error)
+
+	// Clear the raw ips of click events older than the retention period
+	PurgeRawIps(retention time.Duration) (int64, error)
+
+	// Delete the click events older than before of the links in scope
+	PurgeClickEvents(scope AnalyticsScope, before time.Time) (int64, error)
+
+	// Count the click events made since a point in time into daily rollups
+	RollupClicks(since time.Time) (int64, error)
+
+	// Delete the daily rollups of days before before of the links in scope
+	PurgeClickRollups(scope AnalyticsScope, before time.Time) (int64, error)
+
+	// List the partitions of the click events, oldest first
+	ListClickPartitions() ([]*ClickPartitionModel, error)
+
+	// Create the partition of the clicks made from from until to
+	CreateClickPartition(from time.Time, to time.Time) (*ClickPartitionModel, error)
+
+	// Drop a partition along with its click events
+	DropClickPartition(name string) error
+
+	// Refresh the materialized views of the heavy stats
+	RefreshStatsViews() error
+
+	// Links and clicks of the most clicked destination domains, from the view unless it is older than maxAge.
+	// Along with the time the numbers are from
+	DomainStats(limit int, maxAge time.Duration) ([]*DomainStatsModel, time.Time, error)
+
+	// The most clicked links, from the view unless it is older than maxAge. Along with the time the numbers are from
+	Leaderboard(limit int, maxAge time.Duration) ([]*LeaderboardEntryModel, time.Time, error)
+}
+
+// UserRepository manages users and their api keys.
+type UserRepository interface {
+	// Create a user
+	SaveUser(*UserModel) (*UserModel, error)
+
+	// List every user
+	ListUsers() ([]*UserModel, error)
+
+	// Delete a user and its api keys
+	DeleteUser(id int) error
+
+	// Change the role of a user
+	UpdateUserRole(id int, role string) (*UserModel, error)
+
+	// Move a user to another plan
+	UpdateUserPlan(id int, plan string) (*UserModel, error)
+
+	// Create an api key. Only the hash of the key is stored
+	SaveApiKey(*ApiKeyModel) (*ApiKeyModel, error)
+
+	// List the api keys of a user
+	ListApiKeys(userId int) ([]*ApiKeyModel, error)
+
+	// Revoke an api key so it can no longer authenticate
+	RevokeApiKey(id int) error
+
+	// Revoke every api key of a user with a name, e.g. those issued by a sign in provider. It returns how many
+	// were revoked
+	RevokeApiKeysNamed(userId int, name string) (int64, error)
+
+	// Get a non revoked and non expired api key along with the user owning it
+	GetApiKeyByHash(keyHash string) (*ApiKeyModel, error)
+
+	// Get an api key, revoked or not
+	GetApiKey(id int) (*ApiKeyModel, error)
+
+	// Add requests and created links to today's usage of an api key, returning the updated counters
+	AddApiKeyUsage(apiKeyId int, requests int, linksCreated int) (*ApiKeyUsageModel, error)
+
+	// List the daily usage of an api key since a day, oldest first. Days without usage are left out
+	ListApiKeyUsage(apiKeyId int, since time.Time) ([]*ApiKeyUsageModel, error)
+
+	// Get the user an OAuth identity is linked to. On the first sign in the identity is linked to the user
+	// with the same email, created when there is none
+	GetOrCreateUserByIdentity(*UserIdentityModel) (*UserModel, error)
+
+	// List the OAuth identities linked to a user
+	ListUserIdentities(userId int) ([]*UserIdentityModel, error)
+}
+
+// PasswordRepository manages the passwords of the users and their resets.
+type PasswordRepository interface {
+	// Get a user by email, case insensitively, along with its password hash. It returns ErrNotFound for unknown
+	// emails
+	GetUserPassword(email string) (*UserPasswordModel, error)
+
+	// Set the password hash of a user
+	SetPassword(userId int, passwordHash string) error
+
+	// Store a password reset. Only the hash of its token is stored
+	SavePasswordReset(*PasswordResetModel) (*PasswordResetModel, error)
+
+	// Set the password of the user of a pending reset, using up every pending reset of the user, and verify its
+	// email. It returns ErrNotFound for unknown, expired or used resets
+	ResetPassword(tokenHash string, passwordHash string) (*UserModel, error)
+}
+
+// VerificationRepository creates the accounts signing up with a password and verifies their email.
+type VerificationRepository interface {
+	// Create an editor with a password and an email yet to verify. An email already in use fails with a
+	// ConflictError
+	SignUp(email string, passwordHash string) (*UserModel, error)
+
+	// Store an email verification. Only the hash of its token is stored
+	SaveEmailVerification(*EmailVerificationModel) (*EmailVerificationModel, error)
+
+	// Verify the email of the user of a pending verification, if it still is the email it was sent to. It returns
+	// ErrNotFound for unknown, expired or used verifications, and those sent to a previous email
+	VerifyEmail(tokenHash string) (*UserModel, error)
+}
+
+// SessionRepository stores the sessions of the dashboard.
+type SessionRepository interface {
+	// Store a session. Only the hash of its token is stored
+	SaveSession(*SessionModel) (*SessionModel, error)
+
+	// Get the session with the token hash, with its user, and record it was seen. It returns ErrNotFound for
+	// unknown, revoked and expired sessions, and those unseen for idleTimeout
+	GetSessionByHash(tokenHash string, idleTimeout time.Duration) (*SessionModel, error)
+
+	// List the sessions of a user still active, the most recently seen first
+	ListSessions(userId int, idleTimeout time.Duration) ([]*SessionModel, error)
+
+	// Revoke a session of a user. It returns ErrNotFound when the user has no such active session
+	RevokeSession(userId int, id int) error
+
+	// Revoke every session of a user and return how many were
+	RevokeSessions(userId int) (int64, error)
+
+	// Delete the sessions past their expiry or revoked. Idle sessions are deleted once they expire
+	DeleteEndedSessions() (int64, error)
+}
+
+// ClaimRepository lets the creators of anonymous links attach them to the account they sign up for.
+type ClaimRepository interface {
+	// Store the claim of a link. Only the hash of its token is stored
+	SaveLinkClaim(*LinkClaimModel) error
+
+	// Make the user the owner of the link of a claim and delete the claim, along with the edit token of the link. It
+	// returns ErrNotFound for unknown or used claims, and links owned by someone else since
+	ClaimLink(tokenHash string, userId int) (*ShortUrlModel, error)
+}
+
+// EditTokenRepository lets the creators of anonymous links change or delete them without an account.
+type EditTokenRepository interface {
+	// Store the edit token of a link. Only its hash is stored
+	SaveLinkEditToken(*LinkEditTokenModel) error
+
+	// Get the short url of a code when the token hash is its edit token. It returns ErrNotFound otherwise, and for
+	// links claimed into an account since
+	GetShortUrlByEditToken(shortCode string, tokenHash string) (*ShortUrlModel, error)
+
+	// Point a short url to another link and change its expiry, still counted from its creation. A review, when
+	// given, is queued in the same transaction and leaves the link disabled. It returns ErrNotFound for unknown and
+	// disabled links
+	EditShortUrl(shortCode string, link string, expTimeMinutes int, review *ReviewModel) (*ShortUrlModel, error)
+}
+
+// DigestRepository collects the weekly digests of the links of the users, and who wants them.
+type DigestRepository interface {
+	// Get what a user agreed to receive by email
+	GetEmailPreferences(userId int) (*EmailPreferencesModel, error)
+
+	// Change what a user agreed to receive by email
+	UpdateEmailPreferences(*EmailPreferencesModel) (*EmailPreferencesModel, error)
+
+	// List up to limit users past afterId, by id, who want the weekly digest and weren't sent one since sentBefore
+	ListDigestRecipients(sentBefore time.Time, afterId int, limit int) ([]*UserModel, error)
+
+	// Sum up the clicks on the links of a user between since and until, from the daily rollups, and list those
+	// expiring in the week after until. top bounds the links and referrers listed
+	GetLinkDigest(userId int, since time.Time, until time.Time, top int) (*LinkDigestModel, error)
+
+	// Record that a user was sent their digest
+	MarkDigestSent(userId int, at time.Time) error
+}
+
+// NotificationRepository reads the outbox of messages for users, filled by moderation and invitations.
+type NotificationRepository interface {
+	// List up to limit notifications past afterId, by id, not sent yet
+	ListUnsentNotifications(afterId int, limit int) ([]*NotificationModel, error)
+
+	// Record that a notification was sent
+	MarkNotificationSent(id int, at time.Time) error
+}
+
+// PrivacyRepository handles the data export and erasure requests of users.
+type PrivacyRepository interface {
+	// Collect every piece of data stored about a user
+	ExportUserData(userId int) (*UserExportModel, error)
+
+	// Ask for a user to be erased. Processing happens asynchronously
+	RequestUserDeletion(userId int) (*DeletionRequestModel, error)
+
+	// Get the latest deletion request of a user
+	GetDeletionRequest(userId int) (*DeletionRequestModel, error)
+
+	// Erase the users with a pending deletion request, returning how many were erased
+	ProcessDeletionRequests() (int, error)
+}
+
+// BlocklistRepository manages the banned destination domains.
+type BlocklistRepository interface {
+	// Ban a destination domain or pattern
+	SaveBannedDomain(*BannedDomainModel) (*BannedDomainModel, error)
+
+	// List every banned domain pattern
+	ListBannedDomains() ([]*BannedDomainModel, error)
+
+	// Remove a domain from the blocklist
+	DeleteBannedDomain(id int) error
+
+	// Disable active links pointing at a banned domain, returning how many were disabled
+	DisableBannedLinks() (int64, error)
+}
+
+// DomainRepository manages the hostnames short links are served on, the instance's own and those brought by
+// organizations.
+type DomainRepository interface {
+	// Add a domain. It returns ErrDuplicateDomain when the hostname is already there
+	SaveDomain(*DomainModel) (*DomainModel, error)
+
+	// List every domain, verified or not
+	ListDomains() ([]*DomainModel, error)
+
+	// List the custom domains of an organization
+	ListOrganizationDomains(organizationId int) ([]*DomainModel, error)
+
+	// List the domains waiting for their verification
+	ListUnverifiedDomains() ([]*DomainModel, error)
+
+	// Get a domain by its id
+	GetDomain(id int) (*DomainModel, error)
+
+	// Get a domain by its hostname
+	GetDomainByHostname(hostname string) (*DomainModel, error)
+
+	// Record a lookup of the verification record of a domain, marking it verified when the record was found.
+	// A verified domain stays verified
+	MarkDomainChecked(id int, verified bool) (*DomainModel, error)
+
+	// Replace the branding of the pages of a domain with the one of the model
+	UpdateDomainBranding(*DomainModel) (*DomainModel, error)
+
+	// Delete the domains added before a point in time and still not verified, so a hostname can't be held
+	// by whoever claimed it first
+	DeleteUnverifiedDomains(before time.Time) (int64, error)
+
+	// Remove a domain, the links pinned to it are served on every domain again
+	DeleteDomain(id int) error
+}
+
+// NamespaceRepository manages the namespaces grouping links under a prefix.
+type NamespaceRepository interface {
+	// Create a namespace. It returns ErrDuplicateNamespace when the name is taken
+	SaveNamespace(*NamespaceModel) (*NamespaceModel, error)
+
+	// List every namespace
+	ListNamespaces() ([]*NamespaceModel, error)
+
+	// Get a namespace by its name
+	GetNamespaceByName(name string) (*NamespaceModel, error)
+
+	// Delete a namespace. It returns ErrNamespaceInUse while links belong to it
+	DeleteNamespace(id int) error
+}
+
+// JobRepository is the queue of background jobs.
+type JobRepository interface {
+	// Queue a job, pending until a worker claims it
+	EnqueueJob(*JobModel) (*JobModel, error)
+
+	// Get a job with its result
+	GetJob(id int) (*JobModel, error)
+
+	// Claim the oldest pending job of one of the kinds, marking it running. Jobs left running for longer than
+	// staleAfter, by a worker that died, are claimed again. It returns ErrNotFound when there is nothing to run
+	ClaimJob(kinds []string, staleAfter time.Duration) (*JobModel, error)
+
+	// Record the outcome of a job: done with its result, or failed with jobErr when it isn't empty
+	FinishJob(id int, result []byte, jobErr string) error
+
+	// Attach the file a job produced, e.g. the archive of an account export
+	SaveJobFile(id int, file []byte) error
+
+	// Get the file of a job. It returns ErrNotFound when the job has none
+	GetJobFile(id int) ([]byte, error)
+
+	// Record where the file of a job is kept in the blobstore
+	SaveJobFileKey(id int, key string) error
+
+	// Get where the file of a job is kept in the blobstore, ErrNotFound when the job has none there
+	GetJobFileKey(id int) (string, error)
+
+	// List where the files of the jobs finished before a point in time are kept in the blobstore
+	ListFinishedJobFileKeys(before time.Time) ([]string, error)
+
+	// Delete the jobs finished before a point in time, with their files
+	DeleteFinishedJobs(before time.Time) (int64, error)
+
+	// Record that a run of the cron job name started, the job running every interval
+	StartJobRun(name string, interval time.Duration) error
+
+	// Record that the current run of the cron job name finished, clearing the error of a previous run
+	FinishJobRun(name string) error
+
+	// Record that the current run of the cron job name failed with jobErr. The last finish is left as it was
+	FailJobRun(name string, jobErr string) error
+
+	// List the heartbeats of the cron jobs by name
+	ListJobStatuses() ([]*JobStatusModel, error)
+
+	// Ask the cronjobs to run the cron job name at once. It returns ErrNotFound for a job that never ran
+	RequestJobRun(name string) (*JobStatusModel, error)
+
+	// Take the names of the cron jobs whose run was requested, clearing the requests
+	ClaimJobRunRequests() ([]string, error)
+}
+
+// WarehouseRepository lists the rows shipped to the data warehouse and records how far each stream went.
+type WarehouseRepository interface {
+	// List up to limit click events past afterId made before before, by id, with the code of their link
+	ListClicksForExport(afterId int64, before time.Time, limit int) ([]*ClickEventModel, error)
+
+	// List up to limit links last changed after the change at afterUpdatedAt of the link afterId and before before,
+	// oldest change first
+	ListLinksForExport(afterUpdatedAt time.Time, afterId int, before time.Time, limit int) ([]*ShortUrlModel, error)
+
+	// Get how far a stream was exported
+	GetExportCursor(stream string) (*ExportCursorModel, error)
+
+	// Record how far a stream was exported
+	SaveExportCursor(*ExportCursorModel) error
+}
+
+// UpkeepRepository keeps the tables in shape for the planner, and reports how bloated they are.
+type UpkeepRepository interface {
+	// Update the planner statistics of the tables, and of their partitions
+	AnalyzeTables(tables []string) error
+
+	// Reclaim the space of the dead rows of the tables, without locking them
+	VacuumTables(tables []string) error
+
+	// Sizes and row counts of every table of the schema, partitions included
+	ListTableStats() ([]*TableStatsModel, error)
+}
+
+// MaintenanceRepository stores the maintenance switch, read by every replica of the api and by the cronjobs.
+type MaintenanceRepository interface {
+	// Get the maintenance switch
+	GetMaintenance() (*MaintenanceModel, error)
+
+	// Switch maintenance on or off, with the message shown to the clients whose writes are refused
+	SetMaintenance(*MaintenanceModel) (*MaintenanceModel, error)
+}
+
+// ModerationRepository covers the review queue, abuse reports and takedowns.
+type ModerationRepository interface {
+	// Disable a short url and put it in the review queue
+	QueueForReview(*ReviewModel) (*ReviewModel, error)
+
+	// List the review queue entries with the given status
+	ListReviews(status string) ([]*ReviewModel, error)
+
+	// Approve (re-enabling the link) or reject a pending review
+	ResolveReview(id int, approve bool) error
+
+	// Store an abuse report against the link identified by ShortCode
+	SaveAbuseReport(*AbuseReportModel) (*AbuseReportModel, error)
+
+	// List abuse reports with the given status
+	ListAbuseReports(status string) ([]*AbuseReportModel, error)
+
+	// Dismiss an open abuse report
+	DismissAbuseReport(id int) error
+
+	// Disable the reported link and close every open report against it
+	DisableReportedLink(id int) error
+
+	// Move a link from FromState to ToState, recording the event and queueing a notification of the owner, see
+	// NotificationRepository. It returns ErrNotFound when the link is not in FromState anymore
+	TransitionModeration(*TakedownEventModel) (*TakedownEventModel, error)
+
+	// List the moderation history of a short code, oldest first
+	ListTakedownEvents(shortCode string) ([]*TakedownEventModel, error)
+}
+
+// OrganizationRepository manages organizations, their members and invitations, and the links they share.
+type OrganizationRepository interface {
+	// Create an organization owned by the user
+	CreateOrganization(organization *OrganizationModel, ownerId int) (*OrganizationModel, error)
+
+	// List the organizations a user is a member of, with the role of the user
+	ListOrganizations(userId int) ([]*OrganizationModel, error)
+
+	// Get an organization, without a role
+	GetOrganization(id int) (*OrganizationModel, error)
+
+	// Move an organization to another plan
+	UpdateOrganizationPlan(id int, plan string) (*OrganizationModel, error)
+
+	// List the organizations overriding the analytics retention of their plan
+	ListOrganizationRetentions() ([]*RetentionModel, error)
+
+	// Override the analytics retention of an organization, nil retentions following its plan again
+	SetOrganizationRetention(*RetentionModel) (*RetentionModel, error)
+
+	// Get the membership of a user in an organization, ErrNotFound when the user isn't a member
+	GetMembership(organizationId int, userId int) (*MemberModel, error)
+
+	// List the members of an organization, oldest first
+	ListMembers(organizationId int) ([]*MemberModel, error)
+
+	// Remove a member. It returns ErrLastOwner instead of removing the only owner
+	RemoveMember(organizationId int, userId int) error
+
+	// Change the role of a member. It returns ErrLastOwner instead of demoting the only owner
+	UpdateMemberRole(organizationId int, userId int, role string) (*MemberModel, error)
+
+	// Store an invitation, notifying the invitee when it names the email of an existing user
+	SaveInvitation(*InvitationModel) (*InvitationModel, error)
+
+	// Add the user to the organization of a pending invitation. It returns ErrNotFound for unknown, expired or
+	// used invitations and ErrInvitationEmail when the invitation names another email
+	AcceptInvitation(tokenHash string, userId int) (*MemberModel, error)
+
+	// List the pending invitations of an organization, neither accepted nor expired, newest first
+	ListInvitations(organizationId int) ([]*InvitationModel, error)
+
+	// Get an invitation of an organization, ErrNotFound when it doesn't exist
+	GetInvitation(organizationId int, id int) (*InvitationModel, error)
+
+	// Replace the token of an invitation not yet accepted and extend it until expiresAt, reviving expired ones.
+	// It returns ErrNotFound for unknown or accepted invitations
+	RenewInvitation(organizationId int, id int, tokenHash string, expiresAt time.Time) (*InvitationModel, error)
+
+	// Delete an invitation not yet accepted, so its token can't be used. It returns ErrNotFound for unknown or
+	// accepted invitations
+	RevokeInvitation(organizationId int, id int) (*InvitationModel, error)
+
+	// List a page of the links of an organization, newest first. Up to page.Fetch() rows are returned
+	ListOrganizationLinks(organizationId int, page pagination.Page) ([]*ShortUrlModel, error)
+}
+
+// AuditRepository appends to and queries the audit log.
+type AuditRepository interface {
+	// Append an entry to the audit log
+	RecordAudit(*AuditLogModel) error
+
+	// Query a page of the audit log, newest first. Up to filter.Page.Fetch() entries are returned
+	ListAuditLog(AuditLogFilter) ([]*AuditLogModel, error)
+}
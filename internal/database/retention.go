@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/plans"
+)
+
+func (scope AnalyticsScope) String() string {
+	if scope.OrganizationId != 0 {
+		return fmt.Sprintf("organization %d", scope.OrganizationId)
+	}
+	return "plan " + scope.Plan
+}
+
+// condition returns the condition picking the links of scope, over short_url su, organizations o and users u, with
+// its arguments following before. Links of an organization are on its plan, the others on the plan of their owner
+// and anonymous links on plans.Default. The plan leaves out the organizations overriding its retention in column
+func (scope AnalyticsScope) condition(column string, before time.Time) (string, []any) {
+	if scope.OrganizationId != 0 {
+		return "su.organization_id = $2", []any{before, scope.OrganizationId}
+	}
+	return "COALESCE(o.plan, u.plan, $3) = $2 AND o." + column + " IS NULL", []any{before, scope.Plan, plans.Default}
+}
+
+// RollupClicks counts the click events made since since into the daily rollups. Counts only grow, so the days whose
+// click events were partly purged keep what was counted before
+func (s *service) RollupClicks(since time.Time) (int64, error) {
+	query := `INSERT INTO click_daily_rollups (short_url_id, day, clicks, unique_visitors)
+		SELECT short_url_id, date_trunc('day', clicked_at)::date, COUNT(*), COUNT(DISTINCT ip_hash)
+		FROM click_events
+		WHERE clicked_at >= $1
+		GROUP BY 1, 2
+		ON CONFLICT (short_url_id, day) DO UPDATE SET
+			clicks = GREATEST(click_daily_rollups.clicks, EXCLUDED.clicks),
+			unique_visitors = GREATEST(click_daily_rollups.unique_visitors, EXCLUDED.unique_visitors);`
+
+	result, err := s.db.Exec(context.Background(), query, since)
+	if err != nil {
+		log.Printf("[database:RollupClicks] something went wrong: %v", err)
+		return 0, fmt.Errorf("rollup clicks since %s: %w", since.Format(time.RFC3339), err)
+	}
+
+	affected := result.RowsAffected()
+
+	log.Printf("[database:RollupClicks] Rolled up {%d} days of links since %s", affected, since.Format(time.RFC3339))
+
+	return affected, nil
+}
+
+// PurgeClickRollups deletes the daily rollups of days before before of the links in scope
+func (s *service) PurgeClickRollups(scope AnalyticsScope, before time.Time) (int64, error) {
+	log.Printf("[database:PurgeClickRollups] Deleting rollups of {%s} links before %s", scope, before.Format(time.RFC3339))
+
+	condition, args := scope.condition("rollup_retention_days", before)
+	query := `DELETE FROM click_daily_rollups AS r
+		USING short_url AS su
+		LEFT JOIN organizations AS o ON o.id = su.organization_id
+		LEFT JOIN users AS u ON u.id = su.owner_id
+		WHERE r.short_url_id = su.id AND r.day < $1::date AND ` + condition
+
+	affected, err := s.destroy("PurgeClickRollups", query, "su.short_code || '@' || r.day", args...)
+	if err != nil {
+		log.Printf("[database:PurgeClickRollups] something went wrong: %v", err)
+		return 0, fmt.Errorf("purge click rollups of %s: %w", scope, err)
+	}
+
+	log.Printf("[database:PurgeClickRollups] Deleted {%d} rollups", affected)
+
+	return affected, nil
+}
+
+func (s *service) ListOrganizationRetentions() ([]*RetentionModel, error) {
+	query := `SELECT id, click_retention_days, rollup_retention_days FROM organizations
+		WHERE click_retention_days IS NOT NULL OR rollup_retention_days IS NOT NULL ORDER BY id;`
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:ListOrganizationRetentions] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list organization retentions: %w", err)
+	}
+	defer rows.Close()
+
+	retentions := []*RetentionModel{}
+	for rows.Next() {
+		retention := &RetentionModel{}
+		if err := rows.Scan(&retention.OrganizationId, &retention.ClickRetentionDays, &retention.RollupRetentionDays); err != nil {
+			return nil, fmt.Errorf("list organization retentions: %w", err)
+		}
+		retentions = append(retentions, retention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list organization retentions: %w", err)
+	}
+
+	return retentions, nil
+}
+
+func (s *service) SetOrganizationRetention(retention *RetentionModel) (*RetentionModel, error) {
+	log.Printf("[database:SetOrganizationRetention] Setting the retention of organization {%d}", retention.OrganizationId)
+
+	query := `UPDATE organizations SET click_retention_days = $2, rollup_retention_days = $3 WHERE id = $1
+		RETURNING id, click_retention_days, rollup_retention_days;`
+
+	saved := &RetentionModel{}
+	err := s.db.QueryRow(context.Background(), query, retention.OrganizationId, retention.ClickRetentionDays, retention.RollupRetentionDays).
+		Scan(&saved.OrganizationId, &saved.ClickRetentionDays, &saved.RollupRetentionDays)
+	if err != nil {
+		return nil, fmt.Errorf("set retention of organization %d: %w", retention.OrganizationId, notFound(err))
+	}
+
+	return saved, nil
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const pendingReviewReason = "pending review"
+
+func (s *service) QueueForReview(reviewModel *ReviewModel) (*ReviewModel, error) {
+	log.Printf("[database:QueueForReview] Queueing short url {%d} for review with score {%d}", reviewModel.ShortUrlId, reviewModel.Score)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("queue short url %d for review: %w", reviewModel.ShortUrlId, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	inserted, err := queueReview(tx, reviewModel)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("queue short url %d for review: %w", reviewModel.ShortUrlId, err)
+	}
+
+	return inserted, nil
+}
+
+// queueReview disables the short url of the review and inserts the review, within tx
+func queueReview(tx pgx.Tx, reviewModel *ReviewModel) (*ReviewModel, error) {
+	_, err := tx.Exec(context.Background(), "UPDATE short_url SET disabled_at = NOW(), disabled_reason = $2 WHERE id = $1;", reviewModel.ShortUrlId, pendingReviewReason)
+	if err != nil {
+		log.Printf("[database:queueReview] Could not disable short url {%d}: %v", reviewModel.ShortUrlId, err)
+		return nil, fmt.Errorf("queue short url %d for review: %w", reviewModel.ShortUrlId, err)
+	}
+
+	query := "INSERT INTO review_queue (short_url_id, score, reasons, status) VALUES ($1, $2, $3, $4) RETURNING id, short_url_id, score, reasons, status, created_at;"
+
+	inserted := &ReviewModel{}
+	err = tx.QueryRow(context.Background(), query, reviewModel.ShortUrlId, reviewModel.Score, reviewModel.Reasons, ReviewPending).Scan(&inserted.Id, &inserted.ShortUrlId, &inserted.Score, &inserted.Reasons, &inserted.Status, &inserted.CreatedAt)
+	if err != nil {
+		log.Printf("[database:queueReview] Error inserting review: %v", err)
+		return nil, fmt.Errorf("queue short url %d for review: %w", reviewModel.ShortUrlId, err)
+	}
+
+	return inserted, nil
+}
+
+// reviewed runs write, a statement saving a link, in one transaction with the review of the link when there is one.
+// The link is then only ever seen disabled and queued, never live in between. Without a review write runs as is
+func (s *service) reviewed(review *ReviewModel, write func(q dbtx) (*ShortUrlModel, error)) (*ShortUrlModel, error) {
+	if review == nil {
+		return write(s.db)
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("save short url for review: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	saved, err := write(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := reviewSaved(tx, saved, review); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("save short url %s for review: %w", saved.ShortCode, err)
+	}
+
+	return saved, nil
+}
+
+// reviewSaved queues the review of a link saved within tx, and marks saved disabled as the queueing leaves it
+func reviewSaved(tx pgx.Tx, saved *ShortUrlModel, review *ReviewModel) error {
+	queued, err := queueReview(tx, &ReviewModel{ShortUrlId: saved.Id, Score: review.Score, Reasons: review.Reasons})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[database:reviewSaved] Saved shortCode {%s} disabled for review: %s", saved.ShortCode, review.Reasons)
+
+	// NOW() is the start of the transaction, disabled_at and created_at of the review are the same
+	saved.DisabledAt, saved.DisabledReason = &queued.CreatedAt, pendingReviewReason
+	return nil
+}
+
+func (s *service) ListReviews(status string) ([]*ReviewModel, error) {
+	query := `SELECT rq.id, rq.short_url_id, su.short_code, su.link, rq.score, rq.reasons, rq.status, rq.created_at, rq.reviewed_at
+		FROM review_queue AS rq
+		JOIN short_url AS su ON su.id = rq.short_url_id
+		WHERE rq.status = $1
+		ORDER BY rq.score DESC, rq.id;`
+
+	rows, err := s.db.Query(context.Background(), query, status)
+	if err != nil {
+		log.Printf("[database:ListReviews] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list %s reviews: %w", status, err)
+	}
+	defer rows.Close()
+
+	reviews := []*ReviewModel{}
+	for rows.Next() {
+		review := &ReviewModel{}
+		err := rows.Scan(&review.Id, &review.ShortUrlId, &review.ShortCode, &review.Link, &review.Score, &review.Reasons, &review.Status, &review.CreatedAt, &review.ReviewedAt)
+		if err != nil {
+			log.Printf("[database:ListReviews] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list %s reviews: %w", status, err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+func (s *service) ResolveReview(id int, approve bool) error {
+	log.Printf("[database:ResolveReview] Resolving review {%d}, approved: %v", id, approve)
+
+	status := ReviewRejected
+	if approve {
+		status = ReviewApproved
+	}
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("resolve review %d: %w", id, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	var shortUrlId int
+	err = tx.QueryRow(context.Background(), "UPDATE review_queue SET status = $2, reviewed_at = NOW() WHERE id = $1 AND status = $3 RETURNING short_url_id;", id, status, ReviewPending).Scan(&shortUrlId)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("[database:ResolveReview] Could not update review {%d}: %v", id, err)
+		}
+		return fmt.Errorf("resolve review %d: %w", id, notFound(err))
+	}
+
+	if approve {
+		_, err = tx.Exec(context.Background(), "UPDATE short_url SET disabled_at = NULL, disabled_reason = NULL WHERE id = $1 AND disabled_reason = $2;", shortUrlId, pendingReviewReason)
+	} else {
+		_, err = tx.Exec(context.Background(), "UPDATE short_url SET disabled_reason = 'rejected in review' WHERE id = $1;", shortUrlId)
+	}
+	if err != nil {
+		log.Printf("[database:ResolveReview] Could not update short url {%d}: %v", shortUrlId, err)
+		return fmt.Errorf("resolve review %d: %w", id, err)
+	}
+
+	return tx.Commit(context.Background())
+}
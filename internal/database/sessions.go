@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (s *service) SaveSession(sessionModel *SessionModel) (*SessionModel, error) {
+	query := `INSERT INTO sessions (user_id, token_hash, method, ip, user_agent, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, user_id, token_hash, method, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at, last_seen_at, expires_at;`
+
+	inserted := &SessionModel{}
+	err := s.db.QueryRow(context.Background(), query, sessionModel.UserId, sessionModel.TokenHash, sessionModel.Method, sessionModel.Ip, sessionModel.UserAgent, sessionModel.ExpiresAt).
+		Scan(&inserted.Id, &inserted.UserId, &inserted.TokenHash, &inserted.Method, &inserted.Ip, &inserted.UserAgent, &inserted.CreatedAt, &inserted.LastSeenAt, &inserted.ExpiresAt)
+	if err != nil {
+		log.Printf("[database:SaveSession] Error inserting session: %v", err)
+		return nil, fmt.Errorf("save session of user %d: %w", sessionModel.UserId, err)
+	}
+
+	log.Printf("[database:SaveSession] Started session {%d} of user {%d}", inserted.Id, inserted.UserId)
+
+	return inserted, nil
+}
+
+func (s *service) GetSessionByHash(tokenHash string, idleTimeout time.Duration) (*SessionModel, error) {
+	query := `UPDATE sessions SET last_seen_at = NOW()
+		FROM users
+		WHERE sessions.user_id = users.id AND sessions.token_hash = $1 AND sessions.revoked_at IS NULL
+		AND sessions.expires_at > NOW() AND sessions.last_seen_at > $2
+		RETURNING sessions.id, sessions.user_id, sessions.method, COALESCE(sessions.ip, ''), COALESCE(sessions.user_agent, ''), sessions.created_at, sessions.last_seen_at, sessions.expires_at,
+			users.id, users.email, users.role, users.plan, users.created_at, users.email_verified_at;`
+
+	session := &SessionModel{TokenHash: tokenHash, User: &UserModel{}}
+	err := s.db.QueryRow(context.Background(), query, tokenHash, time.Now().Add(-idleTimeout)).Scan(&session.Id, &session.UserId, &session.Method, &session.Ip, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt,
+		&session.User.Id, &session.User.Email, &session.User.Role, &session.User.Plan, &session.User.CreatedAt, &session.User.EmailVerifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get session by hash: %w", notFound(err))
+	}
+
+	return session, nil
+}
+
+func (s *service) ListSessions(userId int, idleTimeout time.Duration) ([]*SessionModel, error) {
+	query := `SELECT id, user_id, method, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at, last_seen_at, expires_at FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND last_seen_at > $2
+		ORDER BY last_seen_at DESC, id DESC;`
+
+	rows, err := s.db.Query(context.Background(), query, userId, time.Now().Add(-idleTimeout))
+	if err != nil {
+		log.Printf("[database:ListSessions] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list sessions of user %d: %w", userId, err)
+	}
+	defer rows.Close()
+
+	sessions := []*SessionModel{}
+	for rows.Next() {
+		session := &SessionModel{}
+		if err := rows.Scan(&session.Id, &session.UserId, &session.Method, &session.Ip, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt); err != nil {
+			log.Printf("[database:ListSessions] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list sessions of user %d: %w", userId, err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (s *service) RevokeSession(userId int, id int) error {
+	log.Printf("[database:RevokeSession] Revoking session {%d} of user {%d}", id, userId)
+
+	query := "UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW();"
+	result, err := s.db.Exec(context.Background(), query, id, userId)
+	if err != nil {
+		log.Printf("[database:RevokeSession] something went wrong while revoking session {%d}: %v", id, err)
+		return fmt.Errorf("revoke session %d: %w", id, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("revoke session %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) RevokeSessions(userId int) (int64, error) {
+	log.Printf("[database:RevokeSessions] Revoking the sessions of user {%d}", userId)
+
+	result, err := s.db.Exec(context.Background(), "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW();", userId)
+	if err != nil {
+		log.Printf("[database:RevokeSessions] something went wrong while revoking the sessions of user {%d}: %v", userId, err)
+		return 0, fmt.Errorf("revoke sessions of user %d: %w", userId, err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (s *service) DeleteEndedSessions() (int64, error) {
+	log.Printf("[database:DeleteEndedSessions] Deleting ended sessions")
+
+	deleted, err := s.destroy("DeleteEndedSessions", "DELETE FROM sessions WHERE expires_at <= NOW() OR revoked_at IS NOT NULL", "id")
+	if err != nil {
+		log.Printf("[database:DeleteEndedSessions] something went wrong: %v", err)
+		return 0, fmt.Errorf("delete ended sessions: %w", err)
+	}
+	log.Printf("[database:DeleteEndedSessions] Deleted {%d} ended sessions", deleted)
+
+	return deleted, nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (s *service) GetLinkStats(shortCode string, days int) (*LinkStatsModel, error) {
+	log.Printf("[database:GetLinkStats] Collecting stats for shortCode: {%s}", shortCode)
+
+	stats := &LinkStatsModel{ShortCode: shortCode}
+
+	var shortUrlId int
+	query := "SELECT id, " + timesClickedExpr + " FROM short_url WHERE short_code = $1;"
+	if err := s.read.QueryRow(context.Background(), query, shortCode).Scan(&shortUrlId, &stats.TotalClicks); err != nil {
+		return nil, fmt.Errorf("get stats of short url %s: %w", shortCode, notFound(err))
+	}
+
+	query = "SELECT COUNT(DISTINCT ip_hash) FROM click_events WHERE short_url_id = $1;"
+	if err := s.read.QueryRow(context.Background(), query, shortUrlId).Scan(&stats.UniqueVisitors); err != nil {
+		log.Printf("[database:GetLinkStats] Could not count unique visitors: %v", err)
+		return nil, fmt.Errorf("get stats of short url %s: %w", shortCode, err)
+	}
+
+	// Days whose click events were purged are read from the rollups, and the others counted live as the rollups may
+	// lag behind
+	query = `SELECT day, MAX(clicks) FROM (
+			SELECT date_trunc('day', clicked_at) AS day, COUNT(*) AS clicks
+			FROM click_events
+			WHERE short_url_id = $1 AND clicked_at >= date_trunc('day', NOW()) - make_interval(days => $2)
+			GROUP BY 1
+			UNION ALL
+			SELECT day::timestamptz, clicks
+			FROM click_daily_rollups
+			WHERE short_url_id = $1 AND day >= (date_trunc('day', NOW()) - make_interval(days => $2))::date
+		) daily
+		GROUP BY day ORDER BY day;`
+
+	rows, err := s.read.Query(context.Background(), query, shortUrlId, days-1)
+	if err != nil {
+		log.Printf("[database:GetLinkStats] Could not count daily clicks: %v", err)
+		return nil, fmt.Errorf("get stats of short url %s: %w", shortCode, err)
+	}
+	defer rows.Close()
+
+	stats.Daily = []*DailyClicksModel{}
+	for rows.Next() {
+		daily := &DailyClicksModel{}
+		if err := rows.Scan(&daily.Day, &daily.Clicks); err != nil {
+			return nil, fmt.Errorf("get stats of short url %s: %w", shortCode, err)
+		}
+		stats.Daily = append(stats.Daily, daily)
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *service) GetLinkSummary(days int) (*LinkSummaryModel, error) {
+	log.Printf("[database:GetLinkSummary] Collecting the link summary")
+
+	summary := &LinkSummaryModel{}
+
+	query := `SELECT
+		COUNT(*),
+		COUNT(*) FILTER (WHERE disabled_at IS NULL AND NOW() < created_at + (exp_time_minutes || ' minutes')::interval),
+		COUNT(*) FILTER (WHERE NOW() >= created_at + (exp_time_minutes || ' minutes')::interval),
+		(SELECT COALESCE(SUM(clicks), 0)::bigint FROM link_click_counters WHERE short_url_id IN (SELECT id FROM short_url WHERE visibility = $1))
+	FROM short_url
+	WHERE visibility = $1;`
+
+	err := s.read.QueryRow(context.Background(), query, VisibilityPublic).Scan(&summary.TotalLinks, &summary.ActiveLinks, &summary.ExpiredLinks, &summary.TotalClicks)
+	if err != nil {
+		log.Printf("[database:GetLinkSummary] Could not count links: %v", err)
+		return nil, fmt.Errorf("get link summary: %w", err)
+	}
+
+	query = `SELECT date_trunc('day', created_at) AS day, COUNT(*)
+		FROM short_url
+		WHERE created_at >= date_trunc('day', NOW()) - make_interval(days => $1) AND visibility = $2
+		GROUP BY day ORDER BY day;`
+
+	rows, err := s.read.Query(context.Background(), query, days-1, VisibilityPublic)
+	if err != nil {
+		log.Printf("[database:GetLinkSummary] Could not count daily links: %v", err)
+		return nil, fmt.Errorf("get link summary: %w", err)
+	}
+	defer rows.Close()
+
+	summary.Daily = []*DailyLinksModel{}
+	for rows.Next() {
+		daily := &DailyLinksModel{}
+		if err := rows.Scan(&daily.Day, &daily.Links); err != nil {
+			return nil, fmt.Errorf("get link summary: %w", err)
+		}
+		summary.Daily = append(summary.Daily, daily)
+	}
+
+	return summary, rows.Err()
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// statsViews are the materialized views refreshed by RefreshStatsViews
+var statsViews = []string{"destination_domain_stats", "link_leaderboard"}
+
+// The live queries compute what the views hold, for when they are stale. Keep them in step with the views of the
+// create_stats_views migration
+const (
+	liveDomainStatsQuery = `SELECT COALESCE(lower(substring(short_url.link from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')), '') AS domain,
+			COUNT(*)::bigint AS links,
+			COALESCE(SUM(counted.clicks), 0)::bigint AS clicks
+		FROM short_url
+		LEFT JOIN (SELECT short_url_id, SUM(clicks) AS clicks FROM link_click_counters GROUP BY short_url_id) counted
+			ON counted.short_url_id = short_url.id
+		GROUP BY 1
+		ORDER BY clicks DESC, domain LIMIT $1;`
+
+	liveLeaderboardQuery = `SELECT short_url.short_code, short_url.link, short_url.visibility, SUM(link_click_counters.clicks)::bigint AS clicks
+		FROM short_url
+		JOIN link_click_counters ON link_click_counters.short_url_id = short_url.id
+		GROUP BY short_url.id
+		ORDER BY clicks DESC, short_url.short_code LIMIT $1;`
+)
+
+func (s *service) RefreshStatsViews() error {
+	var errs []error
+	for _, view := range statsViews {
+		start := time.Now()
+
+		// Concurrently, so the stats endpoints keep reading the previous content meanwhile
+		if _, err := s.db.Exec(context.Background(), "REFRESH MATERIALIZED VIEW CONCURRENTLY "+pgx.Identifier{view}.Sanitize()+";"); err != nil {
+			log.Printf("[database:RefreshStatsViews] Could not refresh {%s}: %v", view, err)
+			errs = append(errs, fmt.Errorf("refresh %s: %w", view, err))
+			continue
+		}
+
+		query := `INSERT INTO stats_view_refreshes (view_name, refreshed_at) VALUES ($1, $2)
+			ON CONFLICT (view_name) DO UPDATE SET refreshed_at = EXCLUDED.refreshed_at;`
+		if _, err := s.db.Exec(context.Background(), query, view, start); err != nil {
+			errs = append(errs, fmt.Errorf("refresh %s: %w", view, err))
+			continue
+		}
+
+		log.Printf("[database:RefreshStatsViews] Refreshed {%s} in %s", view, time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
+
+// viewRefreshedAt returns when view was last refreshed, the zero time when it never was
+func (s *service) viewRefreshedAt(view string) (time.Time, error) {
+	var refreshedAt time.Time
+	err := s.read.QueryRow(context.Background(), "SELECT refreshed_at FROM stats_view_refreshes WHERE view_name = $1;", view).Scan(&refreshedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return refreshedAt, err
+}
+
+// DomainStats reads the view unless it was refreshed longer than maxAge ago, and then counts from the tables
+func (s *service) DomainStats(limit int, maxAge time.Duration) ([]*DomainStatsModel, time.Time, error) {
+	refreshedAt, err := s.viewRefreshedAt("destination_domain_stats")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("domain stats: %w", err)
+	}
+
+	query, asOf := "SELECT domain, links, clicks FROM destination_domain_stats ORDER BY clicks DESC, domain LIMIT $1;", refreshedAt
+	if time.Since(refreshedAt) > maxAge {
+		log.Printf("[database:DomainStats] The view is stale since {%s}, counting live", refreshedAt.Format(time.RFC3339))
+		query, asOf = liveDomainStatsQuery, time.Now()
+	}
+
+	rows, err := s.read.Query(context.Background(), query, limit)
+	if err != nil {
+		log.Printf("[database:DomainStats] Something went wrong: %v", err)
+		return nil, time.Time{}, fmt.Errorf("domain stats: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []*DomainStatsModel{}
+	for rows.Next() {
+		domain := &DomainStatsModel{}
+		if err := rows.Scan(&domain.Domain, &domain.Links, &domain.Clicks); err != nil {
+			return nil, time.Time{}, fmt.Errorf("domain stats: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("domain stats: %w", err)
+	}
+
+	return domains, asOf, nil
+}
+
+// Leaderboard reads the view unless it was refreshed longer than maxAge ago, and then counts from the tables
+func (s *service) Leaderboard(limit int, maxAge time.Duration) ([]*LeaderboardEntryModel, time.Time, error) {
+	refreshedAt, err := s.viewRefreshedAt("link_leaderboard")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("leaderboard: %w", err)
+	}
+
+	query, asOf := "SELECT short_code, link, visibility, clicks FROM link_leaderboard ORDER BY clicks DESC, short_code LIMIT $1;", refreshedAt
+	if time.Since(refreshedAt) > maxAge {
+		log.Printf("[database:Leaderboard] The view is stale since {%s}, counting live", refreshedAt.Format(time.RFC3339))
+		query, asOf = liveLeaderboardQuery, time.Now()
+	}
+
+	rows, err := s.read.Query(context.Background(), query, limit)
+	if err != nil {
+		log.Printf("[database:Leaderboard] Something went wrong: %v", err)
+		return nil, time.Time{}, fmt.Errorf("leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*LeaderboardEntryModel{}
+	for rows.Next() {
+		entry := &LeaderboardEntryModel{}
+		if err := rows.Scan(&entry.ShortCode, &entry.Link, &entry.Visibility, &entry.Clicks); err != nil {
+			return nil, time.Time{}, fmt.Errorf("leaderboard: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("leaderboard: %w", err)
+	}
+
+	return entries, asOf, nil
+}
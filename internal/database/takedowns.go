@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+const takedownReasonPrefix = "taken down: "
+
+func (s *service) TransitionModeration(event *TakedownEventModel) (*TakedownEventModel, error) {
+	log.Printf("[database:TransitionModeration] Moving shortCode {%s} from {%s} to {%s} by {%s}", event.ShortCode, event.FromState, event.ToState, event.Actor)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("move short url %s from %s to %s: %w", event.ShortCode, event.FromState, event.ToState, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	// Taking a link down disables it, clearing it lifts only a takedown, never other disable reasons
+	query := `UPDATE short_url SET
+			moderation_state = $3,
+			disabled_at = CASE
+				WHEN $3 = 'taken_down' THEN COALESCE(disabled_at, NOW())
+				WHEN $3 = 'active' AND disabled_reason LIKE 'taken down: %' THEN NULL
+				ELSE disabled_at END,
+			disabled_reason = CASE
+				WHEN $3 = 'taken_down' THEN $4
+				WHEN $3 = 'active' AND disabled_reason LIKE 'taken down: %' THEN NULL
+				ELSE disabled_reason END
+		WHERE short_code = $1 AND moderation_state = $2
+		RETURNING id, owner_id, link;`
+
+	var ownerId *int
+	var link string
+	inserted := &TakedownEventModel{}
+	err = tx.QueryRow(context.Background(), query, event.ShortCode, event.FromState, event.ToState, takedownReasonPrefix+event.ReasonCode).Scan(&inserted.ShortUrlId, &ownerId, &link)
+	if err != nil {
+		log.Printf("[database:TransitionModeration] Could not move shortCode {%s}: %v", event.ShortCode, err)
+		return nil, fmt.Errorf("move short url %s from %s to %s: %w", event.ShortCode, event.FromState, event.ToState, notFound(err))
+	}
+
+	query = `INSERT INTO takedown_events (short_url_id, short_code, from_state, to_state, reason_code, note, actor)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, short_url_id, short_code, from_state, to_state, reason_code, COALESCE(note, ''), actor, created_at;`
+
+	err = tx.QueryRow(context.Background(), query, inserted.ShortUrlId, event.ShortCode, event.FromState, event.ToState, event.ReasonCode, event.Note, event.Actor).
+		Scan(&inserted.Id, &inserted.ShortUrlId, &inserted.ShortCode, &inserted.FromState, &inserted.ToState, &inserted.ReasonCode, &inserted.Note, &inserted.Actor, &inserted.CreatedAt)
+	if err != nil {
+		log.Printf("[database:TransitionModeration] Could not record takedown event: %v", err)
+		return nil, fmt.Errorf("move short url %s from %s to %s: %w", event.ShortCode, event.FromState, event.ToState, err)
+	}
+
+	if ownerId != nil {
+		subject := fmt.Sprintf("Your short link %s is now %s", event.ShortCode, event.ToState)
+		body := fmt.Sprintf("The short link %s pointing at %s moved from %s to %s.\nReason: %s\n", event.ShortCode, link, event.FromState, event.ToState, event.ReasonCode)
+		if event.Note != "" {
+			body += "Note: " + event.Note + "\n"
+		}
+
+		_, err = tx.Exec(context.Background(), "INSERT INTO notifications (user_id, subject, body) VALUES ($1, $2, $3);", *ownerId, subject, body)
+		if err != nil {
+			log.Printf("[database:TransitionModeration] Could not notify owner {%d}: %v", *ownerId, err)
+			return nil, fmt.Errorf("move short url %s from %s to %s: %w", event.ShortCode, event.FromState, event.ToState, err)
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("move short url %s from %s to %s: %w", event.ShortCode, event.FromState, event.ToState, err)
+	}
+
+	return inserted, nil
+}
+
+func (s *service) ListTakedownEvents(shortCode string) ([]*TakedownEventModel, error) {
+	query := `SELECT id, short_url_id, short_code, from_state, to_state, reason_code, COALESCE(note, ''), actor, created_at
+		FROM takedown_events WHERE short_code = $1 ORDER BY id;`
+
+	rows, err := s.db.Query(context.Background(), query, shortCode)
+	if err != nil {
+		log.Printf("[database:ListTakedownEvents] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list takedown events of %s: %w", shortCode, err)
+	}
+	defer rows.Close()
+
+	events := []*TakedownEventModel{}
+	for rows.Next() {
+		event := &TakedownEventModel{}
+		err := rows.Scan(&event.Id, &event.ShortUrlId, &event.ShortCode, &event.FromState, &event.ToState, &event.ReasonCode, &event.Note, &event.Actor, &event.CreatedAt)
+		if err != nil {
+			log.Printf("[database:ListTakedownEvents] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list takedown events of %s: %w", shortCode, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"url-shortner/migrations"
+)
+
+var (
+	schemaMu       sync.Mutex
+	schemaServices = map[string]*service{}
+)
+
+// ForSchema returns the service whose connections use schemaName as search_path, so every query reaches the
+// tables of that schema. The pool is opened on first use and shared afterwards
+func ForSchema(schemaName string) (Service, error) {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+
+	if instance, ok := schemaServices[schemaName]; ok {
+		return instance, nil
+	}
+
+	instance, err := connect(schemaName, connString(schemaName))
+	if err != nil {
+		return nil, fmt.Errorf("connect to schema %s: %w", schemaName, err)
+	}
+	schemaServices[schemaName] = instance
+
+	return instance, nil
+}
+
+// MigrateSchema creates schemaName when missing and applies the bundled migrations it doesn't have yet,
+// returning how many were applied. Instances migrating the same schema together wait for each other
+func MigrateSchema(schemaName string) (int, error) {
+	all, err := migrations.All()
+	if err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	db, err := ForSchema(schemaName)
+	if err != nil {
+		return 0, err
+	}
+	s := db.(*service)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(context.Background(), "SELECT pg_advisory_xact_lock($1, hashtext($2));", bootstrapLockKey, schemaName); err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	// Schema names come from tenancy, which only allows names that need no quoting
+	if _, err := tx.Exec(context.Background(), "CREATE SCHEMA IF NOT EXISTS "+schemaName+";"); err != nil {
+		log.Printf("[database:MigrateSchema] Could not create schema {%s}: %v", schemaName, err)
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	if _, err := tx.Exec(context.Background(), gooseVersionTableQuery); err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	var current int64
+	if err := tx.QueryRow(context.Background(), "SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied;").Scan(&current); err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	applied, err := applyMigrations(tx, all, current)
+	if err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return 0, fmt.Errorf("migrate schema %s: %w", schemaName, err)
+	}
+
+	log.Printf("[database:MigrateSchema] Applied {%d} migrations to schema {%s}", applied, schemaName)
+
+	return applied, nil
+}
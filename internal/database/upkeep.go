@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *service) AnalyzeTables(tables []string) error {
+	return s.upkeep("ANALYZE", tables)
+}
+
+func (s *service) VacuumTables(tables []string) error {
+	return s.upkeep("VACUUM", tables)
+}
+
+// upkeep runs command on each table in turn, so one failing doesn't keep the others from being done. Neither ANALYZE
+// nor VACUUM take parameters, the names are quoted instead
+func (s *service) upkeep(command string, tables []string) error {
+	var errs []error
+	for _, table := range tables {
+		start := time.Now()
+		if _, err := s.db.Exec(context.Background(), command+" "+pgx.Identifier{table}.Sanitize()+";"); err != nil {
+			log.Printf("[database:upkeep] Could not %s {%s}: %v", command, table, err)
+			errs = append(errs, fmt.Errorf("%s %s: %w", command, table, err))
+			continue
+		}
+		log.Printf("[database:upkeep] Ran %s on {%s} in %s", command, table, time.Since(start))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *service) ListTableStats() ([]*TableStatsModel, error) {
+	query := `SELECT schemaname, relname, n_live_tup, n_dead_tup,
+			pg_table_size(relid), pg_indexes_size(relid),
+			GREATEST(last_analyze, last_autoanalyze)
+		FROM pg_stat_user_tables
+		WHERE schemaname = current_schema()
+		ORDER BY relname;`
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("list table stats: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []*TableStatsModel{}
+	for rows.Next() {
+		table := &TableStatsModel{}
+		if err := rows.Scan(&table.Schema, &table.Table, &table.LiveRows, &table.DeadRows, &table.TableBytes, &table.IndexBytes, &table.AnalyzedAt); err != nil {
+			return nil, fmt.Errorf("list table stats: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list table stats: %w", err)
+	}
+
+	return tables, nil
+}
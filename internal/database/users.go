@@ -0,0 +1,310 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *service) SaveUser(userModel *UserModel) (*UserModel, error) {
+	// Users created by an admin are vouched for, their email needs no verification
+	query := "INSERT INTO users (email, role, email_verified_at) VALUES ($1, $2, NOW()) RETURNING id, email, role, plan, created_at, email_verified_at;"
+
+	inserted := &UserModel{}
+	err := s.db.QueryRow(context.Background(), query, userModel.Email, userModel.Role).Scan(&inserted.Id, &inserted.Email, &inserted.Role, &inserted.Plan, &inserted.CreatedAt, &inserted.EmailVerifiedAt)
+	if err != nil {
+		log.Printf("[database:SaveUser] Error inserting user: %v", err)
+		return nil, fmt.Errorf("save user: %w", unique(err))
+	}
+
+	log.Printf("[database:SaveUser] Inserted user with id: {%d}", inserted.Id)
+
+	return inserted, nil
+}
+
+func (s *service) ListUsers() ([]*UserModel, error) {
+	query := "SELECT id, email, role, plan, created_at, email_verified_at FROM users ORDER BY id;"
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		log.Printf("[database:ListUsers] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*UserModel{}
+	for rows.Next() {
+		user := &UserModel{}
+		if err := rows.Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt); err != nil {
+			log.Printf("[database:ListUsers] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *service) DeleteUser(id int) error {
+	log.Printf("[database:DeleteUser] Deleting user with id: {%d}", id)
+
+	result, err := s.db.Exec(context.Background(), "DELETE FROM users WHERE id = $1;", id)
+	if err != nil {
+		log.Printf("[database:DeleteUser] something went wrong while deleting user {%d}: %v", id, err)
+		return fmt.Errorf("delete user %d: %w", id, err)
+	}
+
+	affected := result.RowsAffected()
+
+	if affected == 0 {
+		return fmt.Errorf("delete user %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) UpdateUserRole(id int, role string) (*UserModel, error) {
+	log.Printf("[database:UpdateUserRole] Setting role of user {%d} to {%s}", id, role)
+
+	query := "UPDATE users SET role = $2 WHERE id = $1 RETURNING id, email, role, plan, created_at, email_verified_at;"
+
+	user := &UserModel{}
+	err := s.db.QueryRow(context.Background(), query, id, role).Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("update role of user %d: %w", id, notFound(err))
+	}
+
+	return user, nil
+}
+
+func (s *service) UpdateUserPlan(id int, plan string) (*UserModel, error) {
+	log.Printf("[database:UpdateUserPlan] Moving user {%d} to plan {%s}", id, plan)
+
+	query := "UPDATE users SET plan = $2 WHERE id = $1 RETURNING id, email, role, plan, created_at, email_verified_at;"
+
+	user := &UserModel{}
+	err := s.db.QueryRow(context.Background(), query, id, plan).Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("update plan of user %d: %w", id, notFound(err))
+	}
+
+	return user, nil
+}
+
+func (s *service) SaveApiKey(apiKeyModel *ApiKeyModel) (*ApiKeyModel, error) {
+	query := "INSERT INTO api_keys (user_id, name, key_prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, user_id, name, key_prefix, scopes, created_at, expires_at;"
+
+	inserted := &ApiKeyModel{}
+	err := s.db.QueryRow(context.Background(), query, apiKeyModel.UserId, apiKeyModel.Name, apiKeyModel.KeyPrefix, apiKeyModel.KeyHash, apiKeyModel.Scopes, apiKeyModel.ExpiresAt).Scan(&inserted.Id, &inserted.UserId, &inserted.Name, &inserted.KeyPrefix, &inserted.Scopes, &inserted.CreatedAt, &inserted.ExpiresAt)
+	if err != nil {
+		log.Printf("[database:SaveApiKey] Error inserting api key: %v", err)
+		return nil, fmt.Errorf("save api key for user %d: %w", apiKeyModel.UserId, err)
+	}
+
+	log.Printf("[database:SaveApiKey] Inserted api key {%s} for user: {%d}", inserted.KeyPrefix, inserted.UserId)
+
+	return inserted, nil
+}
+
+func (s *service) ListApiKeys(userId int) ([]*ApiKeyModel, error) {
+	query := "SELECT id, user_id, name, key_prefix, scopes, created_at, last_used_at, revoked_at, expires_at FROM api_keys WHERE user_id = $1 ORDER BY id;"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		log.Printf("[database:ListApiKeys] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list api keys of user %d: %w", userId, err)
+	}
+	defer rows.Close()
+
+	apiKeys := []*ApiKeyModel{}
+	for rows.Next() {
+		apiKey := &ApiKeyModel{}
+		if err := rows.Scan(&apiKey.Id, &apiKey.UserId, &apiKey.Name, &apiKey.KeyPrefix, &apiKey.Scopes, &apiKey.CreatedAt, &apiKey.LastUsedAt, &apiKey.RevokedAt, &apiKey.ExpiresAt); err != nil {
+			log.Printf("[database:ListApiKeys] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list api keys of user %d: %w", userId, err)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+func (s *service) RevokeApiKey(id int) error {
+	log.Printf("[database:RevokeApiKey] Revoking api key with id: {%d}", id)
+
+	result, err := s.db.Exec(context.Background(), "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL;", id)
+	if err != nil {
+		log.Printf("[database:RevokeApiKey] something went wrong while revoking api key {%d}: %v", id, err)
+		return fmt.Errorf("revoke api key %d: %w", id, err)
+	}
+
+	affected := result.RowsAffected()
+
+	if affected == 0 {
+		return fmt.Errorf("revoke api key %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *service) RevokeApiKeysNamed(userId int, name string) (int64, error) {
+	log.Printf("[database:RevokeApiKeysNamed] Revoking api keys {%s} of user {%d}", name, userId)
+
+	result, err := s.db.Exec(context.Background(), "UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND name = $2 AND revoked_at IS NULL;", userId, name)
+	if err != nil {
+		log.Printf("[database:RevokeApiKeysNamed] something went wrong while revoking api keys of user {%d}: %v", userId, err)
+		return 0, fmt.Errorf("revoke api keys %s of user %d: %w", name, userId, err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (s *service) GetApiKeyByHash(keyHash string) (*ApiKeyModel, error) {
+	query := `UPDATE api_keys SET last_used_at = NOW()
+		FROM users
+		WHERE api_keys.user_id = users.id AND api_keys.key_hash = $1 AND api_keys.revoked_at IS NULL
+		AND (api_keys.expires_at IS NULL OR api_keys.expires_at > NOW())
+		RETURNING api_keys.id, api_keys.user_id, api_keys.name, api_keys.key_prefix, api_keys.scopes, api_keys.created_at, api_keys.last_used_at, api_keys.expires_at,
+			users.id, users.email, users.role, users.plan, users.created_at, users.email_verified_at;`
+
+	apiKey := &ApiKeyModel{KeyHash: keyHash, User: &UserModel{}}
+	err := s.db.QueryRow(context.Background(), query, keyHash).Scan(&apiKey.Id, &apiKey.UserId, &apiKey.Name, &apiKey.KeyPrefix, &apiKey.Scopes, &apiKey.CreatedAt, &apiKey.LastUsedAt, &apiKey.ExpiresAt,
+		&apiKey.User.Id, &apiKey.User.Email, &apiKey.User.Role, &apiKey.User.Plan, &apiKey.User.CreatedAt, &apiKey.User.EmailVerifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get api key by hash: %w", notFound(err))
+	}
+
+	return apiKey, nil
+}
+
+func (s *service) GetApiKey(id int) (*ApiKeyModel, error) {
+	query := "SELECT id, user_id, name, key_prefix, scopes, created_at, last_used_at, revoked_at, expires_at FROM api_keys WHERE id = $1;"
+
+	apiKey := &ApiKeyModel{}
+	err := s.db.QueryRow(context.Background(), query, id).Scan(&apiKey.Id, &apiKey.UserId, &apiKey.Name, &apiKey.KeyPrefix, &apiKey.Scopes, &apiKey.CreatedAt, &apiKey.LastUsedAt, &apiKey.RevokedAt, &apiKey.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("get api key %d: %w", id, notFound(err))
+	}
+
+	return apiKey, nil
+}
+
+func (s *service) AddApiKeyUsage(apiKeyId int, requests int, linksCreated int) (*ApiKeyUsageModel, error) {
+	query := `INSERT INTO api_key_usage (api_key_id, day, requests, links_created) VALUES ($1, (NOW() AT TIME ZONE 'UTC')::date, $2, $3)
+		ON CONFLICT (api_key_id, day) DO UPDATE
+		SET requests = api_key_usage.requests + EXCLUDED.requests, links_created = api_key_usage.links_created + EXCLUDED.links_created
+		RETURNING api_key_id, day, requests, links_created;`
+
+	usage := &ApiKeyUsageModel{}
+	err := s.db.QueryRow(context.Background(), query, apiKeyId, requests, linksCreated).Scan(&usage.ApiKeyId, &usage.Day, &usage.Requests, &usage.LinksCreated)
+	if err != nil {
+		log.Printf("[database:AddApiKeyUsage] Could not count usage of api key {%d}: %v", apiKeyId, err)
+		return nil, fmt.Errorf("add usage of api key %d: %w", apiKeyId, err)
+	}
+
+	return usage, nil
+}
+
+func (s *service) ListApiKeyUsage(apiKeyId int, since time.Time) ([]*ApiKeyUsageModel, error) {
+	query := "SELECT api_key_id, day, requests, links_created FROM api_key_usage WHERE api_key_id = $1 AND day >= $2::date ORDER BY day;"
+
+	rows, err := s.read.Query(context.Background(), query, apiKeyId, since)
+	if err != nil {
+		log.Printf("[database:ListApiKeyUsage] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list usage of api key %d: %w", apiKeyId, err)
+	}
+	defer rows.Close()
+
+	days := []*ApiKeyUsageModel{}
+	for rows.Next() {
+		usage := &ApiKeyUsageModel{}
+		if err := rows.Scan(&usage.ApiKeyId, &usage.Day, &usage.Requests, &usage.LinksCreated); err != nil {
+			log.Printf("[database:ListApiKeyUsage] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list usage of api key %d: %w", apiKeyId, err)
+		}
+		days = append(days, usage)
+	}
+
+	return days, rows.Err()
+}
+
+func (s *service) GetOrCreateUserByIdentity(identity *UserIdentityModel) (*UserModel, error) {
+	log.Printf("[database:GetOrCreateUserByIdentity] Signing in {%s} identity {%s}", identity.Provider, identity.Subject)
+
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		log.Printf("[database:GetOrCreateUserByIdentity] Could not begin transaction: %v", err)
+		return nil, fmt.Errorf("sign in %s identity %s: %w", identity.Provider, identity.Subject, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	user := &UserModel{}
+
+	query := `SELECT users.id, users.email, users.role, users.plan, users.created_at, users.email_verified_at
+		FROM user_identities JOIN users ON users.id = user_identities.user_id
+		WHERE user_identities.provider = $1 AND user_identities.subject = $2;`
+
+	err = tx.QueryRow(context.Background(), query, identity.Provider, identity.Subject).Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[database:GetOrCreateUserByIdentity] Could not look up the identity: %v", err)
+		return nil, fmt.Errorf("sign in %s identity %s: %w", identity.Provider, identity.Subject, err)
+	}
+
+	// First sign in with this identity, link it to the user owning the email or create one. Providers only hand out
+	// verified emails, which verifies the one of the user. The password of an account never verified is dropped,
+	// whoever signed up with the email before its owner must not keep a way in
+	query = `INSERT INTO users (email, role, email_verified_at) VALUES ($1, 'editor', NOW())
+		ON CONFLICT (email) DO UPDATE SET email_verified_at = COALESCE(users.email_verified_at, NOW()),
+			password_hash = CASE WHEN users.email_verified_at IS NULL THEN NULL ELSE users.password_hash END
+		RETURNING id, email, role, plan, created_at, email_verified_at;`
+
+	err = tx.QueryRow(context.Background(), query, identity.Email).Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt)
+	if err != nil {
+		log.Printf("[database:GetOrCreateUserByIdentity] Could not save user: %v", err)
+		return nil, fmt.Errorf("sign in %s identity %s: %w", identity.Provider, identity.Subject, err)
+	}
+
+	query = "INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4);"
+	if _, err := tx.Exec(context.Background(), query, user.Id, identity.Provider, identity.Subject, identity.Email); err != nil {
+		log.Printf("[database:GetOrCreateUserByIdentity] Could not link identity: %v", err)
+		return nil, fmt.Errorf("sign in %s identity %s: %w", identity.Provider, identity.Subject, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("sign in %s identity %s: %w", identity.Provider, identity.Subject, err)
+	}
+
+	log.Printf("[database:GetOrCreateUserByIdentity] Linked {%s} identity to user {%d}", identity.Provider, user.Id)
+
+	return user, nil
+}
+
+func (s *service) ListUserIdentities(userId int) ([]*UserIdentityModel, error) {
+	query := "SELECT id, user_id, provider, subject, email, created_at FROM user_identities WHERE user_id = $1 ORDER BY id;"
+
+	rows, err := s.db.Query(context.Background(), query, userId)
+	if err != nil {
+		log.Printf("[database:ListUserIdentities] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list identities of user %d: %w", userId, err)
+	}
+	defer rows.Close()
+
+	identities := []*UserIdentityModel{}
+	for rows.Next() {
+		identity := &UserIdentityModel{}
+		if err := rows.Scan(&identity.Id, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			log.Printf("[database:ListUserIdentities] Error scanning row: %v", err)
+			return nil, fmt.Errorf("list identities of user %d: %w", userId, err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func (s *service) SignUp(email string, passwordHash string) (*UserModel, error) {
+	query := `INSERT INTO users (email, role, password_hash) VALUES ($1, 'editor', $2)
+		RETURNING id, email, role, plan, created_at, email_verified_at;`
+
+	inserted := &UserModel{}
+	err := s.db.QueryRow(context.Background(), query, email, passwordHash).Scan(&inserted.Id, &inserted.Email, &inserted.Role, &inserted.Plan, &inserted.CreatedAt, &inserted.EmailVerifiedAt)
+	if err != nil {
+		log.Printf("[database:SignUp] Error inserting user: %v", err)
+		return nil, fmt.Errorf("sign up %s: %w", email, unique(err))
+	}
+
+	log.Printf("[database:SignUp] Signed up user {%d}", inserted.Id)
+
+	return inserted, nil
+}
+
+func (s *service) SaveEmailVerification(verificationModel *EmailVerificationModel) (*EmailVerificationModel, error) {
+	query := `INSERT INTO email_verifications (user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, email, token_hash, created_at, expires_at;`
+
+	inserted := &EmailVerificationModel{}
+	err := s.db.QueryRow(context.Background(), query, verificationModel.UserId, verificationModel.Email, verificationModel.TokenHash, verificationModel.ExpiresAt).
+		Scan(&inserted.Id, &inserted.UserId, &inserted.Email, &inserted.TokenHash, &inserted.CreatedAt, &inserted.ExpiresAt)
+	if err != nil {
+		log.Printf("[database:SaveEmailVerification] Error inserting email verification: %v", err)
+		return nil, fmt.Errorf("save email verification of user %d: %w", verificationModel.UserId, err)
+	}
+
+	return inserted, nil
+}
+
+func (s *service) VerifyEmail(tokenHash string) (*UserModel, error) {
+	tx, err := s.db.Begin(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("verify email: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `UPDATE email_verifications SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND NOW() < expires_at
+		RETURNING user_id, email;`
+
+	var userId int
+	var email string
+	if err := tx.QueryRow(context.Background(), query, tokenHash).Scan(&userId, &email); err != nil {
+		return nil, fmt.Errorf("verify email: %w", notFound(err))
+	}
+
+	// A verification sent to a previous email of the user doesn't verify the current one
+	query = `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1 AND email = $2
+		RETURNING id, email, role, plan, created_at, email_verified_at;`
+	user := &UserModel{}
+	if err := tx.QueryRow(context.Background(), query, userId, email).Scan(&user.Id, &user.Email, &user.Role, &user.Plan, &user.CreatedAt, &user.EmailVerifiedAt); err != nil {
+		return nil, fmt.Errorf("verify email of user %d: %w", userId, notFound(err))
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, fmt.Errorf("verify email of user %d: %w", userId, err)
+	}
+
+	log.Printf("[database:VerifyEmail] Verified the email of user {%d}", userId)
+
+	return user, nil
+}
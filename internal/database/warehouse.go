@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *service) ListClicksForExport(afterId int64, before time.Time, limit int) ([]*ClickEventModel, error) {
+	query := `SELECT ce.id, ce.short_url_id, su.short_code, COALESCE(ce.ip, ''), COALESCE(ce.ip_hash, ''), COALESCE(ce.user_agent, ''), COALESCE(ce.referrer, ''), ce.clicked_at
+		FROM click_events ce
+		JOIN short_url su ON su.id = ce.short_url_id
+		WHERE ce.id > $1 AND ce.clicked_at < $2
+		ORDER BY ce.id LIMIT $3;`
+
+	rows, err := s.read.Query(context.Background(), query, afterId, before, limit)
+	if err != nil {
+		log.Printf("[database:ListClicksForExport] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list clicks for export: %w", err)
+	}
+	defer rows.Close()
+
+	clicks := []*ClickEventModel{}
+	for rows.Next() {
+		click := &ClickEventModel{}
+		if err := rows.Scan(&click.Id, &click.ShortUrlId, &click.ShortCode, &click.Ip, &click.IpHash, &click.UserAgent, &click.Referrer, &click.ClickedAt); err != nil {
+			return nil, fmt.Errorf("list clicks for export: %w", err)
+		}
+		clicks = append(clicks, click)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list clicks for export: %w", err)
+	}
+
+	return clicks, nil
+}
+
+// ListLinksForExport dates links by their own updated_at, leaving out clicks, which are exported as click events
+func (s *service) ListLinksForExport(afterUpdatedAt time.Time, afterId int, before time.Time, limit int) ([]*ShortUrlModel, error) {
+	query := `SELECT id, link, exp_time_minutes, short_code, created_at, owner_id, organization_id, domain_id, namespace_id,
+			disabled_at, COALESCE(disabled_reason, ''), moderation_state, visibility, updated_at
+		FROM short_url
+		WHERE (updated_at, id) > ($1, $2) AND updated_at < $3
+		ORDER BY updated_at, id LIMIT $4;`
+
+	rows, err := s.read.Query(context.Background(), query, afterUpdatedAt, afterId, before, limit)
+	if err != nil {
+		log.Printf("[database:ListLinksForExport] Something went wrong: %v", err)
+		return nil, fmt.Errorf("list links for export: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*ShortUrlModel{}
+	for rows.Next() {
+		link := &ShortUrlModel{}
+		err := rows.Scan(&link.Id, &link.Link, &link.ExpTimeMinutes, &link.ShortCode, &link.CreatedAt, &link.OwnerId, &link.OrganizationId, &link.DomainId, &link.NamespaceId,
+			&link.DisabledAt, &link.DisabledReason, &link.ModerationState, &link.Visibility, &link.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("list links for export: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list links for export: %w", err)
+	}
+
+	return links, nil
+}
+
+// GetExportCursor returns a cursor at the start of the stream when it was never exported
+func (s *service) GetExportCursor(stream string) (*ExportCursorModel, error) {
+	cursor := &ExportCursorModel{Stream: stream}
+	err := s.db.QueryRow(context.Background(), "SELECT last_id, last_updated_at, exported_at FROM warehouse_exports WHERE stream = $1;", stream).
+		Scan(&cursor.LastId, &cursor.LastUpdatedAt, &cursor.ExportedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get export cursor of %s: %w", stream, err)
+	}
+
+	return cursor, nil
+}
+
+func (s *service) SaveExportCursor(cursor *ExportCursorModel) error {
+	query := `INSERT INTO warehouse_exports (stream, last_id, last_updated_at, exported_at) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (stream) DO UPDATE SET last_id = EXCLUDED.last_id, last_updated_at = EXCLUDED.last_updated_at, exported_at = EXCLUDED.exported_at;`
+
+	lastUpdatedAt := cursor.LastUpdatedAt
+	if lastUpdatedAt.IsZero() {
+		lastUpdatedAt = time.Unix(0, 0)
+	}
+	if _, err := s.db.Exec(context.Background(), query, cursor.Stream, cursor.LastId, lastUpdatedAt); err != nil {
+		log.Printf("[database:SaveExportCursor] Could not save the cursor of {%s}: %v", cursor.Stream, err)
+		return fmt.Errorf("save export cursor of %s: %w", cursor.Stream, err)
+	}
+
+	return nil
+}
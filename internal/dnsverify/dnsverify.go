@@ -0,0 +1,70 @@
+// Package dnsverify proves the ownership of a domain through a TXT record holding a token handed out beforehand.
+package dnsverify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// The record is looked up on a subdomain so it doesn't get in the way of the records of the domain itself
+	recordPrefix = "_url-shortner-challenge."
+	valuePrefix  = "url-shortner-verification="
+)
+
+// PendingTTL is how long a domain may wait for its verification before it is released for others to claim
+const PendingTTL = 7 * 24 * time.Hour
+
+// ErrNotVerified is returned when none of the TXT records holds the token
+var ErrNotVerified = errors.New("verification record not found")
+
+// Resolver looks up TXT records, net.DefaultResolver implements it
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NewToken returns a random verification token.
+func NewToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// RecordName is the name of the TXT record proving the ownership of hostname.
+func RecordName(hostname string) string {
+	return recordPrefix + hostname
+}
+
+// RecordValue is the content the TXT record must have for token.
+func RecordValue(token string) string {
+	return valuePrefix + token
+}
+
+// Check looks for the TXT record of hostname holding token. It returns ErrNotVerified when the record is missing
+// or holds another token, and the lookup error when the DNS couldn't answer.
+func Check(ctx context.Context, resolver Resolver, hostname string, token string) error {
+	records, err := resolver.LookupTXT(ctx, RecordName(hostname))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return ErrNotVerified
+		}
+		return fmt.Errorf("look up %s: %w", RecordName(hostname), err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == RecordValue(token) {
+			return nil
+		}
+	}
+
+	return ErrNotVerified
+}
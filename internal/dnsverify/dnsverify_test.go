@@ -0,0 +1,45 @@
+package dnsverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	records, ok := f.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestCheck(t *testing.T) {
+	lookupErr := &net.DNSError{Err: "i/o timeout", Name: RecordName("go.example.com"), IsTimeout: true}
+
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		expected error
+	}{
+		{"matching record", &fakeResolver{records: map[string][]string{"_url-shortner-challenge.go.example.com": {"v=spf1 -all", "url-shortner-verification=abc"}}}, nil},
+		{"other token", &fakeResolver{records: map[string][]string{"_url-shortner-challenge.go.example.com": {"url-shortner-verification=xyz"}}}, ErrNotVerified},
+		{"record on the domain itself", &fakeResolver{records: map[string][]string{"go.example.com": {"url-shortner-verification=abc"}}}, ErrNotVerified},
+		{"lookup failure", &fakeResolver{err: lookupErr}, lookupErr},
+	}
+
+	for _, tt := range tests {
+		if err := Check(context.Background(), tt.resolver, "go.example.com", "abc"); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
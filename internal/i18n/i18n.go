@@ -0,0 +1,179 @@
+// Package i18n translates the messages shown to visitors. Translations are embedded in the binary, one JSON file
+// per language under locales, and a deployment can override or add some with its own files, see Load. English is
+// the fallback for missing languages and messages.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Language used when none of the accepted ones is translated
+const Default = "en"
+
+// Keys of the messages. Each one has a ".title" and a ".message" translation
+const (
+	ServiceUnavailable = "service_unavailable"
+	LinkNotFound       = "link_not_found"
+	LinkDisabled       = "link_disabled"
+	LinkExpired        = "link_expired"
+	SomethingWentWrong = "something_went_wrong"
+)
+
+//go:embed locales/*.json
+var files embed.FS
+
+// Languages written from right to left
+var rtlLanguages = map[string]bool{"ar": true, "fa": true, "he": true, "ur": true}
+
+// Messages keyed by language, then by key
+var bundles = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	loaded, err := readLocales(files, "locales")
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+// readLocales reads the JSON files of dir, named after their language like pt.json or pt-br.json
+func readLocales(fsys fs.FS, dir string) (map[string]map[string]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := map[string]map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("invalid locale %s: %w", entry.Name(), err)
+		}
+		loaded[strings.ToLower(strings.TrimSuffix(entry.Name(), ".json"))] = messages
+	}
+
+	return loaded, nil
+}
+
+// Load reads the translations of the deployment from the JSON files in LOCALES_DIR. Their messages override the
+// embedded ones, and files of other languages add them. It does nothing when LOCALES_DIR isn't set.
+func Load() error {
+	dir := os.Getenv("LOCALES_DIR")
+	if dir == "" {
+		return nil
+	}
+
+	loaded, err := readLocales(os.DirFS(dir), ".")
+	if err != nil {
+		return fmt.Errorf("read LOCALES_DIR: %w", err)
+	}
+
+	for language, messages := range loaded {
+		if bundles[language] == nil {
+			bundles[language] = map[string]string{}
+		}
+		for key, message := range messages {
+			bundles[language][key] = message
+		}
+	}
+
+	return nil
+}
+
+// Languages returns the translated languages, sorted.
+func Languages() []string {
+	languages := make([]string, 0, len(bundles))
+	for language := range bundles {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Direction returns "rtl" for the languages written from right to left, like Arabic or Hebrew, and "ltr" for the
+// others. Regional tags follow their base language.
+func Direction(language string) string {
+	base, _, _ := strings.Cut(language, "-")
+	if rtlLanguages[base] {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// Message returns the translation of key in the language, falling back on English and then on the key itself.
+func Message(language string, key string) string {
+	if message, ok := bundles[language][key]; ok {
+		return message
+	}
+	base, _, _ := strings.Cut(language, "-")
+	if message, ok := bundles[base][key]; ok {
+		return message
+	}
+	if message, ok := bundles[Default][key]; ok {
+		return message
+	}
+	return key
+}
+
+// FromRequest returns the language to answer the request in, see Negotiate.
+func FromRequest(r *http.Request) string {
+	return Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// Negotiate picks the translated language the client prefers from an Accept-Language header, e.g.
+// "pt-BR,pt;q=0.9,en;q=0.8". A regional tag matches its base language when the region isn't translated.
+func Negotiate(acceptLanguage string) string {
+	type accepted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []accepted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if tag == "" || quality <= 0 {
+			continue
+		}
+		tags = append(tags, accepted{strings.ToLower(tag), quality})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	for _, accepted := range tags {
+		if _, ok := bundles[accepted.tag]; ok {
+			return accepted.tag
+		}
+		base, _, _ := strings.Cut(accepted.tag, "-")
+		if _, ok := bundles[base]; ok {
+			return base
+		}
+	}
+
+	return Default
+}
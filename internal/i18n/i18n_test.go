@@ -0,0 +1,112 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{"", Default},
+		{"fr", "fr"},
+		{"pt-BR,pt;q=0.9,en;q=0.8", "pt"},
+		{"ja,es;q=0.5", "es"},
+		{"en;q=0.2,de;q=0.7", "de"},
+		{"*", Default},
+		{"ja", Default},
+		{"es;q=0,fr;q=invalid", Default},
+	}
+
+	for _, tt := range tests {
+		if language := Negotiate(tt.acceptLanguage); language != tt.expected {
+			t.Errorf("%q: expected %s; got %s", tt.acceptLanguage, tt.expected, language)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	if message := Message("es", LinkExpired+".title"); message != "Enlace caducado" {
+		t.Errorf("expected the Spanish translation; got %q", message)
+	}
+	if message := Message("ja", LinkExpired+".title"); message != "Link expired" {
+		t.Errorf("expected the English fallback; got %q", message)
+	}
+	if message := Message("es", "unknown"); message != "unknown" {
+		t.Errorf("expected the key of an unknown message; got %q", message)
+	}
+}
+
+func TestLocalesAreComplete(t *testing.T) {
+	for _, language := range Languages() {
+		for key := range bundles[Default] {
+			if _, ok := bundles[language][key]; !ok {
+				t.Errorf("%s: missing translation of %s", language, key)
+			}
+		}
+	}
+}
+
+func TestDirection(t *testing.T) {
+	tests := []struct {
+		language string
+		expected string
+	}{
+		{"en", "ltr"},
+		{"ar", "rtl"},
+		{"he", "rtl"},
+		{"fa-ir", "rtl"},
+		{"pt-br", "ltr"},
+	}
+
+	for _, tt := range tests {
+		if direction := Direction(tt.language); direction != tt.expected {
+			t.Errorf("%s: expected %s; got %s", tt.language, tt.expected, direction)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	defer func(embedded map[string]map[string]string) { bundles = embedded }(bundles)
+	bundles = mustLoad()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"es.json":    `{"link_expired.title": "Este enlace ya no existe"}`,
+		"pt-BR.json": `{"link_expired.message": "Esse link curto expirou."}`,
+		"notes.txt":  "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("LOCALES_DIR", dir)
+
+	if err := Load(); err != nil {
+		t.Fatalf("expected the locales to load; got %v", err)
+	}
+
+	if message := Message("es", LinkExpired+".title"); message != "Este enlace ya no existe" {
+		t.Errorf("expected the overridden message; got %q", message)
+	}
+	if message := Message("es", LinkExpired+".message"); message != "Este enlace corto ha caducado." {
+		t.Errorf("expected the embedded message to be kept; got %q", message)
+	}
+	if language := Negotiate("pt-BR"); language != "pt-br" {
+		t.Errorf("expected the added language; got %s", language)
+	}
+	if message := Message("pt-br", LinkExpired+".title"); message != "Link expirado" {
+		t.Errorf("expected the messages missing from a regional language to fall back on its base; got %q", message)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "fr.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(); err == nil {
+		t.Errorf("expected an invalid locale to fail")
+	}
+}
@@ -0,0 +1,53 @@
+package idn
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Scripts whose letters are mistaken for one another
+var lookAlikeScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+}
+
+// Cyrillic and Greek letters drawn like Latin ones
+const latinLookAlikes = "аеорсухіјѕԁԛԝһӏαικνορτυχ"
+
+// Warnings explains why a host may impersonate another one: a label mixing Latin, Cyrillic or Greek letters, as
+// in "pаypal" with a Cyrillic "а", or only made of Cyrillic or Greek letters that look like Latin ones. It works on
+// both forms of the host and returns nothing for the usual domains.
+func Warnings(host string) []string {
+	var warnings []string
+	for _, label := range strings.Split(ToUnicode(strings.ToLower(host)), ".") {
+		scripts := []string{}
+		lookAlikes := true
+		for _, script := range lookAlikeScripts {
+			found := false
+			for _, r := range label {
+				if unicode.Is(script.table, r) {
+					found = true
+					if script.table != unicode.Latin && !strings.ContainsRune(latinLookAlikes, r) {
+						lookAlikes = false
+					}
+				}
+			}
+			if found {
+				scripts = append(scripts, script.name)
+			}
+		}
+
+		switch {
+		case len(scripts) > 1:
+			warnings = append(warnings, fmt.Sprintf("%q mixes %s letters", label, strings.Join(scripts, " and ")))
+		case len(scripts) == 1 && scripts[0] != "Latin" && lookAlikes:
+			warnings = append(warnings, fmt.Sprintf("%q only has %s letters that look like Latin ones", label, scripts[0]))
+		}
+	}
+	return warnings
+}
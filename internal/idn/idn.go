@@ -0,0 +1,39 @@
+// Package idn converts internationalized domain names between their Unicode form, shown to people, and their
+// punycode form, stored and sent to browsers. It also spots the look-alike characters used to impersonate domains.
+package idn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrInvalidHost = errors.New("invalid domain name")
+
+// profile maps and validates hosts the way browsers look them up (UTS #46), and rejects those DNS can't resolve,
+// e.g. with an empty label or a label longer than 63 characters
+var profile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// ToASCII returns the punycode form of a host, e.g. "xn--mnchen-3ya.de" for "München.de". Labels are lowercased and
+// normalized first. Hosts that aren't valid domain names fail with ErrInvalidHost.
+func ToASCII(host string) (string, error) {
+	ascii, err := profile.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %v", ErrInvalidHost, host, err)
+	}
+	return ascii, nil
+}
+
+// ToUnicode returns the Unicode form of a host, e.g. "münchen.de" for "xn--mnchen-3ya.de". Labels that don't decode
+// are kept as they are.
+func ToUnicode(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if decoded, err := idna.Lookup.ToUnicode(label); err == nil {
+			labels[i] = decoded
+		}
+	}
+	return strings.Join(labels, ".")
+}
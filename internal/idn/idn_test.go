@@ -0,0 +1,82 @@
+package idn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+		err      error
+	}{
+		{"example.com", "example.com", nil},
+		{"Example.COM.", "example.com", nil},
+		{"München.de", "xn--mnchen-3ya.de", nil},
+		{"bücher.example", "xn--bcher-kva.example", nil},
+		{"пример.рф", "xn--e1afmkfd.xn--p1ai", nil},
+		{"中文.com", "xn--fiq228c.com", nil},
+		{"xn--mnchen-3ya.de", "xn--mnchen-3ya.de", nil},
+		{"xn--zz.de", "", ErrInvalidHost},
+		{"-example.com", "", ErrInvalidHost},
+		{"exa mple.com", "", ErrInvalidHost},
+		{"ex☃ample.com", "xn--example-bz6d.com", nil},
+		{"ＥＸＡＭＰＬＥ.com", "example.com", nil},
+		{"ex_ample.com", "", ErrInvalidHost},
+		{"a..com", "", ErrInvalidHost},
+		{strings.Repeat("a", 64) + ".com", "", ErrInvalidHost},
+	}
+
+	for _, tt := range tests {
+		ascii, err := ToASCII(tt.host)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected %v; got %v", tt.host, tt.err, err)
+		}
+		if ascii != tt.expected {
+			t.Errorf("%q: expected %q; got %q", tt.host, tt.expected, ascii)
+		}
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{"xn--mnchen-3ya.de", "münchen.de"},
+		{"xn--e1afmkfd.xn--p1ai", "пример.рф"},
+		{"xn--pypal-4ve.com", "pаypal.com"},
+		{"xn--zz.de", "xn--zz.de"},
+	}
+
+	for _, tt := range tests {
+		if unicode := ToUnicode(tt.host); unicode != tt.expected {
+			t.Errorf("%q: expected %q; got %q", tt.host, tt.expected, unicode)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected int
+	}{
+		{"example.com", 0},
+		{"münchen.de", 0},
+		{"москва.рф", 0},
+		{"中文.com", 0},
+		{"xn--pypal-4ve.com", 1},
+		{"аррle.com", 1},
+		{"ехаmрlе.соm", 2},
+		{"сосо.com", 1},
+	}
+
+	for _, tt := range tests {
+		if warnings := Warnings(tt.host); len(warnings) != tt.expected {
+			t.Errorf("%q: expected %d warnings; got %v", tt.host, tt.expected, warnings)
+		}
+	}
+}
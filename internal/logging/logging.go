@@ -0,0 +1,104 @@
+// Package logging sets up the structured logger of the binaries from LOG_LEVEL, LOG_FORMAT and LOG_FILE. The lines
+// of the standard log package go through it too, at the info level.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 5
+)
+
+// Setup replaces the default loggers, slog's and the log package's, with one configured by:
+//   - LOG_LEVEL: debug, info, warn or error, info by default
+//   - LOG_FORMAT: text or json, text by default
+//   - LOG_FILE: a file to append to instead of stderr, rotated once it grows past LOG_MAX_SIZE_MB, keeping
+//     LOG_MAX_BACKUPS old files
+//
+// component names the binary and is added to every line.
+func Setup(component string) error {
+	var level slog.Level
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid LOG_LEVEL %q: %w", value, err)
+		}
+	}
+
+	out, err := output()
+	if err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format := os.Getenv("LOG_FORMAT"); format {
+	case "", "text":
+		handler = slog.NewTextHandler(out, options)
+	case "json":
+		handler = slog.NewJSONHandler(out, options)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q, expected text or json", format)
+	}
+
+	logger := slog.New(handler).With("component", component)
+	slog.SetDefault(logger)
+
+	// slog.SetDefault would send the log package to the handler too, the bridge does it without the date prefix.
+	// Lines that need another level than info are logged with slog directly
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(bridge{logger: logger})
+	return nil
+}
+
+func output() (io.Writer, error) {
+	path := os.Getenv("LOG_FILE")
+	if path == "" {
+		return os.Stderr, nil
+	}
+
+	maxSizeMB, err := positiveInt("LOG_MAX_SIZE_MB", defaultMaxSizeMB)
+	if err != nil {
+		return nil, err
+	}
+	maxBackups, err := positiveInt("LOG_MAX_BACKUPS", defaultMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := OpenRotating(path, int64(maxSizeMB)<<20, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_FILE: %w", err)
+	}
+	return file, nil
+}
+
+func positiveInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive number", name, value)
+	}
+	return n, nil
+}
+
+// bridge logs the lines of the log package, e.g. "[routes:shortLinkHandler] Could not shorten link {...}"
+type bridge struct {
+	logger *slog.Logger
+}
+
+func (b bridge) Write(p []byte) (int, error) {
+	b.logger.Info(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,126 @@
+// Package mailer sends the emails of the api and the cronjobs, such as the weekly digest, through the SMTP server
+// configured by SMTP_HOST, so that each feature sending emails doesn't wire SMTP itself.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Message is an email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+
+	// Plain text body
+	Text string
+
+	// HTML body, sent along with the text when set
+	HTML string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Nop drops every email, the mailer when SMTP_HOST is unset
+type Nop struct{}
+
+func (Nop) Send(ctx context.Context, message Message) error {
+	log.Printf("[mailer:Nop] Dropping {%s}, SMTP_HOST is unset", message.Subject)
+	return nil
+}
+
+// Capture keeps the emails instead of sending them, for tests
+type Capture struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (c *Capture) Send(ctx context.Context, message Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+// Messages returns the emails sent so far, oldest first
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message(nil), c.messages...)
+}
+
+// Configured reports whether emails are sent, SMTP_HOST being set
+func Configured() bool {
+	return os.Getenv("SMTP_HOST") != ""
+}
+
+// CheckConfig validates the branding, the templates overridden in MAIL_TEMPLATES_DIR, PUBLIC_BASE_URL, and SMTP_PORT
+// and MAIL_FROM, required along with SMTP_HOST.
+func CheckConfig() error {
+	if _, err := brandingFromEnv(); err != nil {
+		return err
+	}
+	if err := checkTemplates(); err != nil {
+		return err
+	}
+	if value := os.Getenv("PUBLIC_BASE_URL"); value != "" {
+		if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("invalid PUBLIC_BASE_URL %q", value)
+		}
+	}
+	if !Configured() {
+		return nil
+	}
+	if _, err := smtpPort(); err != nil {
+		return err
+	}
+	if _, err := mail.ParseAddress(os.Getenv("MAIL_FROM")); err != nil {
+		return fmt.Errorf("invalid MAIL_FROM %q: %w", os.Getenv("MAIL_FROM"), err)
+	}
+	return nil
+}
+
+// New returns the SMTP mailer configured by SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM, or
+// Nop when SMTP_HOST is unset or the configuration is invalid
+func New() Mailer {
+	if !Configured() {
+		return Nop{}
+	}
+	if err := CheckConfig(); err != nil {
+		log.Printf("[mailer:New] Dropping every email: %v", err)
+		return Nop{}
+	}
+
+	port, _ := smtpPort()
+	return &SMTP{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     port,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("MAIL_FROM"),
+	}
+}
+
+// smtpPort reads SMTP_PORT, 587 by default
+func smtpPort() (int, error) {
+	value := os.Getenv("SMTP_PORT")
+	if value == "" {
+		return 587, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid SMTP_PORT %q", value)
+	}
+	return port, nil
+}
@@ -0,0 +1,147 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		host     string
+		port     string
+		from     string
+		expected bool
+	}{
+		{"", "", "", true},
+		{"smtp.example.com", "", "Links <links@example.com>", true},
+		{"smtp.example.com", "465", "links@example.com", true},
+		{"smtp.example.com", "smtp", "links@example.com", false},
+		{"smtp.example.com", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("SMTP_HOST", tt.host)
+		t.Setenv("SMTP_PORT", tt.port)
+		t.Setenv("MAIL_FROM", tt.from)
+		if err := CheckConfig(); (err == nil) != tt.expected {
+			t.Errorf("SMTP_HOST=%q SMTP_PORT=%q MAIL_FROM=%q: expected valid %v; got %v", tt.host, tt.port, tt.from, tt.expected, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	if _, ok := New().(Nop); !ok {
+		t.Errorf("expected emails dropped without SMTP_HOST")
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("MAIL_FROM", "links@example.com")
+	if _, ok := New().(*SMTP); !ok {
+		t.Errorf("expected emails sent through SMTP_HOST")
+	}
+}
+
+func TestCompose(t *testing.T) {
+	s := &SMTP{host: "smtp.example.com", from: "Links <links@example.com>"}
+
+	content, err := s.compose(Message{To: "ada@example.com", Subject: "Vos liens cette semaine", Text: "Ligne 1\n" + strings.Repeat("é", 100)}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"From: Links <links@example.com>\r\n", "To: <ada@example.com>\r\n", "Subject: Vos liens cette semaine\r\n", "Content-Transfer-Encoding: quoted-printable\r\n", "Ligne 1\r\n=C3=A9"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected %q in the email; got %s", expected, content)
+		}
+	}
+	for _, line := range strings.Split(string(content), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("expected lines cut for the transport; got one of %d characters", len(line))
+		}
+	}
+
+	if _, err := s.compose(Message{To: "not an email"}, time.Now()); err == nil {
+		t.Errorf("expected an invalid recipient refused")
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// A server accepting a single email, without STARTTLS nor authentication
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ready\r\n"))
+		var transcript strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				conn.Write([]byte("250 localhost\r\n"))
+			case command == "DATA":
+				conn.Write([]byte("354 go ahead\r\n"))
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				conn.Write([]byte("250 queued\r\n"))
+			case command == "QUIT":
+				conn.Write([]byte("221 bye\r\n"))
+				received <- transcript.String()
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("MAIL_FROM", "links@example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := New().Send(ctx, Message{To: "ada@example.com", Subject: "Hello", Text: "Your links"}); err != nil {
+		t.Fatalf("expected the email sent; got %v", err)
+	}
+
+	transcript := <-received
+	for _, expected := range []string{"MAIL FROM:<links@example.com>", "RCPT TO:<ada@example.com>", "Subject: Hello", "Your links"} {
+		if !strings.Contains(transcript, expected) {
+			t.Errorf("expected %q sent; got %s", expected, transcript)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	capture := &Capture{}
+	capture.Send(context.Background(), Message{To: "ada@example.com", Subject: "First"})
+	capture.Send(context.Background(), Message{To: "bob@example.com", Subject: "Second"})
+
+	if messages := capture.Messages(); len(messages) != 2 || messages[1].Subject != "Second" {
+		t.Errorf("expected the emails kept in order; got %+v", messages)
+	}
+}
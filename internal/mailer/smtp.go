@@ -0,0 +1,150 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Port of SMTP over TLS, other ports upgrade with STARTTLS when the server offers it
+const implicitTLSPort = 465
+
+// SMTP sends emails through an SMTP server
+type SMTP struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func (s *SMTP) Send(ctx context.Context, message Message) error {
+	content, err := s.compose(message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	var conn net.Conn
+	if s.port == implicitTLSPort {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: s.host}}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("mailer: connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mailer: greet %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && s.port != implicitTLSPort {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("mailer: starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("mailer: sign in: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(s.from)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("mailer: sender refused: %w", err)
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return fmt.Errorf("mailer: recipient refused: %w", err)
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mailer: send: %w", err)
+	}
+	if _, err := data.Write(content); err != nil {
+		return fmt.Errorf("mailer: send: %w", err)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("mailer: send: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// compose returns message with its headers, the subject encoded for non ascii characters and the bodies as quoted
+// printable so long lines survive. A message with an HTML body is sent as multipart/alternative, the text first
+func (s *SMTP) compose(message Message, now time.Time) ([]byte, error) {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid recipient %q: %w", message.To, err)
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	var content bytes.Buffer
+	headers := [][2]string{
+		{"From", s.from},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(id) + "@" + s.host + ">"},
+		{"MIME-Version", "1.0"},
+	}
+	for _, header := range headers {
+		content.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+
+	if message.HTML == "" {
+		content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		content.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&content, message.Text)
+		return content.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&content)
+	content.WriteString("Content-Type: multipart/alternative; boundary=" + parts.Boundary() + "\r\n\r\n")
+	for _, body := range [][2]string{{"text/plain", message.Text}, {"text/html", message.HTML}} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {body[0] + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeQuotedPrintable(part, body[1])
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) {
+	writer := quotedprintable.NewWriter(w)
+	writer.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	writer.Close()
+}
@@ -0,0 +1,202 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"url-shortner/internal/pages"
+)
+
+// Each email has a text template, defining its "subject" and "content", and an html template defining its "content".
+// They are rendered within layout.txt and layout.html
+//
+//go:embed templates/*
+var embedded embed.FS
+
+// Emails are the templates embedded, by name
+var Emails = []string{"digest", "email_verification", "invitation", "notification", "password_reset"}
+
+// Branding customizes the emails of a deployment. Empty fields keep the defaults
+type Branding struct {
+	Name         string
+	LogoUrl      string
+	PrimaryColor string
+	FooterText   string
+}
+
+var defaultBranding = Branding{Name: "url-shortner", PrimaryColor: "#2563eb"}
+
+// brandingFromEnv reads MAIL_BRAND_NAME, MAIL_LOGO_URL, MAIL_PRIMARY_COLOR and MAIL_FOOTER_TEXT
+func brandingFromEnv() (Branding, error) {
+	branding := Branding{
+		Name:         os.Getenv("MAIL_BRAND_NAME"),
+		LogoUrl:      os.Getenv("MAIL_LOGO_URL"),
+		PrimaryColor: os.Getenv("MAIL_PRIMARY_COLOR"),
+		FooterText:   os.Getenv("MAIL_FOOTER_TEXT"),
+	}
+	if branding.Name == "" {
+		branding.Name = defaultBranding.Name
+	}
+	if branding.PrimaryColor == "" {
+		branding.PrimaryColor = defaultBranding.PrimaryColor
+	}
+
+	if !pages.ValidColor(branding.PrimaryColor) {
+		return defaultBranding, fmt.Errorf("invalid MAIL_PRIMARY_COLOR %q, expected a hex color", branding.PrimaryColor)
+	}
+	if branding.LogoUrl != "" && !strings.HasPrefix(branding.LogoUrl, "https://") {
+		return defaultBranding, fmt.Errorf("invalid MAIL_LOGO_URL %q, expected an https url", branding.LogoUrl)
+	}
+	return branding, nil
+}
+
+// templateFile reads the template name from MAIL_TEMPLATES_DIR when it holds one, embedded otherwise
+func templateFile(name string) (string, error) {
+	if dir := os.Getenv("MAIL_TEMPLATES_DIR"); dir != "" {
+		content, err := os.ReadFile(path.Join(dir, name))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("mailer: read template %s: %w", name, err)
+		}
+	}
+
+	content, err := embedded.ReadFile("templates/" + name)
+	if err != nil {
+		return "", fmt.Errorf("mailer: unknown template %s: %w", name, err)
+	}
+	return string(content), nil
+}
+
+var funcs = map[string]any{
+	"shortUrl":      ShortUrl,
+	"publicBaseUrl": publicBaseUrl,
+	"date":          func(t time.Time) string { return t.UTC().Format("Jan 2") },
+	"dateTime":      func(t time.Time) string { return t.UTC().Format("Mon Jan 2 15:04 MST") },
+	"lastDay":       func(t time.Time) time.Time { return t.AddDate(0, 0, -1) },
+}
+
+// ShortUrl returns the url of shortCode on PUBLIC_BASE_URL, the code alone when it is unset
+func ShortUrl(shortCode string) string {
+	if base := publicBaseUrl(); base != "" {
+		return base + "/short/" + shortCode
+	}
+	return shortCode
+}
+
+func publicBaseUrl() string {
+	return strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/")
+}
+
+// ErrNoBaseUrl is returned for the urls of emails when PUBLIC_BASE_URL is unset
+var ErrNoBaseUrl = errors.New("mailer: PUBLIC_BASE_URL is unset")
+
+// Url returns the url of path, e.g. "/auth/email/verify", on PUBLIC_BASE_URL. Emailed urls never come from the Host
+// of a request: anyone can forge it and have the tokens of the urls sent to their own host. Without PUBLIC_BASE_URL
+// it fails with ErrNoBaseUrl, and such emails aren't sent
+func Url(path string) (string, error) {
+	base := publicBaseUrl()
+	if base == "" {
+		return "", ErrNoBaseUrl
+	}
+	return base + path, nil
+}
+
+// Compose renders the email name to to, its subject and text from name.txt and its html from name.html, with the
+// branding of the deployment. Templates see data as .Data and the branding as .Brand
+func Compose(to string, name string, data any) (Message, error) {
+	branding, err := brandingFromEnv()
+	if err != nil {
+		return Message{}, err
+	}
+	view := struct {
+		Brand Branding
+		Data  any
+	}{branding, data}
+
+	text, err := parseText(name)
+	if err != nil {
+		return Message{}, err
+	}
+	html, err := parseHTML(name)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", view); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s: %w", name, err)
+	}
+	if err := text.ExecuteTemplate(&textBody, "layout", view); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s: %w", name, err)
+	}
+	if err := html.ExecuteTemplate(&htmlBody, "layout", view); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s: %w", name, err)
+	}
+
+	return Message{To: to, Subject: strings.TrimSpace(subject.String()), Text: textBody.String(), HTML: htmlBody.String()}, nil
+}
+
+func parseText(name string) (*texttemplate.Template, error) {
+	tmpl := texttemplate.New(name).Funcs(funcs)
+	for _, file := range []string{"layout.txt", name + ".txt"} {
+		content, err := templateFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl, err = tmpl.Parse(content); err != nil {
+			return nil, fmt.Errorf("mailer: parse %s: %w", file, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// parseHTML parses name.html along with the subject of name.txt, shown as the title of the page
+func parseHTML(name string) (*htmltemplate.Template, error) {
+	tmpl := htmltemplate.New(name).Funcs(funcs)
+	for _, file := range []string{"layout.html", name + ".html", name + ".txt"} {
+		content, err := templateFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if file == name+".txt" {
+			// Only its subject, the content of the text would replace the one of the html
+			content = subjectOf(content)
+		}
+		if tmpl, err = tmpl.Parse(content); err != nil {
+			return nil, fmt.Errorf("mailer: parse %s: %w", file, err)
+		}
+	}
+	return tmpl, nil
+}
+
+var subjectPattern = regexp.MustCompile(`(?s){{-?\s*define "subject"\s*-?}}.*?{{-?\s*end\s*-?}}`)
+
+// subjectOf returns the definition of the subject in a text template
+func subjectOf(content string) string {
+	return subjectPattern.FindString(content)
+}
+
+// checkTemplates parses every email, so broken overrides fail on boot
+func checkTemplates() error {
+	for _, name := range Emails {
+		if _, err := parseText(name); err != nil {
+			return err
+		}
+		if _, err := parseHTML(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+)
+
+func TestComposeTemplate(t *testing.T) {
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt")
+	t.Setenv("MAIL_BRAND_NAME", "Acme Links")
+	t.Setenv("MAIL_PRIMARY_COLOR", "#ff6600")
+	t.Setenv("MAIL_FOOTER_TEXT", "Acme Inc, 1 Main St")
+
+	since := time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)
+	digest := &database.LinkDigestModel{Since: since, Until: since.AddDate(0, 0, 7), Links: 2, Clicks: 12,
+		TopLinks: []*database.DigestLinkModel{{ShortCode: "docs", Link: "https://example.com/?a=1&b=<2>", Clicks: 12}}}
+
+	message, err := Compose("ada@example.com", "digest", digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.To != "ada@example.com" || message.Subject != "Your links this week: 12 clicks" {
+		t.Errorf("expected the subject rendered; got %+v", message)
+	}
+	for _, expected := range []string{"from Jun 9 to Jun 15", "https://sho.rt/short/docs, 12 clicks, to https://example.com/?a=1&b=<2>", "\n-- \nAcme Inc, 1 Main St\n"} {
+		if !strings.Contains(message.Text, expected) {
+			t.Errorf("expected %q in the text; got %s", expected, message.Text)
+		}
+	}
+	for _, expected := range []string{"<title>Your links this week: 12 clicks</title>", "color: #ff6600", "Acme Links", "Acme Inc, 1 Main St", "https://example.com/?a=1&amp;b=&lt;2&gt;"} {
+		if !strings.Contains(message.HTML, expected) {
+			t.Errorf("expected %q in the html; got %s", expected, message.HTML)
+		}
+	}
+
+	if _, err := Compose("ada@example.com", "unknown", digest); err == nil {
+		t.Errorf("expected an unknown template refused")
+	}
+	t.Setenv("MAIL_PRIMARY_COLOR", "red; display: none")
+	if _, err := Compose("ada@example.com", "digest", digest); err == nil {
+		t.Errorf("expected an invalid color refused")
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MAIL_TEMPLATES_DIR", dir)
+	if err := os.WriteFile(filepath.Join(dir, "layout.txt"), []byte(`{{define "layout"}}Hi from {{.Brand.Name}}
+{{template "content" .}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := Compose("ada@example.com", "digest", &database.LinkDigestModel{Clicks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(message.Text, "Hi from url-shortner\n") {
+		t.Errorf("expected the layout overridden; got %s", message.Text)
+	}
+	if !strings.Contains(message.HTML, "<!DOCTYPE html>") {
+		t.Errorf("expected the html layout embedded kept; got %s", message.HTML)
+	}
+	if err := CheckConfig(); err != nil {
+		t.Errorf("expected the override valid; got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "digest.html"), []byte(`{{define "content"}}{{.Data.Clicks}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckConfig(); err == nil {
+		t.Errorf("expected a broken override refused")
+	}
+}
+
+func TestComposeMultipart(t *testing.T) {
+	s := &SMTP{host: "smtp.example.com", from: "links@example.com"}
+
+	content, err := s.compose(Message{To: "ada@example.com", Subject: "Hi", Text: "Plain", HTML: "<p>Rich</p>"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := string(content)
+	for _, expected := range []string{"Content-Type: multipart/alternative; boundary=", "Content-Type: text/plain; charset=utf-8", "Content-Type: text/html; charset=utf-8", "<p>Rich</p>"} {
+		if !strings.Contains(email, expected) {
+			t.Errorf("expected %q in the email; got %s", expected, email)
+		}
+	}
+	if strings.Index(email, "Plain") > strings.Index(email, "<p>Rich</p>") {
+		t.Errorf("expected the text before the html, clients showing the last part they support")
+	}
+}
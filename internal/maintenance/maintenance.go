@@ -0,0 +1,87 @@
+// Package maintenance holds the maintenance switch of the process. While it is on the api refuses writes and
+// serves redirects from its cache, so the database can be worked on without an outage. The switch is stored in the
+// database and followed by every process with Watch, MAINTENANCE_MODE keeps a process in maintenance regardless.
+package maintenance
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultMessage is shown to the clients whose writes are refused unless another one is set
+const DefaultMessage = "The service is under maintenance, links can't be created or changed for now. Short links keep redirecting."
+
+// RefreshInterval is how often Watch reads the stored switch, so a switch made on one replica reaches the others
+const RefreshInterval = 5 * time.Second
+
+var (
+	forced            = os.Getenv("MAINTENANCE_MODE") == "true"
+	configuredMessage = os.Getenv("MAINTENANCE_MESSAGE")
+
+	mu      sync.RWMutex
+	enabled bool
+	message string
+)
+
+// Enabled reports whether the process is in maintenance: MAINTENANCE_MODE is set, or the switch is on
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return forced || enabled
+}
+
+// Message returns the message of the current maintenance, falling back on MAINTENANCE_MESSAGE then DefaultMessage
+func Message() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	switch {
+	case message != "":
+		return message
+	case configuredMessage != "":
+		return configuredMessage
+	default:
+		return DefaultMessage
+	}
+}
+
+// Set switches maintenance on or off in this process. An empty msg means the default message
+func Set(on bool, msg string) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = on
+	message = msg
+}
+
+// Watch reads the stored switch with load every RefreshInterval until ctx is done, and applies it to the process.
+// While load fails, e.g. with the database down for the maintenance itself, the last switch read is kept
+func Watch(ctx context.Context, load func() (bool, string, error)) {
+	refresh := func() {
+		on, msg, err := load()
+		if err != nil {
+			log.Printf("[maintenance:Watch] Could not read the maintenance switch, keeping {%t}: %v", Enabled(), err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if on != enabled {
+			log.Printf("[maintenance:Watch] Maintenance switched to {%t}", on)
+		}
+		enabled, message = on, msg
+	}
+
+	refresh()
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package metrics counts what the api does and sends it to the sink configured by METRICS_SINK, so that code emits
+// a metric once whatever the monitoring system reads it.
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	defaultStatsdAddr = "127.0.0.1:8125"
+	defaultPrefix     = "url_shortner."
+)
+
+// Sink receives the metrics. Tags are "key:value" pairs, sinks without tags drop them
+type Sink interface {
+	Count(name string, value int64, tags ...string)
+	Timing(name string, value time.Duration, tags ...string)
+	Gauge(name string, value float64, tags ...string)
+}
+
+// Nop drops every metric, the sink when METRICS_SINK is unset
+type Nop struct{}
+
+func (Nop) Count(string, int64, ...string)          {}
+func (Nop) Timing(string, time.Duration, ...string) {}
+func (Nop) Gauge(string, float64, ...string)        {}
+
+var sink = New()
+
+// CheckConfig validates METRICS_SINK and STATSD_ADDR.
+func CheckConfig() error {
+	switch kind := os.Getenv("METRICS_SINK"); kind {
+	case "", "none":
+		return nil
+	case "statsd", "dogstatsd":
+		if _, err := NewStatsd(statsdAddr(), prefix(), kind == "dogstatsd"); err != nil {
+			return fmt.Errorf("invalid STATSD_ADDR %q: %w", statsdAddr(), err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid METRICS_SINK %q", kind)
+	}
+}
+
+// New returns the sink configured by METRICS_SINK: "statsd" or "dogstatsd", the latter sending tags too, to
+// STATSD_ADDR. Names are prefixed with METRICS_PREFIX. Metrics are dropped when it is unset or invalid
+func New() Sink {
+	kind := os.Getenv("METRICS_SINK")
+	if kind != "statsd" && kind != "dogstatsd" {
+		return Nop{}
+	}
+
+	statsd, err := NewStatsd(statsdAddr(), prefix(), kind == "dogstatsd")
+	if err != nil {
+		log.Printf("[metrics:New] Invalid STATSD_ADDR, dropping metrics: %v", err)
+		return Nop{}
+	}
+	return statsd
+}
+
+// SetSink replaces the sink metrics are sent to, e.g. to record them in tests
+func SetSink(s Sink) {
+	sink = s
+}
+
+// Count adds value to the counter name.
+func Count(name string, value int64, tags ...string) {
+	sink.Count(name, value, tags...)
+}
+
+// Timing records a duration, such as the latency of a request.
+func Timing(name string, value time.Duration, tags ...string) {
+	sink.Timing(name, value, tags...)
+}
+
+// Gauge records the current value of name.
+func Gauge(name string, value float64, tags ...string) {
+	sink.Gauge(name, value, tags...)
+}
+
+func statsdAddr() string {
+	if addr := os.Getenv("STATSD_ADDR"); addr != "" {
+		return addr
+	}
+	return defaultStatsdAddr
+}
+
+func prefix() string {
+	if prefix, ok := os.LookupEnv("METRICS_PREFIX"); ok {
+		return prefix
+	}
+	return defaultPrefix
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsd(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer listener.Close()
+
+	tests := []struct {
+		name     string
+		tags     bool
+		send     func(s *Statsd)
+		expected string
+	}{
+		{"count", false, func(s *Statsd) { s.Count("links.created", 2, "source:batch") }, "app.links.created:2|c"},
+		{"tagged count", true, func(s *Statsd) { s.Count("links.created", 1, "source:single") }, "app.links.created:1|c|#source:single"},
+		{"timing", true, func(s *Statsd) { s.Timing("http.request_duration", 1500*time.Microsecond) }, "app.http.request_duration:1.5|ms"},
+		{"gauge", true, func(s *Statsd) { s.Gauge("jobs.pending", 3, "kind:import", "tenant:acme") }, "app.jobs.pending:3|g|#kind:import,tenant:acme"},
+	}
+
+	for _, tt := range tests {
+		statsd, err := NewStatsd(listener.LocalAddr().String(), "app.", tt.tags)
+		if err != nil {
+			t.Fatalf("%s: could not create the sink: %v", tt.name, err)
+		}
+		tt.send(statsd)
+
+		buf := make([]byte, 512)
+		listener.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil || string(buf[:n]) != tt.expected {
+			t.Errorf("%s: expected %q; got %q, %v", tt.name, tt.expected, buf[:n], err)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		sink     string
+		addr     string
+		expected bool
+	}{
+		{"", "", true},
+		{"dogstatsd", "127.0.0.1:8125", true},
+		{"statsd", "", true},
+		{"statsd", "no port", false},
+		{"prometheus", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("METRICS_SINK", tt.sink)
+		t.Setenv("STATSD_ADDR", tt.addr)
+		if err := CheckConfig(); (err == nil) != tt.expected {
+			t.Errorf("METRICS_SINK=%q STATSD_ADDR=%q: expected valid %v; got %v", tt.sink, tt.addr, tt.expected, err)
+		}
+	}
+}
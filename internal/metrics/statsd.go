@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statsd sends metrics over UDP in the StatsD line protocol, with the DogStatsD tags extension when Tags is set.
+// Sending never blocks the caller for long and failures are dropped, metrics are best effort
+type Statsd struct {
+	prefix string
+	tags   bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewStatsd returns a sink sending to addr, a host:port. prefix is prepended to every name
+func NewStatsd(addr string, prefix string, tags bool) (*Statsd, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Statsd{prefix: prefix, tags: tags, conn: conn}, nil
+}
+
+func (s *Statsd) Count(name string, value int64, tags ...string) {
+	s.send(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+func (s *Statsd) Timing(name string, value time.Duration, tags ...string) {
+	s.send(name, strconv.FormatFloat(float64(value)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+func (s *Statsd) Gauge(name string, value float64, tags ...string) {
+	s.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// line formats a metric, e.g. "url_shortner.links.created:1|c|#source:api"
+func (s *Statsd) line(name string, value string, kind string, tags []string) string {
+	line := s.prefix + name + ":" + value + "|" + kind
+	if s.tags && len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+func (s *Statsd) send(name string, value string, kind string, tags []string) {
+	line := s.line(name, value, kind, tags)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	s.conn.Write([]byte(line))
+}
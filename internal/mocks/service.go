@@ -0,0 +1,1457 @@
+// Package mocks provides test doubles for the interfaces of the application.
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/pagination"
+)
+
+// Call is a recorded call to a mocked method.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Service is a database.Service recording every call. Each method delegates to the matching Func field
+// when it is set and returns zero values otherwise, except RunInTransaction which runs fn against the mock itself.
+type Service struct {
+	mu    sync.Mutex
+	calls []Call
+
+	HealthFunc                     func() *database.HealthModel
+	SchemaVersionFunc              func() (int64, error)
+	BootstrapSchemaFunc            func() (int, error)
+	CloseFunc                      func() error
+	WatchLinkChangesFunc           func(context.Context, func(string), func())
+	TryAcquireLockFunc             func(context.Context, string) (*database.Lock, error)
+	SaveShortUrlFunc               func(*database.ShortUrlModel) (*database.ShortUrlModel, error)
+	GetShortUrlFunc                func(string) (*database.ShortUrlModel, error)
+	TakenShortCodesFunc            func([]string) ([]string, error)
+	ListShortCodesFunc             func(time.Time) ([]string, error)
+	UpdateTimesClickedFunc         func(string) error
+	GetLinkStatsFunc               func(string, int) (*database.LinkStatsModel, error)
+	DeleteExpiredLinksFunc         func() error
+	FindShortUrlsByLinkFunc        func(string) ([]*database.ShortUrlModel, error)
+	ListShortUrlsFunc              func(pagination.Page) ([]*database.ShortUrlModel, error)
+	DeleteShortUrlFunc             func(string) error
+	ListActiveShortUrlsFunc        func(int, int) ([]*database.ShortUrlModel, error)
+	PurgeShortUrlFunc              func(string) (*database.PurgeSummaryModel, error)
+	CountUserLinksFunc             func(int, time.Time) (*database.LinkCountModel, error)
+	CountApiKeyLinksFunc           func(int, time.Time) (*database.LinkCountModel, error)
+	GlobalStatsFunc                func() (*database.GlobalStatsModel, error)
+	GetLinkSummaryFunc             func(int) (*database.LinkSummaryModel, error)
+	SaveUserFunc                   func(*database.UserModel) (*database.UserModel, error)
+	ListUsersFunc                  func() ([]*database.UserModel, error)
+	DeleteUserFunc                 func(int) error
+	UpdateUserRoleFunc             func(int, string) (*database.UserModel, error)
+	SaveApiKeyFunc                 func(*database.ApiKeyModel) (*database.ApiKeyModel, error)
+	ListApiKeysFunc                func(int) ([]*database.ApiKeyModel, error)
+	RevokeApiKeyFunc               func(int) error
+	RevokeApiKeysNamedFunc         func(int, string) (int64, error)
+	GetApiKeyByHashFunc            func(string) (*database.ApiKeyModel, error)
+	GetApiKeyFunc                  func(int) (*database.ApiKeyModel, error)
+	AddApiKeyUsageFunc             func(int, int, int) (*database.ApiKeyUsageModel, error)
+	ListApiKeyUsageFunc            func(int, time.Time) ([]*database.ApiKeyUsageModel, error)
+	GetOrCreateUserByIdentityFunc  func(*database.UserIdentityModel) (*database.UserModel, error)
+	ListUserIdentitiesFunc         func(int) ([]*database.UserIdentityModel, error)
+	SaveBannedDomainFunc           func(*database.BannedDomainModel) (*database.BannedDomainModel, error)
+	ListBannedDomainsFunc          func() ([]*database.BannedDomainModel, error)
+	DeleteBannedDomainFunc         func(int) error
+	DisableBannedLinksFunc         func() (int64, error)
+	SaveDomainFunc                 func(*database.DomainModel) (*database.DomainModel, error)
+	ListDomainsFunc                func() ([]*database.DomainModel, error)
+	GetDomainByHostnameFunc        func(string) (*database.DomainModel, error)
+	EnqueueJobFunc                 func(*database.JobModel) (*database.JobModel, error)
+	GetJobFunc                     func(int) (*database.JobModel, error)
+	ClaimJobFunc                   func([]string, time.Duration) (*database.JobModel, error)
+	FinishJobFunc                  func(int, []byte, string) error
+	StartJobRunFunc                func(string, time.Duration) error
+	FinishJobRunFunc               func(string) error
+	LastShortUrlDeletionFunc       func() (time.Time, error)
+	FailJobRunFunc                 func(string, string) error
+	ListJobStatusesFunc            func() ([]*database.JobStatusModel, error)
+	GetMaintenanceFunc             func() (*database.MaintenanceModel, error)
+	SetMaintenanceFunc             func(*database.MaintenanceModel) (*database.MaintenanceModel, error)
+	UpsertShortUrlFunc             func(*database.ShortUrlModel) (*database.ShortUrlModel, bool, error)
+	SaveShortUrlsFunc              func([]*database.ShortUrlModel) ([]*database.ShortUrlModel, error)
+	RecordClicksFunc               func([]*database.ClickEventModel) (int64, error)
+	ListClickPartitionsFunc        func() ([]*database.ClickPartitionModel, error)
+	CreateClickPartitionFunc       func(time.Time, time.Time) (*database.ClickPartitionModel, error)
+	DropClickPartitionFunc         func(string) error
+	RefreshStatsViewsFunc          func() error
+	RollupClicksFunc               func(time.Time) (int64, error)
+	PurgeClickRollupsFunc          func(database.AnalyticsScope, time.Time) (int64, error)
+	ListClicksForExportFunc        func(int64, time.Time, int) ([]*database.ClickEventModel, error)
+	ListLinksForExportFunc         func(time.Time, int, time.Time, int) ([]*database.ShortUrlModel, error)
+	GetExportCursorFunc            func(string) (*database.ExportCursorModel, error)
+	SaveExportCursorFunc           func(*database.ExportCursorModel) error
+	DomainStatsFunc                func(int, time.Duration) ([]*database.DomainStatsModel, time.Time, error)
+	LeaderboardFunc                func(int, time.Duration) ([]*database.LeaderboardEntryModel, time.Time, error)
+	SaveJobFileFunc                func(int, []byte) error
+	GetJobFileFunc                 func(int) ([]byte, error)
+	SaveJobFileKeyFunc             func(int, string) error
+	GetJobFileKeyFunc              func(int) (string, error)
+	ListFinishedJobFileKeysFunc    func(time.Time) ([]string, error)
+	DeleteFinishedJobsFunc         func(time.Time) (int64, error)
+	SaveNamespaceFunc              func(*database.NamespaceModel) (*database.NamespaceModel, error)
+	ListNamespacesFunc             func() ([]*database.NamespaceModel, error)
+	GetNamespaceByNameFunc         func(string) (*database.NamespaceModel, error)
+	DeleteNamespaceFunc            func(int) error
+	UpdateDomainBrandingFunc       func(*database.DomainModel) (*database.DomainModel, error)
+	ListOrganizationDomainsFunc    func(int) ([]*database.DomainModel, error)
+	ListUnverifiedDomainsFunc      func() ([]*database.DomainModel, error)
+	GetDomainFunc                  func(int) (*database.DomainModel, error)
+	MarkDomainCheckedFunc          func(int, bool) (*database.DomainModel, error)
+	DeleteUnverifiedDomainsFunc    func(time.Time) (int64, error)
+	DeleteDomainFunc               func(int) error
+	QueueForReviewFunc             func(*database.ReviewModel) (*database.ReviewModel, error)
+	ListReviewsFunc                func(string) ([]*database.ReviewModel, error)
+	ResolveReviewFunc              func(int, bool) error
+	SaveAbuseReportFunc            func(*database.AbuseReportModel) (*database.AbuseReportModel, error)
+	ListAbuseReportsFunc           func(string) ([]*database.AbuseReportModel, error)
+	DismissAbuseReportFunc         func(int) error
+	DisableReportedLinkFunc        func(int) error
+	TransitionModerationFunc       func(*database.TakedownEventModel) (*database.TakedownEventModel, error)
+	ListTakedownEventsFunc         func(string) ([]*database.TakedownEventModel, error)
+	CreateOrganizationFunc         func(*database.OrganizationModel, int) (*database.OrganizationModel, error)
+	ListOrganizationsFunc          func(int) ([]*database.OrganizationModel, error)
+	GetMembershipFunc              func(int, int) (*database.MemberModel, error)
+	ListMembersFunc                func(int) ([]*database.MemberModel, error)
+	RemoveMemberFunc               func(int, int) error
+	UpdateMemberRoleFunc           func(int, int, string) (*database.MemberModel, error)
+	SaveInvitationFunc             func(*database.InvitationModel) (*database.InvitationModel, error)
+	AcceptInvitationFunc           func(string, int) (*database.MemberModel, error)
+	ListOrganizationLinksFunc      func(int, pagination.Page) ([]*database.ShortUrlModel, error)
+	UpdateUserPlanFunc             func(int, string) (*database.UserModel, error)
+	GetOrganizationFunc            func(int) (*database.OrganizationModel, error)
+	UpdateOrganizationPlanFunc     func(int, string) (*database.OrganizationModel, error)
+	ListOrganizationRetentionsFunc func() ([]*database.RetentionModel, error)
+	SetOrganizationRetentionFunc   func(*database.RetentionModel) (*database.RetentionModel, error)
+	CountOrganizationLinksFunc     func(int, time.Time) (*database.LinkCountModel, error)
+	PurgeClickEventsFunc           func(database.AnalyticsScope, time.Time) (int64, error)
+	RecordAuditFunc                func(*database.AuditLogModel) error
+	ListAuditLogFunc               func(database.AuditLogFilter) ([]*database.AuditLogModel, error)
+	RecordClickFunc                func(*database.ClickEventModel) error
+	PurgeRawIpsFunc                func(time.Duration) (int64, error)
+	ExportUserDataFunc             func(int) (*database.UserExportModel, error)
+	RequestUserDeletionFunc        func(int) (*database.DeletionRequestModel, error)
+	GetDeletionRequestFunc         func(int) (*database.DeletionRequestModel, error)
+	ProcessDeletionRequestsFunc    func() (int, error)
+	AnalyzeTablesFunc              func([]string) error
+	VacuumTablesFunc               func([]string) error
+	ListTableStatsFunc             func() ([]*database.TableStatsModel, error)
+	DryRunFunc                     func() database.Service
+	RequestJobRunFunc              func(string) (*database.JobStatusModel, error)
+	ClaimJobRunRequestsFunc        func() ([]string, error)
+	GetEmailPreferencesFunc        func(userId int) (*database.EmailPreferencesModel, error)
+	UpdateEmailPreferencesFunc     func(*database.EmailPreferencesModel) (*database.EmailPreferencesModel, error)
+	ListDigestRecipientsFunc       func(sentBefore time.Time, afterId int, limit int) ([]*database.UserModel, error)
+	GetLinkDigestFunc              func(userId int, since time.Time, until time.Time, top int) (*database.LinkDigestModel, error)
+	MarkDigestSentFunc             func(userId int, at time.Time) error
+	ListInvitationsFunc            func(int) ([]*database.InvitationModel, error)
+	RenewInvitationFunc            func(int, int, string, time.Time) (*database.InvitationModel, error)
+	RevokeInvitationFunc           func(int, int) (*database.InvitationModel, error)
+	GetInvitationFunc              func(int, int) (*database.InvitationModel, error)
+	GetUserPasswordFunc            func(string) (*database.UserPasswordModel, error)
+	SetPasswordFunc                func(int, string) error
+	SavePasswordResetFunc          func(*database.PasswordResetModel) (*database.PasswordResetModel, error)
+	ResetPasswordFunc              func(string, string) (*database.UserModel, error)
+	SignUpFunc                     func(string, string) (*database.UserModel, error)
+	SaveEmailVerificationFunc      func(*database.EmailVerificationModel) (*database.EmailVerificationModel, error)
+	VerifyEmailFunc                func(string) (*database.UserModel, error)
+	SaveSessionFunc                func(*database.SessionModel) (*database.SessionModel, error)
+	GetSessionByHashFunc           func(string, time.Duration) (*database.SessionModel, error)
+	ListSessionsFunc               func(int, time.Duration) ([]*database.SessionModel, error)
+	RevokeSessionFunc              func(int, int) error
+	RevokeSessionsFunc             func(int) (int64, error)
+	DeleteEndedSessionsFunc        func() (int64, error)
+	CountAnonymousLinksFunc        func(time.Time) (int, error)
+	SaveLinkClaimFunc              func(*database.LinkClaimModel) error
+	ClaimLinkFunc                  func(string, int) (*database.ShortUrlModel, error)
+	SaveLinkEditTokenFunc          func(*database.LinkEditTokenModel) error
+	GetShortUrlByEditTokenFunc     func(string, string) (*database.ShortUrlModel, error)
+	EditShortUrlFunc               func(string, string, int, *database.ReviewModel) (*database.ShortUrlModel, error)
+	ListUnsentNotificationsFunc    func(int, int) ([]*database.NotificationModel, error)
+	MarkNotificationSentFunc       func(int, time.Time) error
+	RunInTransactionFunc           func(fn func(database.Service) error) error
+}
+
+var _ database.Service = (*Service)(nil)
+
+func (m *Service) record(method string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the recorded calls, oldest first.
+func (m *Service) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the recorded calls to method, oldest first.
+func (m *Service) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range m.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (m *Service) Health() *database.HealthModel {
+	m.record("Health")
+	if m.HealthFunc != nil {
+		return m.HealthFunc()
+	}
+	return nil
+}
+
+func (m *Service) WatchLinkChanges(ctx context.Context, changed func(string), missed func()) {
+	m.record("WatchLinkChanges")
+	if m.WatchLinkChangesFunc != nil {
+		m.WatchLinkChangesFunc(ctx, changed, missed)
+	}
+}
+
+func (m *Service) TryAcquireLock(ctx context.Context, key string) (*database.Lock, error) {
+	m.record("TryAcquireLock", key)
+	if m.TryAcquireLockFunc != nil {
+		return m.TryAcquireLockFunc(ctx, key)
+	}
+	return nil, nil
+}
+
+func (m *Service) SchemaVersion() (int64, error) {
+	m.record("SchemaVersion")
+	if m.SchemaVersionFunc != nil {
+		return m.SchemaVersionFunc()
+	}
+	return 0, nil
+}
+
+func (m *Service) BootstrapSchema() (int, error) {
+	m.record("BootstrapSchema")
+	if m.BootstrapSchemaFunc != nil {
+		return m.BootstrapSchemaFunc()
+	}
+	return 0, nil
+}
+
+func (m *Service) Close() error {
+	m.record("Close")
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	return nil
+}
+
+func (m *Service) SaveShortUrl(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+	m.record("SaveShortUrl", shortUrl)
+	if m.SaveShortUrlFunc != nil {
+		return m.SaveShortUrlFunc(shortUrl)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListShortCodes(since time.Time) ([]string, error) {
+	m.record("ListShortCodes", since)
+	if m.ListShortCodesFunc != nil {
+		return m.ListShortCodesFunc(since)
+	}
+	return nil, nil
+}
+
+func (m *Service) TakenShortCodes(shortCodes []string) ([]string, error) {
+	m.record("TakenShortCodes", shortCodes)
+	if m.TakenShortCodesFunc != nil {
+		return m.TakenShortCodesFunc(shortCodes)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetShortUrl(shortCode string) (*database.ShortUrlModel, error) {
+	m.record("GetShortUrl", shortCode)
+	if m.GetShortUrlFunc != nil {
+		return m.GetShortUrlFunc(shortCode)
+	}
+	return nil, nil
+}
+
+func (m *Service) UpdateTimesClicked(shortCode string) error {
+	m.record("UpdateTimesClicked", shortCode)
+	if m.UpdateTimesClickedFunc != nil {
+		return m.UpdateTimesClickedFunc(shortCode)
+	}
+	return nil
+}
+
+func (m *Service) GetLinkStats(shortCode string, days int) (*database.LinkStatsModel, error) {
+	m.record("GetLinkStats", shortCode, days)
+	if m.GetLinkStatsFunc != nil {
+		return m.GetLinkStatsFunc(shortCode, days)
+	}
+	return nil, nil
+}
+
+func (m *Service) DeleteExpiredLinks() error {
+	m.record("DeleteExpiredLinks")
+	if m.DeleteExpiredLinksFunc != nil {
+		return m.DeleteExpiredLinksFunc()
+	}
+	return nil
+}
+
+func (m *Service) FindShortUrlsByLink(link string) ([]*database.ShortUrlModel, error) {
+	m.record("FindShortUrlsByLink", link)
+	if m.FindShortUrlsByLinkFunc != nil {
+		return m.FindShortUrlsByLinkFunc(link)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListShortUrls(page pagination.Page) ([]*database.ShortUrlModel, error) {
+	m.record("ListShortUrls", page)
+	if m.ListShortUrlsFunc != nil {
+		return m.ListShortUrlsFunc(page)
+	}
+	return nil, nil
+}
+
+func (m *Service) DeleteShortUrl(shortCode string) error {
+	m.record("DeleteShortUrl", shortCode)
+	if m.DeleteShortUrlFunc != nil {
+		return m.DeleteShortUrlFunc(shortCode)
+	}
+	return nil
+}
+
+func (m *Service) ListActiveShortUrls(afterId int, limit int) ([]*database.ShortUrlModel, error) {
+	m.record("ListActiveShortUrls", afterId, limit)
+	if m.ListActiveShortUrlsFunc != nil {
+		return m.ListActiveShortUrlsFunc(afterId, limit)
+	}
+	return nil, nil
+}
+
+func (m *Service) PurgeShortUrl(shortCode string) (*database.PurgeSummaryModel, error) {
+	m.record("PurgeShortUrl", shortCode)
+	if m.PurgeShortUrlFunc != nil {
+		return m.PurgeShortUrlFunc(shortCode)
+	}
+	return nil, nil
+}
+
+func (m *Service) CountUserLinks(userId int, since time.Time) (*database.LinkCountModel, error) {
+	m.record("CountUserLinks", userId, since)
+	if m.CountUserLinksFunc != nil {
+		return m.CountUserLinksFunc(userId, since)
+	}
+	return nil, nil
+}
+
+func (m *Service) CountApiKeyLinks(apiKeyId int, since time.Time) (*database.LinkCountModel, error) {
+	m.record("CountApiKeyLinks", apiKeyId, since)
+	if m.CountApiKeyLinksFunc != nil {
+		return m.CountApiKeyLinksFunc(apiKeyId, since)
+	}
+	return nil, nil
+}
+
+func (m *Service) GlobalStats() (*database.GlobalStatsModel, error) {
+	m.record("GlobalStats")
+	if m.GlobalStatsFunc != nil {
+		return m.GlobalStatsFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) GetLinkSummary(days int) (*database.LinkSummaryModel, error) {
+	m.record("GetLinkSummary", days)
+	if m.GetLinkSummaryFunc != nil {
+		return m.GetLinkSummaryFunc(days)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveUser(user *database.UserModel) (*database.UserModel, error) {
+	m.record("SaveUser", user)
+	if m.SaveUserFunc != nil {
+		return m.SaveUserFunc(user)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListUsers() ([]*database.UserModel, error) {
+	m.record("ListUsers")
+	if m.ListUsersFunc != nil {
+		return m.ListUsersFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) DeleteUser(id int) error {
+	m.record("DeleteUser", id)
+	if m.DeleteUserFunc != nil {
+		return m.DeleteUserFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) UpdateUserRole(id int, role string) (*database.UserModel, error) {
+	m.record("UpdateUserRole", id, role)
+	if m.UpdateUserRoleFunc != nil {
+		return m.UpdateUserRoleFunc(id, role)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveApiKey(apiKey *database.ApiKeyModel) (*database.ApiKeyModel, error) {
+	m.record("SaveApiKey", apiKey)
+	if m.SaveApiKeyFunc != nil {
+		return m.SaveApiKeyFunc(apiKey)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListApiKeys(userId int) ([]*database.ApiKeyModel, error) {
+	m.record("ListApiKeys", userId)
+	if m.ListApiKeysFunc != nil {
+		return m.ListApiKeysFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) RevokeApiKey(id int) error {
+	m.record("RevokeApiKey", id)
+	if m.RevokeApiKeyFunc != nil {
+		return m.RevokeApiKeyFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) RevokeApiKeysNamed(userId int, name string) (int64, error) {
+	m.record("RevokeApiKeysNamed", userId, name)
+	if m.RevokeApiKeysNamedFunc != nil {
+		return m.RevokeApiKeysNamedFunc(userId, name)
+	}
+	return 0, nil
+}
+
+func (m *Service) GetApiKeyByHash(keyHash string) (*database.ApiKeyModel, error) {
+	m.record("GetApiKeyByHash", keyHash)
+	if m.GetApiKeyByHashFunc != nil {
+		return m.GetApiKeyByHashFunc(keyHash)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetApiKey(id int) (*database.ApiKeyModel, error) {
+	m.record("GetApiKey", id)
+	if m.GetApiKeyFunc != nil {
+		return m.GetApiKeyFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *Service) AddApiKeyUsage(apiKeyId int, requests int, linksCreated int) (*database.ApiKeyUsageModel, error) {
+	m.record("AddApiKeyUsage", apiKeyId, requests, linksCreated)
+	if m.AddApiKeyUsageFunc != nil {
+		return m.AddApiKeyUsageFunc(apiKeyId, requests, linksCreated)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListApiKeyUsage(apiKeyId int, since time.Time) ([]*database.ApiKeyUsageModel, error) {
+	m.record("ListApiKeyUsage", apiKeyId, since)
+	if m.ListApiKeyUsageFunc != nil {
+		return m.ListApiKeyUsageFunc(apiKeyId, since)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetOrCreateUserByIdentity(identity *database.UserIdentityModel) (*database.UserModel, error) {
+	m.record("GetOrCreateUserByIdentity", identity)
+	if m.GetOrCreateUserByIdentityFunc != nil {
+		return m.GetOrCreateUserByIdentityFunc(identity)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListUserIdentities(userId int) ([]*database.UserIdentityModel, error) {
+	m.record("ListUserIdentities", userId)
+	if m.ListUserIdentitiesFunc != nil {
+		return m.ListUserIdentitiesFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveBannedDomain(bannedDomain *database.BannedDomainModel) (*database.BannedDomainModel, error) {
+	m.record("SaveBannedDomain", bannedDomain)
+	if m.SaveBannedDomainFunc != nil {
+		return m.SaveBannedDomainFunc(bannedDomain)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListBannedDomains() ([]*database.BannedDomainModel, error) {
+	m.record("ListBannedDomains")
+	if m.ListBannedDomainsFunc != nil {
+		return m.ListBannedDomainsFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) DeleteBannedDomain(id int) error {
+	m.record("DeleteBannedDomain", id)
+	if m.DeleteBannedDomainFunc != nil {
+		return m.DeleteBannedDomainFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) DisableBannedLinks() (int64, error) {
+	m.record("DisableBannedLinks")
+	if m.DisableBannedLinksFunc != nil {
+		return m.DisableBannedLinksFunc()
+	}
+	return 0, nil
+}
+
+func (m *Service) SaveDomain(domain *database.DomainModel) (*database.DomainModel, error) {
+	m.record("SaveDomain", domain)
+	if m.SaveDomainFunc != nil {
+		return m.SaveDomainFunc(domain)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListDomains() ([]*database.DomainModel, error) {
+	m.record("ListDomains")
+	if m.ListDomainsFunc != nil {
+		return m.ListDomainsFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) GetDomainByHostname(hostname string) (*database.DomainModel, error) {
+	m.record("GetDomainByHostname", hostname)
+	if m.GetDomainByHostnameFunc != nil {
+		return m.GetDomainByHostnameFunc(hostname)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListOrganizationDomains(organizationId int) ([]*database.DomainModel, error) {
+	m.record("ListOrganizationDomains", organizationId)
+	if m.ListOrganizationDomainsFunc != nil {
+		return m.ListOrganizationDomainsFunc(organizationId)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListUnverifiedDomains() ([]*database.DomainModel, error) {
+	m.record("ListUnverifiedDomains")
+	if m.ListUnverifiedDomainsFunc != nil {
+		return m.ListUnverifiedDomainsFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) GetDomain(id int) (*database.DomainModel, error) {
+	m.record("GetDomain", id)
+	if m.GetDomainFunc != nil {
+		return m.GetDomainFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *Service) MarkDomainChecked(id int, verified bool) (*database.DomainModel, error) {
+	m.record("MarkDomainChecked", id, verified)
+	if m.MarkDomainCheckedFunc != nil {
+		return m.MarkDomainCheckedFunc(id, verified)
+	}
+	return nil, nil
+}
+
+func (m *Service) UpdateDomainBranding(domain *database.DomainModel) (*database.DomainModel, error) {
+	m.record("UpdateDomainBranding", domain)
+	if m.UpdateDomainBrandingFunc != nil {
+		return m.UpdateDomainBrandingFunc(domain)
+	}
+	return nil, nil
+}
+
+func (m *Service) DeleteUnverifiedDomains(before time.Time) (int64, error) {
+	m.record("DeleteUnverifiedDomains", before)
+	if m.DeleteUnverifiedDomainsFunc != nil {
+		return m.DeleteUnverifiedDomainsFunc(before)
+	}
+	return 0, nil
+}
+
+func (m *Service) DeleteDomain(id int) error {
+	m.record("DeleteDomain", id)
+	if m.DeleteDomainFunc != nil {
+		return m.DeleteDomainFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) SaveNamespace(namespace *database.NamespaceModel) (*database.NamespaceModel, error) {
+	m.record("SaveNamespace", namespace)
+	if m.SaveNamespaceFunc != nil {
+		return m.SaveNamespaceFunc(namespace)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListNamespaces() ([]*database.NamespaceModel, error) {
+	m.record("ListNamespaces")
+	if m.ListNamespacesFunc != nil {
+		return m.ListNamespacesFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) GetNamespaceByName(name string) (*database.NamespaceModel, error) {
+	m.record("GetNamespaceByName", name)
+	if m.GetNamespaceByNameFunc != nil {
+		return m.GetNamespaceByNameFunc(name)
+	}
+	return nil, nil
+}
+
+func (m *Service) DeleteNamespace(id int) error {
+	m.record("DeleteNamespace", id)
+	if m.DeleteNamespaceFunc != nil {
+		return m.DeleteNamespaceFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) EnqueueJob(job *database.JobModel) (*database.JobModel, error) {
+	m.record("EnqueueJob", job)
+	if m.EnqueueJobFunc != nil {
+		return m.EnqueueJobFunc(job)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetJob(id int) (*database.JobModel, error) {
+	m.record("GetJob", id)
+	if m.GetJobFunc != nil {
+		return m.GetJobFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *Service) ClaimJob(kinds []string, staleAfter time.Duration) (*database.JobModel, error) {
+	m.record("ClaimJob", kinds, staleAfter)
+	if m.ClaimJobFunc != nil {
+		return m.ClaimJobFunc(kinds, staleAfter)
+	}
+	return nil, nil
+}
+
+func (m *Service) FinishJob(id int, result []byte, jobErr string) error {
+	m.record("FinishJob", id, result, jobErr)
+	if m.FinishJobFunc != nil {
+		return m.FinishJobFunc(id, result, jobErr)
+	}
+	return nil
+}
+
+func (m *Service) SaveJobFile(id int, file []byte) error {
+	m.record("SaveJobFile", id, file)
+	if m.SaveJobFileFunc != nil {
+		return m.SaveJobFileFunc(id, file)
+	}
+	return nil
+}
+
+func (m *Service) GetJobFile(id int) ([]byte, error) {
+	m.record("GetJobFile", id)
+	if m.GetJobFileFunc != nil {
+		return m.GetJobFileFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveJobFileKey(id int, key string) error {
+	m.record("SaveJobFileKey", id, key)
+	if m.SaveJobFileKeyFunc != nil {
+		return m.SaveJobFileKeyFunc(id, key)
+	}
+	return nil
+}
+
+func (m *Service) GetJobFileKey(id int) (string, error) {
+	m.record("GetJobFileKey", id)
+	if m.GetJobFileKeyFunc != nil {
+		return m.GetJobFileKeyFunc(id)
+	}
+	return "", database.ErrNotFound
+}
+
+func (m *Service) ListFinishedJobFileKeys(before time.Time) ([]string, error) {
+	m.record("ListFinishedJobFileKeys", before)
+	if m.ListFinishedJobFileKeysFunc != nil {
+		return m.ListFinishedJobFileKeysFunc(before)
+	}
+	return []string{}, nil
+}
+
+func (m *Service) DeleteFinishedJobs(before time.Time) (int64, error) {
+	m.record("DeleteFinishedJobs", before)
+	if m.DeleteFinishedJobsFunc != nil {
+		return m.DeleteFinishedJobsFunc(before)
+	}
+	return 0, nil
+}
+
+func (m *Service) SaveShortUrls(shortUrls []*database.ShortUrlModel) ([]*database.ShortUrlModel, error) {
+	m.record("SaveShortUrls", shortUrls)
+	if m.SaveShortUrlsFunc != nil {
+		return m.SaveShortUrlsFunc(shortUrls)
+	}
+	return nil, nil
+}
+
+func (m *Service) RecordClicks(clicks []*database.ClickEventModel) (int64, error) {
+	m.record("RecordClicks", clicks)
+	if m.RecordClicksFunc != nil {
+		return m.RecordClicksFunc(clicks)
+	}
+	return int64(len(clicks)), nil
+}
+
+func (m *Service) ListClickPartitions() ([]*database.ClickPartitionModel, error) {
+	m.record("ListClickPartitions")
+	if m.ListClickPartitionsFunc != nil {
+		return m.ListClickPartitionsFunc()
+	}
+	return []*database.ClickPartitionModel{}, nil
+}
+
+func (m *Service) CreateClickPartition(from time.Time, to time.Time) (*database.ClickPartitionModel, error) {
+	m.record("CreateClickPartition", from, to)
+	if m.CreateClickPartitionFunc != nil {
+		return m.CreateClickPartitionFunc(from, to)
+	}
+	return &database.ClickPartitionModel{From: &from, To: to}, nil
+}
+
+func (m *Service) DropClickPartition(name string) error {
+	m.record("DropClickPartition", name)
+	if m.DropClickPartitionFunc != nil {
+		return m.DropClickPartitionFunc(name)
+	}
+	return nil
+}
+
+func (m *Service) RefreshStatsViews() error {
+	m.record("RefreshStatsViews")
+	if m.RefreshStatsViewsFunc != nil {
+		return m.RefreshStatsViewsFunc()
+	}
+	return nil
+}
+
+func (m *Service) DomainStats(limit int, maxAge time.Duration) ([]*database.DomainStatsModel, time.Time, error) {
+	m.record("DomainStats", limit, maxAge)
+	if m.DomainStatsFunc != nil {
+		return m.DomainStatsFunc(limit, maxAge)
+	}
+	return []*database.DomainStatsModel{}, time.Time{}, nil
+}
+
+func (m *Service) Leaderboard(limit int, maxAge time.Duration) ([]*database.LeaderboardEntryModel, time.Time, error) {
+	m.record("Leaderboard", limit, maxAge)
+	if m.LeaderboardFunc != nil {
+		return m.LeaderboardFunc(limit, maxAge)
+	}
+	return []*database.LeaderboardEntryModel{}, time.Time{}, nil
+}
+
+func (m *Service) ListClicksForExport(afterId int64, before time.Time, limit int) ([]*database.ClickEventModel, error) {
+	m.record("ListClicksForExport", afterId, before, limit)
+	if m.ListClicksForExportFunc != nil {
+		return m.ListClicksForExportFunc(afterId, before, limit)
+	}
+	return []*database.ClickEventModel{}, nil
+}
+
+func (m *Service) ListLinksForExport(afterUpdatedAt time.Time, afterId int, before time.Time, limit int) ([]*database.ShortUrlModel, error) {
+	m.record("ListLinksForExport", afterUpdatedAt, afterId, before, limit)
+	if m.ListLinksForExportFunc != nil {
+		return m.ListLinksForExportFunc(afterUpdatedAt, afterId, before, limit)
+	}
+	return []*database.ShortUrlModel{}, nil
+}
+
+func (m *Service) GetExportCursor(stream string) (*database.ExportCursorModel, error) {
+	m.record("GetExportCursor", stream)
+	if m.GetExportCursorFunc != nil {
+		return m.GetExportCursorFunc(stream)
+	}
+	return &database.ExportCursorModel{Stream: stream}, nil
+}
+
+func (m *Service) SaveExportCursor(cursor *database.ExportCursorModel) error {
+	m.record("SaveExportCursor", cursor)
+	if m.SaveExportCursorFunc != nil {
+		return m.SaveExportCursorFunc(cursor)
+	}
+	return nil
+}
+
+func (m *Service) UpsertShortUrl(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, bool, error) {
+	m.record("UpsertShortUrl", shortUrl)
+	if m.UpsertShortUrlFunc != nil {
+		return m.UpsertShortUrlFunc(shortUrl)
+	}
+	return nil, false, nil
+}
+
+func (m *Service) StartJobRun(name string, interval time.Duration) error {
+	m.record("StartJobRun", name, interval)
+	if m.StartJobRunFunc != nil {
+		return m.StartJobRunFunc(name, interval)
+	}
+	return nil
+}
+
+func (m *Service) FinishJobRun(name string) error {
+	m.record("FinishJobRun", name)
+	if m.FinishJobRunFunc != nil {
+		return m.FinishJobRunFunc(name)
+	}
+	return nil
+}
+
+func (m *Service) LastShortUrlDeletion() (time.Time, error) {
+	m.record("LastShortUrlDeletion")
+	if m.LastShortUrlDeletionFunc != nil {
+		return m.LastShortUrlDeletionFunc()
+	}
+	return time.Time{}, nil
+}
+
+func (m *Service) FailJobRun(name string, jobErr string) error {
+	m.record("FailJobRun", name, jobErr)
+	if m.FailJobRunFunc != nil {
+		return m.FailJobRunFunc(name, jobErr)
+	}
+	return nil
+}
+
+func (m *Service) ListJobStatuses() ([]*database.JobStatusModel, error) {
+	m.record("ListJobStatuses")
+	if m.ListJobStatusesFunc != nil {
+		return m.ListJobStatusesFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) GetMaintenance() (*database.MaintenanceModel, error) {
+	m.record("GetMaintenance")
+	if m.GetMaintenanceFunc != nil {
+		return m.GetMaintenanceFunc()
+	}
+	return &database.MaintenanceModel{}, nil
+}
+
+func (m *Service) SetMaintenance(maintenance *database.MaintenanceModel) (*database.MaintenanceModel, error) {
+	m.record("SetMaintenance", maintenance)
+	if m.SetMaintenanceFunc != nil {
+		return m.SetMaintenanceFunc(maintenance)
+	}
+	return maintenance, nil
+}
+
+func (m *Service) QueueForReview(review *database.ReviewModel) (*database.ReviewModel, error) {
+	m.record("QueueForReview", review)
+	if m.QueueForReviewFunc != nil {
+		return m.QueueForReviewFunc(review)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListReviews(status string) ([]*database.ReviewModel, error) {
+	m.record("ListReviews", status)
+	if m.ListReviewsFunc != nil {
+		return m.ListReviewsFunc(status)
+	}
+	return nil, nil
+}
+
+func (m *Service) ResolveReview(id int, approve bool) error {
+	m.record("ResolveReview", id, approve)
+	if m.ResolveReviewFunc != nil {
+		return m.ResolveReviewFunc(id, approve)
+	}
+	return nil
+}
+
+func (m *Service) SaveAbuseReport(report *database.AbuseReportModel) (*database.AbuseReportModel, error) {
+	m.record("SaveAbuseReport", report)
+	if m.SaveAbuseReportFunc != nil {
+		return m.SaveAbuseReportFunc(report)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListAbuseReports(status string) ([]*database.AbuseReportModel, error) {
+	m.record("ListAbuseReports", status)
+	if m.ListAbuseReportsFunc != nil {
+		return m.ListAbuseReportsFunc(status)
+	}
+	return nil, nil
+}
+
+func (m *Service) DismissAbuseReport(id int) error {
+	m.record("DismissAbuseReport", id)
+	if m.DismissAbuseReportFunc != nil {
+		return m.DismissAbuseReportFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) DisableReportedLink(id int) error {
+	m.record("DisableReportedLink", id)
+	if m.DisableReportedLinkFunc != nil {
+		return m.DisableReportedLinkFunc(id)
+	}
+	return nil
+}
+
+func (m *Service) TransitionModeration(event *database.TakedownEventModel) (*database.TakedownEventModel, error) {
+	m.record("TransitionModeration", event)
+	if m.TransitionModerationFunc != nil {
+		return m.TransitionModerationFunc(event)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListTakedownEvents(shortCode string) ([]*database.TakedownEventModel, error) {
+	m.record("ListTakedownEvents", shortCode)
+	if m.ListTakedownEventsFunc != nil {
+		return m.ListTakedownEventsFunc(shortCode)
+	}
+	return nil, nil
+}
+
+func (m *Service) CreateOrganization(organization *database.OrganizationModel, ownerId int) (*database.OrganizationModel, error) {
+	m.record("CreateOrganization", organization, ownerId)
+	if m.CreateOrganizationFunc != nil {
+		return m.CreateOrganizationFunc(organization, ownerId)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListOrganizations(userId int) ([]*database.OrganizationModel, error) {
+	m.record("ListOrganizations", userId)
+	if m.ListOrganizationsFunc != nil {
+		return m.ListOrganizationsFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetMembership(organizationId int, userId int) (*database.MemberModel, error) {
+	m.record("GetMembership", organizationId, userId)
+	if m.GetMembershipFunc != nil {
+		return m.GetMembershipFunc(organizationId, userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListMembers(organizationId int) ([]*database.MemberModel, error) {
+	m.record("ListMembers", organizationId)
+	if m.ListMembersFunc != nil {
+		return m.ListMembersFunc(organizationId)
+	}
+	return nil, nil
+}
+
+func (m *Service) RemoveMember(organizationId int, userId int) error {
+	m.record("RemoveMember", organizationId, userId)
+	if m.RemoveMemberFunc != nil {
+		return m.RemoveMemberFunc(organizationId, userId)
+	}
+	return nil
+}
+
+func (m *Service) UpdateMemberRole(organizationId int, userId int, role string) (*database.MemberModel, error) {
+	m.record("UpdateMemberRole", organizationId, userId, role)
+	if m.UpdateMemberRoleFunc != nil {
+		return m.UpdateMemberRoleFunc(organizationId, userId, role)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveInvitation(invitation *database.InvitationModel) (*database.InvitationModel, error) {
+	m.record("SaveInvitation", invitation)
+	if m.SaveInvitationFunc != nil {
+		return m.SaveInvitationFunc(invitation)
+	}
+	return nil, nil
+}
+
+func (m *Service) AcceptInvitation(tokenHash string, userId int) (*database.MemberModel, error) {
+	m.record("AcceptInvitation", tokenHash, userId)
+	if m.AcceptInvitationFunc != nil {
+		return m.AcceptInvitationFunc(tokenHash, userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListOrganizationLinks(organizationId int, page pagination.Page) ([]*database.ShortUrlModel, error) {
+	m.record("ListOrganizationLinks", organizationId, page)
+	if m.ListOrganizationLinksFunc != nil {
+		return m.ListOrganizationLinksFunc(organizationId, page)
+	}
+	return nil, nil
+}
+
+func (m *Service) RecordAudit(entry *database.AuditLogModel) error {
+	m.record("RecordAudit", entry)
+	if m.RecordAuditFunc != nil {
+		return m.RecordAuditFunc(entry)
+	}
+	return nil
+}
+
+func (m *Service) ListAuditLog(filter database.AuditLogFilter) ([]*database.AuditLogModel, error) {
+	m.record("ListAuditLog", filter)
+	if m.ListAuditLogFunc != nil {
+		return m.ListAuditLogFunc(filter)
+	}
+	return nil, nil
+}
+
+func (m *Service) RecordClick(click *database.ClickEventModel) error {
+	m.record("RecordClick", click)
+	if m.RecordClickFunc != nil {
+		return m.RecordClickFunc(click)
+	}
+	return nil
+}
+
+func (m *Service) PurgeRawIps(retention time.Duration) (int64, error) {
+	m.record("PurgeRawIps", retention)
+	if m.PurgeRawIpsFunc != nil {
+		return m.PurgeRawIpsFunc(retention)
+	}
+	return 0, nil
+}
+
+func (m *Service) ExportUserData(userId int) (*database.UserExportModel, error) {
+	m.record("ExportUserData", userId)
+	if m.ExportUserDataFunc != nil {
+		return m.ExportUserDataFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) RequestUserDeletion(userId int) (*database.DeletionRequestModel, error) {
+	m.record("RequestUserDeletion", userId)
+	if m.RequestUserDeletionFunc != nil {
+		return m.RequestUserDeletionFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetDeletionRequest(userId int) (*database.DeletionRequestModel, error) {
+	m.record("GetDeletionRequest", userId)
+	if m.GetDeletionRequestFunc != nil {
+		return m.GetDeletionRequestFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) ProcessDeletionRequests() (int, error) {
+	m.record("ProcessDeletionRequests")
+	if m.ProcessDeletionRequestsFunc != nil {
+		return m.ProcessDeletionRequestsFunc()
+	}
+	return 0, nil
+}
+
+func (m *Service) RunInTransaction(fn func(database.Service) error) error {
+	m.record("RunInTransaction")
+	if m.RunInTransactionFunc != nil {
+		return m.RunInTransactionFunc(fn)
+	}
+	return fn(m)
+}
+
+func (m *Service) UpdateUserPlan(id int, plan string) (*database.UserModel, error) {
+	m.record("UpdateUserPlan", id, plan)
+	if m.UpdateUserPlanFunc != nil {
+		return m.UpdateUserPlanFunc(id, plan)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetOrganization(id int) (*database.OrganizationModel, error) {
+	m.record("GetOrganization", id)
+	if m.GetOrganizationFunc != nil {
+		return m.GetOrganizationFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *Service) UpdateOrganizationPlan(id int, plan string) (*database.OrganizationModel, error) {
+	m.record("UpdateOrganizationPlan", id, plan)
+	if m.UpdateOrganizationPlanFunc != nil {
+		return m.UpdateOrganizationPlanFunc(id, plan)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListOrganizationRetentions() ([]*database.RetentionModel, error) {
+	m.record("ListOrganizationRetentions")
+	if m.ListOrganizationRetentionsFunc != nil {
+		return m.ListOrganizationRetentionsFunc()
+	}
+	return []*database.RetentionModel{}, nil
+}
+
+func (m *Service) SetOrganizationRetention(retention *database.RetentionModel) (*database.RetentionModel, error) {
+	m.record("SetOrganizationRetention", retention)
+	if m.SetOrganizationRetentionFunc != nil {
+		return m.SetOrganizationRetentionFunc(retention)
+	}
+	return retention, nil
+}
+
+func (m *Service) CountOrganizationLinks(organizationId int, since time.Time) (*database.LinkCountModel, error) {
+	m.record("CountOrganizationLinks", organizationId, since)
+	if m.CountOrganizationLinksFunc != nil {
+		return m.CountOrganizationLinksFunc(organizationId, since)
+	}
+	return nil, nil
+}
+
+func (m *Service) PurgeClickEvents(scope database.AnalyticsScope, before time.Time) (int64, error) {
+	m.record("PurgeClickEvents", scope, before)
+	if m.PurgeClickEventsFunc != nil {
+		return m.PurgeClickEventsFunc(scope, before)
+	}
+	return 0, nil
+}
+
+func (m *Service) RollupClicks(since time.Time) (int64, error) {
+	m.record("RollupClicks", since)
+	if m.RollupClicksFunc != nil {
+		return m.RollupClicksFunc(since)
+	}
+	return 0, nil
+}
+
+func (m *Service) PurgeClickRollups(scope database.AnalyticsScope, before time.Time) (int64, error) {
+	m.record("PurgeClickRollups", scope, before)
+	if m.PurgeClickRollupsFunc != nil {
+		return m.PurgeClickRollupsFunc(scope, before)
+	}
+	return 0, nil
+}
+
+func (m *Service) AnalyzeTables(tables []string) error {
+	m.record("AnalyzeTables", tables)
+	if m.AnalyzeTablesFunc != nil {
+		return m.AnalyzeTablesFunc(tables)
+	}
+	return nil
+}
+
+func (m *Service) VacuumTables(tables []string) error {
+	m.record("VacuumTables", tables)
+	if m.VacuumTablesFunc != nil {
+		return m.VacuumTablesFunc(tables)
+	}
+	return nil
+}
+
+func (m *Service) ListTableStats() ([]*database.TableStatsModel, error) {
+	m.record("ListTableStats")
+	if m.ListTableStatsFunc != nil {
+		return m.ListTableStatsFunc()
+	}
+	return nil, nil
+}
+
+// DryRun returns the mock itself unless DryRunFunc is set, so the calls of a dry run are recorded with the others
+func (m *Service) DryRun() database.Service {
+	m.record("DryRun")
+	if m.DryRunFunc != nil {
+		return m.DryRunFunc()
+	}
+	return m
+}
+
+func (m *Service) RequestJobRun(name string) (*database.JobStatusModel, error) {
+	m.record("RequestJobRun", name)
+	if m.RequestJobRunFunc != nil {
+		return m.RequestJobRunFunc(name)
+	}
+	return nil, nil
+}
+
+func (m *Service) ClaimJobRunRequests() ([]string, error) {
+	m.record("ClaimJobRunRequests")
+	if m.ClaimJobRunRequestsFunc != nil {
+		return m.ClaimJobRunRequestsFunc()
+	}
+	return nil, nil
+}
+
+func (m *Service) GetEmailPreferences(userId int) (*database.EmailPreferencesModel, error) {
+	m.record("GetEmailPreferences", userId)
+	if m.GetEmailPreferencesFunc != nil {
+		return m.GetEmailPreferencesFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) UpdateEmailPreferences(preferences *database.EmailPreferencesModel) (*database.EmailPreferencesModel, error) {
+	m.record("UpdateEmailPreferences", preferences)
+	if m.UpdateEmailPreferencesFunc != nil {
+		return m.UpdateEmailPreferencesFunc(preferences)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListDigestRecipients(sentBefore time.Time, afterId int, limit int) ([]*database.UserModel, error) {
+	m.record("ListDigestRecipients", sentBefore, afterId, limit)
+	if m.ListDigestRecipientsFunc != nil {
+		return m.ListDigestRecipientsFunc(sentBefore, afterId, limit)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetLinkDigest(userId int, since time.Time, until time.Time, top int) (*database.LinkDigestModel, error) {
+	m.record("GetLinkDigest", userId, since, until, top)
+	if m.GetLinkDigestFunc != nil {
+		return m.GetLinkDigestFunc(userId, since, until, top)
+	}
+	return nil, nil
+}
+
+func (m *Service) MarkDigestSent(userId int, at time.Time) error {
+	m.record("MarkDigestSent", userId, at)
+	if m.MarkDigestSentFunc != nil {
+		return m.MarkDigestSentFunc(userId, at)
+	}
+	return nil
+}
+
+func (m *Service) ListInvitations(organizationId int) ([]*database.InvitationModel, error) {
+	m.record("ListInvitations", organizationId)
+	if m.ListInvitationsFunc != nil {
+		return m.ListInvitationsFunc(organizationId)
+	}
+	return nil, nil
+}
+
+func (m *Service) RenewInvitation(organizationId int, id int, tokenHash string, expiresAt time.Time) (*database.InvitationModel, error) {
+	m.record("RenewInvitation", organizationId, id, tokenHash, expiresAt)
+	if m.RenewInvitationFunc != nil {
+		return m.RenewInvitationFunc(organizationId, id, tokenHash, expiresAt)
+	}
+	return nil, nil
+}
+
+func (m *Service) RevokeInvitation(organizationId int, id int) (*database.InvitationModel, error) {
+	m.record("RevokeInvitation", organizationId, id)
+	if m.RevokeInvitationFunc != nil {
+		return m.RevokeInvitationFunc(organizationId, id)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetInvitation(organizationId int, id int) (*database.InvitationModel, error) {
+	m.record("GetInvitation", organizationId, id)
+	if m.GetInvitationFunc != nil {
+		return m.GetInvitationFunc(organizationId, id)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetUserPassword(email string) (*database.UserPasswordModel, error) {
+	m.record("GetUserPassword", email)
+	if m.GetUserPasswordFunc != nil {
+		return m.GetUserPasswordFunc(email)
+	}
+	return nil, nil
+}
+
+func (m *Service) SetPassword(userId int, passwordHash string) error {
+	m.record("SetPassword", userId, passwordHash)
+	if m.SetPasswordFunc != nil {
+		return m.SetPasswordFunc(userId, passwordHash)
+	}
+	return nil
+}
+
+func (m *Service) SavePasswordReset(reset *database.PasswordResetModel) (*database.PasswordResetModel, error) {
+	m.record("SavePasswordReset", reset)
+	if m.SavePasswordResetFunc != nil {
+		return m.SavePasswordResetFunc(reset)
+	}
+	return nil, nil
+}
+
+func (m *Service) ResetPassword(tokenHash string, passwordHash string) (*database.UserModel, error) {
+	m.record("ResetPassword", tokenHash, passwordHash)
+	if m.ResetPasswordFunc != nil {
+		return m.ResetPasswordFunc(tokenHash, passwordHash)
+	}
+	return nil, nil
+}
+
+func (m *Service) SignUp(email string, passwordHash string) (*database.UserModel, error) {
+	m.record("SignUp", email, passwordHash)
+	if m.SignUpFunc != nil {
+		return m.SignUpFunc(email, passwordHash)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveEmailVerification(verification *database.EmailVerificationModel) (*database.EmailVerificationModel, error) {
+	m.record("SaveEmailVerification", verification)
+	if m.SaveEmailVerificationFunc != nil {
+		return m.SaveEmailVerificationFunc(verification)
+	}
+	return nil, nil
+}
+
+func (m *Service) VerifyEmail(tokenHash string) (*database.UserModel, error) {
+	m.record("VerifyEmail", tokenHash)
+	if m.VerifyEmailFunc != nil {
+		return m.VerifyEmailFunc(tokenHash)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveSession(session *database.SessionModel) (*database.SessionModel, error) {
+	m.record("SaveSession", session)
+	if m.SaveSessionFunc != nil {
+		return m.SaveSessionFunc(session)
+	}
+	return nil, nil
+}
+
+func (m *Service) GetSessionByHash(tokenHash string, idleTimeout time.Duration) (*database.SessionModel, error) {
+	m.record("GetSessionByHash", tokenHash, idleTimeout)
+	if m.GetSessionByHashFunc != nil {
+		return m.GetSessionByHashFunc(tokenHash, idleTimeout)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListSessions(userId int, idleTimeout time.Duration) ([]*database.SessionModel, error) {
+	m.record("ListSessions", userId, idleTimeout)
+	if m.ListSessionsFunc != nil {
+		return m.ListSessionsFunc(userId, idleTimeout)
+	}
+	return nil, nil
+}
+
+func (m *Service) RevokeSession(userId int, id int) error {
+	m.record("RevokeSession", userId, id)
+	if m.RevokeSessionFunc != nil {
+		return m.RevokeSessionFunc(userId, id)
+	}
+	return nil
+}
+
+func (m *Service) RevokeSessions(userId int) (int64, error) {
+	m.record("RevokeSessions", userId)
+	if m.RevokeSessionsFunc != nil {
+		return m.RevokeSessionsFunc(userId)
+	}
+	return 0, nil
+}
+
+func (m *Service) DeleteEndedSessions() (int64, error) {
+	m.record("DeleteEndedSessions")
+	if m.DeleteEndedSessionsFunc != nil {
+		return m.DeleteEndedSessionsFunc()
+	}
+	return 0, nil
+}
+
+func (m *Service) CountAnonymousLinks(since time.Time) (int, error) {
+	m.record("CountAnonymousLinks", since)
+	if m.CountAnonymousLinksFunc != nil {
+		return m.CountAnonymousLinksFunc(since)
+	}
+	return 0, nil
+}
+
+func (m *Service) SaveLinkClaim(claimModel *database.LinkClaimModel) error {
+	m.record("SaveLinkClaim", claimModel)
+	if m.SaveLinkClaimFunc != nil {
+		return m.SaveLinkClaimFunc(claimModel)
+	}
+	return nil
+}
+
+func (m *Service) ClaimLink(tokenHash string, userId int) (*database.ShortUrlModel, error) {
+	m.record("ClaimLink", tokenHash, userId)
+	if m.ClaimLinkFunc != nil {
+		return m.ClaimLinkFunc(tokenHash, userId)
+	}
+	return nil, nil
+}
+
+func (m *Service) SaveLinkEditToken(tokenModel *database.LinkEditTokenModel) error {
+	m.record("SaveLinkEditToken", tokenModel)
+	if m.SaveLinkEditTokenFunc != nil {
+		return m.SaveLinkEditTokenFunc(tokenModel)
+	}
+	return nil
+}
+
+func (m *Service) GetShortUrlByEditToken(shortCode string, tokenHash string) (*database.ShortUrlModel, error) {
+	m.record("GetShortUrlByEditToken", shortCode, tokenHash)
+	if m.GetShortUrlByEditTokenFunc != nil {
+		return m.GetShortUrlByEditTokenFunc(shortCode, tokenHash)
+	}
+	return nil, nil
+}
+
+func (m *Service) EditShortUrl(shortCode string, link string, expTimeMinutes int, review *database.ReviewModel) (*database.ShortUrlModel, error) {
+	m.record("EditShortUrl", shortCode, link, expTimeMinutes, review)
+	if m.EditShortUrlFunc != nil {
+		return m.EditShortUrlFunc(shortCode, link, expTimeMinutes, review)
+	}
+	return nil, nil
+}
+
+func (m *Service) ListUnsentNotifications(afterId int, limit int) ([]*database.NotificationModel, error) {
+	m.record("ListUnsentNotifications", afterId, limit)
+	if m.ListUnsentNotificationsFunc != nil {
+		return m.ListUnsentNotificationsFunc(afterId, limit)
+	}
+	return nil, nil
+}
+
+func (m *Service) MarkNotificationSent(id int, at time.Time) error {
+	m.record("MarkNotificationSent", id, at)
+	if m.MarkNotificationSentFunc != nil {
+		return m.MarkNotificationSentFunc(id, at)
+	}
+	return nil
+}
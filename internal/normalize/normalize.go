@@ -0,0 +1,112 @@
+package normalize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// TrackingParams are the click identifiers ad networks and newsletters append to links. They identify the visitor
+// rather than the page. utm_* isn't part of it, campaigns are often wanted in the analytics of the destination
+var TrackingParams = []string{
+	"fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "twclid", "ttclid", "li_fat_id", "igshid", "yclid",
+	"mc_eid", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// URL returns the canonical form of a destination used for comparisons:
+// surrounding whitespace is trimmed, the scheme and authority are lowercased
+// and the default port of http and https is dropped. Path, query and fragment are kept as is.
+//
+// The link_hash migration reproduces this in SQL, keep both in sync.
+func URL(link string) string {
+	link = strings.TrimSpace(link)
+
+	schemeEnd := strings.Index(link, "://")
+	if schemeEnd <= 0 || !isScheme(link[:schemeEnd]) {
+		return link
+	}
+
+	authorityStart := schemeEnd + len("://")
+	authorityEnd := len(link)
+	if i := strings.IndexAny(link[authorityStart:], "/?#"); i >= 0 {
+		authorityEnd = authorityStart + i
+	}
+
+	prefix := strings.ToLower(link[:authorityEnd])
+	prefix = strings.TrimSuffix(prefix, defaultPort(prefix))
+
+	return prefix + link[authorityEnd:]
+}
+
+// Hash returns the hex encoded SHA-256 of the normalized destination.
+func Hash(link string) string {
+	sum := sha256.Sum256([]byte(URL(link)))
+	return hex.EncodeToString(sum[:])
+}
+
+// StripParams removes the query parameters named in params from link, keeping the others as they were written.
+// Names are compared case insensitively, and a name ending in * matches every parameter starting with it, as
+// utm_* does. The "?" goes away with the last parameter.
+func StripParams(link string, params []string) string {
+	queryStart := strings.IndexByte(link, '?')
+	fragmentStart := strings.IndexByte(link, '#')
+	if len(params) == 0 || queryStart < 0 || (fragmentStart >= 0 && fragmentStart < queryStart) {
+		return link
+	}
+
+	queryEnd := len(link)
+	if fragmentStart >= 0 {
+		queryEnd = fragmentStart
+	}
+
+	kept := []string{}
+	for _, pair := range strings.Split(link[queryStart+1:queryEnd], "&") {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !matchesParam(name, params) {
+			kept = append(kept, pair)
+		}
+	}
+
+	if len(kept) == 0 {
+		return link[:queryStart] + link[queryEnd:]
+	}
+	return link[:queryStart+1] + strings.Join(kept, "&") + link[queryEnd:]
+}
+
+func matchesParam(name string, params []string) bool {
+	name = strings.ToLower(name)
+	for _, param := range params {
+		param = strings.ToLower(param)
+		prefix, wildcard := strings.CutSuffix(param, "*")
+		if name == param || wildcard && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPort(prefix string) string {
+	switch {
+	case strings.HasPrefix(prefix, "http://") && strings.HasSuffix(prefix, ":80"):
+		return ":80"
+	case strings.HasPrefix(prefix, "https://") && strings.HasSuffix(prefix, ":443"):
+		return ":443"
+	}
+	return ""
+}
+
+func isScheme(scheme string) bool {
+	for i, r := range scheme {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
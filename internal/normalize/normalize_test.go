@@ -0,0 +1,60 @@
+package normalize
+
+import "testing"
+
+func TestURL(t *testing.T) {
+	tests := map[string]string{
+		"  https://Example.COM/Path?Q=A#Frag ": "https://example.com/Path?Q=A#Frag",
+		"HTTP://example.com:80/":               "http://example.com/",
+		"https://example.com:443":              "https://example.com",
+		"https://example.com:8443/x":           "https://example.com:8443/x",
+		"http://example.com:443/":              "http://example.com:443/",
+		"HTTPS://EXAMPLE.com?x=Y":              "https://example.com?x=Y",
+		"mailto:Someone@Example.com":           "mailto:Someone@Example.com",
+		"example.com/Path":                     "example.com/Path",
+	}
+
+	for link, expected := range tests {
+		if got := URL(link); got != expected {
+			t.Errorf("URL(%q) = %q; expected %q", link, got, expected)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("https://EXAMPLE.com/a") != Hash(" https://example.com:443/a") {
+		t.Errorf("expected equivalent urls to share a hash")
+	}
+
+	if Hash("https://example.com/a") == Hash("https://example.com/A") {
+		t.Errorf("expected paths to stay case sensitive")
+	}
+
+	if len(Hash("https://example.com")) != 64 {
+		t.Errorf("expected a hex encoded SHA-256")
+	}
+}
+
+func TestStripParams(t *testing.T) {
+	tests := []struct {
+		link     string
+		params   []string
+		expected string
+	}{
+		{"https://example.com/?fbclid=abc", TrackingParams, "https://example.com/"},
+		{"https://example.com/?id=1&gclid=abc&page=2#top", TrackingParams, "https://example.com/?id=1&page=2#top"},
+		{"https://example.com/?FBCLID=abc&q=a%20b", TrackingParams, "https://example.com/?q=a%20b"},
+		{"https://example.com/?utm_source=mail&utm_medium=x", TrackingParams, "https://example.com/?utm_source=mail&utm_medium=x"},
+		{"https://example.com/?utm_source=mail&utm_medium=x&ref=1", []string{"utm_*"}, "https://example.com/?ref=1"},
+		{"https://example.com/?utm%5Fsource=mail", []string{"utm_*"}, "https://example.com/"},
+		{"https://example.com/#/route?fbclid=abc", TrackingParams, "https://example.com/#/route?fbclid=abc"},
+		{"https://example.com/?fbclid=abc", nil, "https://example.com/?fbclid=abc"},
+		{"https://example.com/page", TrackingParams, "https://example.com/page"},
+	}
+
+	for _, tt := range tests {
+		if got := StripParams(tt.link, tt.params); got != tt.expected {
+			t.Errorf("StripParams(%q, %v) = %q; expected %q", tt.link, tt.params, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Discord refuses messages longer than this, in characters
+const maxDiscordContent = 2000
+
+// Discord posts events to Discord webhooks
+type Discord struct {
+	// Webhook of each type of event, those missing are not sent
+	webhooks   map[string]string
+	httpClient *http.Client
+}
+
+// NewDiscord returns a client configured like NewSlack, from DISCORD_WEBHOOK_URL, DISCORD_WEBHOOK_URL_<TYPE> and
+// DISCORD_EVENTS. It returns nil when no webhook is configured, meaning the integration is disabled.
+func NewDiscord() *Discord {
+	webhooks := webhooksFromEnv("DISCORD")
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	return &Discord{webhooks: webhooks, httpClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (d *Discord) Routes(eventType string) bool {
+	_, ok := d.webhooks[eventType]
+	return ok
+}
+
+// Notify posts event to the webhook of its type.
+func (d *Discord) Notify(ctx context.Context, event Event) error {
+	webhook, ok := d.webhooks[event.Type]
+	if !ok {
+		return nil
+	}
+
+	title, details, _ := strings.Cut(event.text(), "\n")
+	content := []rune("**" + title + "**\n" + details)
+	if len(content) > maxDiscordContent {
+		content = append(content[:maxDiscordContent-1], '…')
+	}
+
+	// Nothing in an event is meant to ping anyone, such as an @everyone in a short code
+	return post(ctx, d.httpClient, "discord", webhook, map[string]any{
+		"content":          string(content),
+		"allowed_mentions": map[string][]string{"parse": {}},
+	})
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiscordNotify(t *testing.T) {
+	var message struct {
+		Content         string              `json:"content"`
+		AllowedMentions map[string][]string `json:"allowed_mentions"`
+	}
+	hooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&message)
+		// Discord answers webhooks without a body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hooks.Close()
+
+	discord := &Discord{webhooks: map[string]string{AbuseReported: hooks.URL}, httpClient: hooks.Client()}
+	event := Event{Type: AbuseReported, Title: "Link reported for spam", Fields: map[string]string{"short_code": "@everyone"}}
+	if err := discord.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if message.Content != "**Link reported for spam**\nshort_code: @everyone" {
+		t.Errorf("expected the event as the content; got %q", message.Content)
+	}
+	if parse, ok := message.AllowedMentions["parse"]; !ok || len(parse) != 0 {
+		t.Errorf("expected mentions disabled; got %v", message.AllowedMentions)
+	}
+
+	event.Fields["details"] = strings.Repeat("é", 3000)
+	if err := discord.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if length := len([]rune(message.Content)); length != maxDiscordContent {
+		t.Errorf("expected the content cut to %d characters; got %d", maxDiscordContent, length)
+	}
+}
+
+// recorder is a Notifier keeping the events it is sent
+type recorder struct {
+	routes []string
+	events chan Event
+}
+
+func (r *recorder) Routes(eventType string) bool {
+	return strings.Contains(strings.Join(r.routes, ","), eventType)
+}
+
+func (r *recorder) Notify(ctx context.Context, event Event) error {
+	r.events <- event
+	return nil
+}
+
+func TestSendToEveryNotifier(t *testing.T) {
+	oncall := &recorder{routes: []string{JobFailed}, events: make(chan Event, 2)}
+	community := &recorder{routes: []string{JobFailed, AbuseReported}, events: make(chan Event, 2)}
+	SetNotifiers(oncall, community)
+	defer SetNotifiers()
+
+	Send(Event{Type: JobFailed, Title: "Cron job verify_domains failed"})
+	Send(Event{Type: AbuseReported, Title: "Link reported for spam"})
+	Wait()
+
+	if len(oncall.events) != 1 || len(community.events) != 2 {
+		t.Errorf("expected each notifier sent the events it routes; got %d and %d", len(oncall.events), len(community.events))
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	t.Setenv("DISCORD_WEBHOOK_URL", "https://discord.com/api/webhooks/1/token")
+	t.Setenv("DISCORD_EVENTS", "abuse_reported")
+
+	configured := New()
+	if len(configured) != 1 {
+		t.Fatalf("expected only discord configured; got %d notifiers", len(configured))
+	}
+	if discord, ok := configured[0].(*Discord); !ok || !discord.Routes(AbuseReported) || discord.Routes(JobFailed) {
+		t.Errorf("expected discord sent only the abuse reports; got %+v", configured[0])
+	}
+}
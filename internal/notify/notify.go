@@ -0,0 +1,185 @@
+// Package notify tells the people on call about operational events, such as a failing cron job, on the chat they
+// watch rather than in the logs. Sending is best effort and never fails what triggered the event.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The types of events, they route the events to a webhook each
+const (
+	JobFailed     = "job_failed"
+	AbuseReported = "abuse_reported"
+	QuotaNearing  = "quota_nearing"
+)
+
+// EventTypes are every type of event
+var EventTypes = []string{JobFailed, AbuseReported, QuotaNearing}
+
+// How long sending an event may take
+const sendTimeout = 10 * time.Second
+
+// Event is something that happened and someone should know about
+type Event struct {
+	Type  string
+	Title string
+
+	// Details of the event, shown sorted by key
+	Fields map[string]string
+}
+
+// text returns the event as lines of plain text, the title first
+func (e Event) text() string {
+	lines := []string{e.Title}
+	for _, key := range slices.Sorted(maps.Keys(e.Fields)) {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, e.Fields[key]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Notifier sends events to a channel, such as a chat
+type Notifier interface {
+	// Routes reports whether the events of type eventType are sent
+	Routes(eventType string) bool
+
+	Notify(ctx context.Context, event Event) error
+}
+
+var (
+	notifiers = New()
+
+	// The events being sent, see Wait
+	pending sync.WaitGroup
+)
+
+// New returns the notifiers configured, none when no webhook is
+func New() []Notifier {
+	var configured []Notifier
+	if slack := NewSlack(); slack != nil {
+		configured = append(configured, slack)
+	}
+	if discord := NewDiscord(); discord != nil {
+		configured = append(configured, discord)
+	}
+	return configured
+}
+
+// SetNotifiers replaces the notifiers events are sent to, e.g. to record them in tests
+func SetNotifiers(n ...Notifier) {
+	notifiers = n
+}
+
+// CheckConfig validates SLACK_EVENTS and DISCORD_EVENTS.
+func CheckConfig() error {
+	for _, name := range []string{"SLACK_EVENTS", "DISCORD_EVENTS"} {
+		if _, err := eventsFromEnv(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhooksFromEnv returns the webhook of each type of event for the chat prefix, e.g. SLACK: <prefix>_WEBHOOK_URL
+// where every event goes, <prefix>_WEBHOOK_URL_<TYPE> where the events of a type go instead, and <prefix>_EVENTS,
+// the types sent when not all of them should be. Types without a webhook are left out
+func webhooksFromEnv(prefix string) map[string]string {
+	events, err := eventsFromEnv(prefix + "_EVENTS")
+	if err != nil {
+		log.Printf("[notify:webhooksFromEnv] Sending every event: %v", err)
+	}
+
+	webhooks := map[string]string{}
+	for _, event := range EventTypes {
+		if events != nil && !slices.Contains(events, event) {
+			continue
+		}
+
+		webhook := os.Getenv(prefix + "_WEBHOOK_URL_" + strings.ToUpper(event))
+		if webhook == "" {
+			webhook = os.Getenv(prefix + "_WEBHOOK_URL")
+		}
+		if webhook != "" {
+			webhooks[event] = webhook
+		}
+	}
+	return webhooks
+}
+
+// post sends message as json to webhook, for chat
+func post(ctx context.Context, client *http.Client, chat string, webhook string, message any) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("%s: encode message: %w", chat, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%s: build request: %w", chat, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: post failed: %w", chat, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: post returned status %d", chat, resp.StatusCode)
+	}
+	return nil
+}
+
+// eventsFromEnv reads a comma separated list of event types from the variable name, nil when it is unset
+func eventsFromEnv(name string) ([]string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	var events []string
+	for _, event := range strings.Split(value, ",") {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(EventTypes, event) {
+			return nil, fmt.Errorf("invalid %s: unknown event %q, expected %s", name, event, strings.Join(EventTypes, ", "))
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Send sends event in the background, to the notifiers routing its type
+func Send(event Event) {
+	for _, notifier := range notifiers {
+		if !notifier.Routes(event.Type) {
+			continue
+		}
+
+		pending.Add(1)
+		go func() {
+			defer pending.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := notifier.Notify(ctx, event); err != nil {
+				log.Printf("[notify:Send] Could not send {%s}: %v", event.Type, err)
+			}
+		}()
+	}
+}
+
+// Wait waits for the events sent to be delivered, for commands exiting right after sending some
+func Wait() {
+	pending.Wait()
+}
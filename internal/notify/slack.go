@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Slack posts events to Slack incoming webhooks
+type Slack struct {
+	// Webhook of each type of event, those missing are not sent
+	webhooks   map[string]string
+	httpClient *http.Client
+}
+
+// NewSlack returns a client configured from SLACK_WEBHOOK_URL, where every event goes, SLACK_WEBHOOK_URL_<TYPE>,
+// where the events of a type go instead, e.g. SLACK_WEBHOOK_URL_JOB_FAILED, and SLACK_EVENTS, the types sent when
+// not all of them should be. It returns nil when no webhook is configured, meaning the integration is disabled.
+func NewSlack() *Slack {
+	webhooks := webhooksFromEnv("SLACK")
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	return &Slack{webhooks: webhooks, httpClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (s *Slack) Routes(eventType string) bool {
+	_, ok := s.webhooks[eventType]
+	return ok
+}
+
+// Notify posts event to the webhook of its type.
+func (s *Slack) Notify(ctx context.Context, event Event) error {
+	webhook, ok := s.webhooks[event.Type]
+	if !ok {
+		return nil
+	}
+
+	// The title in bold, mrkdwn being the default format of the messages
+	title, details, _ := strings.Cut(event.text(), "\n")
+	return post(ctx, s.httpClient, "slack", webhook, struct {
+		Text string `json:"text"`
+	}{Text: "*" + title + "*\n" + details})
+}
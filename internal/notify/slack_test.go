@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewSlack(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected map[string]string
+	}{
+		{"unset", map[string]string{}, nil},
+		{"every event", map[string]string{"SLACK_WEBHOOK_URL": "https://hooks/all"},
+			map[string]string{JobFailed: "https://hooks/all", AbuseReported: "https://hooks/all", QuotaNearing: "https://hooks/all"}},
+		{"routed", map[string]string{"SLACK_WEBHOOK_URL": "https://hooks/all", "SLACK_WEBHOOK_URL_JOB_FAILED": "https://hooks/oncall"},
+			map[string]string{JobFailed: "https://hooks/oncall", AbuseReported: "https://hooks/all", QuotaNearing: "https://hooks/all"}},
+		{"filtered", map[string]string{"SLACK_WEBHOOK_URL": "https://hooks/all", "SLACK_EVENTS": "abuse_reported, job_failed"},
+			map[string]string{JobFailed: "https://hooks/all", AbuseReported: "https://hooks/all"}},
+		{"single route", map[string]string{"SLACK_WEBHOOK_URL_ABUSE_REPORTED": "https://hooks/trust"},
+			map[string]string{AbuseReported: "https://hooks/trust"}},
+	}
+
+	for _, tt := range tests {
+		for _, name := range []string{"SLACK_WEBHOOK_URL", "SLACK_WEBHOOK_URL_JOB_FAILED", "SLACK_WEBHOOK_URL_ABUSE_REPORTED", "SLACK_EVENTS"} {
+			t.Setenv(name, tt.env[name])
+		}
+
+		slack := NewSlack()
+		if tt.expected == nil {
+			if slack != nil {
+				t.Errorf("%s: expected the integration disabled; got %v", tt.name, slack.webhooks)
+			}
+			continue
+		}
+		if slack == nil || len(slack.webhooks) != len(tt.expected) {
+			t.Errorf("%s: expected %v; got %+v", tt.name, tt.expected, slack)
+			continue
+		}
+		for event, webhook := range tt.expected {
+			if slack.webhooks[event] != webhook {
+				t.Errorf("%s: expected %s sent to %s; got %s", tt.name, event, webhook, slack.webhooks[event])
+			}
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	for value, expected := range map[string]bool{"": true, "job_failed,quota_nearing": true, "job_failed,deploys": false} {
+		t.Setenv("SLACK_EVENTS", value)
+		if err := CheckConfig(); (err == nil) != expected {
+			t.Errorf("SLACK_EVENTS=%q: expected valid %v; got %v", value, expected, err)
+		}
+	}
+}
+
+func TestSlackSend(t *testing.T) {
+	received := make(chan string, 1)
+	hooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var message struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal(body, &message)
+		received <- r.URL.Path + " " + message.Text
+	}))
+	defer hooks.Close()
+
+	SetNotifiers(&Slack{webhooks: map[string]string{JobFailed: hooks.URL + "/oncall"}, httpClient: hooks.Client()})
+	defer SetNotifiers()
+
+	// Not routed, dropped
+	Send(Event{Type: QuotaNearing, Title: "Links nearing their links_today quota"})
+	Send(Event{Type: JobFailed, Title: "Cron job verify_domains failed", Fields: map[string]string{"job": "verify_domains", "error": "timeout"}})
+	Wait()
+
+	expected := "/oncall *Cron job verify_domains failed*\nerror: timeout\njob: verify_domains"
+	if got := <-received; got != expected {
+		t.Errorf("expected %q; got %q", expected, got)
+	}
+	if len(received) != 0 {
+		t.Errorf("expected the event without a webhook dropped")
+	}
+}
+
+func TestSlackNotifyFails(t *testing.T) {
+	hooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer hooks.Close()
+
+	slack := &Slack{webhooks: map[string]string{JobFailed: hooks.URL}, httpClient: hooks.Client()}
+	if err := slack.Notify(context.Background(), Event{Type: JobFailed, Title: "Cron job failed"}); err == nil {
+		t.Errorf("expected a rejected post reported")
+	}
+}
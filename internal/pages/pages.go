@@ -0,0 +1,75 @@
+// Package pages renders the HTML pages shown to visitors following a short link in a browser. The templates are
+// embedded in the binary.
+package pages
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+//go:embed templates/*.html
+var files embed.FS
+
+var templates = template.Must(template.ParseFS(files, "templates/*.html"))
+
+// Branding customizes the pages served on a domain. Empty fields keep the defaults
+type Branding struct {
+	LogoUrl         string
+	PrimaryColor    string
+	BackgroundColor string
+	FooterText      string
+}
+
+var defaultBranding = Branding{
+	PrimaryColor:    "#2563eb",
+	BackgroundColor: "#f8fafc",
+}
+
+// Page is an error page
+type Page struct {
+	Status int
+
+	// Language the page is written in and its direction, English from left to right when empty
+	Language  string
+	Direction string
+
+	Title    string
+	Message  string
+	Branding Branding
+}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ValidColor reports whether color is a CSS hex color like #fff or #2563eb.
+func ValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// WantsHTML reports whether the client prefers a page to JSON, as browsers do.
+func WantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// Render writes the error page with its status code.
+func Render(w http.ResponseWriter, page Page) error {
+	if page.Branding.PrimaryColor == "" {
+		page.Branding.PrimaryColor = defaultBranding.PrimaryColor
+	}
+	if page.Branding.BackgroundColor == "" {
+		page.Branding.BackgroundColor = defaultBranding.BackgroundColor
+	}
+
+	if page.Language == "" {
+		page.Language = "en"
+	}
+	if page.Direction == "" {
+		page.Direction = "ltr"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(page.Status)
+	return templates.ExecuteTemplate(w, "error.html", page)
+}
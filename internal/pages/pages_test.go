@@ -0,0 +1,77 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name        string
+		page        Page
+		contains    []string
+		notContains []string
+	}{
+		{
+			"default branding",
+			Page{Status: http.StatusNotFound, Title: "Link not found", Message: "Check the link."},
+			[]string{"<title>Link not found</title>", "color: #2563eb", "background: #f8fafc"},
+			[]string{"<img", "<footer>"},
+		},
+		{
+			"custom branding",
+			Page{Status: http.StatusGone, Title: "Link expired", Branding: Branding{LogoUrl: "https://acme.com/logo.png", PrimaryColor: "#ff0000", FooterText: "Acme Inc."}},
+			[]string{`<img src="https://acme.com/logo.png"`, "color: #ff0000", "background: #f8fafc", "<footer>Acme Inc.</footer>"},
+			nil,
+		},
+		{
+			"escaped content",
+			Page{Status: http.StatusNotFound, Title: "<script>", Branding: Branding{FooterText: "<b>Acme</b>", LogoUrl: "javascript:alert(1)"}},
+			[]string{"&lt;script&gt;", "&lt;b&gt;Acme&lt;/b&gt;"},
+			[]string{"<script>", "javascript:"},
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		if err := Render(rec, tt.page); err != nil {
+			t.Fatalf("%s: could not render: %v", tt.name, err)
+		}
+
+		if rec.Code != tt.page.Status {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.page.Status, rec.Code)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(rec.Body.String(), s) {
+				t.Errorf("%s: expected the page to contain %q", tt.name, s)
+			}
+		}
+		for _, s := range tt.notContains {
+			if strings.Contains(rec.Body.String(), s) {
+				t.Errorf("%s: expected the page not to contain %q", tt.name, s)
+			}
+		}
+	}
+}
+
+func TestValidColor(t *testing.T) {
+	tests := []struct {
+		color    string
+		expected bool
+	}{
+		{"#fff", true},
+		{"#2563EB", true},
+		{"", false},
+		{"red", false},
+		{"#12345", false},
+		{"#fff;}body{x", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidColor(tt.color); got != tt.expected {
+			t.Errorf("%q: expected %v; got %v", tt.color, tt.expected, got)
+		}
+	}
+}
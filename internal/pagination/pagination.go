@@ -0,0 +1,94 @@
+// Package pagination implements keyset pagination over rows ordered by created_at and id, newest first.
+// Pages are addressed by an opaque cursor instead of an offset, so deep pages cost the same as the first one.
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last row of a page. The next page starts right after it
+type Cursor struct {
+	CreatedAt time.Time
+	Id        int64
+}
+
+// Encode returns the opaque form of the cursor handed out to clients.
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.Id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode parses a cursor produced by Encode. An empty string decodes to nil, the first page.
+func Decode(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Id, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// Page is a request for Limit rows following After. After is nil for the first page
+type Page struct {
+	After *Cursor
+	Limit int
+}
+
+// FromRequest reads the cursor and limit query parameters. The limit falls back to def when absent or
+// invalid and is capped at max.
+func FromRequest(r *http.Request, def int, max int) (Page, error) {
+	query := r.URL.Query()
+
+	after, err := Decode(query.Get("cursor"))
+	if err != nil {
+		return Page{}, err
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = def
+	}
+
+	return Page{After: after, Limit: min(limit, max)}, nil
+}
+
+// Fetch is how many rows to query for the page. One more than the limit is read to know whether another
+// page follows, see Trim
+func (p Page) Fetch() int {
+	return p.Limit + 1
+}
+
+// Trim drops the extra row read through Fetch and returns the cursor of the next page, or an empty string
+// when this is the last one.
+func Trim[T any](items []T, page Page, cursorOf func(T) Cursor) ([]T, string) {
+	if len(items) <= page.Limit {
+		return items, ""
+	}
+
+	items = items[:page.Limit]
+	return items, cursorOf(items[len(items)-1]).Encode()
+}
@@ -0,0 +1,79 @@
+package pagination
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 5, 20, 12, 30, 0, 123456000, time.UTC), Id: 42}
+
+	decoded, err := Decode(cursor.Encode())
+	if err != nil {
+		t.Fatalf("error decoding cursor: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.Id != cursor.Id {
+		t.Errorf("expected %+v; got %+v", cursor, decoded)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoded  string
+		expected error
+	}{
+		{"empty", "", nil},
+		{"not base64", "%%%", ErrInvalidCursor},
+		{"missing id", "MjAyNS0wNS0yMFQxMjozMDowMFo", ErrInvalidCursor},
+		{"invalid time", "bm93fDQy", ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		if _, err := Decode(tt.encoded); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedLimit int
+		expectedErr   error
+	}{
+		{"defaults", "", 50, nil},
+		{"limit", "?limit=10", 10, nil},
+		{"capped limit", "?limit=10000", 500, nil},
+		{"invalid limit", "?limit=-3", 50, nil},
+		{"invalid cursor", "?cursor=%25%25", 0, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		page, err := FromRequest(httptest.NewRequest("GET", "/"+tt.query, nil), 50, 500)
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expectedErr, err)
+		}
+		if page.Limit != tt.expectedLimit {
+			t.Errorf("%s: expected limit %d; got %d", tt.name, tt.expectedLimit, page.Limit)
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	cursorOf := func(id int) Cursor { return Cursor{Id: int64(id)} }
+	page := Page{Limit: 2}
+
+	items, next := Trim([]int{1, 2}, page, cursorOf)
+	if len(items) != 2 || next != "" {
+		t.Errorf("expected the last page; got %v with cursor %q", items, next)
+	}
+
+	items, next = Trim([]int{1, 2, 3}, page, cursorOf)
+	if len(items) != 2 || next != (Cursor{Id: 2}).Encode() {
+		t.Errorf("expected a page of 2 followed by a cursor on 2; got %v with cursor %q", items, next)
+	}
+}
@@ -0,0 +1,129 @@
+// Package plans defines the tiers users and organizations are on and the limits that come with each of them.
+package plans
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	Free       = "free"
+	Pro        = "pro"
+	Enterprise = "enterprise"
+
+	// Plan of users and organizations that weren't given one
+	Default = Free
+)
+
+// Plan is a set of limits and features. Limits of 0 are unlimited
+type Plan struct {
+	Name string `json:"name"`
+
+	LinksPerDay    int `json:"links_per_day"`
+	MaxActiveLinks int `json:"max_active_links"`
+
+	// Days click events and their daily rollups are kept for. 0 follows ANALYTICS_RETENTION_DAYS and
+	// ROLLUP_RETENTION_DAYS, which keep them forever when unset
+	AnalyticsRetentionDays int `json:"analytics_retention_days"`
+	RollupRetentionDays    int `json:"rollup_retention_days"`
+
+	CustomAliases bool `json:"custom_aliases"`
+	CustomDomains bool `json:"custom_domains"`
+}
+
+// Without a PLANS_FILE the plans only differ in features, so a self hosted instance keeps its QUOTA_* settings as the only limits
+var plans = map[string]*Plan{
+	Free:       {Name: Free},
+	Pro:        {Name: Pro, CustomAliases: true, CustomDomains: true},
+	Enterprise: {Name: Enterprise, CustomAliases: true, CustomDomains: true},
+}
+
+// Load reads the plans from the JSON file at PLANS_FILE, an object of plans keyed by name.
+// Plans missing from the file keep their defaults. It does nothing when PLANS_FILE isn't set.
+func Load() error {
+	for _, name := range []string{"ANALYTICS_RETENTION_DAYS", "ROLLUP_RETENTION_DAYS"} {
+		if value := os.Getenv(name); value != "" {
+			if days, err := strconv.Atoi(value); err != nil || days < 0 {
+				return fmt.Errorf("invalid %s %q, expected a number of days", name, value)
+			}
+		}
+	}
+
+	path := os.Getenv("PLANS_FILE")
+	if path == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read PLANS_FILE: %w", err)
+	}
+
+	loaded := map[string]*Plan{}
+	if err := json.Unmarshal(content, &loaded); err != nil {
+		return fmt.Errorf("invalid PLANS_FILE: %w", err)
+	}
+
+	for name, plan := range loaded {
+		if !Valid(name) {
+			return fmt.Errorf("invalid PLANS_FILE: unknown plan %q, plans are free, pro and enterprise", name)
+		}
+		if plan.LinksPerDay < 0 || plan.MaxActiveLinks < 0 || plan.AnalyticsRetentionDays < 0 || plan.RollupRetentionDays < 0 {
+			return fmt.Errorf("invalid PLANS_FILE: limits of plan %q can't be negative", name)
+		}
+		plan.Name = name
+	}
+
+	for name, plan := range loaded {
+		plans[name] = plan
+	}
+
+	return nil
+}
+
+// Valid reports whether name is one of the known plans.
+func Valid(name string) bool {
+	return name == Free || name == Pro || name == Enterprise
+}
+
+// Get returns the plan called name, or the default plan for an empty or unknown name.
+func Get(name string) *Plan {
+	if plan, ok := plans[name]; ok {
+		return plan
+	}
+	return plans[Default]
+}
+
+// All returns every plan, from the cheapest to the most expensive.
+func All() []*Plan {
+	return []*Plan{plans[Free], plans[Pro], plans[Enterprise]}
+}
+
+// Limit combines a limit of the plan with an instance wide one, the stricter applying. 0 is unlimited
+func Limit(planLimit int, instanceLimit int) int {
+	if planLimit == 0 || (instanceLimit > 0 && instanceLimit < planLimit) {
+		return instanceLimit
+	}
+	return planLimit
+}
+
+// ClickRetention returns the days click events of the plan are kept for, 0 for forever
+func (p *Plan) ClickRetention() int {
+	return retention(p.AnalyticsRetentionDays, "ANALYTICS_RETENTION_DAYS")
+}
+
+// RollupRetention returns the days daily rollups of the clicks of the plan are kept for, 0 for forever
+func (p *Plan) RollupRetention() int {
+	return retention(p.RollupRetentionDays, "ROLLUP_RETENTION_DAYS")
+}
+
+// retention returns the retention of a plan, or else the instance wide one set in the environment variable
+func retention(planDays int, name string) int {
+	if planDays > 0 {
+		return planDays
+	}
+	days, _ := strconv.Atoi(os.Getenv(name))
+	return max(days, 0)
+}
@@ -0,0 +1,90 @@
+package plans
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     int
+		instance int
+		expected int
+	}{
+		{"both unlimited", 0, 0, 0},
+		{"plan only", 100, 0, 100},
+		{"instance only", 0, 50, 50},
+		{"plan stricter", 10, 50, 10},
+		{"instance stricter", 100, 50, 50},
+	}
+
+	for _, tt := range tests {
+		if limit := Limit(tt.plan, tt.instance); limit != tt.expected {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expected, limit)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	defer func(saved map[string]*Plan) { plans = saved }(plans)
+
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"overrides", `{"free": {"links_per_day": 20, "analytics_retention_days": 30}}`, true},
+		{"unknown plan", `{"gold": {"links_per_day": 20}}`, false},
+		{"negative limit", `{"pro": {"max_active_links": -1}}`, false},
+		{"not json", `free: 20`, false},
+	}
+
+	for _, tt := range tests {
+		plans = map[string]*Plan{Free: {Name: Free}, Pro: {Name: Pro}, Enterprise: {Name: Enterprise}}
+
+		path := filepath.Join(t.TempDir(), "plans.json")
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PLANS_FILE", path)
+
+		if err := Load(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v; got %v", tt.name, tt.valid, err)
+		}
+
+		if free := Get(""); tt.valid && (free.Name != Free || free.LinksPerDay != 20 || free.AnalyticsRetentionDays != 30) {
+			t.Errorf("%s: expected the free plan to be loaded from the file; got %+v", tt.name, free)
+		}
+		if pro := Get(Pro); pro.MaxActiveLinks != 0 {
+			t.Errorf("%s: expected an invalid file to leave the plans untouched; got %+v", tt.name, pro)
+		}
+	}
+
+	if Get("gold").Name != Default {
+		t.Errorf("expected unknown plans to fall back to %s", Default)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	t.Setenv("ANALYTICS_RETENTION_DAYS", "90")
+	t.Setenv("ROLLUP_RETENTION_DAYS", "")
+
+	plan := &Plan{Name: Pro, AnalyticsRetentionDays: 30}
+	if days := plan.ClickRetention(); days != 30 {
+		t.Errorf("expected the retention of the plan; got %d", days)
+	}
+	if days := (&Plan{Name: Free}).ClickRetention(); days != 90 {
+		t.Errorf("expected ANALYTICS_RETENTION_DAYS for a plan without one; got %d", days)
+	}
+	if days := plan.RollupRetention(); days != 0 {
+		t.Errorf("expected rollups kept forever without ROLLUP_RETENTION_DAYS; got %d", days)
+	}
+
+	t.Setenv("PLANS_FILE", "")
+	t.Setenv("ROLLUP_RETENTION_DAYS", "two years")
+	if err := Load(); err == nil {
+		t.Error("expected an invalid ROLLUP_RETENTION_DAYS to be rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"url-shortner/internal/idn"
+)
+
+const (
+	DefaultRejectScore = 100
+	DefaultReviewScore = 40
+)
+
+// Well known public shorteners. Shortening their links only hides the real destination
+var knownShorteners = []string{
+	"bit.ly", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "buff.ly",
+	"rebrand.ly", "cutt.ly", "shorturl.at", "rb.gy", "tiny.cc", "t.ly", "s.id",
+}
+
+// KnownShortener returns the well known public shortener serving link, if any.
+func KnownShortener(link string) (string, bool) {
+	return FirstMatch(link, knownShorteners)
+}
+
+// Assessment is the result of the phishing heuristics for a destination.
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+func (a *Assessment) add(score int, reason string) {
+	a.Score += score
+	a.Reasons = append(a.Reasons, reason)
+}
+
+// Assess scores a destination on traits commonly found in phishing links.
+// The higher the score, the more suspicious the destination.
+func Assess(link string) Assessment {
+	assessment := Assessment{}
+
+	trimmed := strings.ToLower(strings.TrimSpace(link))
+	for _, scheme := range []string{"data:", "javascript:", "vbscript:", "file:"} {
+		if strings.HasPrefix(trimmed, scheme) {
+			assessment.add(100, "forbidden scheme "+strings.TrimSuffix(scheme, ":"))
+			return assessment
+		}
+	}
+
+	// Malformed destinations are a validation concern, not a phishing one
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsed.Hostname() == "" {
+		return assessment
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+
+	if net.ParseIP(host) != nil {
+		assessment.add(40, "raw ip host")
+	}
+
+	if parsed.User != nil {
+		assessment.add(40, "credentials in url")
+	}
+
+	if len(idn.Warnings(host)) > 0 {
+		assessment.add(40, "mixed-script or look-alike domain")
+	}
+
+	for _, shortener := range knownShorteners {
+		if MatchHost(host, shortener) {
+			assessment.add(50, "chained url shortener "+shortener)
+			break
+		}
+	}
+
+	if strings.Count(host, ".") >= 5 {
+		assessment.add(20, "excessive subdomains")
+	}
+
+	return assessment
+}
@@ -0,0 +1,32 @@
+package policy
+
+import "testing"
+
+func TestAssess(t *testing.T) {
+	tests := []struct {
+		link     string
+		minScore int
+		maxScore int
+	}{
+		{"https://example.com/page", 0, 0},
+		{"data:text/html;base64,PHNjcmlwdD4=", 100, 100},
+		{"JavaScript:alert(1)", 100, 100},
+		{"http://192.168.10.4/login", 40, 40},
+		{"https://paypal.com@evil.io/", 40, 40},
+		{"https://xn--pypal-4ve.com/", 40, 40},
+		{"https://pаypal.com/", 40, 40},
+		{"https://xn--mnchen-3ya.de/", 0, 0},
+		{"https://пример.рф/", 0, 0},
+		{"https://bit.ly/abc", 50, 50},
+		{"http://10.0.0.1/@", 40, 40},
+		{"https://a.b.c.d.e.example.com", 20, 20},
+		{"not a url", 0, 0},
+	}
+
+	for _, tt := range tests {
+		assessment := Assess(tt.link)
+		if assessment.Score < tt.minScore || assessment.Score > tt.maxScore {
+			t.Errorf("Assess(%q) = %d %v; expected between %d and %d", tt.link, assessment.Score, assessment.Reasons, tt.minScore, tt.maxScore)
+		}
+	}
+}
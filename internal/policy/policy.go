@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// NormalizePattern lowercases a domain pattern and strips surrounding dots and whitespace.
+func NormalizePattern(pattern string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(pattern)), ".")
+}
+
+// ParsePatterns splits a comma separated list of domain patterns, dropping empty entries.
+func ParsePatterns(list string) []string {
+	patterns := []string{}
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = NormalizePattern(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// HostOf returns the lowercased host of a destination url, without port.
+// It returns an empty string when the url can't be parsed or has no host.
+func HostOf(link string) string {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+}
+
+// MatchHost reports whether host matches a domain pattern.
+// A plain pattern like "example.com" matches the domain itself and any of its subdomains.
+// A pattern containing "*" is matched as a glob against the whole host, e.g. "*.example.*".
+func MatchHost(host string, pattern string) bool {
+	host = strings.ToLower(host)
+	pattern = NormalizePattern(pattern)
+	if host == "" || pattern == "" {
+		return false
+	}
+
+	if strings.Contains(pattern, "*") {
+		matched, err := path.Match(pattern, host)
+		return err == nil && matched
+	}
+
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// FirstMatch returns the first pattern matching the host of link, if any.
+func FirstMatch(link string, patterns []string) (string, bool) {
+	host := HostOf(link)
+	for _, pattern := range patterns {
+		if MatchHost(host, pattern) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package policy
+
+import "testing"
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		pattern  string
+		expected bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"notexample.com", "example.com", false},
+		{"example.com.evil.io", "example.com", false},
+		{"EXAMPLE.com", " Example.COM. ", true},
+		{"spam.example.net", "*.example.*", true},
+		{"example.net", "*.example.*", false},
+		{"", "example.com", false},
+		{"example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchHost(tt.host, tt.pattern); got != tt.expected {
+			t.Errorf("MatchHost(%q, %q) = %v; expected %v", tt.host, tt.pattern, got, tt.expected)
+		}
+	}
+}
+
+func TestFirstMatch(t *testing.T) {
+	patterns := []string{"spam.io", "*.bad.*"}
+
+	if pattern, ok := FirstMatch("https://promo.spam.io:8443/win?x=1", patterns); !ok || pattern != "spam.io" {
+		t.Errorf("expected spam.io to match; got %q, %v", pattern, ok)
+	}
+
+	if _, ok := FirstMatch("https://good.org/page", patterns); ok {
+		t.Errorf("expected good.org not to match")
+	}
+
+	if _, ok := FirstMatch("::not a url", patterns); ok {
+		t.Errorf("expected invalid url not to match")
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	patterns := ParsePatterns(" Corp.example ,, *.intranet.*,")
+
+	if len(patterns) != 2 || patterns[0] != "corp.example" || patterns[1] != "*.intranet.*" {
+		t.Errorf("unexpected patterns: %q", patterns)
+	}
+
+	if len(ParsePatterns("")) != 0 {
+		t.Errorf("expected no patterns for an empty list")
+	}
+}
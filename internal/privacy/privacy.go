@@ -0,0 +1,62 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	ipHashSalt = os.Getenv("IP_HASH_SALT")
+
+	// RawIpRetention is how long raw ips are kept for abuse investigations. Zero means raw ips are never stored
+	RawIpRetention = time.Duration(envInt("RAW_IP_RETENTION_HOURS", 0)) * time.Hour
+)
+
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return def
+	}
+	return value
+}
+
+// AnonymizeIP truncates an ip address to its /24 network for IPv4 and /48 for IPv6.
+// Anything that isn't an ip address is dropped.
+func AnonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// HashIP returns a salted hash of an ip address, letting clicks from the same visitor be
+// correlated without storing the address. It is empty for anything that isn't an ip address.
+func HashIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(ipHashSalt))
+	mac.Write([]byte(parsed.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RawIP returns the ip to keep for abuse investigations, or an empty string when raw ips must not be stored.
+func RawIP(ip string) string {
+	if RawIpRetention <= 0 || net.ParseIP(ip) == nil {
+		return ""
+	}
+	return ip
+}
@@ -0,0 +1,58 @@
+package privacy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.77":                  "203.0.113.0",
+		"::ffff:203.0.113.77":           "203.0.113.0",
+		"2001:db8:85a3:8d3:1319:8a2e::": "2001:db8:85a3::",
+		"not-an-ip":                     "",
+		"":                              "",
+	}
+
+	for ip, expected := range tests {
+		if got := AnonymizeIP(ip); got != expected {
+			t.Errorf("AnonymizeIP(%q) = %q; expected %q", ip, got, expected)
+		}
+	}
+}
+
+func TestHashIP(t *testing.T) {
+	ipHashSalt = "salt-one"
+	first := HashIP("203.0.113.77")
+
+	if first == "" || first != HashIP("203.0.113.77") {
+		t.Fatalf("expected a stable hash; got %q", first)
+	}
+
+	if first == HashIP("203.0.113.78") {
+		t.Errorf("expected different ips to hash differently")
+	}
+
+	ipHashSalt = "salt-two"
+	if first == HashIP("203.0.113.77") {
+		t.Errorf("expected the salt to change the hash")
+	}
+
+	if HashIP("nope") != "" {
+		t.Errorf("expected an empty hash for an invalid ip")
+	}
+}
+
+func TestRawIP(t *testing.T) {
+	defer func(retention time.Duration) { RawIpRetention = retention }(RawIpRetention)
+
+	RawIpRetention = 0
+	if RawIP("203.0.113.77") != "" {
+		t.Errorf("expected raw ip to be dropped without retention")
+	}
+
+	RawIpRetention = time.Hour
+	if RawIP("203.0.113.77") != "203.0.113.77" {
+		t.Errorf("expected raw ip to be kept with retention")
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory counts requests in the process, so each replica limits on its own
+type Memory struct {
+	mu      sync.Mutex
+	windows map[string]*memoryWindow
+	swept   time.Time
+	now     func() time.Time
+
+	counters
+}
+
+type memoryWindow struct {
+	start    time.Time
+	size     time.Duration
+	current  int64
+	previous int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		windows: map[string]*memoryWindow{},
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	return m.count(m.allow(key, limit, window)), nil
+}
+
+func (m *Memory) allow(key string, limit int, window time.Duration) Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	start := now.Truncate(window)
+	m.sweep(now, window)
+
+	w, ok := m.windows[key]
+	switch {
+	case !ok || w.size != window:
+		w = &memoryWindow{start: start, size: window}
+		m.windows[key] = w
+	case w.start.Add(window).Equal(start):
+		w.start, w.previous, w.current = start, w.current, 0
+	case !w.start.Equal(start):
+		w.start, w.previous, w.current = start, 0, 0
+	}
+
+	w.current++
+
+	return decide(w.previous, w.current, limit, start, window, now)
+}
+
+// sweep drops the windows no request has touched for two windows, at most once per window
+func (m *Memory) sweep(now time.Time, window time.Duration) {
+	if now.Sub(m.swept) < window {
+		return
+	}
+	m.swept = now
+
+	for key, w := range m.windows {
+		if now.Sub(w.start) >= 2*w.size {
+			delete(m.windows, key)
+		}
+	}
+}
+
+func (m *Memory) Metrics() Metrics {
+	return Metrics{
+		Backend: "memory",
+		Allowed: m.allowed.Load(),
+		Limited: m.limited.Load(),
+	}
+}
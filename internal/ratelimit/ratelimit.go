@@ -0,0 +1,116 @@
+// Package ratelimit counts requests in sliding windows. Counts are shared between replicas through Redis when
+// RATE_LIMIT_REDIS_URL is set, and kept in memory otherwise or while Redis is unavailable.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Requests allowed per window, zero meaning no limit
+var (
+	IpLimit  = envInt("RATE_LIMIT_IP_REQUESTS", 0)
+	KeyLimit = envInt("RATE_LIMIT_KEY_REQUESTS", 0)
+	Window   = time.Duration(envInt("RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second
+
+	redisUrl = os.Getenv("RATE_LIMIT_REDIS_URL")
+)
+
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return def
+	}
+	return value
+}
+
+// Result is the decision on a single request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// When the current window ends
+	ResetAt time.Time
+}
+
+// Metrics of a limiter since it started
+type Metrics struct {
+	Backend   string
+	Allowed   int64
+	Limited   int64
+	Fallbacks int64
+
+	// State of the Redis circuit breaker, empty for the memory limiter
+	BreakerState string
+}
+
+// Limiter decides whether the request identified by key fits in limit requests per window.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+	Metrics() Metrics
+}
+
+// CheckConfig validates the rate limit settings.
+func CheckConfig() error {
+	if Window <= 0 {
+		return fmt.Errorf("invalid RATE_LIMIT_WINDOW_SECONDS %q", os.Getenv("RATE_LIMIT_WINDOW_SECONDS"))
+	}
+	if redisUrl != "" {
+		if _, err := parseRedisUrl(redisUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New returns the Redis limiter when RATE_LIMIT_REDIS_URL is set and the memory limiter otherwise.
+func New() Limiter {
+	if redisUrl == "" {
+		return NewMemory()
+	}
+
+	limiter, err := NewRedis(redisUrl)
+	if err != nil {
+		log.Printf("[ratelimit:New] Invalid RATE_LIMIT_REDIS_URL, limiting in memory: %v", err)
+		return NewMemory()
+	}
+	return limiter
+}
+
+// estimate is the sliding window count: the previous window weighs by how much of it the sliding window still covers
+func estimate(previous, current int64, elapsed, window time.Duration) int64 {
+	weight := float64(window-elapsed) / float64(window)
+	return int64(float64(previous)*weight) + current
+}
+
+func decide(previous, current int64, limit int, start time.Time, window time.Duration, now time.Time) Result {
+	count := estimate(previous, current, now.Sub(start), window)
+	return Result{
+		Allowed:   count <= int64(limit),
+		Limit:     limit,
+		Remaining: max(limit-int(count), 0),
+		ResetAt:   start.Add(window),
+	}
+}
+
+// counters tracks the decisions of a limiter
+type counters struct {
+	allowed   atomic.Int64
+	limited   atomic.Int64
+	fallbacks atomic.Int64
+}
+
+func (c *counters) count(result Result) Result {
+	if result.Allowed {
+		c.allowed.Add(1)
+	} else {
+		c.limited.Add(1)
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortner/internal/breaker"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Connections kept open to Redis
+	redisPoolSize = 8
+
+	// Longest a call to Redis may take when the request has no earlier deadline. The limiter sits in front of
+	// every request, so a slow Redis is given up on quickly
+	redisTimeout = 250 * time.Millisecond
+)
+
+// slidingWindow counts the request in the current window and returns it along with the previous window.
+// Windows expire once they can no longer be the previous one. It is sent once, then run by its hash with EVALSHA
+var slidingWindow = redis.NewScript(`
+local current = redis.call('INCR', KEYS[1])
+if current == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+local previous = redis.call('GET', KEYS[2])
+return {current, tonumber(previous) or 0}
+`)
+
+// parseRedisUrl reads urls like redis://[[user]:password@]host[:port][/db]
+func parseRedisUrl(rawUrl string) (*redis.Options, error) {
+	options, err := redis.ParseURL(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_REDIS_URL, expected redis://[:password@]host[:port][/db]: %w", err)
+	}
+
+	options.PoolSize = redisPoolSize
+	options.DialTimeout = redisTimeout
+	options.ReadTimeout = redisTimeout
+	options.WriteTimeout = redisTimeout
+	options.ContextTimeoutEnabled = true
+	// The breaker and the memory limiter take over when Redis fails, retrying would only slow the request down
+	options.MaxRetries = -1
+
+	return options, nil
+}
+
+// Redis shares the counts between replicas. While Redis fails, requests are limited by the memory limiter
+// of each replica and a circuit breaker keeps them from waiting on Redis
+type Redis struct {
+	client   *redis.Client
+	breaker  *breaker.Breaker
+	fallback *Memory
+	now      func() time.Time
+
+	counters
+}
+
+func NewRedis(rawUrl string) (*Redis, error) {
+	options, err := parseRedisUrl(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Redis{
+		client:   redis.NewClient(options),
+		breaker:  breaker.New(3, 10*time.Second),
+		fallback: NewMemory(),
+		now:      time.Now,
+	}, nil
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	if err := r.breaker.Allow(); err != nil {
+		r.fallbacks.Add(1)
+		return r.count(r.fallback.allow(key, limit, window)), nil
+	}
+
+	now := r.now()
+	start := now.Truncate(window)
+	index := start.UnixMilli() / window.Milliseconds()
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	keys := []string{
+		fmt.Sprintf("ratelimit:%s:%d", key, index),
+		fmt.Sprintf("ratelimit:%s:%d", key, index-1),
+	}
+	counts, err := slidingWindow.Run(ctx, r.client, keys, 2*window.Milliseconds()).Int64Slice()
+	r.breaker.Record(err != nil)
+
+	if err == nil && len(counts) != 2 {
+		err = fmt.Errorf("unexpected reply %v", counts)
+	}
+	if err != nil {
+		log.Printf("[ratelimit:Redis] Could not count {%s} in Redis, limiting in memory: %v", key, err)
+		r.fallbacks.Add(1)
+		return r.count(r.fallback.allow(key, limit, window)), nil
+	}
+
+	return r.count(decide(counts[1], counts[0], limit, start, window, now)), nil
+}
+
+func (r *Redis) Metrics() Metrics {
+	return Metrics{
+		Backend:      "redis",
+		Allowed:      r.allowed.Load(),
+		Limited:      r.limited.Load(),
+		Fallbacks:    r.fallbacks.Load(),
+		BreakerState: r.breaker.State().String(),
+	}
+}
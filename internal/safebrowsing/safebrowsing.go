@@ -0,0 +1,123 @@
+package safebrowsing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	defaultEndpoint = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+	// The Lookup API accepts at most 500 threat entries per request
+	MaxBatchSize = 500
+)
+
+// Client checks urls against the Google Safe Browsing Lookup API (v4).
+type Client struct {
+	apiKey     string
+	endpoint   string
+	httpClient *http.Client
+
+	// FailClosed makes callers refuse destinations when the API can't be reached
+	FailClosed bool
+}
+
+// New returns a client configured from SAFE_BROWSING_API_KEY and SAFE_BROWSING_FAIL_CLOSED.
+// It returns nil when no api key is configured, meaning the integration is disabled.
+func New() *Client {
+	apiKey := os.Getenv("SAFE_BROWSING_API_KEY")
+	if apiKey == "" {
+		return nil
+	}
+
+	return &Client{
+		apiKey:     apiKey,
+		endpoint:   defaultEndpoint,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		FailClosed: os.Getenv("SAFE_BROWSING_FAIL_CLOSED") == "true",
+	}
+}
+
+type threatEntry struct {
+	Url string `json:"url"`
+}
+
+type findRequest struct {
+	Client struct {
+		ClientId      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string      `json:"threatTypes"`
+		PlatformTypes    []string      `json:"platformTypes"`
+		ThreatEntryTypes []string      `json:"threatEntryTypes"`
+		ThreatEntries    []threatEntry `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type findResponse struct {
+	Matches []struct {
+		ThreatType string      `json:"threatType"`
+		Threat     threatEntry `json:"threat"`
+	} `json:"matches"`
+}
+
+// Check looks up urls and returns the flagged ones mapped to their threat type.
+// Urls beyond MaxBatchSize must be split by the caller.
+func (c *Client) Check(ctx context.Context, urls []string) (map[string]string, error) {
+	if len(urls) > MaxBatchSize {
+		return nil, fmt.Errorf("safebrowsing: at most %d urls per lookup, got %d", MaxBatchSize, len(urls))
+	}
+
+	flagged := map[string]string{}
+	if len(urls) == 0 {
+		return flagged, nil
+	}
+
+	reqBody := findRequest{}
+	reqBody.Client.ClientId = "url-shortner"
+	reqBody.Client.ClientVersion = "1.0.0"
+	reqBody.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	reqBody.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	reqBody.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	for _, u := range urls {
+		reqBody.ThreatInfo.ThreatEntries = append(reqBody.ThreatInfo.ThreatEntries, threatEntry{Url: u})
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("safebrowsing: encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"?key="+c.apiKey, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("safebrowsing: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("safebrowsing: lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("safebrowsing: lookup returned status %d", resp.StatusCode)
+	}
+
+	var respBody findResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return nil, fmt.Errorf("safebrowsing: invalid response: %w", err)
+	}
+
+	for _, match := range respBody.Matches {
+		flagged[match.Threat.Url] = match.ThreatType
+	}
+
+	return flagged, nil
+}
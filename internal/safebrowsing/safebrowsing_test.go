@@ -0,0 +1,50 @@
+package safebrowsing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-key" {
+			t.Errorf("expected api key to be sent")
+		}
+
+		var reqBody findRequest
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		if len(reqBody.ThreatInfo.ThreatEntries) != 2 {
+			t.Errorf("expected 2 threat entries; got %d", len(reqBody.ThreatInfo.ThreatEntries))
+		}
+
+		w.Write([]byte(`{"matches":[{"threatType":"SOCIAL_ENGINEERING","threat":{"url":"http://phish.test/login"}}]}`))
+	}))
+	defer server.Close()
+
+	client := &Client{apiKey: "test-key", endpoint: server.URL, httpClient: server.Client()}
+
+	flagged, err := client.Check(context.Background(), []string{"http://phish.test/login", "https://example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(flagged) != 1 || flagged["http://phish.test/login"] != "SOCIAL_ENGINEERING" {
+		t.Errorf("unexpected flagged urls: %v", flagged)
+	}
+}
+
+func TestCheckApiError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &Client{apiKey: "test-key", endpoint: server.URL, httpClient: server.Client()}
+
+	if _, err := client.Check(context.Background(), []string{"https://example.com"}); err == nil {
+		t.Errorf("expected an error when the api is unavailable")
+	}
+}
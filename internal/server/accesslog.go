@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Formats of ACCESS_LOG_FORMAT. Unset keeps the development logger, mixed with the application logs
+const (
+	accessLogCombined = "combined"
+	accessLogJSON     = "json"
+)
+
+// checkAccessLog validates ACCESS_LOG_FORMAT and that ACCESS_LOG_FILE can be written to
+func checkAccessLog() error {
+	switch format := os.Getenv("ACCESS_LOG_FORMAT"); format {
+	case "", accessLogCombined, accessLogJSON:
+	default:
+		return fmt.Errorf("invalid ACCESS_LOG_FORMAT %q, expected %q or %q", format, accessLogCombined, accessLogJSON)
+	}
+
+	if path := os.Getenv("ACCESS_LOG_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("invalid ACCESS_LOG_FILE: %w", err)
+		}
+		file.Close()
+	}
+	return nil
+}
+
+// accessLogger returns the access log middleware configured by ACCESS_LOG_FORMAT, writing to ACCESS_LOG_FILE or
+// stdout, away from the application logs on stderr
+func accessLogger() func(http.Handler) http.Handler {
+	format := os.Getenv("ACCESS_LOG_FORMAT")
+	if format != accessLogCombined && format != accessLogJSON {
+		return middleware.Logger
+	}
+
+	var out io.Writer = os.Stdout
+	if path := os.Getenv("ACCESS_LOG_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			log.Printf("[server:accessLogger] Could not open ACCESS_LOG_FILE, writing to stdout: %v", err)
+		} else {
+			out = file
+		}
+	}
+	return newAccessLog(format, out)
+}
+
+// accessEntry is a line of the access log
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	LatencyMs  float64   `json:"latency_ms"`
+	Referrer   string    `json:"referrer"`
+	UserAgent  string    `json:"user_agent"`
+
+	latency time.Duration
+}
+
+// newAccessLog writes a line per request to out, in the Apache combined format or as JSON
+func newAccessLog(format string, out io.Writer) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			// Read before the handlers, decodePath rewrites the path
+			uri := r.RequestURI
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			entry := accessEntry{
+				Time:       start,
+				latency:    time.Since(start),
+				RemoteAddr: clientIP(r),
+				Method:     r.Method,
+				URI:        uri,
+				Proto:      r.Proto,
+				Status:     status,
+				Bytes:      ww.BytesWritten(),
+				Referrer:   r.Referer(),
+				UserAgent:  r.UserAgent(),
+			}
+
+			var line []byte
+			if format == accessLogJSON {
+				entry.LatencyMs = float64(entry.latency.Microseconds()) / 1000
+				line, _ = json.Marshal(entry)
+			} else {
+				line = []byte(entry.combined())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			out.Write(append(line, '\n'))
+		})
+	}
+}
+
+// combined formats the entry the way Apache's combined format does, followed by the latency in microseconds like
+// %D, which parsers of the combined format ignore
+func (e accessEntry) combined() string {
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.Itoa(e.Bytes)
+	}
+
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s "%s" "%s" %d`,
+		e.RemoteAddr, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, escapeAccessLog(e.URI), e.Proto, e.Status, bytes,
+		orDash(escapeAccessLog(e.Referrer)), orDash(escapeAccessLog(e.UserAgent)),
+		e.latency.Microseconds())
+}
+
+// escapeAccessLog escapes what would break the quoting of a combined line, as Apache does
+func escapeAccessLog(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSeeOther)
+		w.Write([]byte("redirect"))
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/short/abcdefgh?utm=1", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header.Set("Referer", "https://example.com/a")
+		req.Header.Set("User-Agent", `curl/8.0 "quoted"`)
+		return req
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		var out bytes.Buffer
+		newAccessLog(accessLogCombined, &out)(handler).ServeHTTP(httptest.NewRecorder(), newRequest())
+
+		expected := regexp.MustCompile(`^203\.0\.113\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+			`"GET /short/abcdefgh\?utm=1 HTTP/1\.1" 303 8 "https://example\.com/a" "curl/8\.0 \\"quoted\\"" \d+\n$`)
+		if !expected.MatchString(out.String()) {
+			t.Errorf("expected a combined line; got %q", out.String())
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		newAccessLog(accessLogJSON, &out)(handler).ServeHTTP(httptest.NewRecorder(), newRequest())
+
+		var entry accessEntry
+		if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+			t.Fatalf("expected a json line; got %q: %v", out.String(), err)
+		}
+		if entry.Status != http.StatusSeeOther || entry.Bytes != 8 || entry.Referrer != "https://example.com/a" ||
+			entry.URI != "/short/abcdefgh?utm=1" || entry.RemoteAddr != "203.0.113.7" {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) registerAccountRoutes(r chi.Router) {
+	r.Use(requireUser)
+
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/usage", s.usageHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/export", s.exportAccountHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Post("/export", s.exportArchiveHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/deletion", s.getDeletionRequestHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/deletion", s.requestDeletionHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/email-preferences", s.getEmailPreferencesHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Put("/email-preferences", s.updateEmailPreferencesHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Put("/password", s.changePasswordHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/email-verification", s.resendEmailVerificationHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/sessions", s.listSessionsHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Delete("/sessions/{session_id}", s.revokeSessionHandler)
+	r.With(requireScope(auth.ScopeLinksWrite), requireRole(auth.RoleEditor)).Post("/links/claim", s.claimLinkHandler)
+}
+
+// requireUser rejects anonymous requests.
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.UserFromContext(r.Context()) == nil {
+			writeError(w, http.StatusUnauthorized, "Authentication required.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userByEmail finds a user for the commands of the api, which name them by email
+func (s *Server) userByEmail(email string) (*database.UserModel, error) {
+	users, err := s.db.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("find user %s: %w", email, err)
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+
+	return nil, fmt.Errorf("find user %s: %w", email, database.ErrNotFound)
+}
+
+// creatorOf identifies who is shortening a link from the authentication of the request.
+func creatorOf(r *http.Request) shortener.Creator {
+	creator := shortener.Creator{Admin: isAdminToken(bearerToken(r))}
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		creator.UserId = &user.Id
+		creator.Plan = user.Plan
+		creator.EmailVerified = user.EmailVerifiedAt != nil
+	}
+	if apiKey := auth.ApiKeyFromContext(r.Context()); apiKey != nil {
+		creator.ApiKeyId = &apiKey.Id
+	}
+	return creator
+}
+
+type quotaResponse struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+
+	// Omitted when the quota is unlimited
+	Remaining *int `json:"remaining,omitempty"`
+}
+
+func toQuotaResponse(quota shortener.Quota) quotaResponse {
+	response := quotaResponse{Used: quota.Used, Limit: quota.Limit}
+	if remaining := quota.Remaining(); remaining >= 0 {
+		response.Remaining = &remaining
+	}
+	return response
+}
+
+func (s *Server) usageHandler(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.shortener.Usage(creatorOf(r), time.Now())
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the usage.")
+		return
+	}
+
+	response := struct {
+		Status           int            `json:"status"`
+		Plan             string         `json:"plan"`
+		LinksToday       quotaResponse  `json:"links_today"`
+		ActiveLinks      quotaResponse  `json:"active_links"`
+		ApiKeyLinksToday *quotaResponse `json:"api_key_links_today,omitempty"`
+		ResetsAt         time.Time      `json:"resets_at"`
+	}{
+		Status:      http.StatusOK,
+		Plan:        usage.Plan,
+		LinksToday:  toQuotaResponse(usage.LinksToday),
+		ActiveLinks: toQuotaResponse(usage.ActiveLinks),
+		ResetsAt:    usage.ResetsAt,
+	}
+	if usage.ApiKeyLinksToday != nil {
+		apiKeyLinksToday := toQuotaResponse(*usage.ApiKeyLinksToday)
+		response.ApiKeyLinksToday = &apiKeyLinksToday
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+type identityResponse struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type clickEventResponse struct {
+	ShortCode string    `json:"short_code"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Referrer  string    `json:"referrer"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+type deletionRequestResponse struct {
+	Id          int        `json:"id"`
+	Status      string     `json:"status"`
+	RequestedAt time.Time  `json:"requested_at"`
+	ProcessedAt *time.Time `json:"processed_at"`
+}
+
+func toDeletionRequestResponse(entity *database.DeletionRequestModel) deletionRequestResponse {
+	return deletionRequestResponse{
+		Id:          entity.Id,
+		Status:      entity.Status,
+		RequestedAt: entity.RequestedAt,
+		ProcessedAt: entity.ProcessedAt,
+	}
+}
+
+// accountExport is everything stored about a user, as exported by exportAccountHandler and in account.json of
+// the archive of exportArchiveHandler
+type accountExport struct {
+	User        userResponse         `json:"user"`
+	Identities  []identityResponse   `json:"identities"`
+	ApiKeys     []apiKeyResponse     `json:"api_keys"`
+	Links       []linkResponse       `json:"links"`
+	ClickEvents []clickEventResponse `json:"click_events"`
+}
+
+func toAccountExport(export *database.UserExportModel) accountExport {
+	identities := make([]identityResponse, 0, len(export.Identities))
+	for _, identity := range export.Identities {
+		identities = append(identities, identityResponse{
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	apiKeys := make([]apiKeyResponse, 0, len(export.ApiKeys))
+	for _, apiKey := range export.ApiKeys {
+		apiKeys = append(apiKeys, toApiKeyResponse(apiKey))
+	}
+
+	links := make([]linkResponse, 0, len(export.Links))
+	for _, link := range export.Links {
+		links = append(links, toLinkResponse(link))
+	}
+
+	clicks := make([]clickEventResponse, 0, len(export.ClickEvents))
+	for _, click := range export.ClickEvents {
+		clicks = append(clicks, clickEventResponse{
+			ShortCode: click.ShortCode,
+			Ip:        click.Ip,
+			UserAgent: click.UserAgent,
+			Referrer:  click.Referrer,
+			ClickedAt: click.ClickedAt,
+		})
+	}
+
+	return accountExport{
+		User:        toUserResponse(export.User),
+		Identities:  identities,
+		ApiKeys:     apiKeys,
+		Links:       links,
+		ClickEvents: clicks,
+	}
+}
+
+func (s *Server) exportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	export, err := s.db.ExportUserData(user.Id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not export account data.")
+		return
+	}
+
+	s.audit(r, "account.export", "user", user.Id, nil, nil)
+
+	w.Header().Set("Content-Disposition", `attachment; filename="account-export.json"`)
+	writeJSON(w, http.StatusOK, struct {
+		Status int `json:"status"`
+		accountExport
+	}{
+		Status:        http.StatusOK,
+		accountExport: toAccountExport(export),
+	})
+}
+
+func (s *Server) requestDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	request, err := s.db.RequestUserDeletion(user.Id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not register the deletion request.")
+		return
+	}
+
+	s.audit(r, "account.deletion_request", "user", user.Id, nil, toDeletionRequestResponse(request))
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status          int                     `json:"status"`
+		DeletionRequest deletionRequestResponse `json:"deletion_request"`
+	}{
+		Status:          http.StatusAccepted,
+		DeletionRequest: toDeletionRequestResponse(request),
+	})
+}
+
+func (s *Server) getDeletionRequestHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	request, err := s.db.GetDeletionRequest(user.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "No deletion request found.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not load the deletion request.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status          int                     `json:"status"`
+		DeletionRequest deletionRequestResponse `json:"deletion_request"`
+	}{
+		Status:          http.StatusOK,
+		DeletionRequest: toDeletionRequestResponse(request),
+	})
+}
@@ -0,0 +1,964 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/pagination"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/policy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) registerAdminRoutes(r chi.Router) {
+	r.Use(s.adminOnly)
+
+	r.Get("/links", s.adminListLinksHandler)
+	r.Get("/links/lookup", s.adminLookupLinkHandler)
+	r.Delete("/links/{short_code}", s.adminDeleteLinkHandler)
+	r.Post("/links/{short_code}/purge", s.adminPurgeLinkHandler)
+	r.Get("/links/{short_code}/moderation", s.adminModerationHistoryHandler)
+	r.Post("/links/{short_code}/moderation", s.adminModerationTransitionHandler)
+	r.Get("/stats", s.adminStatsHandler)
+	r.Get("/stats/domains", s.adminDomainStatsHandler)
+	r.Get("/stats/leaderboard", s.adminLeaderboardHandler)
+	r.Get("/audit", s.adminAuditLogHandler)
+
+	r.Get("/users", s.adminListUsersHandler)
+	r.Post("/users", s.adminCreateUserHandler)
+	r.Delete("/users/{user_id}", s.adminDeleteUserHandler)
+	r.Put("/users/{user_id}/role", s.adminUpdateUserRoleHandler)
+	r.Put("/users/{user_id}/plan", s.adminUpdateUserPlanHandler)
+	r.Put("/orgs/{organization_id}/plan", s.adminUpdateOrganizationPlanHandler)
+	r.Put("/orgs/{organization_id}/retention", s.adminUpdateOrganizationRetentionHandler)
+
+	r.Get("/users/{user_id}/api-keys", s.adminListApiKeysHandler)
+	r.Post("/users/{user_id}/api-keys", s.adminCreateApiKeyHandler)
+	r.Delete("/api-keys/{key_id}", s.adminRevokeApiKeyHandler)
+
+	r.Get("/banned-domains", s.adminListBannedDomainsHandler)
+	r.Post("/banned-domains", s.adminCreateBannedDomainHandler)
+	r.Delete("/banned-domains/{banned_domain_id}", s.adminDeleteBannedDomainHandler)
+
+	r.Get("/domains", s.adminListDomainsHandler)
+	r.Post("/domains", s.adminCreateDomainHandler)
+	r.Delete("/domains/{domain_id}", s.adminDeleteDomainHandler)
+	r.Put("/domains/{domain_id}/branding", s.adminUpdateDomainBrandingHandler)
+
+	r.Get("/namespaces", s.adminListNamespacesHandler)
+	r.Post("/namespaces", s.adminCreateNamespaceHandler)
+	r.Delete("/namespaces/{namespace_id}", s.adminDeleteNamespaceHandler)
+
+	r.Get("/reviews", s.adminListReviewsHandler)
+	r.Post("/reviews/{review_id}/approve", s.adminResolveReviewHandler(true))
+	r.Post("/reviews/{review_id}/reject", s.adminResolveReviewHandler(false))
+
+	r.Get("/reports", s.adminListReportsHandler)
+	r.Post("/reports/{report_id}/dismiss", s.adminDismissReportHandler)
+	r.Post("/reports/{report_id}/disable", s.adminDisableReportedLinkHandler)
+
+	r.Get("/cron", s.adminListCronJobsHandler)
+	r.Post("/cron/{name}/run", s.adminRunCronJobHandler)
+
+	r.Get("/maintenance", s.adminGetMaintenanceHandler)
+	r.Put("/maintenance", s.adminSetMaintenanceHandler)
+
+	r.Get("/debug/exchanges", s.adminListCapturesHandler)
+	r.Delete("/debug/exchanges", s.adminClearCapturesHandler)
+}
+
+type linkResponse struct {
+	Id             int        `json:"id"`
+	Link           string     `json:"link"`
+	ShortCode      string     `json:"short_code"`
+	TimesClicked   int        `json:"times_clicked"`
+	ExpTimeMinutes int        `json:"exp_time_minutes"`
+	CreatedAt      time.Time  `json:"created_at"`
+	OwnerId        *int       `json:"owner_id"`
+	OrganizationId *int       `json:"organization_id"`
+	DomainId       *int       `json:"domain_id"`
+	NamespaceId    *int       `json:"namespace_id"`
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason,omitempty"`
+	State          string     `json:"state"`
+	Visibility     string     `json:"visibility"`
+}
+
+type userResponse struct {
+	Id            int       `json:"id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	Plan          string    `json:"plan"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type apiKeyResponse struct {
+	Id         int        `json:"id"`
+	UserId     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+func toLinkResponse(entity *database.ShortUrlModel) linkResponse {
+	return linkResponse{
+		Id:             entity.Id,
+		Link:           entity.Link,
+		ShortCode:      entity.ShortCode,
+		TimesClicked:   entity.TimesClicked,
+		ExpTimeMinutes: entity.ExpTimeMinutes,
+		CreatedAt:      entity.CreatedAt,
+		OwnerId:        entity.OwnerId,
+		OrganizationId: entity.OrganizationId,
+		DomainId:       entity.DomainId,
+		NamespaceId:    entity.NamespaceId,
+		DisabledAt:     entity.DisabledAt,
+		DisabledReason: entity.DisabledReason,
+		State:          entity.ModerationState,
+		Visibility:     entity.Visibility,
+	}
+}
+
+type bannedDomainResponse struct {
+	Id        int       `json:"id"`
+	Pattern   string    `json:"pattern"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toBannedDomainResponse(entity *database.BannedDomainModel) bannedDomainResponse {
+	return bannedDomainResponse{
+		Id:        entity.Id,
+		Pattern:   entity.Pattern,
+		Reason:    entity.Reason,
+		CreatedAt: entity.CreatedAt,
+	}
+}
+
+type reviewResponse struct {
+	Id         int        `json:"id"`
+	ShortCode  string     `json:"short_code"`
+	Link       string     `json:"link"`
+	Score      int        `json:"score"`
+	Reasons    string     `json:"reasons"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+func toReviewResponse(entity *database.ReviewModel) reviewResponse {
+	return reviewResponse{
+		Id:         entity.Id,
+		ShortCode:  entity.ShortCode,
+		Link:       entity.Link,
+		Score:      entity.Score,
+		Reasons:    entity.Reasons,
+		Status:     entity.Status,
+		CreatedAt:  entity.CreatedAt,
+		ReviewedAt: entity.ReviewedAt,
+	}
+}
+
+type abuseReportResponse struct {
+	Id                int        `json:"id"`
+	ShortCode         string     `json:"short_code"`
+	Link              string     `json:"link"`
+	Reason            string     `json:"reason"`
+	Details           string     `json:"details"`
+	ReporterEmail     string     `json:"reporter_email"`
+	ReporterIp        string     `json:"reporter_ip"`
+	ReporterUserAgent string     `json:"reporter_user_agent"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ResolvedAt        *time.Time `json:"resolved_at"`
+}
+
+func toAbuseReportResponse(entity *database.AbuseReportModel) abuseReportResponse {
+	return abuseReportResponse{
+		Id:                entity.Id,
+		ShortCode:         entity.ShortCode,
+		Link:              entity.Link,
+		Reason:            entity.Reason,
+		Details:           entity.Details,
+		ReporterEmail:     entity.ReporterEmail,
+		ReporterIp:        entity.ReporterIp,
+		ReporterUserAgent: entity.ReporterUserAgent,
+		Status:            entity.Status,
+		CreatedAt:         entity.CreatedAt,
+		ResolvedAt:        entity.ResolvedAt,
+	}
+}
+
+func toUserResponse(entity *database.UserModel) userResponse {
+	return userResponse{
+		Id:            entity.Id,
+		Email:         entity.Email,
+		EmailVerified: entity.EmailVerifiedAt != nil,
+		Role:          entity.Role,
+		Plan:          entity.Plan,
+		CreatedAt:     entity.CreatedAt,
+	}
+}
+
+func toApiKeyResponse(entity *database.ApiKeyModel) apiKeyResponse {
+	return apiKeyResponse{
+		Id:         entity.Id,
+		UserId:     entity.UserId,
+		Name:       entity.Name,
+		KeyPrefix:  entity.KeyPrefix,
+		Scopes:     entity.Scopes,
+		CreatedAt:  entity.CreatedAt,
+		LastUsedAt: entity.LastUsedAt,
+		RevokedAt:  entity.RevokedAt,
+		ExpiresAt:  entity.ExpiresAt,
+	}
+}
+
+func linkCursor(entity *database.ShortUrlModel) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: entity.CreatedAt, Id: int64(entity.Id)}
+}
+
+func (s *Server) adminListLinksHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.FromRequest(r, 50, 500)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+
+	entities, err := s.db.ListShortUrls(page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list links.")
+		return
+	}
+	entities, nextCursor := pagination.Trim(entities, page, linkCursor)
+	if s.listingNotModified(w, r, entities) {
+		return
+	}
+
+	links := make([]linkResponse, 0, len(entities))
+	for _, entity := range entities {
+		links = append(links, toLinkResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status     int            `json:"status"`
+		Links      []linkResponse `json:"links"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+	}{
+		Status:     http.StatusOK,
+		Links:      links,
+		NextCursor: nextCursor,
+	})
+}
+
+func (s *Server) adminLookupLinkHandler(w http.ResponseWriter, r *http.Request) {
+	link := r.URL.Query().Get("url")
+	if link == "" {
+		writeError(w, http.StatusBadRequest, "The url query parameter is required.")
+		return
+	}
+
+	entities, err := s.db.FindShortUrlsByLink(link)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not look up links.")
+		return
+	}
+
+	links := make([]linkResponse, 0, len(entities))
+	for _, entity := range entities {
+		links = append(links, toLinkResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		Links  []linkResponse `json:"links"`
+	}{
+		Status: http.StatusOK,
+		Links:  links,
+	})
+}
+
+func (s *Server) adminDeleteLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("short_code")
+	log.Printf("[admin:adminDeleteLinkHandler] Force deleting short_code: {%s}", shortCode)
+
+	before, _ := s.db.GetShortUrl(shortCode)
+
+	err := s.db.DeleteShortUrl(shortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Did not found a url for the short_code")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not delete link.")
+		return
+	}
+	s.forgetLink(shortCode)
+
+	if before != nil {
+		s.audit(r, "link.delete", "link", shortCode, toLinkResponse(before), nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) adminPurgeLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("short_code")
+	log.Printf("[admin:adminPurgeLinkHandler] Purging short_code: {%s}", shortCode)
+
+	before, _ := s.db.GetShortUrl(shortCode)
+
+	summary, err := s.db.PurgeShortUrl(shortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Did not found a url for the short_code")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not purge link.")
+		return
+	}
+	s.forgetLink(shortCode)
+
+	type purgeSummary struct {
+		ShortCode          string `json:"short_code"`
+		Link               string `json:"link"`
+		LinksRemoved       int    `json:"links_removed"`
+		TimesClicked       int    `json:"times_clicked"`
+		ClickEventsRemoved int64  `json:"click_events_removed"`
+	}
+
+	removed := purgeSummary{
+		ShortCode:          summary.ShortCode,
+		Link:               summary.Link,
+		LinksRemoved:       summary.LinksRemoved,
+		TimesClicked:       summary.TimesClicked,
+		ClickEventsRemoved: summary.ClickEventsRemoved,
+	}
+
+	var beforeResponse any
+	if before != nil {
+		beforeResponse = toLinkResponse(before)
+	}
+	s.audit(r, "link.purge", "link", shortCode, beforeResponse, removed)
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int          `json:"status"`
+		Removed purgeSummary `json:"removed"`
+	}{
+		Status:  http.StatusOK,
+		Removed: removed,
+	})
+}
+
+func (s *Server) adminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.db.GlobalStats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not collect stats.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status       int `json:"status"`
+		TotalLinks   int `json:"total_links"`
+		ActiveLinks  int `json:"active_links"`
+		ExpiredLinks int `json:"expired_links"`
+		TotalClicks  int `json:"total_clicks"`
+		TotalUsers   int `json:"total_users"`
+		TotalApiKeys int `json:"total_api_keys"`
+	}{
+		Status:       http.StatusOK,
+		TotalLinks:   stats.TotalLinks,
+		ActiveLinks:  stats.ActiveLinks,
+		ExpiredLinks: stats.ExpiredLinks,
+		TotalClicks:  stats.TotalClicks,
+		TotalUsers:   stats.TotalUsers,
+		TotalApiKeys: stats.TotalApiKeys,
+	})
+}
+
+func (s *Server) adminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	entities, err := s.db.ListUsers()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list users.")
+		return
+	}
+
+	users := make([]userResponse, 0, len(entities))
+	for _, entity := range entities {
+		users = append(users, toUserResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		Users  []userResponse `json:"users"`
+	}{
+		Status: http.StatusOK,
+		Users:  users,
+	})
+}
+
+func (s *Server) adminCreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.Email == "" {
+		writeError(w, http.StatusBadRequest, "A valid email is required.")
+		return
+	}
+
+	if reqBody.Role == "" {
+		reqBody.Role = auth.RoleEditor
+	}
+	if !auth.ValidRole(reqBody.Role) {
+		writeError(w, http.StatusBadRequest, "Role must be 'viewer', 'editor' or 'admin'.")
+		return
+	}
+
+	entity, err := s.db.SaveUser(&database.UserModel{
+		Email: reqBody.Email,
+		Role:  reqBody.Role,
+	})
+	if writeConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not create user.")
+		return
+	}
+
+	s.audit(r, "user.create", "user", entity.Id, nil, toUserResponse(entity))
+
+	writeJSON(w, http.StatusCreated, struct {
+		Status int          `json:"status"`
+		User   userResponse `json:"user"`
+	}{
+		Status: http.StatusCreated,
+		User:   toUserResponse(entity),
+	})
+}
+
+func (s *Server) adminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	err = s.db.DeleteUser(userId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not delete user.")
+		return
+	}
+	// The links of the user are left without an owner
+	s.forgetLinks()
+
+	s.audit(r, "user.delete", "user", userId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) adminUpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	var reqBody struct {
+		Role string `json:"role"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	if !auth.ValidRole(reqBody.Role) {
+		writeError(w, http.StatusBadRequest, "Role must be 'viewer', 'editor' or 'admin'.")
+		return
+	}
+
+	entity, err := s.db.UpdateUserRole(userId, reqBody.Role)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the role.")
+		return
+	}
+
+	s.audit(r, "user.role_update", "user", userId, nil, toUserResponse(entity))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int          `json:"status"`
+		User   userResponse `json:"user"`
+	}{
+		Status: http.StatusOK,
+		User:   toUserResponse(entity),
+	})
+}
+
+func (s *Server) adminUpdateUserPlanHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	var reqBody struct {
+		Plan string `json:"plan"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	if !plans.Valid(reqBody.Plan) {
+		writeError(w, http.StatusBadRequest, "Plan must be 'free', 'pro' or 'enterprise'.")
+		return
+	}
+
+	entity, err := s.db.UpdateUserPlan(userId, reqBody.Plan)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the plan.")
+		return
+	}
+
+	s.audit(r, "user.plan_update", "user", userId, nil, toUserResponse(entity))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int          `json:"status"`
+		User   userResponse `json:"user"`
+	}{
+		Status: http.StatusOK,
+		User:   toUserResponse(entity),
+	})
+}
+
+func (s *Server) adminUpdateOrganizationPlanHandler(w http.ResponseWriter, r *http.Request) {
+	organizationId, err := strconv.Atoi(r.PathValue("organization_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid organization id.")
+		return
+	}
+
+	var reqBody struct {
+		Plan string `json:"plan"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	if !plans.Valid(reqBody.Plan) {
+		writeError(w, http.StatusBadRequest, "Plan must be 'free', 'pro' or 'enterprise'.")
+		return
+	}
+
+	entity, err := s.db.UpdateOrganizationPlan(organizationId, reqBody.Plan)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Organization not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the plan.")
+		return
+	}
+
+	s.audit(r, "organization.plan_update", "organization", organizationId, nil, toOrganizationResponse(entity))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status       int                  `json:"status"`
+		Organization organizationResponse `json:"organization"`
+	}{
+		Status:       http.StatusOK,
+		Organization: toOrganizationResponse(entity),
+	})
+}
+
+type retentionResponse struct {
+	OrganizationId      int  `json:"organization_id"`
+	ClickRetentionDays  *int `json:"click_retention_days"`
+	RollupRetentionDays *int `json:"rollup_retention_days"`
+}
+
+func toRetentionResponse(entity *database.RetentionModel) retentionResponse {
+	return retentionResponse{
+		OrganizationId:      entity.OrganizationId,
+		ClickRetentionDays:  entity.ClickRetentionDays,
+		RollupRetentionDays: entity.RollupRetentionDays,
+	}
+}
+
+// adminUpdateOrganizationRetentionHandler overrides the analytics retention of the plan of an organization. A
+// retention left out or null follows the plan again, 0 keeps forever
+func (s *Server) adminUpdateOrganizationRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	organizationId, err := strconv.Atoi(r.PathValue("organization_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid organization id.")
+		return
+	}
+
+	var reqBody struct {
+		ClickRetentionDays  *int `json:"click_retention_days"`
+		RollupRetentionDays *int `json:"rollup_retention_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+	if (reqBody.ClickRetentionDays != nil && *reqBody.ClickRetentionDays < 0) || (reqBody.RollupRetentionDays != nil && *reqBody.RollupRetentionDays < 0) {
+		writeError(w, http.StatusBadRequest, "Retentions can't be negative.")
+		return
+	}
+
+	entity, err := s.db.SetOrganizationRetention(&database.RetentionModel{
+		OrganizationId:      organizationId,
+		ClickRetentionDays:  reqBody.ClickRetentionDays,
+		RollupRetentionDays: reqBody.RollupRetentionDays,
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Organization not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the retention.")
+		return
+	}
+
+	s.audit(r, "organization.retention_update", "organization", organizationId, nil, toRetentionResponse(entity))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status    int               `json:"status"`
+		Retention retentionResponse `json:"retention"`
+	}{
+		Status:    http.StatusOK,
+		Retention: toRetentionResponse(entity),
+	})
+}
+
+func (s *Server) adminListApiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	entities, err := s.db.ListApiKeys(userId)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list api keys.")
+		return
+	}
+
+	apiKeys := make([]apiKeyResponse, 0, len(entities))
+	for _, entity := range entities {
+		apiKeys = append(apiKeys, toApiKeyResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int              `json:"status"`
+		ApiKeys []apiKeyResponse `json:"api_keys"`
+	}{
+		Status:  http.StatusOK,
+		ApiKeys: apiKeys,
+	})
+}
+
+func (s *Server) adminCreateApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	var reqBody struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	if reqBody.Name == "" {
+		reqBody.Name = "default"
+	}
+	if len(reqBody.Scopes) == 0 {
+		reqBody.Scopes = auth.DefaultScopes
+	}
+	for _, scope := range reqBody.Scopes {
+		if !auth.ValidScope(scope) {
+			writeError(w, http.StatusBadRequest, "Unknown scope '"+scope+"'. Scopes are links:write, links:read, stats:read and admin.")
+			return
+		}
+	}
+
+	plain, prefix, hash, err := auth.GenerateApiKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not generate api key.")
+		return
+	}
+
+	entity, err := s.db.SaveApiKey(&database.ApiKeyModel{
+		UserId:    userId,
+		Name:      reqBody.Name,
+		KeyPrefix: prefix,
+		KeyHash:   hash,
+		Scopes:    reqBody.Scopes,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not create api key.")
+		return
+	}
+
+	s.audit(r, "api_key.create", "api_key", entity.Id, nil, toApiKeyResponse(entity))
+
+	// The plain key is only ever returned here
+	writeJSON(w, http.StatusCreated, struct {
+		Status int            `json:"status"`
+		ApiKey apiKeyResponse `json:"api_key"`
+		Key    string         `json:"key"`
+	}{
+		Status: http.StatusCreated,
+		ApiKey: toApiKeyResponse(entity),
+		Key:    plain,
+	})
+}
+
+func (s *Server) adminRevokeApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyId, err := strconv.Atoi(r.PathValue("key_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid api key id.")
+		return
+	}
+
+	err = s.db.RevokeApiKey(keyId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Api key not found or already revoked.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not revoke api key.")
+		return
+	}
+
+	s.audit(r, "api_key.revoke", "api_key", keyId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) adminListBannedDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	entities, err := s.db.ListBannedDomains()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list banned domains.")
+		return
+	}
+
+	bannedDomains := make([]bannedDomainResponse, 0, len(entities))
+	for _, entity := range entities {
+		bannedDomains = append(bannedDomains, toBannedDomainResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status        int                    `json:"status"`
+		BannedDomains []bannedDomainResponse `json:"banned_domains"`
+	}{
+		Status:        http.StatusOK,
+		BannedDomains: bannedDomains,
+	})
+}
+
+func (s *Server) adminCreateBannedDomainHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Pattern string `json:"pattern"`
+		Reason  string `json:"reason"`
+	}
+
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	pattern := policy.NormalizePattern(reqBody.Pattern)
+	if pattern == "" || strings.ContainsAny(pattern, "/:? ") {
+		writeError(w, http.StatusBadRequest, "A domain or domain pattern (e.g. 'spam.com' or '*.spam.*') is required.")
+		return
+	}
+
+	entity, err := s.db.SaveBannedDomain(&database.BannedDomainModel{
+		Pattern: pattern,
+		Reason:  reqBody.Reason,
+	})
+	if writeConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not ban domain.")
+		return
+	}
+
+	log.Printf("[admin:adminCreateBannedDomainHandler] Banned pattern: {%s}", entity.Pattern)
+	s.audit(r, "banned_domain.create", "banned_domain", entity.Id, nil, toBannedDomainResponse(entity))
+
+	writeJSON(w, http.StatusCreated, struct {
+		Status       int                  `json:"status"`
+		BannedDomain bannedDomainResponse `json:"banned_domain"`
+	}{
+		Status:       http.StatusCreated,
+		BannedDomain: toBannedDomainResponse(entity),
+	})
+}
+
+func (s *Server) adminDeleteBannedDomainHandler(w http.ResponseWriter, r *http.Request) {
+	bannedDomainId, err := strconv.Atoi(r.PathValue("banned_domain_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid banned domain id.")
+		return
+	}
+
+	err = s.db.DeleteBannedDomain(bannedDomainId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Banned domain not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not remove banned domain.")
+		return
+	}
+
+	s.audit(r, "banned_domain.delete", "banned_domain", bannedDomainId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) adminListReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = database.ReviewPending
+	}
+
+	entities, err := s.db.ListReviews(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list reviews.")
+		return
+	}
+
+	reviews := make([]reviewResponse, 0, len(entities))
+	for _, entity := range entities {
+		reviews = append(reviews, toReviewResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int              `json:"status"`
+		Reviews []reviewResponse `json:"reviews"`
+	}{
+		Status:  http.StatusOK,
+		Reviews: reviews,
+	})
+}
+
+func (s *Server) adminResolveReviewHandler(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewId, err := strconv.Atoi(r.PathValue("review_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid review id.")
+			return
+		}
+
+		err = s.db.ResolveReview(reviewId, approve)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Pending review not found.")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Could not resolve review.")
+			return
+		}
+		s.forgetLinks()
+
+		log.Printf("[admin:adminResolveReviewHandler] Review {%d} resolved, approved: %v", reviewId, approve)
+
+		action := "review.reject"
+		if approve {
+			action = "review.approve"
+		}
+		s.audit(r, action, "review", reviewId, nil, nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) adminListReportsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = database.ReportOpen
+	}
+
+	entities, err := s.db.ListAbuseReports(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list abuse reports.")
+		return
+	}
+
+	reports := make([]abuseReportResponse, 0, len(entities))
+	for _, entity := range entities {
+		reports = append(reports, toAbuseReportResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int                   `json:"status"`
+		Reports []abuseReportResponse `json:"reports"`
+	}{
+		Status:  http.StatusOK,
+		Reports: reports,
+	})
+}
+
+func (s *Server) adminDismissReportHandler(w http.ResponseWriter, r *http.Request) {
+	reportId, err := strconv.Atoi(r.PathValue("report_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid report id.")
+		return
+	}
+
+	err = s.db.DismissAbuseReport(reportId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Open report not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not dismiss report.")
+		return
+	}
+
+	s.audit(r, "report.dismiss", "report", reportId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) adminDisableReportedLinkHandler(w http.ResponseWriter, r *http.Request) {
+	reportId, err := strconv.Atoi(r.PathValue("report_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid report id.")
+		return
+	}
+
+	err = s.db.DisableReportedLink(reportId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Open report not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not disable the reported link.")
+		return
+	}
+	s.forgetLinks()
+
+	log.Printf("[admin:adminDisableReportedLinkHandler] Disabled link of report {%d}", reportId)
+	s.audit(r, "report.disable_link", "report", reportId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
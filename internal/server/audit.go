@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/pagination"
+)
+
+// audit records a mutation in the audit log. before and after are marshalled to JSON and may be nil.
+// Failing to audit never fails the request, it is only logged.
+func (s *Server) audit(r *http.Request, action string, entityType string, entityId any, before any, after any) {
+	entry := &database.AuditLogModel{
+		Actor:      actorOf(r),
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   toEntityId(entityId),
+		Ip:         clientIP(r),
+	}
+
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		entry.After, _ = json.Marshal(after)
+	}
+
+	if err := s.db.RecordAudit(entry); err != nil {
+		log.Printf("[audit:audit] Could not audit {%s} on {%s:%s}: %v", action, entityType, entry.EntityId, err)
+	}
+}
+
+func toEntityId(entityId any) string {
+	switch id := entityId.(type) {
+	case string:
+		return id
+	case int:
+		return strconv.Itoa(id)
+	default:
+		b, _ := json.Marshal(id)
+		return string(b)
+	}
+}
+
+type auditLogResponse struct {
+	Id         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityId   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	Ip         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func rawJSON(document []byte) json.RawMessage {
+	if len(document) == 0 {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(document)
+}
+
+func (s *Server) adminAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := pagination.FromRequest(r, 100, 1000)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+
+	entities, err := s.db.ListAuditLog(database.AuditLogFilter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		EntityId:   query.Get("entity_id"),
+		Page:       page,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not query the audit log.")
+		return
+	}
+	entities, nextCursor := pagination.Trim(entities, page, func(entity *database.AuditLogModel) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: entity.CreatedAt, Id: entity.Id}
+	})
+
+	entries := make([]auditLogResponse, 0, len(entities))
+	for _, entity := range entities {
+		entries = append(entries, auditLogResponse{
+			Id:         entity.Id,
+			Actor:      entity.Actor,
+			Action:     entity.Action,
+			EntityType: entity.EntityType,
+			EntityId:   entity.EntityId,
+			Before:     rawJSON(entity.Before),
+			After:      rawJSON(entity.After),
+			Ip:         entity.Ip,
+			CreatedAt:  entity.CreatedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status     int                `json:"status"`
+		Entries    []auditLogResponse `json:"entries"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}{
+		Status:     http.StatusOK,
+		Entries:    entries,
+		NextCursor: nextCursor,
+	})
+}
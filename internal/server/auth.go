@@ -0,0 +1,140 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+)
+
+var adminToken = os.Getenv("ADMIN_TOKEN")
+
+// Requests a single api key may make per UTC day, 0 means unlimited
+var apiKeyRequestsPerDay, _ = strconv.ParseInt(os.Getenv("API_KEY_REQUESTS_PER_DAY"), 10, 64)
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func isAdminToken(token string) bool {
+	if adminToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// authenticate resolves the api key sent as a bearer token into a user and stores it in the request context.
+// Requests without a token are authenticated by their session cookie, if any, or go through as anonymous. An unknown
+// or revoked key is rejected.
+// Every request counts in the daily usage of its key, and past API_KEY_REQUESTS_PER_DAY the key is answered with 429.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			s.authenticateSession(w, r, next)
+			return
+		}
+		if isAdminToken(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		apiKey, err := s.db.GetApiKeyByHash(auth.HashApiKey(token))
+		if errors.Is(err, database.ErrNotFound) {
+			log.Printf("[auth:authenticate] Rejected api key: %v", err)
+			writeError(w, http.StatusUnauthorized, "Invalid api key.")
+			return
+		}
+		if err != nil {
+			log.Printf("[auth:authenticate] Could not check api key: %v", err)
+			writeError(w, statusOf(err), "Could not check the api key. Try again later")
+			return
+		}
+
+		// Counting is best effort, a failure here must not lock the key out. Nothing is written during a maintenance
+		var usage *database.ApiKeyUsageModel
+		if !maintenance.Enabled() {
+			usage, err = s.db.AddApiKeyUsage(apiKey.Id, 1, 0)
+			if err != nil {
+				log.Printf("[auth:authenticate] Could not count the request of api key {%d}: %v", apiKey.Id, err)
+			}
+		}
+		if usage != nil && apiKeyRequestsPerDay > 0 && usage.Requests > apiKeyRequestsPerDay {
+			setRetryAfter(w, time.Now().UTC().Truncate(24*time.Hour).Add(24*time.Hour))
+			writeError(w, http.StatusTooManyRequests, "Daily request limit of the api key reached, it resets at midnight UTC.")
+			return
+		}
+
+		ctx := auth.WithApiKey(auth.WithUser(r.Context(), apiKey.User), apiKey)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// adminOnly allows requests carrying the ADMIN_TOKEN or an api key of a user with the admin role.
+func (s *Server) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminToken(bearerToken(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "Authentication required.")
+			return
+		}
+
+		if user.Role != auth.RoleAdmin {
+			writeError(w, http.StatusForbidden, "Admin role required.")
+			return
+		}
+
+		if !auth.HasScope(r.Context(), auth.ScopeAdmin) {
+			writeError(w, http.StatusForbidden, "The api key lacks the admin scope.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole rejects authenticated users whose role is below role. Anonymous and admin token requests
+// are left to the other checks of the route.
+func requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.UserFromContext(r.Context())
+			if user != nil && !auth.RoleAllows(user.Role, role) {
+				writeError(w, http.StatusForbidden, "The "+role+" role is required.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireScope rejects requests made with an api key that wasn't granted scope.
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(r.Context(), scope) {
+				writeError(w, http.StatusForbidden, "The api key lacks the "+scope+" scope.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
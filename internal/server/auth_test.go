@@ -0,0 +1,185 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/mocks"
+)
+
+func TestAdminOnly(t *testing.T) {
+	adminToken = "secret-admin-token"
+	defer func() { adminToken = "" }()
+
+	s := &Server{}
+	handler := s.adminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		token    string
+		user     *database.UserModel
+		scopes   []string
+		expected int
+	}{
+		{name: "anonymous", expected: http.StatusUnauthorized},
+		{name: "wrong token", token: "nope", expected: http.StatusUnauthorized},
+		{name: "admin token", token: "secret-admin-token", expected: http.StatusOK},
+		{name: "viewer", user: &database.UserModel{Id: 1, Role: auth.RoleViewer}, expected: http.StatusForbidden},
+		{name: "editor", user: &database.UserModel{Id: 1, Role: auth.RoleEditor}, expected: http.StatusForbidden},
+		{name: "admin user", user: &database.UserModel{Id: 2, Role: auth.RoleAdmin}, expected: http.StatusOK},
+		{name: "admin user with admin scope", user: &database.UserModel{Id: 2, Role: auth.RoleAdmin}, scopes: []string{auth.ScopeAdmin}, expected: http.StatusOK},
+		{name: "admin user without admin scope", user: &database.UserModel{Id: 2, Role: auth.RoleAdmin}, scopes: auth.DefaultScopes, expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.user != nil {
+				req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+			}
+			if tt.scopes != nil {
+				req = req.WithContext(auth.WithApiKey(req.Context(), &database.ApiKeyModel{Scopes: tt.scopes}))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d; got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	handler := requireScope(auth.ScopeLinksWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		apiKey   *database.ApiKeyModel
+		expected int
+	}{
+		{name: "anonymous", expected: http.StatusOK},
+		{name: "key with scope", apiKey: &database.ApiKeyModel{Scopes: auth.DefaultScopes}, expected: http.StatusOK},
+		{name: "read only key", apiKey: &database.ApiKeyModel{Scopes: []string{auth.ScopeLinksRead, auth.ScopeStatsRead}}, expected: http.StatusForbidden},
+		{name: "key without scopes", apiKey: &database.ApiKeyModel{}, expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/short", nil)
+			if tt.apiKey != nil {
+				req = req.WithContext(auth.WithApiKey(req.Context(), tt.apiKey))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d; got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := requireRole(auth.RoleEditor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		user     *database.UserModel
+		expected int
+	}{
+		{name: "anonymous", expected: http.StatusOK},
+		{name: "viewer", user: &database.UserModel{Id: 1, Role: auth.RoleViewer}, expected: http.StatusForbidden},
+		{name: "editor", user: &database.UserModel{Id: 1, Role: auth.RoleEditor}, expected: http.StatusOK},
+		{name: "admin", user: &database.UserModel{Id: 1, Role: auth.RoleAdmin}, expected: http.StatusOK},
+		{name: "unknown role", user: &database.UserModel{Id: 1, Role: "user"}, expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/short", nil)
+			if tt.user != nil {
+				req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d; got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDailyRequestLimit(t *testing.T) {
+	defer func(limit int64) { apiKeyRequestsPerDay = limit }(apiKeyRequestsPerDay)
+	apiKeyRequestsPerDay = 100
+
+	tests := []struct {
+		name        string
+		usage       *database.ApiKeyUsageModel
+		err         error
+		maintenance bool
+		expected    int
+	}{
+		{name: "within limit", usage: &database.ApiKeyUsageModel{Requests: 100}, expected: http.StatusOK},
+		{name: "over limit", usage: &database.ApiKeyUsageModel{Requests: 101}, expected: http.StatusTooManyRequests},
+		{name: "usage not counted", err: errors.New("boom"), expected: http.StatusOK},
+		{name: "maintenance", usage: &database.ApiKeyUsageModel{Requests: 101}, maintenance: true, expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mocks.Service{
+				GetApiKeyByHashFunc: func(keyHash string) (*database.ApiKeyModel, error) {
+					return &database.ApiKeyModel{Id: 3, UserId: 1, User: &database.UserModel{Id: 1, Role: auth.RoleEditor}}, nil
+				},
+				AddApiKeyUsageFunc: func(apiKeyId int, requests int, linksCreated int) (*database.ApiKeyUsageModel, error) {
+					return tt.usage, tt.err
+				},
+			}
+			s := &Server{db: db}
+			handler := s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			maintenance.Set(tt.maintenance, "")
+			defer maintenance.Set(false, "")
+
+			req := httptest.NewRequest(http.MethodGet, "/short/summary", nil)
+			req.Header.Set("Authorization", "Bearer us_key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d; got %d", tt.expected, rec.Code)
+			}
+
+			// Nothing is written during a maintenance
+			expectedCalls := 1
+			if tt.maintenance {
+				expectedCalls = 0
+			}
+			if calls := db.CallsTo("AddApiKeyUsage"); len(calls) != expectedCalls {
+				t.Errorf("expected the request to be counted %d times; got %d", expectedCalls, len(calls))
+			}
+		})
+	}
+}
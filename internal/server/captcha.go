@@ -0,0 +1,59 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/captcha"
+	"url-shortner/internal/metrics"
+)
+
+// Header carrying the CAPTCHA answer of an anonymous visitor, the token the widget hands out once solved
+const captchaHeader = "X-Captcha-Token"
+
+// requireCaptcha asks anonymous requests for a CAPTCHA answer when CAPTCHA_PROVIDER is set, so bots can't create
+// links on public instances. Users and the ADMIN_TOKEN are never asked. A provider that can't be reached refuses the
+// request, unless CAPTCHA_FAIL_OPEN is set
+func (s *Server) requireCaptcha(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.captcha == nil || auth.UserFromContext(r.Context()) != nil || isAdminToken(bearerToken(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := s.captcha.Verify(r.Context(), r.Header.Get(captchaHeader), clientIP(r))
+		switch {
+		case err == nil:
+		case errors.Is(err, captcha.ErrFailed):
+			log.Printf("[captcha:requireCaptcha] Refused anonymous request: %v", err)
+			metrics.Count("captcha.failures", 1, "provider:"+s.captcha.Provider)
+			writeError(w, http.StatusForbidden, "Solve the CAPTCHA and send its token as "+captchaHeader+", or sign in.")
+			return
+		case s.captcha.FailOpen:
+			log.Printf("[captcha:requireCaptcha] Could not verify, letting the request through: %v", err)
+		default:
+			log.Printf("[captcha:requireCaptcha] Could not verify: %v", err)
+			writeError(w, http.StatusServiceUnavailable, "Could not check the CAPTCHA. Try again later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// captchaConfigHandler tells the forms of anonymous visitors which CAPTCHA widget to show, if any
+func (s *Server) captchaConfigHandler(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Status   int    `json:"status"`
+		Enabled  bool   `json:"enabled"`
+		Provider string `json:"provider,omitempty"`
+		SiteKey  string `json:"site_key,omitempty"`
+	}{Status: http.StatusOK}
+	if s.captcha != nil {
+		response.Enabled, response.Provider, response.SiteKey = true, s.captcha.Provider, s.captcha.SiteKey
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/captcha"
+	"url-shortner/internal/database"
+)
+
+func TestRequireCaptcha(t *testing.T) {
+	adminToken = "secret-admin-token"
+	defer func() { adminToken = "" }()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("response") == "solved" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false}`))
+	}))
+	defer provider.Close()
+
+	tests := []struct {
+		name     string
+		answer   string
+		user     bool
+		token    string
+		expected int
+	}{
+		{name: "anonymous solved", answer: "solved", expected: http.StatusOK},
+		{name: "anonymous unsolved", expected: http.StatusForbidden},
+		{name: "anonymous wrong", answer: "wrong", expected: http.StatusForbidden},
+		{name: "user", user: true, expected: http.StatusOK},
+		{name: "admin token", token: "secret-admin-token", expected: http.StatusOK},
+	}
+
+	t.Setenv("CAPTCHA_PROVIDER", captcha.HCaptcha)
+	t.Setenv("CAPTCHA_VERIFY_URL", provider.URL)
+	s := &Server{captcha: captcha.New()}
+	handler := s.requireCaptcha(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/short", nil)
+		if tt.answer != "" {
+			req.Header.Set(captchaHeader, tt.answer)
+		}
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if tt.user {
+			req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 1}))
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expected, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortner/internal/capture"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// captureExchanges keeps a sanitized copy of each request and its response in s.capture, when DEBUG_CAPTURE is
+// set. Bodies are copied as they are read and written, the handlers see them unchanged
+func (s *Server) captureExchanges(next http.Handler) http.Handler {
+	if s.capture == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Looking at the captures would capture them again
+		if strings.HasPrefix(r.URL.Path, "/api/v1/admin/debug/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		exchange := capture.Exchange{
+			Time:           start,
+			Method:         r.Method,
+			URL:            r.URL.String(),
+			RemoteAddr:     clientIP(r),
+			RequestHeaders: capture.Headers(r.Header),
+		}
+
+		requestBody := &cappedBuffer{max: s.capture.BodyBytes}
+		if r.Body != nil {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, requestBody), r.Body}
+		}
+
+		responseBody := &cappedBuffer{max: s.capture.BodyBytes}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(responseBody)
+		next.ServeHTTP(ww, r)
+
+		exchange.RequestBody = requestBody.String()
+		exchange.Status = ww.Status()
+		if exchange.Status == 0 {
+			exchange.Status = http.StatusOK
+		}
+		exchange.ResponseHeaders = capture.Headers(ww.Header())
+		exchange.ResponseBody = responseBody.String()
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			exchange.Route = rctx.RoutePattern()
+		}
+		exchange.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+
+		s.capture.Add(exchange)
+	})
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func (s *Server) adminListCapturesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.capture == nil {
+		writeError(w, http.StatusNotFound, "Debug capture is disabled, set DEBUG_CAPTURE to enable it.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status    int                `json:"status"`
+		Exchanges []capture.Exchange `json:"exchanges"`
+	}{
+		Status:    http.StatusOK,
+		Exchanges: s.capture.List(),
+	})
+}
+
+func (s *Server) adminClearCapturesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.capture == nil {
+		writeError(w, http.StatusNotFound, "Debug capture is disabled, set DEBUG_CAPTURE to enable it.")
+		return
+	}
+
+	s.capture.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortner/internal/capture"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestCaptureExchanges(t *testing.T) {
+	s := &Server{capture: capture.NewRing(10, 16)}
+	router := chi.NewRouter()
+	router.Use(s.captureExchanges)
+	router.Post("/api/v1/orgs/invitations/{token}/accept", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+		w.Write(body)
+	})
+	handler := http.Handler(router)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/invitations/inv_secret/accept", strings.NewReader(`{"code":"abc"}`))
+	req.Header.Set("Authorization", "Bearer usk_secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Body.String() != `{"code":"abc"}{"code":"abc"}` {
+		t.Errorf("expected the handler to see the whole body; got %q", rec.Body.String())
+	}
+
+	exchanges := s.capture.List()
+	if len(exchanges) != 1 {
+		t.Fatalf("expected an exchange captured; got %d", len(exchanges))
+	}
+	exchange := exchanges[0]
+	if exchange.Status != http.StatusCreated || exchange.RequestBody != `{"code":"abc"}` {
+		t.Errorf("expected the request body and the status; got %+v", exchange)
+	}
+	// Cut past 16 bytes, the json can't be parsed anymore
+	if exchange.ResponseBody != "[unparseable json, not kept]" {
+		t.Errorf("expected the truncated response dropped; got %q", exchange.ResponseBody)
+	}
+	if exchange.URL != "/api/v1/orgs/invitations/%5BREDACTED%5D/accept" {
+		t.Errorf("expected the invitation token redacted from the url; got %s", exchange.URL)
+	}
+	if exchange.RequestHeaders["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected the authorization redacted; got %q", exchange.RequestHeaders["Authorization"])
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+// claimLinkHandler attaches a link created anonymously to the account of the request, with the claim token
+// answered when it was created
+func (s *Server) claimLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		ClaimToken string `json:"claim_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.ClaimToken == "" {
+		writeError(w, http.StatusBadRequest, "Send the claim_token answered when the link was created.")
+		return
+	}
+
+	entity, err := s.shortener.Claim(reqBody.ClaimToken, creatorOf(r))
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Unknown or already used claim token.")
+		return
+	}
+	if err != nil {
+		log.Printf("[claims:claimLinkHandler] Could not claim link: %v", err)
+		writeShortenError(w, err)
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	s.audit(r, "link.claim", "link", entity.ShortCode, nil, map[string]int{"owner_id": user.Id})
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int          `json:"status"`
+		Link   linkResponse `json:"link"`
+	}{
+		Status: http.StatusOK,
+		Link:   toLinkResponse(entity),
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/shortener"
+)
+
+func TestClaimLinkHandler(t *testing.T) {
+	db := &mocks.Service{
+		ClaimLinkFunc: func(tokenHash string, userId int) (*database.ShortUrlModel, error) {
+			if tokenHash != auth.HashToken("secret") {
+				return nil, database.ErrNotFound
+			}
+			return &database.ShortUrlModel{Id: 1, ShortCode: "abc", OwnerId: &userId}, nil
+		},
+	}
+	s := &Server{db: db, shortener: shortener.New(db, nil)}
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{name: "claimed", body: `{"claim_token": "secret"}`, expected: http.StatusOK},
+		{name: "unknown", body: `{"claim_token": "guess"}`, expected: http.StatusNotFound},
+		{name: "missing", body: `{}`, expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/me/links/claim", strings.NewReader(tt.body))
+		req = req.WithContext(auth.WithUser(context.Background(), &database.UserModel{Id: 7, EmailVerifiedAt: &verifiedAt}))
+		rec := httptest.NewRecorder()
+		s.claimLinkHandler(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expected, rec.Code, rec.Body)
+		}
+		if tt.expected == http.StatusOK && !strings.Contains(rec.Body.String(), `"owner_id":7`) {
+			t.Errorf("%s: expected the link owned by the user; got %s", tt.name, rec.Body)
+		}
+	}
+
+	if claims := db.CallsTo("ClaimLink"); len(claims) != 2 || claims[0].Args[1] != 7 {
+		t.Errorf("expected the links claimed for the user; got %+v", claims)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortner/internal/database"
+)
+
+// writeJSONWithETag writes body like writeJSON with a 200, tagged with a weak ETag of its content. A request whose
+// If-None-Match holds the tag gets a 304 without the body, so dashboards polling many links only download what
+// changed. The tag is weak since the same content may be encoded differently by another version of the api
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, body any) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not encode the response.")
+		return
+	}
+	encoded = append(encoded, '\n')
+
+	sum := sha256.Sum256(encoded)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	// Polling clients must come back with the tag rather than keep their copy
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+// etagMatches reports whether the If-None-Match header holds etag, "*" matching any. Tags are compared weakly, as
+// RFC 9110 requires for If-None-Match, so W/"x" matches "x"
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// listingNotModified sets the Last-Modified of a listing of links, the newest change of the links listed or the
+// last deletion of a link, which may have moved another one into the listing. It answers 304 and reports true when
+// If-Modified-Since isn't older, the listing is then left unwritten. Dates only have a precision of a second
+func (s *Server) listingNotModified(w http.ResponseWriter, r *http.Request, entities []*database.ShortUrlModel) bool {
+	lastModified, err := s.db.LastShortUrlDeletion()
+	if err != nil {
+		return false
+	}
+	for _, entity := range entities {
+		if entity.UpdatedAt.After(lastModified) {
+			lastModified = entity.UpdatedAt
+		}
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestWriteJSONWithETag(t *testing.T) {
+	body := map[string]int{"total_clicks": 3}
+
+	rec := httptest.NewRecorder()
+	writeJSONWithETag(rec, httptest.NewRequest(http.MethodGet, "/short/abc/stats", nil), body)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Body.String() != "{\"total_clicks\":3}\n" {
+		t.Fatalf("expected the body with an ETag; got %d %q %q", rec.Code, etag, rec.Body.String())
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		expected    int
+	}{
+		{"same tag", etag, http.StatusNotModified},
+		{"strong form of the tag", etag[2:], http.StatusNotModified},
+		{"one of several", `W/"other", ` + etag, http.StatusNotModified},
+		{"any", "*", http.StatusNotModified},
+		{"other tag", `W/"other"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/short/abc/stats", nil)
+		req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		rec := httptest.NewRecorder()
+		writeJSONWithETag(rec, req, body)
+
+		if rec.Code != tt.expected || rec.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected %d tagged %s; got %d tagged %s", tt.name, tt.expected, etag, rec.Code, rec.Header().Get("ETag"))
+		}
+		if tt.expected == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: expected no body; got %q", tt.name, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	writeJSONWithETag(rec, httptest.NewRequest(http.MethodGet, "/short/abc/stats", nil), map[string]int{"total_clicks": 4})
+	if rec.Header().Get("ETag") == etag {
+		t.Errorf("expected another tag once the content changed")
+	}
+}
+
+func TestListingNotModified(t *testing.T) {
+	deletedAt := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2025, 6, 2, 10, 0, 0, 500, time.UTC)
+	s := &Server{db: &mocks.Service{
+		LastShortUrlDeletionFunc: func() (time.Time, error) { return deletedAt, nil },
+	}}
+	entities := []*database.ShortUrlModel{{UpdatedAt: updatedAt}, {UpdatedAt: updatedAt.Add(-time.Hour)}}
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		expected        bool
+	}{
+		{"no condition", "", false},
+		{"unchanged", updatedAt.Format(http.TimeFormat), true},
+		{"changed since", updatedAt.Add(-time.Second).Format(http.TimeFormat), false},
+		{"invalid date", "yesterday", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/links", nil)
+		if tt.ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+		}
+		rec := httptest.NewRecorder()
+
+		if notModified := s.listingNotModified(rec, req, entities); notModified != tt.expected {
+			t.Errorf("%s: expected not modified %t; got %t", tt.name, tt.expected, notModified)
+		}
+		if lastModified := rec.Header().Get("Last-Modified"); lastModified != updatedAt.Format(http.TimeFormat) {
+			t.Errorf("%s: expected the newest change as Last-Modified; got %q", tt.name, lastModified)
+		}
+	}
+
+	// An empty listing changes with the deletions only
+	rec := httptest.NewRecorder()
+	s.listingNotModified(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/links", nil), nil)
+	if lastModified := rec.Header().Get("Last-Modified"); lastModified != deletedAt.Format(http.TimeFormat) {
+		t.Errorf("expected the last deletion as Last-Modified; got %q", lastModified)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/metrics"
+)
+
+// A cron job is late once its last run finished this many intervals ago, unless CRON_LATE_FACTOR says otherwise
+const defaultCronLateFactor = 3
+
+var cronLateFactor = cronLateFactorFromEnv()
+
+func cronLateFactorFromEnv() int {
+	factor, err := strconv.Atoi(os.Getenv("CRON_LATE_FACTOR"))
+	if err != nil || factor <= 0 {
+		return defaultCronLateFactor
+	}
+	return factor
+}
+
+type jobStatusResponse struct {
+	Name           string     `json:"name"`
+	IntervalSecs   int        `json:"interval_seconds"`
+	LastStartedAt  *time.Time `json:"last_started_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastFailedAt   *time.Time `json:"last_failed_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	Running        bool       `json:"running"`
+	Late           bool       `json:"late"`
+	RunRequestedAt *time.Time `json:"run_requested_at,omitempty"`
+}
+
+func toJobStatusResponse(entity *database.JobStatusModel, now time.Time) jobStatusResponse {
+	return jobStatusResponse{
+		Name:           entity.Name,
+		IntervalSecs:   int(entity.Interval.Seconds()),
+		LastStartedAt:  entity.LastStartedAt,
+		LastFinishedAt: entity.LastFinishedAt,
+		LastFailedAt:   entity.LastFailedAt,
+		LastError:      entity.LastError,
+		Running:        entity.Running(),
+		Late:           entity.Late(now, cronLateFactor),
+		RunRequestedAt: entity.RunRequestedAt,
+	}
+}
+
+func (s *Server) adminListCronJobsHandler(w http.ResponseWriter, r *http.Request) {
+	entities, err := s.db.ListJobStatuses()
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list the cron jobs.")
+		return
+	}
+
+	now := time.Now()
+	jobs := make([]jobStatusResponse, 0, len(entities))
+	for _, entity := range entities {
+		jobs = append(jobs, toJobStatusResponse(entity, now))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int                 `json:"status"`
+		Jobs   []jobStatusResponse `json:"jobs"`
+	}{
+		Status: http.StatusOK,
+		Jobs:   jobs,
+	})
+}
+
+// adminRunCronJobHandler asks the cronjobs to run a job at once, outside its schedule. They pick the request up within
+// seconds, and the run shows in the job status as a scheduled one would
+func (s *Server) adminRunCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	entity, err := s.db.RequestJobRun(name)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Cron job not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not request the run.")
+		return
+	}
+
+	s.audit(r, "cron.run_request", "cron_job", name, nil, nil)
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status int               `json:"status"`
+		Job    jobStatusResponse `json:"job"`
+	}{
+		Status: http.StatusAccepted,
+		Job:    toJobStatusResponse(entity, time.Now()),
+	})
+}
+
+// cronHealth reports the cron jobs as degraded when one of them is late, naming it. It returns nil before the
+// cronjobs ran anything. The number of late jobs is sent as the cron.late_jobs gauge
+func (s *Server) cronHealth() *componentHealthResponse {
+	entities, err := s.db.ListJobStatuses()
+	if err != nil || len(entities) == 0 {
+		return nil
+	}
+
+	component := &componentHealthResponse{Status: database.HealthUp, Details: map[string]string{}}
+	late := 0
+	now := time.Now()
+	for _, entity := range entities {
+		if !entity.Late(now, cronLateFactor) {
+			component.Details[entity.Name] = "ok"
+			continue
+		}
+
+		late++
+		component.Status = database.HealthDegraded
+		switch {
+		case entity.LastError != "":
+			component.Details[entity.Name] = "failing: " + entity.LastError
+		case entity.LastFinishedAt == nil:
+			component.Details[entity.Name] = "never finished"
+		default:
+			component.Details[entity.Name] = "late, last finished " + entity.LastFinishedAt.UTC().Format(time.RFC3339)
+		}
+	}
+
+	metrics.Gauge("cron.late_jobs", float64(late))
+	return component
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestCronHealth(t *testing.T) {
+	now := time.Now()
+	at := func(ago time.Duration) *time.Time {
+		t := now.Add(-ago)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		status   *database.JobStatusModel
+		expected string
+	}{
+		{"on time", &database.JobStatusModel{Interval: time.Minute, LastStartedAt: at(30 * time.Second), LastFinishedAt: at(29 * time.Second)}, "ok"},
+		{"running on time", &database.JobStatusModel{Interval: time.Minute, LastStartedAt: at(10 * time.Second), LastFinishedAt: at(70 * time.Second)}, "ok"},
+		{"wedged", &database.JobStatusModel{Interval: time.Minute, LastStartedAt: at(4 * time.Minute), LastFinishedAt: at(5 * time.Minute)}, "late, last finished " + at(5*time.Minute).UTC().Format(time.RFC3339)},
+		{"never finished", &database.JobStatusModel{Interval: time.Minute, LastStartedAt: at(10 * time.Minute)}, "never finished"},
+		{"failing", &database.JobStatusModel{Interval: time.Minute, LastStartedAt: at(30 * time.Second), LastFinishedAt: at(5 * time.Minute), LastFailedAt: at(29 * time.Second), LastError: "connection refused"}, "failing: connection refused"},
+	}
+
+	for _, tt := range tests {
+		tt.status.Name = "delete_expired_links"
+		s := &Server{db: &mocks.Service{
+			ListJobStatusesFunc: func() ([]*database.JobStatusModel, error) {
+				return []*database.JobStatusModel{tt.status}, nil
+			},
+		}}
+
+		component := s.cronHealth()
+		if detail := component.Details["delete_expired_links"]; detail != tt.expected {
+			t.Errorf("%s: expected %q; got %q", tt.name, tt.expected, detail)
+		}
+		if late := tt.expected != "ok"; late != (component.Status == database.HealthDegraded) {
+			t.Errorf("%s: expected degraded to be %t; got status %s", tt.name, late, component.Status)
+		}
+	}
+
+	if component := (&Server{db: &mocks.Service{}}).cronHealth(); component != nil {
+		t.Errorf("expected no component before any run; got %+v", component)
+	}
+}
+
+func TestAdminRunCronJob(t *testing.T) {
+	tests := []struct {
+		name         string
+		job          string
+		expectedCode int
+	}{
+		{"scheduled", "refresh_stats_views", http.StatusAccepted},
+		{"never ran", "purge_everything", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			RequestJobRunFunc: func(name string) (*database.JobStatusModel, error) {
+				if name != "refresh_stats_views" {
+					return nil, database.ErrNotFound
+				}
+				now := time.Now()
+				return &database.JobStatusModel{Name: name, Interval: 5 * time.Minute, RunRequestedAt: &now}, nil
+			},
+		}
+		s := &Server{db: db}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/cron/"+tt.job+"/run", nil)
+		req.SetPathValue("name", tt.job)
+		rec := httptest.NewRecorder()
+		s.adminRunCronJobHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+		if audited := len(db.CallsTo("RecordAudit")) == 1; audited != (tt.expectedCode == http.StatusAccepted) {
+			t.Errorf("%s: expected the request audited only when accepted; got %t", tt.name, audited)
+		}
+	}
+}
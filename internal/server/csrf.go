@@ -0,0 +1,68 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"url-shortner/internal/auth"
+)
+
+// Header carrying the CSRF token of the session on the requests changing something. It is left out of the allowed
+// CORS headers on purpose: only pages of the api origin, the dashboard, may send it
+const csrfHeader = "X-CSRF-Token"
+
+// csrfToken derives the CSRF token of a session from the token of its cookie. Other sites can neither read the cookie
+// nor the token, and the token needs no storage of its own: it lives and dies with the session
+func csrfToken(sessionToken string) string {
+	return auth.HashToken("csrf:" + sessionToken)
+}
+
+// safeMethod reports whether requests with method change nothing, and so need no CSRF token
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// requireCSRF refuses the requests authenticated by a session cookie which change something without the CSRF token
+// of the session in X-CSRF-Token. Requests authenticated by a bearer token, api keys and the ADMIN_TOKEN, are exempt:
+// browsers never send those on their own
+func requireCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if safeMethod(r.Method) || auth.SessionFromContext(r.Context()) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookieName)
+		sent := r.Header.Get(csrfHeader)
+		if err != nil || sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(csrfToken(cookie.Value))) != 1 {
+			writeError(w, http.StatusForbidden, "Missing or invalid CSRF token, send the one of the session as "+csrfHeader+".")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfTokenHandler answers the CSRF token of the session of the request, e.g. for a dashboard reloaded after its
+// sign in
+func (s *Server) csrfTokenHandler(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || auth.SessionFromContext(r.Context()) == nil {
+		writeError(w, http.StatusUnauthorized, "Sign in with a session first.")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, struct {
+		Status    int    `json:"status"`
+		CsrfToken string `json:"csrf_token"`
+	}{
+		Status:    http.StatusOK,
+		CsrfToken: csrfToken(cookie.Value),
+	})
+}
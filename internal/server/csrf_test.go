@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+func TestRequireCSRF(t *testing.T) {
+	handler := requireCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		session  bool
+		token    string
+		expected int
+	}{
+		{name: "read with a session", method: http.MethodGet, session: true, expected: http.StatusOK},
+		{name: "write with a session and its token", method: http.MethodPost, session: true, token: csrfToken("cookie-token"), expected: http.StatusOK},
+		{name: "write with a session without token", method: http.MethodPost, session: true, expected: http.StatusForbidden},
+		{name: "write with a session and another token", method: http.MethodDelete, session: true, token: csrfToken("other-token"), expected: http.StatusForbidden},
+		{name: "write with an api key", method: http.MethodPost, expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/short", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "cookie-token"})
+		if tt.session {
+			req = req.WithContext(auth.WithSession(req.Context(), &database.SessionModel{Id: 1}))
+		} else {
+			req.Header.Set("Authorization", "Bearer us_key")
+			req = req.WithContext(auth.WithApiKey(req.Context(), &database.ApiKeyModel{Id: 1}))
+		}
+		if tt.token != "" {
+			req.Header.Set(csrfHeader, tt.token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expected, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/idn"
+	"url-shortner/internal/policy"
+)
+
+// When set, only destinations matching one of these patterns may be shortened
+var allowedDestinations = policy.ParsePatterns(os.Getenv("DESTINATION_ALLOWLIST"))
+
+// Phishing heuristics thresholds: destinations scoring at least the reject score are refused,
+// the ones scoring at least the review score are created disabled and queued for an admin
+var (
+	phishingRejectScore = envInt("PHISHING_REJECT_SCORE", policy.DefaultRejectScore)
+	phishingReviewScore = envInt("PHISHING_REVIEW_SCORE", policy.DefaultReviewScore)
+)
+
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// destinationRefusal is why a destination can't be shortened, answered with its status
+type destinationRefusal struct {
+	status  int
+	message string
+}
+
+// checkDestination enforces the destination policies on a link about to be shortened.
+// It writes the error response and returns false when the link must be refused,
+// otherwise it returns the phishing assessment of the link.
+func (s *Server) checkDestination(w http.ResponseWriter, r *http.Request, link string) (policy.Assessment, bool) {
+	assessment, refusal := s.vetDestination(r.Context(), requestHost(r), link)
+	if refusal != nil {
+		writeError(w, refusal.status, refusal.message)
+		return assessment, false
+	}
+
+	return assessment, true
+}
+
+// vetDestination enforces the destination policies on a link about to be shortened, see checkDestination.
+// host is the host the instance was reached on. It returns why the link must be refused, if it must
+func (s *Server) vetDestination(ctx context.Context, host string, link string) (policy.Assessment, *destinationRefusal) {
+	// A short link to this instance redirects back to it, possibly to itself forever
+	if s.ownHost(host, policy.HostOf(link)) {
+		log.Printf("[destination:vetDestination] Refused link {%s} pointing back at this instance", link)
+		return policy.Assessment{}, &destinationRefusal{http.StatusBadRequest, "Links can't point back at this instance, they would redirect in a loop."}
+	}
+
+	if len(allowedDestinations) > 0 {
+		if _, allowed := policy.FirstMatch(link, allowedDestinations); !allowed {
+			log.Printf("[destination:vetDestination] Refused link {%s} outside of the allowlist", link)
+			return policy.Assessment{}, &destinationRefusal{http.StatusForbidden, "Only destinations on the allowlist can be shortened."}
+		}
+	}
+
+	bannedDomains, err := s.db.ListBannedDomains()
+	if err != nil {
+		return policy.Assessment{}, &destinationRefusal{http.StatusInternalServerError, "Something went wrong with generating short url. Try again later"}
+	}
+
+	patterns := make([]string, 0, len(bannedDomains))
+	for _, bannedDomain := range bannedDomains {
+		patterns = append(patterns, bannedDomain.Pattern)
+	}
+
+	if pattern, banned := policy.FirstMatch(link, patterns); banned {
+		log.Printf("[destination:vetDestination] Refused link {%s} matching banned pattern {%s}", link, pattern)
+		return policy.Assessment{}, &destinationRefusal{http.StatusForbidden, "The destination domain is not allowed."}
+	}
+
+	if s.safeBrowsing != nil {
+		flagged, err := s.safeBrowsing.Check(ctx, []string{link})
+		if err != nil {
+			log.Printf("[destination:vetDestination] Safe Browsing lookup failed for {%s}: %v", link, err)
+			if s.safeBrowsing.FailClosed {
+				return policy.Assessment{}, &destinationRefusal{http.StatusServiceUnavailable, "Could not verify the destination. Try again later"}
+			}
+		}
+
+		if threatType, ok := flagged[link]; ok {
+			log.Printf("[destination:vetDestination] Refused link {%s} flagged as {%s}", link, threatType)
+			return policy.Assessment{}, &destinationRefusal{http.StatusForbidden, "The destination has been flagged as unsafe."}
+		}
+	}
+
+	assessment := policy.Assess(link)
+	if assessment.Score >= phishingRejectScore {
+		log.Printf("[destination:vetDestination] Refused link {%s} with phishing score {%d}: %v", link, assessment.Score, assessment.Reasons)
+		return assessment, &destinationRefusal{http.StatusForbidden, "The destination looks suspicious and was refused."}
+	}
+
+	return assessment, nil
+}
+
+// ownHost reports whether linkHost is served by this instance: it is host, the host the instance was reached on, or
+// one of the verified domains
+func (s *Server) ownHost(host string, linkHost string) bool {
+	if linkHost == "" {
+		return false
+	}
+	if linkHost == host {
+		return true
+	}
+
+	domain, err := s.domainOf(linkHost)
+	if err != nil {
+		log.Printf("[destination:ownHost] Could not look up domain {%s}: %v", linkHost, err)
+		return false
+	}
+	return domain != nil
+}
+
+// reviewOf returns the review to queue along with a link to a destination scoring at least phishingReviewScore, which
+// is then saved disabled in the same transaction. It is nil for the other destinations
+func reviewOf(assessment policy.Assessment) *database.ReviewModel {
+	if assessment.Score < phishingReviewScore {
+		return nil
+	}
+	return &database.ReviewModel{
+		Score:   assessment.Score,
+		Reasons: strings.Join(assessment.Reasons, ", "),
+	}
+}
+
+// linkWarnings explains why the domain of a link may impersonate another one, see idn.Warnings
+func linkWarnings(link string) []string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return nil
+	}
+	return idn.Warnings(parsed.Hostname())
+}
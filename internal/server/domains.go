@@ -0,0 +1,565 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/dnsverify"
+	"url-shortner/internal/i18n"
+	"url-shortner/internal/pages"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/policy"
+)
+
+type domainResponse struct {
+	Id             int              `json:"id"`
+	Hostname       string           `json:"hostname"`
+	OrganizationId *int             `json:"organization_id,omitempty"`
+	Verified       bool             `json:"verified"`
+	VerifiedAt     *time.Time       `json:"verified_at,omitempty"`
+	CheckedAt      *time.Time       `json:"checked_at,omitempty"`
+	Branding       brandingResponse `json:"branding"`
+	CreatedAt      time.Time        `json:"created_at"`
+
+	// TXT record to create, until the domain is verified
+	Verification *verificationResponse `json:"verification,omitempty"`
+}
+
+type brandingResponse struct {
+	LogoUrl         string `json:"logo_url"`
+	PrimaryColor    string `json:"primary_color"`
+	BackgroundColor string `json:"background_color"`
+	FooterText      string `json:"footer_text"`
+}
+
+type verificationResponse struct {
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Deadline time.Time `json:"deadline"`
+}
+
+func toDomainResponse(entity *database.DomainModel) domainResponse {
+	response := domainResponse{
+		Id:             entity.Id,
+		Hostname:       entity.Hostname,
+		OrganizationId: entity.OrganizationId,
+		Verified:       entity.VerifiedAt != nil,
+		VerifiedAt:     entity.VerifiedAt,
+		CheckedAt:      entity.CheckedAt,
+		Branding: brandingResponse{
+			LogoUrl:         entity.LogoUrl,
+			PrimaryColor:    entity.PrimaryColor,
+			BackgroundColor: entity.BackgroundColor,
+			FooterText:      entity.FooterText,
+		},
+		CreatedAt: entity.CreatedAt,
+	}
+
+	if entity.VerifiedAt == nil {
+		response.Verification = &verificationResponse{
+			Type:     "TXT",
+			Name:     dnsverify.RecordName(entity.Hostname),
+			Value:    dnsverify.RecordValue(entity.VerificationToken),
+			Deadline: entity.CreatedAt.Add(dnsverify.PendingTTL),
+		}
+	}
+
+	return response
+}
+
+// normalizeHostname lowercases a hostname, returning "" when it isn't one
+func normalizeHostname(hostname string) string {
+	hostname = policy.NormalizePattern(hostname)
+	if strings.ContainsAny(hostname, "/:?*@ ") || !strings.Contains(hostname, ".") {
+		return ""
+	}
+	return hostname
+}
+
+// requestHost is the lowercased host the request was sent to, without its port
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return policy.NormalizePattern(host)
+}
+
+// domainOf looks up the verified domain with the hostname. Unknown and unverified hostnames give a nil domain,
+// they are cached too so requests to other hosts don't query the database every time
+func (s *Server) domainOf(hostname string) (*database.DomainModel, error) {
+	if domain, ok := s.domainCache.Get(hostname); ok {
+		return domain, nil
+	}
+
+	domain, err := s.db.GetDomainByHostname(hostname)
+	if errors.Is(err, database.ErrNotFound) {
+		domain, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if domain != nil && domain.VerifiedAt == nil {
+		domain = nil
+	}
+
+	s.domainCache.Set(hostname, domain)
+
+	return domain, nil
+}
+
+// servedOn reports whether the link may be followed on the host of the request. Links without a domain are
+// served on every host
+func (s *Server) servedOn(entity *database.ShortUrlModel, r *http.Request) bool {
+	if entity.DomainId == nil {
+		return true
+	}
+
+	domain, err := s.domainOf(requestHost(r))
+	if err != nil {
+		// Not being able to tell shouldn't break the redirect
+		log.Printf("[domains:servedOn] Could not look up the domain of host {%s}: %v", r.Host, err)
+		return true
+	}
+
+	return domain != nil && domain.Id == *entity.DomainId
+}
+
+// shortUrlOf builds the short url of a code on host
+func shortUrlOf(r *http.Request, host string, shortCode string) string {
+	return baseUrlOf(r, host) + shortPath(shortCode)
+}
+
+// baseUrlOf returns the scheme and host of the short urls answered to the request
+func baseUrlOf(r *http.Request, host string) string {
+	if r.URL.Scheme != "" {
+		return "https://" + host
+	}
+	return "http://" + host
+}
+
+// shortPath returns the path of a short code. Unicode codes are percent-encoded, namespaced ones keep their slash
+func shortPath(shortCode string) string {
+	return (&url.URL{Path: "/short/" + shortCode}).EscapedPath()
+}
+
+// listDomainsHandler lists the domains of the instance, those every link can be pinned to
+func (s *Server) listDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	entities, err := s.db.ListDomains()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list domains.")
+		return
+	}
+
+	domains := make([]domainResponse, 0, len(entities))
+	for _, entity := range entities {
+		if entity.OrganizationId == nil && entity.VerifiedAt != nil {
+			domains = append(domains, toDomainResponse(entity))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int              `json:"status"`
+		Domains []domainResponse `json:"domains"`
+	}{
+		Status:  http.StatusOK,
+		Domains: domains,
+	})
+}
+
+func (s *Server) adminListDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	entities, err := s.db.ListDomains()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list domains.")
+		return
+	}
+
+	domains := make([]domainResponse, 0, len(entities))
+	for _, entity := range entities {
+		domains = append(domains, toDomainResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int              `json:"status"`
+		Domains []domainResponse `json:"domains"`
+	}{
+		Status:  http.StatusOK,
+		Domains: domains,
+	})
+}
+
+func (s *Server) adminCreateDomainHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Hostname string `json:"hostname"`
+	}
+
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	hostname := normalizeHostname(reqBody.Hostname)
+	if hostname == "" {
+		writeError(w, http.StatusBadRequest, "A hostname (e.g. 'go.example.com') is required.")
+		return
+	}
+
+	// The domains of the instance are trusted, there is nothing to verify
+	now := time.Now()
+	entity, err := s.db.SaveDomain(&database.DomainModel{Hostname: hostname, VerifiedAt: &now})
+	if writeConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not add domain.")
+		return
+	}
+
+	// The hostname may have been cached as unknown
+	s.domainCache.Delete(entity.Hostname)
+
+	log.Printf("[domains:adminCreateDomainHandler] Added domain: {%s}", entity.Hostname)
+	s.audit(r, "domain.create", "domain", entity.Id, nil, toDomainResponse(entity))
+
+	writeJSON(w, http.StatusCreated, struct {
+		Status int            `json:"status"`
+		Domain domainResponse `json:"domain"`
+	}{
+		Status: http.StatusCreated,
+		Domain: toDomainResponse(entity),
+	})
+}
+
+func (s *Server) adminDeleteDomainHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := strconv.Atoi(r.PathValue("domain_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid domain id.")
+		return
+	}
+
+	err = s.db.DeleteDomain(domainId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Domain not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not delete domain.")
+		return
+	}
+
+	s.audit(r, "domain.delete", "domain", domainId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// organizationDomain loads the domain of the path, answering 404 when it isn't one of the organization's
+func (s *Server) organizationDomain(w http.ResponseWriter, r *http.Request, organizationId int) (*database.DomainModel, bool) {
+	domainId, err := strconv.Atoi(r.PathValue("domain_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid domain id.")
+		return nil, false
+	}
+
+	entity, err := s.db.GetDomain(domainId)
+	if err == nil && (entity.OrganizationId == nil || *entity.OrganizationId != organizationId) {
+		err = database.ErrNotFound
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Domain not found.")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the domain.")
+		return nil, false
+	}
+
+	return entity, true
+}
+
+func (s *Server) listOrganizationDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	entities, err := s.db.ListOrganizationDomains(member.OrganizationId)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list domains.")
+		return
+	}
+
+	domains := make([]domainResponse, 0, len(entities))
+	for _, entity := range entities {
+		domains = append(domains, toDomainResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int              `json:"status"`
+		Domains []domainResponse `json:"domains"`
+	}{
+		Status:  http.StatusOK,
+		Domains: domains,
+	})
+}
+
+// createOrganizationDomainHandler claims a custom domain for the organization. Links are only served on it once
+// the TXT record of the response is found, see verifyDomains in the cronjobs
+func (s *Server) createOrganizationDomainHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	organization, err := s.db.GetOrganization(member.OrganizationId)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the organization.")
+		return
+	}
+	if !plans.Get(organization.Plan).CustomDomains {
+		writeError(w, http.StatusForbidden, "Custom domains aren't part of the "+organization.Plan+" plan.")
+		return
+	}
+
+	var reqBody struct {
+		Hostname string `json:"hostname"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	hostname := normalizeHostname(reqBody.Hostname)
+	if hostname == "" {
+		writeError(w, http.StatusBadRequest, "A hostname (e.g. 'go.example.com') is required.")
+		return
+	}
+
+	token, err := dnsverify.NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not add domain.")
+		return
+	}
+
+	entity, err := s.db.SaveDomain(&database.DomainModel{
+		Hostname:          hostname,
+		OrganizationId:    &member.OrganizationId,
+		VerificationToken: token,
+	})
+	if writeConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not add domain.")
+		return
+	}
+
+	s.audit(r, "organization.domain_create", "domain", entity.Id, nil, toDomainResponse(entity))
+
+	writeJSON(w, http.StatusCreated, struct {
+		Status int            `json:"status"`
+		Domain domainResponse `json:"domain"`
+	}{
+		Status: http.StatusCreated,
+		Domain: toDomainResponse(entity),
+	})
+}
+
+// verifyOrganizationDomainHandler looks up the TXT record of a domain right away instead of waiting for the cronjob
+func (s *Server) verifyOrganizationDomainHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	entity, ok := s.organizationDomain(w, r, member.OrganizationId)
+	if !ok {
+		return
+	}
+
+	if entity.VerifiedAt == nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		err := dnsverify.Check(ctx, s.resolver, entity.Hostname, entity.VerificationToken)
+		cancel()
+		if err != nil && !errors.Is(err, dnsverify.ErrNotVerified) {
+			log.Printf("[domains:verifyOrganizationDomainHandler] Could not look up the record of {%s}: %v", entity.Hostname, err)
+			writeError(w, http.StatusBadGateway, "Could not look up the TXT record. Try again later")
+			return
+		}
+
+		entity, err = s.db.MarkDomainChecked(entity.Id, err == nil)
+		if err != nil {
+			writeError(w, statusOf(err), "Could not verify the domain.")
+			return
+		}
+
+		if entity.VerifiedAt != nil {
+			s.domainCache.Delete(entity.Hostname)
+			s.audit(r, "organization.domain_verify", "domain", entity.Id, nil, toDomainResponse(entity))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		Domain domainResponse `json:"domain"`
+	}{
+		Status: http.StatusOK,
+		Domain: toDomainResponse(entity),
+	})
+}
+
+func (s *Server) deleteOrganizationDomainHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	entity, ok := s.organizationDomain(w, r, member.OrganizationId)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteDomain(entity.Id); err != nil {
+		writeError(w, statusOf(err), "Could not delete domain.")
+		return
+	}
+
+	s.domainCache.Delete(entity.Hostname)
+	s.audit(r, "organization.domain_delete", "domain", entity.Id, toDomainResponse(entity), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pinnableBy reports whether links of the creator may be pinned to the domain: the domains of the instance take
+// any link, custom domains only the links of their organization
+func pinnableBy(domain *database.DomainModel, organizationId *int) bool {
+	return domain.OrganizationId == nil || (organizationId != nil && *organizationId == *domain.OrganizationId)
+}
+
+// updateBranding replaces the branding of the domain with the one of the request body
+func (s *Server) updateBranding(w http.ResponseWriter, r *http.Request, entity *database.DomainModel, action string) {
+	var reqBody brandingResponse
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	if reqBody.LogoUrl != "" {
+		logo, err := url.Parse(reqBody.LogoUrl)
+		if err != nil || (logo.Scheme != "https" && logo.Scheme != "http") || logo.Host == "" || len(reqBody.LogoUrl) > 2048 {
+			writeError(w, http.StatusBadRequest, "The logo must be an http(s) url of at most 2048 characters.")
+			return
+		}
+	}
+	for _, color := range []string{reqBody.PrimaryColor, reqBody.BackgroundColor} {
+		if color != "" && !pages.ValidColor(color) {
+			writeError(w, http.StatusBadRequest, "Colors must be hex colors like '#2563eb'.")
+			return
+		}
+	}
+	if utf8.RuneCountInString(reqBody.FooterText) > 200 {
+		writeError(w, http.StatusBadRequest, "The footer text can't be longer than 200 characters.")
+		return
+	}
+
+	updated, err := s.db.UpdateDomainBranding(&database.DomainModel{
+		Id:              entity.Id,
+		LogoUrl:         reqBody.LogoUrl,
+		PrimaryColor:    reqBody.PrimaryColor,
+		BackgroundColor: reqBody.BackgroundColor,
+		FooterText:      strings.TrimSpace(reqBody.FooterText),
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Domain not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the branding.")
+		return
+	}
+
+	s.domainCache.Delete(updated.Hostname)
+	s.audit(r, action, "domain", updated.Id, toDomainResponse(entity).Branding, toDomainResponse(updated).Branding)
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		Domain domainResponse `json:"domain"`
+	}{
+		Status: http.StatusOK,
+		Domain: toDomainResponse(updated),
+	})
+}
+
+func (s *Server) updateOrganizationDomainBrandingHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	entity, ok := s.organizationDomain(w, r, member.OrganizationId)
+	if !ok {
+		return
+	}
+
+	s.updateBranding(w, r, entity, "organization.domain_branding")
+}
+
+func (s *Server) adminUpdateDomainBrandingHandler(w http.ResponseWriter, r *http.Request) {
+	domainId, err := strconv.Atoi(r.PathValue("domain_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid domain id.")
+		return
+	}
+
+	entity, err := s.db.GetDomain(domainId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Domain not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the domain.")
+		return
+	}
+
+	s.updateBranding(w, r, entity, "domain.branding")
+}
+
+// brandingOf is the branding of the pages served on the host of the request
+func (s *Server) brandingOf(r *http.Request) pages.Branding {
+	domain, err := s.domainOf(requestHost(r))
+	if err != nil || domain == nil {
+		return pages.Branding{}
+	}
+
+	return pages.Branding{
+		LogoUrl:         domain.LogoUrl,
+		PrimaryColor:    domain.PrimaryColor,
+		BackgroundColor: domain.BackgroundColor,
+		FooterText:      domain.FooterText,
+	}
+}
+
+// writePageError answers browsers with an error page in the branding of the domain, and everyone else with the
+// standard JSON error. Both are in the language of the visitor, key is one of the i18n keys
+func (s *Server) writePageError(w http.ResponseWriter, r *http.Request, status int, key string) {
+	language := i18n.FromRequest(r)
+	w.Header().Set("Content-Language", language)
+	w.Header().Add("Vary", "Accept-Language")
+
+	if !pages.WantsHTML(r) {
+		writeError(w, status, i18n.Message(language, key+".message"))
+		return
+	}
+
+	err := pages.Render(w, pages.Page{
+		Status:    status,
+		Language:  language,
+		Direction: i18n.Direction(language),
+		Title:     i18n.Message(language, key+".title"),
+		Message:   i18n.Message(language, key+".message"),
+		Branding:  s.brandingOf(r),
+	})
+	if err != nil {
+		log.Printf("[domains:writePageError] Could not render the page: %v", err)
+	}
+}
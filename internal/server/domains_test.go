@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/i18n"
+	"url-shortner/internal/mocks"
+)
+
+type txtResolver struct {
+	records []string
+	err     error
+}
+
+func (f txtResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return f.records, f.err
+}
+
+func TestVerifyOrganizationDomainHandler(t *testing.T) {
+	organizationId, otherOrganizationId := 7, 8
+
+	tests := []struct {
+		name             string
+		domainOrg        int
+		resolver         txtResolver
+		expectedCode     int
+		expectedVerified bool
+	}{
+		{"record found", organizationId, txtResolver{records: []string{"url-shortner-verification=abc"}}, http.StatusOK, true},
+		{"record missing", organizationId, txtResolver{records: []string{"url-shortner-verification=xyz"}}, http.StatusOK, false},
+		{"lookup failure", organizationId, txtResolver{err: errors.New("i/o timeout")}, http.StatusBadGateway, false},
+		{"domain of another organization", otherOrganizationId, txtResolver{records: []string{"url-shortner-verification=abc"}}, http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		domain := &database.DomainModel{Id: 3, Hostname: "go.example.com", OrganizationId: &tt.domainOrg, VerificationToken: "abc", CreatedAt: time.Now()}
+		db := &mocks.Service{
+			GetMembershipFunc: func(organizationId, userId int) (*database.MemberModel, error) {
+				return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: database.OrgRoleAdmin}, nil
+			},
+			GetDomainFunc: func(id int) (*database.DomainModel, error) {
+				return domain, nil
+			},
+			MarkDomainCheckedFunc: func(id int, verified bool) (*database.DomainModel, error) {
+				checked := *domain
+				now := time.Now()
+				checked.CheckedAt = &now
+				if verified {
+					checked.VerifiedAt = &now
+				}
+				return &checked, nil
+			},
+		}
+		s := &Server{db: db, resolver: tt.resolver, domainCache: cache.NewLRU[*database.DomainModel](10, time.Minute)}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/7/domains/3/verify", nil)
+		req.SetPathValue("organization_id", "7")
+		req.SetPathValue("domain_id", "3")
+		req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 1, Role: auth.RoleEditor}))
+		rec := httptest.NewRecorder()
+		s.verifyOrganizationDomainHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+			continue
+		}
+
+		if rec.Code == http.StatusOK {
+			var body struct {
+				Domain domainResponse `json:"domain"`
+			}
+			json.NewDecoder(rec.Body).Decode(&body)
+			if body.Domain.Verified != tt.expectedVerified || (body.Domain.Verification == nil) != tt.expectedVerified {
+				t.Errorf("%s: expected verified to be %v; got %+v", tt.name, tt.expectedVerified, body.Domain)
+			}
+		}
+	}
+}
+
+func TestPinnableBy(t *testing.T) {
+	organizationId, otherOrganizationId := 7, 8
+
+	tests := []struct {
+		name           string
+		domain         *database.DomainModel
+		organizationId *int
+		expected       bool
+	}{
+		{"instance domain, personal link", &database.DomainModel{}, nil, true},
+		{"instance domain, organization link", &database.DomainModel{}, &organizationId, true},
+		{"custom domain, personal link", &database.DomainModel{OrganizationId: &organizationId}, nil, false},
+		{"custom domain, link of its organization", &database.DomainModel{OrganizationId: &organizationId}, &organizationId, true},
+		{"custom domain, link of another organization", &database.DomainModel{OrganizationId: &organizationId}, &otherOrganizationId, false},
+	}
+
+	for _, tt := range tests {
+		if got := pinnableBy(tt.domain, tt.organizationId); got != tt.expected {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestWritePageError(t *testing.T) {
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name                string
+		host                string
+		accept              string
+		acceptLanguage      string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{"api client", "go.acme.com", "application/json", "", "application/json", `"message":"Short Link is expired."`},
+		{"browser on a branded domain", "go.acme.com", "text/html,application/xhtml+xml", "", "text/html; charset=utf-8", "<footer>Acme Inc.</footer>"},
+		{"browser on another host", "localhost:8080", "text/html", "", "text/html; charset=utf-8", "color: #2563eb"},
+		{"spanish api client", "go.acme.com", "application/json", "es-ES,es;q=0.9", "application/json", `"message":"Este enlace corto ha caducado."`},
+		{"spanish browser", "go.acme.com", "text/html", "es", "text/html; charset=utf-8", `<html lang="es" dir="ltr">`},
+		{"arabic browser", "go.acme.com", "text/html", "ar-EG", "text/html; charset=utf-8", `<html lang="ar" dir="rtl">`},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetDomainByHostnameFunc: func(hostname string) (*database.DomainModel, error) {
+				if hostname != "go.acme.com" {
+					return nil, database.ErrNotFound
+				}
+				return &database.DomainModel{Id: 3, Hostname: hostname, VerifiedAt: &verifiedAt, PrimaryColor: "#ff0000", FooterText: "Acme Inc."}, nil
+			},
+		}
+		s := &Server{db: db, domainCache: cache.NewLRU[*database.DomainModel](10, time.Minute)}
+
+		req := httptest.NewRequest(http.MethodGet, "/short/abcdefgh", nil)
+		req.Host = tt.host
+		req.Header.Set("Accept", tt.accept)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		rec := httptest.NewRecorder()
+		s.writePageError(rec, req, http.StatusGone, i18n.LinkExpired)
+
+		if rec.Code != http.StatusGone {
+			t.Errorf("%s: expected %d; got %d", tt.name, http.StatusGone, rec.Code)
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != tt.expectedContentType {
+			t.Errorf("%s: expected %s; got %s", tt.name, tt.expectedContentType, contentType)
+		}
+		if !strings.Contains(rec.Body.String(), tt.expectedBody) {
+			t.Errorf("%s: expected the body to contain %q; got %s", tt.name, tt.expectedBody, rec.Body.String())
+		}
+	}
+}
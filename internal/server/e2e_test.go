@@ -0,0 +1,917 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/testutil"
+)
+
+func shorten(t *testing.T, server *httptest.Server, link string, expTimeMinutes int) string {
+	t.Helper()
+
+	body := strings.NewReader(`{"link_to_short": "` + link + `", "exp_time_minutes": ` + strconv.Itoa(expTimeMinutes) + `}`)
+	resp, err := http.Post(server.URL+"/short", "application/json", body)
+	if err != nil {
+		t.Fatalf("error shortening %s: %v", link, err)
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		Status   int    `json:"status"`
+		ShortUrl string `json:"short_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || created.Status != http.StatusOK {
+		t.Fatalf("unexpected shortening response for %s: %+v, %v", link, created, err)
+	}
+
+	return created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+}
+
+func TestShortenRedirectExpireCleanup(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	s := &Server{
+		db:         db,
+		infoCache:  cache.NewLRU[*database.ShortUrlModel](100, time.Minute),
+		statsCache: cache.NewLRU[*database.LinkStatsModel](100, time.Minute),
+
+		domainCache: cache.NewLRU[*database.DomainModel](100, time.Minute),
+	}
+	s.shortener = shortener.New(s.db, s.infoCache)
+	server := httptest.NewServer(s.RegisterRoutes())
+	defer server.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	active := shorten(t, server, "https://example.com/active", 60)
+	expired := shorten(t, server, "https://example.com/expired", 0)
+
+	resp, err := client.Get(server.URL + "/short/" + active)
+	if err != nil {
+		t.Fatalf("error following %s: %v", active, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "https://example.com/active" {
+		t.Fatalf("expected a redirect to the destination; got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	entity, err := db.GetShortUrl(active)
+	if err != nil || entity.TimesClicked != 1 {
+		t.Fatalf("expected the redirect to be counted; got %+v, %v", entity, err)
+	}
+
+	resp, err = client.Get(server.URL + "/short/" + expired)
+	if err != nil {
+		t.Fatalf("error following %s: %v", expired, err)
+	}
+	var expiredBody errorResponse
+	json.NewDecoder(resp.Body).Decode(&expiredBody)
+	resp.Body.Close()
+	if expiredBody.Status != http.StatusGone {
+		t.Fatalf("expected the expired link to be gone; got %+v", expiredBody)
+	}
+
+	if err := db.DeleteExpiredLinks(); err != nil {
+		t.Fatalf("error deleting expired links: %v", err)
+	}
+
+	if _, err := db.GetShortUrl(expired); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the expired link to be cleaned up; got %v", err)
+	}
+
+	if _, err := db.GetShortUrl(active); err != nil {
+		t.Errorf("expected the active link to survive the cleanup; got %v", err)
+	}
+}
+
+func TestSignInKeysExpireAndAreReplaced(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	user, err := db.SaveUser(&database.UserModel{Email: "sign-in@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	if _, err := db.SaveApiKey(&database.ApiKeyModel{UserId: user.Id, Name: "github sign in", KeyPrefix: "old", KeyHash: "old-hash", ExpiresAt: &expired}); err != nil {
+		t.Fatalf("error saving the expired key: %v", err)
+	}
+	if _, err := db.GetApiKeyByHash("old-hash"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the expired key to be refused; got %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if _, err := db.SaveApiKey(&database.ApiKeyModel{UserId: user.Id, Name: "github sign in", KeyPrefix: "new", KeyHash: "new-hash", ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("error saving the key: %v", err)
+	}
+	if _, err := db.SaveApiKey(&database.ApiKeyModel{UserId: user.Id, Name: "deploys", KeyPrefix: "ci", KeyHash: "ci-hash"}); err != nil {
+		t.Fatalf("error saving the key: %v", err)
+	}
+	if _, err := db.GetApiKeyByHash("new-hash"); err != nil {
+		t.Errorf("expected the key to authenticate before it expires; got %v", err)
+	}
+
+	revoked, err := db.RevokeApiKeysNamed(user.Id, "github sign in")
+	if err != nil || revoked != 2 {
+		t.Errorf("expected both sign in keys revoked; got %d, %v", revoked, err)
+	}
+	if _, err := db.GetApiKeyByHash("new-hash"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the replaced key to be refused; got %v", err)
+	}
+	if _, err := db.GetApiKeyByHash("ci-hash"); err != nil {
+		t.Errorf("expected the other keys kept; got %v", err)
+	}
+}
+
+func TestModerateLongCode(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	code := "quarterly-planning-2025-engineering-offsite"
+	if _, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/offsite", ExpTimeMinutes: 60, ShortCode: code}); err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+
+	event, err := db.TransitionModeration(&database.TakedownEventModel{
+		ShortCode:  code,
+		FromState:  database.ModerationActive,
+		ToState:    database.ModerationFlagged,
+		ReasonCode: "spam",
+		Actor:      "admin-token",
+	})
+	if err != nil || event.ShortCode != code {
+		t.Fatalf("expected the long code to be moderated; got %+v, %v", event, err)
+	}
+
+	events, err := db.ListTakedownEvents(code)
+	if err != nil || len(events) != 1 {
+		t.Errorf("expected the transition in the history; got %d events, %v", len(events), err)
+	}
+}
+
+func TestModerationNotifiesOwner(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	owner, err := db.SaveUser(&database.UserModel{Email: "owner@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the owner: %v", err)
+	}
+	if _, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/sale", ExpTimeMinutes: 60, ShortCode: "sale", OwnerId: &owner.Id}); err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if _, err := db.TransitionModeration(&database.TakedownEventModel{ShortCode: "sale", FromState: database.ModerationActive, ToState: database.ModerationTakenDown, ReasonCode: "spam", Actor: "admin-token"}); err != nil {
+		t.Fatalf("error taking the link down: %v", err)
+	}
+
+	notifications, err := db.ListUnsentNotifications(0, 10)
+	if err != nil || len(notifications) != 1 || notifications[0].Email != "owner@example.com" || !strings.Contains(notifications[0].Body, "Reason: spam") {
+		t.Fatalf("expected the owner notified by email; got %+v, %v", notifications, err)
+	}
+
+	if err := db.MarkNotificationSent(notifications[0].Id, time.Now()); err != nil {
+		t.Fatalf("error marking the notification sent: %v", err)
+	}
+	if notifications, err := db.ListUnsentNotifications(0, 10); err != nil || len(notifications) != 0 {
+		t.Errorf("expected nothing left to send; got %+v, %v", notifications, err)
+	}
+}
+
+func TestUpsertOwnLink(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	owner, err := db.SaveUser(&database.UserModel{Email: "deploys@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+	other, err := db.SaveUser(&database.UserModel{Email: "other@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+
+	saved, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/v1", ExpTimeMinutes: 1, ShortCode: "release", OwnerId: &owner.Id})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+
+	updated, created, err := db.UpsertShortUrl(&database.ShortUrlModel{Link: "https://example.com/v2", ExpTimeMinutes: 60, ShortCode: "release", OwnerId: &owner.Id})
+	if err != nil || created {
+		t.Fatalf("expected the link updated; got created %v, %v", created, err)
+	}
+	if !updated.CreatedAt.After(saved.CreatedAt) {
+		t.Errorf("expected the expiry to count from the update; got created_at %v, was %v", updated.CreatedAt, saved.CreatedAt)
+	}
+
+	_, _, err = db.UpsertShortUrl(&database.ShortUrlModel{Link: "https://example.com/v3", ExpTimeMinutes: 60, ShortCode: "release", OwnerId: &other.Id})
+	if !errors.Is(err, database.ErrDuplicateCode) {
+		t.Errorf("expected the code of someone else refused; got %v", err)
+	}
+
+	if _, err := db.QueueForReview(&database.ReviewModel{ShortUrlId: saved.Id, Score: 80, Reasons: "look-alike domain"}); err != nil {
+		t.Fatalf("error queueing the link for review: %v", err)
+	}
+
+	_, _, err = db.UpsertShortUrl(&database.ShortUrlModel{Link: "https://example.com/v3", ExpTimeMinutes: 60, ShortCode: "release", OwnerId: &owner.Id})
+	if !errors.Is(err, database.ErrDisabled) {
+		t.Errorf("expected the link under review refused as disabled; got %v", err)
+	}
+}
+
+func TestSaveShortUrlForReview(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	saved, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://paypa1.example", ExpTimeMinutes: 60, ShortCode: "phishy", Review: &database.ReviewModel{Score: 80, Reasons: "look-alike domain"}})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if saved.DisabledAt == nil {
+		t.Errorf("expected the link answered disabled; got %+v", saved)
+	}
+	if stored, err := db.GetShortUrl("phishy"); err != nil || stored.DisabledAt == nil {
+		t.Errorf("expected the link stored disabled; got %+v, %v", stored, err)
+	}
+	reviews, err := db.ListReviews(database.ReviewPending)
+	if err != nil || len(reviews) != 1 || reviews[0].ShortUrlId != saved.Id {
+		t.Fatalf("expected the link queued along with it; got %+v, %v", reviews, err)
+	}
+
+}
+
+func TestLinkChangesAreDated(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	saved, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/v1", ExpTimeMinutes: 60, ShortCode: "dated"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	created, err := db.GetShortUrl(saved.ShortCode)
+	if err != nil {
+		t.Fatalf("error loading the link: %v", err)
+	}
+
+	if err := db.UpdateTimesClicked(saved.ShortCode); err != nil {
+		t.Fatalf("error counting a click: %v", err)
+	}
+	clicked, err := db.GetShortUrl(saved.ShortCode)
+	if err != nil || !clicked.UpdatedAt.After(created.UpdatedAt) {
+		t.Fatalf("expected the click to date the link; got %v after %v, %v", clicked.UpdatedAt, created.UpdatedAt, err)
+	}
+
+	before, err := db.LastShortUrlDeletion()
+	if err != nil {
+		t.Fatalf("error loading the last deletion: %v", err)
+	}
+	if err := db.DeleteExpiredLinks(); err != nil {
+		t.Fatalf("error deleting expired links: %v", err)
+	}
+	if after, err := db.LastShortUrlDeletion(); err != nil || !after.Equal(before) {
+		t.Errorf("expected a cleanup deleting nothing to leave the date alone; got %v, was %v, %v", after, before, err)
+	}
+
+	if err := db.DeleteShortUrl(saved.ShortCode); err != nil {
+		t.Fatalf("error deleting the link: %v", err)
+	}
+	if after, err := db.LastShortUrlDeletion(); err != nil || !after.After(before) {
+		t.Errorf("expected the deletion dated; got %v, was %v, %v", after, before, err)
+	}
+}
+
+func TestRecordClicksInBatches(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	kept, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/kept", ExpTimeMinutes: 60, ShortCode: "kept"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	deleted, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/deleted", ExpTimeMinutes: 60, ShortCode: "deleted"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if err := db.DeleteShortUrl(deleted.ShortCode); err != nil {
+		t.Fatalf("error deleting the link: %v", err)
+	}
+
+	clicks := []*database.ClickEventModel{
+		{ShortUrlId: kept.Id, Ip: "203.0.113.0", UserAgent: "curl/8.5.0"},
+		{ShortUrlId: kept.Id, Referrer: "https://news.example.com/"},
+		{ShortUrlId: deleted.Id},
+	}
+	stored, err := db.RecordClicks(clicks)
+	if err != nil || stored != 2 {
+		t.Fatalf("expected the clicks of the deleted link left out; got %d stored, %v", stored, err)
+	}
+
+	entity, err := db.GetShortUrl(kept.ShortCode)
+	if err != nil || entity.TimesClicked != 2 {
+		t.Fatalf("expected the clicks counted; got %+v, %v", entity, err)
+	}
+	stats, err := db.GetLinkStats(kept.ShortCode, 7)
+	if err != nil || len(stats.Daily) != 1 || stats.Daily[0].Clicks != 2 {
+		t.Errorf("expected the click events stored; got %+v, %v", stats, err)
+	}
+}
+
+func TestClickPartitions(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	partitions, err := db.ListClickPartitions()
+	if err != nil || len(partitions) != 2 || partitions[0].From != nil {
+		t.Fatalf("expected the history partition and the one of next month; got %+v, %v", partitions, err)
+	}
+
+	last := partitions[len(partitions)-1]
+	created, err := db.CreateClickPartition(last.To, last.To.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("error creating a partition: %v", err)
+	}
+
+	saved, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/later", ExpTimeMinutes: 60, ShortCode: "later"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if stored, err := db.RecordClicks([]*database.ClickEventModel{{ShortUrlId: saved.Id, ClickedAt: last.To.Add(time.Hour)}}); err != nil || stored != 1 {
+		t.Fatalf("expected the click stored in the new partition; got %d, %v", stored, err)
+	}
+
+	if err := db.DropClickPartition(created.Name); err != nil {
+		t.Fatalf("error dropping the partition: %v", err)
+	}
+	stats, err := db.GetLinkStats(saved.ShortCode, 400)
+	if err != nil || len(stats.Daily) != 0 {
+		t.Errorf("expected the click dropped with its partition; got %+v, %v", stats, err)
+	}
+	if partitions, err := db.ListClickPartitions(); err != nil || len(partitions) != 2 {
+		t.Errorf("expected the partition gone; got %+v, %v", partitions, err)
+	}
+}
+
+func TestStatsViewsFallBackWhenStale(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	for _, code := range []string{"docs", "blog"} {
+		if _, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://Example.com/" + code, ExpTimeMinutes: 60, ShortCode: code}); err != nil {
+			t.Fatalf("error saving the link: %v", err)
+		}
+	}
+	if err := db.UpdateTimesClicked("docs"); err != nil {
+		t.Fatalf("error counting a click: %v", err)
+	}
+
+	// Built with the migration, before the links
+	domains, _, err := db.DomainStats(10, time.Hour)
+	if err != nil || len(domains) != 0 {
+		t.Fatalf("expected the view read while fresh; got %+v, %v", domains, err)
+	}
+
+	domains, _, err = db.DomainStats(10, 0)
+	if err != nil || len(domains) != 1 || domains[0].Domain != "example.com" || domains[0].Links != 2 || domains[0].Clicks != 1 {
+		t.Fatalf("expected the stale view counted live; got %+v, %v", domains, err)
+	}
+
+	if err := db.RefreshStatsViews(); err != nil {
+		t.Fatalf("error refreshing the views: %v", err)
+	}
+	leaderboard, asOf, err := db.Leaderboard(10, time.Hour)
+	if err != nil || len(leaderboard) != 1 || leaderboard[0].ShortCode != "docs" || time.Since(asOf) > time.Minute {
+		t.Errorf("expected the refreshed view read; got %+v as of %v, %v", leaderboard, asOf, err)
+	}
+}
+
+func TestAnalyticsRetention(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	owner, err := db.SaveUser(&database.UserModel{Email: "analytics@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+	organization, err := db.CreateOrganization(&database.OrganizationModel{Name: "Analytics", Plan: plans.Free}, owner.Id)
+	if err != nil {
+		t.Fatalf("error creating the organization: %v", err)
+	}
+
+	anonymous, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/anonymous", ExpTimeMinutes: 60, ShortCode: "anonymous"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	shared, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/shared", ExpTimeMinutes: 60, ShortCode: "shared", OrganizationId: &organization.Id})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+
+	now := time.Now()
+	var clicks []*database.ClickEventModel
+	for _, link := range []*database.ShortUrlModel{anonymous, shared} {
+		clicks = append(clicks, &database.ClickEventModel{ShortUrlId: link.Id, ClickedAt: now.AddDate(0, 0, -10)}, &database.ClickEventModel{ShortUrlId: link.Id, ClickedAt: now})
+	}
+	if _, err := db.RecordClicks(clicks); err != nil {
+		t.Fatalf("error recording the clicks: %v", err)
+	}
+	if _, err := db.RollupClicks(now.AddDate(0, 0, -30)); err != nil {
+		t.Fatalf("error rolling up the clicks: %v", err)
+	}
+
+	forever := 0
+	if _, err := db.SetOrganizationRetention(&database.RetentionModel{OrganizationId: organization.Id, ClickRetentionDays: &forever}); err != nil {
+		t.Fatalf("error overriding the retention: %v", err)
+	}
+
+	free := database.AnalyticsScope{Plan: plans.Free}
+	if purged, err := db.PurgeClickEvents(free, now.AddDate(0, 0, -5)); err != nil || purged != 1 {
+		t.Fatalf("expected the old click of the anonymous link purged only; got %d, %v", purged, err)
+	}
+
+	// The purged day is read from the rollups
+	stats, err := db.GetLinkStats(anonymous.ShortCode, 30)
+	if err != nil || len(stats.Daily) != 2 {
+		t.Fatalf("expected both days counted; got %+v, %v", stats, err)
+	}
+
+	if purged, err := db.PurgeClickRollups(free, now.AddDate(0, 0, -5)); err != nil || purged != 2 {
+		t.Fatalf("expected the old rollups of both links purged, the organization following its plan for those; got %d, %v", purged, err)
+	}
+	if stats, err := db.GetLinkStats(anonymous.ShortCode, 30); err != nil || len(stats.Daily) != 1 {
+		t.Errorf("expected only today left; got %+v, %v", stats, err)
+	}
+
+	retentions, err := db.ListOrganizationRetentions()
+	if err != nil || len(retentions) != 1 || retentions[0].OrganizationId != organization.Id || retentions[0].RollupRetentionDays != nil {
+		t.Errorf("expected the override of the organization listed; got %+v, %v", retentions, err)
+	}
+}
+
+func TestWarehouseExportQueries(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	saved, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/export", ExpTimeMinutes: 60, ShortCode: "export"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if _, err := db.RecordClicks([]*database.ClickEventModel{{ShortUrlId: saved.Id, RawIp: "203.0.113.7"}, {ShortUrlId: saved.Id}}); err != nil {
+		t.Fatalf("error recording the clicks: %v", err)
+	}
+
+	cursor, err := db.GetExportCursor("click_events")
+	if err != nil || cursor.LastId != 0 {
+		t.Fatalf("expected a cursor at the start; got %+v, %v", cursor, err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	clicks, err := db.ListClicksForExport(cursor.LastId, later, 1)
+	if err != nil || len(clicks) != 1 || clicks[0].ShortCode != "export" || clicks[0].RawIp != "" {
+		t.Fatalf("expected the first click with its code; got %+v, %v", clicks, err)
+	}
+	cursor.LastId = clicks[0].Id
+	if err := db.SaveExportCursor(cursor); err != nil {
+		t.Fatalf("error saving the cursor: %v", err)
+	}
+	if cursor, err = db.GetExportCursor("click_events"); err != nil || cursor.LastId != clicks[0].Id {
+		t.Fatalf("expected the cursor saved; got %+v, %v", cursor, err)
+	}
+	if clicks, err := db.ListClicksForExport(cursor.LastId, later, 10); err != nil || len(clicks) != 1 {
+		t.Errorf("expected the click after the cursor only; got %+v, %v", clicks, err)
+	}
+
+	links, err := db.ListLinksForExport(time.Time{}, 0, later, 10)
+	if err != nil || len(links) != 1 || links[0].ShortCode != "export" {
+		t.Fatalf("expected the link listed; got %+v, %v", links, err)
+	}
+	if links, err := db.ListLinksForExport(links[0].UpdatedAt, links[0].Id, later, 10); err != nil || len(links) != 0 {
+		t.Errorf("expected nothing past the last change; got %+v, %v", links, err)
+	}
+}
+
+func TestLinkChangesEvictCaches(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	s := &Server{db: db}
+	s.initCaches()
+	s.shortener = shortener.New(s.db, s.infoCache)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.watchLinkChanges(ctx)
+
+	saved, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/v1", ExpTimeMinutes: 60, ShortCode: "release"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+
+	// The listener connects in the background, changes made before it listens go unnoticed
+	deadline := time.Now().Add(10 * time.Second)
+	for version := 2; ; version++ {
+		s.infoCache.Set(saved.ShortCode, saved)
+
+		// As another replica would, behind the back of s
+		link := "https://example.com/v" + strconv.Itoa(version)
+		if _, _, err := db.UpsertShortUrl(&database.ShortUrlModel{Link: link, ExpTimeMinutes: 60, ShortCode: saved.ShortCode}); err != nil {
+			t.Fatalf("error updating the link: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if _, ok := s.infoCache.Get(saved.ShortCode); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the updated link evicted from the cache")
+		}
+	}
+}
+
+func TestTableUpkeep(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	_, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/gone", ExpTimeMinutes: 0, ShortCode: "gone"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if err := db.DeleteExpiredLinks(); err != nil {
+		t.Fatalf("error deleting the expired link: %v", err)
+	}
+
+	// click_events is partitioned, its partitions are analyzed and vacuumed along with it
+	tables := []string{"short_url", "click_events"}
+	if err := db.VacuumTables(tables); err != nil {
+		t.Fatalf("error vacuuming: %v", err)
+	}
+	if err := db.AnalyzeTables(tables); err != nil {
+		t.Fatalf("error analyzing: %v", err)
+	}
+	if err := db.AnalyzeTables([]string{"missing_table"}); err == nil {
+		t.Errorf("expected an unknown table refused")
+	}
+
+	stats, err := db.ListTableStats()
+	if err != nil {
+		t.Fatalf("error listing the table stats: %v", err)
+	}
+	found := false
+	for _, table := range stats {
+		if table.Table == "short_url" {
+			found = true
+			if table.TableBytes == 0 || table.AnalyzedAt == nil {
+				t.Errorf("expected the size and analyze time of short_url; got %+v", table)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected short_url in the stats; got %d tables", len(stats))
+	}
+}
+
+func TestDryRunChangesNothing(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	if _, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/expired", ExpTimeMinutes: 0, ShortCode: "expired"}); err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	dryRun := db.DryRun()
+	if err := dryRun.DeleteExpiredLinks(); err != nil {
+		t.Fatalf("error dry running the deletion: %v", err)
+	}
+	if _, err := db.GetShortUrl("expired"); err != nil {
+		t.Errorf("expected the expired link kept by the dry run; got %v", err)
+	}
+	if deleted, err := dryRun.DeleteFinishedJobs(time.Now()); err != nil || deleted != 0 {
+		t.Errorf("expected nothing to delete; got %d, %v", deleted, err)
+	}
+
+	// Transactions of a dry run stay dry runs
+	err := dryRun.RunInTransaction(func(tx database.Service) error {
+		return tx.DeleteExpiredLinks()
+	})
+	if err != nil {
+		t.Fatalf("error dry running in a transaction: %v", err)
+	}
+	if _, err := db.GetShortUrl("expired"); err != nil {
+		t.Errorf("expected the expired link kept by the dry run in a transaction; got %v", err)
+	}
+
+	if err := db.DeleteExpiredLinks(); err != nil {
+		t.Fatalf("error deleting the expired links: %v", err)
+	}
+	if _, err := db.GetShortUrl("expired"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the expired link deleted for real; got %v", err)
+	}
+}
+
+func TestRequestJobRun(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	if _, err := db.RequestJobRun("refresh_stats_views"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("expected a job that never ran not found; got %v", err)
+	}
+
+	if err := db.StartJobRun("refresh_stats_views", 5*time.Minute); err != nil {
+		t.Fatalf("error recording the run: %v", err)
+	}
+	requested, err := db.RequestJobRun("refresh_stats_views")
+	if err != nil || requested.RunRequestedAt == nil || requested.Interval != 5*time.Minute {
+		t.Fatalf("expected the run requested; got %+v, %v", requested, err)
+	}
+
+	if names, err := db.ClaimJobRunRequests(); err != nil || len(names) != 1 || names[0] != "refresh_stats_views" {
+		t.Errorf("expected the request claimed; got %v, %v", names, err)
+	}
+	if names, err := db.ClaimJobRunRequests(); err != nil || len(names) != 0 {
+		t.Errorf("expected the request claimed only once; got %v, %v", names, err)
+	}
+}
+
+func TestLinkDigest(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	user, err := db.SaveUser(&database.UserModel{Email: "digest@example.com", Role: "user"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+	link, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com/docs", ShortCode: "digest1", ExpTimeMinutes: 3 * 24 * 60, OwnerId: &user.Id})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+
+	now := time.Now().UTC()
+	clicks := []*database.ClickEventModel{
+		{ShortUrlId: link.Id, Referrer: "https://news.ycombinator.com/item?id=1", ClickedAt: now.Add(-time.Hour)},
+		{ShortUrlId: link.Id, Referrer: "https://news.ycombinator.com/", ClickedAt: now.Add(-time.Hour)},
+		{ShortUrlId: link.Id, ClickedAt: now.Add(-time.Hour)},
+	}
+	if _, err := db.RecordClicks(clicks); err != nil {
+		t.Fatalf("error recording the clicks: %v", err)
+	}
+	if _, err := db.RollupClicks(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("error rolling the clicks up: %v", err)
+	}
+
+	since := now.Truncate(24 * time.Hour)
+	digest, err := db.GetLinkDigest(user.Id, since, since.AddDate(0, 0, 1), 5)
+	if err != nil {
+		t.Fatalf("error collecting the digest: %v", err)
+	}
+	if digest.Links != 1 || digest.Clicks != 3 || len(digest.TopLinks) != 1 || digest.TopLinks[0].Clicks != 3 {
+		t.Errorf("expected the clicks of the day summed up; got %+v", digest)
+	}
+	if len(digest.TopReferrers) != 1 || digest.TopReferrers[0].Referrer != "news.ycombinator.com" || digest.TopReferrers[0].Clicks != 2 {
+		t.Errorf("expected the referrers counted by host; got %+v", digest.TopReferrers)
+	}
+	if len(digest.Expiring) != 1 || digest.Expiring[0].ShortCode != "digest1" {
+		t.Errorf("expected the link expiring within the week listed; got %+v", digest.Expiring)
+	}
+
+	if _, err := db.UpdateEmailPreferences(&database.EmailPreferencesModel{UserId: user.Id, WeeklyDigest: false}); err != nil {
+		t.Fatalf("error opting out: %v", err)
+	}
+	if recipients, err := db.ListDigestRecipients(now, 0, 10); err != nil || len(recipients) != 0 {
+		t.Errorf("expected the user opted out left out; got %v, %v", recipients, err)
+	}
+}
+
+func TestPendingInvitations(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	owner, err := db.SaveUser(&database.UserModel{Email: "owner@example.com", Role: "user"})
+	if err != nil {
+		t.Fatalf("error saving the owner: %v", err)
+	}
+	invitee, err := db.SaveUser(&database.UserModel{Email: "invitee@example.com", Role: "user"})
+	if err != nil {
+		t.Fatalf("error saving the invitee: %v", err)
+	}
+	organization, err := db.CreateOrganization(&database.OrganizationModel{Name: "Acme"}, owner.Id)
+	if err != nil {
+		t.Fatalf("error creating the organization: %v", err)
+	}
+
+	invitation, err := db.SaveInvitation(&database.InvitationModel{OrganizationId: organization.Id, Email: invitee.Email, Role: database.OrgRoleEditor, TokenHash: "first", InvitedBy: &owner.Id, ExpiresAt: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("error saving the invitation: %v", err)
+	}
+	if pending, err := db.ListInvitations(organization.Id); err != nil || len(pending) != 0 {
+		t.Errorf("expected the expired invitation not pending; got %v, %v", pending, err)
+	}
+
+	if _, err := db.RenewInvitation(organization.Id, invitation.Id, "second", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("error renewing the invitation: %v", err)
+	}
+	if pending, err := db.ListInvitations(organization.Id); err != nil || len(pending) != 1 || pending[0].Id != invitation.Id {
+		t.Errorf("expected the renewed invitation pending; got %v, %v", pending, err)
+	}
+	if _, err := db.AcceptInvitation("first", invitee.Id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the replaced token refused; got %v", err)
+	}
+	if _, err := db.AcceptInvitation("second", invitee.Id); err != nil {
+		t.Fatalf("error accepting the invitation: %v", err)
+	}
+
+	if _, err := db.RevokeInvitation(organization.Id, invitation.Id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an accepted invitation kept; got %v", err)
+	}
+}
+
+func TestPasswordResetIsSingleUse(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	user, err := db.SaveUser(&database.UserModel{Email: "Reset@example.com", Role: "user"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+	if found, err := db.GetUserPassword("reset@example.com"); err != nil || found.User.Id != user.Id || found.PasswordHash != "" {
+		t.Fatalf("expected the user found by email without a password; got %+v, %v", found, err)
+	}
+
+	for _, tokenHash := range []string{"first", "second"} {
+		if _, err := db.SavePasswordReset(&database.PasswordResetModel{UserId: user.Id, TokenHash: tokenHash, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatalf("error saving the reset: %v", err)
+		}
+	}
+	if _, err := db.SavePasswordReset(&database.PasswordResetModel{UserId: user.Id, TokenHash: "expired", ExpiresAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("error saving the reset: %v", err)
+	}
+
+	if _, err := db.ResetPassword("expired", "hash"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an expired reset refused; got %v", err)
+	}
+	if reset, err := db.ResetPassword("second", "new hash"); err != nil || reset.Id != user.Id {
+		t.Fatalf("expected the password reset; got %+v, %v", reset, err)
+	}
+	if found, err := db.GetUserPassword(user.Email); err != nil || found.PasswordHash != "new hash" {
+		t.Errorf("expected the password set; got %+v, %v", found, err)
+	}
+	for _, tokenHash := range []string{"second", "first"} {
+		if _, err := db.ResetPassword(tokenHash, "other hash"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("expected the reset %s used up; got %v", tokenHash, err)
+		}
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	user, err := db.SignUp("new@example.com", "hash")
+	if err != nil || user.EmailVerifiedAt != nil {
+		t.Fatalf("expected the user signed up unverified; got %+v, %v", user, err)
+	}
+	var conflict *database.ConflictError
+	if _, err := db.SignUp("new@example.com", "other hash"); !errors.As(err, &conflict) || conflict.Field != "email" {
+		t.Errorf("expected a second sign up with the email refused; got %v", err)
+	}
+
+	if _, err := db.SaveEmailVerification(&database.EmailVerificationModel{UserId: user.Id, Email: user.Email, TokenHash: "expired", ExpiresAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("error saving the verification: %v", err)
+	}
+	if _, err := db.SaveEmailVerification(&database.EmailVerificationModel{UserId: user.Id, Email: user.Email, TokenHash: "valid", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("error saving the verification: %v", err)
+	}
+
+	if _, err := db.VerifyEmail("expired"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an expired verification refused; got %v", err)
+	}
+	if verified, err := db.VerifyEmail("valid"); err != nil || verified.Id != user.Id || verified.EmailVerifiedAt == nil {
+		t.Fatalf("expected the email verified; got %+v, %v", verified, err)
+	}
+	if _, err := db.VerifyEmail("valid"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the verification used up; got %v", err)
+	}
+
+	// Whoever signed up first with the email of someone else loses the password once its owner signs in
+	squatter, err := db.SignUp("owner@example.com", "squatter hash")
+	if err != nil {
+		t.Fatalf("error signing up: %v", err)
+	}
+	owner, err := db.GetOrCreateUserByIdentity(&database.UserIdentityModel{Provider: "github", Subject: "1", Email: "owner@example.com"})
+	if err != nil || owner.Id != squatter.Id || owner.EmailVerifiedAt == nil {
+		t.Fatalf("expected the identity linked to the user, verified; got %+v, %v", owner, err)
+	}
+	if found, err := db.GetUserPassword("owner@example.com"); err != nil || found.PasswordHash != "" {
+		t.Errorf("expected the unverified password dropped; got %+v, %v", found, err)
+	}
+}
+
+func TestSessionsEnd(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	user, err := db.SaveUser(&database.UserModel{Email: "session@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+
+	for _, tokenHash := range []string{"active", "expired", "other"} {
+		expiresAt := time.Now().Add(time.Hour)
+		if tokenHash == "expired" {
+			expiresAt = time.Now().Add(-time.Minute)
+		}
+		if _, err := db.SaveSession(&database.SessionModel{UserId: user.Id, TokenHash: tokenHash, Method: "password", ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("error saving the session: %v", err)
+		}
+	}
+
+	session, err := db.GetSessionByHash("active", time.Minute)
+	if err != nil || session.User.Id != user.Id {
+		t.Fatalf("expected the session of the user; got %+v, %v", session, err)
+	}
+	if _, err := db.GetSessionByHash("expired", time.Minute); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an expired session refused; got %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := db.GetSessionByHash("active", 5*time.Millisecond); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an idle session refused; got %v", err)
+	}
+
+	if sessions, err := db.ListSessions(user.Id, time.Hour); err != nil || len(sessions) != 2 {
+		t.Errorf("expected the active sessions listed; got %d, %v", len(sessions), err)
+	}
+	if err := db.RevokeSession(user.Id+1, session.Id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the session of someone else left alone; got %v", err)
+	}
+	if err := db.RevokeSession(user.Id, session.Id); err != nil {
+		t.Fatalf("error revoking the session: %v", err)
+	}
+	if _, err := db.GetSessionByHash("active", time.Hour); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected a revoked session refused; got %v", err)
+	}
+
+	if deleted, err := db.DeleteEndedSessions(); err != nil || deleted != 2 {
+		t.Errorf("expected the expired and revoked sessions deleted; got %d, %v", deleted, err)
+	}
+	if revoked, err := db.RevokeSessions(user.Id); err != nil || revoked != 1 {
+		t.Errorf("expected the remaining session revoked; got %d, %v", revoked, err)
+	}
+}
+
+func TestClaimLink(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	user, err := db.SaveUser(&database.UserModel{Email: "claim@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+	link, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com", ShortCode: "claimed"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if err := db.SaveLinkClaim(&database.LinkClaimModel{ShortUrlId: link.Id, TokenHash: "claim"}); err != nil {
+		t.Fatalf("error saving the claim: %v", err)
+	}
+
+	if _, err := db.ClaimLink("unknown", user.Id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an unknown claim refused; got %v", err)
+	}
+	claimed, err := db.ClaimLink("claim", user.Id)
+	if err != nil || claimed.OwnerId == nil || *claimed.OwnerId != user.Id {
+		t.Fatalf("expected the link owned by the user; got %+v, %v", claimed, err)
+	}
+	if _, err := db.ClaimLink("claim", user.Id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the claim used up; got %v", err)
+	}
+}
+
+func TestLinkEditToken(t *testing.T) {
+	db := testutil.NewDatabase(t)
+
+	link, err := db.SaveShortUrl(&database.ShortUrlModel{Link: "https://example.com", ExpTimeMinutes: 60, ShortCode: "editable"})
+	if err != nil {
+		t.Fatalf("error saving the link: %v", err)
+	}
+	if err := db.SaveLinkEditToken(&database.LinkEditTokenModel{ShortUrlId: link.Id, TokenHash: "edit"}); err != nil {
+		t.Fatalf("error saving the edit token: %v", err)
+	}
+
+	if _, err := db.GetShortUrlByEditToken("editable", "guess"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected another token refused; got %v", err)
+	}
+	if found, err := db.GetShortUrlByEditToken("editable", "edit"); err != nil || found.Id != link.Id {
+		t.Fatalf("expected the link of the token; got %+v, %v", found, err)
+	}
+
+	edited, err := db.EditShortUrl("editable", "https://example.org", 120, nil)
+	if err != nil || edited.Link != "https://example.org" || edited.ExpTimeMinutes != 120 || !edited.CreatedAt.Equal(link.CreatedAt) {
+		t.Errorf("expected the link edited, its expiry still counted from its creation; got %+v, %v", edited, err)
+	}
+
+	// Once claimed, the link is managed from the account
+	user, err := db.SaveUser(&database.UserModel{Email: "editor@example.com", Role: "editor"})
+	if err != nil {
+		t.Fatalf("error saving the user: %v", err)
+	}
+	if err := db.SaveLinkClaim(&database.LinkClaimModel{ShortUrlId: link.Id, TokenHash: "claim"}); err != nil {
+		t.Fatalf("error saving the claim: %v", err)
+	}
+	if _, err := db.ClaimLink("claim", user.Id); err != nil {
+		t.Fatalf("error claiming the link: %v", err)
+	}
+	if _, err := db.GetShortUrlByEditToken("editable", "edit"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the edit token of a claimed link refused; got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/policy"
+	"url-shortner/internal/shortener"
+)
+
+// Header carrying the edit token of an anonymous link, answered as edit_token when the link was created
+const editTokenHeader = "X-Edit-Token"
+
+// editableLink returns the link of the request when X-Edit-Token is its edit token, and answers the request
+// otherwise
+func (s *Server) editableLink(w http.ResponseWriter, r *http.Request) *database.ShortUrlModel {
+	entity, err := s.shortener.Editable(r.PathValue("short_code"), r.Header.Get(editTokenHeader))
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusForbidden, "Send the edit_token answered when the link was created as "+editTokenHeader+".")
+		return nil
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the link.")
+		return nil
+	}
+	return entity
+}
+
+// editLinkHandler changes the destination or the expiry of an anonymous link, with its edit token. Fields left out
+// of the body are kept
+func (s *Server) editLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		LinkToShort    *string `json:"link_to_short"`
+		ExpTimeMinutes *int    `json:"exp_time_minutes"`
+	}
+
+	err := decodeLinkBody(w, r, &reqBody)
+	if errors.Is(err, shortener.ErrLinkTooLong) {
+		writeShortenError(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+
+	entity := s.editableLink(w, r)
+	if entity == nil {
+		return
+	}
+
+	link, expTimeMinutes := entity.Link, entity.ExpTimeMinutes
+	if reqBody.ExpTimeMinutes != nil {
+		expTimeMinutes = *reqBody.ExpTimeMinutes
+	}
+
+	// A new destination goes through the checks of a new link
+	var assessment policy.Assessment
+	if reqBody.LinkToShort != nil {
+		if err := shortener.Validate(*reqBody.LinkToShort, expTimeMinutes); err != nil {
+			writeShortenError(w, err)
+			return
+		}
+
+		link, err = shortener.NormalizeLink(*reqBody.LinkToShort)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		link, err = s.unwrapDestination(r.Context(), requestHost(r), link)
+		if err != nil {
+			writeShortenError(w, err)
+			return
+		}
+
+		var ok bool
+		if assessment, ok = s.checkDestination(w, r, link); !ok {
+			return
+		}
+	}
+
+	// The link may have been disabled or deleted since it was loaded, EditShortUrl tells neither apart
+	review := reviewOf(assessment)
+	edited, err := s.shortener.Edit(entity, link, expTimeMinutes, review)
+	if errors.Is(err, database.ErrDisabled) || errors.Is(err, database.ErrNotFound) {
+		writeJSON(w, http.StatusConflict, errorResponse{
+			Status:  http.StatusConflict,
+			Message: "The link is disabled or under review, it can't be updated until it is restored.",
+			Field:   "short_code",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[edits:editLinkHandler] Could not edit link {%s}: %v", entity.ShortCode, err)
+		writeShortenError(w, err)
+		return
+	}
+
+	s.forgetLink(edited.ShortCode)
+	s.audit(r, "link.update", "link", edited.ShortCode, toLinkResponse(entity), toLinkResponse(edited))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status      int          `json:"status"`
+		Link        linkResponse `json:"link"`
+		UnderReview bool         `json:"under_review,omitempty"`
+	}{
+		Status:      http.StatusOK,
+		Link:        toLinkResponse(edited),
+		UnderReview: review != nil,
+	})
+}
+
+// deleteLinkHandler deletes an anonymous link, with its edit token
+func (s *Server) deleteLinkHandler(w http.ResponseWriter, r *http.Request) {
+	entity := s.editableLink(w, r)
+	if entity == nil {
+		return
+	}
+
+	err := s.db.DeleteShortUrl(entity.ShortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Did not found a url for the short_code")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not delete link.")
+		return
+	}
+	s.forgetLink(entity.ShortCode)
+
+	s.audit(r, "link.delete", "link", entity.ShortCode, toLinkResponse(entity), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/shortener"
+)
+
+func TestEditLinkHandler(t *testing.T) {
+	db := &mocks.Service{
+		GetShortUrlByEditTokenFunc: func(shortCode string, tokenHash string) (*database.ShortUrlModel, error) {
+			if tokenHash != auth.HashToken("secret") {
+				return nil, database.ErrNotFound
+			}
+			return &database.ShortUrlModel{Id: 1, ShortCode: shortCode, Link: "https://example.com/", ExpTimeMinutes: 60}, nil
+		},
+		EditShortUrlFunc: func(shortCode string, link string, expTimeMinutes int, review *database.ReviewModel) (*database.ShortUrlModel, error) {
+			return &database.ShortUrlModel{Id: 1, ShortCode: shortCode, Link: link, ExpTimeMinutes: expTimeMinutes}, nil
+		},
+	}
+	s := &Server{db: db, domainCache: cache.NewLRU[*database.DomainModel](10, time.Minute)}
+	s.initCaches()
+	s.shortener = shortener.New(db, s.infoCache)
+
+	tests := []struct {
+		name         string
+		token        string
+		body         string
+		expectedCode int
+		expectedLink string
+		expectedExp  int
+	}{
+		{name: "expiry", token: "secret", body: `{"exp_time_minutes": 120}`, expectedCode: http.StatusOK, expectedLink: "https://example.com/", expectedExp: 120},
+		{name: "destination", token: "secret", body: `{"link_to_short": "https://example.org/fixed"}`, expectedCode: http.StatusOK, expectedLink: "https://example.org/fixed", expectedExp: 60},
+		{name: "invalid destination", token: "secret", body: `{"link_to_short": "ftp://example.org"}`, expectedCode: http.StatusBadRequest},
+		{name: "wrong token", token: "guess", body: `{"exp_time_minutes": 120}`, expectedCode: http.StatusForbidden},
+		{name: "no token", body: `{"exp_time_minutes": 120}`, expectedCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		previous := len(db.CallsTo("EditShortUrl"))
+		req := httptest.NewRequest(http.MethodPatch, "/short/abc", strings.NewReader(tt.body))
+		req.SetPathValue("short_code", "abc")
+		if tt.token != "" {
+			req.Header.Set(editTokenHeader, tt.token)
+		}
+		rec := httptest.NewRecorder()
+		s.editLinkHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expectedCode, rec.Code, rec.Body)
+			continue
+		}
+		edits := db.CallsTo("EditShortUrl")[previous:]
+		if tt.expectedCode != http.StatusOK {
+			if len(edits) != 0 {
+				t.Errorf("%s: expected the link left alone; got %+v", tt.name, edits)
+			}
+			continue
+		}
+		if len(edits) != 1 || edits[0].Args[1] != tt.expectedLink || edits[0].Args[2] != tt.expectedExp {
+			t.Errorf("%s: expected the link edited to %s expiring after %d minutes; got %+v", tt.name, tt.expectedLink, tt.expectedExp, edits)
+		}
+	}
+}
+
+func TestDeleteLinkHandler(t *testing.T) {
+	db := &mocks.Service{
+		GetShortUrlByEditTokenFunc: func(shortCode string, tokenHash string) (*database.ShortUrlModel, error) {
+			if tokenHash != auth.HashToken("secret") {
+				return nil, database.ErrNotFound
+			}
+			return &database.ShortUrlModel{Id: 1, ShortCode: shortCode}, nil
+		},
+	}
+	s := &Server{db: db}
+	s.initCaches()
+	s.shortener = shortener.New(db, s.infoCache)
+
+	for _, tt := range []struct {
+		token    string
+		expected int
+	}{
+		{token: "guess", expected: http.StatusForbidden},
+		{token: "secret", expected: http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(http.MethodDelete, "/short/abc", nil)
+		req.SetPathValue("short_code", "abc")
+		req.Header.Set(editTokenHeader, tt.token)
+		rec := httptest.NewRecorder()
+		s.deleteLinkHandler(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d; got %d %s", tt.token, tt.expected, rec.Code, rec.Body)
+		}
+	}
+
+	if deleted := db.CallsTo("DeleteShortUrl"); len(deleted) != 1 || deleted[0].Args[0] != "abc" {
+		t.Errorf("expected only the link of the right token deleted; got %+v", deleted)
+	}
+}
+
+func TestLinkStatsEditToken(t *testing.T) {
+	ownerId := 7
+	db := &mocks.Service{
+		GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+			if shortCode == "owned" {
+				return &database.ShortUrlModel{Id: 2, ShortCode: shortCode, OwnerId: &ownerId}, nil
+			}
+			return &database.ShortUrlModel{Id: 1, ShortCode: shortCode}, nil
+		},
+		GetShortUrlByEditTokenFunc: func(shortCode string, tokenHash string) (*database.ShortUrlModel, error) {
+			if shortCode != "abc" || tokenHash != auth.HashToken("secret") {
+				return nil, database.ErrNotFound
+			}
+			return &database.ShortUrlModel{Id: 1, ShortCode: shortCode}, nil
+		},
+		GetLinkStatsFunc: func(shortCode string, days int) (*database.LinkStatsModel, error) {
+			return &database.LinkStatsModel{TotalClicks: 4}, nil
+		},
+	}
+	s := &Server{db: db}
+	s.initCaches()
+	s.shortener = shortener.New(db, s.infoCache)
+
+	for _, tt := range []struct {
+		name      string
+		shortCode string
+		token     string
+		expected  int
+	}{
+		{name: "anonymous without token", shortCode: "abc", expected: http.StatusForbidden},
+		{name: "anonymous with a wrong token", shortCode: "abc", token: "guess", expected: http.StatusForbidden},
+		{name: "anonymous with its token", shortCode: "abc", token: "secret", expected: http.StatusOK},
+		{name: "owned", shortCode: "owned", expected: http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/short/"+tt.shortCode+"/stats", nil)
+		req.SetPathValue("short_code", tt.shortCode)
+		if tt.token != "" {
+			req.Header.Set(editTokenHeader, tt.token)
+		}
+		rec := httptest.NewRecorder()
+		s.linkStatsHandler(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expected, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/bulkexport"
+	"url-shortner/internal/database"
+)
+
+// Days of daily clicks exported for each link
+const exportStatsDays = 365
+
+type exportPayload struct {
+	UserId  int    `json:"user_id"`
+	BaseUrl string `json:"base_url"`
+}
+
+// exportResult is the result of an export job
+type exportResult struct {
+	DownloadUrl string `json:"download_url"`
+	Bytes       int    `json:"bytes"`
+	Links       int    `json:"links"`
+	ClickEvents int    `json:"click_events"`
+}
+
+// exportArchiveHandler queues the export of the account as a zip archive, downloaded from the download_url of the
+// job once it is done. Unlike exportAccountHandler it holds the stats of the links, and links.csv can be imported
+// back with importLinksHandler
+func (s *Server) exportArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	payload, err := json.Marshal(exportPayload{UserId: user.Id, BaseUrl: baseUrlOf(r, r.Host)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not export account data.")
+		return
+	}
+
+	job, err := s.db.EnqueueJob(&database.JobModel{Kind: jobExport, Payload: payload, UserId: &user.Id})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not export account data.")
+		return
+	}
+
+	s.audit(r, "account.export", "user", user.Id, nil, toJobResponse(job))
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status int         `json:"status"`
+		Job    jobResponse `json:"job"`
+	}{
+		Status: http.StatusAccepted,
+		Job:    toJobResponse(job),
+	})
+}
+
+// runExportJob writes the archive queued by exportArchiveHandler and attaches it to the job
+func (s *Server) runExportJob(ctx context.Context, job *database.JobModel) (any, error) {
+	var decoded exportPayload
+	if err := json.Unmarshal(job.Payload, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid export payload: %w", err)
+	}
+
+	var archive bytes.Buffer
+	result, err := s.writeArchive(&archive, decoded.UserId, decoded.BaseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveJobFile(ctx, job, archive.Bytes()); err != nil {
+		return nil, err
+	}
+
+	result.DownloadUrl = decoded.BaseUrl + downloadPath(job.Id)
+	result.Bytes = archive.Len()
+
+	return result, nil
+}
+
+// writeArchive writes everything stored about the user, with the stats of their links, as a zip to w
+func (s *Server) writeArchive(w io.Writer, userId int, baseUrl string) (*exportResult, error) {
+	export, err := s.db.ExportUserData(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]bulkexport.Link, 0, len(export.Links))
+	for _, entity := range export.Links {
+		// An archive without the stats of a link beats no archive
+		stats, err := s.db.GetLinkStats(entity.ShortCode, exportStatsDays)
+		if err != nil {
+			log.Printf("[exports:writeArchive] Could not load the stats of {%s}: %v", entity.ShortCode, err)
+		}
+
+		links = append(links, bulkexport.Link{ShortUrlModel: entity, ShortUrl: baseUrl + shortPath(entity.ShortCode), Stats: stats})
+	}
+
+	archive := bulkexport.Archive{Account: toAccountExport(export), Links: links, Clicks: export.ClickEvents}
+	if err := bulkexport.Write(w, archive); err != nil {
+		return nil, fmt.Errorf("write export of user %d: %w", userId, err)
+	}
+
+	log.Printf("[exports:writeArchive] Exported {%d} links and {%d} click events of user {%d}", len(links), len(export.ClickEvents), userId)
+
+	return &exportResult{Links: len(links), ClickEvents: len(export.ClickEvents)}, nil
+}
+
+// Export writes the archive of the account of the user with the email to w, as exportArchiveHandler would. It backs
+// the export command of the api, see cmd/api
+func Export(w io.Writer, email string, baseUrl string) error {
+	s, err := newCommandServer()
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.writeArchive(w, user.Id, baseUrl)
+	return err
+}
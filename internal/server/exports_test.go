@@ -0,0 +1,102 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/blobstore"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestRunExportJob(t *testing.T) {
+	var saved []byte
+	db := &mocks.Service{
+		ExportUserDataFunc: func(userId int) (*database.UserExportModel, error) {
+			return &database.UserExportModel{
+				User:  &database.UserModel{Id: userId, Email: "me@example.com"},
+				Links: []*database.ShortUrlModel{{Link: "https://example.com", ShortCode: "abcde", ExpTimeMinutes: 60, CreatedAt: time.Now()}},
+			}, nil
+		},
+		GetLinkStatsFunc: func(shortCode string, days int) (*database.LinkStatsModel, error) {
+			return &database.LinkStatsModel{ShortCode: shortCode, TotalClicks: 3}, nil
+		},
+		SaveJobFileFunc: func(id int, file []byte) error {
+			saved = file
+			return nil
+		},
+	}
+	s := &Server{db: db}
+
+	payload, _ := json.Marshal(exportPayload{UserId: 1, BaseUrl: "https://sho.rt"})
+	result, err := s.runExportJob(context.Background(), &database.JobModel{Id: 9, Kind: jobExport, Payload: payload})
+	if err != nil {
+		t.Fatalf("expected the export to run; got %v", err)
+	}
+
+	expected := &exportResult{DownloadUrl: "https://sho.rt/api/v1/jobs/9/download", Bytes: len(saved), Links: 1}
+	if *result.(*exportResult) != *expected {
+		t.Errorf("expected %+v; got %+v", expected, result)
+	}
+
+	if _, err := zip.NewReader(bytes.NewReader(saved), int64(len(saved))); err != nil {
+		t.Errorf("expected the saved file to be a zip; got %v", err)
+	}
+}
+
+func TestExportArchiveInBlobstore(t *testing.T) {
+	t.Setenv("BLOBSTORE", "disk")
+	t.Setenv("BLOBSTORE_DIR", t.TempDir())
+	blobs, err := blobstore.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userId := 1
+	var key string
+	db := &mocks.Service{
+		ExportUserDataFunc: func(userId int) (*database.UserExportModel, error) {
+			return &database.UserExportModel{User: &database.UserModel{Id: userId, Email: "me@example.com"}}, nil
+		},
+		SaveJobFileKeyFunc: func(id int, saved string) error {
+			key = saved
+			return nil
+		},
+		GetJobFunc: func(id int) (*database.JobModel, error) {
+			return &database.JobModel{Id: id, Kind: jobExport, Status: database.JobDone, UserId: &userId}, nil
+		},
+		GetJobFileKeyFunc: func(id int) (string, error) {
+			return key, nil
+		},
+	}
+	s := &Server{db: db, blobs: blobs, tenant: "acme"}
+
+	payload, _ := json.Marshal(exportPayload{UserId: userId, BaseUrl: "https://sho.rt"})
+	result, err := s.runExportJob(context.Background(), &database.JobModel{Id: 9, Kind: jobExport, Payload: payload})
+	if err != nil {
+		t.Fatalf("expected the export to run; got %v", err)
+	}
+	if key != "jobs/acme/9/export.zip" || len(db.CallsTo("SaveJobFile")) != 0 {
+		t.Fatalf("expected the archive kept in the blobstore under the tenant; got key %q", key)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/9/download", nil)
+	req.SetPathValue("job_id", "9")
+	req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: userId}))
+	rec := httptest.NewRecorder()
+	s.downloadJobHandler(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.Len() != result.(*exportResult).Bytes {
+		t.Fatalf("expected the archive downloaded from the blobstore; got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if _, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len())); err != nil {
+		t.Errorf("expected a zip; got %v", err)
+	}
+}
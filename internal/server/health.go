@@ -0,0 +1,163 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/pagination"
+	"url-shortner/migrations"
+)
+
+type componentHealthResponse struct {
+	Status  string            `json:"status"`
+	Error   string            `json:"error,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+type healthResponse struct {
+	Status     string                              `json:"status"`
+	Message    string                              `json:"message"`
+	Components map[string]*componentHealthResponse `json:"components"`
+}
+
+func toHealthResponse(health *database.HealthModel) *healthResponse {
+	response := &healthResponse{
+		Status:     health.Status,
+		Message:    health.Message,
+		Components: make(map[string]*componentHealthResponse, len(health.Components)),
+	}
+
+	for name, component := range health.Components {
+		response.Components[name] = &componentHealthResponse{
+			Status:  component.Status,
+			Error:   component.Error,
+			Details: component.Details,
+		}
+	}
+
+	return response
+}
+
+// healthHandler answers 503 only when the database is down, a degraded database still serves traffic
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	health := s.db.Health()
+
+	status := http.StatusOK
+	if health.Status == database.HealthDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	response := toHealthResponse(health)
+	if s.limiter != nil {
+		response.Components["ratelimit"] = s.rateLimitHealth()
+	}
+	if health.Status != database.HealthDown {
+		if cron := s.cronHealth(); cron != nil {
+			response.Components["cron"] = cron
+		}
+	}
+
+	writeJSON(w, status, response)
+}
+
+// Number of recent links loaded into the info cache before the server reports ready
+const warmCacheSize = 1000
+
+// warmCache preloads the most recently created links so the first redirects after a start don't all hit the database
+func (s *Server) warmCache() {
+	links, err := s.db.ListShortUrls(pagination.Page{Limit: warmCacheSize})
+	if err != nil {
+		log.Printf("[health:warmCache] Could not warm the cache: %v", err)
+		return
+	}
+
+	for _, link := range links {
+		s.infoCache.Set(link.ShortCode, link)
+	}
+
+	s.cacheWarm.Store(true)
+	log.Printf("[health:warmCache] Cached {%d} links", len(links))
+}
+
+// livezHandler only tells the process is serving requests, it never looks at dependencies
+func (s *Server) livezHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  http.StatusOK,
+		Message: "alive",
+	})
+}
+
+// readyzHandler answers 200 once the database is reachable, every migration is applied and the cache is warm
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{}
+	ready := true
+
+	if health := s.db.Health(); health.Status == database.HealthDown && maintenance.Enabled() && s.cacheWarm.Load() {
+		// Redirects are served from the cache during the maintenance, the database is expected to go away
+		checks["database"] = "maintenance"
+	} else if health.Status == database.HealthDown {
+		checks["database"] = health.Message
+		ready = false
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if !ready || checks["database"] == "maintenance" {
+		checks["migrations"] = "skipped"
+	} else if err := checkSchema(s.db); err != nil {
+		checks["migrations"] = err.Error()
+		ready = false
+	} else {
+		checks["migrations"] = "ok"
+	}
+
+	if !s.cacheWarm.Load() && ready {
+		s.warmCache()
+	}
+	if s.cacheWarm.Load() {
+		checks["cache"] = "ok"
+	} else {
+		checks["cache"] = "not warm"
+		ready = false
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, struct {
+		Status int               `json:"status"`
+		Ready  bool              `json:"ready"`
+		Checks map[string]string `json:"checks"`
+	}{
+		Status: status,
+		Ready:  ready,
+		Checks: checks,
+	})
+}
+
+// checkSchema fails when a migration bundled with this build hasn't been applied to the database yet
+func checkSchema(db database.Service) error {
+	expected, err := migrations.LatestVersion()
+	if err != nil {
+		return fmt.Errorf("could not read the bundled migrations: %w", err)
+	}
+
+	applied, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("could not read the schema version: %w", err)
+	}
+
+	if applied < expected {
+		return fmt.Errorf("schema at version %d, expected %d", applied, expected)
+	}
+
+	return nil
+}
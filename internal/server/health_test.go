@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/pagination"
+	"url-shortner/migrations"
+)
+
+func healthDB(status string, version int64) *mocks.Service {
+	return &mocks.Service{
+		HealthFunc: func() *database.HealthModel {
+			return &database.HealthModel{
+				Status:     status,
+				Components: map[string]*database.ComponentHealthModel{"primary": {Status: status}},
+			}
+		},
+		SchemaVersionFunc: func() (int64, error) {
+			return version, nil
+		},
+		ListShortUrlsFunc: func(page pagination.Page) ([]*database.ShortUrlModel, error) {
+			return []*database.ShortUrlModel{{ShortCode: "abcdefgh"}}, nil
+		},
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		status       string
+		expectedCode int
+	}{
+		{database.HealthUp, http.StatusOK},
+		{database.HealthDegraded, http.StatusOK},
+		{database.HealthDown, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		s := &Server{db: healthDB(tt.status, 0)}
+
+		rec := httptest.NewRecorder()
+		s.healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("status %s: expected %d; got %d", tt.status, tt.expectedCode, rec.Code)
+		}
+
+		var body healthResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("status %s: could not decode body: %v", tt.status, err)
+		}
+		if body.Status != tt.status || body.Components["primary"] == nil {
+			t.Errorf("status %s: unexpected body %+v", tt.status, body)
+		}
+	}
+}
+
+func TestLivezHandler(t *testing.T) {
+	s := &Server{}
+
+	rec := httptest.NewRecorder()
+	s.livezHandler(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected %d; got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	latest, err := migrations.LatestVersion()
+	if err != nil {
+		t.Fatalf("could not read the migrations: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		status       string
+		version      int64
+		expectedCode int
+	}{
+		{"ready", database.HealthUp, latest, http.StatusOK},
+		{"degraded database", database.HealthDegraded, latest, http.StatusOK},
+		{"database down", database.HealthDown, latest, http.StatusServiceUnavailable},
+		{"pending migrations", database.HealthUp, latest - 1, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		s := &Server{
+			db:        healthDB(tt.status, tt.version),
+			infoCache: cache.NewLRU[*database.ShortUrlModel](10, time.Minute),
+		}
+
+		rec := httptest.NewRecorder()
+		s.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d: %s", tt.name, tt.expectedCode, rec.Code, rec.Body.String())
+		}
+
+		if _, ok := s.infoCache.Get("abcdefgh"); ok != (tt.expectedCode == http.StatusOK) {
+			t.Errorf("%s: expected the cache to be warmed only when ready", tt.name)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/bulkimport"
+	"url-shortner/internal/database"
+	"url-shortner/internal/policy"
+	"url-shortner/internal/shortener"
+)
+
+const (
+	// Files with more rows are imported in the background, see runImportJob
+	syncImportRows = 100
+
+	// Largest file accepted by importLinksHandler
+	maxImportBytes = 10 << 20
+)
+
+// importPayload is everything needed to import a file, queued as is for large files
+type importPayload struct {
+	Creator shortener.Creator     `json:"creator"`
+	BaseUrl string                `json:"base_url"`
+	Rows    []bulkimport.Row      `json:"rows"`
+	Errors  []bulkimport.RowError `json:"errors"`
+}
+
+// importLinksHandler creates links in bulk from a CSV file uploaded as the "file" field of a multipart form. Small
+// files are imported right away, larger ones are queued and their report is read from /api/v1/jobs/{job_id}
+func (s *Server) importLinksHandler(w http.ResponseWriter, r *http.Request) {
+	creator := creatorOf(r)
+	if creator.UserId == nil {
+		writeError(w, http.StatusUnauthorized, "Sign in or use an api key to import links.")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Upload the CSV file as the file field of a multipart form, up to 10MB.")
+		return
+	}
+	defer file.Close()
+
+	if value := r.FormValue("organization_id"); value != "" {
+		organizationId, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid organization id.")
+			return
+		}
+		creator.OrganizationId = &organizationId
+
+		// Every row would fail the same way
+		member, err := s.db.GetMembership(organizationId, *creator.UserId)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusForbidden, "Only members of the organization can create links for it.")
+			return
+		}
+		if err != nil {
+			writeError(w, statusOf(err), "Could not import the links. Try again later")
+			return
+		}
+		if !auth.OrgRoleAllows(member.Role, database.OrgRoleEditor) {
+			writeError(w, http.StatusForbidden, "The editor role in the organization is required to create links for it.")
+			return
+		}
+	}
+
+	rows, rowErrors, err := bulkimport.Parse(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	payload := importPayload{Creator: creator, BaseUrl: baseUrlOf(r, r.Host), Rows: rows, Errors: rowErrors}
+
+	if len(rows) <= syncImportRows {
+		result := s.importLinks(r.Context(), payload)
+		s.audit(r, "link.import", "link", "", nil, struct {
+			Rows    int `json:"rows"`
+			Created int `json:"created"`
+		}{result.Rows, len(result.Created)})
+
+		writeJSON(w, http.StatusOK, struct {
+			Status int                `json:"status"`
+			Result *bulkimport.Result `json:"result"`
+		}{
+			Status: http.StatusOK,
+			Result: result,
+		})
+		return
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not import the links. Try again later")
+		return
+	}
+
+	job, err := s.db.EnqueueJob(&database.JobModel{Kind: jobImport, Payload: encoded, UserId: creator.UserId})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not import the links. Try again later")
+		return
+	}
+
+	log.Printf("[imports:importLinksHandler] Queued the import of {%d} rows as job {%d}", len(rows), job.Id)
+	s.audit(r, "job.create", "job", job.Id, nil, toJobResponse(job))
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status int         `json:"status"`
+		Job    jobResponse `json:"job"`
+	}{
+		Status: http.StatusAccepted,
+		Job:    toJobResponse(job),
+	})
+}
+
+// runImportJob imports a file queued by importLinksHandler
+func (s *Server) runImportJob(ctx context.Context, job *database.JobModel) (any, error) {
+	var decoded importPayload
+	if err := json.Unmarshal(job.Payload, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid import payload: %w", err)
+	}
+
+	return s.importLinks(ctx, decoded), nil
+}
+
+// importLinks creates the links of the rows like shortLinkHandler would, saving them in bulk, and reports how every
+// row went
+func (s *Server) importLinks(ctx context.Context, payload importPayload) *bulkimport.Result {
+	result := bulkimport.NewResult(len(payload.Rows)+len(payload.Errors), payload.Errors)
+	namespaces := map[string]*database.NamespaceModel{}
+
+	var vetted []bulkimport.Row
+	var links []shortener.Link
+	for _, row := range payload.Rows {
+		link, err := s.vetRow(ctx, row, policy.HostOf(payload.BaseUrl), namespaces)
+		if err != nil {
+			result.Errors = append(result.Errors, bulkimport.RowError{Line: row.Line, Error: err.Error()})
+			continue
+		}
+		vetted = append(vetted, row)
+		links = append(links, link)
+	}
+
+	entities, errs, err := s.shortener.ShortenBatch(links, payload.Creator)
+	if err != nil {
+		// Every row fails the same way, e.g. the creator left the organization since the import was queued
+		errs = make([]error, len(links))
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+
+	for i, row := range vetted {
+		if errs[i] != nil {
+			result.Errors = append(result.Errors, bulkimport.RowError{Line: row.Line, Error: importError(row, errs[i]).Error()})
+			continue
+		}
+
+		result.Created = append(result.Created, bulkimport.Created{
+			Line:      row.Line,
+			ShortCode: entities[i].ShortCode,
+			ShortUrl:  payload.BaseUrl + shortPath(entities[i].ShortCode),
+		})
+	}
+
+	sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+
+	log.Printf("[imports:importLinks] Imported {%d} of {%d} rows", len(result.Created), result.Rows)
+
+	return result
+}
+
+// vetRow checks the destination of a row against the policies of the instance and returns the link to create for
+// it. host is the host of the short urls of the import. Namespaces are looked up once per import
+func (s *Server) vetRow(ctx context.Context, row bulkimport.Row, host string, namespaces map[string]*database.NamespaceModel) (shortener.Link, error) {
+	if err := shortener.Validate(row.Link, row.ExpTimeMinutes); err != nil {
+		return shortener.Link{}, err
+	}
+
+	link, err := shortener.NormalizeLink(row.Link)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+
+	if link, err = s.unwrapDestination(ctx, host, link); err != nil {
+		return shortener.Link{}, err
+	}
+
+	assessment, refusal := s.vetDestination(ctx, host, link)
+	if refusal != nil {
+		return shortener.Link{}, errors.New(refusal.message)
+	}
+
+	options := shortener.Options{Code: row.Code, Review: reviewOf(assessment)}
+	if row.Namespace != "" {
+		namespace, ok := namespaces[row.Namespace]
+		if !ok {
+			namespace, err = s.db.GetNamespaceByName(row.Namespace)
+			if err != nil && !errors.Is(err, database.ErrNotFound) {
+				return shortener.Link{}, errors.New("could not look up the namespace")
+			}
+			namespaces[row.Namespace] = namespace
+		}
+		if namespace == nil {
+			return shortener.Link{}, fmt.Errorf("unknown namespace %q", row.Namespace)
+		}
+		options.Namespace = namespace
+	}
+
+	return shortener.Link{Link: link, ExpTimeMinutes: row.ExpTimeMinutes, Options: options}, nil
+}
+
+// importError is what the report says of a row that could not be created, without the details of server errors
+func importError(row bulkimport.Row, err error) error {
+	if errors.Is(err, database.ErrDuplicateCode) && row.Code != "" {
+		return fmt.Errorf("the code %q is already taken", row.Code)
+	}
+	if statusOf(err) == http.StatusInternalServerError {
+		log.Printf("[imports:importError] Could not shorten the link of line {%d}: %v", row.Line, err)
+		return errors.New("could not create the link")
+	}
+	return err
+}
+
+// Import creates the links of a CSV file for the user with the email, whatever the size of the file, and reports
+// how every row went. It backs the import command of the api, see cmd/api
+func Import(file io.Reader, email string, organizationId *int, baseUrl string) (*bulkimport.Result, error) {
+	s, err := newCommandServer()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	creator := shortener.Creator{UserId: &user.Id, Plan: user.Plan, EmailVerified: user.EmailVerifiedAt != nil, OrganizationId: organizationId}
+
+	rows, rowErrors, err := bulkimport.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.importLinks(context.Background(), importPayload{Creator: creator, BaseUrl: baseUrl, Rows: rows, Errors: rowErrors}), nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/bulkimport"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/policy"
+	"url-shortner/internal/shortener"
+)
+
+func TestImportLinksHandler(t *testing.T) {
+	verified := time.Now()
+	tests := []struct {
+		name            string
+		csv             string
+		expectedCode    int
+		expectedCreated int
+		expectedErrors  []bulkimport.RowError
+		expectedJobs    int
+	}{
+		{
+			name:            "small file",
+			csv:             "long_url,custom_bitlinks\nhttps://example.com/a,\nnot a link,\nhttps://example.com/b,bit.ly/taken\nhttps://banned.example/c,\nhttps://sho.rt/short/a,\n",
+			expectedCode:    http.StatusOK,
+			expectedCreated: 1,
+			expectedErrors: []bulkimport.RowError{
+				{Line: 3, Error: shortener.ErrInvalidLink.Error()},
+				{Line: 4, Error: `the code "taken" is already taken`},
+				{Line: 5, Error: "The destination domain is not allowed."},
+				{Line: 6, Error: "Links can't point back at this instance, they would redirect in a loop."},
+			},
+		},
+		{
+			name:         "large file",
+			csv:          "link_to_short\n" + string(bytes.Repeat([]byte("https://example.com\n"), syncImportRows+1)),
+			expectedCode: http.StatusAccepted,
+			expectedJobs: 1,
+		},
+		{name: "no link column", csv: "title\nhello\n", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		jobs := 0
+		db := &mocks.Service{
+			ListBannedDomainsFunc: func() ([]*database.BannedDomainModel, error) {
+				return []*database.BannedDomainModel{{Pattern: "banned.example"}}, nil
+			},
+			SaveShortUrlsFunc: func(shortUrls []*database.ShortUrlModel) ([]*database.ShortUrlModel, error) {
+				var inserted []*database.ShortUrlModel
+				for _, shortUrl := range shortUrls {
+					if shortUrl.ShortCode != "taken" {
+						inserted = append(inserted, shortUrl)
+					}
+				}
+				return inserted, nil
+			},
+			EnqueueJobFunc: func(job *database.JobModel) (*database.JobModel, error) {
+				jobs++
+				job.Id = 1
+				return job, nil
+			},
+		}
+		s := &Server{db: db, shortener: shortener.New(db, nil), domainCache: cache.NewLRU[*database.DomainModel](10, time.Minute)}
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "links.csv")
+		part.Write([]byte(tt.csv))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/short/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Host = "sho.rt"
+		req = req.WithContext(auth.WithUser(context.Background(), &database.UserModel{Id: 1, Role: auth.RoleEditor, Plan: plans.Pro, EmailVerifiedAt: &verified}))
+		rec := httptest.NewRecorder()
+		s.importLinksHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d: %s", tt.name, tt.expectedCode, rec.Code, rec.Body)
+			continue
+		}
+		if jobs != tt.expectedJobs {
+			t.Errorf("%s: expected %d queued jobs; got %d", tt.name, tt.expectedJobs, jobs)
+		}
+
+		if rec.Code == http.StatusOK {
+			var response struct {
+				Result bulkimport.Result `json:"result"`
+			}
+			json.NewDecoder(rec.Body).Decode(&response)
+			if len(response.Result.Created) != tt.expectedCreated {
+				t.Errorf("%s: expected %d links created; got %+v", tt.name, tt.expectedCreated, response.Result.Created)
+			}
+			if len(response.Result.Errors) != len(tt.expectedErrors) {
+				t.Errorf("%s: expected errors %+v; got %+v", tt.name, tt.expectedErrors, response.Result.Errors)
+				continue
+			}
+			for i, expected := range tt.expectedErrors {
+				if response.Result.Errors[i] != expected {
+					t.Errorf("%s: expected error %+v; got %+v", tt.name, expected, response.Result.Errors[i])
+				}
+			}
+		}
+	}
+}
+
+func TestImportLinksForReview(t *testing.T) {
+	verified := time.Now()
+	var saved []*database.ShortUrlModel
+	db := &mocks.Service{
+		SaveShortUrlsFunc: func(shortUrls []*database.ShortUrlModel) ([]*database.ShortUrlModel, error) {
+			saved = shortUrls
+			return shortUrls, nil
+		},
+	}
+	s := &Server{db: db, shortener: shortener.New(db, nil), domainCache: cache.NewLRU[*database.DomainModel](10, time.Minute)}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "links.csv")
+	part.Write([]byte("link_to_short\nhttps://example.com/a\nhttps://paypal.com@evil.io/\n"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/short/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Host = "sho.rt"
+	req = req.WithContext(auth.WithUser(context.Background(), &database.UserModel{Id: 1, Role: auth.RoleEditor, Plan: plans.Pro, EmailVerifiedAt: &verified}))
+	rec := httptest.NewRecorder()
+	s.importLinksHandler(rec, req)
+
+	if rec.Code != http.StatusOK || len(saved) != 2 {
+		t.Fatalf("expected both links saved; got %d %s", rec.Code, rec.Body)
+	}
+	if saved[0].Review != nil || saved[1].Review == nil || saved[1].Review.Score < policy.DefaultReviewScore {
+		t.Errorf("expected only the suspicious link saved with its review; got %+v, %+v", saved[0].Review, saved[1].Review)
+	}
+	if queued := db.CallsTo("QueueForReview"); len(queued) != 0 {
+		t.Errorf("expected the review saved along with the link, not queued afterwards; got %+v", queued)
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+
+	"url-shortner/internal/metrics"
+)
+
+// watchLinkChanges keeps the caches in step with the links changed by every replica, until ctx is done. Changes that
+// may have gone unnoticed drop every cached link
+func (s *Server) watchLinkChanges(ctx context.Context) {
+	s.db.WatchLinkChanges(ctx, s.forgetLink, s.forgetLinks)
+}
+
+// forgetLink drops what is cached about the link of shortCode, once it was updated, disabled or deleted, so the
+// change takes effect without waiting for the caches to expire
+func (s *Server) forgetLink(shortCode string) {
+	metrics.Count("cache.invalidations", 1, "scope:link")
+
+	s.infoCache.Delete(shortCode)
+	s.statsCache.Delete(shortCode)
+	s.summaryCache.Delete(summaryCacheKey)
+	s.shortener.Forget(shortCode)
+}
+
+// forgetLinks drops every cached link, for changes touching links the server can't name, e.g. those of a deleted user
+func (s *Server) forgetLinks() {
+	metrics.Count("cache.invalidations", 1, "scope:all")
+
+	s.infoCache.Clear()
+	s.statsCache.Clear()
+	s.summaryCache.Clear()
+	s.shortener.ForgetAll()
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/shortener"
+)
+
+func TestForgetLink(t *testing.T) {
+	db := &mocks.Service{}
+	s := &Server{db: db}
+	s.initCaches()
+	s.shortener = shortener.New(db, s.infoCache)
+
+	for _, code := range []string{"abc", "def"} {
+		s.infoCache.Set(code, &database.ShortUrlModel{ShortCode: code})
+		s.statsCache.Set(code, &database.LinkStatsModel{})
+	}
+	s.summaryCache.Set(summaryCacheKey, &database.LinkSummaryModel{})
+
+	s.forgetLink("abc")
+
+	if _, ok := s.infoCache.Get("abc"); ok {
+		t.Errorf("expected the changed link dropped from the info cache")
+	}
+	if _, ok := s.statsCache.Get("abc"); ok {
+		t.Errorf("expected the changed link dropped from the stats cache")
+	}
+	if _, ok := s.summaryCache.Get(summaryCacheKey); ok {
+		t.Errorf("expected the summary dropped")
+	}
+	if _, ok := s.infoCache.Get("def"); !ok {
+		t.Errorf("expected the other links kept")
+	}
+
+	s.forgetLinks()
+
+	if s.infoCache.Len() != 0 || s.statsCache.Len() != 0 {
+		t.Errorf("expected every link dropped; got %d and %d left", s.infoCache.Len(), s.statsCache.Len())
+	}
+}
+
+func TestWatchLinkChanges(t *testing.T) {
+	db := &mocks.Service{}
+	s := &Server{db: db}
+	s.initCaches()
+	s.shortener = shortener.New(db, s.infoCache)
+
+	db.WatchLinkChangesFunc = func(ctx context.Context, changed func(string), missed func()) {
+		for _, code := range []string{"abc", "def", "ghi"} {
+			s.infoCache.Set(code, &database.ShortUrlModel{ShortCode: code})
+		}
+
+		changed("abc")
+		if _, ok := s.infoCache.Get("abc"); ok {
+			t.Errorf("expected the changed link dropped")
+		}
+		if _, ok := s.infoCache.Get("def"); !ok {
+			t.Errorf("expected the other links kept")
+		}
+
+		missed()
+		if s.infoCache.Len() != 0 {
+			t.Errorf("expected every link dropped once changes were missed; got %d left", s.infoCache.Len())
+		}
+	}
+
+	s.watchLinkChanges(context.Background())
+
+	if len(db.CallsTo("WatchLinkChanges")) != 1 {
+		t.Errorf("expected the changes watched")
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/blobstore"
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+)
+
+const (
+	// How long an idle worker waits before looking for jobs again
+	jobPollInterval = 5 * time.Second
+
+	// Jobs running for longer are assumed to belong to a worker that died, and are run again
+	jobStaleAfter = time.Hour
+)
+
+// Kinds of jobs
+const (
+	jobImport = "import"
+	jobExport = "export"
+)
+
+// jobHandler runs a job from its payload and returns its result, stored as JSON
+type jobHandler func(ctx context.Context, job *database.JobModel) (any, error)
+
+func (s *Server) jobHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		jobImport: s.runImportJob,
+		jobExport: s.runExportJob,
+	}
+}
+
+// runJobs works through the job queue of the database of s until the process exits
+func (s *Server) runJobs() {
+	handlers := s.jobHandlers()
+	kinds := make([]string, 0, len(handlers))
+	for kind := range handlers {
+		kinds = append(kinds, kind)
+	}
+
+	for {
+		// Jobs write their results, they wait for the end of the maintenance
+		if maintenance.Enabled() || !s.runNextJob(handlers, kinds) {
+			time.Sleep(jobPollInterval)
+		}
+	}
+}
+
+// runNextJob claims and runs a single job. It returns false when none was waiting
+func (s *Server) runNextJob(handlers map[string]jobHandler, kinds []string) bool {
+	job, err := s.db.ClaimJob(kinds, jobStaleAfter)
+	if errors.Is(err, database.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[jobs:runNextJob] Could not claim a job: %v", err)
+		return false
+	}
+
+	var encoded []byte
+	jobErr := ""
+
+	result, err := handlers[job.Kind](context.Background(), job)
+	if err == nil {
+		encoded, err = json.Marshal(result)
+	}
+	if err != nil {
+		log.Printf("[jobs:runNextJob] {%s} job {%d} failed: %v", job.Kind, job.Id, err)
+		jobErr = err.Error()
+	}
+
+	if err := s.db.FinishJob(job.Id, encoded, jobErr); err != nil {
+		log.Printf("[jobs:runNextJob] Could not finish {%s} job {%d}: %v", job.Kind, job.Id, err)
+	}
+
+	return true
+}
+
+type jobResponse struct {
+	Id         int             `json:"id"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at"`
+}
+
+func toJobResponse(entity *database.JobModel) jobResponse {
+	return jobResponse{
+		Id:         entity.Id,
+		Kind:       entity.Kind,
+		Status:     entity.Status,
+		Result:     entity.Result,
+		Error:      entity.Error,
+		CreatedAt:  entity.CreatedAt,
+		StartedAt:  entity.StartedAt,
+		FinishedAt: entity.FinishedAt,
+	}
+}
+
+// jobOf loads the job of the request. Only the user who queued it and admins can see it, it answers 404 to others
+func (s *Server) jobOf(w http.ResponseWriter, r *http.Request) *database.JobModel {
+	jobId, err := strconv.Atoi(r.PathValue("job_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid job id.")
+		return nil
+	}
+
+	entity, err := s.db.GetJob(jobId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Job not found.")
+		return nil
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the job.")
+		return nil
+	}
+
+	user := auth.UserFromContext(r.Context())
+	isOwner := entity.UserId != nil && *entity.UserId == user.Id
+	if !isOwner && !(user.Role == auth.RoleAdmin && auth.HasScope(r.Context(), auth.ScopeAdmin)) {
+		writeError(w, http.StatusNotFound, "Job not found.")
+		return nil
+	}
+
+	return entity
+}
+
+// getJobHandler reports the progress of a job, and its result once it is done
+func (s *Server) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	entity := s.jobOf(w, r)
+	if entity == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int         `json:"status"`
+		Job    jobResponse `json:"job"`
+	}{
+		Status: http.StatusOK,
+		Job:    toJobResponse(entity),
+	})
+}
+
+// downloadJobHandler serves the file a job produced, e.g. the archive of an account export
+func (s *Server) downloadJobHandler(w http.ResponseWriter, r *http.Request) {
+	entity := s.jobOf(w, r)
+	if entity == nil {
+		return
+	}
+
+	if entity.Status != database.JobDone {
+		writeError(w, http.StatusConflict, "The job isn't done yet.")
+		return
+	}
+
+	if s.blobs != nil {
+		key, err := s.db.GetJobFileKey(entity.Id)
+		if err == nil {
+			s.serveJobBlob(w, r, entity, key)
+			return
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			writeError(w, statusOf(err), "Could not load the file.")
+			return
+		}
+		// Files saved before the blobstore was configured are still in the database
+	}
+
+	file, err := s.db.GetJobFile(entity.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "The job has no file to download.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the file.")
+		return
+	}
+
+	writeDownloadHeaders(w, entity, int64(len(file)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(file)
+}
+
+// serveJobBlob streams the file of a job from the blobstore
+func (s *Server) serveJobBlob(w http.ResponseWriter, r *http.Request, entity *database.JobModel, key string) {
+	blob, err := s.blobs.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "The job has no file to download.")
+		return
+	}
+	if err != nil {
+		log.Printf("[jobs:serveJobBlob] Could not read {%s}: %v", key, err)
+		writeError(w, http.StatusInternalServerError, "Could not load the file.")
+		return
+	}
+	defer blob.Close()
+
+	writeDownloadHeaders(w, entity, blob.Size)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, blob); err != nil {
+		log.Printf("[jobs:serveJobBlob] Could not send {%s}: %v", key, err)
+	}
+}
+
+// writeDownloadHeaders describes the file of a job as a zip attachment, of size bytes unless size is negative
+func writeDownloadHeaders(w http.ResponseWriter, entity *database.JobModel, size int64) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%d.zip"`, entity.Kind, entity.Id))
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+}
+
+// saveJobFile keeps the file a job produced in the blobstore when one is configured, in the database otherwise.
+// Blobs are keyed by tenant, as tenants share the blobstore
+func (s *Server) saveJobFile(ctx context.Context, job *database.JobModel, file []byte) error {
+	if s.blobs == nil {
+		return s.db.SaveJobFile(job.Id, file)
+	}
+
+	key := path.Join("jobs", s.tenant, strconv.Itoa(job.Id), job.Kind+".zip")
+	if err := s.blobs.Put(ctx, key, bytes.NewReader(file), int64(len(file)), "application/zip"); err != nil {
+		return err
+	}
+
+	return s.db.SaveJobFileKey(job.Id, key)
+}
+
+// downloadPath is where the file of a job is downloaded from, see downloadJobHandler
+func downloadPath(jobId int) string {
+	return fmt.Sprintf("/api/v1/jobs/%d/download", jobId)
+}
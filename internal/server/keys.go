@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) registerApiKeyRoutes(r chi.Router) {
+	r.With(requireScope(auth.ScopeStatsRead)).Get("/{key_id}/usage", s.apiKeyUsageHandler)
+}
+
+type dailyApiKeyUsageResponse struct {
+	Day          string `json:"day"`
+	Requests     int64  `json:"requests"`
+	LinksCreated int64  `json:"links_created"`
+}
+
+// canSeeApiKey allows the owner of the key, admins and the ADMIN_TOKEN
+func canSeeApiKey(r *http.Request, apiKey *database.ApiKeyModel) bool {
+	if isAdminToken(bearerToken(r)) {
+		return true
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return false
+	}
+
+	return user.Id == apiKey.UserId || (user.Role == auth.RoleAdmin && auth.HasScope(r.Context(), auth.ScopeAdmin))
+}
+
+// apiKeyUsageHandler reports the requests and link creations of an api key over the last days
+func (s *Server) apiKeyUsageHandler(w http.ResponseWriter, r *http.Request) {
+	keyId, err := strconv.Atoi(r.PathValue("key_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid api key id.")
+		return
+	}
+
+	if auth.UserFromContext(r.Context()) == nil && !isAdminToken(bearerToken(r)) {
+		writeError(w, http.StatusUnauthorized, "Authentication required.")
+		return
+	}
+
+	// Keys of other users are reported as missing, so their ids can't be probed
+	apiKey, err := s.db.GetApiKey(keyId)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !canSeeApiKey(r, apiKey)) {
+		writeError(w, http.StatusNotFound, "Api key not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the api key.")
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	entities, err := s.db.ListApiKeyUsage(apiKey.Id, today.AddDate(0, 0, -(statsDays-1)))
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the usage.")
+		return
+	}
+
+	var totalRequests, totalLinks int64
+	daily := make([]dailyApiKeyUsageResponse, 0, len(entities))
+	for _, entity := range entities {
+		totalRequests += entity.Requests
+		totalLinks += entity.LinksCreated
+		daily = append(daily, dailyApiKeyUsageResponse{
+			Day:          entity.Day.Format(time.DateOnly),
+			Requests:     entity.Requests,
+			LinksCreated: entity.LinksCreated,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status       int    `json:"status"`
+		ApiKeyId     int    `json:"api_key_id"`
+		Name         string `json:"name"`
+		Requests     int64  `json:"requests"`
+		LinksCreated int64  `json:"links_created"`
+		// 0 when unlimited
+		RequestsPerDayLimit int64                      `json:"requests_per_day_limit"`
+		Daily               []dailyApiKeyUsageResponse `json:"daily"`
+	}{
+		Status:              http.StatusOK,
+		ApiKeyId:            apiKey.Id,
+		Name:                apiKey.Name,
+		Requests:            totalRequests,
+		LinksCreated:        totalLinks,
+		RequestsPerDayLimit: apiKeyRequestsPerDay,
+		Daily:               daily,
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Longest a stats view may go without a refresh before its numbers are counted live, unless STATS_VIEW_MAX_AGE
+	// says otherwise. The views are refreshed every five minutes
+	defaultStatsViewMaxAge = 15 * time.Minute
+
+	defaultLeaderboardLimit = 20
+	maxLeaderboardLimit     = 100
+)
+
+var statsViewMaxAge = statsViewMaxAgeFromEnv()
+
+func statsViewMaxAgeFromEnv() time.Duration {
+	maxAge, err := time.ParseDuration(os.Getenv("STATS_VIEW_MAX_AGE"))
+	if err != nil || maxAge <= 0 {
+		return defaultStatsViewMaxAge
+	}
+	return maxAge
+}
+
+// leaderboardLimit reads the limit query parameter, falling back to the default when absent or invalid
+func leaderboardLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultLeaderboardLimit
+	}
+	return min(limit, maxLeaderboardLimit)
+}
+
+type domainStatsResponse struct {
+	Domain string `json:"domain"`
+	Links  int    `json:"links"`
+	Clicks int    `json:"clicks"`
+}
+
+type leaderboardEntryResponse struct {
+	ShortCode  string `json:"short_code"`
+	Link       string `json:"link"`
+	Visibility string `json:"visibility"`
+	Clicks     int    `json:"clicks"`
+}
+
+func (s *Server) adminDomainStatsHandler(w http.ResponseWriter, r *http.Request) {
+	entities, asOf, err := s.db.DomainStats(leaderboardLimit(r), statsViewMaxAge)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not collect the domain stats.")
+		return
+	}
+
+	domains := make([]domainStatsResponse, 0, len(entities))
+	for _, entity := range entities {
+		domains = append(domains, domainStatsResponse{Domain: entity.Domain, Links: entity.Links, Clicks: entity.Clicks})
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int                   `json:"status"`
+		AsOf    time.Time             `json:"as_of"`
+		Domains []domainStatsResponse `json:"domains"`
+	}{
+		Status:  http.StatusOK,
+		AsOf:    asOf,
+		Domains: domains,
+	})
+}
+
+func (s *Server) adminLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	entities, asOf, err := s.db.Leaderboard(leaderboardLimit(r), statsViewMaxAge)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not collect the leaderboard.")
+		return
+	}
+
+	links := make([]leaderboardEntryResponse, 0, len(entities))
+	for _, entity := range entities {
+		links = append(links, leaderboardEntryResponse{ShortCode: entity.ShortCode, Link: entity.Link, Visibility: entity.Visibility, Clicks: entity.Clicks})
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int                        `json:"status"`
+		AsOf   time.Time                  `json:"as_of"`
+		Links  []leaderboardEntryResponse `json:"links"`
+	}{
+		Status: http.StatusOK,
+		AsOf:   asOf,
+		Links:  links,
+	})
+}
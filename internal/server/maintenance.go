@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+)
+
+// The switch itself, which must keep working to end the maintenance
+const maintenancePath = "/api/v1/admin/maintenance"
+
+// refuseWritesInMaintenance answers 503 to anything but reads while maintenance is on
+func refuseWritesInMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenance.Enabled() || r.URL.Path == maintenancePath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			writeError(w, http.StatusServiceUnavailable, maintenance.Message())
+		}
+	})
+}
+
+type maintenanceResponse struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+func currentMaintenance() maintenanceResponse {
+	return maintenanceResponse{Enabled: maintenance.Enabled(), Message: maintenance.Message()}
+}
+
+func writeMaintenance(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, struct {
+		Status      int                 `json:"status"`
+		Maintenance maintenanceResponse `json:"maintenance"`
+	}{
+		Status:      http.StatusOK,
+		Maintenance: currentMaintenance(),
+	})
+}
+
+func (s *Server) adminGetMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	writeMaintenance(w)
+}
+
+// loadMaintenance reads the stored switch, for maintenance.Watch
+func (s *Server) loadMaintenance() (bool, string, error) {
+	switched, err := s.maintenanceStore.GetMaintenance()
+	if err != nil {
+		return false, "", err
+	}
+	return switched.Enabled, switched.Message, nil
+}
+
+// adminSetMaintenanceHandler switches maintenance on or off. The switch is stored, the other replicas and the
+// cronjobs follow it within maintenance.RefreshInterval
+func (s *Server) adminSetMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Enabled *bool  `json:"enabled"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.Enabled == nil {
+		writeError(w, http.StatusBadRequest, "Enabled must be true or false.")
+		return
+	}
+
+	before := currentMaintenance()
+	switched, err := s.maintenanceStore.SetMaintenance(&database.MaintenanceModel{Enabled: *reqBody.Enabled, Message: reqBody.Message})
+	if err != nil {
+		log.Printf("[maintenance:adminSetMaintenanceHandler] Could not store the maintenance switch: %v", err)
+		writeError(w, statusOf(err), "Could not store the maintenance switch, set MAINTENANCE_MODE on each process instead.")
+		return
+	}
+	maintenance.Set(switched.Enabled, switched.Message)
+	after := currentMaintenance()
+
+	log.Printf("[maintenance:adminSetMaintenanceHandler] Maintenance switched to {%t}", after.Enabled)
+	s.audit(r, "maintenance.update", "maintenance", "api", before, after)
+
+	writeMaintenance(w)
+}
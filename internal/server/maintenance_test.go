@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/mocks"
+)
+
+func TestRefuseWritesInMaintenance(t *testing.T) {
+	maintenance.Set(true, "")
+	defer maintenance.Set(false, "")
+
+	handler := refuseWritesInMaintenance(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodGet, "/short/abcdefgh", http.StatusOK},
+		{http.MethodHead, "/short/abcdefgh", http.StatusOK},
+		{http.MethodPost, "/short", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/api/v1/links/abcdefgh", http.StatusServiceUnavailable},
+		{http.MethodPut, maintenancePath, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.expected {
+			t.Errorf("expected %s %s to answer %d; got %d", tt.method, tt.path, tt.expected, rec.Code)
+		}
+	}
+
+	maintenance.Set(false, "")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/short", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected writes once the maintenance is over; got %d", rec.Code)
+	}
+}
+
+func TestSetMaintenanceStoresTheSwitch(t *testing.T) {
+	defer maintenance.Set(false, "")
+
+	db := &mocks.Service{}
+	s := &Server{db: db, maintenanceStore: db}
+
+	body := strings.NewReader(`{"enabled": true, "message": "Back at 10:00 UTC"}`)
+	rec := httptest.NewRecorder()
+	s.adminSetMaintenanceHandler(rec, httptest.NewRequest(http.MethodPut, maintenancePath, body))
+
+	if rec.Code != http.StatusOK || !maintenance.Enabled() || maintenance.Message() != "Back at 10:00 UTC" {
+		t.Fatalf("expected the maintenance on; got %d, %t %q", rec.Code, maintenance.Enabled(), maintenance.Message())
+	}
+	if calls := db.CallsTo("SetMaintenance"); len(calls) != 1 {
+		t.Errorf("expected the switch stored for the other processes; got %d calls", len(calls))
+	}
+
+	db.SetMaintenanceFunc = func(*database.MaintenanceModel) (*database.MaintenanceModel, error) {
+		return nil, errors.New("boom")
+	}
+	rec = httptest.NewRecorder()
+	s.adminSetMaintenanceHandler(rec, httptest.NewRequest(http.MethodPut, maintenancePath, strings.NewReader(`{"enabled": false}`)))
+
+	if rec.Code != http.StatusInternalServerError || !maintenance.Enabled() {
+		t.Errorf("expected the switch kept when it can't be stored; got %d, %t", rec.Code, maintenance.Enabled())
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// measure counts the requests and their latency by route and status, see metrics
+func measure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// The pattern rather than the path, so that every short code doesn't make a series of its own
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		tags := []string{"method:" + r.Method, "route:" + route, "status:" + strconv.Itoa(status)}
+		metrics.Count("http.requests", 1, tags...)
+		metrics.Timing("http.request_duration", time.Since(start), tags...)
+	})
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"url-shortner/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// recordingSink keeps the tags of the counts it receives by name
+type recordingSink struct {
+	metrics.Nop
+	counts map[string][][]string
+}
+
+func (s *recordingSink) Count(name string, value int64, tags ...string) {
+	s.counts[name] = append(s.counts[name], tags)
+}
+
+func TestMeasure(t *testing.T) {
+	sink := &recordingSink{counts: map[string][][]string{}}
+	metrics.SetSink(sink)
+	defer metrics.SetSink(metrics.Nop{})
+
+	r := chi.NewRouter()
+	r.Use(measure)
+	r.Get("/short/{short_code}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSeeOther)
+	})
+
+	for _, path := range []string{"/short/abcdefgh", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	expected := [][]string{
+		{"method:GET", "route:/short/{short_code}", "status:303"},
+		{"method:GET", "route:unmatched", "status:404"},
+	}
+	if !slices.EqualFunc(sink.counts["http.requests"], expected, slices.Equal) {
+		t.Errorf("expected requests counted as %v; got %v", expected, sink.counts["http.requests"])
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+)
+
+// Allowed moderation transitions. Moving back to active clears the link
+var moderationTransitions = map[string][]string{
+	database.ModerationActive:      {database.ModerationFlagged},
+	database.ModerationFlagged:     {database.ModerationUnderReview, database.ModerationActive},
+	database.ModerationUnderReview: {database.ModerationTakenDown, database.ModerationActive},
+	database.ModerationTakenDown:   {database.ModerationActive},
+}
+
+var takedownReasonCodes = []string{"phishing", "malware", "spam", "copyright", "trademark", "illegal_content", "court_order", "other"}
+
+// actorOf describes who is performing an admin action, for audit purposes.
+func actorOf(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return fmt.Sprintf("user:%d", user.Id)
+	}
+	if isAdminToken(bearerToken(r)) {
+		return "admin-token"
+	}
+	return "anonymous"
+}
+
+type takedownEventResponse struct {
+	Id         int       `json:"id"`
+	FromState  string    `json:"from_state"`
+	ToState    string    `json:"to_state"`
+	ReasonCode string    `json:"reason_code"`
+	Note       string    `json:"note,omitempty"`
+	Actor      string    `json:"actor"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func toTakedownEventResponse(entity *database.TakedownEventModel) takedownEventResponse {
+	return takedownEventResponse{
+		Id:         entity.Id,
+		FromState:  entity.FromState,
+		ToState:    entity.ToState,
+		ReasonCode: entity.ReasonCode,
+		Note:       entity.Note,
+		Actor:      entity.Actor,
+		CreatedAt:  entity.CreatedAt,
+	}
+}
+
+func (s *Server) adminModerationHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("short_code")
+
+	entity, err := s.db.GetShortUrl(shortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Did not found a url for the short_code")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not load link.")
+		return
+	}
+
+	entities, err := s.db.ListTakedownEvents(shortCode)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not load moderation history.")
+		return
+	}
+
+	events := make([]takedownEventResponse, 0, len(entities))
+	for _, event := range entities {
+		events = append(events, toTakedownEventResponse(event))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int                     `json:"status"`
+		State   string                  `json:"state"`
+		History []takedownEventResponse `json:"history"`
+	}{
+		Status:  http.StatusOK,
+		State:   entity.ModerationState,
+		History: events,
+	})
+}
+
+func (s *Server) adminModerationTransitionHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("short_code")
+
+	var reqBody struct {
+		State      string `json:"state"`
+		ReasonCode string `json:"reason_code"`
+		Note       string `json:"note"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	if !slices.Contains(takedownReasonCodes, reqBody.ReasonCode) {
+		writeError(w, http.StatusBadRequest, "Invalid reason_code.")
+		return
+	}
+
+	entity, err := s.db.GetShortUrl(shortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Did not found a url for the short_code")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not load link.")
+		return
+	}
+
+	if !slices.Contains(moderationTransitions[entity.ModerationState], reqBody.State) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Can't move a link from %s to %s.", entity.ModerationState, reqBody.State))
+		return
+	}
+
+	event, err := s.db.TransitionModeration(&database.TakedownEventModel{
+		ShortCode:  shortCode,
+		FromState:  entity.ModerationState,
+		ToState:    reqBody.State,
+		ReasonCode: reqBody.ReasonCode,
+		Note:       shortener.Truncate(reqBody.Note, 2000),
+		Actor:      actorOf(r),
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusConflict, "The link changed state in the meantime, reload and try again.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not change the moderation state.")
+		return
+	}
+	s.forgetLink(shortCode)
+
+	log.Printf("[moderation:adminModerationTransitionHandler] short_code {%s} moved to {%s} by {%s}", shortCode, event.ToState, event.Actor)
+	s.audit(r, "link.moderate", "link", shortCode, map[string]string{"state": event.FromState}, toTakedownEventResponse(event))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int                   `json:"status"`
+		Event  takedownEventResponse `json:"event"`
+	}{
+		Status: http.StatusOK,
+		Event:  toTakedownEventResponse(event),
+	})
+}
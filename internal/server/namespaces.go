@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+)
+
+type namespaceResponse struct {
+	Id          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func toNamespaceResponse(entity *database.NamespaceModel) namespaceResponse {
+	return namespaceResponse{
+		Id:          entity.Id,
+		Name:        entity.Name,
+		Description: entity.Description,
+		CreatedAt:   entity.CreatedAt,
+	}
+}
+
+// namespaced serves the links of a namespace through a handler of /short/{short_code}: their short code is
+// stored as "<namespace>/<code>".
+func namespaced(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("short_code", r.PathValue("namespace")+"/"+r.PathValue("code"))
+		next(w, r)
+	}
+}
+
+func (s *Server) adminListNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	entities, err := s.db.ListNamespaces()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not list namespaces.")
+		return
+	}
+
+	namespaces := make([]namespaceResponse, 0, len(entities))
+	for _, entity := range entities {
+		namespaces = append(namespaces, toNamespaceResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status     int                 `json:"status"`
+		Namespaces []namespaceResponse `json:"namespaces"`
+	}{
+		Status:     http.StatusOK,
+		Namespaces: namespaces,
+	})
+}
+
+func (s *Server) adminCreateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	if err := shortener.ValidateNamespace(reqBody.Name); err != nil {
+		writeError(w, http.StatusBadRequest, "The name must be 1 to 32 lowercase letters, digits or dashes, and not a reserved path.")
+		return
+	}
+	if len(reqBody.Description) > 255 {
+		writeError(w, http.StatusBadRequest, "The description can't be longer than 255 characters.")
+		return
+	}
+
+	entity, err := s.db.SaveNamespace(&database.NamespaceModel{Name: reqBody.Name, Description: reqBody.Description})
+	if writeConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not add namespace.")
+		return
+	}
+
+	log.Printf("[namespaces:adminCreateNamespaceHandler] Added namespace: {%s}", entity.Name)
+	s.audit(r, "namespace.create", "namespace", entity.Id, nil, toNamespaceResponse(entity))
+
+	writeJSON(w, http.StatusCreated, struct {
+		Status    int               `json:"status"`
+		Namespace namespaceResponse `json:"namespace"`
+	}{
+		Status:    http.StatusCreated,
+		Namespace: toNamespaceResponse(entity),
+	})
+}
+
+func (s *Server) adminDeleteNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	namespaceId, err := strconv.Atoi(r.PathValue("namespace_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid namespace id.")
+		return
+	}
+
+	err = s.db.DeleteNamespace(namespaceId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Namespace not found.")
+		return
+	}
+	if errors.Is(err, database.ErrNamespaceInUse) {
+		writeError(w, http.StatusConflict, "The namespace still has links.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not delete namespace.")
+		return
+	}
+	s.forgetLinks()
+
+	s.audit(r, "namespace.delete", "namespace", namespaceId, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
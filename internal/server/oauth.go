@@ -0,0 +1,172 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+// Cookie holding the state, the PKCE verifier and the sign in mode between the login redirect and the callback
+const oauthCookieName = "oauth_flow"
+
+// How long the api key issued by a sign in authenticates, signing in again replaces it
+const signInKeyLifetime = 30 * 24 * time.Hour
+
+func (s *Server) oauthProvider(w http.ResponseWriter, r *http.Request) (*auth.Provider, bool) {
+	provider, ok := s.oauthProviders[r.PathValue("provider")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown or unconfigured sign in provider.")
+		return nil, false
+	}
+	return provider, true
+}
+
+func (s *Server) oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := auth.NewState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not start the sign in.")
+		return
+	}
+	verifier, challenge, err := auth.NewPKCE()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not start the sign in.")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookieName,
+		Value:    state + "." + verifier + "." + r.URL.Query().Get("mode"),
+		Path:     "/auth/" + provider.Name,
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		// Lax keeps the cookie on the top level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, challenge), http.StatusFound)
+}
+
+// oauthCallbackHandler finishes the sign in with the provider, see signIn
+func (s *Server) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	// The cookie only serves a single attempt
+	http.SetCookie(w, &http.Cookie{Name: oauthCookieName, Path: "/auth/" + provider.Name, MaxAge: -1})
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		writeError(w, http.StatusUnauthorized, "Sign in was cancelled or refused: "+reason)
+		return
+	}
+
+	cookie, err := r.Cookie(oauthCookieName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Sign in expired, start again.")
+		return
+	}
+	state, flow, found := strings.Cut(cookie.Value, ".")
+	verifier, mode, _ := strings.Cut(flow, ".")
+	if !found || state == "" || query.Get("state") != state {
+		writeError(w, http.StatusBadRequest, "Sign in state mismatch, start again.")
+		return
+	}
+
+	accessToken, err := provider.Exchange(r.Context(), query.Get("code"), verifier)
+	if err != nil {
+		log.Printf("[oauth:oauthCallbackHandler] Could not exchange the {%s} code: %v", provider.Name, err)
+		writeError(w, http.StatusBadGateway, "Could not complete the sign in. Try again later")
+		return
+	}
+
+	identity, err := provider.Identity(r.Context(), accessToken)
+	if errors.Is(err, auth.ErrNoVerifiedEmail) {
+		writeError(w, http.StatusForbidden, "The "+provider.Name+" account needs a verified email.")
+		return
+	}
+	if err != nil {
+		log.Printf("[oauth:oauthCallbackHandler] Could not fetch the {%s} identity: %v", provider.Name, err)
+		writeError(w, http.StatusBadGateway, "Could not complete the sign in. Try again later")
+		return
+	}
+
+	user, err := s.db.GetOrCreateUserByIdentity(&database.UserIdentityModel{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not complete the sign in. Try again later")
+		return
+	}
+
+	s.signIn(w, r, user, provider.Name, mode == "session")
+}
+
+// signIn answers with a new api key for the user, which the dashboard authenticates with from then on. It replaces
+// the key of the previous sign in with the same method, so they don't pile up, and expires after signInKeyLifetime.
+// A session sign in answers with a session cookie instead, see startSession
+func (s *Server) signIn(w http.ResponseWriter, r *http.Request, user *database.UserModel, method string, session bool) {
+	if session {
+		s.startSession(w, r, user, method)
+		return
+	}
+
+	plain, prefix, hash, err := auth.GenerateApiKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not generate api key.")
+		return
+	}
+
+	name := signInKeyName(method)
+	if _, err := s.db.RevokeApiKeysNamed(user.Id, name); err != nil {
+		writeError(w, statusOf(err), "Could not complete the sign in. Try again later")
+		return
+	}
+
+	expiresAt := time.Now().Add(signInKeyLifetime)
+	apiKey, err := s.db.SaveApiKey(&database.ApiKeyModel{
+		UserId:    user.Id,
+		Name:      name,
+		KeyPrefix: prefix,
+		KeyHash:   hash,
+		Scopes:    auth.DefaultScopes,
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not complete the sign in. Try again later")
+		return
+	}
+
+	s.audit(r, "user.sign_in", "user", user.Id, nil, map[string]string{"provider": method, "api_key": apiKey.KeyPrefix})
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		User   userResponse   `json:"user"`
+		ApiKey apiKeyResponse `json:"api_key"`
+		Key    string         `json:"key"`
+	}{
+		Status: http.StatusOK,
+		User:   toUserResponse(user),
+		ApiKey: toApiKeyResponse(apiKey),
+		Key:    plain,
+	})
+}
+
+// signInKeyName names the api keys issued by a sign in method, a provider or "password"
+func signInKeyName(method string) string {
+	return method + " sign in"
+}
@@ -0,0 +1,619 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/pagination"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// How long an invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+// How long emailing an invitation may hold its request
+const invitationEmailTimeout = 10 * time.Second
+
+func (s *Server) registerOrganizationRoutes(r chi.Router) {
+	r.Use(requireUser)
+
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/", s.listOrganizationsHandler)
+	r.With(requireScope(auth.ScopeLinksWrite), requireRole(auth.RoleEditor)).Post("/", s.createOrganizationHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/{organization_id}/members", s.listMembersHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Put("/{organization_id}/members/{user_id}", s.updateMemberRoleHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Delete("/{organization_id}/members/{user_id}", s.removeMemberHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/{organization_id}/invitations", s.listInvitationsHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/{organization_id}/invitations", s.createInvitationHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/{organization_id}/invitations/{invitation_id}/resend", s.resendInvitationHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Delete("/{organization_id}/invitations/{invitation_id}", s.revokeInvitationHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/{organization_id}/links", s.listOrganizationLinksHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/{organization_id}/domains", s.listOrganizationDomainsHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/{organization_id}/domains", s.createOrganizationDomainHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/{organization_id}/domains/{domain_id}/verify", s.verifyOrganizationDomainHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Delete("/{organization_id}/domains/{domain_id}", s.deleteOrganizationDomainHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Put("/{organization_id}/domains/{domain_id}/branding", s.updateOrganizationDomainBrandingHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Post("/invitations/{token}/accept", s.acceptInvitationHandler)
+}
+
+type organizationResponse struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Plan      string    `json:"plan"`
+	Role      string    `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type memberResponse struct {
+	OrganizationId int       `json:"organization_id"`
+	UserId         int       `json:"user_id"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type invitationResponse struct {
+	Id             int       `json:"id"`
+	OrganizationId int       `json:"organization_id"`
+	Email          string    `json:"email,omitempty"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+func toOrganizationResponse(entity *database.OrganizationModel) organizationResponse {
+	return organizationResponse{
+		Id:        entity.Id,
+		Name:      entity.Name,
+		Plan:      entity.Plan,
+		Role:      entity.Role,
+		CreatedAt: entity.CreatedAt,
+	}
+}
+
+func toMemberResponse(entity *database.MemberModel) memberResponse {
+	return memberResponse{
+		OrganizationId: entity.OrganizationId,
+		UserId:         entity.UserId,
+		Email:          entity.Email,
+		Role:           entity.Role,
+		CreatedAt:      entity.CreatedAt,
+	}
+}
+
+func toInvitationResponse(entity *database.InvitationModel) invitationResponse {
+	return invitationResponse{
+		Id:             entity.Id,
+		OrganizationId: entity.OrganizationId,
+		Email:          entity.Email,
+		Role:           entity.Role,
+		CreatedAt:      entity.CreatedAt,
+		ExpiresAt:      entity.ExpiresAt,
+	}
+}
+
+// membership loads the membership of the authenticated user in the organization of the path and checks it
+// holds at least minRole. Non members get a 404 so organizations can't be enumerated.
+func (s *Server) membership(w http.ResponseWriter, r *http.Request, minRole string) (*database.MemberModel, bool) {
+	organizationId, err := strconv.Atoi(r.PathValue("organization_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid organization id.")
+		return nil, false
+	}
+
+	user := auth.UserFromContext(r.Context())
+	member, err := s.db.GetMembership(organizationId, user.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Organization not found.")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the organization.")
+		return nil, false
+	}
+
+	if !auth.OrgRoleAllows(member.Role, minRole) {
+		writeError(w, http.StatusForbidden, "The "+minRole+" role is required.")
+		return nil, false
+	}
+
+	return member, true
+}
+
+func (s *Server) listOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	entities, err := s.db.ListOrganizations(user.Id)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list organizations.")
+		return
+	}
+
+	organizations := make([]organizationResponse, 0, len(entities))
+	for _, entity := range entities {
+		organizations = append(organizations, toOrganizationResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status        int                    `json:"status"`
+		Organizations []organizationResponse `json:"organizations"`
+	}{
+		Status:        http.StatusOK,
+		Organizations: organizations,
+	})
+}
+
+func (s *Server) createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Name string `json:"name"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name)
+	if reqBody.Name == "" || len(reqBody.Name) > 100 {
+		writeError(w, http.StatusBadRequest, "A name of at most 100 characters is required.")
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	entity, err := s.db.CreateOrganization(&database.OrganizationModel{Name: reqBody.Name}, user.Id)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not create the organization.")
+		return
+	}
+
+	s.audit(r, "organization.create", "organization", entity.Id, nil, toOrganizationResponse(entity))
+
+	writeJSON(w, http.StatusCreated, struct {
+		Status       int                  `json:"status"`
+		Organization organizationResponse `json:"organization"`
+	}{
+		Status:       http.StatusCreated,
+		Organization: toOrganizationResponse(entity),
+	})
+}
+
+func (s *Server) listMembersHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	entities, err := s.db.ListMembers(member.OrganizationId)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list members.")
+		return
+	}
+
+	members := make([]memberResponse, 0, len(entities))
+	for _, entity := range entities {
+		members = append(members, toMemberResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status  int              `json:"status"`
+		Members []memberResponse `json:"members"`
+	}{
+		Status:  http.StatusOK,
+		Members: members,
+	})
+}
+
+// removeMemberHandler lets admins remove members and anyone leave. Only owners can remove other owners
+func (s *Server) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	if userId != member.UserId {
+		removed, err := s.db.GetMembership(member.OrganizationId, userId)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Member not found.")
+			return
+		}
+		if err != nil {
+			writeError(w, statusOf(err), "Could not remove the member.")
+			return
+		}
+
+		if !auth.OrgRoleAllows(member.Role, database.OrgRoleAdmin) || !auth.OrgRoleAllows(member.Role, removed.Role) {
+			writeError(w, http.StatusForbidden, "Not allowed to remove this member.")
+			return
+		}
+	}
+
+	err = s.db.RemoveMember(member.OrganizationId, userId)
+	if errors.Is(err, database.ErrLastOwner) {
+		writeError(w, statusOf(err), "The last owner can't leave the organization.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not remove the member.")
+		return
+	}
+
+	s.audit(r, "organization.member_remove", "organization", member.OrganizationId, map[string]int{"user_id": userId}, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateMemberRoleHandler lets admins change roles up to their own. Only owners change the role of other owners
+func (s *Server) updateMemberRoleHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	userId, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id.")
+		return
+	}
+
+	var reqBody struct {
+		Role string `json:"role"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	if auth.OrgRoleRank(reqBody.Role) == 0 {
+		writeError(w, http.StatusBadRequest, "Role must be 'viewer', 'editor', 'admin' or 'owner'.")
+		return
+	}
+
+	current, err := s.db.GetMembership(member.OrganizationId, userId)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Member not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the role.")
+		return
+	}
+
+	if !auth.OrgRoleAllows(member.Role, current.Role) || !auth.OrgRoleAllows(member.Role, reqBody.Role) {
+		writeError(w, http.StatusForbidden, "Can't change roles above your own.")
+		return
+	}
+
+	entity, err := s.db.UpdateMemberRole(member.OrganizationId, userId, reqBody.Role)
+	if errors.Is(err, database.ErrLastOwner) {
+		writeError(w, statusOf(err), "The last owner can't be demoted.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the role.")
+		return
+	}
+
+	s.audit(r, "organization.role_update", "organization", member.OrganizationId, toMemberResponse(current), toMemberResponse(entity))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		Member memberResponse `json:"member"`
+	}{
+		Status: http.StatusOK,
+		Member: toMemberResponse(entity),
+	})
+}
+
+func (s *Server) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	var reqBody struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	if reqBody.Role == "" {
+		reqBody.Role = database.OrgRoleEditor
+	}
+	if auth.OrgRoleRank(reqBody.Role) == 0 {
+		writeError(w, http.StatusBadRequest, "Role must be 'viewer', 'editor', 'admin' or 'owner'.")
+		return
+	}
+	if !auth.OrgRoleAllows(member.Role, reqBody.Role) {
+		writeError(w, http.StatusForbidden, "Can't invite with a role above your own.")
+		return
+	}
+	if reqBody.Email != "" && !strings.Contains(reqBody.Email, "@") {
+		writeError(w, http.StatusBadRequest, "Invalid email.")
+		return
+	}
+
+	token, err := auth.NewState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not create the invitation.")
+		return
+	}
+
+	entity, err := s.db.SaveInvitation(&database.InvitationModel{
+		OrganizationId: member.OrganizationId,
+		Email:          reqBody.Email,
+		Role:           reqBody.Role,
+		TokenHash:      auth.HashToken(token),
+		InvitedBy:      &member.UserId,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not create the invitation.")
+		return
+	}
+
+	s.audit(r, "organization.invite", "organization", member.OrganizationId, nil, toInvitationResponse(entity))
+
+	// The token is only ever returned here and in the email
+	writeJSON(w, http.StatusCreated, struct {
+		Status     int                `json:"status"`
+		Invitation invitationResponse `json:"invitation"`
+		AcceptUrl  string             `json:"accept_url"`
+		EmailSent  *bool              `json:"email_sent,omitempty"`
+	}{
+		Status:     http.StatusCreated,
+		Invitation: toInvitationResponse(entity),
+		AcceptUrl:  invitationUrl(token),
+		EmailSent:  s.sendInvitation(r, entity, token),
+	})
+}
+
+func (s *Server) listInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	entities, err := s.db.ListInvitations(member.OrganizationId)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list invitations.")
+		return
+	}
+
+	invitations := make([]invitationResponse, 0, len(entities))
+	for _, entity := range entities {
+		invitations = append(invitations, toInvitationResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status      int                  `json:"status"`
+		Invitations []invitationResponse `json:"invitations"`
+	}{
+		Status:      http.StatusOK,
+		Invitations: invitations,
+	})
+}
+
+// resendInvitationHandler replaces the token of a pending invitation, so the previous one stops working, extends it
+// for invitationTTL and emails it again. Resending an invitation without an email renews its link
+func (s *Server) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	member, invitation, ok := s.pendingInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := auth.NewState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not resend the invitation.")
+		return
+	}
+
+	entity, err := s.db.RenewInvitation(member.OrganizationId, invitation.Id, auth.HashToken(token), time.Now().Add(invitationTTL))
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Invitation not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not resend the invitation.")
+		return
+	}
+
+	s.audit(r, "organization.invitation_resend", "organization", member.OrganizationId, toInvitationResponse(invitation), toInvitationResponse(entity))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status     int                `json:"status"`
+		Invitation invitationResponse `json:"invitation"`
+		AcceptUrl  string             `json:"accept_url"`
+		EmailSent  *bool              `json:"email_sent,omitempty"`
+	}{
+		Status:     http.StatusOK,
+		Invitation: toInvitationResponse(entity),
+		AcceptUrl:  invitationUrl(token),
+		EmailSent:  s.sendInvitation(r, entity, token),
+	})
+}
+
+func (s *Server) revokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	member, invitation, ok := s.pendingInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := s.db.RevokeInvitation(member.OrganizationId, invitation.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Invitation not found.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not revoke the invitation.")
+		return
+	}
+
+	s.audit(r, "organization.invitation_revoke", "organization", member.OrganizationId, toInvitationResponse(invitation), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pendingInvitation loads the invitation of the path, not yet accepted, for an admin of its organization. Only
+// owners act on invitations to become owner, as only they can send them
+func (s *Server) pendingInvitation(w http.ResponseWriter, r *http.Request) (*database.MemberModel, *database.InvitationModel, bool) {
+	member, ok := s.membership(w, r, database.OrgRoleAdmin)
+	if !ok {
+		return nil, nil, false
+	}
+
+	invitationId, err := strconv.Atoi(r.PathValue("invitation_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid invitation id.")
+		return nil, nil, false
+	}
+
+	invitation, err := s.db.GetInvitation(member.OrganizationId, invitationId)
+	if err == nil && invitation.AcceptedAt != nil {
+		err = database.ErrNotFound
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Invitation not found.")
+		return nil, nil, false
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the invitation.")
+		return nil, nil, false
+	}
+
+	if !auth.OrgRoleAllows(member.Role, invitation.Role) {
+		writeError(w, http.StatusForbidden, "Not allowed to manage this invitation.")
+		return nil, nil, false
+	}
+
+	return member, invitation, true
+}
+
+// invitationPath returns the path accepting the invitation of token
+func invitationPath(token string) string {
+	return "/api/v1/orgs/invitations/" + token + "/accept"
+}
+
+// invitationUrl returns the url accepting the invitation of token on PUBLIC_BASE_URL for the inviter, or its path
+// alone when it is unset: the inviter knows the host of the api, the Host of the request can't be trusted with it
+func invitationUrl(token string) string {
+	if url, err := mailer.Url(invitationPath(token)); err == nil {
+		return url
+	}
+	return invitationPath(token)
+}
+
+// sendInvitation emails the invitation to its invitee. It returns whether the email went out, nil for invitations
+// shared as a link. A failure leaves the invitation pending, to be resent
+func (s *Server) sendInvitation(r *http.Request, invitation *database.InvitationModel, token string) *bool {
+	if invitation.Email == "" {
+		return nil
+	}
+	sent := false
+
+	organization, err := s.db.GetOrganization(invitation.OrganizationId)
+	if err != nil {
+		log.Printf("[server:sendInvitation] Could not load organization {%d}: %v", invitation.OrganizationId, err)
+		return &sent
+	}
+
+	acceptUrl, err := mailer.Url(invitationPath(token))
+	if err != nil {
+		log.Printf("[server:sendInvitation] Could not email invitation {%d}: %v", invitation.Id, err)
+		return &sent
+	}
+
+	message, err := mailer.Compose(invitation.Email, "invitation", struct {
+		Organization string
+		Role         string
+		InvitedBy    string
+		AcceptUrl    string
+		ExpiresAt    time.Time
+	}{organization.Name, invitation.Role, auth.UserFromContext(r.Context()).Email, acceptUrl, invitation.ExpiresAt})
+	if err != nil {
+		log.Printf("[server:sendInvitation] Could not render invitation {%d}: %v", invitation.Id, err)
+		return &sent
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), invitationEmailTimeout)
+	defer cancel()
+	if err := s.mailer.Send(ctx, message); err != nil {
+		log.Printf("[server:sendInvitation] Could not email invitation {%d}: %v", invitation.Id, err)
+		return &sent
+	}
+
+	sent = true
+	return &sent
+}
+
+func (s *Server) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	member, err := s.db.AcceptInvitation(auth.HashToken(r.PathValue("token")), user.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "The invitation doesn't exist, has expired or was already used.")
+		return
+	}
+	if errors.Is(err, database.ErrInvitationEmail) {
+		writeError(w, statusOf(err), "The invitation was sent to another email.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not accept the invitation.")
+		return
+	}
+
+	s.audit(r, "organization.join", "organization", member.OrganizationId, nil, toMemberResponse(member))
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int            `json:"status"`
+		Member memberResponse `json:"member"`
+	}{
+		Status: http.StatusOK,
+		Member: toMemberResponse(member),
+	})
+}
+
+func (s *Server) listOrganizationLinksHandler(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.membership(w, r, database.OrgRoleViewer)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, 50, 500)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+
+	entities, err := s.db.ListOrganizationLinks(member.OrganizationId, page)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list links.")
+		return
+	}
+	entities, nextCursor := pagination.Trim(entities, page, linkCursor)
+	if s.listingNotModified(w, r, entities) {
+		return
+	}
+
+	links := make([]linkResponse, 0, len(entities))
+	for _, entity := range entities {
+		links = append(links, toLinkResponse(entity))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status     int            `json:"status"`
+		Links      []linkResponse `json:"links"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+	}{
+		Status:     http.StatusOK,
+		Links:      links,
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/mocks"
+)
+
+func TestRemoveMemberHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		callerRole   string
+		removed      int
+		removedRole  string
+		removeErr    error
+		expectedCode int
+	}{
+		{name: "editor leaves", callerRole: database.OrgRoleEditor, removed: 1, expectedCode: http.StatusNoContent},
+		{name: "editor removes editor", callerRole: database.OrgRoleEditor, removed: 2, removedRole: database.OrgRoleEditor, expectedCode: http.StatusForbidden},
+		{name: "admin removes editor", callerRole: database.OrgRoleAdmin, removed: 2, removedRole: database.OrgRoleEditor, expectedCode: http.StatusNoContent},
+		{name: "admin removes owner", callerRole: database.OrgRoleAdmin, removed: 2, removedRole: database.OrgRoleOwner, expectedCode: http.StatusForbidden},
+		{name: "owner removes owner", callerRole: database.OrgRoleOwner, removed: 2, removedRole: database.OrgRoleOwner, expectedCode: http.StatusNoContent},
+		{name: "last owner leaves", callerRole: database.OrgRoleOwner, removed: 1, removeErr: database.ErrLastOwner, expectedCode: http.StatusConflict},
+		{name: "not a member", removed: 1, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetMembershipFunc: func(organizationId, userId int) (*database.MemberModel, error) {
+				role := tt.callerRole
+				if userId != 1 {
+					role = tt.removedRole
+				}
+				if role == "" {
+					return nil, database.ErrNotFound
+				}
+				return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: role}, nil
+			},
+			RemoveMemberFunc: func(organizationId, userId int) error {
+				return tt.removeErr
+			},
+		}
+		s := &Server{db: db}
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/7/members/x", nil)
+		req.SetPathValue("organization_id", "7")
+		req.SetPathValue("user_id", strconv.Itoa(tt.removed))
+		req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 1, Role: auth.RoleEditor}))
+		rec := httptest.NewRecorder()
+		s.removeMemberHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+	}
+}
+
+func TestCreateInvitationHandler(t *testing.T) {
+	db := &mocks.Service{
+		GetMembershipFunc: func(organizationId, userId int) (*database.MemberModel, error) {
+			return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: database.OrgRoleAdmin}, nil
+		},
+		SaveInvitationFunc: func(invitation *database.InvitationModel) (*database.InvitationModel, error) {
+			saved := *invitation
+			saved.Id = 3
+			return &saved, nil
+		},
+		GetOrganizationFunc: func(id int) (*database.OrganizationModel, error) {
+			return &database.OrganizationModel{Id: id, Name: "Acme"}, nil
+		},
+	}
+	mail := &mailer.Capture{}
+	s := &Server{db: db, mailer: mail}
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/7/invitations", strings.NewReader(`{"email": "bob@example.com", "role": "viewer"}`))
+	req.Host = "evil.example"
+	req.SetPathValue("organization_id", "7")
+	req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 1, Email: "ada@example.com", Role: auth.RoleEditor}))
+	rec := httptest.NewRecorder()
+	s.createInvitationHandler(rec, req)
+
+	var created struct {
+		AcceptUrl string `json:"accept_url"`
+		EmailSent *bool  `json:"email_sent"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.EmailSent == nil || !*created.EmailSent {
+		t.Fatalf("expected the invitation created and emailed; got %d %s", rec.Code, rec.Body)
+	}
+
+	sent := mail.Messages()
+	if len(sent) != 1 || sent[0].To != "bob@example.com" || sent[0].Subject != "ada@example.com invited you to Acme" {
+		t.Fatalf("expected the invitee emailed; got %+v", sent)
+	}
+	if !strings.HasPrefix(created.AcceptUrl, "https://sho.rt/") || !strings.Contains(sent[0].Text, created.AcceptUrl) {
+		t.Errorf("expected the email to hold the accept url %s on PUBLIC_BASE_URL; got %s", created.AcceptUrl, sent[0].Text)
+	}
+	saved := db.CallsTo("SaveInvitation")[0].Args[0].(*database.InvitationModel)
+	if token := strings.TrimSuffix(created.AcceptUrl[strings.Index(created.AcceptUrl, "/invitations/")+len("/invitations/"):], "/accept"); saved.TokenHash != auth.HashToken(token) {
+		t.Errorf("expected only the hash of the token stored")
+	}
+}
+
+func TestCreateInvitationWithoutBaseUrl(t *testing.T) {
+	db := &mocks.Service{
+		GetMembershipFunc: func(organizationId, userId int) (*database.MemberModel, error) {
+			return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: database.OrgRoleAdmin}, nil
+		},
+		SaveInvitationFunc: func(invitation *database.InvitationModel) (*database.InvitationModel, error) {
+			return invitation, nil
+		},
+	}
+	mail := &mailer.Capture{}
+	s := &Server{db: db, mailer: mail}
+	t.Setenv("PUBLIC_BASE_URL", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/7/invitations", strings.NewReader(`{"email": "bob@example.com", "role": "viewer"}`))
+	req.SetPathValue("organization_id", "7")
+	req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 1, Email: "ada@example.com", Role: auth.RoleEditor}))
+	rec := httptest.NewRecorder()
+	s.createInvitationHandler(rec, req)
+
+	var created struct {
+		AcceptUrl string `json:"accept_url"`
+		EmailSent *bool  `json:"email_sent"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.EmailSent == nil || *created.EmailSent || !strings.HasPrefix(created.AcceptUrl, "/api/v1/orgs/invitations/") {
+		t.Errorf("expected the invitation created with its path alone, and not emailed; got %d %s", rec.Code, rec.Body)
+	}
+	if sent := mail.Messages(); len(sent) != 0 {
+		t.Errorf("expected no email without PUBLIC_BASE_URL; got %+v", sent)
+	}
+}
+
+func TestResendInvitationHandler(t *testing.T) {
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt")
+	accepted := time.Now()
+	tests := []struct {
+		name         string
+		callerRole   string
+		invitation   *database.InvitationModel
+		expectedCode int
+		expectedSent int
+	}{
+		{name: "admin resends", callerRole: database.OrgRoleAdmin, invitation: &database.InvitationModel{Id: 3, Email: "bob@example.com", Role: database.OrgRoleEditor}, expectedCode: http.StatusOK, expectedSent: 1},
+		{name: "admin renews a link", callerRole: database.OrgRoleAdmin, invitation: &database.InvitationModel{Id: 3, Role: database.OrgRoleEditor}, expectedCode: http.StatusOK},
+		{name: "admin resends to owner", callerRole: database.OrgRoleAdmin, invitation: &database.InvitationModel{Id: 3, Email: "bob@example.com", Role: database.OrgRoleOwner}, expectedCode: http.StatusForbidden},
+		{name: "editor resends", callerRole: database.OrgRoleEditor, invitation: &database.InvitationModel{Id: 3, Email: "bob@example.com", Role: database.OrgRoleEditor}, expectedCode: http.StatusForbidden},
+		{name: "accepted", callerRole: database.OrgRoleAdmin, invitation: &database.InvitationModel{Id: 3, Email: "bob@example.com", Role: database.OrgRoleEditor, AcceptedAt: &accepted}, expectedCode: http.StatusNotFound},
+		{name: "unknown", callerRole: database.OrgRoleAdmin, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetMembershipFunc: func(organizationId, userId int) (*database.MemberModel, error) {
+				return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: tt.callerRole}, nil
+			},
+			GetInvitationFunc: func(organizationId, id int) (*database.InvitationModel, error) {
+				if tt.invitation == nil {
+					return nil, database.ErrNotFound
+				}
+				return tt.invitation, nil
+			},
+			RenewInvitationFunc: func(organizationId, id int, tokenHash string, expiresAt time.Time) (*database.InvitationModel, error) {
+				renewed := *tt.invitation
+				renewed.TokenHash, renewed.ExpiresAt = tokenHash, expiresAt
+				return &renewed, nil
+			},
+			GetOrganizationFunc: func(id int) (*database.OrganizationModel, error) {
+				return &database.OrganizationModel{Id: id, Name: "Acme"}, nil
+			},
+		}
+		mail := &mailer.Capture{}
+		s := &Server{db: db, mailer: mail}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/7/invitations/3/resend", nil)
+		req.SetPathValue("organization_id", "7")
+		req.SetPathValue("invitation_id", "3")
+		req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 1, Email: "ada@example.com", Role: auth.RoleEditor}))
+		rec := httptest.NewRecorder()
+		s.resendInvitationHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+		if sent := len(mail.Messages()); sent != tt.expectedSent {
+			t.Errorf("%s: expected %d emails; got %d", tt.name, tt.expectedSent, sent)
+		}
+	}
+}
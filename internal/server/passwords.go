@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// How long a password reset can be used
+const passwordResetTTL = time.Hour
+
+// Password resets asked per email and per client ip within passwordResetWindow, so the api can't be used to flood a
+// mailbox. Sign ins are limited per email, against guessing the password of an account
+const (
+	passwordResetsPerEmail = 3
+	passwordResetsPerIp    = 10
+	passwordResetWindow    = time.Hour
+
+	signInsPerEmail = 10
+	signInWindow    = 15 * time.Minute
+)
+
+// How long emailing a password reset may hold its request
+const passwordResetEmailTimeout = 10 * time.Second
+
+func (s *Server) registerPasswordRoutes(r chi.Router) {
+	r.Post("/sign-up", s.signUpHandler)
+	r.Post("/sign-in", s.passwordSignInHandler)
+	r.Post("/forgot", s.forgotPasswordHandler)
+	r.Post("/reset", s.resetPasswordHandler)
+}
+
+// allowPasswordAttempt counts an attempt under key against limit per window. A broken limiter lets it through, as
+// the rate limiting of every request does
+func (s *Server) allowPasswordAttempt(w http.ResponseWriter, r *http.Request, key string, limit int, window time.Duration) bool {
+	// Emails are only unique within the schema of a tenant
+	if s.tenant != "" {
+		key = s.tenant + ":" + key
+	}
+
+	result, err := s.limiter.Allow(r.Context(), key, limit, window)
+	if err != nil {
+		log.Printf("[passwords:allowPasswordAttempt] Could not check {%s}: %v", key, err)
+		return true
+	}
+	if !result.Allowed {
+		setRetryAfter(w, result.ResetAt)
+		writeError(w, http.StatusTooManyRequests, "Too many attempts. Try again later")
+		return false
+	}
+	return true
+}
+
+func (s *Server) passwordSignInHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	email := strings.ToLower(strings.TrimSpace(reqBody.Email))
+	if email == "" || reqBody.Password == "" {
+		writeError(w, http.StatusBadRequest, "An email and a password are required.")
+		return
+	}
+	if !s.allowPasswordAttempt(w, r, "sign_in:"+email, signInsPerEmail, signInWindow) {
+		return
+	}
+
+	user, err := s.db.GetUserPassword(email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeError(w, statusOf(err), "Could not complete the sign in. Try again later")
+		return
+	}
+
+	// Unknown emails are checked against no password, so they take as long to refuse
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !auth.CheckPassword(hash, reqBody.Password) {
+		writeError(w, http.StatusUnauthorized, "Invalid email or password.")
+		return
+	}
+
+	s.signIn(w, r, user.User, "password", sessionRequested(r))
+}
+
+// forgotPasswordHandler emails a password reset to the user with the email. It answers the same whether there is
+// one or not, so emails can't be told apart
+func (s *Server) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Email string `json:"email"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	email := strings.ToLower(strings.TrimSpace(reqBody.Email))
+	if !strings.Contains(email, "@") {
+		writeError(w, http.StatusBadRequest, "Invalid email.")
+		return
+	}
+
+	// Checked before looking the email up, so that a missing PUBLIC_BASE_URL doesn't tell accounts apart either
+	resetUrl, err := mailer.Url("/auth/password/reset")
+	if err != nil {
+		log.Printf("[passwords:forgotPasswordHandler] Could not send a password reset: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "Password resets are unavailable on this instance.")
+		return
+	}
+	if !s.allowPasswordAttempt(w, r, "password_reset:ip:"+clientIP(r), passwordResetsPerIp, passwordResetWindow) ||
+		!s.allowPasswordAttempt(w, r, "password_reset:"+email, passwordResetsPerEmail, passwordResetWindow) {
+		return
+	}
+
+	user, err := s.db.GetUserPassword(email)
+	if err == nil {
+		err = s.sendPasswordReset(r, user.User, resetUrl)
+	}
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		log.Printf("[passwords:forgotPasswordHandler] Could not send a password reset: %v", err)
+		writeError(w, statusOf(err), "Could not send the password reset. Try again later")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  http.StatusAccepted,
+		Message: "If an account uses this email, a password reset is on its way.",
+	})
+}
+
+// sendPasswordReset stores a reset for the user and emails its token, the only place it is ever shown, along with
+// resetUrl to send it to
+func (s *Server) sendPasswordReset(r *http.Request, user *database.UserModel, resetUrl string) error {
+	token, err := auth.NewState()
+	if err != nil {
+		return err
+	}
+
+	reset, err := s.db.SavePasswordReset(&database.PasswordResetModel{
+		UserId:    user.Id,
+		TokenHash: auth.HashToken(token),
+		Ip:        clientIP(r),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit(r, "user.password_reset_request", "user", user.Id, nil, map[string]any{"reset_id": reset.Id, "expires_at": reset.ExpiresAt})
+
+	message, err := mailer.Compose(user.Email, "password_reset", struct {
+		Token     string
+		ResetUrl  string
+		Ip        string
+		ExpiresAt time.Time
+	}{token, resetUrl, reset.Ip, reset.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), passwordResetEmailTimeout)
+	defer cancel()
+	return s.mailer.Send(ctx, message)
+}
+
+// resetPasswordHandler sets the password of the user of a reset token. The keys of previous password sign ins and
+// every session are revoked, whoever knew the old password is signed out
+func (s *Server) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	if reqBody.Token == "" {
+		writeError(w, http.StatusBadRequest, "A reset token is required.")
+		return
+	}
+	if err := auth.ValidatePassword(reqBody.Password); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid password, "+err.Error()+".")
+		return
+	}
+
+	hash, err := auth.HashPassword(reqBody.Password)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not reset the password.")
+		return
+	}
+
+	user, err := s.db.ResetPassword(auth.HashToken(reqBody.Token), hash)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusBadRequest, "The reset token is invalid, has expired or was already used.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not reset the password.")
+		return
+	}
+
+	revoked, err := s.db.RevokeApiKeysNamed(user.Id, signInKeyName("password"))
+	if err != nil {
+		log.Printf("[passwords:resetPasswordHandler] Could not revoke the password sign ins of user {%d}: %v", user.Id, err)
+	}
+	revokedSessions, err := s.db.RevokeSessions(user.Id)
+	if err != nil {
+		log.Printf("[passwords:resetPasswordHandler] Could not revoke the sessions of user {%d}: %v", user.Id, err)
+	}
+
+	s.audit(r, "user.password_reset", "user", user.Id, nil, map[string]int64{"revoked_api_keys": revoked, "revoked_sessions": revokedSessions})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changePasswordHandler sets the password of the authenticated user. Users who already have one must confirm it
+func (s *Server) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	if err := auth.ValidatePassword(reqBody.Password); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid password, "+err.Error()+".")
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	current, err := s.db.GetUserPassword(user.Email)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not change the password.")
+		return
+	}
+	if current.PasswordHash != "" && !auth.CheckPassword(current.PasswordHash, reqBody.CurrentPassword) {
+		writeError(w, http.StatusForbidden, "The current password is wrong.")
+		return
+	}
+
+	hash, err := auth.HashPassword(reqBody.Password)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not change the password.")
+		return
+	}
+	if err := s.db.SetPassword(user.Id, hash); err != nil {
+		writeError(w, statusOf(err), "Could not change the password.")
+		return
+	}
+
+	s.audit(r, "user.password_change", "user", user.Id, nil, map[string]bool{"had_password": current.PasswordHash != ""})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/ratelimit"
+)
+
+func TestForgotPasswordHandler(t *testing.T) {
+	db := &mocks.Service{
+		GetUserPasswordFunc: func(email string) (*database.UserPasswordModel, error) {
+			if email != "ada@example.com" {
+				return nil, database.ErrNotFound
+			}
+			return &database.UserPasswordModel{User: &database.UserModel{Id: 1, Email: email}}, nil
+		},
+		SavePasswordResetFunc: func(reset *database.PasswordResetModel) (*database.PasswordResetModel, error) {
+			return reset, nil
+		},
+	}
+	mail := &mailer.Capture{}
+	s := &Server{db: db, mailer: mail, limiter: ratelimit.NewMemory()}
+	t.Setenv("PUBLIC_BASE_URL", "")
+
+	forgot := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/forgot", strings.NewReader(`{"email": "`+email+`"}`))
+		req.Host = "evil.example"
+		rec := httptest.NewRecorder()
+		s.forgotPasswordHandler(rec, req)
+		return rec
+	}
+
+	if rec := forgot("ada@example.com"); rec.Code != http.StatusServiceUnavailable || len(mail.Messages()) != 0 {
+		t.Errorf("expected no reset sent without PUBLIC_BASE_URL; got %d", rec.Code)
+	}
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt")
+
+	known, unknown := forgot("Ada@example.com"), forgot("nobody@example.com")
+	if known.Code != http.StatusAccepted || unknown.Code != http.StatusAccepted || known.Body.String() != unknown.Body.String() {
+		t.Errorf("expected the same answer for known and unknown emails; got %d %s and %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+
+	sent := mail.Messages()
+	if len(sent) != 1 || sent[0].To != "ada@example.com" {
+		t.Fatalf("expected only the known email sent a reset; got %+v", sent)
+	}
+	if !strings.Contains(sent[0].Text, "https://sho.rt/auth/password/reset") || strings.Contains(sent[0].Text, "evil.example") {
+		t.Errorf("expected the reset url on PUBLIC_BASE_URL; got %s", sent[0].Text)
+	}
+	saved := db.CallsTo("SavePasswordReset")[0].Args[0].(*database.PasswordResetModel)
+	token := sent[0].Text[strings.Index(sent[0].Text, `"token": "`)+len(`"token": "`):]
+	token = token[:strings.Index(token, `"`)]
+	if saved.TokenHash != auth.HashToken(token) {
+		t.Errorf("expected only the hash of the emailed token stored")
+	}
+
+	for range passwordResetsPerEmail - 1 {
+		forgot("ada@example.com")
+	}
+	if rec := forgot("ada@example.com"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the resets of an email limited; got %d", rec.Code)
+	}
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{name: "reset", body: `{"token": "valid", "password": "correct horse battery"}`, expectedCode: http.StatusNoContent},
+		{name: "used token", body: `{"token": "used", "password": "correct horse battery"}`, expectedCode: http.StatusBadRequest},
+		{name: "short password", body: `{"token": "valid", "password": "short"}`, expectedCode: http.StatusBadRequest},
+		{name: "no token", body: `{"password": "correct horse battery"}`, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			ResetPasswordFunc: func(tokenHash string, passwordHash string) (*database.UserModel, error) {
+				if tokenHash != auth.HashToken("valid") {
+					return nil, database.ErrNotFound
+				}
+				return &database.UserModel{Id: 1}, nil
+			},
+		}
+		s := &Server{db: db}
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		s.resetPasswordHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expectedCode, rec.Code, rec.Body)
+		}
+		if tt.expectedCode == http.StatusNoContent {
+			reset := db.CallsTo("ResetPassword")[0]
+			if !auth.CheckPassword(reset.Args[1].(string), "correct horse battery") {
+				t.Errorf("%s: expected the password stored hashed", tt.name)
+			}
+			if revoked := db.CallsTo("RevokeApiKeysNamed"); len(revoked) != 1 || revoked[0].Args[1] != "password sign in" {
+				t.Errorf("%s: expected the password sign ins revoked; got %+v", tt.name, revoked)
+			}
+		}
+	}
+}
+
+func TestPasswordSignInHandler(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mocks.Service{
+		GetUserPasswordFunc: func(email string) (*database.UserPasswordModel, error) {
+			switch email {
+			case "ada@example.com":
+				return &database.UserPasswordModel{User: &database.UserModel{Id: 1, Email: email}, PasswordHash: hash}, nil
+			case "oauth@example.com":
+				return &database.UserPasswordModel{User: &database.UserModel{Id: 2, Email: email}}, nil
+			}
+			return nil, database.ErrNotFound
+		},
+		SaveApiKeyFunc: func(apiKey *database.ApiKeyModel) (*database.ApiKeyModel, error) {
+			return apiKey, nil
+		},
+	}
+	s := &Server{db: db, limiter: ratelimit.NewMemory()}
+
+	tests := []struct {
+		email        string
+		password     string
+		expectedCode int
+	}{
+		{"ada@example.com", "correct horse battery", http.StatusOK},
+		{"ada@example.com", "wrong horse battery", http.StatusUnauthorized},
+		{"oauth@example.com", "", http.StatusBadRequest},
+		{"oauth@example.com", "correct horse battery", http.StatusUnauthorized},
+		{"nobody@example.com", "correct horse battery", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{"email": tt.email, "password": tt.password})
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/sign-in", strings.NewReader(string(body)))
+		rec := httptest.NewRecorder()
+		s.passwordSignInHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s with %q: expected %d; got %d %s", tt.email, tt.password, tt.expectedCode, rec.Code, rec.Body)
+		}
+	}
+}
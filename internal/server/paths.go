@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+
+	"url-shortner/internal/shortener"
+)
+
+// decodePath routes requests on their decoded path, in its NFC form. Clients percent-encode Unicode codes in
+// different ways, e.g. with lowercase hex digits or decomposed accents, and all of them must reach the same link
+func decodePath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawPath == "" && isASCII(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decoded := *r.URL
+		decoded.RawPath = ""
+		decoded.Path = shortener.NormalizeCode(decoded.Path)
+
+		r = r.WithContext(r.Context())
+		r.URL = &decoded
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
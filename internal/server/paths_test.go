@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDecodePath(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(decodePath)
+	r.Get("/short/{short_code}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("short_code")))
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"ascii", "/short/abcdefgh", "abcdefgh"},
+		{"emoji", "/short/%F0%9F%8E%89", "🎉"},
+		{"lowercase hex", "/short/%f0%9f%8e%89", "🎉"},
+		{"decomposed accent", "/short/e%CC%81te%CC%81", "été"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Body.String() != tt.expected {
+			t.Errorf("%s: expected %q; got %q", tt.name, tt.expected, rec.Body.String())
+		}
+	}
+}
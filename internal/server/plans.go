@@ -0,0 +1,18 @@
+package server
+
+import (
+	"net/http"
+
+	"url-shortner/internal/plans"
+)
+
+// listPlansHandler publishes the plans and their limits, 0 meaning unlimited
+func (s *Server) listPlansHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Status int           `json:"status"`
+		Plans  []*plans.Plan `json:"plans"`
+	}{
+		Status: http.StatusOK,
+		Plans:  plans.All(),
+	})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+type emailPreferencesResponse struct {
+	WeeklyDigest bool `json:"weekly_digest"`
+}
+
+func (s *Server) getEmailPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	preferences, err := s.db.GetEmailPreferences(user.Id)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not load the email preferences.")
+		return
+	}
+
+	writeEmailPreferences(w, preferences)
+}
+
+func (s *Server) updateEmailPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	var reqBody struct {
+		WeeklyDigest *bool `json:"weekly_digest"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.WeeklyDigest == nil {
+		writeError(w, http.StatusBadRequest, "Weekly_digest must be true or false.")
+		return
+	}
+
+	preferences, err := s.db.UpdateEmailPreferences(&database.EmailPreferencesModel{UserId: user.Id, WeeklyDigest: *reqBody.WeeklyDigest})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not update the email preferences.")
+		return
+	}
+
+	s.audit(r, "account.email_preferences", "user", user.Id, nil, emailPreferencesResponse{WeeklyDigest: preferences.WeeklyDigest})
+
+	writeEmailPreferences(w, preferences)
+}
+
+func writeEmailPreferences(w http.ResponseWriter, preferences *database.EmailPreferencesModel) {
+	writeJSON(w, http.StatusOK, struct {
+		Status           int                      `json:"status"`
+		EmailPreferences emailPreferencesResponse `json:"email_preferences"`
+	}{
+		Status:           http.StatusOK,
+		EmailPreferences: emailPreferencesResponse{WeeklyDigest: preferences.WeeklyDigest},
+	})
+}
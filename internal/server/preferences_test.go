@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+func TestUpdateEmailPreferences(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{"opt out", `{"weekly_digest": false}`, http.StatusOK},
+		{"opt in", `{"weekly_digest": true}`, http.StatusOK},
+		{"missing", `{}`, http.StatusBadRequest},
+		{"not a boolean", `{"weekly_digest": "no"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			UpdateEmailPreferencesFunc: func(preferences *database.EmailPreferencesModel) (*database.EmailPreferencesModel, error) {
+				return preferences, nil
+			},
+		}
+		s := &Server{db: db}
+
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/me/email-preferences", strings.NewReader(tt.body))
+		req = req.WithContext(auth.WithUser(req.Context(), &database.UserModel{Id: 7}))
+		rec := httptest.NewRecorder()
+		s.updateEmailPreferencesHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+			continue
+		}
+		if tt.expectedCode != http.StatusOK {
+			continue
+		}
+
+		calls := db.CallsTo("UpdateEmailPreferences")
+		if len(calls) != 1 || calls[0].Args[0].(*database.EmailPreferencesModel).UserId != 7 {
+			t.Errorf("%s: expected the preferences of the user updated; got %+v", tt.name, calls)
+		}
+		if expected := `"weekly_digest":` + strconv.FormatBool(strings.Contains(tt.body, "true")); !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("%s: expected the preferences answered; got %s", tt.name, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/ratelimit"
+)
+
+// Probes are left out of rate limiting, an orchestrator polling them must never be turned away
+var unlimitedPaths = map[string]bool{
+	"/health": true,
+	"/livez":  true,
+	"/readyz": true,
+}
+
+// rateLimit limits requests per api key or session, or per client ip for requests without one. The ADMIN_TOKEN isn't limited.
+// When the limiter fails the request goes through, a broken limiter must not take the api down
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedPaths[r.URL.Path] || isAdminToken(bearerToken(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, limit := "ip:"+clientIP(r), ratelimit.IpLimit
+		if apiKey := auth.ApiKeyFromContext(r.Context()); apiKey != nil {
+			key, limit = "key:"+strconv.Itoa(apiKey.Id), ratelimit.KeyLimit
+		} else if session := auth.SessionFromContext(r.Context()); session != nil {
+			key, limit = "session:"+strconv.Itoa(session.Id), ratelimit.KeyLimit
+		}
+		if limit == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Api key and session ids are only unique within the schema of a tenant
+		if s.tenant != "" {
+			key = s.tenant + ":" + key
+		}
+
+		result, err := s.limiter.Allow(r.Context(), key, limit, ratelimit.Window)
+		if err != nil {
+			log.Printf("[ratelimit:rateLimit] Could not check {%s}: %v", key, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		setRateLimitHeaders(w, result)
+		if !result.Allowed {
+			setRetryAfter(w, result.ResetAt)
+			writeError(w, http.StatusTooManyRequests, "Too many requests. Try again later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRateLimitHeaders tells clients where they stand, X-RateLimit-Reset being the unix time the window ends
+func setRateLimitHeaders(w http.ResponseWriter, result ratelimit.Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+}
+
+// setRetryAfter sets Retry-After to the whole seconds left until resetAt, at least one
+func setRetryAfter(w http.ResponseWriter, resetAt time.Time) {
+	seconds := int64(math.Ceil(time.Until(resetAt).Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+}
+
+// rateLimitHealth reports the limiter in /health. Limiting in memory while Redis is down degrades it
+func (s *Server) rateLimitHealth() *componentHealthResponse {
+	metrics := s.limiter.Metrics()
+
+	component := &componentHealthResponse{
+		Status: "up",
+		Details: map[string]string{
+			"backend":   metrics.Backend,
+			"allowed":   strconv.FormatInt(metrics.Allowed, 10),
+			"limited":   strconv.FormatInt(metrics.Limited, 10),
+			"fallbacks": strconv.FormatInt(metrics.Fallbacks, 10),
+		},
+	}
+
+	if metrics.BreakerState != "" {
+		component.Details["breaker"] = metrics.BreakerState
+		if metrics.BreakerState != "closed" {
+			component.Status = "degraded"
+		}
+	}
+
+	return component
+}
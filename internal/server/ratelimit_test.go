@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	defer func(ipLimit, keyLimit int) { ratelimit.IpLimit, ratelimit.KeyLimit = ipLimit, keyLimit }(ratelimit.IpLimit, ratelimit.KeyLimit)
+	ratelimit.IpLimit, ratelimit.KeyLimit = 2, 3
+
+	adminToken = "secret-admin-token"
+	defer func() { adminToken = "" }()
+
+	s := &Server{limiter: ratelimit.NewMemory()}
+	handler := s.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(path string, remoteAddr string, token string, apiKey *database.ApiKeyModel) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if apiKey != nil {
+			req = req.WithContext(auth.WithApiKey(req.Context(), apiKey))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		token      string
+		apiKey     *database.ApiKeyModel
+		expected   int
+	}{
+		{"first anonymous", "/short/abc", "1.2.3.4:1000", "", nil, http.StatusOK},
+		{"second anonymous, other port", "/short/abc", "1.2.3.4:2000", "", nil, http.StatusOK},
+		{"third anonymous", "/short/abc", "1.2.3.4:1000", "", nil, http.StatusTooManyRequests},
+		{"other ip", "/short/abc", "5.6.7.8:1000", "", nil, http.StatusOK},
+		{"probe", "/readyz", "1.2.3.4:1000", "", nil, http.StatusOK},
+		{"admin token", "/api/v1/admin/stats", "1.2.3.4:1000", "secret-admin-token", nil, http.StatusOK},
+		{"api key from a limited ip", "/short/abc", "1.2.3.4:1000", "", &database.ApiKeyModel{Id: 7}, http.StatusOK},
+		{"api key again", "/short/abc", "1.2.3.4:1000", "", &database.ApiKeyModel{Id: 7}, http.StatusOK},
+		{"api key third", "/short/abc", "5.6.7.8:1000", "", &database.ApiKeyModel{Id: 7}, http.StatusOK},
+		{"api key fourth", "/short/abc", "5.6.7.8:1000", "", &database.ApiKeyModel{Id: 7}, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		if code := request(tt.path, tt.remoteAddr, tt.token, tt.apiKey); code != tt.expected {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expected, code)
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	defer func(ipLimit int) { ratelimit.IpLimit = ipLimit }(ratelimit.IpLimit)
+	ratelimit.IpLimit = 1
+
+	s := &Server{limiter: ratelimit.NewMemory()}
+	handler := s.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		remaining  string
+		retryAfter bool
+		expected   int
+	}{
+		{"allowed", "0", false, http.StatusOK},
+		{"limited", "0", true, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/short/abc", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expected, rec.Code)
+		}
+		if limit := rec.Header().Get("X-RateLimit-Limit"); limit != "1" {
+			t.Errorf("%s: expected a limit of 1; got %q", tt.name, limit)
+		}
+		if remaining := rec.Header().Get("X-RateLimit-Remaining"); remaining != tt.remaining {
+			t.Errorf("%s: expected %s remaining; got %q", tt.name, tt.remaining, remaining)
+		}
+
+		reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil || reset <= time.Now().Unix()-1 || reset > time.Now().Add(ratelimit.Window).Unix() {
+			t.Errorf("%s: expected the reset within the window; got %q", tt.name, rec.Header().Get("X-RateLimit-Reset"))
+		}
+
+		if retryAfter := rec.Header().Get("Retry-After"); (retryAfter != "") != tt.retryAfter {
+			t.Errorf("%s: expected Retry-After %v; got %q", tt.name, tt.retryAfter, retryAfter)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/notify"
+	"url-shortner/internal/shortener"
+)
+
+var reportReasons = []string{"phishing", "malware", "spam", "illegal", "other"}
+
+// clientIP returns the ip address of the client, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) reportLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("short_code")
+
+	var reqBody struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+		Email   string `json:"email"`
+	}
+
+	json.NewDecoder(r.Body).Decode(&reqBody)
+	log.Printf("[reports:reportLinkHandler] Report received for short_code {%s} with reason {%s}", shortCode, reqBody.Reason)
+
+	if !slices.Contains(reportReasons, reqBody.Reason) {
+		writeError(w, http.StatusBadRequest, "Reason must be one of: phishing, malware, spam, illegal, other.")
+		return
+	}
+
+	report, err := s.db.SaveAbuseReport(&database.AbuseReportModel{
+		ShortCode:         shortCode,
+		Reason:            reqBody.Reason,
+		Details:           shortener.Truncate(reqBody.Details, 2000),
+		ReporterEmail:     shortener.Truncate(reqBody.Email, 255),
+		ReporterIp:        clientIP(r),
+		ReporterUserAgent: shortener.Truncate(r.UserAgent(), 512),
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Did not found a valid url for the short_code")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not save the report. Try again later")
+		return
+	}
+
+	s.audit(r, "report.create", "report", report.Id, nil, map[string]string{"short_code": shortCode, "reason": report.Reason})
+
+	fields := map[string]string{"short_code": shortCode, "reason": report.Reason}
+	if s.tenant != "" {
+		fields["tenant"] = s.tenant
+	}
+	notify.Send(notify.Event{Type: notify.AbuseReported, Title: "Link reported for " + report.Reason, Fields: fields})
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  http.StatusAccepted,
+		Message: "Thank you, the report will be reviewed.",
+	})
+}
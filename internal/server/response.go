@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"url-shortner/internal/breaker"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/unwrap"
+)
+
+type errorResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+
+	// Field whose value is already taken, only set on conflicts
+	Field string `json:"field,omitempty"`
+}
+
+// writeJSON writes body as JSON using the given HTTP status code.
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes the standard {status, message} error body.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{
+		Status:  status,
+		Message: message,
+	})
+}
+
+// writeConflict answers 409 naming the field whose value is already taken when err is a database.ConflictError, and
+// reports whether it did
+func writeConflict(w http.ResponseWriter, err error) bool {
+	var conflict *database.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	writeJSON(w, http.StatusConflict, errorResponse{
+		Status:  http.StatusConflict,
+		Message: fmt.Sprintf("The %s is already in use.", strings.ReplaceAll(conflict.Field, "_", " ")),
+		Field:   conflict.Field,
+	})
+	return true
+}
+
+// decodeLinkBody decodes the JSON body of the requests creating a link into v. Bodies too large to hold a link of
+// the maximum length are cut short and refused with shortener.ErrLinkTooLong, so that huge data: urls aren't read
+// into memory
+func decodeLinkBody(w http.ResponseWriter, r *http.Request, v any) error {
+	// A link takes at most 6 times its length once escaped in JSON, plus room for the other fields
+	r.Body = http.MaxBytesReader(w, r.Body, int64(6*shortener.MaxLinkLength()+16<<10))
+
+	var tooLarge *http.MaxBytesError
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.As(err, &tooLarge) {
+		return shortener.ErrLinkTooLong
+	}
+	return err
+}
+
+// writeShortenError answers why a link could not be created, see shortener.Shorten
+func writeShortenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, shortener.ErrDailyQuotaExceeded) || errors.Is(err, shortener.ErrActiveQuotaExceeded):
+		writeError(w, statusOf(err), "Link quota exceeded, see /api/v1/me/usage.")
+	case errors.Is(err, shortener.ErrNotMember):
+		writeError(w, statusOf(err), "Only members of the organization can create links for it.")
+	case errors.Is(err, shortener.ErrAliasNotAllowed):
+		writeError(w, statusOf(err), "Choosing the code requires a plan with custom aliases, see /api/v1/plans.")
+	case errors.Is(err, shortener.ErrUnicodeDisabled):
+		writeError(w, statusOf(err), "Emoji codes are disabled on this instance.")
+	case errors.Is(err, shortener.ErrInvalidCode):
+		writeError(w, statusOf(err), "The code must be 1 to 64 letters, digits, dashes or underscores, and not a reserved word.")
+	case writeConflict(w, err):
+		// Answered with the field that is taken
+	case errors.Is(err, database.ErrDuplicateCode):
+		writeError(w, statusOf(err), "The code is already taken.")
+	case errors.Is(err, shortener.ErrViewerRole):
+		writeError(w, statusOf(err), "The editor role in the organization is required to create links for it.")
+	case errors.Is(err, shortener.ErrLinkTooLong):
+		writeError(w, statusOf(err), fmt.Sprintf("The link is longer than the %d characters allowed.", shortener.MaxLinkLength()))
+	case errors.Is(err, shortener.ErrInvalidLink), errors.Is(err, shortener.ErrInvalidExpiry):
+		writeError(w, statusOf(err), err.Error())
+	case errors.Is(err, unwrap.ErrTooManyHops):
+		writeError(w, statusOf(err), "The destination redirects through too many short links.")
+	case errors.Is(err, shortener.ErrInvalidVisibility):
+		writeError(w, statusOf(err), "The visibility must be public, unlisted or private.")
+	case errors.Is(err, shortener.ErrPrivateAnonymous):
+		writeError(w, statusOf(err), "Sign in or use an api key to create private links.")
+	case errors.Is(err, shortener.ErrEmailUnverified):
+		writeError(w, statusOf(err), "Verify your email to create links, see POST /api/v1/me/email-verification.")
+	case errors.Is(err, shortener.ErrAnonymousBlocked):
+		writeError(w, statusOf(err), "Sign in or use an api key to create links on this instance.")
+	default:
+		writeError(w, statusOf(err), "Something went wrong with generating short url. Try again later")
+	}
+}
+
+// statusOf maps the errors of the database and shortener layers to the HTTP status they should be answered with.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrExpired), errors.Is(err, database.ErrDisabled):
+		return http.StatusGone
+	case errors.Is(err, database.ErrDuplicateCode), errors.Is(err, database.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, mailer.ErrNoBaseUrl):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, shortener.ErrLinkTooLong):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, shortener.ErrInvalidLink), errors.Is(err, shortener.ErrInvalidExpiry), errors.Is(err, shortener.ErrInvalidCode),
+		errors.Is(err, shortener.ErrUnicodeDisabled), errors.Is(err, unwrap.ErrTooManyHops), errors.Is(err, shortener.ErrInvalidVisibility):
+		return http.StatusBadRequest
+	case errors.Is(err, shortener.ErrPrivateAnonymous), errors.Is(err, shortener.ErrAnonymousBlocked),
+		errors.Is(err, shortener.ErrClaimAnonymous):
+		return http.StatusUnauthorized
+	case errors.Is(err, shortener.ErrDailyQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, shortener.ErrActiveQuotaExceeded), errors.Is(err, shortener.ErrNotMember), errors.Is(err, shortener.ErrViewerRole),
+		errors.Is(err, shortener.ErrAliasNotAllowed), errors.Is(err, shortener.ErrEmailUnverified):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrLastOwner), errors.Is(err, database.ErrInvitationEmail):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+)
+
+func TestWriteShortenError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectedCode  int
+		expectedField string
+	}{
+		{"taken code", fmt.Errorf("shorten: %w", &database.ConflictError{Field: "short_code"}), http.StatusConflict, "short_code"},
+		{"taken domain", fmt.Errorf("save domain: %w", &database.ConflictError{Field: "domain"}), http.StatusConflict, "domain"},
+		{"quota", shortener.ErrDailyQuotaExceeded, http.StatusTooManyRequests, ""},
+		{"link too long", shortener.ErrLinkTooLong, http.StatusRequestEntityTooLarge, ""},
+		{"invalid expiry", shortener.ErrInvalidExpiry, http.StatusBadRequest, ""},
+		{"database failure", errors.New("connection reset"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeShortenError(rec, tt.err)
+
+		var body errorResponse
+		json.NewDecoder(rec.Body).Decode(&body)
+		if rec.Code != tt.expectedCode || body.Field != tt.expectedField {
+			t.Errorf("%s: expected %d naming %q; got %d %+v", tt.name, tt.expectedCode, tt.expectedField, rec.Code, body)
+		}
+	}
+}
+
+func TestDecodeLinkBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected error
+	}{
+		{"link", `{"link_to_short": "https://example.com"}`, nil},
+		{"data url", `{"link_to_short": "data:text/html;base64,` + strings.Repeat("A", 4<<20) + `"}`, shortener.ErrLinkTooLong},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/short", strings.NewReader(tt.body))
+
+		var reqBody struct {
+			LinkToShort string `json:"link_to_short"`
+		}
+		if err := decodeLinkBody(httptest.NewRecorder(), req, &reqBody); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -2,37 +2,88 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
-	"math/rand"
+	"log/slog"
 	"net/http"
-	"time"
 
+	"url-shortner/internal/auth"
+	"url-shortner/internal/breaker"
 	"url-shortner/internal/database"
+	"url-shortner/internal/i18n"
+	"url-shortner/internal/policy"
+	"url-shortner/internal/shortener"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(accessLogger())
+	r.Use(measure)
+	r.Use(s.captureExchanges)
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Captcha-Token", "X-Edit-Token"},
+		ExposedHeaders:   []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
+	r.Use(decodePath)
+	r.Use(refuseWritesInMaintenance)
+	r.Use(s.authenticate)
+	r.Use(s.rateLimit)
+	r.Use(requireCSRF)
+
 	r.Get("/", s.HelloWorldHandler)
 
 	r.Get("/health", s.healthHandler)
+	r.Get("/livez", s.livezHandler)
+	r.Get("/readyz", s.readyzHandler)
+
+	r.Route("/auth/password", s.registerPasswordRoutes)
+	r.Get("/auth/email/verify", s.verifyEmailHandler)
+	r.Post("/auth/sign-out", s.signOutHandler)
+	r.Get("/auth/csrf", s.csrfTokenHandler)
+	r.Get("/api/v1/captcha", s.captchaConfigHandler)
+	r.Get("/auth/{provider}/login", s.oauthLoginHandler)
+	r.Get("/auth/{provider}/callback", s.oauthCallbackHandler)
 
 	r.Get("/short/{short_code}", s.redirectUrlHandler)
-	r.Post("/short", s.shortLinkHandler)
+	r.With(requireScope(auth.ScopeLinksWrite), requireRole(auth.RoleEditor), s.requireCaptcha).Post("/short", s.shortLinkHandler)
+	r.With(requireScope(auth.ScopeLinksWrite), requireRole(auth.RoleEditor), s.requireCaptcha).Put("/short/{short_code}", s.upsertLinkHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Patch("/short/{short_code}", s.editLinkHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Delete("/short/{short_code}", s.deleteLinkHandler)
+	r.With(requireScope(auth.ScopeStatsRead)).Get("/short/summary", s.linkSummaryHandler)
+	r.With(requireScope(auth.ScopeLinksWrite)).Get("/short/suggest", s.suggestCodesHandler)
+	r.With(requireScope(auth.ScopeLinksWrite), requireRole(auth.RoleEditor), s.requireCaptcha).Post("/short/import", s.importLinksHandler)
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/short/{short_code}/info", s.linkInfoHandler)
+	r.With(requireScope(auth.ScopeStatsRead)).Get("/short/{short_code}/stats", s.linkStatsHandler)
+	r.Post("/short/{short_code}/report", s.reportLinkHandler)
+
+	r.Get("/short/{namespace}/{code}", namespaced(s.redirectUrlHandler))
+	r.With(requireScope(auth.ScopeLinksRead)).Get("/short/{namespace}/{code}/info", namespaced(s.linkInfoHandler))
+	r.With(requireScope(auth.ScopeStatsRead)).Get("/short/{namespace}/{code}/stats", namespaced(s.linkStatsHandler))
+	r.Post("/short/{namespace}/{code}/report", namespaced(s.reportLinkHandler))
+	r.With(requireScope(auth.ScopeLinksWrite), requireRole(auth.RoleEditor), s.requireCaptcha).Put("/short/{namespace}/{code}", namespaced(s.upsertLinkHandler))
+	r.With(requireScope(auth.ScopeLinksWrite)).Patch("/short/{namespace}/{code}", namespaced(s.editLinkHandler))
+	r.With(requireScope(auth.ScopeLinksWrite)).Delete("/short/{namespace}/{code}", namespaced(s.deleteLinkHandler))
+
+	// Go links style, e.g. /go/wiki for the "wiki" code of the "go" namespace
+	r.Get("/{namespace}/{code}", namespaced(s.redirectUrlHandler))
+
+	r.Route("/api/v1/admin", s.registerAdminRoutes)
+	r.Route("/api/v1/me", s.registerAccountRoutes)
+	r.Route("/api/v1/orgs", s.registerOrganizationRoutes)
+	r.Route("/api/v1/keys", s.registerApiKeyRoutes)
+	r.Get("/api/v1/plans", s.listPlansHandler)
+	r.Get("/api/v1/domains", s.listDomainsHandler)
+	r.With(requireUser).Get("/api/v1/jobs/{job_id}", s.getJobHandler)
+	r.With(requireUser).Get("/api/v1/jobs/{job_id}/download", s.downloadJobHandler)
 
 	return r
 }
@@ -49,108 +100,173 @@ func (s *Server) HelloWorldHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(jsonResp)
 }
 
-func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	jsonResp, _ := json.Marshal(s.db.Health())
-	_, _ = w.Write(jsonResp)
-}
-
 func (s *Server) redirectUrlHandler(w http.ResponseWriter, r *http.Request) {
 	shortCode := r.PathValue("short_code")
-	log.Printf("[routes:redirectUrlHandler] Request received with short_code: {%s}", shortCode)
+	slog.Debug("Redirect requested", "short_code", shortCode)
 
-	entity, err := s.db.GetShortUrl(shortCode)
+	entity, err := s.shortener.Resolve(shortCode)
 
-	if err != nil {
-		errResponse := struct {
-			Status  int    `json:"status"`
-			Message string `json:"message"`
-		}{
-			Status:  404,
-			Message: "Did not found a valid url for the short_code",
-		}
-
-		json.NewEncoder(w).Encode(errResponse)
-		return 
+	// Links pinned to a domain don't exist on the other domains
+	if entity != nil && !s.servedOn(entity, r) {
+		slog.Debug("Link not served on the host", "short_code", shortCode, "host", r.Host)
+		err = database.ErrNotFound
 	}
 
-	// Checking for expiration time
-	expireAt := entity.CreatedAt.Add(time.Duration(entity.ExpTimeMinutes) * time.Minute)
-
-	if time.Now().After(expireAt) {
-		log.Printf("[routes:redirectUrlHandler] The link for short_code {%s} has expired", entity.ShortCode)
-		errResponse := struct {
-			Status  int    `json:"status"`
-			Message string `json:"message"`
-		}{
-			Status:  410,
-			Message: "Short Link is expired.",
-		}
+	// Private links don't exist for anyone but their owner
+	if entity != nil && !s.visibleTo(r, entity) {
+		slog.Debug("Link is private", "short_code", shortCode)
+		err = database.ErrNotFound
+	}
 
-		json.NewEncoder(w).Encode(errResponse)
-		return 
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		slog.Warn("Database unavailable and the link is not cached", "short_code", shortCode)
+		s.writePageError(w, r, http.StatusServiceUnavailable, i18n.ServiceUnavailable)
+		return
+	case errors.Is(err, database.ErrNotFound):
+		s.writePageError(w, r, http.StatusNotFound, i18n.LinkNotFound)
+		return
+	case errors.Is(err, database.ErrDisabled):
+		slog.Debug("Link is disabled", "short_code", entity.ShortCode, "reason", entity.DisabledReason)
+		s.writePageError(w, r, statusOf(err), i18n.LinkDisabled)
+		return
+	case errors.Is(err, database.ErrExpired):
+		slog.Debug("Link has expired", "short_code", entity.ShortCode)
+		s.writePageError(w, r, statusOf(err), i18n.LinkExpired)
+		return
+	case err != nil:
+		slog.Error("Could not load the link", "short_code", shortCode, "error", err)
+		s.writePageError(w, r, statusOf(err), i18n.SomethingWentWrong)
+		return
 	}
 
-	log.Printf("[routes:redirectUrlHandler] Redirecting for short_code: {%s}", shortCode)
+	slog.Debug("Redirecting", "short_code", shortCode)
 	http.Redirect(w, r, entity.Link, http.StatusSeeOther)
-	s.db.UpdateTimesClicked(shortCode)
+
+	err = s.shortener.RecordVisit(entity, shortener.Visit{
+		Ip:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+	})
+	if err != nil {
+		slog.Error("Could not record the click", "short_code", shortCode, "error", err)
+	}
 }
 
 func (s *Server) shortLinkHandler(w http.ResponseWriter, r *http.Request) {
-
-	// log.Printf("[routes:shortLinkHandler] rquest: %+v", r.Host)
-
-	fmt.Printf("%s %s", r.URL.Scheme, r.Host)
-
-
 	var reqBody struct {
-		LinkToShort string `json:"link_to_short"`
-		ExpTimeMinutes int `json:"exp_time_minutes"`
+		LinkToShort    string `json:"link_to_short"`
+		ExpTimeMinutes int    `json:"exp_time_minutes"`
+		OrganizationId *int   `json:"organization_id"`
+		Domain         string `json:"domain"`
+		Namespace      string `json:"namespace"`
+		Code           string `json:"code"`
+		Emoji          bool   `json:"emoji"`
+		Visibility     string `json:"visibility"`
 	}
 
-	json.NewDecoder(r.Body).Decode(&reqBody)
+	if err := decodeLinkBody(w, r, &reqBody); errors.Is(err, shortener.ErrLinkTooLong) {
+		writeShortenError(w, err)
+		return
+	}
 	log.Printf("[routes:shortLinkHandler] Request received with body: %+v", reqBody)
 
-	new := &database.ShortUrlModel{
-		Link:           reqBody.LinkToShort,
-		ExpTimeMinutes: reqBody.ExpTimeMinutes,
-		ShortCode:      generateRandomString(8),
+	if err := shortener.Validate(reqBody.LinkToShort, reqBody.ExpTimeMinutes); err != nil {
+		writeShortenError(w, err)
+		return
 	}
 
-	entity, err := s.db.SaveShortUrl(new)
+	// Internationalized domains are checked in the punycode form they are stored in
+	link, err := shortener.NormalizeLink(reqBody.LinkToShort)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	link, err = s.unwrapDestination(r.Context(), requestHost(r), link)
 	if err != nil {
-		errResponse := struct {
-			Status  int    `json:"status"`
-			Message string `json:"message"`
-		}{
-			Status:  500,
-			Message: "Something went wrong with generating short url. Try again later",
+		writeShortenError(w, err)
+		return
+	}
+
+	assessment, ok := s.checkDestination(w, r, link)
+	if !ok {
+		return
+	}
+
+	// Unpinned links are served on every domain, their short url uses the host of the request
+	host := r.Host
+	creator := creatorOf(r)
+	creator.OrganizationId = reqBody.OrganizationId
+	options := shortener.Options{Code: reqBody.Code, Emoji: reqBody.Emoji, Visibility: reqBody.Visibility, Review: reviewOf(assessment)}
+
+	if reqBody.Domain != "" {
+		domain, err := s.domainOf(policy.NormalizePattern(reqBody.Domain))
+		if err != nil {
+			log.Printf("[routes:shortLinkHandler] Could not look up domain {%s}: %v", reqBody.Domain, err)
+			writeError(w, statusOf(err), "Something went wrong with generating short url. Try again later")
+			return
+		}
+		if domain == nil {
+			writeError(w, http.StatusBadRequest, "Unknown domain, see /api/v1/domains.")
+			return
+		}
+		if !pinnableBy(domain, creator.OrganizationId) {
+			writeError(w, http.StatusForbidden, "The domain only takes the links of its organization.")
+			return
 		}
 
-		json.NewEncoder(w).Encode(errResponse)
+		host = domain.Hostname
+		options.DomainId = &domain.Id
 	}
 
-	baseUrl := "http://"
-	if r.URL.Scheme != "" {
-		baseUrl = "https://"
+	if reqBody.Namespace != "" {
+		namespace, err := s.db.GetNamespaceByName(reqBody.Namespace)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusBadRequest, "Unknown namespace.")
+			return
+		}
+		if err != nil {
+			log.Printf("[routes:shortLinkHandler] Could not look up namespace {%s}: %v", reqBody.Namespace, err)
+			writeError(w, statusOf(err), "Something went wrong with generating short url. Try again later")
+			return
+		}
+		options.Namespace = namespace
 	}
 
-	succResponse := struct {
-		Status int `json:"status"`
-		ShortUrl string `json:"short_url"`
-	} {
-		Status: 200,
-		ShortUrl: fmt.Sprint(baseUrl+r.Host+"/short/"+entity.ShortCode),
+	entity, err := s.shortener.Shorten(link, reqBody.ExpTimeMinutes, creator, options)
+	if err != nil {
+		log.Printf("[routes:shortLinkHandler] Could not shorten link {%s}: %v", reqBody.LinkToShort, err)
+		writeShortenError(w, err)
+		return
 	}
 
-	json.NewEncoder(w).Encode(succResponse)
-}
+	s.audit(r, "link.create", "link", entity.ShortCode, nil, toLinkResponse(entity))
+
+	// The link exists by now, without its tokens it only can't be attached to an account or edited later
+	claimToken, editToken := "", ""
+	if creator.Anonymous() {
+		if claimToken, err = s.shortener.IssueClaim(entity); err != nil {
+			log.Printf("[routes:shortLinkHandler] Could not issue the claim of shortCode {%s}: %v", entity.ShortCode, err)
+		}
+		if editToken, err = s.shortener.IssueEditToken(entity); err != nil {
+			log.Printf("[routes:shortLinkHandler] Could not issue the edit token of shortCode {%s}: %v", entity.ShortCode, err)
+		}
+	}
 
-func generateRandomString(stringLength int) string {
-	letters := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-	finalStringRune := make([]rune, stringLength)
-	for i := range finalStringRune {
-		finalStringRune[i] = letters[rand.Intn(len(letters))]
+	succResponse := struct {
+		Status      int    `json:"status"`
+		ShortUrl    string `json:"short_url"`
+		UnderReview bool   `json:"under_review,omitempty"`
+		ClaimToken  string `json:"claim_token,omitempty"`
+		EditToken   string `json:"edit_token,omitempty"`
+	}{
+		Status:      200,
+		ShortUrl:    shortUrlOf(r, host, entity.ShortCode),
+		UnderReview: options.Review != nil,
+		ClaimToken:  claimToken,
+		EditToken:   editToken,
 	}
-	return string(finalStringRune)
+
+	json.NewEncoder(w).Encode(succResponse)
 }
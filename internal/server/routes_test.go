@@ -1,10 +1,20 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/breaker"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/shortener"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +39,174 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestRedirectUrlHandler(t *testing.T) {
+	now := time.Now()
+	disabledAt := now
+
+	tests := []struct {
+		name           string
+		entity         *database.ShortUrlModel
+		err            error
+		expectedCode   int
+		expectedClicks int
+	}{
+		{"active", &database.ShortUrlModel{Id: 1, ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: now}, nil, http.StatusSeeOther, 1},
+		{"disabled", &database.ShortUrlModel{Id: 1, ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: now, DisabledAt: &disabledAt}, nil, http.StatusGone, 0},
+		{"expired", &database.ShortUrlModel{Id: 1, ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 1, CreatedAt: now.Add(-time.Hour)}, nil, http.StatusGone, 0},
+		{"not found", nil, database.ErrNotFound, http.StatusNotFound, 0},
+		{"wrapped not found", nil, fmt.Errorf("get short url abcdefgh: %w", database.ErrNotFound), http.StatusNotFound, 0},
+		{"database unavailable", nil, breaker.ErrOpen, http.StatusServiceUnavailable, 0},
+		{"database error", nil, errors.New("boom"), http.StatusInternalServerError, 0},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+				return tt.entity, tt.err
+			},
+		}
+		s := &Server{db: db, shortener: shortener.New(db, cache.NewLRU[*database.ShortUrlModel](10, time.Minute))}
+
+		req := httptest.NewRequest(http.MethodGet, "/short/abcdefgh", nil)
+		req.SetPathValue("short_code", "abcdefgh")
+		rec := httptest.NewRecorder()
+		s.redirectUrlHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+
+		if clicks := len(db.CallsTo("UpdateTimesClicked")); clicks != tt.expectedClicks {
+			t.Errorf("%s: expected %d counted clicks; got %d", tt.name, tt.expectedClicks, clicks)
+		}
+
+		if recorded := len(db.CallsTo("RecordClick")); recorded != tt.expectedClicks {
+			t.Errorf("%s: expected %d recorded clicks; got %d", tt.name, tt.expectedClicks, recorded)
+		}
+	}
+}
+
+func TestRedirectPrivateLink(t *testing.T) {
+	ownerId, organizationId := 1, 5
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		entity       *database.ShortUrlModel
+		user         *database.UserModel
+		expectedCode int
+	}{
+		{"unlisted", &database.ShortUrlModel{Visibility: database.VisibilityUnlisted, OwnerId: &ownerId}, nil, http.StatusSeeOther},
+		{"anonymous", &database.ShortUrlModel{Visibility: database.VisibilityPrivate, OwnerId: &ownerId}, nil, http.StatusNotFound},
+		{"owner", &database.ShortUrlModel{Visibility: database.VisibilityPrivate, OwnerId: &ownerId}, &database.UserModel{Id: ownerId}, http.StatusSeeOther},
+		{"someone else", &database.ShortUrlModel{Visibility: database.VisibilityPrivate, OwnerId: &ownerId}, &database.UserModel{Id: 2}, http.StatusNotFound},
+		{"member", &database.ShortUrlModel{Visibility: database.VisibilityPrivate, OwnerId: &ownerId, OrganizationId: &organizationId}, &database.UserModel{Id: 3}, http.StatusSeeOther},
+		{"admin", &database.ShortUrlModel{Visibility: database.VisibilityPrivate, OwnerId: &ownerId}, &database.UserModel{Id: 4, Role: auth.RoleAdmin}, http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		entity := tt.entity
+		entity.Id, entity.ShortCode, entity.Link, entity.ExpTimeMinutes, entity.CreatedAt = 1, "abcdefgh", "https://example.com", 60, now
+
+		db := &mocks.Service{
+			GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+				return entity, nil
+			},
+			GetMembershipFunc: func(organizationId int, userId int) (*database.MemberModel, error) {
+				if userId == 3 {
+					return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: database.OrgRoleViewer}, nil
+				}
+				return nil, database.ErrNotFound
+			},
+		}
+		s := &Server{db: db, shortener: shortener.New(db, cache.NewLRU[*database.ShortUrlModel](10, time.Minute))}
+
+		req := httptest.NewRequest(http.MethodGet, "/short/abcdefgh", nil)
+		req.SetPathValue("short_code", "abcdefgh")
+		if tt.user != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+		}
+		rec := httptest.NewRecorder()
+		s.redirectUrlHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+	}
+}
+
+func TestRedirectPinnedDomain(t *testing.T) {
+	domainId, verifiedAt := 3, time.Now()
+	entity := &database.ShortUrlModel{Id: 1, ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: time.Now(), DomainId: &domainId}
+
+	tests := []struct {
+		name         string
+		host         string
+		expectedCode int
+	}{
+		{"pinned domain", "go.example.com", http.StatusSeeOther},
+		{"pinned domain with port", "GO.example.com:8080", http.StatusSeeOther},
+		{"other domain", "links.example.com", http.StatusNotFound},
+		{"unknown host", "localhost:8080", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+				return entity, nil
+			},
+			GetDomainByHostnameFunc: func(hostname string) (*database.DomainModel, error) {
+				switch hostname {
+				case "go.example.com":
+					return &database.DomainModel{Id: domainId, Hostname: hostname, VerifiedAt: &verifiedAt}, nil
+				case "links.example.com":
+					return &database.DomainModel{Id: domainId + 1, Hostname: hostname, VerifiedAt: &verifiedAt}, nil
+				}
+				return nil, database.ErrNotFound
+			},
+		}
+		s := &Server{
+			db:          db,
+			shortener:   shortener.New(db, cache.NewLRU[*database.ShortUrlModel](10, time.Minute)),
+			domainCache: cache.NewLRU[*database.DomainModel](10, time.Minute),
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/short/abcdefgh", nil)
+		req.Host = tt.host
+		req.SetPathValue("short_code", "abcdefgh")
+		rec := httptest.NewRecorder()
+		s.redirectUrlHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+	}
+}
+
+func TestLinkSummaryHandler(t *testing.T) {
+	db := &mocks.Service{
+		GetLinkSummaryFunc: func(days int) (*database.LinkSummaryModel, error) {
+			return &database.LinkSummaryModel{TotalLinks: 3, ActiveLinks: 2, ExpiredLinks: 1, TotalClicks: 7}, nil
+		},
+	}
+	s := &Server{db: db, summaryCache: cache.NewLRU[*database.LinkSummaryModel](1, time.Minute)}
+	handler := s.RegisterRoutes()
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/short/summary", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d; got %d", http.StatusOK, rec.Code)
+		}
+	}
+
+	if calls := len(db.CallsTo("GetShortUrl")); calls != 0 {
+		t.Errorf("expected the summary not to be resolved as a short code; got %d lookups", calls)
+	}
+
+	if calls := len(db.CallsTo("GetLinkSummary")); calls != 1 {
+		t.Errorf("expected the second summary to be cached; got %d queries", calls)
+	}
+}
@@ -1,39 +1,169 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 
+	"url-shortner/internal/auth"
+	"url-shortner/internal/blobstore"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/captcha"
+	"url-shortner/internal/capture"
 	"url-shortner/internal/database"
+	"url-shortner/internal/dnsverify"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/ratelimit"
+	"url-shortner/internal/safebrowsing"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/tenancy"
+	"url-shortner/internal/unwrap"
 )
 
 type Server struct {
 	port int
 
 	db database.Service
+
+	// Business rules of shortening and following links
+	shortener *shortener.Service
+
+	safeBrowsing *safebrowsing.Client
+
+	// Verifies the CAPTCHA answers of anonymous link creations, nil unless CAPTCHA_PROVIDER is set
+	captcha *captcha.Client
+
+	// Follows the links to other shorteners to store their destination, nil unless UNWRAP_SHORTENERS is set
+	unwrapper *unwrap.Client
+
+	// The last requests and responses, sanitized, nil unless DEBUG_CAPTURE is set
+	capture *capture.Ring
+
+	// Keeps the files of jobs, nil unless BLOBSTORE is set and the database keeps them then
+	blobs blobstore.Store
+
+	// Sends the emails, dropping them unless SMTP_HOST is set
+	mailer mailer.Mailer
+
+	// Shared between replicas when Redis is configured
+	limiter ratelimit.Limiter
+
+	// Sign in providers by name, only those with a client id configured
+	oauthProviders map[string]*auth.Provider
+
+	// Short lived caches for the info and stats endpoints, so polling them doesn't hit the click counters
+	infoCache  *cache.LRU[*database.ShortUrlModel]
+	statsCache *cache.LRU[*database.LinkStatsModel]
+
+	// The summary aggregates every link, it is recomputed at most once a minute
+	summaryCache *cache.LRU[*database.LinkSummaryModel]
+
+	// Verified domains by hostname, nil for hostnames that aren't one
+	domainCache *cache.LRU[*database.DomainModel]
+
+	// Looks up the TXT records proving the ownership of custom domains
+	resolver dnsverify.Resolver
+
+	// Set once the info cache has been preloaded, see warmCache
+	cacheWarm atomic.Bool
+
+	// Name of the tenant served, empty unless TENANT_MODE=schema, see tenantHandler
+	tenant string
+
+	// Done once the http server shuts down, it stops the goroutines watching for changes
+	ctx context.Context
+
+	// Stores the maintenance switch, in the default schema so it is shared by every tenant and replica
+	maintenanceStore database.MaintenanceRepository
 }
 
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	ctx, cancel := context.WithCancel(context.Background())
 	NewServer := &Server{
+		ctx: ctx,
+
 		port: port,
 
 		db: database.New(),
+
+		safeBrowsing: safebrowsing.New(),
+
+		captcha: captcha.New(),
+
+		unwrapper: unwrap.New(),
+
+		capture: capture.New(),
+
+		limiter: ratelimit.New(),
+
+		oauthProviders: auth.OAuthProviders(),
+
+		mailer: mailer.New(),
+
+		resolver: net.DefaultResolver,
+	}
+
+	// Its configuration was checked by Preflight
+	NewServer.blobs, _ = blobstore.New()
+
+	NewServer.maintenanceStore = NewServer.db
+	NewServer.initCaches()
+	NewServer.shortener = shortener.New(NewServer.db, NewServer.infoCache)
+
+	go maintenance.Watch(NewServer.ctx, NewServer.loadMaintenance)
+	go NewServer.watchLinkChanges(NewServer.ctx)
+	go NewServer.warmCache()
+	go NewServer.shortener.WatchCodes()
+	go NewServer.shortener.FlushClicks()
+	go NewServer.runJobs()
+
+	handler := NewServer.RegisterRoutes()
+	if tenancy.Enabled {
+		handler = NewServer.tenantHandler()
 	}
 
 	// Declare Server config
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", NewServer.port),
-		Handler:      NewServer.RegisterRoutes(),
+		Handler:      handler,
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	server.RegisterOnShutdown(cancel)
 
 	return server
 }
+
+// newCommandServer returns a server for the commands of the api, such as import, that run without serving http
+func newCommandServer() (*Server, error) {
+	if err := plans.Load(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{db: database.New(), safeBrowsing: safebrowsing.New(), unwrapper: unwrap.New(), mailer: mailer.New()}
+	s.initCaches()
+	s.shortener = shortener.New(s.db, s.infoCache)
+
+	return s, nil
+}
+
+// initCaches creates the caches of the server, empty
+func (s *Server) initCaches() {
+	s.infoCache = cache.NewLRU[*database.ShortUrlModel](10000, 30*time.Second)
+	s.statsCache = cache.NewLRU[*database.LinkStatsModel](10000, time.Minute)
+
+	s.summaryCache = cache.NewLRU[*database.LinkSummaryModel](1, time.Minute)
+
+	s.domainCache = cache.NewLRU[*database.DomainModel](1000, time.Minute)
+}
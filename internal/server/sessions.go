@@ -0,0 +1,235 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+// Cookie holding the token of a dashboard session
+const sessionCookieName = "session"
+
+// A session ends once unused for SESSION_IDLE_TIMEOUT, and SESSION_ABSOLUTE_TIMEOUT after its sign in however
+// active it is
+const (
+	defaultSessionIdleTimeout = 30 * time.Minute
+	defaultSessionLifetime    = 12 * time.Hour
+)
+
+// Longest user agent stored with a session
+const maxSessionUserAgent = 512
+
+var (
+	sessionIdleTimeout = sessionTimeoutFromEnv("SESSION_IDLE_TIMEOUT", defaultSessionIdleTimeout)
+	sessionLifetime    = sessionTimeoutFromEnv("SESSION_ABSOLUTE_TIMEOUT", defaultSessionLifetime)
+)
+
+// sessionTimeoutFromEnv falls back on invalid values, Preflight refuses to boot with them
+func sessionTimeoutFromEnv(name string, fallback time.Duration) time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || timeout <= 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// checkSessionConfig validates the SESSION_* timeouts
+func checkSessionConfig() error {
+	for _, name := range []string{"SESSION_IDLE_TIMEOUT", "SESSION_ABSOLUTE_TIMEOUT"} {
+		if value := os.Getenv(name); value != "" {
+			if timeout, err := time.ParseDuration(value); err != nil || timeout <= 0 {
+				return fmt.Errorf("invalid %s %q", name, value)
+			}
+		}
+	}
+	if sessionIdleTimeout > sessionLifetime {
+		return fmt.Errorf("invalid SESSION_IDLE_TIMEOUT %s, longer than SESSION_ABSOLUTE_TIMEOUT %s", sessionIdleTimeout, sessionLifetime)
+	}
+	return nil
+}
+
+// sessionRequested reports whether a sign in asked for a session cookie, with ?mode=session, rather than an api key
+func sessionRequested(r *http.Request) bool {
+	return r.URL.Query().Get("mode") == "session"
+}
+
+// secureRequest reports whether the client reached the api over https, directly or through a proxy
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// setSessionCookie hands the token of a session to the browser, out of reach of scripts. An empty token clears it
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expiresAt time.Time) {
+	cookie := &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if token == "" {
+		cookie.Expires, cookie.MaxAge = time.Time{}, -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+type sessionResponse struct {
+	Id         int       `json:"id"`
+	Method     string    `json:"method"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func toSessionResponse(entity *database.SessionModel, current *database.SessionModel) sessionResponse {
+	return sessionResponse{
+		Id:         entity.Id,
+		Method:     entity.Method,
+		Ip:         entity.Ip,
+		UserAgent:  entity.UserAgent,
+		CreatedAt:  entity.CreatedAt,
+		LastSeenAt: entity.LastSeenAt,
+		ExpiresAt:  entity.ExpiresAt,
+		Current:    current != nil && current.Id == entity.Id,
+	}
+}
+
+// startSession signs the user in with a session cookie rather than an api key. Sessions of other browsers are kept
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user *database.UserModel, method string) {
+	token, err := auth.NewState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not complete the sign in.")
+		return
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxSessionUserAgent {
+		userAgent = userAgent[:maxSessionUserAgent]
+	}
+
+	session, err := s.db.SaveSession(&database.SessionModel{
+		UserId:    user.Id,
+		TokenHash: auth.HashToken(token),
+		Method:    method,
+		Ip:        clientIP(r),
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(sessionLifetime),
+	})
+	if err != nil {
+		writeError(w, statusOf(err), "Could not complete the sign in. Try again later")
+		return
+	}
+
+	s.audit(r, "user.sign_in", "user", user.Id, nil, map[string]any{"provider": method, "session": session.Id})
+
+	setSessionCookie(w, r, token, session.ExpiresAt)
+	writeJSON(w, http.StatusOK, struct {
+		Status    int             `json:"status"`
+		User      userResponse    `json:"user"`
+		Session   sessionResponse `json:"session"`
+		CsrfToken string          `json:"csrf_token"`
+	}{
+		Status:    http.StatusOK,
+		User:      toUserResponse(user),
+		Session:   toSessionResponse(session, session),
+		CsrfToken: csrfToken(token),
+	})
+}
+
+// authenticateSession resolves the session cookie of a request without a bearer token into its user. A session
+// which ended is cleared and the request goes through as anonymous, as it would once the browser drops the cookie
+func (s *Server) authenticateSession(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	session, err := s.db.GetSessionByHash(auth.HashToken(cookie.Value), sessionIdleTimeout)
+	if errors.Is(err, database.ErrNotFound) {
+		setSessionCookie(w, r, "", time.Time{})
+		next.ServeHTTP(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("[sessions:authenticateSession] Could not check session: %v", err)
+		writeError(w, statusOf(err), "Could not check the session. Try again later")
+		return
+	}
+
+	ctx := auth.WithSession(auth.WithUser(r.Context(), session.User), session)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// signOutHandler ends the session of the request and clears its cookie. Api keys are revoked through their own
+// endpoints
+func (s *Server) signOutHandler(w http.ResponseWriter, r *http.Request) {
+	if session := auth.SessionFromContext(r.Context()); session != nil {
+		if err := s.db.RevokeSession(session.UserId, session.Id); err != nil && !errors.Is(err, database.ErrNotFound) {
+			writeError(w, statusOf(err), "Could not sign out. Try again later")
+			return
+		}
+		s.audit(r, "user.sign_out", "user", session.UserId, nil, map[string]int{"session": session.Id})
+	}
+
+	setSessionCookie(w, r, "", time.Time{})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+
+	sessions, err := s.db.ListSessions(user.Id, sessionIdleTimeout)
+	if err != nil {
+		writeError(w, statusOf(err), "Could not list the sessions.")
+		return
+	}
+
+	current := auth.SessionFromContext(r.Context())
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, toSessionResponse(session, current))
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status   int               `json:"status"`
+		Sessions []sessionResponse `json:"sessions"`
+	}{
+		Status:   http.StatusOK,
+		Sessions: response,
+	})
+}
+
+// revokeSessionHandler signs out one of the sessions of the user, e.g. of a browser left signed in elsewhere
+func (s *Server) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("session_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid session id.")
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if err := s.db.RevokeSession(user.Id, id); err != nil {
+		writeError(w, statusOf(err), "Could not revoke the session.")
+		return
+	}
+
+	s.audit(r, "user.session_revoke", "user", user.Id, nil, map[string]int{"session": id})
+
+	if current := auth.SessionFromContext(r.Context()); current != nil && current.Id == id {
+		setSessionCookie(w, r, "", time.Time{})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/ratelimit"
+)
+
+func TestAuthenticateSession(t *testing.T) {
+	db := &mocks.Service{
+		GetSessionByHashFunc: func(tokenHash string, idleTimeout time.Duration) (*database.SessionModel, error) {
+			switch tokenHash {
+			case auth.HashToken("active"):
+				return &database.SessionModel{Id: 3, UserId: 1, User: &database.UserModel{Id: 1, Role: auth.RoleEditor}}, nil
+			case auth.HashToken("broken"):
+				return nil, errors.New("connection refused")
+			}
+			return nil, database.ErrNotFound
+		},
+	}
+	s := &Server{db: db}
+
+	tests := []struct {
+		name         string
+		cookie       string
+		expectedCode int
+		expectedUser int
+		cleared      bool
+	}{
+		{name: "anonymous", expectedCode: http.StatusOK},
+		{name: "active", cookie: "active", expectedCode: http.StatusOK, expectedUser: 1},
+		{name: "ended", cookie: "ended", expectedCode: http.StatusOK, cleared: true},
+		{name: "unavailable", cookie: "broken", expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		userId := 0
+		handler := s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := auth.UserFromContext(r.Context()); user != nil && auth.SessionFromContext(r.Context()) != nil {
+				userId = user.Id
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/me/usage", nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expectedCode || userId != tt.expectedUser {
+			t.Errorf("%s: expected %d as user %d; got %d as user %d", tt.name, tt.expectedCode, tt.expectedUser, rec.Code, userId)
+		}
+		if cleared := strings.Contains(rec.Header().Get("Set-Cookie"), "Max-Age=0"); cleared != tt.cleared {
+			t.Errorf("%s: expected the cookie cleared %v; got %q", tt.name, tt.cleared, rec.Header().Get("Set-Cookie"))
+		}
+	}
+
+	if calls := db.CallsTo("GetSessionByHash"); calls[0].Args[1] != sessionIdleTimeout {
+		t.Errorf("expected sessions looked up with the idle timeout; got %v", calls[0].Args[1])
+	}
+}
+
+func TestPasswordSignInSession(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mocks.Service{
+		GetUserPasswordFunc: func(email string) (*database.UserPasswordModel, error) {
+			return &database.UserPasswordModel{User: &database.UserModel{Id: 1, Email: email}, PasswordHash: hash}, nil
+		},
+		SaveSessionFunc: func(session *database.SessionModel) (*database.SessionModel, error) {
+			session.Id = 5
+			return session, nil
+		},
+	}
+	s := &Server{db: db, limiter: ratelimit.NewMemory()}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/password/sign-in?mode=session", strings.NewReader(`{"email": "ada@example.com", "password": "correct horse battery"}`))
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	s.passwordSignInHandler(rec, req)
+
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"key"`) {
+		t.Fatalf("expected a session rather than an api key; got %d %s", rec.Code, rec.Body)
+	}
+	if len(db.CallsTo("SaveApiKey")) != 0 {
+		t.Errorf("expected no api key issued")
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookieName || !cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected a secure, http only session cookie; got %+v", cookies)
+	}
+	saved := db.CallsTo("SaveSession")[0].Args[0].(*database.SessionModel)
+	if saved.TokenHash != auth.HashToken(cookies[0].Value) || saved.Method != "password" {
+		t.Errorf("expected only the hash of the cookie stored; got %+v", saved)
+	}
+	if lifetime := time.Until(saved.ExpiresAt); lifetime > sessionLifetime || lifetime < sessionLifetime-time.Minute {
+		t.Errorf("expected the session to end after %s; got %s", sessionLifetime, lifetime)
+	}
+}
+
+func TestSignOutHandler(t *testing.T) {
+	db := &mocks.Service{}
+	s := &Server{db: db}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/sign-out", nil)
+	ctx := auth.WithUser(context.Background(), &database.UserModel{Id: 1})
+	req = req.WithContext(auth.WithSession(ctx, &database.SessionModel{Id: 3, UserId: 1}))
+	rec := httptest.NewRecorder()
+	s.signOutHandler(rec, req)
+
+	if rec.Code != http.StatusNoContent || !strings.Contains(rec.Header().Get("Set-Cookie"), "Max-Age=0") {
+		t.Errorf("expected the cookie cleared; got %d %q", rec.Code, rec.Header().Get("Set-Cookie"))
+	}
+	if revoked := db.CallsTo("RevokeSession"); len(revoked) != 1 || revoked[0].Args[0] != 1 || revoked[0].Args[1] != 3 {
+		t.Errorf("expected the session revoked; got %+v", revoked)
+	}
+}
+
+func TestCheckSessionConfig(t *testing.T) {
+	defer func(idle, lifetime time.Duration) { sessionIdleTimeout, sessionLifetime = idle, lifetime }(sessionIdleTimeout, sessionLifetime)
+
+	t.Setenv("SESSION_IDLE_TIMEOUT", "soon")
+	if err := checkSessionConfig(); err == nil {
+		t.Errorf("expected an invalid timeout refused")
+	}
+
+	t.Setenv("SESSION_IDLE_TIMEOUT", "")
+	sessionIdleTimeout, sessionLifetime = 2*time.Hour, time.Hour
+	if err := checkSessionConfig(); err == nil {
+		t.Errorf("expected an idle timeout longer than the absolute one refused")
+	}
+}
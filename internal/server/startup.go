@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"url-shortner/internal/blobstore"
+	"url-shortner/internal/captcha"
+	"url-shortner/internal/database"
+	"url-shortner/internal/i18n"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/metrics"
+	"url-shortner/internal/notify"
+	"url-shortner/internal/plans"
+	"url-shortner/internal/ratelimit"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/tenancy"
+)
+
+// Preflight validates the configuration, the database connection and the schema version, so a bad
+// deployment fails on boot with a clear error instead of on its first request
+func Preflight() error {
+	if err := database.CheckConfig(); err != nil {
+		return err
+	}
+
+	if err := plans.Load(); err != nil {
+		return err
+	}
+
+	if err := i18n.Load(); err != nil {
+		return err
+	}
+
+	if err := ratelimit.CheckConfig(); err != nil {
+		return err
+	}
+
+	if err := shortener.CheckConfig(); err != nil {
+		return err
+	}
+
+	if err := metrics.CheckConfig(); err != nil {
+		return err
+	}
+
+	if err := notify.CheckConfig(); err != nil {
+		return err
+	}
+
+	if err := mailer.CheckConfig(); err != nil {
+		return err
+	}
+
+	if _, err := blobstore.New(); err != nil {
+		return err
+	}
+
+	if err := checkAccessLog(); err != nil {
+		return err
+	}
+
+	if err := checkSessionConfig(); err != nil {
+		return err
+	}
+
+	if err := captcha.CheckConfig(); err != nil {
+		return err
+	}
+
+	if err := tenancy.Load(); err != nil {
+		return err
+	}
+
+	if port, err := strconv.Atoi(os.Getenv("PORT")); err != nil || port <= 0 {
+		return fmt.Errorf("invalid PORT %q", os.Getenv("PORT"))
+	}
+
+	db := database.New()
+
+	health := db.Health()
+	if health.Status == database.HealthDown {
+		return fmt.Errorf("database unreachable: %s", health.Components["primary"].Error)
+	}
+
+	if database.Bootstrap {
+		if _, err := db.BootstrapSchema(); err != nil {
+			return fmt.Errorf("could not bootstrap the schema: %w", err)
+		}
+	}
+
+	if err := checkSchema(db); err != nil {
+		return fmt.Errorf("database schema out of date, run the migrations first: %w", err)
+	}
+
+	// The schema of each tenant is migrated here, goose only knows about the default one
+	for _, tenant := range tenancy.All() {
+		if _, err := database.MigrateSchema(tenant.Schema()); err != nil {
+			return fmt.Errorf("could not migrate the schema of tenant %s: %w", tenant.Name, err)
+		}
+	}
+
+	return nil
+}
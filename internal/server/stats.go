@@ -0,0 +1,174 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+)
+
+const statsDays = 30
+
+type dailyClicksResponse struct {
+	Day    string `json:"day"`
+	Clicks int    `json:"clicks"`
+}
+
+type dailyLinksResponse struct {
+	Day   string `json:"day"`
+	Links int    `json:"links"`
+}
+
+// visibleLink loads the link of the short code of the path through the info cache. It writes the error response
+// and returns nil when the link doesn't exist or is private to someone else, see visibleTo
+func (s *Server) visibleLink(w http.ResponseWriter, r *http.Request) *database.ShortUrlModel {
+	shortCode := r.PathValue("short_code")
+
+	entity, ok := s.infoCache.Get(shortCode)
+	if !ok {
+		var err error
+		entity, err = s.db.GetShortUrl(shortCode)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Did not found a valid url for the short_code")
+			return nil
+		}
+		if err != nil {
+			writeError(w, statusOf(err), "Could not load the link.")
+			return nil
+		}
+		s.infoCache.Set(shortCode, entity)
+	}
+
+	if !s.visibleTo(r, entity) {
+		writeError(w, http.StatusNotFound, "Did not found a valid url for the short_code")
+		return nil
+	}
+
+	return entity
+}
+
+func (s *Server) linkInfoHandler(w http.ResponseWriter, r *http.Request) {
+	entity := s.visibleLink(w, r)
+	if entity == nil {
+		return
+	}
+
+	expiresAt := entity.CreatedAt.Add(time.Duration(entity.ExpTimeMinutes) * time.Minute)
+
+	writeJSONWithETag(w, r, struct {
+		Status       int       `json:"status"`
+		ShortCode    string    `json:"short_code"`
+		Link         string    `json:"link"`
+		DisplayLink  string    `json:"display_link"`
+		Warnings     []string  `json:"warnings,omitempty"`
+		CreatedAt    time.Time `json:"created_at"`
+		ExpiresAt    time.Time `json:"expires_at"`
+		Expired      bool      `json:"expired"`
+		Disabled     bool      `json:"disabled"`
+		Visibility   string    `json:"visibility"`
+		TimesClicked int       `json:"times_clicked"`
+	}{
+		Status:       http.StatusOK,
+		ShortCode:    entity.ShortCode,
+		Link:         entity.Link,
+		DisplayLink:  shortener.DisplayLink(entity.Link),
+		Warnings:     linkWarnings(entity.Link),
+		CreatedAt:    entity.CreatedAt,
+		ExpiresAt:    expiresAt,
+		Expired:      time.Now().After(expiresAt),
+		Disabled:     entity.DisabledAt != nil,
+		Visibility:   entity.Visibility,
+		TimesClicked: entity.TimesClicked,
+	})
+}
+
+func (s *Server) linkStatsHandler(w http.ResponseWriter, r *http.Request) {
+	entity := s.visibleLink(w, r)
+	if entity == nil {
+		return
+	}
+	// Links without an owner can't be private, their stats are for whoever holds the edit token
+	if entity.OwnerId == nil && !isAdminToken(bearerToken(r)) && s.editableLink(w, r) == nil {
+		return
+	}
+	shortCode := r.PathValue("short_code")
+
+	stats, ok := s.statsCache.Get(shortCode)
+	if !ok {
+		var err error
+		stats, err = s.db.GetLinkStats(shortCode, statsDays)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Did not found a valid url for the short_code")
+			return
+		}
+		if err != nil {
+			writeError(w, statusOf(err), "Could not load the stats.")
+			return
+		}
+		s.statsCache.Set(shortCode, stats)
+	}
+
+	daily := make([]dailyClicksResponse, 0, len(stats.Daily))
+	for _, day := range stats.Daily {
+		daily = append(daily, dailyClicksResponse{
+			Day:    day.Day.Format(time.DateOnly),
+			Clicks: day.Clicks,
+		})
+	}
+
+	writeJSONWithETag(w, r, struct {
+		Status         int                   `json:"status"`
+		ShortCode      string                `json:"short_code"`
+		TotalClicks    int                   `json:"total_clicks"`
+		UniqueVisitors int                   `json:"unique_visitors"`
+		Daily          []dailyClicksResponse `json:"daily"`
+	}{
+		Status:         http.StatusOK,
+		ShortCode:      stats.ShortCode,
+		TotalClicks:    stats.TotalClicks,
+		UniqueVisitors: stats.UniqueVisitors,
+		Daily:          daily,
+	})
+}
+
+// Key of the single entry of the summary cache
+const summaryCacheKey = "summary"
+
+func (s *Server) linkSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	summary, ok := s.summaryCache.Get(summaryCacheKey)
+	if !ok {
+		var err error
+		summary, err = s.db.GetLinkSummary(statsDays)
+		if err != nil {
+			writeError(w, statusOf(err), "Could not load the summary.")
+			return
+		}
+		s.summaryCache.Set(summaryCacheKey, summary)
+	}
+
+	daily := make([]dailyLinksResponse, 0, len(summary.Daily))
+	for _, day := range summary.Daily {
+		daily = append(daily, dailyLinksResponse{
+			Day:   day.Day.Format(time.DateOnly),
+			Links: day.Links,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status       int                  `json:"status"`
+		TotalLinks   int                  `json:"total_links"`
+		ActiveLinks  int                  `json:"active_links"`
+		ExpiredLinks int                  `json:"expired_links"`
+		TotalClicks  int                  `json:"total_clicks"`
+		Daily        []dailyLinksResponse `json:"daily"`
+	}{
+		Status:       http.StatusOK,
+		TotalLinks:   summary.TotalLinks,
+		ActiveLinks:  summary.ActiveLinks,
+		ExpiredLinks: summary.ExpiredLinks,
+		TotalClicks:  summary.TotalClicks,
+		Daily:        daily,
+	})
+}
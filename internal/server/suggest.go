@@ -0,0 +1,51 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"url-shortner/internal/database"
+)
+
+// How many codes suggestCodesHandler returns
+const suggestionCount = 5
+
+// suggestCodesHandler suggests available codes to choose when shortening, e.g. GET /short/suggest?hint=summer-sale.
+// Without a hint the suggestions are made of dictionary words
+func (s *Server) suggestCodesHandler(w http.ResponseWriter, r *http.Request) {
+	hint := r.URL.Query().Get("hint")
+	if len(hint) > 100 {
+		writeError(w, http.StatusBadRequest, "The hint can't be longer than 100 characters.")
+		return
+	}
+
+	var namespace *database.NamespaceModel
+	if name := r.URL.Query().Get("namespace"); name != "" {
+		var err error
+		namespace, err = s.db.GetNamespaceByName(name)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusBadRequest, "Unknown namespace.")
+			return
+		}
+		if err != nil {
+			writeError(w, statusOf(err), "Could not suggest codes.")
+			return
+		}
+	}
+
+	suggestions, err := s.shortener.Suggest(hint, namespace, suggestionCount)
+	if err != nil {
+		log.Printf("[suggest:suggestCodesHandler] Could not suggest codes for hint {%s}: %v", hint, err)
+		writeError(w, statusOf(err), "Could not suggest codes.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Status      int      `json:"status"`
+		Suggestions []string `json:"suggestions"`
+	}{
+		Status:      http.StatusOK,
+		Suggestions: suggestions,
+	})
+}
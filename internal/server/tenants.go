@@ -0,0 +1,88 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"url-shortner/internal/capture"
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/tenancy"
+)
+
+// tenantServer builds the Server of a tenant: the clients of s shared in front of the schema of the tenant, with
+// caches and debug captures of its own so nothing read for one tenant is ever served to another
+func (s *Server) tenantServer(tenant *tenancy.Tenant) (*Server, error) {
+	db, err := database.ForSchema(tenant.Schema())
+	if err != nil {
+		return nil, err
+	}
+
+	tenantServer := &Server{
+		port:           s.port,
+		db:             db,
+		safeBrowsing:   s.safeBrowsing,
+		captcha:        s.captcha,
+		unwrapper:      s.unwrapper,
+		capture:        capture.New(),
+		blobs:          s.blobs,
+		mailer:         s.mailer,
+		limiter:        s.limiter,
+		oauthProviders: s.oauthProviders,
+		resolver:       s.resolver,
+		tenant:         tenant.Name,
+		ctx:            s.ctx,
+
+		maintenanceStore: s.maintenanceStore,
+	}
+	tenantServer.initCaches()
+	tenantServer.shortener = shortener.New(db, tenantServer.infoCache)
+
+	go tenantServer.watchLinkChanges(tenantServer.ctx)
+	go tenantServer.warmCache()
+	go tenantServer.shortener.WatchCodes()
+	go tenantServer.shortener.FlushClicks()
+	go tenantServer.runJobs()
+
+	return tenantServer, nil
+}
+
+// tenantHandler serves each request with the routes of its tenant, built on its first request. The probes are
+// about the process and answered by s, requests matching no tenant get a 404
+func (s *Server) tenantHandler() http.Handler {
+	probes := s.RegisterRoutes()
+
+	var mu sync.Mutex
+	handlers := map[string]http.Handler{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedPaths[r.URL.Path] {
+			probes.ServeHTTP(w, r)
+			return
+		}
+
+		tenant, r := tenancy.Resolve(r)
+		if tenant == nil {
+			writeError(w, http.StatusNotFound, "Unknown tenant.")
+			return
+		}
+
+		mu.Lock()
+		handler, ok := handlers[tenant.Name]
+		if !ok {
+			tenantServer, err := s.tenantServer(tenant)
+			if err != nil {
+				mu.Unlock()
+				log.Printf("[tenants:tenantHandler] Could not serve tenant {%s}: %v", tenant.Name, err)
+				writeError(w, http.StatusServiceUnavailable, "Service temporarily unavailable. Try again later")
+				return
+			}
+			handler = tenantServer.RegisterRoutes()
+			handlers[tenant.Name] = handler
+		}
+		mu.Unlock()
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortner/internal/mocks"
+)
+
+func TestTenantHandler(t *testing.T) {
+	s := &Server{db: &mocks.Service{}}
+	handler := s.tenantHandler()
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+	}{
+		{"probe", "/livez", http.StatusOK},
+		{"unknown tenant", "/short/abcdefgh", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = "unknown.example.com"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d", tt.name, tt.expectedCode, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/policy"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/unwrap"
+)
+
+// unwrapDestination follows link while it points at a known shortener or at a link of this instance, and returns
+// the destination it ends at, normalized. The links of this instance are looked up rather than requested. Nothing
+// is followed unless UNWRAP_SHORTENERS is set. A shortener that can't be reached leaves the link where it was, a
+// chain longer than UNWRAP_MAX_HOPS fails with unwrap.ErrTooManyHops.
+// host is the host the instance was reached on
+func (s *Server) unwrapDestination(ctx context.Context, host string, link string) (string, error) {
+	if s.unwrapper == nil {
+		return link, nil
+	}
+
+	for hop := 0; hop < s.unwrapper.MaxHops; hop++ {
+		next, ok, err := s.nextHop(ctx, host, link)
+		if err != nil {
+			log.Printf("[unwrap:unwrapDestination] Could not follow {%s}, keeping it: %v", link, err)
+			return link, nil
+		}
+		if !ok {
+			return link, nil
+		}
+		slog.Debug("Following a short link", "link", link, "next", next)
+
+		if err := shortener.Validate(next, 0); err != nil {
+			log.Printf("[unwrap:unwrapDestination] {%s} redirects to an invalid link, keeping it: %v", link, err)
+			return link, nil
+		}
+		if link, err = shortener.NormalizeLink(next); err != nil {
+			return "", err
+		}
+	}
+
+	if _, ok, _ := s.nextHop(ctx, host, link); ok {
+		log.Printf("[unwrap:unwrapDestination] Gave up on {%s} after {%d} redirects", link, s.unwrapper.MaxHops)
+		return "", unwrap.ErrTooManyHops
+	}
+	return link, nil
+}
+
+// nextHop returns where link redirects to, see unwrap.Client.Next
+func (s *Server) nextHop(ctx context.Context, host string, link string) (string, bool, error) {
+	if shortCode, ok := s.ownShortCode(host, link); ok {
+		entity, err := s.shortener.Resolve(shortCode)
+		if err != nil || entity.Visibility == database.VisibilityPrivate {
+			// Unknown, disabled, expired or private links are left for the destination policies
+			return "", false, nil
+		}
+		return entity.Link, true, nil
+	}
+
+	return s.unwrapper.Next(ctx, link)
+}
+
+// ownShortCode returns the short code of link when it is the short url of a link of this instance: its host is
+// host or one of the verified domains, and its path one of the redirect routes
+func (s *Server) ownShortCode(host string, link string) (string, bool) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+
+	if !s.ownHost(host, policy.HostOf(link)) {
+		return "", false
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch {
+	case len(segments) == 2 && segments[0] == "short":
+		return shortener.NormalizeCode(segments[1]), true
+	case len(segments) == 3 && segments[0] == "short":
+		return shortener.NormalizeCode(segments[1] + "/" + segments[2]), true
+	case len(segments) == 2:
+		// Go links style, /{namespace}/{code}
+		return shortener.NormalizeCode(segments[0] + "/" + segments[1]), true
+	}
+	return "", false
+}
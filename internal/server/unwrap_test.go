@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/shortener"
+	"url-shortner/internal/unwrap"
+)
+
+func TestUnwrapDestination(t *testing.T) {
+	t.Setenv("UNWRAP_SHORTENERS", "true")
+
+	now := time.Now()
+	links := map[string]*database.ShortUrlModel{
+		"docs/intro": {ShortCode: "docs/intro", Link: "https://sho.rt/short/abcdefgh", ExpTimeMinutes: 60, CreatedAt: now},
+		"abcdefgh":   {ShortCode: "abcdefgh", Link: "https://example.com/intro", ExpTimeMinutes: 60, CreatedAt: now},
+		"loop":       {ShortCode: "loop", Link: "https://go.example.org/short/loop", ExpTimeMinutes: 60, CreatedAt: now},
+		"expired":    {ShortCode: "expired", Link: "https://example.com/old", ExpTimeMinutes: 1, CreatedAt: now.Add(-time.Hour)},
+	}
+
+	tests := []struct {
+		name        string
+		link        string
+		expected    string
+		expectedErr error
+	}{
+		{"other destination", "https://example.com/page", "https://example.com/page", nil},
+		{"own link", "https://sho.rt/short/abcdefgh", "https://example.com/intro", nil},
+		{"chain of own links", "https://sho.rt/docs/intro", "https://example.com/intro", nil},
+		{"custom domain", "https://go.example.org/short/abcdefgh", "https://example.com/intro", nil},
+		{"unknown code", "https://sho.rt/short/missing", "https://sho.rt/short/missing", nil},
+		{"expired link", "https://sho.rt/short/expired", "https://sho.rt/short/expired", nil},
+		{"loop", "https://sho.rt/short/loop", "", unwrap.ErrTooManyHops},
+	}
+
+	for _, tt := range tests {
+		domainId, verifiedAt := 3, now
+		db := &mocks.Service{
+			GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+				if entity, ok := links[shortCode]; ok {
+					return entity, nil
+				}
+				return nil, database.ErrNotFound
+			},
+			GetDomainByHostnameFunc: func(hostname string) (*database.DomainModel, error) {
+				if hostname == "go.example.org" {
+					return &database.DomainModel{Id: domainId, Hostname: hostname, VerifiedAt: &verifiedAt}, nil
+				}
+				return nil, database.ErrNotFound
+			},
+		}
+		s := &Server{db: db, unwrapper: unwrap.New()}
+		s.initCaches()
+		s.shortener = shortener.New(db, s.infoCache)
+
+		link, err := s.unwrapDestination(context.Background(), "sho.rt", tt.link)
+		if link != tt.expected || !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %q, %v; got %q, %v", tt.name, tt.expected, tt.expectedErr, link, err)
+		}
+	}
+}
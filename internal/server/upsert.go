@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/shortener"
+)
+
+// upsertLinkHandler makes sure the link with the code of the path points to the destination of the body: it creates
+// it, or updates the link when it belongs to the caller or to their organization. Deploy pipelines call it again and
+// again with the same body
+func (s *Server) upsertLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		LinkToShort    string `json:"link_to_short"`
+		ExpTimeMinutes int    `json:"exp_time_minutes"`
+		OrganizationId *int   `json:"organization_id"`
+		Visibility     string `json:"visibility"`
+	}
+
+	err := decodeLinkBody(w, r, &reqBody)
+	if errors.Is(err, shortener.ErrLinkTooLong) {
+		writeShortenError(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+
+	if err := shortener.Validate(reqBody.LinkToShort, reqBody.ExpTimeMinutes); err != nil {
+		writeShortenError(w, err)
+		return
+	}
+
+	link, err := shortener.NormalizeLink(reqBody.LinkToShort)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	link, err = s.unwrapDestination(r.Context(), requestHost(r), link)
+	if err != nil {
+		writeShortenError(w, err)
+		return
+	}
+
+	assessment, ok := s.checkDestination(w, r, link)
+	if !ok {
+		return
+	}
+
+	creator := creatorOf(r)
+	creator.OrganizationId = reqBody.OrganizationId
+	options := shortener.Options{Code: r.PathValue("short_code"), Visibility: reqBody.Visibility, Review: reviewOf(assessment)}
+
+	if name, code, ok := strings.Cut(options.Code, "/"); ok {
+		namespace, err := s.db.GetNamespaceByName(name)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "Unknown namespace.")
+			return
+		}
+		if err != nil {
+			writeError(w, statusOf(err), "Something went wrong with generating short url. Try again later")
+			return
+		}
+		options.Namespace, options.Code = namespace, code
+	}
+
+	// The link as it was, for the audit log. A code that isn't taken yet leaves it nil
+	previous, err := s.db.GetShortUrl(r.PathValue("short_code"))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		log.Printf("[upsert:upsertLinkHandler] Could not load link {%s} before upserting it: %v", options.Code, err)
+	}
+
+	entity, created, err := s.shortener.Upsert(link, reqBody.ExpTimeMinutes, creator, options)
+	if errors.Is(err, database.ErrDisabled) {
+		writeJSON(w, http.StatusConflict, errorResponse{
+			Status:  http.StatusConflict,
+			Message: "The link is disabled or under review, it can't be updated until it is restored.",
+			Field:   "short_code",
+		})
+		return
+	}
+	if errors.Is(err, database.ErrDuplicateCode) {
+		writeJSON(w, http.StatusConflict, errorResponse{
+			Status:  http.StatusConflict,
+			Message: "The code belongs to a link you can't update.",
+			Field:   "short_code",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[upsert:upsertLinkHandler] Could not upsert link {%s}: %v", options.Code, err)
+		writeShortenError(w, err)
+		return
+	}
+
+	s.forgetLink(entity.ShortCode)
+
+	status, action, before := http.StatusOK, "link.update", any(nil)
+	if created {
+		status, action = http.StatusCreated, "link.create"
+	} else if previous != nil {
+		before = toLinkResponse(previous)
+	}
+	s.audit(r, action, "link", entity.ShortCode, before, toLinkResponse(entity))
+
+	writeJSON(w, status, struct {
+		Status      int    `json:"status"`
+		ShortUrl    string `json:"short_url"`
+		Created     bool   `json:"created"`
+		UnderReview bool   `json:"under_review,omitempty"`
+	}{
+		Status:      status,
+		ShortUrl:    shortUrlOf(r, r.Host, entity.ShortCode),
+		Created:     created,
+		UnderReview: options.Review != nil,
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+)
+
+// How long an email verification can be used
+const emailVerificationTTL = 24 * time.Hour
+
+// Sign ups per client ip and verification emails per user within their window, so neither can be used to flood
+// mailboxes
+const (
+	signUpsPerIp  = 5
+	signUpWindow  = time.Hour
+	verifyPerUser = 3
+	verifyWindow  = time.Hour
+)
+
+// How long emailing a verification may hold its request
+const emailVerificationTimeout = 10 * time.Second
+
+// signUpHandler creates an editor with a password and signs it in. The account can't create links until its email
+// is verified, with the link emailed here
+func (s *Server) signUpHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&reqBody)
+
+	email := strings.ToLower(strings.TrimSpace(reqBody.Email))
+	if !strings.Contains(email, "@") {
+		writeError(w, http.StatusBadRequest, "Invalid email.")
+		return
+	}
+	if err := auth.ValidatePassword(reqBody.Password); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid password, "+err.Error()+".")
+		return
+	}
+	if !s.allowPasswordAttempt(w, r, "sign_up:ip:"+clientIP(r), signUpsPerIp, signUpWindow) {
+		return
+	}
+
+	hash, err := auth.HashPassword(reqBody.Password)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not sign up.")
+		return
+	}
+
+	user, err := s.db.SignUp(email, hash)
+	if writeConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not sign up.")
+		return
+	}
+
+	s.audit(r, "user.sign_up", "user", user.Id, nil, map[string]string{"email": user.Email})
+
+	// The account exists either way, a verification can be asked again once signed in
+	if err := s.sendEmailVerification(r, user); err != nil {
+		log.Printf("[verifications:signUpHandler] Could not send the email verification of user {%d}: %v", user.Id, err)
+	}
+
+	s.signIn(w, r, user, "password", sessionRequested(r))
+}
+
+// sendEmailVerification stores a verification of the current email of the user and emails its link. The link is
+// built on PUBLIC_BASE_URL, nothing is stored nor sent without it
+func (s *Server) sendEmailVerification(r *http.Request, user *database.UserModel) error {
+	token, err := auth.NewState()
+	if err != nil {
+		return err
+	}
+	verifyUrl, err := mailer.Url("/auth/email/verify?token=" + token)
+	if err != nil {
+		return err
+	}
+
+	verification, err := s.db.SaveEmailVerification(&database.EmailVerificationModel{
+		UserId:    user.Id,
+		Email:     user.Email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	message, err := mailer.Compose(user.Email, "email_verification", struct {
+		VerifyUrl string
+		ExpiresAt time.Time
+	}{verifyUrl, verification.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), emailVerificationTimeout)
+	defer cancel()
+	return s.mailer.Send(ctx, message)
+}
+
+// verifyEmailHandler verifies the email of the user of a verification token. It is a GET so the emailed link works
+// when opened, the token still works only once
+func (s *Server) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "A verification token is required.")
+		return
+	}
+
+	user, err := s.db.VerifyEmail(auth.HashToken(token))
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusBadRequest, "The verification link is invalid, has expired or was already used.")
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), "Could not verify the email.")
+		return
+	}
+
+	s.audit(r, "user.email_verify", "user", user.Id, nil, map[string]string{"email": user.Email})
+
+	writeJSON(w, http.StatusOK, struct {
+		Status int          `json:"status"`
+		User   userResponse `json:"user"`
+	}{
+		Status: http.StatusOK,
+		User:   toUserResponse(user),
+	})
+}
+
+// resendEmailVerificationHandler emails the authenticated user a new verification of their email
+func (s *Server) resendEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user.EmailVerifiedAt != nil {
+		writeError(w, http.StatusConflict, "The email is already verified.")
+		return
+	}
+	if !s.allowPasswordAttempt(w, r, "email_verification:user:"+strconv.Itoa(user.Id), verifyPerUser, verifyWindow) {
+		return
+	}
+
+	if err := s.sendEmailVerification(r, user); err != nil {
+		log.Printf("[verifications:resendEmailVerificationHandler] Could not send the email verification of user {%d}: %v", user.Id, err)
+		writeError(w, statusOf(err), "Could not send the email verification. Try again later")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  http.StatusAccepted,
+		Message: "A verification link is on its way to " + user.Email + ".",
+	})
+}
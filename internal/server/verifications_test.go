@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mailer"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/ratelimit"
+)
+
+func TestSignUpHandler(t *testing.T) {
+	db := &mocks.Service{
+		SignUpFunc: func(email string, passwordHash string) (*database.UserModel, error) {
+			if email == "taken@example.com" {
+				return nil, fmt.Errorf("sign up %s: %w", email, &database.ConflictError{Field: "email"})
+			}
+			return &database.UserModel{Id: 1, Email: email, Role: auth.RoleEditor}, nil
+		},
+		SaveEmailVerificationFunc: func(verification *database.EmailVerificationModel) (*database.EmailVerificationModel, error) {
+			return verification, nil
+		},
+		SaveApiKeyFunc: func(apiKey *database.ApiKeyModel) (*database.ApiKeyModel, error) {
+			return apiKey, nil
+		},
+	}
+	mail := &mailer.Capture{}
+	s := &Server{db: db, mailer: mail, limiter: ratelimit.NewMemory()}
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt")
+
+	tests := []struct {
+		email        string
+		password     string
+		expectedCode int
+	}{
+		{"Ada@example.com", "correct horse battery", http.StatusOK},
+		{"taken@example.com", "correct horse battery", http.StatusConflict},
+		{"ada@example.com", "short", http.StatusBadRequest},
+		{"not an email", "correct horse battery", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{"email": tt.email, "password": tt.password})
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/sign-up", strings.NewReader(string(body)))
+		req.Host = "evil.example"
+		rec := httptest.NewRecorder()
+		s.signUpHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s with %q: expected %d; got %d %s", tt.email, tt.password, tt.expectedCode, rec.Code, rec.Body)
+		}
+	}
+
+	signedUp := db.CallsTo("SignUp")[0]
+	if signedUp.Args[0] != "ada@example.com" || !auth.CheckPassword(signedUp.Args[1].(string), "correct horse battery") {
+		t.Errorf("expected the lowercased email and the hashed password stored; got %+v", signedUp.Args)
+	}
+
+	sent := mail.Messages()
+	if len(sent) != 1 || sent[0].To != "ada@example.com" {
+		t.Fatalf("expected a verification sent to the new account; got %+v", sent)
+	}
+	if !strings.Contains(sent[0].Text, "https://sho.rt/auth/email/verify?token=") {
+		t.Errorf("expected the verification linked on PUBLIC_BASE_URL, not the request host; got %s", sent[0].Text)
+	}
+	saved := db.CallsTo("SaveEmailVerification")[0].Args[0].(*database.EmailVerificationModel)
+	token := sent[0].Text[strings.Index(sent[0].Text, "?token=")+len("?token="):]
+	token = token[:strings.Index(token, "\n")]
+	if saved.TokenHash != auth.HashToken(token) || saved.Email != "ada@example.com" {
+		t.Errorf("expected only the hash of the emailed token stored, for the email it was sent to; got %+v", saved)
+	}
+}
+
+func TestVerifyEmailHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		expectedCode int
+	}{
+		{name: "valid", token: "valid", expectedCode: http.StatusOK},
+		{name: "used", token: "used", expectedCode: http.StatusBadRequest},
+		{name: "missing", token: "", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			VerifyEmailFunc: func(tokenHash string) (*database.UserModel, error) {
+				if tokenHash != auth.HashToken("valid") {
+					return nil, database.ErrNotFound
+				}
+				verified := time.Now()
+				return &database.UserModel{Id: 1, Email: "ada@example.com", EmailVerifiedAt: &verified}, nil
+			},
+		}
+		s := &Server{db: db}
+
+		req := httptest.NewRequest(http.MethodGet, "/auth/email/verify?token="+tt.token, nil)
+		rec := httptest.NewRecorder()
+		s.verifyEmailHandler(rec, req)
+
+		if rec.Code != tt.expectedCode {
+			t.Errorf("%s: expected %d; got %d %s", tt.name, tt.expectedCode, rec.Code, rec.Body)
+			continue
+		}
+		if rec.Code == http.StatusOK {
+			var response struct {
+				User userResponse `json:"user"`
+			}
+			json.NewDecoder(rec.Body).Decode(&response)
+			if !response.User.EmailVerified {
+				t.Errorf("%s: expected the user answered verified; got %+v", tt.name, response.User)
+			}
+		}
+	}
+}
+
+func TestResendEmailVerificationHandler(t *testing.T) {
+	db := &mocks.Service{
+		SaveEmailVerificationFunc: func(verification *database.EmailVerificationModel) (*database.EmailVerificationModel, error) {
+			return verification, nil
+		},
+	}
+	mail := &mailer.Capture{}
+	s := &Server{db: db, mailer: mail, limiter: ratelimit.NewMemory()}
+
+	resend := func(user *database.UserModel) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/me/email-verification", nil)
+		req = req.WithContext(auth.WithUser(context.Background(), user))
+		rec := httptest.NewRecorder()
+		s.resendEmailVerificationHandler(rec, req)
+		return rec
+	}
+
+	verified := time.Now()
+	if rec := resend(&database.UserModel{Id: 2, Email: "bob@example.com", EmailVerifiedAt: &verified}); rec.Code != http.StatusConflict {
+		t.Errorf("expected a verified email refused; got %d %s", rec.Code, rec.Body)
+	}
+
+	unverified := &database.UserModel{Id: 1, Email: "ada@example.com"}
+	t.Setenv("PUBLIC_BASE_URL", "")
+	if rec := resend(unverified); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected no verification without PUBLIC_BASE_URL; got %d %s", rec.Code, rec.Body)
+	}
+	if saved := db.CallsTo("SaveEmailVerification"); len(saved) != 0 || len(mail.Messages()) != 0 {
+		t.Errorf("expected nothing stored nor sent without PUBLIC_BASE_URL; got %d stored, %d sent", len(saved), len(mail.Messages()))
+	}
+
+	t.Setenv("PUBLIC_BASE_URL", "https://sho.rt")
+	for range verifyPerUser - 1 {
+		if rec := resend(unverified); rec.Code != http.StatusAccepted {
+			t.Errorf("expected a verification sent; got %d %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := resend(unverified); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the verifications of a user limited; got %d", rec.Code)
+	}
+	if sent := mail.Messages(); len(sent) != verifyPerUser-1 || !strings.Contains(sent[0].HTML, "https://sho.rt/auth/email/verify?token=") {
+		t.Errorf("expected %d verifications linking the verify endpoint; got %+v", verifyPerUser-1, sent)
+	}
+}
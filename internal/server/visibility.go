@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+// visibleTo reports whether the request may follow or look up the link. Private links are only visible to their
+// owner, to the members of their organization and to admins; public and unlisted links to everyone
+func (s *Server) visibleTo(r *http.Request, entity *database.ShortUrlModel) bool {
+	if entity.Visibility != database.VisibilityPrivate || isAdminToken(bearerToken(r)) {
+		return true
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return false
+	}
+	if entity.OwnerId != nil && *entity.OwnerId == user.Id {
+		return true
+	}
+	if user.Role == auth.RoleAdmin && auth.HasScope(r.Context(), auth.ScopeAdmin) {
+		return true
+	}
+
+	if entity.OrganizationId != nil {
+		_, err := s.db.GetMembership(*entity.OrganizationId, user.Id)
+		return err == nil
+	}
+	return false
+}
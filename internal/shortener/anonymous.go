@@ -0,0 +1,115 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Policies of ANONYMOUS_LINKS on the links created without signing in: a public tool allows them, possibly with
+// reduced limits, while a corporate deployment blocks them
+const (
+	AnonymousAllowed = "allowed"
+	AnonymousLimited = "limited"
+	AnonymousBlocked = "blocked"
+)
+
+// Longest expiry of anonymous links under the limited policy unless ANONYMOUS_MAX_EXP_MINUTES says otherwise, a day
+const defaultAnonymousMaxExpMinutes = 24 * 60
+
+var (
+	anonymousLinks         = anonymousPolicyFromEnv()
+	anonymousMaxExpMinutes = anonymousMaxExpFromEnv()
+
+	// Anonymous links all visitors together may create per UTC day under the limited policy, zero meaning no limit
+	anonymousLinksPerDay = quotaFromEnv("ANONYMOUS_LINKS_PER_DAY")
+)
+
+var ErrAnonymousBlocked = errors.New("anonymous links are disabled, sign in or use an api key")
+
+// anonymousPolicyFromEnv reads ANONYMOUS_LINKS, allowing anonymous links on invalid values, Preflight refuses to
+// boot with them
+func anonymousPolicyFromEnv() string {
+	switch policy := os.Getenv("ANONYMOUS_LINKS"); policy {
+	case AnonymousLimited, AnonymousBlocked:
+		return policy
+	default:
+		return AnonymousAllowed
+	}
+}
+
+func anonymousMaxExpFromEnv() int {
+	minutes, err := strconv.Atoi(os.Getenv("ANONYMOUS_MAX_EXP_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return defaultAnonymousMaxExpMinutes
+	}
+	return minutes
+}
+
+// checkAnonymousConfig validates ANONYMOUS_LINKS, ANONYMOUS_MAX_EXP_MINUTES and ANONYMOUS_LINKS_PER_DAY
+func checkAnonymousConfig() error {
+	switch value := os.Getenv("ANONYMOUS_LINKS"); value {
+	case "", AnonymousAllowed, AnonymousLimited, AnonymousBlocked:
+	default:
+		return fmt.Errorf("invalid ANONYMOUS_LINKS %q, expected %s, %s or %s", value, AnonymousAllowed, AnonymousLimited, AnonymousBlocked)
+	}
+	if value := os.Getenv("ANONYMOUS_MAX_EXP_MINUTES"); value != "" {
+		if minutes, err := strconv.Atoi(value); err != nil || minutes <= 0 {
+			return fmt.Errorf("invalid ANONYMOUS_MAX_EXP_MINUTES %q", value)
+		}
+	}
+	if value := os.Getenv("ANONYMOUS_LINKS_PER_DAY"); value != "" {
+		if quota, err := strconv.Atoi(value); err != nil || quota < 0 {
+			return fmt.Errorf("invalid ANONYMOUS_LINKS_PER_DAY %q", value)
+		}
+	}
+	return nil
+}
+
+// AnonymousLinks returns the policy on anonymous links, one of the Anonymous* values
+func AnonymousLinks() string {
+	return anonymousLinks
+}
+
+// Anonymous reports whether the creator is nobody: neither a user, an api key nor the ADMIN_TOKEN
+func (c Creator) Anonymous() bool {
+	return c.UserId == nil && c.ApiKeyId == nil && !c.Admin
+}
+
+// checkAnonymous fails with ErrAnonymousBlocked for anonymous creators when ANONYMOUS_LINKS blocks them, and with
+// ErrDailyQuotaExceeded when the count more links don't fit in ANONYMOUS_LINKS_PER_DAY under the limited policy
+func (s *Service) checkAnonymous(creator Creator, count int) error {
+	if !creator.Anonymous() {
+		return nil
+	}
+
+	switch {
+	case anonymousLinks == AnonymousBlocked:
+		return ErrAnonymousBlocked
+	case anonymousLinks != AnonymousLimited || anonymousLinksPerDay == 0:
+		return nil
+	}
+
+	created, err := s.db.CountAnonymousLinks(time.Now().UTC().Truncate(24 * time.Hour))
+	if err != nil {
+		return fmt.Errorf("anonymous links: %w", err)
+	}
+	if created+count > anonymousLinksPerDay {
+		return ErrDailyQuotaExceeded
+	}
+	return nil
+}
+
+// limitAnonymous returns the expiry to store for a link of creator: under the limited policy anonymous links expire
+// after ANONYMOUS_MAX_EXP_MINUTES at most, those asked to never expire or to expire later included
+func limitAnonymous(expTimeMinutes int, creator Creator) int {
+	if anonymousLinks != AnonymousLimited || !creator.Anonymous() {
+		return expTimeMinutes
+	}
+	if expTimeMinutes == 0 || expTimeMinutes > anonymousMaxExpMinutes {
+		return anonymousMaxExpMinutes
+	}
+	return expTimeMinutes
+}
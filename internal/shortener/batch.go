@@ -0,0 +1,135 @@
+package shortener
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/metrics"
+)
+
+// Link is one of the links stored by ShortenBatch
+type Link struct {
+	Link           string
+	ExpTimeMinutes int
+	Options        Options
+}
+
+// ShortenBatch validates and stores many links of a creator at once, e.g. for an import. Links are checked like
+// Shorten does but saved together, in a round trip per attempt at drawing codes. It returns the link created for
+// each of them, or why it wasn't. Links past the quotas of the creator fail with the quota error, the ones before
+// them are created. The error is for failures of the whole batch, such as a creator outside of its organization
+func (s *Service) ShortenBatch(links []Link, creator Creator) ([]*database.ShortUrlModel, []error, error) {
+	entities := make([]*database.ShortUrlModel, len(links))
+	errs := make([]error, len(links))
+
+	if err := s.checkAnonymous(creator, len(links)); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkVerified(creator); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.checkMember(creator); err != nil {
+		return nil, nil, err
+	}
+
+	aliasErr := error(nil)
+	for _, link := range links {
+		if link.Options.Code != "" {
+			aliasErr = s.checkAlias(creator)
+			break
+		}
+	}
+
+	allowed, exceeded := -1, error(nil)
+	if creator.UserId != nil || creator.ApiKeyId != nil {
+		usage, err := s.Usage(creator, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		allowed, exceeded = usage.Allowance()
+	}
+
+	pending := map[int]*database.ShortUrlModel{}
+	for i, link := range links {
+		shortUrl, err := prepare(link.Link, link.ExpTimeMinutes, creator, link.Options)
+		if err == nil && link.Options.Code != "" {
+			err = aliasErr
+		}
+		if err == nil && allowed >= 0 && len(pending) >= allowed {
+			err = exceeded
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		pending[i] = shortUrl
+	}
+
+	created := 0
+	for attempt := 0; len(pending) > 0 && attempt < maxCodeAttempts; attempt++ {
+		order := make([]int, 0, len(pending))
+		for i := range pending {
+			order = append(order, i)
+		}
+		sort.Ints(order)
+
+		batch := make([]*database.ShortUrlModel, 0, len(order))
+		for _, i := range order {
+			batch = append(batch, pending[i])
+		}
+
+		inserted, err := s.db.SaveShortUrls(batch)
+		if err != nil {
+			for _, i := range order {
+				errs[i] = fmt.Errorf("shorten: %w", err)
+			}
+			pending = nil
+			break
+		}
+
+		byCode := make(map[string]*database.ShortUrlModel, len(inserted))
+		for _, entity := range inserted {
+			byCode[entity.ShortCode] = entity
+		}
+
+		// A code is inserted once, for the first link that has it
+		for _, i := range order {
+			if entity, ok := byCode[pending[i].ShortCode]; ok {
+				entities[i] = entity
+				s.rememberCode(entity.ShortCode)
+				delete(byCode, entity.ShortCode)
+				delete(pending, i)
+				created++
+				continue
+			}
+
+			// Chosen codes are taken for good, generated ones are drawn again
+			if links[i].Options.Code != "" {
+				errs[i] = fmt.Errorf("shorten: %w", database.ErrDuplicateCode)
+				delete(pending, i)
+				continue
+			}
+			pending[i].ShortCode = links[i].Options.generateCode()
+		}
+	}
+
+	for i := range pending {
+		errs[i] = fmt.Errorf("shorten: %w", database.ErrDuplicateCode)
+	}
+
+	metrics.Count("links.created", int64(created), "source:batch")
+
+	// The links exist by now, failing to count them only skews the usage report
+	if creator.ApiKeyId != nil && created > 0 {
+		if _, err := s.db.AddApiKeyUsage(*creator.ApiKeyId, 0, created); err != nil {
+			log.Printf("[shortener:ShortenBatch] Could not count {%d} links in the usage of api key {%d}: %v", created, *creator.ApiKeyId, err)
+		}
+	}
+
+	return entities, errs, nil
+}
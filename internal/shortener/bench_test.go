@@ -0,0 +1,120 @@
+package shortener
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/mocks"
+)
+
+// benchStore answers the calls of the redirect path from memory without recording them, so the benchmarks
+// measure the shortener rather than the mock
+type benchStore struct {
+	*mocks.Service
+	links map[string]*database.ShortUrlModel
+}
+
+func newBenchStore(count int) *benchStore {
+	store := &benchStore{Service: &mocks.Service{}, links: map[string]*database.ShortUrlModel{}}
+	for i := range count {
+		code := fmt.Sprintf("code%04d", i)
+		store.links[code] = &database.ShortUrlModel{Id: i + 1, ShortCode: code, Link: "https://example.com/" + code, ExpTimeMinutes: 60, CreatedAt: time.Now()}
+	}
+	store.ListShortCodesFunc = func(since time.Time) ([]string, error) {
+		codes := make([]string, 0, len(store.links))
+		for code := range store.links {
+			codes = append(codes, code)
+		}
+		return codes, nil
+	}
+	return store
+}
+
+func (b *benchStore) GetShortUrl(shortCode string) (*database.ShortUrlModel, error) {
+	if entity, ok := b.links[shortCode]; ok {
+		return entity, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+func (b *benchStore) RunInTransaction(fn func(database.Service) error) error {
+	return fn(b)
+}
+
+func (b *benchStore) UpdateTimesClicked(shortCode string) error {
+	return nil
+}
+
+func (b *benchStore) RecordClick(click *database.ClickEventModel) error {
+	return nil
+}
+
+func BenchmarkResolve(b *testing.B) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	store := newBenchStore(1000)
+	codes := make([]string, 0, len(store.links))
+	for code := range store.links {
+		codes = append(codes, code)
+	}
+
+	b.Run("existing code", func(b *testing.B) {
+		s := New(store, cache.NewLRU[*database.ShortUrlModel](1000, time.Minute))
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				if _, err := s.Resolve(codes[i%len(codes)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("unknown code filtered", func(b *testing.B) {
+		s := New(store, nil)
+		if _, err := s.rebuildCodes(); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				s.Resolve("zzzzzzzz")
+			}
+		})
+	})
+
+	b.Run("unknown code cached", func(b *testing.B) {
+		s := New(store, nil)
+		if s.missing == nil {
+			b.Skip("NOT_FOUND_CACHE_TTL is 0")
+		}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				s.Resolve("zzzzzzzz")
+			}
+		})
+	})
+}
+
+func BenchmarkRecordVisit(b *testing.B) {
+	store := newBenchStore(1)
+	s := New(store, nil)
+	entity := store.links["code0000"]
+	visit := Visit{Ip: "203.0.113.7", UserAgent: "Mozilla/5.0 (X11; Linux x86_64)", Referrer: "https://news.example.com/"}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := s.RecordVisit(entity, visit); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
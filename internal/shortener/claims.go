@@ -0,0 +1,50 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+var ErrClaimAnonymous = errors.New("links are claimed into an account, sign in first")
+
+// IssueClaim returns the token claiming a link created anonymously, handed to its creator once. Only its hash is
+// stored, so a lost token can't be recovered
+func (s *Service) IssueClaim(entity *database.ShortUrlModel) (string, error) {
+	token, err := auth.NewState()
+	if err != nil {
+		return "", fmt.Errorf("issue claim of %s: %w", entity.ShortCode, err)
+	}
+
+	if err := s.db.SaveLinkClaim(&database.LinkClaimModel{ShortUrlId: entity.Id, TokenHash: auth.HashToken(token)}); err != nil {
+		return "", fmt.Errorf("issue claim of %s: %w", entity.ShortCode, err)
+	}
+
+	return token, nil
+}
+
+// Claim makes the user of creator the owner of the anonymous link of a claim token, as if they created it: the link
+// counts against their quotas from then on. A token works once, unknown and used ones fail with
+// database.ErrNotFound
+func (s *Service) Claim(token string, creator Creator) (*database.ShortUrlModel, error) {
+	if creator.UserId == nil {
+		return nil, ErrClaimAnonymous
+	}
+
+	if err := checkVerified(creator); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkQuota(creator); err != nil {
+		return nil, err
+	}
+
+	entity, err := s.db.ClaimLink(auth.HashToken(token), *creator.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("claim: %w", err)
+	}
+
+	return entity, nil
+}
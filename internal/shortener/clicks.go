@@ -0,0 +1,204 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/metrics"
+)
+
+const (
+	defaultClickFlushInterval = 500 * time.Millisecond
+	defaultClickFlushSize     = 1000
+
+	// Policies for the clicks made while the buffer is full
+	overflowDrop  = "drop"
+	overflowWrite = "write"
+)
+
+var (
+	// Click events held in memory until they are stored together, 0 to store every click as it is made
+	clickBufferSize = intFromEnv("CLICK_BUFFER_SIZE", 0)
+
+	// The buffer is stored this often, or as soon as clickFlushSize events wait
+	clickFlushInterval = durationFromEnv("CLICK_FLUSH_INTERVAL", defaultClickFlushInterval)
+	clickFlushSize     = intFromEnv("CLICK_FLUSH_SIZE", defaultClickFlushSize)
+
+	// What becomes of the clicks made while the buffer is full: dropped, or stored as they are made
+	clickOverflow = os.Getenv("CLICK_BUFFER_OVERFLOW")
+)
+
+// Every buffer created, for DrainClicks
+var (
+	buffersMu sync.Mutex
+	buffers   []*clickBuffer
+)
+
+// intFromEnv falls back on invalid values, Preflight refuses to boot with them
+func intFromEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// durationFromEnv falls back on invalid values, Preflight refuses to boot with them
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// checkClickConfig validates the CLICK_* settings of the buffer
+func checkClickConfig() error {
+	for _, name := range []string{"CLICK_BUFFER_SIZE", "CLICK_FLUSH_SIZE"} {
+		if value := os.Getenv(name); value != "" {
+			if size, err := strconv.Atoi(value); err != nil || size < 0 || (name == "CLICK_FLUSH_SIZE" && size == 0) {
+				return fmt.Errorf("invalid %s %q", name, value)
+			}
+		}
+	}
+	if value := os.Getenv("CLICK_FLUSH_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid CLICK_FLUSH_INTERVAL %q", value)
+		}
+	}
+	switch clickOverflow {
+	case "", overflowDrop, overflowWrite:
+	default:
+		return fmt.Errorf("invalid CLICK_BUFFER_OVERFLOW %q, expected drop or write", clickOverflow)
+	}
+	return nil
+}
+
+// clickBuffer holds click events in memory so they are stored many at once with COPY, rather than with a
+// transaction per redirect
+type clickBuffer struct {
+	db        Store
+	size      int
+	flushSize int
+
+	mu     sync.Mutex
+	events []*database.ClickEventModel
+
+	// Signalled once flushSize events wait
+	ready chan struct{}
+
+	// One flush at a time, so the events put back by a failed flush stay ahead of the newer ones
+	flushMu sync.Mutex
+}
+
+func newClickBuffer(db Store, size int, flushSize int) *clickBuffer {
+	return &clickBuffer{db: db, size: size, flushSize: min(flushSize, size), ready: make(chan struct{}, 1)}
+}
+
+// add holds click until the next flush. It reports false when the buffer is full
+func (b *clickBuffer) add(click *database.ClickEventModel) bool {
+	b.mu.Lock()
+	if len(b.events) >= b.size {
+		b.mu.Unlock()
+		return false
+	}
+	b.events = append(b.events, click)
+	full := len(b.events) >= b.flushSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.ready <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// flush stores the events held. When that fails they are put back for the next flush, as far as there is room
+func (b *clickBuffer) flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	events := b.events
+	b.events = nil
+	b.mu.Unlock()
+
+	metrics.Gauge("clicks.buffered", float64(len(events)))
+	if len(events) == 0 {
+		return nil
+	}
+
+	started := time.Now()
+	stored, err := b.db.RecordClicks(events)
+	metrics.Timing("clicks.flush", time.Since(started))
+
+	if err != nil {
+		metrics.Count("clicks.flush_failures", 1)
+
+		b.mu.Lock()
+		kept := min(b.size-len(b.events), len(events))
+		b.events = append(events[:kept:kept], b.events...)
+		b.mu.Unlock()
+
+		if dropped := len(events) - kept; dropped > 0 {
+			metrics.Count("clicks.dropped", int64(dropped), "reason:flush_failed")
+		}
+		log.Printf("[shortener:flush] Could not store {%d} clicks, {%d} kept for the next flush: %v", len(events), kept, err)
+		return err
+	}
+
+	metrics.Count("clicks.flushed", stored)
+	return nil
+}
+
+// FlushClicks stores the clicks held in memory every CLICK_FLUSH_INTERVAL, or as soon as CLICK_FLUSH_SIZE wait.
+// It never returns and does nothing unless CLICK_BUFFER_SIZE is set, see DrainClicks for the shutdown
+func (s *Service) FlushClicks() {
+	if s.clicks == nil {
+		return
+	}
+
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.clicks.ready:
+		}
+		s.clicks.flush()
+	}
+}
+
+// DrainClicks stores the clicks still held in memory by every Service, before the process exits. Redirects must
+// have stopped by then, e.g. once the http server is shut down. It gives up when ctx is done
+func DrainClicks(ctx context.Context) error {
+	buffersMu.Lock()
+	drained := append([]*clickBuffer(nil), buffers...)
+	buffersMu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var errs []error
+		for _, buffer := range drained {
+			errs = append(errs, buffer.flush())
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
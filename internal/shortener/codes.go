@@ -0,0 +1,172 @@
+package shortener
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"url-shortner/internal/bloom"
+)
+
+// Whether Resolve answers for unknown codes without asking the database, see WatchCodes
+var codeFilter = os.Getenv("SHORT_CODE_FILTER") == "true"
+
+// How long a code that wasn't found is answered for without asking the database again, 0 to always ask
+var notFoundTTL = notFoundTTLFromEnv()
+
+// Codes remembered as not found at once, the least recently asked for are forgotten first
+const notFoundCacheSize = 10000
+
+const defaultNotFoundTTL = 10 * time.Second
+
+// notFoundTTLFromEnv falls back to the default on invalid values, Preflight refuses to boot with them
+func notFoundTTLFromEnv() time.Duration {
+	value, ok := os.LookupEnv("NOT_FOUND_CACHE_TTL")
+	if !ok {
+		return defaultNotFoundTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return defaultNotFoundTTL
+	}
+	return ttl
+}
+
+const (
+	// Codes created by other replicas and processes are picked up this often
+	codeFilterRefresh = 10 * time.Second
+
+	// The filter is built again from scratch this often, dropping deleted codes and growing with the table
+	codeFilterRebuild = time.Hour
+
+	// A refresh looks this far back, covering the lag of the replica and links committed long after they were
+	// inserted, e.g. by an import
+	codeFilterOverlap = 5 * time.Minute
+
+	codeFilterFalsePositives = 0.01
+
+	// Room left for the codes created until the next rebuild
+	codeFilterHeadroom = 10000
+)
+
+// WatchCodes keeps a Bloom filter of the existing short codes, so that Resolve turns down the codes that were
+// never created, e.g. those of scanners, without a database round trip. It never returns and does nothing unless
+// SHORT_CODE_FILTER is set. Codes are filtered once the first build is done. Codes created by other replicas are
+// added as their changes are notified, see Forget, and the filter is refreshed at once when notifications were missed
+func (s *Service) WatchCodes() {
+	if !codeFilter {
+		return
+	}
+
+	for {
+		since, err := s.rebuildCodes()
+		if err != nil {
+			log.Printf("[shortener:WatchCodes] Could not build the short code filter: %v", err)
+			time.Sleep(codeFilterRefresh)
+			continue
+		}
+
+		for rebuilt := time.Now(); time.Since(rebuilt) < codeFilterRebuild; {
+			select {
+			case <-time.After(codeFilterRefresh):
+			case <-s.codesMissed:
+				// Codes created elsewhere may have gone unnoticed, see ForgetAll
+			}
+
+			refreshed := time.Now().Add(-codeFilterOverlap)
+			if err := s.refreshCodes(since); err != nil {
+				log.Printf("[shortener:WatchCodes] Could not refresh the short code filter: %v", err)
+				continue
+			}
+			since = refreshed
+		}
+	}
+}
+
+// rebuildCodes builds the filter from every code. It returns from when the next refresh must look for new codes
+func (s *Service) rebuildCodes() (time.Time, error) {
+	since := time.Now().Add(-codeFilterOverlap)
+
+	// Codes created by this process while the list is read go to both filters
+	s.codesMu.Lock()
+	s.codesPending = []string{}
+	s.codesMu.Unlock()
+
+	codes, err := s.db.ListShortCodes(time.Time{})
+	if err != nil {
+		s.codesMu.Lock()
+		s.codesPending = nil
+		s.codesMu.Unlock()
+		return time.Time{}, err
+	}
+
+	filter := bloom.New(2*len(codes)+codeFilterHeadroom, codeFilterFalsePositives)
+	for _, code := range codes {
+		filter.Add(code)
+	}
+
+	s.codesMu.Lock()
+	for _, code := range s.codesPending {
+		filter.Add(code)
+	}
+	s.codesPending = nil
+	s.codes.Store(filter)
+	s.codesMu.Unlock()
+
+	log.Printf("[shortener:rebuildCodes] Built the short code filter with {%d} codes", len(codes))
+	return since, nil
+}
+
+func (s *Service) refreshCodes(since time.Time) error {
+	codes, err := s.db.ListShortCodes(since)
+	if err != nil {
+		return err
+	}
+
+	filter := s.codes.Load()
+	for _, code := range codes {
+		filter.Add(code)
+	}
+	return nil
+}
+
+// catchUpCodes has WatchCodes refresh the filter without waiting for the next refresh, unless one is already due
+func (s *Service) catchUpCodes() {
+	select {
+	case s.codesMissed <- struct{}{}:
+	default:
+	}
+}
+
+// rememberCode adds the code of a link just created to the filter, and forgets it was missing
+func (s *Service) rememberCode(code string) {
+	if s.missing != nil {
+		// In this order, so a miss cached meanwhile is dropped by either side, see rememberMissing
+		s.changed.Set(code, true)
+		s.missing.Delete(code)
+	}
+
+	s.codesMu.Lock()
+	defer s.codesMu.Unlock()
+
+	if filter := s.codes.Load(); filter != nil {
+		filter.Add(code)
+	}
+	if s.codesPending != nil {
+		s.codesPending = append(s.codesPending, code)
+	}
+}
+
+// rememberMissing caches that code wasn't found, unless it changed lately
+func (s *Service) rememberMissing(code string) {
+	s.missing.Set(code, true)
+	if _, ok := s.changed.Get(code); ok {
+		s.missing.Delete(code)
+	}
+}
+
+// unknownCode reports whether code was certainly never created
+func (s *Service) unknownCode(code string) bool {
+	filter := s.codes.Load()
+	return filter != nil && !filter.MayContain(code)
+}
@@ -0,0 +1,65 @@
+package shortener
+
+import (
+	"fmt"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/database"
+)
+
+// IssueEditToken returns the token letting the anonymous creator of a link change or delete it, handed to them once.
+// Only its hash is stored, so a lost token can't be recovered
+func (s *Service) IssueEditToken(entity *database.ShortUrlModel) (string, error) {
+	token, err := auth.NewState()
+	if err != nil {
+		return "", fmt.Errorf("issue edit token of %s: %w", entity.ShortCode, err)
+	}
+
+	if err := s.db.SaveLinkEditToken(&database.LinkEditTokenModel{ShortUrlId: entity.Id, TokenHash: auth.HashToken(token)}); err != nil {
+		return "", fmt.Errorf("issue edit token of %s: %w", entity.ShortCode, err)
+	}
+
+	return token, nil
+}
+
+// Editable returns the link of shortCode when token is its edit token. Unknown links, other tokens and links claimed
+// into an account since fail alike with database.ErrNotFound
+func (s *Service) Editable(shortCode string, token string) (*database.ShortUrlModel, error) {
+	if token == "" {
+		return nil, fmt.Errorf("editable %s: %w", shortCode, database.ErrNotFound)
+	}
+
+	entity, err := s.db.GetShortUrlByEditToken(shortCode, auth.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("editable %s: %w", shortCode, err)
+	}
+	return entity, nil
+}
+
+// Edit points an anonymous link to another destination and changes its expiry, with the rules of its creation: the
+// expiry still counts from then and is capped under the limited ANONYMOUS_LINKS policy. A disabled link, e.g. under
+// review, fails with database.ErrDisabled. With a review the edited link is disabled and queued at once
+func (s *Service) Edit(entity *database.ShortUrlModel, link string, expTimeMinutes int, review *database.ReviewModel) (*database.ShortUrlModel, error) {
+	if entity.DisabledAt != nil {
+		return nil, database.ErrDisabled
+	}
+
+	if err := Validate(link, expTimeMinutes); err != nil {
+		return nil, err
+	}
+
+	link, err := NormalizeLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if len(link) > maxLinkLength {
+		return nil, ErrLinkTooLong
+	}
+
+	edited, err := s.db.EditShortUrl(entity.ShortCode, link, limitAnonymous(expTimeMinutes, Creator{}), review)
+	if err != nil {
+		return nil, fmt.Errorf("edit: %w", err)
+	}
+
+	return edited, nil
+}
@@ -0,0 +1,254 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"url-shortner/internal/database"
+	"url-shortner/internal/notify"
+	"url-shortner/internal/plans"
+)
+
+// Instance wide quotas on link creation, zero meaning no limit. They apply on top of the limits of the plans.
+// Daily quotas reset at midnight UTC
+var (
+	linksPerDay       = quotaFromEnv("QUOTA_LINKS_PER_DAY")
+	maxActiveLinks    = quotaFromEnv("QUOTA_ACTIVE_LINKS")
+	apiKeyLinksPerDay = quotaFromEnv("QUOTA_API_KEY_LINKS_PER_DAY")
+)
+
+// Percentage of a quota past which the creator is reported as nearing it, see notifyNearing
+var quotaWarningPercent = warningPercentFromEnv()
+
+const defaultQuotaWarningPercent = 90
+
+var (
+	ErrDailyQuotaExceeded  = errors.New("daily link quota exceeded")
+	ErrActiveQuotaExceeded = errors.New("active link quota exceeded")
+)
+
+func quotaFromEnv(name string) int {
+	quota, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || quota < 0 {
+		return 0
+	}
+	return quota
+}
+
+// warningPercentFromEnv reads QUOTA_WARNING_PERCENT, falling back to the default on invalid values, Preflight
+// refuses to boot with them
+func warningPercentFromEnv() int {
+	percent, err := strconv.Atoi(os.Getenv("QUOTA_WARNING_PERCENT"))
+	if err != nil || percent < 1 || percent > 100 {
+		return defaultQuotaWarningPercent
+	}
+	return percent
+}
+
+// Creator identifies who shortens a link. Anonymous links have neither a user nor an api key and
+// aren't subject to quotas, but to ANONYMOUS_LINKS
+type Creator struct {
+	UserId   *int
+	ApiKeyId *int
+
+	// Whether the request carries the ADMIN_TOKEN, which has neither a user nor an api key but isn't anonymous
+	Admin bool
+
+	// Whether the user verified its email, see EMAIL_VERIFICATION
+	EmailVerified bool
+
+	// Plan of the user, personal links count against its limits
+	Plan string
+
+	// Organization the link is shared with, nil for personal links
+	OrganizationId *int
+}
+
+// Quota is the consumption of a single quota. A zero Limit means unlimited
+type Quota struct {
+	Used  int
+	Limit int
+}
+
+// Exceeded reports whether no more links fit in the quota.
+func (q Quota) Exceeded() bool {
+	return q.Limit > 0 && q.Used >= q.Limit
+}
+
+// Nearing reports whether one more link takes the quota past percent of its limit.
+func (q Quota) Nearing(percent int) bool {
+	if q.Limit == 0 {
+		return false
+	}
+	threshold := (q.Limit*percent + 99) / 100
+	return q.Used < threshold && q.Used+1 >= threshold
+}
+
+// Remaining returns how many links still fit in the quota, or -1 when it is unlimited.
+func (q Quota) Remaining() int {
+	if q.Limit == 0 {
+		return -1
+	}
+	return max(q.Limit-q.Used, 0)
+}
+
+// Usage is where a creator stands against its quotas
+type Usage struct {
+	// Plan the links count against, the one of the organization for links shared with one
+	Plan string
+
+	LinksToday  Quota
+	ActiveLinks Quota
+
+	// Links created today with the api key of the request, nil without one
+	ApiKeyLinksToday *Quota
+
+	ResetsAt time.Time
+}
+
+// Allowance returns how many more links fit in every quota, or -1 when none limits them, along with the error
+// of the quota that runs out first
+func (u *Usage) Allowance() (int, error) {
+	quotas := []struct {
+		quota *Quota
+		err   error
+	}{
+		{&u.LinksToday, ErrDailyQuotaExceeded},
+		{u.ApiKeyLinksToday, ErrDailyQuotaExceeded},
+		{&u.ActiveLinks, ErrActiveQuotaExceeded},
+	}
+
+	allowed, exceeded := -1, error(nil)
+	for _, q := range quotas {
+		if q.quota == nil {
+			continue
+		}
+		if remaining := q.quota.Remaining(); remaining >= 0 && (allowed < 0 || remaining < allowed) {
+			allowed, exceeded = remaining, q.err
+		}
+	}
+
+	return allowed, exceeded
+}
+
+// Usage counts the links of the creator against its plan and the instance wide quotas. Links shared with an
+// organization are counted for the whole organization.
+func (s *Service) Usage(creator Creator, now time.Time) (*Usage, error) {
+	plan, err := s.planOf(creator)
+	if err != nil {
+		return nil, err
+	}
+	return s.usage(creator, plan, now)
+}
+
+// planOf returns the plan links of the creator count against
+func (s *Service) planOf(creator Creator) (*plans.Plan, error) {
+	if creator.OrganizationId == nil {
+		return plans.Get(creator.Plan), nil
+	}
+
+	organization, err := s.db.GetOrganization(*creator.OrganizationId)
+	if err != nil {
+		return nil, fmt.Errorf("plan of organization %d: %w", *creator.OrganizationId, err)
+	}
+	return plans.Get(organization.Plan), nil
+}
+
+func (s *Service) usage(creator Creator, plan *plans.Plan, now time.Time) (*Usage, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	usage := &Usage{
+		Plan:        plan.Name,
+		LinksToday:  Quota{Limit: plans.Limit(plan.LinksPerDay, linksPerDay)},
+		ActiveLinks: Quota{Limit: plans.Limit(plan.MaxActiveLinks, maxActiveLinks)},
+		ResetsAt:    today.Add(24 * time.Hour),
+	}
+
+	var count *database.LinkCountModel
+	var err error
+	switch {
+	case creator.OrganizationId != nil:
+		count, err = s.db.CountOrganizationLinks(*creator.OrganizationId, today)
+	case creator.UserId != nil:
+		count, err = s.db.CountUserLinks(*creator.UserId, today)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usage: %w", err)
+	}
+	if count != nil {
+		usage.LinksToday.Used = count.Created
+		usage.ActiveLinks.Used = count.Active
+	}
+
+	if creator.ApiKeyId != nil {
+		count, err := s.db.CountApiKeyLinks(*creator.ApiKeyId, today)
+		if err != nil {
+			return nil, fmt.Errorf("usage: %w", err)
+		}
+		usage.ApiKeyLinksToday = &Quota{Used: count.Created, Limit: apiKeyLinksPerDay}
+	}
+
+	return usage, nil
+}
+
+// checkQuota fails with ErrDailyQuotaExceeded or ErrActiveQuotaExceeded when the creator can't create another link.
+// Concurrent requests may each pass the check, so a quota can be overrun by the number of requests in flight
+func (s *Service) checkQuota(creator Creator) error {
+	if creator.UserId == nil && creator.ApiKeyId == nil {
+		return nil
+	}
+
+	plan, err := s.planOf(creator)
+	if err != nil {
+		return err
+	}
+	if plans.Limit(plan.LinksPerDay, linksPerDay) == 0 && plans.Limit(plan.MaxActiveLinks, maxActiveLinks) == 0 && apiKeyLinksPerDay == 0 {
+		return nil
+	}
+
+	usage, err := s.usage(creator, plan, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if usage.LinksToday.Exceeded() || (usage.ApiKeyLinksToday != nil && usage.ApiKeyLinksToday.Exceeded()) {
+		return ErrDailyQuotaExceeded
+	}
+	if usage.ActiveLinks.Exceeded() {
+		return ErrActiveQuotaExceeded
+	}
+
+	notifyNearing(creator, usage)
+	return nil
+}
+
+// notifyNearing notifies the quotas the link about to be created takes past QUOTA_WARNING_PERCENT, once per quota
+// and period since only the link crossing it does
+func notifyNearing(creator Creator, usage *Usage) {
+	quotas := map[string]*Quota{"links_today": &usage.LinksToday, "active_links": &usage.ActiveLinks, "api_key_links_today": usage.ApiKeyLinksToday}
+	for name, quota := range quotas {
+		if quota == nil || !quota.Nearing(quotaWarningPercent) {
+			continue
+		}
+
+		fields := map[string]string{
+			"quota": name,
+			"used":  strconv.Itoa(quota.Used + 1),
+			"limit": strconv.Itoa(quota.Limit),
+			"plan":  usage.Plan,
+		}
+		switch {
+		case creator.OrganizationId != nil:
+			fields["organization_id"] = strconv.Itoa(*creator.OrganizationId)
+		case creator.UserId != nil:
+			fields["user_id"] = strconv.Itoa(*creator.UserId)
+		}
+		if creator.ApiKeyId != nil {
+			fields["api_key_id"] = strconv.Itoa(*creator.ApiKeyId)
+		}
+
+		notify.Send(notify.Event{Type: notify.QuotaNearing, Title: "Links nearing their " + name + " quota", Fields: fields})
+	}
+}
@@ -0,0 +1,691 @@
+// Package shortener holds the rules of shortening and following links, independently of the transport serving them.
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/bloom"
+	"url-shortner/internal/breaker"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/idn"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/metrics"
+	"url-shortner/internal/normalize"
+	"url-shortner/internal/privacy"
+)
+
+const (
+	// Length of the generated short codes
+	CodeLength = 8
+
+	// How many short codes are drawn before giving up on a collision
+	maxCodeAttempts = 3
+
+	// Longest link accepted unless MAX_LINK_LENGTH says otherwise. Browsers and proxies commonly cut urls past it
+	defaultMaxLinkLength = 2048
+)
+
+// Longest link accepted, in bytes. The short_url.link column has no limit of its own
+var maxLinkLength = linkLengthFromEnv()
+
+var (
+	ErrInvalidLink   = errors.New("link must be an absolute http or https url")
+	ErrLinkTooLong   = errors.New("link is longer than the maximum length")
+	ErrInvalidExpiry = errors.New("expiration time can't be negative")
+	ErrNotMember     = errors.New("only members can create links for an organization")
+	ErrViewerRole    = errors.New("viewers can't create links for an organization")
+
+	ErrAliasNotAllowed  = errors.New("the plan doesn't include custom aliases")
+	ErrInvalidCode      = errors.New("code must be 1 to 64 letters, digits, dashes or underscores")
+	ErrInvalidNamespace = errors.New("namespace must be 1 to 32 lowercase letters, digits or dashes")
+
+	ErrInvalidVisibility = errors.New("visibility must be public, unlisted or private")
+	ErrPrivateAnonymous  = errors.New("private links need an owner, sign in or use an api key")
+
+	ErrEmailUnverified = errors.New("the email of the account must be verified to create links")
+)
+
+// Users must verify their email before creating links, unless EMAIL_VERIFICATION is off as on internal deployments
+var emailVerificationRequired = os.Getenv("EMAIL_VERIFICATION") != "off"
+
+// MaxLinkLength is the longest link accepted, in bytes
+func MaxLinkLength() int {
+	return maxLinkLength
+}
+
+// CheckConfig validates MAX_LINK_LENGTH, NOT_FOUND_CACHE_TTL, QUOTA_WARNING_PERCENT, ANONYMOUS_LINKS and the click
+// buffering.
+func CheckConfig() error {
+	if value := os.Getenv("MAX_LINK_LENGTH"); value != "" {
+		if length, err := strconv.Atoi(value); err != nil || length <= 0 {
+			return fmt.Errorf("invalid MAX_LINK_LENGTH %q", value)
+		}
+	}
+	if value := os.Getenv("NOT_FOUND_CACHE_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err != nil || ttl < 0 {
+			return fmt.Errorf("invalid NOT_FOUND_CACHE_TTL %q", value)
+		}
+	}
+	if value := os.Getenv("EMAIL_VERIFICATION"); value != "" && value != "required" && value != "off" {
+		return fmt.Errorf("invalid EMAIL_VERIFICATION %q, expected required or off", value)
+	}
+	if value := os.Getenv("QUOTA_WARNING_PERCENT"); value != "" {
+		if percent, err := strconv.Atoi(value); err != nil || percent < 1 || percent > 100 {
+			return fmt.Errorf("invalid QUOTA_WARNING_PERCENT %q", value)
+		}
+	}
+	if err := checkAnonymousConfig(); err != nil {
+		return err
+	}
+	return checkClickConfig()
+}
+
+// linkLengthFromEnv falls back to the default on invalid values, Preflight refuses to boot with them
+func linkLengthFromEnv() int {
+	length, err := strconv.Atoi(os.Getenv("MAX_LINK_LENGTH"))
+	if err != nil || length <= 0 {
+		return defaultMaxLinkLength
+	}
+	return length
+}
+
+var codeLetters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+var (
+	codePattern      = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,63}$`)
+	namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+	// Taken by the routes under /short/{short_code}
+	reservedCodes = map[string]bool{"summary": true, "suggest": true, "import": true, "info": true, "stats": true, "report": true}
+
+	// Taken by the routes at the root, namespaces are also served as /{namespace}/{code}, and by /short/import/...
+	reservedNamespaces = map[string]bool{"import": true, "short": true, "api": true, "auth": true, "health": true, "livez": true, "readyz": true}
+)
+
+// Store is the part of the database the shortener relies on.
+type Store interface {
+	database.LinkRepository
+
+	// Links shared with an organization can only be created by its members
+	GetMembership(organizationId int, userId int) (*database.MemberModel, error)
+
+	// Links shared with an organization count against its plan
+	GetOrganization(id int) (*database.OrganizationModel, error)
+
+	// Links created with an api key count in its daily usage
+	AddApiKeyUsage(apiKeyId int, requests int, linksCreated int) (*database.ApiKeyUsageModel, error)
+
+	// Visits are recorded in a transaction, see database.Service
+	RunInTransaction(fn func(database.Service) error) error
+
+	// Or many at once when they are buffered, see FlushClicks
+	RecordClicks(clicks []*database.ClickEventModel) (int64, error)
+
+	// Anonymous links can be claimed into an account later, see IssueClaim, and edited until then, see IssueEditToken
+	database.ClaimRepository
+	database.EditTokenRepository
+}
+
+// Service shortens links and resolves short codes on top of the database.
+type Service struct {
+	db Store
+
+	// Recently resolved links, used to keep redirecting while the database is unreachable
+	recent *cache.LRU[*database.ShortUrlModel]
+
+	// The existing short codes, nil until built, see WatchCodes. Codes created while it is rebuilt are kept in
+	// codesPending to be added to the new one
+	codes        atomic.Pointer[bloom.Filter]
+	codesMu      sync.Mutex
+	codesPending []string
+
+	// Signals WatchCodes to refresh the filter at once, see catchUpCodes
+	codesMissed chan struct{}
+
+	// Codes recently looked up and not found, nil when NOT_FOUND_CACHE_TTL is 0
+	missing *cache.LRU[bool]
+
+	// Codes created, changed or deleted lately, whose misses aren't cached: the lookup may have raced the change, or
+	// read a replica lagging behind it. nil along with missing
+	changed *cache.LRU[bool]
+
+	// Clicks waiting to be stored, nil when CLICK_BUFFER_SIZE is 0
+	clicks *clickBuffer
+}
+
+// New returns a Service storing links in db. recent may be nil to disable the fallback on recently resolved links.
+func New(db Store, recent *cache.LRU[*database.ShortUrlModel]) *Service {
+	s := &Service{db: db, recent: recent, codesMissed: make(chan struct{}, 1)}
+	if notFoundTTL > 0 {
+		s.missing = cache.NewLRU[bool](notFoundCacheSize, notFoundTTL)
+		s.changed = cache.NewLRU[bool](notFoundCacheSize, notFoundTTL)
+	}
+	if clickBufferSize > 0 {
+		s.clicks = newClickBuffer(db, clickBufferSize, clickFlushSize)
+
+		buffersMu.Lock()
+		buffers = append(buffers, s.clicks)
+		buffersMu.Unlock()
+	}
+	return s
+}
+
+// Forget drops what is cached about the link of shortCode, after it was created, changed or deleted
+func (s *Service) Forget(shortCode string) {
+	if s.recent != nil {
+		s.recent.Delete(shortCode)
+	}
+	s.rememberCode(shortCode)
+}
+
+// ForgetAll drops every cached link, for changes to links that can't be named one by one, and refreshes the filter
+// of codes since some may have been created unnoticed
+func (s *Service) ForgetAll() {
+	if s.recent != nil {
+		s.recent.Clear()
+	}
+	if s.missing != nil {
+		s.missing.Clear()
+	}
+	s.catchUpCodes()
+}
+
+// Visit describes who followed a short link.
+type Visit struct {
+	Ip        string
+	UserAgent string
+	Referrer  string
+}
+
+// GenerateCode returns a random short code of CodeLength letters.
+func GenerateCode() string {
+	code := make([]rune, CodeLength)
+	for i := range code {
+		code[i] = codeLetters[rand.Intn(len(codeLetters))]
+	}
+	return string(code)
+}
+
+// ValidateCode checks a short code chosen instead of a generated one. Unicode codes are only accepted when
+// UNICODE_CODES is set, and must be normalized first, see NormalizeCode.
+func ValidateCode(code string) error {
+	if reservedCodes[code] {
+		return ErrInvalidCode
+	}
+	if codePattern.MatchString(code) {
+		return nil
+	}
+	if unicodeCodes && validUnicodeCode(code) && code == NormalizeCode(code) {
+		return nil
+	}
+	return ErrInvalidCode
+}
+
+// ValidateNamespace checks the name of a new namespace.
+func ValidateNamespace(name string) error {
+	if !namespacePattern.MatchString(name) || reservedNamespaces[name] {
+		return ErrInvalidNamespace
+	}
+	return nil
+}
+
+// Options are the choices made when shortening a link. The zero value generates a code served on every domain.
+type Options struct {
+	// Domain the link is pinned to, nil to serve it on every domain
+	DomainId *int
+
+	// Namespace scoping the code, nil for the global namespace
+	Namespace *database.NamespaceModel
+
+	// Code chosen for the link, empty to generate one
+	Code string
+
+	// Generate a code of emojis rather than letters, it requires UNICODE_CODES
+	Emoji bool
+
+	// One of the database.Visibility* values, empty for public. Private links need a user to own them
+	Visibility string
+
+	// Review of the destination, the link is then saved disabled and queued for review at once
+	Review *database.ReviewModel
+}
+
+// generateCode draws a code of the kind asked for
+func (o Options) generateCode() string {
+	if o.Emoji {
+		return o.shortCode(GenerateEmojiCode())
+	}
+	return o.shortCode(GenerateCode())
+}
+
+// shortCode returns the code stored for the link: namespaced links are stored as "<namespace>/<code>",
+// so codes are unique within each namespace.
+func (o Options) shortCode(code string) string {
+	if o.Namespace == nil {
+		return code
+	}
+	return o.Namespace.Name + "/" + code
+}
+
+// Validate checks a link and its expiration time before anything is stored. The host must be an ip or a valid,
+// possibly internationalized, domain name.
+func Validate(link string, expTimeMinutes int) error {
+	if len(link) > maxLinkLength {
+		return ErrLinkTooLong
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidLink
+	}
+
+	if host := parsed.Hostname(); net.ParseIP(host) == nil {
+		if _, err := idn.ToASCII(host); err != nil {
+			return ErrInvalidLink
+		}
+	}
+
+	if expTimeMinutes < 0 {
+		return ErrInvalidExpiry
+	}
+
+	return nil
+}
+
+// NormalizeLink returns the link in the form it is stored and redirected to: with an internationalized host in
+// punycode, e.g. "https://xn--mnchen-3ya.de/" for "https://münchen.de/", and without the tracking parameters of
+// STRIP_TRACKING_PARAMS. Anything else is returned as it is.
+func NormalizeLink(link string) (string, error) {
+	link = normalize.StripParams(link, trackingParams)
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Hostname() == "" || isASCII(parsed.Hostname()) {
+		return link, nil
+	}
+
+	host := parsed.Hostname()
+	ascii, err := idn.ToASCII(host)
+	if err != nil {
+		return "", ErrInvalidLink
+	}
+
+	// The host follows the scheme and the user info, if any
+	authorityStart := strings.Index(link, "://") + len("://")
+	authorityEnd := len(link)
+	if i := strings.IndexAny(link[authorityStart:], "/?#"); i >= 0 {
+		authorityEnd = authorityStart + i
+	}
+	hostStart := authorityStart + strings.LastIndex(link[authorityStart:authorityEnd], "@") + 1
+
+	i := strings.Index(link[hostStart:authorityEnd], host)
+	if i < 0 {
+		return "", ErrInvalidLink
+	}
+	return link[:hostStart+i] + ascii + link[hostStart+i+len(host):], nil
+}
+
+// DisplayLink returns the link with a punycode host in its Unicode form, the way people read it.
+func DisplayLink(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || !strings.Contains(parsed.Hostname(), "xn--") {
+		return link
+	}
+
+	host := parsed.Hostname()
+	return strings.Replace(link, host, idn.ToUnicode(host), 1)
+}
+
+// Shorten validates and stores a link under a fresh or chosen short code, within the quotas of its creator.
+// A chosen code that is already taken fails with database.ErrDuplicateCode.
+func (s *Service) Shorten(link string, expTimeMinutes int, creator Creator, options Options) (*database.ShortUrlModel, error) {
+	shortUrl, err := prepare(link, expTimeMinutes, creator, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkAnonymous(creator, 1); err != nil {
+		return nil, err
+	}
+
+	if err := checkVerified(creator); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkMember(creator); err != nil {
+		return nil, err
+	}
+
+	if options.Code != "" {
+		if err := s.checkAlias(creator); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkQuota(creator); err != nil {
+		return nil, err
+	}
+
+	entity, err := s.db.SaveShortUrl(shortUrl)
+
+	// Generated short codes are random, on the rare collision draw another one
+	for attempt := 1; options.Code == "" && errors.Is(err, database.ErrDuplicateCode) && attempt < maxCodeAttempts; attempt++ {
+		shortUrl.ShortCode = options.generateCode()
+		entity, err = s.db.SaveShortUrl(shortUrl)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("shorten: %w", err)
+	}
+	s.rememberCode(entity.ShortCode)
+
+	// The link exists by now, failing to count it only skews the usage report
+	if creator.ApiKeyId != nil {
+		if _, err := s.db.AddApiKeyUsage(*creator.ApiKeyId, 0, 1); err != nil {
+			log.Printf("[shortener:Shorten] Could not count the link in the usage of api key {%d}: %v", *creator.ApiKeyId, err)
+		}
+	}
+
+	metrics.Count("links.created", 1, "source:single")
+
+	return entity, nil
+}
+
+// Upsert stores a link under its chosen code, or points the existing link with that code to it when it belongs to
+// the creator, or to its organization. Deploy scripts call it to make sure a link exists, whether or not it did.
+// It reports whether the link was created. A code of someone else fails with database.ErrDuplicateCode
+func (s *Service) Upsert(link string, expTimeMinutes int, creator Creator, options Options) (*database.ShortUrlModel, bool, error) {
+	if options.Code == "" {
+		return nil, false, ErrInvalidCode
+	}
+
+	shortUrl, err := prepare(link, expTimeMinutes, creator, options)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.checkAnonymous(creator, 1); err != nil {
+		return nil, false, err
+	}
+
+	if err := checkVerified(creator); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.checkMember(creator); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.checkAlias(creator); err != nil {
+		return nil, false, err
+	}
+
+	// Only a new link counts against the quotas
+	_, err = s.db.GetShortUrl(shortUrl.ShortCode)
+	if errors.Is(err, database.ErrNotFound) {
+		err = s.checkQuota(creator)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	entity, created, err := s.db.UpsertShortUrl(shortUrl)
+	if err != nil {
+		return nil, false, fmt.Errorf("upsert: %w", err)
+	}
+
+	if s.recent != nil {
+		s.recent.Delete(entity.ShortCode)
+	}
+
+	if created {
+		s.rememberCode(entity.ShortCode)
+		metrics.Count("links.created", 1, "source:upsert")
+	} else {
+		metrics.Count("links.updated", 1)
+	}
+
+	if created && creator.ApiKeyId != nil {
+		if _, err := s.db.AddApiKeyUsage(*creator.ApiKeyId, 0, 1); err != nil {
+			log.Printf("[shortener:Upsert] Could not count the link in the usage of api key {%d}: %v", *creator.ApiKeyId, err)
+		}
+	}
+
+	return entity, created, nil
+}
+
+// prepare validates a link and returns the short url to store for it, with its code drawn or chosen. It checks
+// everything that doesn't need the database
+func prepare(link string, expTimeMinutes int, creator Creator, options Options) (*database.ShortUrlModel, error) {
+	if err := Validate(link, expTimeMinutes); err != nil {
+		return nil, err
+	}
+
+	expTimeMinutes = limitAnonymous(expTimeMinutes, creator)
+
+	link, err := NormalizeLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if len(link) > maxLinkLength {
+		return nil, ErrLinkTooLong
+	}
+
+	if options.Emoji && !unicodeCodes {
+		return nil, ErrUnicodeDisabled
+	}
+
+	visibility, err := checkVisibility(options.Visibility, creator)
+	if err != nil {
+		return nil, err
+	}
+
+	shortUrl := &database.ShortUrlModel{
+		Link:           link,
+		ExpTimeMinutes: expTimeMinutes,
+		ShortCode:      options.generateCode(),
+		OwnerId:        creator.UserId,
+		OrganizationId: creator.OrganizationId,
+		ApiKeyId:       creator.ApiKeyId,
+		DomainId:       options.DomainId,
+		Visibility:     visibility,
+		Review:         options.Review,
+	}
+
+	if options.Code != "" {
+		code := NormalizeCode(options.Code)
+		if err := ValidateCode(code); err != nil {
+			return nil, err
+		}
+		shortUrl.ShortCode = options.shortCode(code)
+	}
+	if options.Namespace != nil {
+		shortUrl.NamespaceId = &options.Namespace.Id
+	}
+
+	return shortUrl, nil
+}
+
+// checkVisibility returns the visibility to store for a link of creator, public when none is asked for
+func checkVisibility(visibility string, creator Creator) (string, error) {
+	switch visibility {
+	case "", database.VisibilityPublic:
+		return database.VisibilityPublic, nil
+	case database.VisibilityUnlisted:
+		return visibility, nil
+	case database.VisibilityPrivate:
+		if creator.UserId == nil {
+			return "", ErrPrivateAnonymous
+		}
+		return visibility, nil
+	}
+	return "", ErrInvalidVisibility
+}
+
+// EmailVerificationRequired reports whether users must verify their email before creating links
+func EmailVerificationRequired() bool {
+	return emailVerificationRequired
+}
+
+// checkVerified fails with ErrEmailUnverified for users who haven't verified their email yet, when it is required.
+// Requests made with the ADMIN_TOKEN and anonymous ones have no user to verify
+func checkVerified(creator Creator) error {
+	if emailVerificationRequired && creator.UserId != nil && !creator.EmailVerified {
+		return ErrEmailUnverified
+	}
+	return nil
+}
+
+// checkMember fails with ErrNotMember or ErrViewerRole unless the creator may create links for its organization,
+// if any
+func (s *Service) checkMember(creator Creator) error {
+	if creator.OrganizationId == nil {
+		return nil
+	}
+	if creator.UserId == nil {
+		return ErrNotMember
+	}
+
+	member, err := s.db.GetMembership(*creator.OrganizationId, *creator.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrNotMember
+	}
+	if err != nil {
+		return fmt.Errorf("shorten: %w", err)
+	}
+
+	if !auth.OrgRoleAllows(member.Role, database.OrgRoleEditor) {
+		return ErrViewerRole
+	}
+
+	return nil
+}
+
+// checkAlias fails with ErrAliasNotAllowed unless the plan of the creator includes custom aliases.
+// Anonymous links always get a generated code
+func (s *Service) checkAlias(creator Creator) error {
+	if creator.UserId == nil && creator.ApiKeyId == nil {
+		return ErrAliasNotAllowed
+	}
+
+	plan, err := s.planOf(creator)
+	if err != nil {
+		return err
+	}
+	if !plan.CustomAliases {
+		return ErrAliasNotAllowed
+	}
+
+	return nil
+}
+
+// Resolve returns the link behind a short code. It fails with database.ErrNotFound for unknown codes and with
+// database.ErrDisabled or database.ErrExpired, along with the link, when it can't be followed anymore.
+func (s *Service) Resolve(shortCode string) (*database.ShortUrlModel, error) {
+	if s.unknownCode(shortCode) {
+		metrics.Count("links.filtered", 1)
+		return nil, fmt.Errorf("resolve %s: %w", shortCode, database.ErrNotFound)
+	}
+	if s.missing != nil {
+		if _, ok := s.missing.Get(shortCode); ok {
+			metrics.Count("links.not_found_cached", 1)
+			return nil, fmt.Errorf("resolve %s: %w", shortCode, database.ErrNotFound)
+		}
+	}
+
+	// During a maintenance links looked up before, however long ago, are served without the database. Nothing
+	// can have changed them since writes are refused
+	if maintenance.Enabled() && s.recent != nil {
+		if cached, ok := s.recent.GetStale(shortCode); ok {
+			return cached, cached.Usable(time.Now())
+		}
+	}
+
+	entity, err := s.db.GetShortUrl(shortCode)
+
+	if err == nil && s.recent != nil {
+		s.recent.Set(shortCode, entity)
+	}
+	if errors.Is(err, database.ErrNotFound) && s.missing != nil {
+		s.rememberMissing(shortCode)
+	}
+
+	if errors.Is(err, breaker.ErrOpen) && s.recent != nil {
+		// The database is unreachable, fall back to the copy cached by a recent lookup
+		if cached, ok := s.recent.Get(shortCode); ok {
+			entity, err = cached, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := entity.Usable(time.Now()); err != nil {
+		return entity, err
+	}
+
+	return entity, nil
+}
+
+// RecordVisit counts a click on entity and stores the visit, anonymized according to the privacy settings.
+// The counter and the click event are kept in step. With CLICK_BUFFER_SIZE set the click is only held in memory,
+// to be stored by FlushClicks with the others.
+func (s *Service) RecordVisit(entity *database.ShortUrlModel, visit Visit) error {
+	metrics.Count("links.clicks", 1)
+
+	// Clicks are a write too, those during a maintenance are only counted by the metrics
+	if maintenance.Enabled() {
+		return nil
+	}
+
+	click := &database.ClickEventModel{
+		ShortUrlId: entity.Id,
+		Ip:         privacy.AnonymizeIP(visit.Ip),
+		IpHash:     privacy.HashIP(visit.Ip),
+		RawIp:      privacy.RawIP(visit.Ip),
+		UserAgent:  Truncate(visit.UserAgent, 512),
+		Referrer:   Truncate(visit.Referrer, 2048),
+		ClickedAt:  time.Now(),
+	}
+
+	if s.clicks != nil {
+		if s.clicks.add(click) {
+			return nil
+		}
+		if clickOverflow != overflowWrite {
+			metrics.Count("clicks.dropped", 1, "reason:overflow")
+			return nil
+		}
+		metrics.Count("clicks.overflow_writes", 1)
+	}
+
+	return s.db.RunInTransaction(func(tx database.Service) error {
+		if err := tx.UpdateTimesClicked(entity.ShortCode); err != nil {
+			return err
+		}
+
+		return tx.RecordClick(click)
+	})
+}
+
+// Truncate cuts value to at most max bytes, for the free text columns with a length limit
+func Truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
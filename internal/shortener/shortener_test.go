@@ -0,0 +1,893 @@
+package shortener
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortner/internal/auth"
+	"url-shortner/internal/breaker"
+	"url-shortner/internal/cache"
+	"url-shortner/internal/database"
+	"url-shortner/internal/maintenance"
+	"url-shortner/internal/mocks"
+	"url-shortner/internal/normalize"
+	"url-shortner/internal/plans"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		link           string
+		expTimeMinutes int
+		expected       error
+	}{
+		{"https link", "https://example.com/page", 60, nil},
+		{"http link", "http://example.com", 0, nil},
+		{"missing scheme", "example.com", 60, ErrInvalidLink},
+		{"other scheme", "ftp://example.com", 60, ErrInvalidLink},
+		{"missing host", "https://", 60, ErrInvalidLink},
+		{"empty", "", 60, ErrInvalidLink},
+		{"negative expiry", "https://example.com", -1, ErrInvalidExpiry},
+		{"internationalized domain", "https://münchen.de/karte", 60, nil},
+		{"ip host", "http://[::1]:8080/", 60, nil},
+		{"invalid domain", "https://ex_ample.com", 60, ErrInvalidLink},
+		{"invalid punycode", "https://xn--zz.de", 60, ErrInvalidLink},
+		{"longest link", "https://example.com/" + strings.Repeat("a", maxLinkLength-20), 60, nil},
+		{"too long", "https://example.com/" + strings.Repeat("a", maxLinkLength), 60, ErrLinkTooLong},
+	}
+
+	for _, tt := range tests {
+		if err := Validate(tt.link, tt.expTimeMinutes); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		link            string
+		expected        string
+		expectedDisplay string
+	}{
+		{"https://example.com/münchen", "https://example.com/münchen", "https://example.com/münchen"},
+		{"https://München.de/karte?q=ü", "https://xn--mnchen-3ya.de/karte?q=ü", "https://münchen.de/karte?q=ü"},
+		{"https://muenchen@münchen.de:8443/", "https://muenchen@xn--mnchen-3ya.de:8443/", "https://muenchen@münchen.de:8443/"},
+		{"http://пример.рф", "http://xn--e1afmkfd.xn--p1ai", "http://пример.рф"},
+	}
+
+	for _, tt := range tests {
+		normalized, err := NormalizeLink(tt.link)
+		if err != nil || normalized != tt.expected {
+			t.Errorf("%q: expected %q; got %q, %v", tt.link, tt.expected, normalized, err)
+		}
+		if display := DisplayLink(normalized); display != tt.expectedDisplay {
+			t.Errorf("%q: expected to display %q; got %q", normalized, tt.expectedDisplay, display)
+		}
+	}
+}
+
+func TestNormalizeLinkTrackingParams(t *testing.T) {
+	defer func(params []string) { trackingParams = params }(trackingParams)
+
+	tests := []struct {
+		name     string
+		params   []string
+		link     string
+		expected string
+	}{
+		{"disabled", nil, "https://example.com/?fbclid=abc", "https://example.com/?fbclid=abc"},
+		{"defaults", normalize.TrackingParams, "https://münchen.de/?gclid=abc&utm_source=mail", "https://xn--mnchen-3ya.de/?utm_source=mail"},
+		{"campaigns", []string{"utm_*"}, "https://example.com/?utm_source=mail&id=1", "https://example.com/?id=1"},
+	}
+
+	for _, tt := range tests {
+		trackingParams = tt.params
+		if normalized, err := NormalizeLink(tt.link); err != nil || normalized != tt.expected {
+			t.Errorf("%s: expected %q; got %q, %v", tt.name, tt.expected, normalized, err)
+		}
+	}
+}
+
+func TestShortenRetriesDuplicateCodes(t *testing.T) {
+	tests := []struct {
+		name          string
+		duplicates    int
+		expectedErr   error
+		expectedSaves int
+	}{
+		{"no collision", 0, nil, 1},
+		{"one collision", 1, nil, 2},
+		{"out of attempts", maxCodeAttempts, database.ErrDuplicateCode, maxCodeAttempts},
+	}
+
+	for _, tt := range tests {
+		saves := 0
+		db := &mocks.Service{
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				saves++
+				if saves <= tt.duplicates {
+					return nil, database.ErrDuplicateCode
+				}
+				return shortUrl, nil
+			},
+		}
+
+		entity, err := New(db, nil).Shorten("https://example.com", 60, Creator{}, Options{})
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expectedErr, err)
+		}
+		if err == nil && len(entity.ShortCode) != CodeLength {
+			t.Errorf("%s: expected a %d letters short code; got %q", tt.name, CodeLength, entity.ShortCode)
+		}
+		if saves != tt.expectedSaves {
+			t.Errorf("%s: expected %d saves; got %d", tt.name, tt.expectedSaves, saves)
+		}
+	}
+}
+
+func TestShortenChosenCode(t *testing.T) {
+	userId := 1
+	pro, free := Creator{UserId: &userId, EmailVerified: true, Plan: plans.Pro}, Creator{UserId: &userId, EmailVerified: true, Plan: plans.Free}
+	docs := &database.NamespaceModel{Id: 3, Name: "docs"}
+	tests := []struct {
+		name        string
+		creator     Creator
+		options     Options
+		duplicate   bool
+		expectedErr error
+		expected    string
+	}{
+		{"global", pro, Options{Code: "wiki"}, false, nil, "wiki"},
+		{"namespaced", pro, Options{Namespace: docs, Code: "onboarding"}, false, nil, "docs/onboarding"},
+		{"generated in namespace", Creator{}, Options{Namespace: docs}, false, nil, ""},
+		{"taken", pro, Options{Namespace: docs, Code: "onboarding"}, true, database.ErrDuplicateCode, ""},
+		{"slash", pro, Options{Code: "docs/onboarding"}, false, ErrInvalidCode, ""},
+		{"reserved", pro, Options{Namespace: docs, Code: "info"}, false, ErrInvalidCode, ""},
+		{"plan without aliases", free, Options{Code: "wiki"}, false, ErrAliasNotAllowed, ""},
+		{"anonymous", Creator{}, Options{Code: "wiki"}, false, ErrAliasNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				if tt.duplicate {
+					return nil, database.ErrDuplicateCode
+				}
+				return shortUrl, nil
+			},
+		}
+
+		entity, err := New(db, nil).Shorten("https://example.com", 60, tt.creator, tt.options)
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expectedErr, err)
+		}
+		if err == nil && tt.expected == "" && (len(entity.ShortCode) != len("docs/")+CodeLength || *entity.NamespaceId != docs.Id) {
+			t.Errorf("%s: expected a generated code in the namespace; got %q", tt.name, entity.ShortCode)
+		}
+		if err == nil && tt.expected != "" && entity.ShortCode != tt.expected {
+			t.Errorf("%s: expected short code %q; got %q", tt.name, tt.expected, entity.ShortCode)
+		}
+		if saves := len(db.CallsTo("SaveShortUrl")); tt.duplicate && saves != 1 {
+			t.Errorf("%s: expected a chosen code not to be retried; got %d saves", tt.name, saves)
+		}
+	}
+}
+
+func TestShortenVisibility(t *testing.T) {
+	userId := 1
+	tests := []struct {
+		name        string
+		creator     Creator
+		visibility  string
+		expectedErr error
+		expected    string
+	}{
+		{"default", Creator{}, "", nil, database.VisibilityPublic},
+		{"unlisted", Creator{}, database.VisibilityUnlisted, nil, database.VisibilityUnlisted},
+		{"private", Creator{UserId: &userId, EmailVerified: true}, database.VisibilityPrivate, nil, database.VisibilityPrivate},
+		{"private without owner", Creator{}, database.VisibilityPrivate, ErrPrivateAnonymous, ""},
+		{"unknown", Creator{}, "secret", ErrInvalidVisibility, ""},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				return shortUrl, nil
+			},
+		}
+
+		entity, err := New(db, nil).Shorten("https://example.com", 60, tt.creator, Options{Visibility: tt.visibility})
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expectedErr, err)
+		}
+		if err == nil && entity.Visibility != tt.expected {
+			t.Errorf("%s: expected visibility %q; got %q", tt.name, tt.expected, entity.Visibility)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Now()
+	active := &database.ShortUrlModel{ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: now}
+	expired := &database.ShortUrlModel{ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 1, CreatedAt: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name     string
+		cached   *database.ShortUrlModel
+		entity   *database.ShortUrlModel
+		err      error
+		expected error
+	}{
+		{"active", nil, active, nil, nil},
+		{"expired", nil, expired, nil, database.ErrExpired},
+		{"not found", nil, nil, database.ErrNotFound, database.ErrNotFound},
+		{"unavailable and cached", active, nil, breaker.ErrOpen, nil},
+		{"unavailable and not cached", nil, nil, breaker.ErrOpen, breaker.ErrOpen},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+				return tt.entity, tt.err
+			},
+		}
+		recent := cache.NewLRU[*database.ShortUrlModel](10, time.Minute)
+		if tt.cached != nil {
+			recent.Set("abcdefgh", tt.cached)
+		}
+
+		_, err := New(db, recent).Resolve("abcdefgh")
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestResolveInMaintenance(t *testing.T) {
+	maintenance.Set(true, "")
+	defer maintenance.Set(false, "")
+
+	db := &mocks.Service{
+		GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+			return nil, breaker.ErrOpen
+		},
+	}
+	recent := cache.NewLRU[*database.ShortUrlModel](10, time.Millisecond)
+	recent.Set("abcdefgh", &database.ShortUrlModel{ShortCode: "abcdefgh", Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: time.Now()})
+	time.Sleep(5 * time.Millisecond)
+
+	entity, err := New(db, recent).Resolve("abcdefgh")
+	if err != nil || entity.Link != "https://example.com" {
+		t.Errorf("expected the link cached before the maintenance; got %v, %v", entity, err)
+	}
+	if calls := len(db.CallsTo("GetShortUrl")); calls != 0 {
+		t.Errorf("expected the database left alone; got %d lookups", calls)
+	}
+
+	if err := New(db, recent).RecordVisit(entity, Visit{}); err != nil || len(db.CallsTo("RunInTransaction")) != 0 {
+		t.Errorf("expected the click not to be stored; got %v", err)
+	}
+}
+
+func TestResolveCodeFilter(t *testing.T) {
+	db := &mocks.Service{
+		ListShortCodesFunc: func(since time.Time) ([]string, error) {
+			return []string{"abcdefgh"}, nil
+		},
+		GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+			return &database.ShortUrlModel{ShortCode: shortCode, Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: time.Now()}, nil
+		},
+		SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+			return shortUrl, nil
+		},
+	}
+	s := New(db, nil)
+
+	// Nothing is filtered before the first build
+	if _, err := s.Resolve("zzzzzzzz"); err != nil {
+		t.Errorf("expected the database to be asked before the filter is built; got %v", err)
+	}
+
+	if _, err := s.rebuildCodes(); err != nil {
+		t.Fatal(err)
+	}
+	lookups := len(db.CallsTo("GetShortUrl"))
+
+	if _, err := s.Resolve("zzzzzzzz"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an unknown code not found; got %v", err)
+	}
+	if calls := len(db.CallsTo("GetShortUrl")); calls != lookups {
+		t.Errorf("expected the database left alone for an unknown code; got %d lookups", calls-lookups)
+	}
+
+	created, err := s.Shorten("https://example.com", 60, Creator{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"abcdefgh", created.ShortCode} {
+		if _, err := s.Resolve(code); err != nil {
+			t.Errorf("expected %s to resolve; got %v", code, err)
+		}
+	}
+
+	// Created by another replica, and notified
+	s.Forget("elsewhere")
+	if _, err := s.Resolve("elsewhere"); err != nil {
+		t.Errorf("expected a notified code to resolve; got %v", err)
+	}
+
+	s.ForgetAll()
+	select {
+	case <-s.codesMissed:
+	default:
+		t.Errorf("expected the filter refreshed once notifications were missed")
+	}
+}
+
+func TestResolveNotFoundCache(t *testing.T) {
+	saved := map[string]*database.ShortUrlModel{}
+	db := &mocks.Service{
+		GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+			if entity, ok := saved[shortCode]; ok {
+				return entity, nil
+			}
+			return nil, database.ErrNotFound
+		},
+	}
+	s := New(db, nil)
+
+	for range 3 {
+		if _, err := s.Resolve("go/docs"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("expected an unknown code not found; got %v", err)
+		}
+	}
+	if calls := len(db.CallsTo("GetShortUrl")); calls != 1 {
+		t.Errorf("expected the database asked once for an unknown code; got %d lookups", calls)
+	}
+
+	// Notified as created by another replica, while the lookups still read a lagging replica
+	s.Forget("go/docs")
+	for range 2 {
+		s.Resolve("go/docs")
+	}
+	if calls := len(db.CallsTo("GetShortUrl")); calls != 3 {
+		t.Errorf("expected the misses of a code changed lately left uncached; got %d lookups", calls)
+	}
+
+	// As done by Shorten once the link is saved
+	saved["go/docs"] = &database.ShortUrlModel{ShortCode: "go/docs", Link: "https://example.com", ExpTimeMinutes: 60, CreatedAt: time.Now()}
+	s.rememberCode("go/docs")
+
+	if _, err := s.Resolve("go/docs"); err != nil {
+		t.Errorf("expected the code to resolve once created; got %v", err)
+	}
+}
+
+func TestShortenQuotas(t *testing.T) {
+	defer func(perDay, active, apiKeyPerDay int) {
+		linksPerDay, maxActiveLinks, apiKeyLinksPerDay = perDay, active, apiKeyPerDay
+	}(linksPerDay, maxActiveLinks, apiKeyLinksPerDay)
+	linksPerDay, maxActiveLinks, apiKeyLinksPerDay = 10, 5, 3
+
+	userId, apiKeyId := 1, 2
+	tests := []struct {
+		name        string
+		creator     Creator
+		userCount   database.LinkCountModel
+		apiKeyCount database.LinkCountModel
+		expected    error
+	}{
+		{"anonymous", Creator{}, database.LinkCountModel{Created: 100, Active: 100}, database.LinkCountModel{}, nil},
+		{"within quotas", Creator{UserId: &userId, EmailVerified: true, ApiKeyId: &apiKeyId}, database.LinkCountModel{Created: 4, Active: 4}, database.LinkCountModel{Created: 2}, nil},
+		{"user daily quota", Creator{UserId: &userId, EmailVerified: true}, database.LinkCountModel{Created: 10, Active: 1}, database.LinkCountModel{}, ErrDailyQuotaExceeded},
+		{"api key daily quota", Creator{UserId: &userId, EmailVerified: true, ApiKeyId: &apiKeyId}, database.LinkCountModel{Created: 3, Active: 3}, database.LinkCountModel{Created: 3}, ErrDailyQuotaExceeded},
+		{"active quota", Creator{UserId: &userId, EmailVerified: true}, database.LinkCountModel{Created: 1, Active: 5}, database.LinkCountModel{}, ErrActiveQuotaExceeded},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			CountUserLinksFunc: func(userId int, since time.Time) (*database.LinkCountModel, error) {
+				return &tt.userCount, nil
+			},
+			CountApiKeyLinksFunc: func(apiKeyId int, since time.Time) (*database.LinkCountModel, error) {
+				return &tt.apiKeyCount, nil
+			},
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				return shortUrl, nil
+			},
+		}
+
+		_, err := New(db, nil).Shorten("https://example.com", 60, tt.creator, Options{})
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+
+		if saves := len(db.CallsTo("SaveShortUrl")); (saves == 1) != (tt.expected == nil) {
+			t.Errorf("%s: expected the link to be saved only within quotas; got %d saves", tt.name, saves)
+		}
+	}
+}
+
+func TestShortenBatch(t *testing.T) {
+	defer func(perDay int) { linksPerDay = perDay }(linksPerDay)
+	linksPerDay = 3
+
+	userId := 1
+	calls := 0
+	db := &mocks.Service{
+		CountUserLinksFunc: func(userId int, since time.Time) (*database.LinkCountModel, error) {
+			return &database.LinkCountModel{}, nil
+		},
+		SaveShortUrlsFunc: func(shortUrls []*database.ShortUrlModel) ([]*database.ShortUrlModel, error) {
+			calls++
+			var inserted []*database.ShortUrlModel
+			for _, shortUrl := range shortUrls {
+				// The generated code collides on the first attempt
+				if shortUrl.ShortCode == "taken" || (calls == 1 && len(shortUrl.ShortCode) == CodeLength) {
+					continue
+				}
+				inserted = append(inserted, shortUrl)
+			}
+			return inserted, nil
+		},
+	}
+
+	links := []Link{
+		{Link: "https://example.com/a", ExpTimeMinutes: 60},
+		{Link: "https://example.com/b", ExpTimeMinutes: 60, Options: Options{Code: "taken"}},
+		{Link: "not a link", ExpTimeMinutes: 60},
+		{Link: "https://example.com/c", ExpTimeMinutes: 60, Options: Options{Code: "free"}},
+		{Link: "https://example.com/d", ExpTimeMinutes: 60},
+	}
+	entities, errs, err := New(db, nil).ShortenBatch(links, Creator{UserId: &userId, EmailVerified: true, Plan: plans.Pro})
+	if err != nil {
+		t.Fatalf("expected the batch to be saved; got %v", err)
+	}
+
+	expected := []error{nil, database.ErrDuplicateCode, ErrInvalidLink, nil, ErrDailyQuotaExceeded}
+	for i := range links {
+		if !errors.Is(errs[i], expected[i]) || (errs[i] != nil) != (expected[i] != nil) {
+			t.Errorf("link %d: expected %v; got %v", i, expected[i], errs[i])
+		}
+		if (entities[i] != nil) != (expected[i] == nil) {
+			t.Errorf("link %d: expected a link only without error; got %+v", i, entities[i])
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected the colliding code to be drawn again in a second batch; got %d batches", calls)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	defer func(perDay int) { linksPerDay = perDay }(linksPerDay)
+	linksPerDay = 1
+
+	userId := 1
+	pro := Creator{UserId: &userId, EmailVerified: true, Plan: plans.Pro}
+	tests := []struct {
+		name            string
+		creator         Creator
+		code            string
+		exists          bool
+		createdToday    int
+		otherOwner      bool
+		expectedErr     error
+		expectedCreated bool
+	}{
+		{name: "no code", creator: pro, expectedErr: ErrInvalidCode},
+		{name: "anonymous", code: "deploy", expectedErr: ErrAliasNotAllowed},
+		{name: "create", creator: pro, code: "deploy", expectedCreated: true},
+		{name: "create over quota", creator: pro, code: "deploy", createdToday: 1, expectedErr: ErrDailyQuotaExceeded},
+		{name: "update over quota", creator: pro, code: "deploy", exists: true, createdToday: 1},
+		{name: "code of someone else", creator: pro, code: "deploy", exists: true, otherOwner: true, expectedErr: database.ErrDuplicateCode},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetShortUrlFunc: func(shortCode string) (*database.ShortUrlModel, error) {
+				if !tt.exists {
+					return nil, database.ErrNotFound
+				}
+				return &database.ShortUrlModel{ShortCode: shortCode}, nil
+			},
+			CountUserLinksFunc: func(userId int, since time.Time) (*database.LinkCountModel, error) {
+				return &database.LinkCountModel{Created: tt.createdToday}, nil
+			},
+			UpsertShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, bool, error) {
+				if tt.otherOwner {
+					return nil, false, database.ErrDuplicateCode
+				}
+				return shortUrl, !tt.exists, nil
+			},
+		}
+
+		entity, created, err := New(db, nil).Upsert("https://example.com", 60, tt.creator, Options{Code: tt.code})
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expectedErr, err)
+			continue
+		}
+		if err == nil && (created != tt.expectedCreated || entity.ShortCode != tt.code) {
+			t.Errorf("%s: expected %q to be created %v; got %+v created %v", tt.name, tt.code, tt.expectedCreated, entity, created)
+		}
+	}
+}
+
+func TestShortenOrganization(t *testing.T) {
+	userId, organizationId := 1, 7
+	tests := []struct {
+		name     string
+		creator  Creator
+		role     string
+		expected error
+	}{
+		{"anonymous", Creator{OrganizationId: &organizationId}, "", ErrNotMember},
+		{"not a member", Creator{UserId: &userId, EmailVerified: true, OrganizationId: &organizationId}, "", ErrNotMember},
+		{"viewer", Creator{UserId: &userId, EmailVerified: true, OrganizationId: &organizationId}, database.OrgRoleViewer, ErrViewerRole},
+		{"editor", Creator{UserId: &userId, EmailVerified: true, OrganizationId: &organizationId}, database.OrgRoleEditor, nil},
+		{"owner", Creator{UserId: &userId, EmailVerified: true, OrganizationId: &organizationId}, database.OrgRoleOwner, nil},
+	}
+
+	for _, tt := range tests {
+		db := &mocks.Service{
+			GetMembershipFunc: func(organizationId, userId int) (*database.MemberModel, error) {
+				if tt.role == "" {
+					return nil, database.ErrNotFound
+				}
+				return &database.MemberModel{OrganizationId: organizationId, UserId: userId, Role: tt.role}, nil
+			},
+			GetOrganizationFunc: func(id int) (*database.OrganizationModel, error) {
+				return &database.OrganizationModel{Id: id, Plan: plans.Pro}, nil
+			},
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				return shortUrl, nil
+			},
+		}
+
+		entity, err := New(db, nil).Shorten("https://example.com", 60, tt.creator, Options{})
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+
+		if err == nil && (entity.OrganizationId == nil || *entity.OrganizationId != organizationId) {
+			t.Errorf("%s: expected the link to belong to organization %d", tt.name, organizationId)
+		}
+	}
+}
+
+func TestShortenEmailVerification(t *testing.T) {
+	defer func(required bool) { emailVerificationRequired = required }(emailVerificationRequired)
+
+	userId := 1
+	tests := []struct {
+		name     string
+		required bool
+		creator  Creator
+		expected error
+	}{
+		{"verified", true, Creator{UserId: &userId, EmailVerified: true}, nil},
+		{"unverified", true, Creator{UserId: &userId}, ErrEmailUnverified},
+		{"anonymous", true, Creator{}, nil},
+		{"not required", false, Creator{UserId: &userId}, nil},
+	}
+
+	for _, tt := range tests {
+		emailVerificationRequired = tt.required
+		db := &mocks.Service{
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				return shortUrl, nil
+			},
+		}
+
+		_, err := New(db, nil).Shorten("https://example.com", 60, tt.creator, Options{})
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+		if _, _, err := New(db, nil).ShortenBatch([]Link{{Link: "https://example.com", ExpTimeMinutes: 60}}, tt.creator); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected the batch to fail with %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestShortenAnonymousLinks(t *testing.T) {
+	defer func(policy string, perDay int) { anonymousLinks, anonymousLinksPerDay = policy, perDay }(anonymousLinks, anonymousLinksPerDay)
+	anonymousLinksPerDay = 10
+
+	userId := 1
+	tests := []struct {
+		name        string
+		policy      string
+		creator     Creator
+		expMinutes  int
+		created     int
+		expected    error
+		expectedExp int
+	}{
+		{name: "allowed", policy: AnonymousAllowed, created: 10},
+		{name: "blocked", policy: AnonymousBlocked, expected: ErrAnonymousBlocked},
+		{name: "blocked user", policy: AnonymousBlocked, creator: Creator{UserId: &userId, EmailVerified: true}},
+		{name: "blocked admin", policy: AnonymousBlocked, creator: Creator{Admin: true}},
+		{name: "limited expiry", policy: AnonymousLimited, expMinutes: 60, expectedExp: 60},
+		{name: "limited longer expiry", policy: AnonymousLimited, expMinutes: anonymousMaxExpMinutes + 1, expectedExp: anonymousMaxExpMinutes},
+		{name: "limited no expiry", policy: AnonymousLimited, expectedExp: anonymousMaxExpMinutes},
+		{name: "limited daily quota", policy: AnonymousLimited, created: 10, expected: ErrDailyQuotaExceeded},
+		{name: "limited user", policy: AnonymousLimited, creator: Creator{UserId: &userId, EmailVerified: true}, created: 10},
+	}
+
+	for _, tt := range tests {
+		anonymousLinks = tt.policy
+		db := &mocks.Service{
+			SaveShortUrlFunc: func(shortUrl *database.ShortUrlModel) (*database.ShortUrlModel, error) {
+				return shortUrl, nil
+			},
+			CountAnonymousLinksFunc: func(since time.Time) (int, error) {
+				return tt.created, nil
+			},
+		}
+
+		entity, err := New(db, nil).Shorten("https://example.com", tt.expMinutes, tt.creator, Options{})
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+			continue
+		}
+		if err == nil && entity.ExpTimeMinutes != tt.expectedExp {
+			t.Errorf("%s: expected to expire after %d minutes; got %d", tt.name, tt.expectedExp, entity.ExpTimeMinutes)
+		}
+	}
+
+	anonymousLinks = AnonymousLimited
+	db := &mocks.Service{CountAnonymousLinksFunc: func(since time.Time) (int, error) { return 9, nil }}
+	links := []Link{{Link: "https://example.com"}, {Link: "https://example.org"}}
+	if _, _, err := New(db, nil).ShortenBatch(links, Creator{}); !errors.Is(err, ErrDailyQuotaExceeded) {
+		t.Errorf("expected a batch past the daily quota refused; got %v", err)
+	}
+
+	anonymousLinks = AnonymousBlocked
+	if _, _, err := New(db, nil).ShortenBatch(links, Creator{}); !errors.Is(err, ErrAnonymousBlocked) {
+		t.Errorf("expected the batch refused; got %v", err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	db := &mocks.Service{
+		ClaimLinkFunc: func(tokenHash string, userId int) (*database.ShortUrlModel, error) {
+			return &database.ShortUrlModel{Id: 1, OwnerId: &userId}, nil
+		},
+	}
+	service := New(db, nil)
+
+	token, err := service.IssueClaim(&database.ShortUrlModel{Id: 1, ShortCode: "abc"})
+	if err != nil || token == "" {
+		t.Fatalf("expected a claim token; got %q, %v", token, err)
+	}
+	saved := db.CallsTo("SaveLinkClaim")[0].Args[0].(*database.LinkClaimModel)
+	if saved.ShortUrlId != 1 || saved.TokenHash != auth.HashToken(token) {
+		t.Errorf("expected only the hash of the token stored; got %+v", saved)
+	}
+
+	if _, err := service.Claim(token, Creator{}); !errors.Is(err, ErrClaimAnonymous) {
+		t.Errorf("expected anonymous claims refused; got %v", err)
+	}
+	userId := 2
+	claimed, err := service.Claim(token, Creator{UserId: &userId, EmailVerified: true})
+	if err != nil || *claimed.OwnerId != userId {
+		t.Errorf("expected the link claimed; got %+v, %v", claimed, err)
+	}
+	if calls := db.CallsTo("ClaimLink"); len(calls) != 1 || calls[0].Args[0] != auth.HashToken(token) {
+		t.Errorf("expected the claim looked up by the hash of its token; got %+v", calls)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	defer func(policy string) { anonymousLinks = policy }(anonymousLinks)
+	anonymousLinks = AnonymousLimited
+
+	db := &mocks.Service{
+		EditShortUrlFunc: func(shortCode string, link string, expTimeMinutes int, review *database.ReviewModel) (*database.ShortUrlModel, error) {
+			return &database.ShortUrlModel{ShortCode: shortCode, Link: link, ExpTimeMinutes: expTimeMinutes}, nil
+		},
+	}
+	service := New(db, nil)
+
+	if _, err := service.Editable("abc", ""); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected a missing token refused; got %v", err)
+	}
+	if _, err := service.Edit(&database.ShortUrlModel{ShortCode: "abc", DisabledAt: &time.Time{}}, "https://example.com", 60, nil); !errors.Is(err, database.ErrDisabled) {
+		t.Errorf("expected a disabled link left alone; got %v", err)
+	}
+	if _, err := service.Edit(&database.ShortUrlModel{ShortCode: "abc"}, "javascript:alert(1)", 60, nil); !errors.Is(err, ErrInvalidLink) {
+		t.Errorf("expected an invalid destination refused; got %v", err)
+	}
+
+	edited, err := service.Edit(&database.ShortUrlModel{ShortCode: "abc"}, "https://example.com", 0, nil)
+	if err != nil || edited.ExpTimeMinutes != anonymousMaxExpMinutes {
+		t.Errorf("expected the expiry capped under the limited policy; got %+v, %v", edited, err)
+	}
+}
+
+func TestQuotaNearing(t *testing.T) {
+	tests := []struct {
+		quota    Quota
+		expected bool
+	}{
+		{Quota{Used: 8, Limit: 10}, true},
+		{Quota{Used: 9, Limit: 10}, false},
+		{Quota{Used: 7, Limit: 10}, false},
+		{Quota{Used: 0, Limit: 1}, true},
+		{Quota{Used: 89, Limit: 99}, true},
+		{Quota{Used: 500, Limit: 0}, false},
+	}
+
+	for _, tt := range tests {
+		if nearing := tt.quota.Nearing(90); nearing != tt.expected {
+			t.Errorf("%+v: expected nearing %t; got %t", tt.quota, tt.expected, nearing)
+		}
+	}
+}
+
+func TestUsageOrganization(t *testing.T) {
+	defer func(perDay int) { linksPerDay = perDay }(linksPerDay)
+	linksPerDay = 10
+
+	userId, organizationId := 1, 7
+	db := &mocks.Service{
+		GetOrganizationFunc: func(id int) (*database.OrganizationModel, error) {
+			return &database.OrganizationModel{Id: id, Plan: plans.Enterprise}, nil
+		},
+		CountOrganizationLinksFunc: func(organizationId int, since time.Time) (*database.LinkCountModel, error) {
+			return &database.LinkCountModel{Created: 4, Active: 9}, nil
+		},
+	}
+
+	usage, err := New(db, nil).Usage(Creator{UserId: &userId, EmailVerified: true, OrganizationId: &organizationId, Plan: plans.Free}, time.Now())
+	if err != nil {
+		t.Fatalf("expected the usage; got %v", err)
+	}
+
+	if usage.Plan != plans.Enterprise {
+		t.Errorf("expected the plan of the organization; got %s", usage.Plan)
+	}
+	if usage.LinksToday != (Quota{Used: 4, Limit: 10}) || usage.ActiveLinks != (Quota{Used: 9}) {
+		t.Errorf("expected the links of the organization against the instance quotas; got %+v", usage)
+	}
+	if calls := db.CallsTo("CountUserLinks"); len(calls) != 0 {
+		t.Errorf("expected the links of the user not to be counted; got %d calls", len(calls))
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		hint     string
+		expected string
+	}{
+		{"summer-sale", "summer-sale"},
+		{"  Summer Sale! 2025 ", "summer-sale-2025"},
+		{"--Été__promo--", "t-promo"},
+		{"!!!", ""},
+		{strings.Repeat("a", 50), strings.Repeat("a", maxHintLength)},
+	}
+
+	for _, tt := range tests {
+		if slug := Slugify(tt.hint); slug != tt.expected {
+			t.Errorf("%q: expected %q; got %q", tt.hint, tt.expected, slug)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	docs := &database.NamespaceModel{Id: 3, Name: "docs"}
+	db := &mocks.Service{
+		TakenShortCodesFunc: func(shortCodes []string) ([]string, error) {
+			return []string{"docs/summer-sale", "docs/ss"}, nil
+		},
+	}
+
+	suggestions, err := New(db, nil).Suggest("Summer Sale", docs, 4)
+	if err != nil {
+		t.Fatalf("expected suggestions; got %v", err)
+	}
+
+	expected := []string{"summersale", "summer-sale-2", "summer-sale-3", "summer-sale-4"}
+	if strings.Join(suggestions, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v; got %v", expected, suggestions)
+	}
+}
+
+func TestValidateCodeUnicode(t *testing.T) {
+	defer func(enabled bool) { unicodeCodes = enabled }(unicodeCodes)
+
+	tests := []struct {
+		name     string
+		code     string
+		enabled  bool
+		expected error
+	}{
+		{"ascii", "summer-sale", false, nil},
+		{"emoji disabled", "🎉", false, ErrInvalidCode},
+		{"emoji", "🎉🚀", true, nil},
+		{"emoji sequence", "👍🏽", true, nil},
+		{"accents", "été-2025", true, nil},
+		{"decomposed accents", "e\u0301te\u0301", true, ErrInvalidCode},
+		{"other script", "ссылка", true, nil},
+		{"space", "🎉 🚀", true, ErrInvalidCode},
+		{"slash", "🎉/🚀", true, ErrInvalidCode},
+		{"leading dash", "-🎉", true, ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		unicodeCodes = tt.enabled
+		if err := ValidateCode(tt.code); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	unicodeCodes = true
+	if err := ValidateCode(NormalizeCode("e\u0301te\u0301")); err != nil {
+		t.Errorf("expected the normalized code to be valid; got %v", err)
+	}
+	if code := GenerateEmojiCode(); !validUnicodeCode(code) || len([]rune(code)) != EmojiCodeLength {
+		t.Errorf("expected a code of %d emojis; got %q", EmojiCodeLength, code)
+	}
+}
+
+func TestRecordVisitBuffered(t *testing.T) {
+	failing := true
+	var stored []*database.ClickEventModel
+	db := &mocks.Service{
+		RecordClicksFunc: func(clicks []*database.ClickEventModel) (int64, error) {
+			if failing {
+				return 0, breaker.ErrOpen
+			}
+			stored = append(stored, clicks...)
+			return int64(len(clicks)), nil
+		},
+	}
+	s := New(db, nil)
+	s.clicks = newClickBuffer(db, 3, 2)
+	entity := &database.ShortUrlModel{Id: 7, ShortCode: "abcdefgh"}
+
+	for range 2 {
+		if err := s.RecordVisit(entity, Visit{Ip: "203.0.113.7"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := len(db.CallsTo("RunInTransaction")); calls != 0 {
+		t.Errorf("expected the clicks held in memory; got %d transactions", calls)
+	}
+	select {
+	case <-s.clicks.ready:
+	default:
+		t.Errorf("expected a flush asked for once the flush size is reached")
+	}
+
+	// A failed flush keeps the clicks for the next one
+	if err := s.clicks.flush(); err == nil {
+		t.Fatalf("expected the flush to fail")
+	}
+	s.RecordVisit(entity, Visit{Ip: "203.0.113.7"})
+
+	// The buffer is full, the click is dropped
+	s.RecordVisit(entity, Visit{Ip: "203.0.113.7"})
+	if calls := len(db.CallsTo("RunInTransaction")); calls != 0 {
+		t.Errorf("expected the overflowing click dropped; got %d transactions", calls)
+	}
+
+	failing = false
+	if err := s.clicks.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 3 || stored[0].ShortUrlId != 7 || stored[0].IpHash == "" || stored[0].ClickedAt.IsZero() {
+		t.Errorf("expected the 3 clicks held stored, anonymized and dated; got %+v", stored)
+	}
+
+	clickOverflow = overflowWrite
+	defer func() { clickOverflow = "" }()
+	for range 4 {
+		s.RecordVisit(entity, Visit{Ip: "203.0.113.7"})
+	}
+	if calls := len(db.CallsTo("RunInTransaction")); calls != 1 {
+		t.Errorf("expected the overflowing click stored right away; got %d transactions", calls)
+	}
+}
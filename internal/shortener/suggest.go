@@ -0,0 +1,112 @@
+package shortener
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"url-shortner/internal/database"
+)
+
+// Longest slug kept from a hint, leaving room for the suffixes of its variations
+const maxHintLength = 40
+
+// Short, easy to spell words used to vary a hint or to make up codes without one
+var suggestionWords = []string{
+	"amber", "apple", "arrow", "bloom", "breeze", "brick", "cedar", "cloud", "comet", "coral",
+	"crisp", "daisy", "delta", "ember", "fable", "fern", "flint", "frost", "glow", "harbor",
+	"hazel", "island", "jade", "lemon", "lunar", "maple", "meadow", "mint", "nova", "ocean",
+	"orbit", "pearl", "pixel", "quartz", "river", "sage", "spark", "stone", "sunny", "tidal",
+	"tulip", "velvet", "willow", "zest",
+}
+
+// Slugify turns a hint into a lowercase code of letters, digits and dashes, e.g. "Summer Sale!" into "summer-sale".
+func Slugify(hint string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(hint) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	return strings.TrimRight(Truncate(slug.String(), maxHintLength), "-")
+}
+
+// candidates returns human friendly codes for the hint, the closest to it first
+func candidates(hint string) []string {
+	slug := Slugify(hint)
+	words := rand.Perm(len(suggestionWords))
+
+	if slug == "" {
+		codes := make([]string, 0, len(words))
+		for i := 0; i+1 < len(words); i += 2 {
+			codes = append(codes, suggestionWords[words[i]]+"-"+suggestionWords[words[i+1]])
+		}
+		return codes
+	}
+
+	codes := []string{slug}
+	if compact := strings.ReplaceAll(slug, "-", ""); compact != slug {
+		codes = append(codes, compact)
+	}
+	if parts := strings.Split(slug, "-"); len(parts) > 1 {
+		initials := ""
+		for _, part := range parts {
+			initials += part[:1]
+		}
+		codes = append(codes, initials)
+	}
+	for n := 2; n <= 5; n++ {
+		codes = append(codes, slug+"-"+strconv.Itoa(n))
+	}
+	for _, i := range words {
+		codes = append(codes, slug+"-"+suggestionWords[i])
+	}
+
+	return codes
+}
+
+// Suggest returns up to count available codes inspired by the hint, in the namespace when it isn't nil.
+// Codes are only checked, one may still be taken by the time it is used.
+func (s *Service) Suggest(hint string, namespace *database.NamespaceModel, count int) ([]string, error) {
+	options := Options{Namespace: namespace}
+
+	var codes, shortCodes []string
+	for _, code := range candidates(hint) {
+		if ValidateCode(code) != nil {
+			continue
+		}
+		codes = append(codes, code)
+		shortCodes = append(shortCodes, options.shortCode(code))
+	}
+
+	taken, err := s.db.TakenShortCodes(shortCodes)
+	if err != nil {
+		return nil, fmt.Errorf("suggest codes for %q: %w", hint, err)
+	}
+
+	unavailable := make(map[string]bool, len(taken))
+	for _, shortCode := range taken {
+		unavailable[shortCode] = true
+	}
+
+	suggestions := []string{}
+	for i, code := range codes {
+		if len(suggestions) == count {
+			break
+		}
+		if !unavailable[shortCodes[i]] {
+			suggestions = append(suggestions, code)
+		}
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,30 @@
+package shortener
+
+import (
+	"os"
+	"strings"
+
+	"url-shortner/internal/normalize"
+)
+
+// Query parameters removed from destinations before they are stored, none unless STRIP_TRACKING_PARAMS is set:
+// "true" strips normalize.TrackingParams, anything else is a comma separated list such as "fbclid,gclid,utm_*"
+var trackingParams = trackingParamsFromEnv()
+
+func trackingParamsFromEnv() []string {
+	value := os.Getenv("STRIP_TRACKING_PARAMS")
+	switch value {
+	case "", "false":
+		return nil
+	case "true":
+		return normalize.TrackingParams
+	}
+
+	params := []string{}
+	for _, param := range strings.Split(value, ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
@@ -0,0 +1,66 @@
+package shortener
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Chosen codes may use any letter, digit or emoji when UNICODE_CODES is set, only ASCII otherwise
+var unicodeCodes = os.Getenv("UNICODE_CODES") == "true"
+
+// Length of the generated emoji codes
+const EmojiCodeLength = 4
+
+var ErrUnicodeDisabled = errors.New("unicode codes are disabled")
+
+// Emojis drawn for generated emoji codes, one code point each so they look alike on every platform
+var emojiLetters = []rune("😀😎🤖👻👽🎃🐶🐱🦊🐼🐸🐙🦄🐝🦋🌵🌻🍀🍄🌈🔥⭐🌙☀🍕🍔🍩🍉🍓🍒🥑🌮🎉🎈🎁🎸🎧🎮🚀🛸⚡💎🔑💡📌📎🧩🏆⚽🏀🎯🧲🪐🌊🍿🥨🧁🍦🦖🐳🦀🐢🍇")
+
+// NormalizeCode returns the NFC form of a short code, so the composed and decomposed spellings of an accented
+// letter are the same code.
+func NormalizeCode(code string) string {
+	return norm.NFC.String(code)
+}
+
+// GenerateEmojiCode returns a random short code of EmojiCodeLength emojis.
+func GenerateEmojiCode() string {
+	code := make([]rune, EmojiCodeLength)
+	for i := range code {
+		code[i] = emojiLetters[rand.Intn(len(emojiLetters))]
+	}
+	return string(code)
+}
+
+// validUnicodeCode reports whether code is made of 1 to 64 letters, digits, emojis, dashes or underscores.
+// Marks, modifiers and zero width joiners are accepted after another character to spell accents, skin tones and
+// emoji sequences
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func validUnicodeCode(code string) bool {
+	if !utf8.ValidString(code) || utf8.RuneCountInString(code) > 64 {
+		return false
+	}
+
+	for i, r := range []rune(code) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.Is(unicode.So, r):
+		case i > 0 && (r == '-' || r == '_' || r == '\u200d' || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Sk, r)):
+		default:
+			return false
+		}
+	}
+
+	return code != ""
+}
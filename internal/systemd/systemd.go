@@ -0,0 +1,115 @@
+// Package systemd speaks the parts of the systemd protocols a service needs: socket activation and sd_notify,
+// with its watchdog. Everything is detected at runtime from the environment systemd sets, so the binaries behave
+// the same as before when started any other way.
+package systemd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States sent with Notify
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// First file descriptor passed by socket activation, after stdin, stdout and stderr
+const listenFdsStart = 3
+
+// Listener returns the socket systemd passed to the process when it was socket activated, nil otherwise. Only the
+// first socket is used, the api serves a single port
+func Listener() (net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		// Not activated, or the variables were meant for the process that started this one
+		return nil, nil
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	if fds > 1 {
+		log.Printf("[systemd:Listener] Received {%d} sockets, serving the first one only", fds)
+	}
+
+	file := os.NewFile(uintptr(listenFdsStart), "LISTEN_FD_3")
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("use the activated socket: %w", err)
+	}
+	return listener, nil
+}
+
+// Notify sends state to the service manager. It does nothing when the process isn't run by systemd with
+// Type=notify, NOTIFY_SOCKET being unset then
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("notify %s: %w", state, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notify %s: %w", state, err)
+	}
+	return nil
+}
+
+// WatchdogInterval returns how often the watchdog expects to be pinged, half of WatchdogSec so a late ping
+// doesn't get the process killed. It returns false when the watchdog isn't enabled for this process
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
+
+// StartWatchdog pings the watchdog for as long as healthy reports true, so systemd restarts the process once it
+// hangs. It does nothing when the watchdog isn't enabled
+func StartWatchdog(healthy func() bool) {
+	interval, ok := WatchdogInterval()
+	if !ok {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if !healthy() {
+				log.Printf("[systemd:StartWatchdog] Unhealthy, skipping the watchdog ping")
+				continue
+			}
+			if err := Notify(Watchdog); err != nil {
+				log.Printf("[systemd:StartWatchdog] Could not ping the watchdog: %v", err)
+			}
+		}
+	}()
+}
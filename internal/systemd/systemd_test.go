@@ -0,0 +1,75 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := Notify(Ready); err != nil {
+		t.Fatalf("expected the state to be sent; got %v", err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != Ready {
+		t.Errorf("expected %q; got %q, %v", Ready, buf[:n], err)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(Ready); err != nil {
+		t.Errorf("expected nothing sent outside of systemd; got %v", err)
+	}
+}
+
+func TestListenerNotActivated(t *testing.T) {
+	// Meant for another process
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listener, err := Listener()
+	if listener != nil || err != nil {
+		t.Errorf("expected no listener; got %v, %v", listener, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("expected the variables cleared for child processes")
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		usec     string
+		pid      string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", "", 0, false},
+		{"30000000", "", 15 * time.Second, true},
+		{"30000000", strconv.Itoa(os.Getpid()), 15 * time.Second, true},
+		{"30000000", strconv.Itoa(os.Getpid() + 1), 0, false},
+		{"invalid", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+
+		interval, ok := WatchdogInterval()
+		if interval != tt.expected || ok != tt.ok {
+			t.Errorf("WATCHDOG_USEC=%q WATCHDOG_PID=%q: expected %v, %t; got %v, %t", tt.usec, tt.pid, tt.expected, tt.ok, interval, ok)
+		}
+	}
+}
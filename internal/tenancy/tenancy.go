@@ -0,0 +1,137 @@
+// Package tenancy implements the optional schema per tenant mode, where the data of each tenant lives in its own
+// Postgres schema and requests are routed to a tenant by their host or api key.
+package tenancy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Enabled is set by TENANT_MODE=schema. Otherwise the instance serves a single tenant from BLUEPRINT_DB_SCHEMA
+var Enabled = os.Getenv("TENANT_MODE") == "schema"
+
+// Tenant is a customer whose data is isolated in its own schema
+type Tenant struct {
+	Name string `json:"-"`
+
+	// Hostnames the tenant is served on
+	Hosts []string `json:"hosts"`
+}
+
+// Schema is the Postgres schema holding the data of the tenant.
+func (t *Tenant) Schema() string {
+	return "tenant_" + t.Name
+}
+
+// Names end up in schema names, so they are kept to what needs no quoting
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+var (
+	tenants = map[string]*Tenant{}
+	byHost  = map[string]*Tenant{}
+)
+
+// Load reads the tenants from the JSON file at TENANTS_FILE, an object of tenants keyed by name:
+//
+//	{"acme": {"hosts": ["go.acme.com"]}}
+//
+// It does nothing unless the schema per tenant mode is enabled, where the file is required.
+func Load() error {
+	if !Enabled {
+		return nil
+	}
+
+	path := os.Getenv("TENANTS_FILE")
+	if path == "" {
+		return fmt.Errorf("TENANT_MODE=schema requires TENANTS_FILE")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read TENANTS_FILE: %w", err)
+	}
+
+	return parse(content)
+}
+
+// parse validates every tenant before replacing the loaded ones
+func parse(content []byte) error {
+	loaded := map[string]*Tenant{}
+	if err := json.Unmarshal(content, &loaded); err != nil {
+		return fmt.Errorf("invalid TENANTS_FILE: %w", err)
+	}
+	if len(loaded) == 0 {
+		return fmt.Errorf("invalid TENANTS_FILE: no tenants")
+	}
+
+	hosts := map[string]*Tenant{}
+	for name, tenant := range loaded {
+		if !namePattern.MatchString(name) {
+			return fmt.Errorf("invalid TENANTS_FILE: tenant %q must be lowercase letters, digits and underscores", name)
+		}
+		tenant.Name = name
+
+		for i, host := range tenant.Hosts {
+			host = strings.ToLower(strings.TrimSpace(host))
+			if other, ok := hosts[host]; ok {
+				return fmt.Errorf("invalid TENANTS_FILE: host %q belongs to both %q and %q", host, other.Name, name)
+			}
+			tenant.Hosts[i] = host
+			hosts[host] = tenant
+		}
+	}
+
+	tenants, byHost = loaded, hosts
+
+	return nil
+}
+
+// Get returns the tenant called name, nil when there is none.
+func Get(name string) *Tenant {
+	return tenants[name]
+}
+
+// All returns every tenant, sorted by name.
+func All() []*Tenant {
+	all := make([]*Tenant, 0, len(tenants))
+	for _, tenant := range tenants {
+		all = append(all, tenant)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Resolve finds the tenant of a request: the one serving its host, or else the one named in front of its api key
+// as in "Bearer acme.us_...". In the latter case the returned request carries the api key alone.
+// The tenant is nil when neither matches.
+func Resolve(r *http.Request) (*Tenant, *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if tenant, ok := byHost[strings.ToLower(host)]; ok {
+		return tenant, r
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return nil, r
+	}
+
+	name, key, found := strings.Cut(strings.TrimSpace(token), ".")
+	tenant, ok := tenants[name]
+	if !found || !ok {
+		return nil, r
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+key)
+
+	return tenant, r
+}
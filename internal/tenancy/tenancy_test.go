@@ -0,0 +1,69 @@
+package tenancy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"valid", `{"acme": {"hosts": ["go.acme.com"]}, "globex": {}}`, true},
+		{"no tenants", `{}`, false},
+		{"invalid name", `{"Acme-Corp": {}}`, false},
+		{"shared host", `{"acme": {"hosts": ["go.example.com"]}, "globex": {"hosts": ["GO.example.com"]}}`, false},
+		{"not json", `acme`, false},
+	}
+
+	for _, tt := range tests {
+		if err := parse([]byte(tt.content)); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid to be %v; got %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if err := parse([]byte(`{"acme": {"hosts": ["go.acme.com"]}, "globex": {}}`)); err != nil {
+		t.Fatalf("could not parse the tenants: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		host          string
+		authorization string
+		expected      string
+		expectedAuth  string
+	}{
+		{"host", "go.acme.com", "Bearer us_abc", "acme", "Bearer us_abc"},
+		{"host with port", "GO.acme.com:8080", "", "acme", ""},
+		{"api key", "localhost", "Bearer globex.us_abc", "globex", "Bearer us_abc"},
+		{"unknown tenant in the api key", "localhost", "Bearer initech.us_abc", "", "Bearer initech.us_abc"},
+		{"plain api key", "localhost", "Bearer us_abc", "", "Bearer us_abc"},
+		{"anonymous", "localhost", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/short/abcdefgh", nil)
+		req.Host = tt.host
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+
+		tenant, resolved := Resolve(req)
+
+		name := ""
+		if tenant != nil {
+			name = tenant.Name
+		}
+		if name != tt.expected {
+			t.Errorf("%s: expected tenant %q; got %q", tt.name, tt.expected, name)
+		}
+		if auth := resolved.Header.Get("Authorization"); auth != tt.expectedAuth {
+			t.Errorf("%s: expected authorization %q; got %q", tt.name, tt.expectedAuth, auth)
+		}
+	}
+}
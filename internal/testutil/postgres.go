@@ -0,0 +1,60 @@
+// Package testutil starts throwaway Postgres containers for tests that need real SQL behavior.
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortner/internal/database"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// NewDatabase starts a Postgres container, applies every migration and returns a Service connected to it.
+// The container is removed when the test finishes. The test is skipped when no Docker daemon is available.
+func NewDatabase(t *testing.T) database.Service {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+
+	container, err := postgres.Run(
+		ctx,
+		"postgres:latest",
+		postgres.WithDatabase("database"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second)),
+	)
+	if err != nil {
+		t.Fatalf("could not start postgres container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Logf("could not terminate postgres container: %v", err)
+		}
+	})
+
+	connStr, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("could not get the connection string: %v", err)
+	}
+
+	db, err := database.Connect(connStr)
+	if err != nil {
+		t.Fatalf("could not connect to postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.BootstrapSchema(); err != nil {
+		t.Fatalf("could not apply the migrations: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,129 @@
+// Package unwrap follows the redirects of public url shorteners, so that links to them can be stored with their
+// final destination instead of a chain of redirects.
+package unwrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"url-shortner/internal/policy"
+)
+
+// Redirects followed for a single link unless UNWRAP_MAX_HOPS says otherwise, chains of shorteners included
+const defaultMaxHops = 5
+
+var (
+	ErrTooManyHops    = errors.New("unwrap: too many redirects")
+	ErrPrivateAddress = errors.New("unwrap: refusing to connect to a private address")
+)
+
+// Carrier-grade NAT, RFC 6598, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Client asks shorteners where their links redirect to. It never connects to private, loopback or link-local
+// addresses, whatever the hostnames resolve to, and never follows redirects on its own.
+type Client struct {
+	httpClient *http.Client
+
+	// MaxHops bounds the redirects followed for a single link
+	MaxHops int
+
+	// Reports whether a link is served by a shortener, policy.KnownShortener by default
+	isShortener func(link string) bool
+}
+
+// New returns a client configured from UNWRAP_SHORTENERS and UNWRAP_MAX_HOPS.
+// It returns nil unless UNWRAP_SHORTENERS is "true", meaning links are stored the way they were submitted.
+func New() *Client {
+	if os.Getenv("UNWRAP_SHORTENERS") != "true" {
+		return nil
+	}
+
+	maxHops, err := strconv.Atoi(os.Getenv("UNWRAP_MAX_HOPS"))
+	if err != nil || maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+
+	client := newClient(publicAddress)
+	client.MaxHops = maxHops
+	return client
+}
+
+func newClient(allowed func(ip net.IP) bool) *Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+
+		// Checked once the hostname is resolved, so a public name pointing at a private address is refused too
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		MaxHops: defaultMaxHops,
+		isShortener: func(link string) bool {
+			_, ok := policy.KnownShortener(link)
+			return ok
+		},
+	}
+}
+
+// Next returns where link redirects to when it is served by a known shortener. It returns false when the link
+// isn't a shortener's or the shortener didn't redirect, e.g. for a deleted link.
+func (c *Client) Next(ctx context.Context, link string) (string, bool, error) {
+	if !c.isShortener(link) {
+		return "", false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("unwrap %s: %w", link, err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("unwrap %s: %w", link, err)
+	}
+	resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+		return "", false, nil
+	}
+
+	next, err := resp.Request.URL.Parse(location)
+	if err != nil || (next.Scheme != "http" && next.Scheme != "https") {
+		return "", false, fmt.Errorf("unwrap %s: invalid redirect to %q", link, location)
+	}
+	return next.String(), true, nil
+}
+
+// publicAddress reports whether ip may be reached from the internet
+func publicAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	return !sharedAddressSpace.Contains(ip)
+}
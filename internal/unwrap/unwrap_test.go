@@ -0,0 +1,93 @@
+package unwrap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/absolute":
+			http.Redirect(w, r, "https://example.com/landing?id=1", http.StatusMovedPermanently)
+		case "/relative":
+			http.Redirect(w, r, "/absolute", http.StatusFound)
+		case "/scheme":
+			w.Header().Set("Location", "javascript:alert(1)")
+			w.WriteHeader(http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := newClient(func(net.IP) bool { return true })
+	client.isShortener = func(string) bool { return true }
+
+	tests := []struct {
+		path        string
+		expected    string
+		expectedOk  bool
+		expectedErr bool
+	}{
+		{"/absolute", "https://example.com/landing?id=1", true, false},
+		{"/relative", server.URL + "/absolute", true, false},
+		{"/deleted", "", false, false},
+		{"/scheme", "", false, true},
+	}
+
+	for _, tt := range tests {
+		next, ok, err := client.Next(context.Background(), server.URL+tt.path)
+		if next != tt.expected || ok != tt.expectedOk || (err != nil) != tt.expectedErr {
+			t.Errorf("%s: expected %q, %v, error %v; got %q, %v, %v", tt.path, tt.expected, tt.expectedOk, tt.expectedErr, next, ok, err)
+		}
+	}
+}
+
+func TestNextRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := newClient(publicAddress)
+	client.isShortener = func(string) bool { return true }
+
+	if _, _, err := client.Next(context.Background(), server.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected %v; got %v", ErrPrivateAddress, err)
+	}
+}
+
+func TestNextSkipsOtherHosts(t *testing.T) {
+	client := newClient(publicAddress)
+
+	if next, ok, err := client.Next(context.Background(), "https://example.com/page"); ok || err != nil {
+		t.Errorf("expected to leave links to other hosts alone; got %q, %v, %v", next, ok, err)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+
+	for address, expected := range tests {
+		if got := publicAddress(net.ParseIP(address)); got != expected {
+			t.Errorf("publicAddress(%s) = %v; expected %v", address, got, expected)
+		}
+	}
+}
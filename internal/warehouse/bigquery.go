@@ -0,0 +1,245 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBigQueryEndpoint = "https://bigquery.googleapis.com/bigquery/v2"
+	bigQueryScope           = "https://www.googleapis.com/auth/bigquery.insertdata"
+)
+
+// bigQuery streams rows with tabledata.insertAll, authenticated as a service account
+type bigQuery struct {
+	endpoint   string
+	project    string
+	dataset    string
+	account    serviceAccount
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// serviceAccount is the part of a Google service account key used to get access tokens
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// newBigQuery reads BIGQUERY_PROJECT, BIGQUERY_DATASET and the service account key at GOOGLE_APPLICATION_CREDENTIALS
+func newBigQuery() (*bigQuery, error) {
+	project, dataset := os.Getenv("BIGQUERY_PROJECT"), os.Getenv("BIGQUERY_DATASET")
+	if project == "" || dataset == "" {
+		return nil, fmt.Errorf("BIGQUERY_PROJECT and BIGQUERY_DATASET are required with WAREHOUSE=bigquery")
+	}
+
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return nil, fmt.Errorf("GOOGLE_APPLICATION_CREDENTIALS is required with WAREHOUSE=bigquery")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	account, err := parseServiceAccount(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+
+	return &bigQuery{
+		endpoint:   defaultBigQueryEndpoint,
+		project:    project,
+		dataset:    dataset,
+		account:    account,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func parseServiceAccount(content []byte) (serviceAccount, error) {
+	var account serviceAccount
+	if err := json.Unmarshal(content, &account); err != nil {
+		return account, err
+	}
+	if account.ClientEmail == "" || account.TokenUri == "" {
+		return account, fmt.Errorf("client_email and token_uri are required")
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return account, fmt.Errorf("private_key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return account, fmt.Errorf("private_key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return account, fmt.Errorf("private_key is not an RSA key")
+	}
+	account.key = rsaKey
+
+	return account, nil
+}
+
+type insertAllRequest struct {
+	Rows []insertAllRow `json:"rows"`
+}
+
+type insertAllRow struct {
+	InsertId string         `json:"insertId,omitempty"`
+	Json     map[string]any `json:"json"`
+}
+
+type insertAllResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+func (b *bigQuery) insert(ctx context.Context, table string, rows []Row) error {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	reqBody := insertAllRequest{}
+	for _, row := range rows {
+		reqBody.Rows = append(reqBody.Rows, insertAllRow{InsertId: row.InsertId, Json: row.Values})
+	}
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", b.endpoint, url.PathEscape(b.project), url.PathEscape(b.dataset), url.PathEscape(table))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("insert failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("insert returned status %d", resp.StatusCode)
+	}
+
+	var respBody insertAllResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	// The other rows of the batch are written, and skipped by their insert id when the batch is sent again
+	if len(respBody.InsertErrors) > 0 {
+		first := respBody.InsertErrors[0]
+		message := "unknown error"
+		if len(first.Errors) > 0 {
+			message = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return fmt.Errorf("%d rows refused, row %d: %s", len(respBody.InsertErrors), first.Index, message)
+	}
+
+	return nil
+}
+
+// accessToken returns the cached access token, or exchanges a JWT signed with the key of the service account for a
+// new one when it's about to expire
+func (b *bigQuery) accessToken(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.token != "" && time.Until(b.tokenExpiry) > time.Minute {
+		return b.token, nil
+	}
+
+	assertion, err := b.account.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.account.TokenUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned status %d", resp.StatusCode)
+	}
+
+	var respBody struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil || respBody.AccessToken == "" {
+		return "", fmt.Errorf("invalid token response: %v", err)
+	}
+
+	b.token, b.tokenExpiry = respBody.AccessToken, time.Now().Add(time.Duration(respBody.ExpiresIn)*time.Second)
+	return b.token, nil
+}
+
+// assertion returns the JWT asking for an access token with the insert scope, valid for an hour from now
+func (a serviceAccount) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   a.ClientEmail,
+		"scope": bigQueryScope,
+		"aud":   a.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign token request: %w", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,83 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// clickHouse inserts through the HTTP interface of ClickHouse, one JSON object per row
+type clickHouse struct {
+	endpoint   string
+	database   string
+	user       string
+	password   string
+	httpClient *http.Client
+}
+
+// newClickHouse reads CLICKHOUSE_URL, e.g. http://clickhouse:8123, CLICKHOUSE_DATABASE, CLICKHOUSE_USER and
+// CLICKHOUSE_PASSWORD
+func newClickHouse() (*clickHouse, error) {
+	endpoint := os.Getenv("CLICKHOUSE_URL")
+	if endpoint == "" {
+		return nil, fmt.Errorf("CLICKHOUSE_URL is required with WAREHOUSE=clickhouse")
+	}
+
+	return &clickHouse{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		database:   envOr("CLICKHOUSE_DATABASE", "default"),
+		user:       envOr("CLICKHOUSE_USER", "default"),
+		password:   os.Getenv("CLICKHOUSE_PASSWORD"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *clickHouse) insert(ctx context.Context, table string, rows []Row) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row.Values); err != nil {
+			return fmt.Errorf("encode row: %w", err)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("query", fmt.Sprintf("INSERT INTO %s.%s FORMAT JSONEachRow", quoteIdentifier(c.database), quoteIdentifier(table)))
+	// Times are sent in RFC 3339
+	query.Set("date_time_input_format", "best_effort")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/?"+query.Encode(), &body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("X-ClickHouse-User", c.user)
+	if c.password != "" {
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("insert failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("insert returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+// quoteIdentifier quotes a database or table name for ClickHouse
+func quoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
@@ -0,0 +1,82 @@
+// Package warehouse ships rows to the data warehouse of the analysts, BigQuery or ClickHouse, as configured by the
+// WAREHOUSE environment variable.
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Rows sent in a single request, the batch size BigQuery recommends for streaming inserts
+const maxBatchSize = 500
+
+// Row is a row of a warehouse table
+type Row struct {
+	// Identifies the row so the warehouse drops it when it's sent again, where supported
+	InsertId string
+
+	// Column values, encoded as JSON. Times are sent in RFC 3339
+	Values map[string]any
+}
+
+// sink writes a batch of rows to a table
+type sink interface {
+	insert(ctx context.Context, table string, rows []Row) error
+}
+
+// Warehouse is the configured warehouse, with the tables rows are shipped to
+type Warehouse struct {
+	// Name of the warehouse, bigquery or clickhouse
+	Kind string
+
+	ClicksTable string
+	LinksTable  string
+
+	sink sink
+}
+
+// New returns the warehouse configured by WAREHOUSE, bigquery or clickhouse, and the variables of that warehouse.
+// It returns nil when WAREHOUSE isn't set, meaning the export is disabled.
+func New() (*Warehouse, error) {
+	warehouse := &Warehouse{
+		Kind:        os.Getenv("WAREHOUSE"),
+		ClicksTable: envOr("WAREHOUSE_CLICKS_TABLE", "click_events"),
+		LinksTable:  envOr("WAREHOUSE_LINKS_TABLE", "links"),
+	}
+
+	var err error
+	switch warehouse.Kind {
+	case "":
+		return nil, nil
+	case "bigquery":
+		warehouse.sink, err = newBigQuery()
+	case "clickhouse":
+		warehouse.sink, err = newClickHouse()
+	default:
+		return nil, fmt.Errorf("invalid WAREHOUSE %q, expected bigquery or clickhouse", warehouse.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return warehouse, nil
+}
+
+// Insert writes rows to table, in batches. The batches sent before one fails stay written
+func (w *Warehouse) Insert(ctx context.Context, table string, rows []Row) error {
+	for start := 0; start < len(rows); start += maxBatchSize {
+		batch := rows[start:min(start+maxBatchSize, len(rows))]
+		if err := w.sink.insert(ctx, table, batch); err != nil {
+			return fmt.Errorf("warehouse: insert %d rows into %s: %w", len(batch), table, err)
+		}
+	}
+	return nil
+}
+
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
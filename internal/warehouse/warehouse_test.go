@@ -0,0 +1,126 @@
+package warehouse
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Setenv("WAREHOUSE", "")
+	if warehouse, err := New(); warehouse != nil || err != nil {
+		t.Errorf("expected the export disabled without WAREHOUSE; got %v, %v", warehouse, err)
+	}
+
+	t.Setenv("WAREHOUSE", "redshift")
+	if _, err := New(); err == nil {
+		t.Error("expected an unknown warehouse to be rejected")
+	}
+
+	t.Setenv("WAREHOUSE", "clickhouse")
+	t.Setenv("CLICKHOUSE_URL", "")
+	if _, err := New(); err == nil {
+		t.Error("expected CLICKHOUSE_URL to be required")
+	}
+
+	t.Setenv("CLICKHOUSE_URL", "http://clickhouse:8123/")
+	warehouse, err := New()
+	if err != nil || warehouse.ClicksTable != "click_events" || warehouse.LinksTable != "links" {
+		t.Errorf("expected the default tables; got %+v, %v", warehouse, err)
+	}
+}
+
+func TestClickHouseInsert(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query := r.URL.Query().Get("query"); query != "INSERT INTO `analytics`.`click_events` FORMAT JSONEachRow" {
+			t.Errorf("unexpected query %q", query)
+		}
+		if r.Header.Get("X-ClickHouse-User") != "exporter" || r.Header.Get("X-ClickHouse-Key") != "secret" {
+			t.Errorf("expected the credentials to be sent")
+		}
+
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+	}))
+	defer server.Close()
+
+	warehouse := &Warehouse{sink: &clickHouse{endpoint: server.URL, database: "analytics", user: "exporter", password: "secret", httpClient: server.Client()}}
+
+	rows := make([]Row, maxBatchSize+1)
+	for i := range rows {
+		rows[i] = Row{Values: map[string]any{"id": i}}
+	}
+	if err := warehouse.Insert(context.Background(), "click_events", rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != len(rows) || lines[0] != `{"id":0}` {
+		t.Errorf("expected a JSON object per row; got %d lines starting with %v", len(lines), lines[:1])
+	}
+}
+
+func TestBigQueryInsert(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := 0
+	var inserted insertAllRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokens++
+			r.ParseForm()
+			if parts := strings.Split(r.Form.Get("assertion"), "."); len(parts) != 3 {
+				t.Errorf("expected a signed JWT; got %q", r.Form.Get("assertion"))
+			}
+			w.Write([]byte(`{"access_token": "ya29.token", "expires_in": 3600}`))
+		case "/projects/acme/datasets/links/tables/click_events/insertAll":
+			if r.Header.Get("Authorization") != "Bearer ya29.token" {
+				t.Errorf("expected the access token to be sent")
+			}
+			json.NewDecoder(r.Body).Decode(&inserted)
+			if inserted.Rows[0].InsertId == "2" {
+				w.Write([]byte(`{"insertErrors": [{"index": 0, "errors": [{"reason": "invalid", "message": "no such field: ip"}]}]}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	bigQuery := &bigQuery{
+		endpoint:   server.URL,
+		project:    "acme",
+		dataset:    "links",
+		account:    serviceAccount{ClientEmail: "exporter@acme.iam.gserviceaccount.com", TokenUri: server.URL + "/token", key: key},
+		httpClient: server.Client(),
+	}
+	warehouse := &Warehouse{sink: bigQuery}
+
+	if err := warehouse.Insert(context.Background(), "click_events", []Row{{InsertId: "1", Values: map[string]any{"id": 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted.Rows) != 1 || inserted.Rows[0].Json["id"] != float64(1) {
+		t.Errorf("expected the row inserted with its id; got %+v", inserted)
+	}
+
+	err = warehouse.Insert(context.Background(), "click_events", []Row{{InsertId: "2", Values: map[string]any{"ip": "203.0.113.0"}}})
+	if err == nil || !strings.Contains(err.Error(), "no such field") {
+		t.Errorf("expected the refused row reported; got %v", err)
+	}
+	if tokens != 1 {
+		t.Errorf("expected the access token reused; got %d token requests", tokens)
+	}
+}
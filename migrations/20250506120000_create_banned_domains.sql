@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE banned_domains (
+    id SERIAL PRIMARY KEY,
+    pattern VARCHAR(253) NOT NULL UNIQUE,
+    reason VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+ALTER TABLE short_url
+ADD COLUMN disabled_at TIMESTAMPTZ,
+ADD COLUMN disabled_reason VARCHAR(255);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS disabled_reason,
+DROP COLUMN IF EXISTS disabled_at;
+
+DROP TABLE banned_domains;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE review_queue (
+    id SERIAL PRIMARY KEY,
+    short_url_id INT NOT NULL REFERENCES short_url(id) ON DELETE CASCADE,
+    score INT NOT NULL,
+    reasons TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    reviewed_at TIMESTAMPTZ
+);
+
+CREATE INDEX review_queue_status_idx ON review_queue (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE review_queue;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE abuse_reports (
+    id SERIAL PRIMARY KEY,
+    short_url_id INT NOT NULL REFERENCES short_url(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL,
+    details TEXT,
+    reporter_email VARCHAR(255),
+    reporter_ip VARCHAR(45),
+    reporter_user_agent VARCHAR(512),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX abuse_reports_status_idx ON abuse_reports (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE abuse_reports;
+-- +goose StatementEnd
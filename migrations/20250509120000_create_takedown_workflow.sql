@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE short_url
+ADD COLUMN moderation_state VARCHAR(20) NOT NULL DEFAULT 'active';
+
+-- No foreign key on purpose: the trail must outlive the link it describes
+CREATE TABLE takedown_events (
+    id SERIAL PRIMARY KEY,
+    short_url_id INT NOT NULL,
+    short_code VARCHAR(10) NOT NULL,
+    from_state VARCHAR(20) NOT NULL,
+    to_state VARCHAR(20) NOT NULL,
+    reason_code VARCHAR(30) NOT NULL,
+    note TEXT,
+    actor VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX takedown_events_short_url_id_idx ON takedown_events (short_url_id);
+
+CREATE FUNCTION takedown_events_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'takedown_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER takedown_events_no_update_delete
+BEFORE UPDATE OR DELETE ON takedown_events
+FOR EACH ROW EXECUTE FUNCTION takedown_events_immutable();
+
+-- Outbox of messages for link owners, delivered by whichever channel is configured
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX notifications_unsent_idx ON notifications (id) WHERE sent_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE notifications;
+DROP TABLE takedown_events;
+DROP FUNCTION takedown_events_immutable();
+
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS moderation_state;
+-- +goose StatementEnd
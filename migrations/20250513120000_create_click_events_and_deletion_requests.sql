@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE click_events (
+    id BIGSERIAL PRIMARY KEY,
+    short_url_id INT NOT NULL REFERENCES short_url(id) ON DELETE CASCADE,
+    ip VARCHAR(45),
+    user_agent VARCHAR(512),
+    referrer VARCHAR(2048),
+    clicked_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX click_events_short_url_id_idx ON click_events (short_url_id, clicked_at);
+
+-- No foreign key on purpose: the request must outlive the user it erases
+CREATE TABLE deletion_requests (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX deletion_requests_pending_idx ON deletion_requests (user_id) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE deletion_requests;
+DROP TABLE click_events;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE click_events
+ADD COLUMN ip_hash VARCHAR(64),
+ADD COLUMN raw_ip VARCHAR(45);
+
+-- Existing addresses are truncated to their /24 (IPv4) or /48 (IPv6) network
+UPDATE click_events
+SET ip = CASE
+    WHEN ip ~ '^[0-9a-fA-F:.]+$' THEN host(network(set_masklen(ip::inet, CASE WHEN family(ip::inet) = 4 THEN 24 ELSE 48 END)))
+    ELSE NULL END
+WHERE ip IS NOT NULL;
+
+CREATE INDEX click_events_raw_ip_idx ON click_events (clicked_at) WHERE raw_ip IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE click_events
+DROP COLUMN IF EXISTS raw_ip,
+DROP COLUMN IF EXISTS ip_hash;
+-- +goose StatementEnd
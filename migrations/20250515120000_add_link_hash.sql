@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE short_url
+ADD COLUMN link_hash CHAR(64);
+
+-- Mirrors normalize.URL: trim, lowercase scheme and authority, drop the default http/https port
+UPDATE short_url
+SET link_hash = encode(sha256(convert_to(
+    CASE WHEN btrim(link, E' \t\n\r\v\f') ~ '^[a-zA-Z][a-zA-Z0-9+.-]*://'
+        THEN regexp_replace(regexp_replace(
+                lower(substring(btrim(link, E' \t\n\r\v\f') from '^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*')),
+                '^(http://.*):80$', '\1'),
+                '^(https://.*):443$', '\1')
+            || substring(btrim(link, E' \t\n\r\v\f') from '^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*(.*)$')
+        ELSE btrim(link, E' \t\n\r\v\f')
+    END, 'UTF8')), 'hex');
+
+ALTER TABLE short_url
+ALTER COLUMN link_hash SET NOT NULL;
+
+CREATE INDEX short_url_link_hash_idx ON short_url (link_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS link_hash;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Clicks are spread over a few rows per link so concurrent redirects don't all update the same row,
+-- and never lock the short_url row itself
+CREATE TABLE link_click_counters (
+    short_url_id INT NOT NULL REFERENCES short_url(id) ON DELETE CASCADE,
+    shard SMALLINT NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url_id, shard)
+);
+
+INSERT INTO link_click_counters (short_url_id, shard, clicks)
+SELECT id, 0, times_clicked FROM short_url WHERE times_clicked > 0;
+
+ALTER TABLE short_url
+DROP COLUMN times_clicked;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url
+ADD COLUMN times_clicked INT DEFAULT 0;
+
+UPDATE short_url SET times_clicked = counters.total
+FROM (SELECT short_url_id, SUM(clicks) AS total FROM link_click_counters GROUP BY short_url_id) AS counters
+WHERE short_url.id = counters.short_url_id;
+
+DROP TABLE link_click_counters;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX short_url_created_at_id_idx ON short_url (created_at DESC, id DESC);
+CREATE INDEX audit_log_created_at_id_idx ON audit_log (created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS audit_log_created_at_id_idx;
+DROP INDEX IF EXISTS short_url_created_at_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE short_url
+ADD COLUMN api_key_id INT REFERENCES api_keys(id) ON DELETE SET NULL;
+
+CREATE INDEX short_url_owner_id_idx ON short_url (owner_id, created_at);
+CREATE INDEX short_url_api_key_id_idx ON short_url (api_key_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS short_url_api_key_id_idx;
+DROP INDEX IF EXISTS short_url_owner_id_idx;
+
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS api_key_id;
+-- +goose StatementEnd
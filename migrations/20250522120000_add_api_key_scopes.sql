@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing keys keep everything they could do before scopes existed
+ALTER TABLE api_keys
+ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{links:write,links:read,stats:read,admin}';
+
+ALTER TABLE api_keys
+ALTER COLUMN scopes SET DEFAULT '{links:write,links:read,stats:read}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys
+DROP COLUMN IF EXISTS scopes;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+    organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+-- Invitations either name an email, only the user with that email can accept them,
+-- or are shared as a link anyone holding it can accept
+CREATE TABLE organization_invitations (
+    id SERIAL PRIMARY KEY,
+    organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ
+);
+
+-- Links of an organization outlive the membership of whoever created them
+ALTER TABLE short_url
+ADD COLUMN organization_id INT REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX short_url_organization_id_idx ON short_url (organization_id, created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE organization_invitations;
+DROP TABLE organization_members;
+DROP TABLE organizations;
+-- +goose StatementEnd
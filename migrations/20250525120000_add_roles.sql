@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Users and members could create links before roles existed, they keep doing so as editors
+UPDATE users SET role = 'editor' WHERE role = 'user';
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'editor';
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('viewer', 'editor', 'admin'));
+
+UPDATE organization_members SET role = 'editor' WHERE role = 'member';
+ALTER TABLE organization_members ALTER COLUMN role SET DEFAULT 'editor';
+ALTER TABLE organization_members ADD CONSTRAINT organization_members_role_check CHECK (role IN ('viewer', 'editor', 'admin', 'owner'));
+
+UPDATE organization_invitations SET role = 'editor' WHERE role = 'member';
+ALTER TABLE organization_invitations ALTER COLUMN role SET DEFAULT 'editor';
+ALTER TABLE organization_invitations ADD CONSTRAINT organization_invitations_role_check CHECK (role IN ('viewer', 'editor', 'admin', 'owner'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE organization_invitations DROP CONSTRAINT IF EXISTS organization_invitations_role_check;
+ALTER TABLE organization_invitations ALTER COLUMN role SET DEFAULT 'member';
+UPDATE organization_invitations SET role = 'member' WHERE role IN ('viewer', 'editor');
+
+ALTER TABLE organization_members DROP CONSTRAINT IF EXISTS organization_members_role_check;
+ALTER TABLE organization_members ALTER COLUMN role SET DEFAULT 'member';
+UPDATE organization_members SET role = 'member' WHERE role IN ('viewer', 'editor');
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'user';
+UPDATE users SET role = 'user' WHERE role IN ('viewer', 'editor');
+-- +goose StatementEnd
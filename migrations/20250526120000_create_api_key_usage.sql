@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Daily counters of each api key, days are UTC
+CREATE TABLE api_key_usage (
+    api_key_id INT NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    links_created BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, day)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE api_key_usage;
+-- +goose StatementEnd
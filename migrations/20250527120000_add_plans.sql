@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN plan VARCHAR(20) NOT NULL DEFAULT 'free' CHECK (plan IN ('free', 'pro', 'enterprise'));
+
+ALTER TABLE organizations
+ADD COLUMN plan VARCHAR(20) NOT NULL DEFAULT 'free' CHECK (plan IN ('free', 'pro', 'enterprise'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE organizations
+DROP COLUMN IF EXISTS plan;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS plan;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE domains (
+    id SERIAL PRIMARY KEY,
+    hostname VARCHAR(253) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Links without a domain are served on every domain of the instance
+ALTER TABLE short_url
+ADD COLUMN domain_id INTEGER REFERENCES domains(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS domain_id;
+
+DROP TABLE domains;
+-- +goose StatementEnd
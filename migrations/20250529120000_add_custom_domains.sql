@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE domains
+ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+ADD COLUMN verification_token VARCHAR(64),
+ADD COLUMN verified_at TIMESTAMPTZ,
+ADD COLUMN checked_at TIMESTAMPTZ;
+
+-- Domains added by admins before custom domains existed are trusted
+UPDATE domains SET verified_at = created_at;
+
+CREATE INDEX domains_unverified_idx ON domains (id) WHERE verified_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS domains_unverified_idx;
+
+ALTER TABLE domains
+DROP COLUMN IF EXISTS checked_at,
+DROP COLUMN IF EXISTS verified_at,
+DROP COLUMN IF EXISTS verification_token,
+DROP COLUMN IF EXISTS organization_id;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE domains
+ADD COLUMN logo_url VARCHAR(2048),
+ADD COLUMN primary_color VARCHAR(7),
+ADD COLUMN background_color VARCHAR(7),
+ADD COLUMN footer_text VARCHAR(200);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE domains
+DROP COLUMN IF EXISTS footer_text,
+DROP COLUMN IF EXISTS background_color,
+DROP COLUMN IF EXISTS primary_color,
+DROP COLUMN IF EXISTS logo_url;
+-- +goose StatementEnd
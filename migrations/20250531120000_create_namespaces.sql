@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE namespaces (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE,
+    description VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Links of a namespace are stored as "<namespace>/<code>", so the unique index on short_code keeps codes unique
+-- within each namespace
+ALTER TABLE short_url
+ALTER COLUMN short_code TYPE VARCHAR(100),
+ADD COLUMN namespace_id INTEGER REFERENCES namespaces(id);
+
+-- The moderation history copies the code of the link
+ALTER TABLE takedown_events
+ALTER COLUMN short_code TYPE VARCHAR(100);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM short_url WHERE namespace_id IS NOT NULL;
+DELETE FROM takedown_events WHERE length(short_code) > 10;
+
+ALTER TABLE takedown_events
+ALTER COLUMN short_code TYPE VARCHAR(10);
+
+ALTER TABLE short_url
+DROP COLUMN IF EXISTS namespace_id,
+ALTER COLUMN short_code TYPE VARCHAR(10);
+
+DROP TABLE namespaces;
+-- +goose StatementEnd
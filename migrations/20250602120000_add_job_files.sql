@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Archives produced by jobs, such as account exports, downloaded once the job is done
+ALTER TABLE jobs ADD COLUMN file BYTEA;
+
+CREATE INDEX jobs_finished_at_idx ON jobs (finished_at) WHERE finished_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX jobs_finished_at_idx;
+ALTER TABLE jobs DROP COLUMN file;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The length of links is enforced by the api, MAX_LINK_LENGTH, rather than by the column
+ALTER TABLE short_url ALTER COLUMN link TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url ALTER COLUMN link TYPE VARCHAR(251);
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Private links only redirect for their owner, unlisted ones are left out of the public endpoints listing links
+ALTER TABLE short_url ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'unlisted', 'private'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE short_url DROP COLUMN visibility;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Heartbeats of the cron jobs, a job whose last run finished too long ago has stopped or is wedged
+CREATE TABLE jobs_status (
+    name VARCHAR(64) PRIMARY KEY,
+    interval_seconds INTEGER NOT NULL,
+    last_started_at TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE jobs_status;
+-- +goose StatementEnd
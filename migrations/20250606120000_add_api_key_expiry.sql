@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keys issued by a sign in stop authenticating after a while, those created from the api never do
+ALTER TABLE api_keys
+ADD COLUMN expires_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys
+DROP COLUMN IF EXISTS expires_at;
+-- +goose StatementEnd
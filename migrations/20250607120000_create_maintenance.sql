@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The maintenance switch, a single row read by every replica of the api and by the cronjobs
+CREATE TABLE maintenance (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO maintenance DEFAULT VALUES;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE maintenance;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A failed run leaves last_finished_at alone, so a job that keeps failing shows up as late, with its error
+ALTER TABLE jobs_status ADD COLUMN last_failed_at TIMESTAMPTZ;
+ALTER TABLE jobs_status ADD COLUMN last_error TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs_status DROP COLUMN last_error;
+ALTER TABLE jobs_status DROP COLUMN last_failed_at;
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When a link last changed, for the Last-Modified of the listings. Clicks are dated on their counter shards, so
+-- redirects still never write the short_url row
+ALTER TABLE short_url ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+UPDATE short_url SET updated_at = created_at;
+
+CREATE FUNCTION short_url_touch() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER short_url_updated_at
+BEFORE UPDATE ON short_url
+FOR EACH ROW EXECUTE FUNCTION short_url_touch();
+
+ALTER TABLE link_click_counters ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- A deleted link leaves nothing to date, the last deletion is kept instead
+CREATE TABLE short_url_deletions (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO short_url_deletions DEFAULT VALUES;
+
+-- Once per statement, and only when it deleted something: the cleanup of expired links runs every minute
+CREATE FUNCTION short_url_record_deletion() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM deleted) THEN
+        UPDATE short_url_deletions SET last_deleted_at = NOW();
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER short_url_deleted_at
+AFTER DELETE ON short_url
+REFERENCING OLD TABLE AS deleted
+FOR EACH STATEMENT EXECUTE FUNCTION short_url_record_deletion();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER short_url_deleted_at ON short_url;
+DROP FUNCTION short_url_record_deletion();
+DROP TABLE short_url_deletions;
+ALTER TABLE link_click_counters DROP COLUMN updated_at;
+DROP TRIGGER short_url_updated_at ON short_url;
+DROP FUNCTION short_url_touch();
+ALTER TABLE short_url DROP COLUMN updated_at;
+-- +goose StatementEnd
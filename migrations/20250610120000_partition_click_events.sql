@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Click events are partitioned by month of clicked_at, in UTC, so those past the retention are dropped a partition
+-- at a time. The existing table becomes the partition of every click made until the end of the current month, later
+-- partitions are created ahead by the manage_click_partitions cron job
+ALTER TABLE click_events RENAME TO click_events_history;
+ALTER INDEX click_events_short_url_id_idx RENAME TO click_events_history_short_url_id_idx;
+ALTER INDEX click_events_raw_ip_idx RENAME TO click_events_history_raw_ip_idx;
+
+-- The key of a partitioned table must hold the partition key
+ALTER TABLE click_events_history DROP CONSTRAINT click_events_pkey;
+
+UPDATE click_events_history SET clicked_at = short_url.created_at
+FROM short_url WHERE short_url.id = click_events_history.short_url_id AND click_events_history.clicked_at IS NULL;
+ALTER TABLE click_events_history ALTER COLUMN clicked_at SET NOT NULL;
+
+CREATE TABLE click_events (
+    id BIGINT NOT NULL DEFAULT nextval('click_events_id_seq'),
+    short_url_id INT NOT NULL REFERENCES short_url(id) ON DELETE CASCADE,
+    ip VARCHAR(45),
+    user_agent VARCHAR(512),
+    referrer VARCHAR(2048),
+    clicked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ip_hash VARCHAR(64),
+    raw_ip VARCHAR(45),
+    PRIMARY KEY (id, clicked_at)
+) PARTITION BY RANGE (clicked_at);
+
+-- Dropping the history partition must not take the ids with it
+ALTER SEQUENCE click_events_id_seq OWNED BY click_events.id;
+
+CREATE INDEX click_events_short_url_id_idx ON click_events (short_url_id, clicked_at);
+CREATE INDEX click_events_raw_ip_idx ON click_events (clicked_at) WHERE raw_ip IS NOT NULL;
+
+ALTER TABLE click_events ATTACH PARTITION click_events_history
+FOR VALUES FROM (MINVALUE) TO ((date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '1 month') AT TIME ZONE 'UTC');
+
+-- Next month, so clicks have a partition until the cron job first runs
+DO $$
+DECLARE
+    next_month TIMESTAMP := date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '1 month';
+BEGIN
+    EXECUTE format('CREATE TABLE %I PARTITION OF click_events FOR VALUES FROM (%L) TO (%L)',
+        'click_events_' || to_char(next_month, 'YYYYMMDD'),
+        next_month AT TIME ZONE 'UTC', (next_month + INTERVAL '1 month') AT TIME ZONE 'UTC');
+END;
+$$;
+
+-- Clicks falling in no partition, e.g. while the cron job is down. A partition can't be created over the clicks
+-- held here, see manage_click_partitions
+CREATE TABLE click_events_default PARTITION OF click_events DEFAULT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE click_events DETACH PARTITION click_events_history;
+ALTER TABLE click_events_history DROP CONSTRAINT click_events_history_pkey;
+INSERT INTO click_events_history SELECT * FROM click_events;
+ALTER SEQUENCE click_events_id_seq OWNED BY click_events_history.id;
+DROP TABLE click_events;
+
+ALTER TABLE click_events_history ALTER COLUMN clicked_at DROP NOT NULL;
+ALTER TABLE click_events_history ADD CONSTRAINT click_events_pkey PRIMARY KEY (id);
+ALTER INDEX click_events_history_short_url_id_idx RENAME TO click_events_short_url_id_idx;
+ALTER INDEX click_events_history_raw_ip_idx RENAME TO click_events_raw_ip_idx;
+ALTER TABLE click_events_history RENAME TO click_events;
+-- +goose StatementEnd
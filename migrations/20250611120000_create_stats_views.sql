@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Aggregations over every link, too heavy to run on each request. They are refreshed by the refresh_stats_views
+-- cron job, and the api queries the tables directly while they are stale. The live queries in stats_views.go must
+-- be kept in step with these definitions
+
+-- Links and clicks per destination domain
+CREATE MATERIALIZED VIEW destination_domain_stats AS
+SELECT COALESCE(lower(substring(short_url.link from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')), '') AS domain,
+    COUNT(*)::bigint AS links,
+    COALESCE(SUM(counted.clicks), 0)::bigint AS clicks
+FROM short_url
+LEFT JOIN (SELECT short_url_id, SUM(clicks) AS clicks FROM link_click_counters GROUP BY short_url_id) counted
+    ON counted.short_url_id = short_url.id
+GROUP BY 1;
+
+-- REFRESH ... CONCURRENTLY needs a unique index, it keeps the view readable while refreshed
+CREATE UNIQUE INDEX destination_domain_stats_domain_idx ON destination_domain_stats (domain);
+
+-- The most clicked links
+CREATE MATERIALIZED VIEW link_leaderboard AS
+SELECT short_url.id AS short_url_id, short_url.short_code, short_url.link, short_url.visibility, SUM(link_click_counters.clicks)::bigint AS clicks
+FROM short_url
+JOIN link_click_counters ON link_click_counters.short_url_id = short_url.id
+GROUP BY short_url.id
+ORDER BY clicks DESC
+LIMIT 1000;
+
+CREATE UNIQUE INDEX link_leaderboard_short_url_id_idx ON link_leaderboard (short_url_id);
+
+-- When each view was last refreshed, views don't keep it themselves
+CREATE TABLE stats_view_refreshes (
+    view_name TEXT PRIMARY KEY,
+    refreshed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO stats_view_refreshes (view_name) VALUES ('destination_domain_stats'), ('link_leaderboard');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE stats_view_refreshes;
+DROP MATERIALIZED VIEW link_leaderboard;
+DROP MATERIALIZED VIEW destination_domain_stats;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Clicks per link and day, kept after the click events they were counted from are purged. Filled by the
+-- rollup_clicks cron job
+CREATE TABLE click_daily_rollups (
+    short_url_id INT NOT NULL REFERENCES short_url(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    clicks BIGINT NOT NULL,
+    unique_visitors BIGINT NOT NULL,
+    PRIMARY KEY (short_url_id, day)
+);
+CREATE INDEX click_daily_rollups_day_idx ON click_daily_rollups (day);
+
+-- The clicks recorded so far, before any of them is purged
+INSERT INTO click_daily_rollups (short_url_id, day, clicks, unique_visitors)
+SELECT short_url_id, date_trunc('day', clicked_at)::date, COUNT(*), COUNT(DISTINCT ip_hash)
+FROM click_events
+GROUP BY 1, 2;
+
+-- Retentions of an organization overriding those of its plan. NULL follows the plan, 0 keeps forever
+ALTER TABLE organizations
+    ADD COLUMN click_retention_days INT CHECK (click_retention_days >= 0),
+    ADD COLUMN rollup_retention_days INT CHECK (rollup_retention_days >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE organizations DROP COLUMN rollup_retention_days, DROP COLUMN click_retention_days;
+DROP TABLE click_daily_rollups;
+-- +goose StatementEnd
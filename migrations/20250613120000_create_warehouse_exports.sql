@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- How far each stream was shipped to the warehouse by the export_to_warehouse cron job: click events by id, links
+-- by the time and id of their last change
+CREATE TABLE warehouse_exports (
+    stream TEXT PRIMARY KEY,
+    last_id BIGINT NOT NULL DEFAULT 0,
+    last_updated_at TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+    exported_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX short_url_updated_at_idx ON short_url (updated_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX short_url_updated_at_idx;
+DROP TABLE warehouse_exports;
+-- +goose StatementEnd
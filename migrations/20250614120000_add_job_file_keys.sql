@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Where the file of a job is kept in the blobstore, when one is configured. The file column holds it otherwise
+ALTER TABLE jobs ADD COLUMN file_key TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs DROP COLUMN file_key;
+-- +goose StatementEnd
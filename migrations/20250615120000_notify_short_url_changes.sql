@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tells the api replicas listening on short_url_changes which links to drop from their caches, once the change is
+-- committed. The schema is sent along since tenants share the channel
+CREATE FUNCTION notify_short_url_change() RETURNS trigger AS $$
+DECLARE
+    changed short_url;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    PERFORM pg_notify('short_url_changes', json_build_object('schema', TG_TABLE_SCHEMA, 'short_code', changed.short_code)::text);
+    IF TG_OP = 'UPDATE' AND OLD.short_code IS DISTINCT FROM NEW.short_code THEN
+        PERFORM pg_notify('short_url_changes', json_build_object('schema', TG_TABLE_SCHEMA, 'short_code', OLD.short_code)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER short_url_changes
+AFTER INSERT OR DELETE ON short_url
+FOR EACH ROW EXECUTE FUNCTION notify_short_url_change();
+
+-- Updates leaving the row as it was change nothing cached
+CREATE TRIGGER short_url_updates
+AFTER UPDATE ON short_url
+FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION notify_short_url_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER short_url_updates ON short_url;
+DROP TRIGGER short_url_changes ON short_url;
+DROP FUNCTION notify_short_url_change();
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Set by the admin api to have the cronjobs run a job at once, outside its schedule, and cleared once it is picked up
+ALTER TABLE jobs_status ADD COLUMN run_requested_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs_status DROP COLUMN run_requested_at;
+-- +goose StatementEnd
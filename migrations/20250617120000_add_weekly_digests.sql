@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Users receive the weekly digest of their links unless they opt out. digest_sent_at keeps a rerun of the job from
+-- sending it twice in a week
+ALTER TABLE users ADD COLUMN weekly_digest BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN digest_sent_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN digest_sent_at;
+ALTER TABLE users DROP COLUMN weekly_digest;
+-- +goose StatementEnd
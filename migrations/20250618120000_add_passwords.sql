@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- bcrypt hash of the password of the user, NULL for users signing in through a provider only
+ALTER TABLE users ADD COLUMN password_hash VARCHAR(72);
+
+-- Each reset is used at most once, and using one uses up the other pending resets of the user
+CREATE TABLE password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    ip VARCHAR(45),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX password_resets_user_id_idx ON password_resets (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE password_resets;
+
+ALTER TABLE users DROP COLUMN password_hash;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NULL until the user proves owning the email. Accounts created so far, by admins or sign in providers, are trusted
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+UPDATE users SET email_verified_at = COALESCE(created_at, NOW());
+
+-- A verification only verifies the email it was sent to, should the user have changed it since
+CREATE TABLE email_verifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX email_verifications_user_id_idx ON email_verifications (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE email_verifications;
+
+ALTER TABLE users DROP COLUMN email_verified_at;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Claims of the links created anonymously, identified by the hash of the token handed to their creator. A claim is
+-- deleted once used, along with its link otherwise
+CREATE TABLE link_claims (
+    short_url_id INT PRIMARY KEY REFERENCES short_url(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE link_claims;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Edit tokens of the links created anonymously, identified by their hash. They let the creator change or delete the
+-- link without an account, until it is claimed into one
+CREATE TABLE link_edit_tokens (
+    short_url_id INT PRIMARY KEY REFERENCES short_url(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE link_edit_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+// Package migrations embeds the goose migrations so the server can tell whether the schema is up to date,
+// and create it on a fresh database.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration is the Up section of a goose migration file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+}
+
+// All returns the bundled migrations, oldest first.
+func All() ([]Migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version prefix: %w", name, err)
+		}
+
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			Up:      upSection(string(content)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// LatestVersion returns the version of the newest migration, taken from its file name prefix.
+func LatestVersion() (int64, error) {
+	migrations, err := All()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// upSection keeps what sits between the "+goose Up" and "+goose Down" annotations.
+// The StatementBegin/End annotations are plain SQL comments and can stay.
+func upSection(content string) string {
+	_, up, found := strings.Cut(content, "-- +goose Up")
+	if !found {
+		return ""
+	}
+
+	up, _, _ = strings.Cut(up, "-- +goose Down")
+	return strings.TrimSpace(up)
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("could not read the migrations: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("expected bundled migrations")
+	}
+
+	for i, migration := range migrations {
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			t.Errorf("expected %s to come after %s", migration.Name, migrations[i-1].Name)
+		}
+
+		if migration.Up == "" {
+			t.Errorf("expected %s to have an Up section", migration.Name)
+		}
+
+		if strings.Contains(migration.Up, "+goose Down") {
+			t.Errorf("expected the Up section of %s to stop at the Down annotation", migration.Name)
+		}
+	}
+
+	latest, err := LatestVersion()
+	if err != nil || latest != migrations[len(migrations)-1].Version {
+		t.Errorf("expected the latest version to be %d; got %d, %v", migrations[len(migrations)-1].Version, latest, err)
+	}
+}
+
+func TestUpSection(t *testing.T) {
+	content := "-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE t ();\n-- +goose StatementEnd\n\n-- +goose Down\nDROP TABLE t;\n"
+
+	if up := upSection(content); up != "-- +goose StatementBegin\nCREATE TABLE t ();\n-- +goose StatementEnd" {
+		t.Errorf("unexpected Up section: %q", up)
+	}
+}